@@ -0,0 +1,251 @@
+// Package anonymize rewrites the personal data in a copy of the database,
+// so that staging can run on data shaped like production's without holding
+// anyone's name, email address or writing.
+//
+// Fake values are derived from the real ones with a keyed hash: the same
+// email becomes the same fake wherever it is stored, and runs with the same
+// key give the same result, while the fakes cannot be traced back without
+// the key.
+package anonymize
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const pageSize = 500
+
+// Faker derives fake values from real ones.
+type Faker struct {
+	key []byte
+}
+
+func NewFaker(key string) *Faker {
+	return &Faker{key: []byte(key)}
+}
+
+func (f *Faker) sum(kind, v string) []byte {
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(v))
+	return mac.Sum(nil)
+}
+
+// Email returns an address in the reserved .invalid domain. Addresses are
+// compared case-insensitively, as the API stores them, so that fakes stay
+// unique where the real ones are.
+func (f *Faker) Email(email string) string {
+	if email == "" {
+		return ""
+	}
+	sum := f.sum("email", strings.ToLower(strings.TrimSpace(email)))
+	return "user-" + hex.EncodeToString(sum[:8]) + "@example.invalid"
+}
+
+var words = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing
+elit sed do eiusmod tempor incididunt ut labore et dolore magna aliqua enim ad
+minim veniam quis nostrud exercitation ullamco laboris nisi aliquip ex ea
+commodo consequat duis aute irure in reprehenderit voluptate velit esse cillum
+fugiat nulla pariatur excepteur sint occaecat cupidatat non proident sunt
+culpa qui officia deserunt mollit anim id est laborum`)
+
+// Text returns filler text with as many words as s, so that lengths stay
+// realistic.
+func (f *Faker) Text(s string) string {
+	n := len(strings.Fields(s))
+	if n == 0 {
+		return s
+	}
+	var seed [32]byte
+	copy(seed[:], f.sum("text", s))
+	rnd := rand.New(rand.NewChaCha8(seed))
+
+	fake := make([]string, n)
+	for i := range fake {
+		fake[i] = words[rnd.IntN(len(words))]
+	}
+	return strings.Join(fake, " ")
+}
+
+var (
+	givenNames = strings.Fields(`Alex Avery Blake Casey Charlie Dana Drew Eden
+Elliot Emery Finley Harper Hayden Jamie Jordan Kai Logan Morgan Noel Parker
+Quinn Reese Riley Rowan Sage Sam Skyler Taylor`)
+	familyNames = strings.Fields(`Abbott Baker Carter Dalton Ellis Fischer
+Garcia Hughes Ito Jensen Keller Larsen Moreau Nakamura Novak Olsen Patel Quint
+Rossi Silva Tanaka Umar Vogel Weber Young Zimmer`)
+)
+
+// Name returns a fake full name. Blank names, which mean none was set, stay
+// blank.
+func (f *Faker) Name(name string) string {
+	if strings.TrimSpace(name) == "" {
+		return name
+	}
+	sum := f.sum("name", name)
+	return givenNames[int(sum[0])%len(givenNames)] + " " + familyNames[int(sum[1])%len(familyNames)]
+}
+
+// IP returns an address in 10.0.0.0/8, whatever the family of ip.
+func (f *Faker) IP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	sum := f.sum("ip", ip)
+	return net.IPv4(10, sum[0], sum[1], sum[2]).String()
+}
+
+// Details rewrites the emails in the details of an audit entry: the string
+// values of the keys ending in email, such as email and old_email.
+func (f *Faker) Details(details string) string {
+	var d map[string]any
+	if err := json.Unmarshal([]byte(details), &d); err != nil || d == nil {
+		return details
+	}
+	rewritten := false
+	for k, v := range d {
+		email, ok := v.(string)
+		if !ok || !strings.HasSuffix(k, "email") {
+			continue
+		}
+		d[k] = f.Email(email)
+		rewritten = true
+	}
+	if !rewritten {
+		return details
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return details
+	}
+	return string(b)
+}
+
+// Column is a column holding personal data, rewritten row by row. Key is
+// the single-column primary key of Table, by which rows are paged.
+type Column struct {
+	Table string
+	Key   string
+	Name  string
+	Fake  func(*Faker, string) string
+}
+
+// Columns are the columns Run rewrites.
+var Columns = []Column{
+	{"users", "id", "email", (*Faker).Email},
+	{"users", "id", "display_name", (*Faker).Name},
+	{"invitations", "id", "email", (*Faker).Email},
+	{"transfers", "id", "note", (*Faker).Text},
+	{"annotations", "id", "text", (*Faker).Text},
+	{"annotations", "id", "note", (*Faker).Text},
+	{"interaction_events", "id", "query", (*Faker).Text},
+	{"reviews", "id", "title", (*Faker).Text},
+	{"reviews", "id", "body", (*Faker).Text},
+	{"audit_log", "id", "ip", (*Faker).IP},
+	{"audit_log", "id", "details", (*Faker).Details},
+	{"api_keys", "id", "last_used_ip", (*Faker).IP},
+}
+
+// Clears are statements run after the columns are rewritten, for data
+// that is not worth faking: credentials, which must not work on staging,
+// stored responses, provider payloads and dead letters, whose payloads are
+// whole events such as users'. API keys and invitations are kept
+// for the audit log and memberships to refer to, but their unique hashes are
+// replaced by the row's id, which no key or token hashes to.
+var Clears = []string{
+	"UPDATE users SET password_hash = ''",
+	"DELETE FROM sessions",
+	"DELETE FROM email_verification_tokens",
+	"DELETE FROM password_reset_tokens",
+	"UPDATE api_keys SET key_hash = CAST(id AS VARCHAR(64))",
+	"DELETE FROM service_account_secrets",
+	"UPDATE invitations SET token_hash = CAST(id AS VARCHAR(64))",
+	"DELETE FROM idempotency_keys",
+	"UPDATE payment_events SET payload = '{}'",
+	"DELETE FROM dead_letters",
+}
+
+// Result counts the values Run rewrote and the rows the clears touched.
+type Result struct {
+	Rewritten int
+	Cleared   int64
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d values rewritten, %d rows cleared", r.Rewritten, r.Cleared)
+}
+
+type row struct {
+	RowKey   string
+	RowValue string
+}
+
+// Run rewrites Columns and runs Clears in one transaction, so that a
+// failed run leaves the database as it was.
+func Run(ctx context.Context, db *gorm.DB, f *Faker) (Result, error) {
+	var res Result
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, c := range Columns {
+			n, err := rewrite(tx, f, c)
+			res.Rewritten += n
+			if err != nil {
+				return fmt.Errorf("anonymize %s.%s: %w", c.Table, c.Name, err)
+			}
+		}
+		for _, stmt := range Clears {
+			r := tx.Exec(stmt)
+			if r.Error != nil {
+				return fmt.Errorf("anonymize: %s: %w", stmt, r.Error)
+			}
+			res.Cleared += r.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+func rewrite(tx *gorm.DB, f *Faker, c Column) (int, error) {
+	n, last := 0, ""
+	for {
+		var rows []row
+		q := tx.Table(c.Table).
+			Select(fmt.Sprintf("%s AS row_key, %s AS row_value", c.Key, c.Name)).
+			Order(c.Key).
+			Limit(pageSize)
+		if last != "" {
+			q = q.Where(c.Key+" > ?", last)
+		}
+		if err := q.Scan(&rows).Error; err != nil {
+			return n, err
+		}
+
+		for _, r := range rows {
+			fake := c.Fake(f, r.RowValue)
+			if fake == r.RowValue {
+				continue
+			}
+			if err := tx.Table(c.Table).Where(c.Key+" = ?", r.RowKey).Update(c.Name, fake).Error; err != nil {
+				return n, err
+			}
+			n++
+		}
+
+		if len(rows) < pageSize {
+			return n, nil
+		}
+		last = rows[len(rows)-1].RowKey
+	}
+}
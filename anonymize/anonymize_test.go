@@ -0,0 +1,137 @@
+package anonymize_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/anonymize"
+	"hello/api/resource/apikey"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+)
+
+var schema = []string{
+	"CREATE TABLE users (id TEXT PRIMARY KEY, email TEXT NOT NULL UNIQUE, display_name TEXT NOT NULL DEFAULT '', password_hash TEXT NOT NULL)",
+	"CREATE TABLE invitations (id TEXT PRIMARY KEY, email TEXT NOT NULL, token_hash TEXT NOT NULL UNIQUE)",
+	"CREATE TABLE transfers (id TEXT PRIMARY KEY, note TEXT NOT NULL DEFAULT '')",
+	"CREATE TABLE annotations (id TEXT PRIMARY KEY, text TEXT NOT NULL DEFAULT '', note TEXT NOT NULL DEFAULT '')",
+	"CREATE TABLE interaction_events (id TEXT PRIMARY KEY, query TEXT NOT NULL DEFAULT '')",
+	"CREATE TABLE reviews (id TEXT PRIMARY KEY, title TEXT NOT NULL DEFAULT '', body TEXT NOT NULL DEFAULT '')",
+	"CREATE TABLE audit_log (id TEXT PRIMARY KEY, ip TEXT NOT NULL DEFAULT '', details TEXT NOT NULL DEFAULT '{}')",
+	"CREATE TABLE service_account_secrets (id TEXT PRIMARY KEY, secret_hash TEXT NOT NULL)",
+	"CREATE TABLE sessions (id TEXT PRIMARY KEY)",
+	"CREATE TABLE email_verification_tokens (token_hash TEXT PRIMARY KEY)",
+	"CREATE TABLE password_reset_tokens (token_hash TEXT PRIMARY KEY)",
+	"CREATE TABLE idempotency_keys (caller TEXT, key TEXT, PRIMARY KEY (caller, key))",
+	"CREATE TABLE payment_events (id TEXT PRIMARY KEY, payload TEXT NOT NULL)",
+	"CREATE TABLE dead_letters (id TEXT PRIMARY KEY, payload TEXT NOT NULL)",
+}
+
+func TestFaker(t *testing.T) {
+	t.Parallel()
+
+	f := anonymize.NewFaker("staging")
+
+	// Fakes only depend on the key and the real value.
+	testUtil.Equal(t, f.Email("ada@example.com"), anonymize.NewFaker("staging").Email("Ada@Example.com"))
+	testUtil.Equal(t, true, f.Email("ada@example.com") != anonymize.NewFaker("other").Email("ada@example.com"))
+	testUtil.Equal(t, true, strings.HasSuffix(f.Email("ada@example.com"), "@example.invalid"))
+	testUtil.Equal(t, "", f.Email(""))
+
+	text := f.Text("a note about my copy")
+	testUtil.Equal(t, text, f.Text("a note about my copy"))
+	testUtil.Equal(t, 5, len(strings.Fields(text)))
+	testUtil.Equal(t, "", f.Text(""))
+
+	testUtil.Equal(t, true, strings.HasPrefix(f.IP("2001:db8::1"), "10."))
+	testUtil.Equal(t, `{"email":"`+f.Email("ada@example.com")+`","role":"editor"}`, f.Details(`{"email":"ada@example.com","role":"editor"}`))
+	testUtil.Equal(t, `{"email":"`+f.Email("ada@example.com")+`","old_email":"`+f.Email("ada@old.example")+`"}`,
+		f.Details(`{"email":"ada@example.com","old_email":"ada@old.example"}`))
+	testUtil.Equal(t, `{"into":"x"}`, f.Details(`{"into":"x"}`))
+
+	name := f.Name("Ada Lovelace")
+	testUtil.Equal(t, name, f.Name("Ada Lovelace"))
+	testUtil.Equal(t, 2, len(strings.Fields(name)))
+	testUtil.Equal(t, "", f.Name(""))
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t)
+	for _, stmt := range schema {
+		testUtil.NoError(t, db.Exec(stmt).Error)
+	}
+	testUtil.NoError(t, db.AutoMigrate(&apikey.Key{}))
+	keyHash := hash("ak_production")
+	for _, stmt := range []string{
+		"INSERT INTO users VALUES ('u1', 'ada@example.com', 'Ada Lovelace', 'hash'), ('u2', 'alan@example.com', '', 'hash')",
+		"INSERT INTO invitations VALUES ('i1', 'ADA@example.com', 'a1b2'), ('i2', 'alan@example.com', 'c3d4')",
+		"INSERT INTO annotations VALUES ('a1', 'highlighted passage', 'my own thoughts here')",
+		"INSERT INTO reviews VALUES ('r1', '', 'My grandmother read this to me')",
+		"INSERT INTO audit_log VALUES ('l1', '203.0.113.7', '{\"email\":\"ada@example.com\",\"old_email\":\"ada@old.example\"}')",
+		"INSERT INTO service_account_secrets VALUES ('x1', 'e5f6')",
+		"INSERT INTO sessions VALUES ('s1')",
+		"INSERT INTO payment_events VALUES ('p1', '{\"customer_email\":\"ada@example.com\"}')",
+		"INSERT INTO dead_letters VALUES ('d1', '{\"email\":\"ada@example.com\"}')",
+	} {
+		testUtil.NoError(t, db.Exec(stmt).Error)
+	}
+	testUtil.NoError(t, db.Create(&apikey.Key{ID: uuid.New(), TenantID: "acme", Name: "ci", KeyHash: keyHash, CreatedAt: time.Now()}).Error)
+	keys := apikey.NewRepository(db)
+	_, err := keys.Authenticate(keyHash, "203.0.113.7", time.Now())
+	testUtil.NoError(t, err)
+
+	f := anonymize.NewFaker("staging")
+	res, err := anonymize.Run(context.Background(), db, f)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, anonymize.Result{Rewritten: 11, Cleared: 9}, res)
+
+	var email, name, invited, hash string
+	testUtil.NoError(t, db.Raw("SELECT email, display_name, password_hash FROM users WHERE id = 'u1'").Row().Scan(&email, &name, &hash))
+	testUtil.NoError(t, db.Raw("SELECT email FROM invitations WHERE id = 'i1'").Row().Scan(&invited))
+	testUtil.Equal(t, f.Email("ada@example.com"), email)
+	testUtil.Equal(t, email, invited)
+	testUtil.Equal(t, f.Name("Ada Lovelace"), name)
+	testUtil.Equal(t, "", hash)
+
+	var note, details, ip, payload string
+	testUtil.NoError(t, db.Raw("SELECT note FROM annotations WHERE id = 'a1'").Row().Scan(&note))
+	testUtil.Equal(t, 4, len(strings.Fields(note)))
+	testUtil.Equal(t, false, strings.Contains(note, "thoughts"))
+	var body string
+	testUtil.NoError(t, db.Raw("SELECT body FROM reviews WHERE id = 'r1'").Row().Scan(&body))
+	testUtil.Equal(t, f.Text("My grandmother read this to me"), body)
+	testUtil.NoError(t, db.Raw("SELECT ip, details FROM audit_log WHERE id = 'l1'").Row().Scan(&ip, &details))
+	testUtil.Equal(t, f.IP("203.0.113.7"), ip)
+	testUtil.Equal(t, `{"email":"`+email+`","old_email":"`+f.Email("ada@old.example")+`"}`, details)
+	testUtil.NoError(t, db.Raw("SELECT payload FROM payment_events").Row().Scan(&payload))
+	testUtil.Equal(t, "{}", payload)
+
+	var sessions, letters int64
+	testUtil.NoError(t, db.Table("sessions").Count(&sessions).Error)
+	testUtil.Equal(t, int64(0), sessions)
+	testUtil.NoError(t, db.Table("dead_letters").Count(&letters).Error)
+	testUtil.Equal(t, int64(0), letters)
+
+	// Production credentials do not work on the copy.
+	_, err = keys.Authenticate(keyHash, "203.0.113.7", time.Now())
+	testUtil.Equal(t, gorm.ErrRecordNotFound, err)
+	var secrets, invites int64
+	testUtil.NoError(t, db.Table("service_account_secrets").Count(&secrets).Error)
+	testUtil.Equal(t, int64(0), secrets)
+	testUtil.NoError(t, db.Table("invitations").Where("token_hash IN ?", []string{"a1b2", "c3d4"}).Count(&invites).Error)
+	testUtil.Equal(t, int64(0), invites)
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,299 @@
+// Package access restricts routes by the network and country requests come
+// from, for deployments that must not serve some regions. Rules are read
+// from a JSON file mapping resources and routes to allow and deny lists of
+// CIDRs and ISO 3166-1 country codes; countries are looked up in a MaxMind
+// DB, see geoip.
+package access
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	e "hello/api/resource/common/err"
+)
+
+var (
+	RespNetworkDenied = e.New(http.StatusForbidden, "network_denied", "not available from your network")
+	RespRegionDenied  = e.New(http.StatusUnavailableForLegalReasons, "region_denied", "not available in your country")
+)
+
+// Rule restricts a route by the client address, in order:
+//
+//   - addresses in DenyCIDRs are denied, and those in AllowCIDRs allowed;
+//   - addresses located in DenyCountries are denied;
+//   - with AllowCIDRs or AllowCountries, addresses in neither are denied,
+//     including those whose country is unknown;
+//   - other addresses are allowed.
+//
+// Allowing a CIDR thus lets, e.g., an office network through a country
+// block.
+//
+//	{"allow_cidrs": ["10.0.0.0/8"], "deny_countries": ["KP", "IR"]}
+type Rule struct {
+	AllowCIDRs     []netip.Prefix
+	DenyCIDRs      []netip.Prefix
+	AllowCountries []string
+	DenyCountries  []string
+}
+
+type ruleJSON struct {
+	AllowCIDRs     []string `json:"allow_cidrs"`
+	DenyCIDRs      []string `json:"deny_cidrs"`
+	AllowCountries []string `json:"allow_countries"`
+	DenyCountries  []string `json:"deny_countries"`
+}
+
+func (rule *Rule) UnmarshalJSON(b []byte) error {
+	var v ruleJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	allow, err := ParseCIDRs(v.AllowCIDRs)
+	if err != nil {
+		return err
+	}
+	deny, err := ParseCIDRs(v.DenyCIDRs)
+	if err != nil {
+		return err
+	}
+	*rule = Rule{
+		AllowCIDRs:     allow,
+		DenyCIDRs:      deny,
+		AllowCountries: upper(v.AllowCountries),
+		DenyCountries:  upper(v.DenyCountries),
+	}
+	return nil
+}
+
+// ParseCIDRs parses CIDRs, and single addresses as networks of their own.
+func ParseCIDRs(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cidr %q", s)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", s)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+func upper(codes []string) []string {
+	out := make([]string, len(codes))
+	for i, c := range codes {
+		out[i] = strings.ToUpper(strings.TrimSpace(c))
+	}
+	return out
+}
+
+func (rule *Rule) usesCountries() bool {
+	return len(rule.AllowCountries) > 0 || len(rule.DenyCountries) > 0
+}
+
+// Config maps routes to rules, like cache policies: routes are named by
+// method and pattern as declared, e.g. "GET /books/{id}", and resources by
+// the first segment of their patterns, e.g. "orders" for every route under
+// /orders. Default applies to routes with neither.
+//
+//	{"default": {"deny_countries": ["KP"]},
+//	 "resources": {"orders": {"allow_countries": ["DE", "AT", "CH"]}},
+//	 "routes": {"POST /admin/rebuild": {"allow_cidrs": ["10.0.0.0/8"]}}}
+type Config struct {
+	Default   *Rule            `json:"default"`
+	Resources map[string]*Rule `json:"resources"`
+	Routes    map[string]*Rule `json:"routes"`
+}
+
+// Load reads a rule file. An empty path yields an empty config.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("access: parse %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// For returns the rule of a route, or nil.
+func (c *Config) For(method, pattern string) *Rule {
+	if c == nil {
+		return nil
+	}
+	if rule := c.Routes[method+" "+pattern]; rule != nil {
+		return rule
+	}
+	resource, _, _ := strings.Cut(strings.TrimPrefix(pattern, "/"), "/")
+	if rule := c.Resources[resource]; rule != nil {
+		return rule
+	}
+	return c.Default
+}
+
+// UsesCountries reports whether some rule needs the country of clients.
+func (c *Config) UsesCountries() bool {
+	if c.Default != nil && c.Default.usesCountries() {
+		return true
+	}
+	for _, rules := range []map[string]*Rule{c.Resources, c.Routes} {
+		for _, rule := range rules {
+			if rule.usesCountries() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Locator finds the country of an address, "" when it is unknown.
+// *geoip.DB is one.
+type Locator interface {
+	Country(ip netip.Addr) (string, error)
+}
+
+// Access enforces the rules of a Config. Clients are known by the address
+// of their connection, or, when it is one of the trusted proxies, by the
+// last address in X-Forwarded-For that is not.
+type Access struct {
+	config  *Config
+	locator Locator
+	trusted []netip.Prefix
+}
+
+// New returns an Access for config, locating clients with locator, which
+// may be nil when no rule names countries.
+func New(config *Config, locator Locator, trustedProxies []netip.Prefix) *Access {
+	return &Access{config: config, locator: locator, trusted: trustedProxies}
+}
+
+// For returns the middleware enforcing the rule of a route, or nil, also on
+// a nil Access.
+func (a *Access) For(method, pattern string) func(http.Handler) http.Handler {
+	if a == nil {
+		return nil
+	}
+	rule := a.config.For(method, pattern)
+	if rule == nil {
+		return nil
+	}
+	return a.Middleware(rule)
+}
+
+// Middleware answers 403 to requests from networks rule denies, and 451 to
+// those from countries it does.
+func (a *Access) Middleware(rule *Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if resp := a.check(rule, a.ClientIP(r)); resp != nil {
+				e.Write(w, resp)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// check returns the response denying ip, or nil when rule allows it.
+func (a *Access) check(rule *Rule, ip netip.Addr) *e.Problem {
+	if contains(rule.DenyCIDRs, ip) {
+		return RespNetworkDenied
+	}
+	if contains(rule.AllowCIDRs, ip) {
+		return nil
+	}
+
+	if rule.usesCountries() {
+		country := ""
+		if ip.IsValid() && a.locator != nil {
+			var err error
+			if country, err = a.locator.Country(ip); err != nil {
+				// The rule cannot be checked; failing closed keeps the
+				// restriction of deployments relying on it.
+				log.Printf("access: locate %s: %s", ip, err)
+				return RespRegionDenied
+			}
+		}
+		if country != "" && slices.Contains(rule.DenyCountries, country) {
+			return RespRegionDenied
+		}
+		if country != "" && slices.Contains(rule.AllowCountries, country) {
+			return nil
+		}
+		if len(rule.AllowCountries) > 0 {
+			return RespRegionDenied
+		}
+	}
+
+	if len(rule.AllowCIDRs) > 0 && len(rule.AllowCountries) == 0 {
+		return RespNetworkDenied
+	}
+	return nil
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client of r, which is invalid when
+// it cannot be told.
+func (a *Access) ClientIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	ip = ip.Unmap()
+
+	// Proxies append the address they got the request from, so the last
+	// address not added by one of ours is the client's; those before it may
+	// be made up.
+	if !contains(a.trusted, ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}
+		}
+		ip = hop.Unmap()
+		if !contains(a.trusted, ip) {
+			return ip
+		}
+	}
+	return ip
+}
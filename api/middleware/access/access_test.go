@@ -0,0 +1,100 @@
+package access_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"hello/api/middleware/access"
+	testUtil "hello/util/test"
+)
+
+type locator map[string]string
+
+func (l locator) Country(ip netip.Addr) (string, error) {
+	if ip.String() == "203.0.113.66" {
+		return "", errors.New("corrupt record")
+	}
+	return l[ip.String()], nil
+}
+
+func TestAccess(t *testing.T) {
+	t.Parallel()
+
+	var config access.Config
+	testUtil.NoError(t, json.Unmarshal([]byte(`{
+		"default": {"deny_countries": ["kp"]},
+		"resources": {
+			"orders": {"allow_countries": ["DE", "AT"], "allow_cidrs": ["10.0.0.0/8"]},
+			"admin": {"allow_cidrs": ["192.0.2.0/24", "2001:db8::1"]}
+		},
+		"routes": {"GET /orders/{id}": {"deny_cidrs": ["198.51.100.0/24"]}}
+	}`), &config))
+
+	a := access.New(&config, locator{
+		"198.51.100.7": "DE",
+		"203.0.113.1":  "DE",
+		"203.0.113.2":  "FR",
+		"203.0.113.3":  "KP",
+	}, []netip.Prefix{netip.MustParsePrefix("172.16.0.0/12")})
+
+	tests := []struct {
+		name    string
+		method  string
+		pattern string
+		remote  string
+		xff     string
+		status  int
+	}{
+		{"default allows", http.MethodGet, "/books", "203.0.113.2", "", http.StatusOK},
+		{"default denies country", http.MethodGet, "/books", "203.0.113.3", "", http.StatusUnavailableForLegalReasons},
+		{"resource allows country", http.MethodPost, "/orders", "203.0.113.1", "", http.StatusOK},
+		{"resource denies other country", http.MethodPost, "/orders", "203.0.113.2", "", http.StatusUnavailableForLegalReasons},
+		{"resource denies unknown country", http.MethodPost, "/orders", "192.0.2.1", "", http.StatusUnavailableForLegalReasons},
+		{"resource allows cidr", http.MethodPost, "/orders", "10.1.2.3", "", http.StatusOK},
+		{"route replaces resource", http.MethodGet, "/orders/{id}", "203.0.113.2", "", http.StatusOK},
+		{"route denies cidr", http.MethodGet, "/orders/{id}", "198.51.100.7", "", http.StatusForbidden},
+		{"cidr only allows", http.MethodPost, "/admin/rebuild", "192.0.2.9", "", http.StatusOK},
+		{"cidr only allows address", http.MethodPost, "/admin/rebuild", "[2001:db8::1]", "", http.StatusOK},
+		{"cidr only denies", http.MethodPost, "/admin/rebuild", "203.0.113.1", "", http.StatusForbidden},
+		{"lookup failure denies", http.MethodGet, "/books", "203.0.113.66", "", http.StatusUnavailableForLegalReasons},
+		{"trusted proxy forwards", http.MethodPost, "/admin/rebuild", "172.16.0.1", "203.0.113.1, 192.0.2.9, 172.16.0.2", http.StatusOK},
+		{"spoofed hop ignored", http.MethodPost, "/admin/rebuild", "172.16.0.1", "192.0.2.9, 203.0.113.1", http.StatusForbidden},
+		{"untrusted peer forwards nothing", http.MethodPost, "/admin/rebuild", "203.0.113.1", "192.0.2.9", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			if restrict := a.For(tt.method, tt.pattern); restrict != nil {
+				h = restrict(h)
+			}
+
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.RemoteAddr = tt.remote + ":1234"
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			testUtil.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestConfig_UsesCountries(t *testing.T) {
+	t.Parallel()
+
+	var config access.Config
+	testUtil.NoError(t, json.Unmarshal([]byte(`{"routes": {"GET /books": {"allow_cidrs": ["10.0.0.0/8"]}}}`), &config))
+	testUtil.Equal(t, false, config.UsesCountries())
+
+	testUtil.NoError(t, json.Unmarshal([]byte(`{"resources": {"orders": {"deny_countries": ["KP"]}}}`), &config))
+	testUtil.Equal(t, true, config.UsesCountries())
+
+	err := json.Unmarshal([]byte(`{"default": {"deny_cidrs": ["10.0.0.0/33"]}}`), &config)
+	testUtil.Equal(t, true, err != nil)
+}
@@ -0,0 +1,144 @@
+// Package apiversion lets representations change without breaking existing
+// clients. A client asks for a version with ?v=2 or with a version parameter
+// on its Accept header, e.g. "application/json; version=2"; clients that ask
+// for none get Default. Resources build their DTOs in the Latest shape and
+// render them for older versions with Serializers.
+package apiversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	e "hello/api/resource/common/err"
+)
+
+const (
+	// Default is the version of clients asking for none. It stays at the
+	// first version, whose shape existing clients were written against.
+	Default = 1
+	// Latest is the newest version.
+	Latest = 2
+
+	// Query asks for a version, taking precedence over the Accept header.
+	Query = "v"
+	// Header reports the version a response is rendered in.
+	Header = "API-Version"
+)
+
+var RespUnsupported = e.New(http.StatusBadRequest, "unsupported_api_version", fmt.Sprintf("API version must be between %d and %d", Default, Latest))
+
+type ctxKey struct{}
+
+// Middleware stores the requested version in the request context and
+// reports it in the API-Version header. Unsupported versions answer 400.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, ok := requested(r)
+		if !ok {
+			e.BadRequest(w, RespUnsupported)
+			return
+		}
+
+		w.Header().Set(Header, strconv.Itoa(v))
+		w.Header().Add("Vary", "Accept")
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, v)))
+	})
+}
+
+// requested returns the version r asks for, or Default. It reports false on
+// a version that is not supported.
+func requested(r *http.Request) (int, bool) {
+	raw := r.URL.Query().Get(Query)
+	if raw == "" {
+		for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+			if _, params, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && params["version"] != "" {
+				raw = params["version"]
+				break
+			}
+		}
+	}
+	if raw == "" {
+		return Default, true
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < Default || v > Latest {
+		return 0, false
+	}
+	return v, true
+}
+
+// From returns the version of the request context, or Default when
+// Middleware has not run.
+func From(ctx context.Context) int {
+	if v, ok := ctx.Value(ctxKey{}).(int); ok {
+		return v
+	}
+	return Default
+}
+
+// Serializer renders a representation of one version, decoded to generic
+// JSON, in the shape of the version before it.
+type Serializer func(v any) any
+
+// Serializers holds a resource's serializers by the version that introduced
+// the change they undo: Serializers{2: ...} renders version 2 as version 1.
+type Serializers map[int]Serializer
+
+// Render returns v, a DTO in the Latest shape, as version expects it. Without
+// changes between the two, v is returned as is.
+func (s Serializers) Render(v any, version int) (any, error) {
+	if version >= Latest {
+		return v, nil
+	}
+
+	var generic any
+	for from := Latest; from > version; from-- {
+		serialize, ok := s[from]
+		if !ok {
+			continue
+		}
+		if generic == nil {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(b, &generic); err != nil {
+				return nil, err
+			}
+		}
+		generic = serialize(generic)
+	}
+	if generic == nil {
+		return v, nil
+	}
+	return generic, nil
+}
+
+// Rename returns a serializer renaming fields, given as new name to old
+// name, of an object or of each object in an array.
+func Rename(fields map[string]string) Serializer {
+	var rename func(v any) any
+	rename = func(v any) any {
+		switch v := v.(type) {
+		case []any:
+			for i := range v {
+				v[i] = rename(v[i])
+			}
+		case map[string]any:
+			for newName, oldName := range fields {
+				if value, ok := v[newName]; ok {
+					delete(v, newName)
+					v[oldName] = value
+				}
+			}
+		}
+		return v
+	}
+	return rename
+}
@@ -0,0 +1,80 @@
+package apiversion_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"hello/api/middleware/apiversion"
+	e "hello/api/resource/common/err"
+	testUtil "hello/util/test"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	h := apiversion.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(apiversion.From(r.Context()))))
+	}))
+	serve := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// Clients asking for no version get the default one.
+	w := serve("/books", "application/json")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "1", w.Body.String())
+	testUtil.Equal(t, "1", w.Header().Get(apiversion.Header))
+	testUtil.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	w = serve("/books", "text/csv, application/json; version=2")
+	testUtil.Equal(t, "2", w.Body.String())
+	testUtil.Equal(t, "2", w.Header().Get(apiversion.Header))
+
+	// The query parameter takes precedence over the Accept header.
+	w = serve("/books?v=1", "application/json; version=2")
+	testUtil.Equal(t, "1", w.Body.String())
+
+	for _, target := range []string{"/books?v=0", "/books?v=3", "/books?v=two"} {
+		w = serve(target, "")
+		testUtil.Equal(t, http.StatusBadRequest, w.Code)
+		testUtil.Equal(t, e.ContentType, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestSerializersRender(t *testing.T) {
+	t.Parallel()
+
+	type dto struct {
+		Title  string `json:"title"`
+		Author string `json:"author"`
+	}
+	versions := apiversion.Serializers{
+		2: apiversion.Rename(map[string]string{"author": "Author"}),
+	}
+	render := func(v any, version int) string {
+		rendered, err := versions.Render(v, version)
+		testUtil.NoError(t, err)
+		b, err := json.Marshal(rendered)
+		testUtil.NoError(t, err)
+		return string(b)
+	}
+
+	dune := &dto{Title: "Dune", Author: "Frank Herbert"}
+	testUtil.Equal(t, `{"title":"Dune","author":"Frank Herbert"}`, render(dune, 2))
+	testUtil.Equal(t, `{"Author":"Frank Herbert","title":"Dune"}`, render(dune, 1))
+	testUtil.Equal(t, `[{"Author":"Frank Herbert","title":"Dune"}]`, render([]*dto{dune}, 1))
+
+	// Without changes since the version asked for, v is returned as is.
+	rendered, err := apiversion.Serializers{}.Render(dune, 1)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, any(dune), rendered)
+}
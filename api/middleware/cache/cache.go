@@ -0,0 +1,14 @@
+package cache
+
+import "net/http"
+
+// Control sets the Cache-Control header to policy. Handlers may still
+// override it, e.g. to mark an error response uncacheable.
+func Control(policy string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", policy)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
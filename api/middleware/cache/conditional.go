@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Conditional answers conditional GET requests with 304 Not Modified when
+// the client's copy is still current, so that browsers and CDNs can
+// revalidate what they cache without downloading it again. Successful
+// responses get a weak ETag hashing their body unless the handler set one;
+// If-None-Match is checked against it, and otherwise If-Modified-Since
+// against the Last-Modified header of handlers setting one, see
+// LastModified.
+//
+// Handlers behind it always see unconditional requests, so that their full
+// response can be served from the server cache of a policy.
+func Conditional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		unconditional := r
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			unconditional = r.Clone(r.Context())
+			unconditional.Header.Del("If-None-Match")
+			unconditional.Header.Del("If-Modified-Since")
+		}
+		bw := &bufferWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, unconditional)
+		if bw.passThrough {
+			return
+		}
+
+		h := w.Header()
+		if h.Get("ETag") == "" {
+			h.Set("ETag", weakETag(bw.body.Bytes()))
+		}
+		if notModified(r, h) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(bw.body.Bytes())
+	})
+}
+
+// LastModified sets the Last-Modified header to t, at the second precision
+// of HTTP dates. A zero t sets nothing.
+func LastModified(w http.ResponseWriter, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified reports whether the client holds the response of header. As
+// RFC 9110 has it, If-Modified-Since is ignored when If-None-Match is sent,
+// and tags are compared weakly.
+func notModified(r *http.Request, header http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		tag := strings.TrimPrefix(header.Get("ETag"), "W/")
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// bufferWriter keeps a successful response to be checked against the
+// request's conditions, and writes any other through.
+type bufferWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	passThrough bool
+	body        bytes.Buffer
+}
+
+func (bw *bufferWriter) WriteHeader(status int) {
+	if bw.wroteHeader {
+		return
+	}
+	bw.wroteHeader = true
+	if status != http.StatusOK {
+		bw.passThrough = true
+		bw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (bw *bufferWriter) Write(b []byte) (int, error) {
+	if !bw.wroteHeader {
+		bw.WriteHeader(http.StatusOK)
+	}
+	if bw.passThrough {
+		return bw.ResponseWriter.Write(b)
+	}
+	return bw.body.Write(b)
+}
+
+func (bw *bufferWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
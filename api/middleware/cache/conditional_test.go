@@ -0,0 +1,66 @@
+package cache_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"hello/api/middleware/cache"
+	e "hello/api/resource/common/err"
+	"hello/event"
+	testUtil "hello/util/test"
+)
+
+func TestConditional(t *testing.T) {
+	t.Parallel()
+
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	p := &cache.Policy{TTL: time.Hour, Conditional: true}
+	c := cache.New(&cache.Config{}, event.NewBus())
+	h := cache.Conditional(c.Middleware(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// Handlers see unconditional requests.
+		testUtil.Equal(t, "", r.Header.Get("If-None-Match"))
+		cache.LastModified(w, modified)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("catalog"))
+	})))
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/catalog/books", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("", "")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "catalog", w.Body.String())
+	testUtil.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", w.Header().Get("Last-Modified"))
+	tag := w.Header().Get("ETag")
+
+	// Revalidations are answered from the server cache.
+	w = serve("If-None-Match", `"other", `+tag)
+	testUtil.Equal(t, http.StatusNotModified, w.Code)
+	testUtil.Equal(t, 0, w.Body.Len())
+	testUtil.Equal(t, tag, w.Header().Get("ETag"))
+	testUtil.Equal(t, "", w.Header().Get("Content-Type"))
+	testUtil.Equal(t, 1, calls)
+
+	testUtil.Equal(t, http.StatusOK, serve("If-None-Match", `W/"other"`).Code)
+	testUtil.Equal(t, http.StatusNotModified, serve("If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT").Code)
+	testUtil.Equal(t, http.StatusOK, serve("If-Modified-Since", "Wed, 01 May 2024 11:59:59 GMT").Code)
+	testUtil.Equal(t, http.StatusOK, serve("If-Modified-Since", "yesterday").Code)
+
+	// Errors are passed through.
+	h = cache.Conditional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.NotFound(w, e.RespNotFound)
+	}))
+	w = serve("If-None-Match", "*")
+	testUtil.Equal(t, http.StatusNotFound, w.Code)
+	testUtil.Equal(t, "", w.Header().Get("ETag"))
+}
@@ -0,0 +1,330 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"hello/api/middleware/scope"
+	"hello/event"
+)
+
+const (
+	// MaxEntries bounds the responses kept per policy. When full, expired
+	// entries are dropped, and new responses are not kept until some
+	// expire.
+	MaxEntries = 10000
+	// BypassHeader asks for a response from the handler rather than the
+	// server cache, as Cache-Control: no-cache does.
+	BypassHeader = "X-Cache-Bypass"
+)
+
+// identityHeaders identify the caller. They are always part of the key of
+// a cached response, so that one caller is never served another's.
+var identityHeaders = []string{"Authorization", "X-API-Key", "Cookie", "X-Tenant-ID", "X-Scopes"}
+
+// Policy is how the responses of a route are cached:
+//
+//	{"control": "public, max-age=60", "ttl": "30s", "vary": ["Accept-Language"], "invalidate_on": ["book.updated"], "conditional": true}
+type Policy struct {
+	// Control is the Cache-Control header, replacing the route's own.
+	Control string
+	// TTL keeps successful responses in the server cache for that long.
+	// Zero keeps none.
+	TTL time.Duration
+	// Vary lists the request headers the response depends on. They are part
+	// of the key of cached responses and sent in the Vary header.
+	Vary []string
+	// InvalidateOn names the events that drop the cached responses.
+	InvalidateOn []string
+	// Conditional answers conditional requests with 304 when the response
+	// is unchanged, see Conditional.
+	Conditional bool
+}
+
+type policyJSON struct {
+	Control      string   `json:"control"`
+	TTL          string   `json:"ttl"`
+	Vary         []string `json:"vary"`
+	InvalidateOn []string `json:"invalidate_on"`
+	Conditional  bool     `json:"conditional"`
+}
+
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	var v policyJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var ttl time.Duration
+	if v.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(v.TTL); err != nil || ttl < 0 {
+			return fmt.Errorf("invalid ttl %q", v.TTL)
+		}
+	}
+	vary := make([]string, len(v.Vary))
+	for i, h := range v.Vary {
+		vary[i] = http.CanonicalHeaderKey(h)
+	}
+	*p = Policy{Control: v.Control, TTL: ttl, Vary: vary, InvalidateOn: v.InvalidateOn, Conditional: v.Conditional}
+	return nil
+}
+
+// Config maps routes to cache policies. Routes are named by method and
+// pattern as declared, e.g. "GET /books/{id}"; resources by the first
+// segment of their patterns, e.g. "books", and apply to every GET route
+// under it without a policy of its own.
+//
+//	{"resources": {"genres": {"control": "public, max-age=600"}},
+//	 "routes": {"GET /books/{id}": {"ttl": "1m", "invalidate_on": ["book.updated", "book.deleted"]}}}
+//
+// BypassScopes are the scopes of callers allowed to skip the server cache,
+// see Cache.Middleware. They are set from the environment rather than the
+// file.
+type Config struct {
+	Resources map[string]*Policy `json:"resources"`
+	Routes    map[string]*Policy `json:"routes"`
+
+	BypassScopes []string `json:"-"`
+}
+
+// Load reads a policy file. An empty path yields an empty config.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("cache: parse %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// For returns the policy of a route, or nil. Only GET and HEAD responses
+// are cached.
+func (c *Config) For(method, pattern string) *Policy {
+	if c == nil || (method != http.MethodGet && method != http.MethodHead) {
+		return nil
+	}
+	if p := c.Routes[method+" "+pattern]; p != nil {
+		return p
+	}
+	resource, _, _ := strings.Cut(strings.TrimPrefix(pattern, "/"), "/")
+	return c.Resources[resource]
+}
+
+// Cache serves the policies of a Config, keeping responses in memory. Each
+// instance has its own cache, so after an invalidating event other
+// instances serve their copies until the TTL runs out.
+type Cache struct {
+	config *Config
+
+	mu      sync.Mutex
+	entries map[*Policy]map[string]*entry
+}
+
+type entry struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// New returns a cache for config that drops the responses of a policy when
+// the bus publishes one of its InvalidateOn events.
+func New(config *Config, bus event.Bus) *Cache {
+	c := &Cache{config: config, entries: make(map[*Policy]map[string]*entry)}
+	for _, policies := range []map[string]*Policy{config.Resources, config.Routes} {
+		for _, p := range policies {
+			for _, name := range p.InvalidateOn {
+				bus.Subscribe(name, func(context.Context, event.Event) error {
+					c.invalidate(p)
+					return nil
+				})
+			}
+		}
+	}
+	return c
+}
+
+// For returns the policy of a route, or nil, also on a nil cache.
+func (c *Cache) For(method, pattern string) *Policy {
+	if c == nil {
+		return nil
+	}
+	return c.config.For(method, pattern)
+}
+
+// Middleware applies p: it sets the Cache-Control and Vary headers and,
+// with a TTL, serves 200 responses from memory while they are fresh. The
+// X-Cache header tells whether a response was a HIT or a MISS.
+//
+// Conditional requests bypass the server cache, so that their handlers can
+// answer 304, unless Conditional answers them in front of it. Callers
+// holding one of the BypassScopes can bypass it too, with the
+// X-Cache-Bypass header or Cache-Control: no-cache, and get a fresh
+// response marked BYPASS that replaces the cached one. Others asking to
+// are served from the cache as usual, so that they cannot load the
+// database by asking.
+func (c *Cache) Middleware(p *Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p.Control != "" {
+				w.Header().Set("Cache-Control", p.Control)
+			}
+			for _, h := range p.Vary {
+				w.Header().Add("Vary", h)
+			}
+			if p.TTL <= 0 || r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := key(r, p.Vary)
+			outcome := "MISS"
+			if c.bypass(r) {
+				outcome = "BYPASS"
+			} else if e := c.get(p, key); e != nil {
+				for k, v := range e.header {
+					w.Header()[k] = v
+				}
+				w.Header().Set("X-Cache", "HIT")
+				w.WriteHeader(e.status)
+				w.Write(e.body)
+				return
+			}
+
+			w.Header().Set("X-Cache", outcome)
+			tw := &teeWriter{ResponseWriter: w}
+			next.ServeHTTP(tw, r)
+			if tw.status == 0 || tw.status == http.StatusOK {
+				header := w.Header().Clone()
+				header.Del("X-Cache")
+				c.put(p, key, &entry{status: http.StatusOK, header: header, body: tw.body.Bytes(), expires: time.Now().Add(p.TTL)})
+			}
+		})
+	}
+}
+
+// bypass reports whether r asks to skip the server cache and may.
+func (c *Cache) bypass(r *http.Request) bool {
+	if r.Header.Get(BypassHeader) == "" && !noCache(r.Header.Get("Cache-Control")) {
+		return false
+	}
+	return slices.ContainsFunc(scope.From(r.Context()), func(s string) bool {
+		return slices.Contains(c.config.BypassScopes, s)
+	})
+}
+
+func noCache(control string) bool {
+	for _, d := range strings.Split(control, ",") {
+		if strings.EqualFold(strings.TrimSpace(d), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cache) get(p *Policy, key string) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entries[p][key]
+	if e == nil || !time.Now().Before(e.expires) {
+		return nil
+	}
+	return e
+}
+
+func (c *Cache) put(p *Policy, key string, e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := c.entries[p]
+	if entries == nil {
+		entries = make(map[string]*entry)
+		c.entries[p] = entries
+	}
+	if len(entries) >= MaxEntries {
+		now := time.Now()
+		for k, old := range entries {
+			if !now.Before(old.expires) {
+				delete(entries, k)
+			}
+		}
+		if len(entries) >= MaxEntries {
+			return
+		}
+	}
+	entries[key] = e
+}
+
+func (c *Cache) invalidate(p *Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, p)
+}
+
+func key(r *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.RequestURI())
+
+	for _, h := range vary {
+		b.WriteByte('\n')
+		b.WriteString(r.Header.Get(h))
+	}
+
+	identity := sha256.New()
+	for _, h := range identityHeaders {
+		identity.Write([]byte(r.Header.Get(h)))
+		identity.Write([]byte{0})
+	}
+	b.WriteByte('\n')
+	b.WriteString(hex.EncodeToString(identity.Sum(nil)))
+
+	return b.String()
+}
+
+// teeWriter passes the response through while keeping a copy of it.
+type teeWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (t *teeWriter) WriteHeader(status int) {
+	if t.status == 0 {
+		t.status = status
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *teeWriter) Write(b []byte) (int, error) {
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	t.body.Write(b)
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *teeWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
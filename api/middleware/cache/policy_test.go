@@ -0,0 +1,139 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hello/api/middleware/cache"
+	"hello/api/middleware/scope"
+	"hello/event"
+	testUtil "hello/util/test"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cache.json")
+	testUtil.NoError(t, os.WriteFile(path, []byte(`{
+		"resources": {"books": {"control": "private, max-age=60"}},
+		"routes": {"GET /books/{id}": {"ttl": "1m", "vary": ["accept-language"]}}
+	}`), 0o600))
+
+	c, err := cache.Load(path)
+	testUtil.NoError(t, err)
+
+	p := c.For(http.MethodGet, "/books/{id}")
+	testUtil.Equal(t, "1m0s", p.TTL.String())
+	testUtil.Equal(t, "Accept-Language", p.Vary[0])
+	testUtil.Equal(t, "private, max-age=60", c.For(http.MethodGet, "/books/{id}/cover").Control)
+	testUtil.Equal(t, true, c.For(http.MethodPost, "/books") == nil)
+	testUtil.Equal(t, true, c.For(http.MethodGet, "/genres") == nil)
+
+	testUtil.NoError(t, os.WriteFile(path, []byte(`{"routes": {"GET /": {"ttl": "soon"}}}`), 0o600))
+	_, err = cache.Load(path)
+	testUtil.Equal(t, true, err != nil)
+
+	c, err = cache.Load("")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, true, c.For(http.MethodGet, "/books") == nil)
+}
+
+func TestCache_Middleware(t *testing.T) {
+	t.Parallel()
+
+	p := &cache.Policy{Control: "private, max-age=30", TTL: time.Hour, Vary: []string{"Accept-Language"}, InvalidateOn: []string{"book.updated"}}
+	bus := event.NewBus()
+	c := cache.New(&cache.Config{Routes: map[string]*cache.Policy{"GET /books": p}}, bus)
+
+	calls := 0
+	h := c.Middleware(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	serve := func(lang, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		req.Header.Set("Accept-Language", lang)
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("de", "a")
+	testUtil.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	testUtil.Equal(t, "private, max-age=30", w.Header().Get("Cache-Control"))
+	testUtil.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+
+	w = serve("de", "a")
+	testUtil.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	testUtil.Equal(t, "de", w.Body.String())
+	testUtil.Equal(t, 1, calls)
+
+	// Varying headers and callers have responses of their own.
+	testUtil.Equal(t, "fr", serve("fr", "a").Body.String())
+	serve("de", "b")
+	testUtil.Equal(t, 3, calls)
+
+	// An invalidating event drops every response of the policy.
+	testUtil.NoError(t, bus.Publish(context.Background(), event.New("book.updated", "1", nil)))
+	testUtil.Equal(t, "MISS", serve("de", "a").Header().Get("X-Cache"))
+	testUtil.Equal(t, 4, calls)
+
+	// Conditional requests bypass the cache.
+	req := httptest.NewRequest(http.MethodGet, "/books", nil)
+	req.Header.Set("If-None-Match", `"x"`)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	testUtil.Equal(t, 5, calls)
+}
+
+func TestCache_Bypass(t *testing.T) {
+	t.Parallel()
+
+	p := &cache.Policy{TTL: time.Hour}
+	c := cache.New(&cache.Config{Routes: map[string]*cache.Policy{"GET /books": p}, BypassScopes: []string{"admin"}}, event.NewBus())
+
+	calls := 0
+	h := scope.Middleware(c.Middleware(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, calls)
+	})))
+	serve := func(scopes, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		req.Header.Set(scope.Header, scopes)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	testUtil.Equal(t, "MISS", serve("admin", "", "").Header().Get("X-Cache"))
+	testUtil.Equal(t, "HIT", serve("admin", "", "").Header().Get("X-Cache"))
+
+	w := serve("admin", cache.BypassHeader, "1")
+	testUtil.Equal(t, "BYPASS", w.Header().Get("X-Cache"))
+	testUtil.Equal(t, "2", w.Body.String())
+	w = serve("admin", "Cache-Control", "max-age=0, No-Cache")
+	testUtil.Equal(t, "BYPASS", w.Header().Get("X-Cache"))
+	testUtil.Equal(t, "3", w.Body.String())
+
+	// The fresh response replaces the cached one.
+	w = serve("admin", "", "")
+	testUtil.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	testUtil.Equal(t, "3", w.Body.String())
+
+	// Callers without a bypass scope are served from the cache.
+	testUtil.Equal(t, "MISS", serve("books:read", "", "").Header().Get("X-Cache"))
+	w = serve("books:read", cache.BypassHeader, "1")
+	testUtil.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	testUtil.Equal(t, "4", w.Body.String())
+	testUtil.Equal(t, "HIT", serve("books:read", "Cache-Control", "no-cache").Header().Get("X-Cache"))
+	testUtil.Equal(t, 4, calls)
+}
@@ -0,0 +1,114 @@
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"golang.org/x/sync/singleflight"
+
+	"hello/api/middleware/user"
+)
+
+// varyHeaders are request headers that change the response, and so are part
+// of the coalescing key alongside the URL.
+var varyHeaders = []string{"Accept", "Accept-Language", "Accept-Encoding", "If-None-Match"}
+
+// scopeHeaders identify the caller. They are hashed into the key, along with
+// the identity resolved from them, so that concurrent requests are only
+// shared within the same auth scope.
+var scopeHeaders = []string{"Authorization", "X-API-Key", "Cookie", "X-Tenant-ID", "X-Scopes", user.Header}
+
+// New coalesces concurrent identical GET and HEAD requests into a single
+// execution of next and replays the captured response to every waiter. This
+// protects the database from stampedes when many clients miss the same cache
+// entry at once. It needs the caller's identity in the request context, so it
+// is mounted behind the middleware authenticating the caller.
+func New() func(http.Handler) http.Handler {
+	var group singleflight.Group
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			v, _, _ := group.Do(key(r), func() (any, error) {
+				// The leader's client may go away; the shared execution must
+				// still complete for everyone else waiting on it.
+				rec := newRecorder()
+				next.ServeHTTP(rec, r.WithContext(context.WithoutCancel(r.Context())))
+				return rec, nil
+			})
+
+			v.(*recorder).replay(w)
+		})
+	}
+}
+
+func key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.RequestURI())
+
+	for _, h := range varyHeaders {
+		b.WriteByte('\n')
+		b.WriteString(r.Header.Get(h))
+	}
+
+	scope := sha256.New()
+	for _, h := range scopeHeaders {
+		scope.Write([]byte(r.Header.Get(h)))
+		scope.Write([]byte{0})
+	}
+	ctx := r.Context()
+	if id, ok := user.From(ctx); ok {
+		scope.Write([]byte("user:" + id.String()))
+	}
+	if id, ok := user.Service(ctx); ok {
+		scope.Write([]byte("service:" + id.String()))
+	}
+	if id, ok := user.APIKey(ctx); ok {
+		scope.Write([]byte("api_key:" + id.String()))
+	}
+	b.WriteByte('\n')
+	b.WriteString(hex.EncodeToString(scope.Sum(nil)))
+
+	return b.String()
+}
+
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	rec.status = status
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) replay(w http.ResponseWriter) {
+	h := w.Header()
+	for k, v := range rec.header {
+		h[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
@@ -0,0 +1,93 @@
+package coalesce_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/coalesce"
+	"hello/api/middleware/user"
+	testUtil "hello/util/test"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := coalesce.New()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+
+	const n = 5
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, n)
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/books?author=x", nil))
+		}(recs[i])
+	}
+
+	// Let the other requests join the in-flight call before it completes.
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	testUtil.Equal(t, true, calls.Load() < n)
+	for _, w := range recs {
+		testUtil.Equal(t, "[]", w.Body.String())
+		testUtil.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestNew_Users(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := user.Middleware(coalesce.New()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		id, _ := user.From(r.Context())
+		w.Write([]byte(id.String()))
+	})))
+
+	ids := []string{uuid.NewString(), uuid.NewString()}
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, len(ids))
+	for i, id := range ids {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder, id string) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+			r.Header.Set(user.Header, id)
+			h.ServeHTTP(w, r)
+		}(recs[i], id)
+	}
+
+	// Requests of different users are never shared.
+	for deadline := time.Now().Add(time.Second); calls.Load() < int32(len(ids)) && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	for i, w := range recs {
+		testUtil.Equal(t, ids[i], w.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+package compress_test
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"hello/api/middleware/compress"
+	testUtil "hello/util/test"
+)
+
+func TestDownload(t *testing.T) {
+	t.Parallel()
+
+	const body = "id,title\n1,Dune\n"
+	h := compress.Download(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "books.csv"}))
+		w.Write([]byte(body[:9]))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(body[9:]))
+	}))
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+	filename := func(w *httptest.ResponseRecorder) string {
+		_, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+		testUtil.NoError(t, err)
+		return params["filename"]
+	}
+
+	w := serve("/export?compress=gzip")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+	testUtil.Equal(t, "books.csv.gz", filename(w))
+	gr, err := gzip.NewReader(w.Body)
+	testUtil.NoError(t, err)
+	got, err := io.ReadAll(gr)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, body, string(got))
+
+	w = serve("/export?compress=zstd")
+	testUtil.Equal(t, "application/zstd", w.Header().Get("Content-Type"))
+	testUtil.Equal(t, "books.csv.zst", filename(w))
+	zr, err := zstd.NewReader(w.Body)
+	testUtil.NoError(t, err)
+	got, err = io.ReadAll(zr)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, body, string(got))
+
+	w = serve("/export")
+	testUtil.Equal(t, "books.csv", filename(w))
+	testUtil.Equal(t, body, w.Body.String())
+
+	w = serve("/export?compress=gzip&fail=1")
+	testUtil.Equal(t, http.StatusNotFound, w.Code)
+	testUtil.Equal(t, "not found", w.Body.String())
+
+	testUtil.Equal(t, http.StatusBadRequest, serve("/export?compress=brotli").Code)
+}
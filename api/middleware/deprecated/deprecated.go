@@ -0,0 +1,78 @@
+package deprecated
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"hello/api/middleware/warning"
+)
+
+// Deprecation describes a route or parameter scheduled for removal.
+type Deprecation struct {
+	// ID names the deprecated item in usage reports, e.g. "GET /v1/books?decade".
+	ID string
+	// Sunset is when the item will be removed; zero when not yet scheduled.
+	Sunset time.Time
+	// Successor optionally links to the replacement.
+	Successor string
+}
+
+// Recorder receives one call per request that used a deprecated item.
+type Recorder interface {
+	Record(id, client string)
+}
+
+// ClientID identifies the caller in usage reports without storing the raw
+// API key.
+func ClientID(r *http.Request) string {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return "anonymous"
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// Route marks every request to the wrapped route as using d.
+func Route(rec Recorder, d Deprecation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			use(w, r, rec, d, "this route is deprecated")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Param marks requests to the wrapped route that send the query parameter
+// param as using d.
+func Param(rec Recorder, param string, d Deprecation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has(param) {
+				use(w, r, rec, d, fmt.Sprintf("query parameter %q is deprecated", param))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// use sets the Deprecation, Sunset and Link headers from
+// draft-ietf-httpapi-deprecation-header and RFC 8594.
+func use(w http.ResponseWriter, r *http.Request, rec Recorder, d Deprecation, message string) {
+	h := w.Header()
+	h.Set("Deprecation", "true")
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		message += "; removal on " + d.Sunset.UTC().Format("2006-01-02")
+	}
+	if d.Successor != "" {
+		h.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", d.Successor))
+	}
+
+	warning.Add(r, warning.CodeDeprecated, message)
+	rec.Record(d.ID, ClientID(r))
+}
@@ -0,0 +1,35 @@
+package deprecated_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"hello/api/middleware/deprecated"
+	testUtil "hello/util/test"
+)
+
+type recorder map[string]int
+
+func (rec recorder) Record(id, client string) {
+	rec[id+" "+client]++
+}
+
+func TestParam(t *testing.T) {
+	t.Parallel()
+
+	rec := recorder{}
+	d := deprecated.Deprecation{ID: "GET /v1/books?decade", Sunset: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := deprecated.Param(rec, "decade", d)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/books", nil))
+	testUtil.Equal(t, "", w.Header().Get("Deprecation"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/books?decade=1990", nil))
+	testUtil.Equal(t, "true", w.Header().Get("Deprecation"))
+	testUtil.Equal(t, "Fri, 01 Jan 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	testUtil.Equal(t, 1, rec["GET /v1/books?decade anonymous"])
+}
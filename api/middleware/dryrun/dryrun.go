@@ -0,0 +1,67 @@
+// Package dryrun lets callers ask a write endpoint what it would do without
+// doing it, e.g. to pre-validate a form. A dry run goes through validation
+// and the business rules of the endpoint and answers as the real request
+// would, but writes nothing, records nothing and publishes no events.
+package dryrun
+
+import (
+	"net/http"
+	"strconv"
+
+	e "hello/api/resource/common/err"
+)
+
+// Header asks for a dry run as an alternative to the dry_run query
+// parameter. Guard sets it on responses to dry runs too, so that a preview
+// is never mistaken for the result of a write.
+const Header = "Dry-Run"
+
+// Query is the query parameter asking for a dry run.
+const Query = "dry_run"
+
+var (
+	RespInvalid     = e.New(http.StatusBadRequest, "invalid_dry_run", "dry_run must be true or false")
+	RespUnsupported = e.New(http.StatusBadRequest, "dry_run_unsupported", "this endpoint does not support dry runs")
+)
+
+// Requested tells whether r asks for a dry run. Guard has rejected values
+// that are not booleans by the time handlers call it.
+func Requested(r *http.Request) bool {
+	on, _ := value(r)
+	return on
+}
+
+func value(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get(Query)
+	if v == "" {
+		v = r.Header.Get(Header)
+	}
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// Guard rejects with 400 requests asking for a dry run with a value other
+// than a boolean and, unless supported, any dry run at all, so that a route
+// that cannot preview never performs the write a caller meant to try.
+func Guard(supported bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			on, err := value(r)
+			if err != nil {
+				e.BadRequest(w, RespInvalid)
+				return
+			}
+			if on && !supported {
+				e.BadRequest(w, RespUnsupported)
+				return
+			}
+			if on {
+				w.Header().Set(Header, "true")
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
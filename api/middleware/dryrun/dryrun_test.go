@@ -0,0 +1,50 @@
+package dryrun_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hello/api/middleware/dryrun"
+	testUtil "hello/util/test"
+)
+
+func TestGuard(t *testing.T) {
+	t.Parallel()
+
+	var requested bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = dryrun.Requested(r)
+	})
+
+	tests := []struct {
+		name      string
+		supported bool
+		target    string
+		header    string
+		status    int
+		requested bool
+	}{
+		{"no dry run", false, "/books", "", http.StatusOK, false},
+		{"query", true, "/books?dry_run=true", "", http.StatusOK, true},
+		{"header", true, "/books", "1", http.StatusOK, true},
+		{"query wins", true, "/books?dry_run=false", "true", http.StatusOK, false},
+		{"invalid", true, "/books?dry_run=maybe", "", http.StatusBadRequest, false},
+		{"unsupported", false, "/books", "true", http.StatusBadRequest, false},
+		{"off on unsupported", false, "/books?dry_run=false", "", http.StatusOK, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requested = false
+			req := httptest.NewRequest(http.MethodPost, tc.target, nil)
+			if tc.header != "" {
+				req.Header.Set(dryrun.Header, tc.header)
+			}
+			w := httptest.NewRecorder()
+			dryrun.Guard(tc.supported)(handler).ServeHTTP(w, req)
+			testUtil.Equal(t, tc.status, w.Code)
+			testUtil.Equal(t, tc.requested, requested)
+			testUtil.Equal(t, tc.requested, w.Header().Get(dryrun.Header) == "true")
+		})
+	}
+}
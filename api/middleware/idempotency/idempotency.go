@@ -0,0 +1,282 @@
+// Package idempotency makes retried writes safe. A client sends a unique
+// Idempotency-Key with a request; the first response to it is stored, and a
+// retry with the same key gets that response again instead of repeating the
+// write.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"hello/api/middleware/dryrun"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+)
+
+const (
+	// Header carries the key chosen by the client.
+	Header = "Idempotency-Key"
+	// ReplayedHeader marks a response replayed from an earlier request.
+	ReplayedHeader = "Idempotent-Replayed"
+	// MaxKeyLength bounds keys; a UUID fits comfortably.
+	MaxKeyLength = 255
+)
+
+var (
+	RespInvalidKey = e.New(http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be 1 to 255 characters")
+	RespKeyReused  = e.New(http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used for a different request")
+	RespInProgress = e.New(http.StatusConflict, "idempotency_key_in_progress", "a request with this Idempotency-Key is still in progress")
+)
+
+// storedHeaders are the response headers replayed along with the status and
+// body.
+var storedHeaders = []string{"Content-Type", "Location", "ETag", "Warning"}
+
+// Record is the stored outcome of a request made with a key. Keys are
+// scoped to the caller, so that two clients choosing the same key do not
+// see each other's responses. Status is zero while the first request is
+// still being served.
+type Record struct {
+	Caller      string `gorm:"primarykey"`
+	Key         string `gorm:"primarykey"`
+	RequestHash string
+	Status      int
+	Header      []byte
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
+
+// Store keeps the records in the idempotency_keys table for TTL after the
+// first request.
+type Store struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+func New(db *gorm.DB, ttl time.Duration) *Store {
+	return &Store{db: db, ttl: ttl}
+}
+
+// Middleware serves requests carrying an Idempotency-Key at most once per
+// key. A retry of the same request, by method, URL and body, replays the
+// stored response with the Idempotent-Replayed header; reusing the key for
+// a different request answers 422, and retrying while the first request is
+// still being served answers 409. Responses with a 5xx status are not
+// stored, so that a request that failed on the server can be retried.
+// Requests without a key, and dry runs, pass through.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || dryrun.Requested(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > MaxKeyLength {
+			e.Write(w, RespInvalidKey)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			e.BadRequest(w, e.RespInvalidBody)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := &Record{Caller: caller(r.Context()), Key: key, RequestHash: requestHash(r, body)}
+		existing, err := s.claim(r.Context(), rec)
+		if err != nil {
+			log.Printf("idempotency: claim key: %s", err)
+			e.ServerError(w, e.RespInternal)
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != rec.RequestHash:
+				e.Write(w, RespKeyReused)
+			case existing.Status == 0:
+				e.Write(w, RespInProgress)
+			default:
+				replay(w, existing)
+			}
+			return
+		}
+
+		// The outcome is saved even if the client has gone away, as the write
+		// it stands for has happened.
+		ctx := context.WithoutCancel(r.Context())
+		tw := &teeWriter{ResponseWriter: w}
+		done := false
+		defer func() {
+			if !done {
+				s.release(ctx, rec)
+			}
+		}()
+		next.ServeHTTP(tw, r)
+
+		done = true
+		if tw.status == 0 {
+			tw.status = http.StatusOK
+		}
+		if tw.status >= http.StatusInternalServerError {
+			s.release(ctx, rec)
+			return
+		}
+		if err := s.complete(ctx, rec, tw); err != nil {
+			log.Printf("idempotency: save response: %s", err)
+		}
+	})
+}
+
+// claim inserts rec as the pending record of its key, replacing an expired
+// one. When the key is taken, it returns the record holding it instead.
+func (s *Store) claim(ctx context.Context, rec *Record) (*Record, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	if err := db.Where("caller = ? AND key = ? AND expires_at <= ?", rec.Caller, rec.Key, now).Delete(&Record{}).Error; err != nil {
+		return nil, err
+	}
+
+	rec.CreatedAt, rec.ExpiresAt = now, now.Add(s.ttl)
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(rec)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	existing := &Record{}
+	if err := db.Where("caller = ? AND key = ?", rec.Caller, rec.Key).First(existing).Error; err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// replay writes the stored response of rec.
+func replay(w http.ResponseWriter, rec *Record) {
+	var header http.Header
+	if err := json.Unmarshal(rec.Header, &header); err != nil {
+		log.Printf("idempotency: stored header: %s", err)
+	}
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+func (s *Store) complete(ctx context.Context, rec *Record, tw *teeWriter) error {
+	header := http.Header{}
+	for _, k := range storedHeaders {
+		if v := tw.Header().Values(k); len(v) > 0 {
+			header[k] = v
+		}
+	}
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Model(&Record{}).
+		Where("caller = ? AND key = ?", rec.Caller, rec.Key).
+		Updates(map[string]any{"status": tw.status, "header": raw, "body": tw.body.Bytes()}).Error
+}
+
+// release drops the pending record of a request that failed, so that the
+// key can be used again.
+func (s *Store) release(ctx context.Context, rec *Record) {
+	err := s.db.WithContext(ctx).Where("caller = ? AND key = ? AND status = 0", rec.Caller, rec.Key).Delete(&Record{}).Error
+	if err != nil {
+		log.Printf("idempotency: release key: %s", err)
+	}
+}
+
+// DeleteExpired removes the records that expired by now.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) error {
+	return s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&Record{}).Error
+}
+
+// Run deletes expired records every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.DeleteExpired(ctx, time.Now()); err != nil {
+				log.Printf("idempotency sweep: %s", err)
+			}
+		}
+	}
+}
+
+// caller identifies who made the request: the tenant and the user or
+// service account. Anonymous callers of a tenant share their keys.
+func caller(ctx context.Context) string {
+	var b strings.Builder
+	b.WriteString(tenant.From(ctx))
+	b.WriteByte('/')
+	if id, ok := user.From(ctx); ok {
+		b.WriteString(id.String())
+	} else if id, ok := user.Service(ctx); ok {
+		b.WriteString("service:" + id.String())
+	} else if id, ok := user.APIKey(ctx); ok {
+		b.WriteString("key:" + id.String())
+	}
+	return b.String()
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// teeWriter passes the response through while keeping a copy of it.
+type teeWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (t *teeWriter) WriteHeader(status int) {
+	if t.status == 0 {
+		t.status = status
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *teeWriter) Write(b []byte) (int, error) {
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	t.body.Write(b)
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *teeWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
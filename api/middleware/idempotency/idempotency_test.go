@@ -0,0 +1,83 @@
+package idempotency_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"hello/api/middleware/idempotency"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+)
+
+func TestStore_Middleware(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &idempotency.Record{})
+
+	created, failures := 0, 1
+	store := idempotency.New(db, time.Hour)
+	h := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "fail") && failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		created++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/books/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"title": "Dune"}`))
+	}))
+
+	serve := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	first := serve("k1", `{"title": "Dune"}`)
+	testUtil.Equal(t, http.StatusCreated, first.Code)
+	testUtil.Equal(t, "", first.Header().Get(idempotency.ReplayedHeader))
+
+	again := serve("k1", `{"title": "Dune"}`)
+	testUtil.Equal(t, http.StatusCreated, again.Code)
+	testUtil.Equal(t, "true", again.Header().Get(idempotency.ReplayedHeader))
+	testUtil.Equal(t, "/books/1", again.Header().Get("Location"))
+	testUtil.Equal(t, first.Body.String(), again.Body.String())
+	testUtil.Equal(t, 1, created)
+
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve("k1", `{"title": "Emma"}`).Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(strings.Repeat("k", idempotency.MaxKeyLength+1), `{}`).Code)
+
+	// Without a key, every request is served.
+	serve("", `{}`)
+	serve("", `{}`)
+	testUtil.Equal(t, 3, created)
+
+	// A server error is not stored, so the retry is served.
+	testUtil.Equal(t, http.StatusInternalServerError, serve("k2", `"fail"`).Code)
+	testUtil.Equal(t, http.StatusCreated, serve("k2", `"fail"`).Code)
+	testUtil.Equal(t, 4, created)
+
+	// A request still in progress holds its key.
+	testUtil.NoError(t, db.Model(&idempotency.Record{}).Where("key = ?", "k1").Update("status", 0).Error)
+	testUtil.Equal(t, http.StatusConflict, serve("k1", `{"title": "Dune"}`).Code)
+
+	// Expired keys are swept, and may then be used again.
+	testUtil.NoError(t, store.DeleteExpired(context.Background(), time.Now().Add(2*time.Hour)))
+	var n int64
+	testUtil.NoError(t, db.Model(&idempotency.Record{}).Count(&n).Error)
+	testUtil.Equal(t, int64(0), n)
+	testUtil.Equal(t, "", serve("k1", `{"title": "Emma"}`).Header().Get(idempotency.ReplayedHeader))
+	testUtil.Equal(t, 5, created)
+}
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header carries the request ID. An incoming one is kept, e.g. when the
+// gateway assigned it already, as long as it is a plain token of at most
+// maxIDLen characters.
+const Header = "X-Request-ID"
+
+const maxIDLen = 128
+
+type ctxKey struct{}
+
+type entry struct {
+	id     string
+	logger *slog.Logger
+}
+
+// New returns middleware that assigns every request an ID, echoes it in the
+// X-Request-ID response header and logs the request as one structured line
+// once it is served. Requests served in a trace span are tagged with the
+// trace ID as well.
+func New(l *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(Header)
+			if !validID(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(Header, id)
+
+			rl := l.With("request_id", id)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				rl = rl.With("trace_id", sc.TraceID().String())
+			}
+			ctx := context.WithValue(r.Context(), ctxKey{}, &entry{id: id, logger: rl})
+			rw := &writer{ResponseWriter: w}
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+			rl.LogAttrs(ctx, level(rw.status), "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rw.status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes", rw.bytes),
+			)
+		})
+	}
+}
+
+// RequestID returns the ID of the request, or "" outside the middleware.
+func RequestID(ctx context.Context) string {
+	if e, ok := ctx.Value(ctxKey{}).(*entry); ok {
+		return e.id
+	}
+	return ""
+}
+
+// From returns a logger tagged with the request ID, for handlers to log
+// lines that correlate with the request line. Outside the middleware it is
+// the default logger.
+func From(ctx context.Context) *slog.Logger {
+	if e, ok := ctx.Value(ctxKey{}).(*entry); ok {
+		return e.logger
+	}
+	return slog.Default()
+}
+
+func level(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+func validID(id string) bool {
+	if id == "" || len(id) > maxIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type writer struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *writer) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
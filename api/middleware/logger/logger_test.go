@@ -0,0 +1,84 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hello/api/middleware/logger"
+	e "hello/api/resource/common/err"
+	testUtil "hello/util/test"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	var seen string
+	h := logger.New(slog.New(slog.NewJSONHandler(&out, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.RequestID(r.Context())
+		if r.URL.Path == "/fail" {
+			e.BadRequest(w, e.RespInvalidFilter)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	tests := []struct {
+		name     string
+		path     string
+		incoming string
+		keep     bool
+		status   int
+	}{
+		{"generated", "/books", "", false, http.StatusOK},
+		{"propagated", "/books", "gw-42.a_b", true, http.StatusOK},
+		{"invalid incoming", "/books", "bad id\n", false, http.StatusOK},
+		{"too long incoming", "/books", strings.Repeat("a", 129), false, http.StatusOK},
+		{"error", "/fail", "gw-43", true, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out.Reset()
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.incoming != "" {
+				req.Header.Set(logger.Header, tc.incoming)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			id := w.Header().Get(logger.Header)
+			testUtil.Equal(t, id, seen)
+			testUtil.Equal(t, tc.keep, id == tc.incoming)
+			testUtil.Equal(t, true, id != "")
+
+			var line struct {
+				Msg       string  `json:"msg"`
+				RequestID string  `json:"request_id"`
+				Method    string  `json:"method"`
+				Path      string  `json:"path"`
+				Status    int     `json:"status"`
+				Latency   float64 `json:"latency_ms"`
+				Bytes     int     `json:"bytes"`
+			}
+			testUtil.NoError(t, json.Unmarshal(out.Bytes(), &line))
+			testUtil.Equal(t, "request", line.Msg)
+			testUtil.Equal(t, id, line.RequestID)
+			testUtil.Equal(t, http.MethodGet, line.Method)
+			testUtil.Equal(t, tc.path, line.Path)
+			testUtil.Equal(t, tc.status, line.Status)
+			testUtil.Equal(t, w.Body.Len(), line.Bytes)
+
+			if tc.status != http.StatusOK {
+				var body e.Problem
+				testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				testUtil.Equal(t, "invalid_filter", body.Code)
+				testUtil.Equal(t, id, body.RequestID)
+			}
+		})
+	}
+}
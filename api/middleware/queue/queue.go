@@ -0,0 +1,444 @@
+// Package queue keeps heavy requests, such as exports, imports and
+// searches, from taking every database connection. A Queue serves a fixed
+// number of them at once. Requests arriving while all are busy wait in the
+// queue and are answered 202 Accepted with an operation telling their
+// position; once served, the operation points at the response, kept for a
+// while to be fetched.
+//
+// Waiting requests are taken in turns by identity, so that one client
+// queueing many requests does not hold back the others. Operations live in
+// the instance that accepted them and are lost when it stops.
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/ratelimit"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+	"hello/worker"
+)
+
+const (
+	// MaxBody bounds the bodies of queued requests, which are kept in
+	// memory until served. It leaves room for a 10 MiB import.
+	MaxBody = 16 << 20
+	// MaxResult bounds the responses kept for operations. Larger ones fail
+	// the operation; they are better fetched when the queue is quiet.
+	MaxResult = 64 << 20
+)
+
+// Status of an operation.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+var (
+	RespQueueFull      = e.New(http.StatusServiceUnavailable, "queue_full", "too many heavy requests are waiting, retry later")
+	RespBodyTooLarge   = e.New(http.StatusRequestEntityTooLarge, "body_too_large", "request body too large to queue")
+	RespResultTooLarge = e.New(http.StatusInternalServerError, "result_too_large", "response too large to keep, retry when the queue is quiet")
+	RespNotDone        = e.New(http.StatusConflict, "operation_not_done", "operation not done yet")
+)
+
+// Operation is a queued request. Position counts from 1 for the next
+// request to be served, and is only set while queued. Result is the path of
+// the response once done; Error tells why a failed operation has none.
+type Operation struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	Position  int        `json:"position,omitempty"`
+	Result    string     `json:"result,omitempty"`
+	Error     *e.Problem `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	DoneAt    *time.Time `json:"done_at,omitempty"`
+}
+
+type operation struct {
+	id       uuid.UUID
+	identity string
+	status   string
+	created  time.Time
+	done     time.Time
+	err      *e.Problem
+
+	next http.Handler
+	r    *http.Request
+	rec  *recorder
+}
+
+// Queue serves up to workers heavy requests at once and queues up to depth
+// more. Responses of queued requests are kept for ttl once done.
+type Queue struct {
+	workers int
+	depth   int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	running int
+	waiting int
+	// pending holds the queued operations of each identity, in order;
+	// turns lists the identities with some, the next to be served first.
+	pending map[string][]*operation
+	turns   []string
+	ops     map[uuid.UUID]*operation
+
+	meter worker.Meter
+}
+
+func New(workers, depth int, ttl time.Duration) *Queue {
+	return &Queue{
+		workers: max(1, workers),
+		depth:   depth,
+		ttl:     ttl,
+		pending: make(map[string][]*operation),
+		ops:     make(map[uuid.UUID]*operation),
+	}
+}
+
+// Middleware serves requests at once while the queue has room to, and
+// queues them otherwise, answering 202 with the operation and its Location.
+// A full queue answers 503 with Retry-After.
+func (q *Queue) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q.mu.Lock()
+		if q.running < q.workers {
+			q.running++
+			q.mu.Unlock()
+			defer q.release()
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			q.meter.Observe(0, time.Since(start))
+			return
+		}
+		full := q.waiting >= q.depth
+		q.mu.Unlock()
+		if full {
+			w.Header().Set("Retry-After", "5")
+			e.Write(w, RespQueueFull)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBody))
+		if err != nil {
+			e.PayloadTooLarge(w, RespBodyTooLarge)
+			return
+		}
+
+		// The request is served after it was answered, so it must outlive
+		// its context and read its body from memory.
+		queued := r.Clone(context.WithoutCancel(r.Context()))
+		queued.Body = io.NopCloser(bytes.NewReader(body))
+		op := &operation{
+			id:       uuid.New(),
+			identity: ratelimit.IdentityKey(r),
+			status:   StatusQueued,
+			created:  time.Now(),
+			next:     next,
+			r:        queued,
+		}
+
+		q.mu.Lock()
+		q.sweep()
+		if q.waiting >= q.depth {
+			q.mu.Unlock()
+			w.Header().Set("Retry-After", "5")
+			e.Write(w, RespQueueFull)
+			return
+		}
+		q.enqueue(op)
+		q.dispatch()
+		v := q.view(op)
+		q.mu.Unlock()
+
+		w.Header().Set("Location", location(op))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			e.ServerError(w, e.RespJSONEncodeFailure)
+			return
+		}
+	})
+}
+
+func location(op *operation) string {
+	return "/v1/operations/" + idcodec.Encode(op.id)
+}
+
+func (q *Queue) enqueue(op *operation) {
+	if len(q.pending[op.identity]) == 0 {
+		q.turns = append(q.turns, op.identity)
+	}
+	q.pending[op.identity] = append(q.pending[op.identity], op)
+	q.ops[op.id] = op
+	q.waiting++
+}
+
+// release frees the slot of a request and serves the next waiting one.
+func (q *Queue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	q.dispatch()
+}
+
+// dispatch starts queued operations while there are free slots, taking
+// identities in turns. It is called with q.mu held.
+func (q *Queue) dispatch() {
+	for q.running < q.workers && len(q.turns) > 0 {
+		identity := q.turns[0]
+		q.turns = q.turns[1:]
+		ops := q.pending[identity]
+		op := ops[0]
+		if len(ops) > 1 {
+			q.pending[identity] = ops[1:]
+			q.turns = append(q.turns, identity)
+		} else {
+			delete(q.pending, identity)
+		}
+		q.waiting--
+		q.running++
+		op.status = StatusRunning
+		go q.run(op)
+	}
+}
+
+func (q *Queue) run(op *operation) {
+	defer q.release()
+
+	start := time.Now()
+	rec := newRecorder()
+	op.next.ServeHTTP(rec, op.r)
+	q.meter.Observe(start.Sub(op.created), time.Since(start))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	op.r, op.next = nil, nil
+	op.done = time.Now()
+	if rec.overflow {
+		op.status = StatusFailed
+		op.err = RespResultTooLarge
+		return
+	}
+	op.status = StatusDone
+	op.rec = rec
+}
+
+// Stats tells how many requests are served and waiting, and how long they
+// waited and took.
+func (q *Queue) Stats() worker.Stats {
+	q.mu.Lock()
+	s := worker.Stats{Workers: q.workers, Busy: q.running, Queued: q.waiting, Capacity: q.depth}
+	q.mu.Unlock()
+	q.meter.Fill(&s)
+	return s
+}
+
+// Resize sets how many requests are served at once. Growing the queue
+// serves waiting requests at once; shrinking it lets the requests being
+// served complete.
+func (q *Queue) Resize(n int) error {
+	if err := worker.Validate(n); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.workers = n
+	q.dispatch()
+	return nil
+}
+
+// sweep drops the operations done for longer than q.ttl. It is called with
+// q.mu held.
+func (q *Queue) sweep() {
+	now := time.Now()
+	for id, op := range q.ops {
+		if !op.done.IsZero() && now.Sub(op.done) > q.ttl {
+			delete(q.ops, id)
+		}
+	}
+}
+
+// position returns where op is in the order operations will be served in,
+// counting from 1. Each identity has one operation served per round, in
+// the order of q.turns. It is called with q.mu held.
+func (q *Queue) position(op *operation) int {
+	ops := q.pending[op.identity]
+	round := 0
+	for i, o := range ops {
+		if o == op {
+			round = i
+			break
+		}
+	}
+
+	position, before := 1, true
+	for _, identity := range q.turns {
+		n := len(q.pending[identity])
+		position += min(n, round)
+		if identity == op.identity {
+			before = false
+		}
+		if before && n > round {
+			position++
+		}
+	}
+	return position
+}
+
+// view returns op as shown to clients. It is called with q.mu held.
+func (q *Queue) view(op *operation) *Operation {
+	v := &Operation{
+		ID:        idcodec.Encode(op.id),
+		Status:    op.status,
+		CreatedAt: op.created,
+		Error:     op.err,
+	}
+	switch op.status {
+	case StatusQueued:
+		v.Position = q.position(op)
+	case StatusDone:
+		v.Result = location(op) + "/result"
+	}
+	if !op.done.IsZero() {
+		v.DoneAt = &op.done
+	}
+	return v
+}
+
+// lookup returns the operation of the id URL parameter, if the caller
+// queued it. Operations of other callers are not found, so that their IDs
+// reveal nothing.
+func (q *Queue) lookup(r *http.Request) *operation {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil
+	}
+
+	q.sweep()
+	op := q.ops[id]
+	if op == nil || op.identity != ratelimit.IdentityKey(r) {
+		return nil
+	}
+	return op
+}
+
+// Read godoc
+//
+//	@summary        Read operation
+//	@description    Status of a queued heavy request. Position counts from 1 while queued; Retry-After suggests when to ask again. Once done, result is the path of the response
+//	@tags           operations
+//	@produce        json
+//	@param          id	path        string  true    "Operation ID"
+//	@success        200 {object}    Operation
+//	@failure        404 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /operations/{id} [get]
+func (q *Queue) Read(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	op := q.lookup(r)
+	var v *Operation
+	if op != nil {
+		v = q.view(op)
+	}
+	q.mu.Unlock()
+	if v == nil {
+		e.NotFound(w, e.RespNotFound)
+		return
+	}
+
+	if v.Status == StatusQueued || v.Status == StatusRunning {
+		w.Header().Set("Retry-After", strconv.Itoa(min(30, max(1, v.Position))))
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		e.ServerError(w, e.RespJSONEncodeFailure)
+		return
+	}
+}
+
+// Result godoc
+//
+//	@summary        Read operation result
+//	@description    The response of a queued heavy request, as the endpoint it was made to answered it
+//	@tags           operations
+//	@param          id	path        string  true    "Operation ID"
+//	@success        200
+//	@failure        404 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@router         /operations/{id}/result [get]
+func (q *Queue) Result(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	op := q.lookup(r)
+	var rec *recorder
+	if op != nil {
+		rec = op.rec
+	}
+	q.mu.Unlock()
+	if op == nil {
+		e.NotFound(w, e.RespNotFound)
+		return
+	}
+	if rec == nil {
+		e.Write(w, RespNotDone)
+		return
+	}
+	rec.replay(w)
+}
+
+// recorder keeps a response to be replayed, up to MaxResult bytes of body.
+type recorder struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header)}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if rec.body.Len()+len(b) > MaxResult {
+		rec.overflow = true
+		return 0, http.ErrContentLength
+	}
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) Flush() {}
+
+func (rec *recorder) replay(w http.ResponseWriter) {
+	h := w.Header()
+	for k, v := range rec.header {
+		h[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(max(rec.status, http.StatusOK))
+	w.Write(rec.body.Bytes())
+}
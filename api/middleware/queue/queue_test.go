@@ -0,0 +1,146 @@
+package queue_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/queue"
+	"hello/api/middleware/user"
+	testUtil "hello/util/test"
+)
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+
+	q := queue.New(1, 3, time.Minute)
+	started, release := make(chan struct{}, 4), make(chan struct{})
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.With(q.Middleware).Post("/export", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write(body)
+	})
+	r.Get("/v1/operations/{id}", q.Read)
+	r.Get("/v1/operations/{id}/result", q.Result)
+
+	users := map[string]string{"a": uuid.NewString(), "b": uuid.NewString(), "c": uuid.NewString()}
+	serve := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(user.Header, users[key])
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	operation := func(w *httptest.ResponseRecorder) *queue.Operation {
+		op := &queue.Operation{}
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), op))
+		return op
+	}
+
+	// The first request is served at once, and holds the only worker.
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- serve(http.MethodPost, "/export", "a", "a0") }()
+	<-started
+	testUtil.Equal(t, http.StatusAccepted, serve(http.MethodPost, "/export", "a", "a1").Code)
+	// The second queued request of a waits behind the first of b.
+	w := serve(http.MethodPost, "/export", "a", "a2")
+	testUtil.Equal(t, http.StatusAccepted, w.Code)
+	a2 := w.Header().Get("Location")
+	testUtil.Equal(t, 2, operation(w).Position)
+	w = serve(http.MethodPost, "/export", "b", "b1")
+	testUtil.Equal(t, http.StatusAccepted, w.Code)
+	b1 := w.Header().Get("Location")
+	testUtil.Equal(t, 2, operation(w).Position)
+
+	w = serve(http.MethodGet, a2, "a", "")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, 3, operation(w).Position)
+	testUtil.Equal(t, "3", w.Header().Get("Retry-After"))
+
+	// Operations are only found by whoever queued them.
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, a2, "b", "").Code)
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodGet, a2+"/result", "a", "").Code)
+
+	w = serve(http.MethodPost, "/export", "c", "c1")
+	testUtil.Equal(t, http.StatusServiceUnavailable, w.Code)
+	testUtil.Equal(t, "5", w.Header().Get("Retry-After"))
+
+	close(release)
+	testUtil.Equal(t, "a0", (<-first).Body.String())
+
+	for _, tc := range []struct{ location, key, body string }{{b1, "b", "b1"}, {a2, "a", "a2"}} {
+		deadline := time.Now().Add(time.Second)
+		for operation(serve(http.MethodGet, tc.location, tc.key, "")).Status != queue.StatusDone {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s not done", tc.location)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		op := operation(serve(http.MethodGet, tc.location, tc.key, ""))
+		testUtil.Equal(t, tc.location+"/result", op.Result)
+
+		w := serve(http.MethodGet, op.Result, tc.key, "")
+		testUtil.Equal(t, http.StatusOK, w.Code)
+		testUtil.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		testUtil.Equal(t, tc.body, w.Body.String())
+	}
+}
+
+func TestQueue_Resize(t *testing.T) {
+	t.Parallel()
+
+	q := queue.New(1, 3, time.Minute)
+	started, release := make(chan struct{}, 4), make(chan struct{})
+	h := q.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	serve := func() int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/export", nil))
+		return w.Code
+	}
+
+	done := make(chan int)
+	go func() { done <- serve() }()
+	<-started
+	testUtil.Equal(t, http.StatusAccepted, serve())
+	testUtil.Equal(t, http.StatusAccepted, serve())
+
+	s := q.Stats()
+	testUtil.Equal(t, 1, s.Workers)
+	testUtil.Equal(t, 1, s.Busy)
+	testUtil.Equal(t, 2, s.Queued)
+	testUtil.Equal(t, 3, s.Capacity)
+
+	// Growing the queue serves the waiting requests at once.
+	testUtil.NoError(t, q.Resize(3))
+	<-started
+	<-started
+	s = q.Stats()
+	testUtil.Equal(t, 3, s.Busy)
+	testUtil.Equal(t, 0, s.Queued)
+	testUtil.Equal(t, true, q.Resize(0) != nil)
+
+	close(release)
+	testUtil.Equal(t, http.StatusOK, <-done)
+	deadline := time.Now().Add(time.Second)
+	for q.Stats().Busy > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("queued requests not processed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	testUtil.Equal(t, int64(3), q.Stats().Processed)
+}
@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenBucket allows bursts of up to limit requests per key and refills
+// at limit requests per window, so that a client spreading its requests
+// evenly is never rejected, unlike at the edges of a fixed window.
+type TokenBucket struct {
+	limit int
+	// rate is the number of tokens added per second.
+	rate   float64
+	window time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweepAt time.Time
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+func NewTokenBucket(limit int, window time.Duration) *TokenBucket {
+	return &TokenBucket{
+		limit:   limit,
+		rate:    float64(limit) / window.Seconds(),
+		window:  window,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (tb *TokenBucket) Allow(key string) Result {
+	now := time.Now()
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.sweep(now)
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(tb.limit), at: now}
+		tb.buckets[key] = b
+	}
+	b.tokens = min(float64(tb.limit), b.tokens+now.Sub(b.at).Seconds()*tb.rate)
+	b.at = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return bucketResult(tb.limit, tb.rate, b.tokens, allowed, now)
+}
+
+// sweep drops full buckets, which are no different from missing ones, at
+// most once per window so idle clients do not accumulate.
+func (tb *TokenBucket) sweep(now time.Time) {
+	if now.Before(tb.sweepAt) {
+		return
+	}
+	for k, b := range tb.buckets {
+		if b.tokens+now.Sub(b.at).Seconds()*tb.rate >= float64(tb.limit) {
+			delete(tb.buckets, k)
+		}
+	}
+	tb.sweepAt = now.Add(tb.window)
+}
+
+// bucketResult reports a bucket left with tokens. Reset is when the next
+// request will be allowed for a rejected one, and when the bucket is full
+// again otherwise.
+func bucketResult(limit int, rate, tokens float64, allowed bool, now time.Time) Result {
+	missing := float64(limit) - tokens
+	if !allowed {
+		missing = 1 - tokens
+	}
+	return Result{
+		Limit:     limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     now.Add(time.Duration(missing / rate * float64(time.Second))),
+		Allowed:   allowed,
+	}
+}
+
+// bucketScript takes a token from the bucket at KEYS[1], given its capacity,
+// its refill rate in tokens per second and the time in milliseconds. It
+// returns whether a token was taken and the tokens left, as a string since
+// Redis truncates numbers returned by scripts.
+const bucketScript = `
+local limit = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(b[1]) or limit
+local at = tonumber(b[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - at) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(limit / rate * 1000))
+return {allowed, tostring(tokens)}
+`
+
+// RedisBucket is a TokenBucket kept in Redis, so that instances share the
+// quota of a client. Buckets expire once they would be full again. The time
+// is taken from the instance, so their clocks should agree.
+//
+// When Redis cannot be reached requests are allowed, so that an outage of
+// the limiter does not take the service down with it.
+type RedisBucket struct {
+	client *redis.Client
+	prefix string
+	limit  int
+	rate   float64
+}
+
+// NewRedisBucket keeps buckets under the prefix, which tells apart limiters
+// sharing a server.
+func NewRedisBucket(client *redis.Client, prefix string, limit int, window time.Duration) *RedisBucket {
+	return &RedisBucket{
+		client: client,
+		prefix: prefix,
+		limit:  limit,
+		rate:   float64(limit) / window.Seconds(),
+	}
+}
+
+func (rb *RedisBucket) Allow(key string) Result {
+	now := time.Now()
+	reply, err := rb.client.Eval(context.Background(), bucketScript, []string{rb.prefix + key},
+		strconv.Itoa(rb.limit),
+		strconv.FormatFloat(rb.rate, 'f', -1, 64),
+		strconv.FormatInt(now.UnixMilli(), 10),
+	).Result()
+	if err == nil {
+		if items, ok := reply.([]any); ok && len(items) == 2 {
+			allowed, _ := items[0].(int64)
+			s, _ := items[1].(string)
+			if tokens, perr := strconv.ParseFloat(s, 64); perr == nil {
+				return bucketResult(rb.limit, rb.rate, tokens, allowed == 1, now)
+			}
+		}
+	}
+
+	log.Printf("ratelimit: redis bucket %s: %v %v", key, err, reply)
+	return Result{Limit: rb.limit, Remaining: rb.limit, Reset: now, Allowed: true}
+}
@@ -0,0 +1,108 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"hello/api/middleware/ratelimit"
+	"hello/util/redis"
+	"hello/util/redis/redistest"
+	testUtil "hello/util/test"
+)
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	tb := ratelimit.NewTokenBucket(3, time.Hour)
+	for want := 2; want >= 0; want-- {
+		res := tb.Allow("k")
+		testUtil.Equal(t, true, res.Allowed)
+		testUtil.Equal(t, want, res.Remaining)
+	}
+
+	res := tb.Allow("k")
+	testUtil.Equal(t, false, res.Allowed)
+	testUtil.Equal(t, 0, res.Remaining)
+	// The next token arrives after a third of the window.
+	testUtil.Equal(t, true, time.Until(res.Reset) > 19*time.Minute && time.Until(res.Reset) <= 20*time.Minute)
+
+	testUtil.Equal(t, true, tb.Allow("other").Allowed)
+}
+
+func TestTokenBucket_Refill(t *testing.T) {
+	t.Parallel()
+
+	tb := ratelimit.NewTokenBucket(2, 100*time.Millisecond)
+	tb.Allow("k")
+	tb.Allow("k")
+	testUtil.Equal(t, false, tb.Allow("k").Allowed)
+
+	time.Sleep(60 * time.Millisecond)
+	testUtil.Equal(t, true, tb.Allow("k").Allowed)
+}
+
+func TestRedisBucket(t *testing.T) {
+	t.Parallel()
+
+	client := redis.New(startFakeRedis(t), "")
+	a := ratelimit.NewRedisBucket(client, "ratelimit:global:", 2, time.Hour)
+	b := ratelimit.NewRedisBucket(client, "ratelimit:global:", 2, time.Hour)
+
+	// Limiters sharing a prefix share buckets, as instances do.
+	testUtil.Equal(t, 1, a.Allow("k").Remaining)
+	testUtil.Equal(t, 0, b.Allow("k").Remaining)
+	res := a.Allow("k")
+	testUtil.Equal(t, false, res.Allowed)
+	testUtil.Equal(t, true, res.Reset.After(time.Now()))
+
+	other := ratelimit.NewRedisBucket(client, "ratelimit:upload:", 2, time.Hour)
+	testUtil.Equal(t, true, other.Allow("k").Allowed)
+}
+
+func TestRedisBucket_Unavailable(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	testUtil.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	rb := ratelimit.NewRedisBucket(redis.New(addr, ""), "ratelimit:global:", 1, time.Hour)
+	testUtil.Equal(t, true, rb.Allow("k").Allowed)
+	testUtil.Equal(t, true, rb.Allow("k").Allowed)
+}
+
+// startFakeRedis serves EVAL of the bucket script, running its logic in Go.
+func startFakeRedis(t *testing.T) string {
+	t.Helper()
+
+	srv := redistest.Start(t)
+	buckets := make(map[string][2]float64)
+	srv.Handle("EVAL", func(cmd []string) string {
+		if len(cmd) != 7 {
+			return "-ERR unknown command\r\n"
+		}
+		limit, _ := strconv.ParseFloat(cmd[4], 64)
+		rate, _ := strconv.ParseFloat(cmd[5], 64)
+		now, _ := strconv.ParseFloat(cmd[6], 64)
+
+		tokens, at := limit, now
+		if b, ok := buckets[cmd[3]]; ok {
+			tokens, at = b[0], b[1]
+		}
+		tokens = min(limit, tokens+max(0, now-at)/1000*rate)
+		allowed := 0
+		if tokens >= 1 {
+			tokens--
+			allowed = 1
+		}
+		buckets[cmd[3]] = [2]float64{tokens, now}
+
+		s := strconv.FormatFloat(tokens, 'f', -1, 64)
+		return fmt.Sprintf("*2\r\n:%d\r\n$%d\r\n%s\r\n", allowed, len(s), s)
+	})
+	return srv.Addr
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Result describes a client's quota after counting the current request.
+type Result struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Allowed   bool
+}
+
+// Limiter counts requests per client key.
+type Limiter interface {
+	Allow(key string) Result
+}
+
+// Factory makes the limiter of a quota of limit requests per window. Name
+// tells apart the quotas of one service, such as rate limit classes.
+type Factory func(name string, limit int, window time.Duration) Limiter
+
+// Algorithms understood by NewFactory.
+const (
+	AlgorithmFixedWindow = "fixed_window"
+	AlgorithmTokenBucket = "token_bucket"
+)
+
+// NewFactory returns a factory of limiters using algorithm, kept in memory,
+// or in Redis when client is not nil. Only token buckets can be kept in
+// Redis.
+func NewFactory(algorithm string, client *redis.Client) (Factory, error) {
+	switch {
+	case algorithm == AlgorithmFixedWindow && client == nil:
+		return func(_ string, limit int, window time.Duration) Limiter {
+			return NewFixedWindow(limit, window)
+		}, nil
+	case algorithm == AlgorithmTokenBucket && client == nil:
+		return func(_ string, limit int, window time.Duration) Limiter {
+			return NewTokenBucket(limit, window)
+		}, nil
+	case algorithm == AlgorithmTokenBucket:
+		return func(name string, limit int, window time.Duration) Limiter {
+			return NewRedisBucket(client, "ratelimit:"+name+":", limit, window)
+		}, nil
+	case algorithm == AlgorithmFixedWindow:
+		return nil, fmt.Errorf("rate limit algorithm %q cannot be kept in redis", algorithm)
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", algorithm)
+	}
+}
+
+// FixedWindow allows limit requests per key in each window.
+type FixedWindow struct {
+	limit  int
+	window time.Duration
+
+	mu       sync.Mutex
+	counters map[string]*counter
+	sweepAt  time.Time
+}
+
+type counter struct {
+	count int
+	reset time.Time
+}
+
+func NewFixedWindow(limit int, window time.Duration) *FixedWindow {
+	return &FixedWindow{
+		limit:    limit,
+		window:   window,
+		counters: make(map[string]*counter),
+	}
+}
+
+func (fw *FixedWindow) Allow(key string) Result {
+	now := time.Now()
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.sweep(now)
+
+	c, ok := fw.counters[key]
+	if !ok || !now.Before(c.reset) {
+		c = &counter{reset: now.Add(fw.window)}
+		fw.counters[key] = c
+	}
+	c.count++
+
+	return Result{
+		Limit:     fw.limit,
+		Remaining: max(0, fw.limit-c.count),
+		Reset:     c.reset,
+		Allowed:   c.count <= fw.limit,
+	}
+}
+
+// sweep drops expired counters at most once per window so idle clients do not
+// accumulate.
+func (fw *FixedWindow) sweep(now time.Time) {
+	if now.Before(fw.sweepAt) {
+		return
+	}
+	for k, c := range fw.counters {
+		if !now.Before(c.reset) {
+			delete(fw.counters, k)
+		}
+	}
+	fw.sweepAt = now.Add(fw.window)
+}
+
+// Classes holds the limiters of named rate limit classes, for routes that
+// need a quota tighter than the global one.
+type Classes map[string]Limiter
+
+// ParseClasses parses class definitions of the form "name=requests/window",
+// e.g. "upload=30/1m". Each class gets its own limiter from newLimiter.
+func ParseClasses(defs []string, newLimiter Factory) (Classes, error) {
+	classes := make(Classes, len(defs))
+	for _, def := range defs {
+		name, quota, ok := strings.Cut(def, "=")
+		requests, window, ok2 := strings.Cut(quota, "/")
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("rate limit class %q: want name=requests/window", def)
+		}
+
+		n, err := strconv.Atoi(requests)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("rate limit class %q: invalid request count", def)
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("rate limit class %q: invalid window", def)
+		}
+
+		classes[name] = newLimiter(name, n, d)
+	}
+	return classes, nil
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+)
+
+const (
+	HeaderLimit     = "X-RateLimit-Limit"
+	HeaderRemaining = "X-RateLimit-Remaining"
+	HeaderReset     = "X-RateLimit-Reset"
+	HeaderWarning   = "X-RateLimit-Warning"
+)
+
+// Classes routes fall into by method when they name none, so that reads
+// and writes are counted apart.
+const (
+	ClassRead  = "read"
+	ClassWrite = "write"
+)
+
+var RespRateLimitExceeded = e.New(http.StatusTooManyRequests, "rate_limit_exceeded", "rate limit exceeded")
+
+// KeyFunc identifies the client a request is counted against.
+type KeyFunc func(r *http.Request) string
+
+// ClientKey identifies clients by API key once the key has been
+// authenticated and by remote IP otherwise. Unauthenticated X-API-Key
+// headers are ignored, so that a client cannot dodge its quota by sending a
+// new one with every request, and secrets never end up in limiter keys. It
+// needs the key in the request context, so it only works behind the
+// middleware authenticating API keys.
+func ClientKey(r *http.Request) string {
+	if id, ok := user.APIKey(r.Context()); ok {
+		return "api_key:" + id.String()
+	}
+	return ipKey(r)
+}
+
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// IdentityKey identifies clients by who they act as: the signed-in user,
+// service account or API key, and by remote IP when anonymous. Headers
+// naming no authenticated caller never change the key, so that anonymous
+// callers cannot spread their requests over many quotas. It needs the
+// identity in the request context, so it only works behind the middleware
+// authenticating the caller.
+func IdentityKey(r *http.Request) string {
+	ctx := r.Context()
+	if id, ok := user.From(ctx); ok {
+		return "user:" + id.String()
+	}
+	if id, ok := user.Service(ctx); ok {
+		return "service:" + id.String()
+	}
+	if id, ok := user.APIKey(ctx); ok {
+		return "api_key:" + id.String()
+	}
+	return ipKey(r)
+}
+
+// New reports quota headers on every response, warns once a client has used
+// warnRatio of its quota, and rejects requests beyond it with 429.
+func New(l Limiter, key KeyFunc, warnRatio float64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := l.Allow(key(r))
+
+			h := w.Header()
+			h.Set(HeaderLimit, strconv.Itoa(res.Limit))
+			h.Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+			h.Set(HeaderReset, strconv.FormatInt(res.Reset.Unix(), 10))
+
+			if !res.Allowed {
+				retryAfter := int(math.Ceil(time.Until(res.Reset).Seconds()))
+				h.Set("Retry-After", strconv.Itoa(max(1, retryAfter)))
+				e.TooManyRequests(w, RespRateLimitExceeded)
+				return
+			}
+
+			if used := float64(res.Limit-res.Remaining) / float64(res.Limit); used >= warnRatio {
+				h.Set(HeaderWarning, fmt.Sprintf("%d%% of quota used; %d requests remain until %s",
+					int(used*100), res.Remaining, res.Reset.UTC().Format(time.RFC3339)))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/ratelimit"
+	"hello/api/middleware/user"
+	testUtil "hello/util/test"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	h := ratelimit.New(ratelimit.NewFixedWindow(5, time.Minute), ratelimit.ClientKey, 0.8)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/books", nil))
+		return w
+	}
+
+	for i := 1; i <= 3; i++ {
+		w := do()
+		testUtil.Equal(t, http.StatusOK, w.Code)
+		testUtil.Equal(t, "5", w.Header().Get(ratelimit.HeaderLimit))
+		testUtil.Equal(t, "", w.Header().Get(ratelimit.HeaderWarning))
+	}
+
+	w := do()
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "1", w.Header().Get(ratelimit.HeaderRemaining))
+	testUtil.Equal(t, true, w.Header().Get(ratelimit.HeaderWarning) != "")
+
+	do()
+	w = do()
+	testUtil.Equal(t, http.StatusTooManyRequests, w.Code)
+	testUtil.Equal(t, true, w.Header().Get("Retry-After") != "")
+}
+
+func TestClientKey(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "sk_unchecked")
+	testUtil.Equal(t, "ip:192.0.2.1", ratelimit.ClientKey(req))
+
+	req = req.WithContext(user.WithAPIKey(req.Context(), id))
+	testUtil.Equal(t, "api_key:"+id.String(), ratelimit.ClientKey(req))
+}
+
+func TestIdentityKey(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"user", user.WithID(context.Background(), id), "user:" + id.String()},
+		{"service account", user.WithService(context.Background(), id), "service:" + id.String()},
+		{"api key", user.WithAPIKey(context.Background(), id), "api_key:" + id.String()},
+		{"anonymous", context.Background(), "ip:192.0.2.1"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(tc.ctx)
+		req.Header.Set("X-API-Key", "sk_"+uuid.NewString())
+		testUtil.Equal(t, tc.want, ratelimit.IdentityKey(req))
+	}
+}
+
+func TestParseClasses(t *testing.T) {
+	t.Parallel()
+
+	classes, err := ratelimit.ParseClasses([]string{"upload=30/1m", "admin=60/1h"}, newFixedWindow)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 2, len(classes))
+	testUtil.Equal(t, 30, classes["upload"].Allow("k").Limit)
+
+	for _, def := range []string{"upload", "upload=30", "=30/1m", "upload=x/1m", "upload=30/x", "upload=0/1m"} {
+		_, err := ratelimit.ParseClasses([]string{def}, newFixedWindow)
+		testUtil.Equal(t, true, err != nil)
+	}
+}
+
+func newFixedWindow(_ string, limit int, window time.Duration) ratelimit.Limiter {
+	return ratelimit.NewFixedWindow(limit, window)
+}
+
+func TestNewFactory(t *testing.T) {
+	t.Parallel()
+
+	newLimiter, err := ratelimit.NewFactory(ratelimit.AlgorithmTokenBucket, nil)
+	testUtil.NoError(t, err)
+	_, ok := newLimiter("global", 5, time.Minute).(*ratelimit.TokenBucket)
+	testUtil.Equal(t, true, ok)
+
+	_, err = ratelimit.NewFactory("sliding_log", nil)
+	testUtil.Equal(t, true, err != nil)
+}
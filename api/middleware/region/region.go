@@ -0,0 +1,24 @@
+package region
+
+import (
+	"net/http"
+
+	"hello/config"
+)
+
+const (
+	HeaderRegion     = "X-Served-By-Region"
+	HeaderRegionRole = "X-Region-Role"
+)
+
+// Headers exposes which region, and in which role, served the request so that
+// clients and the global load balancer can tell when a failover happened.
+func Headers(c *config.ConfRegion) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderRegion, c.Name)
+			w.Header().Set(HeaderRegionRole, c.Role)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package scope
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	e "hello/api/resource/common/err"
+)
+
+// Header carries the caller's scopes as a comma-separated list. The service
+// does no authentication of its own, so the header is expected to be set by
+// the gateway in front of it after authenticating the caller.
+const Header = "X-Scopes"
+
+var RespInsufficientScope = e.New(http.StatusForbidden, "insufficient_scope", "insufficient scope")
+
+type ctxKey struct{}
+
+// Middleware stores the scopes from the X-Scopes header in the request
+// context.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var scopes []string
+		for _, s := range strings.Split(r.Header.Get(Header), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				scopes = append(scopes, s)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(With(r.Context(), scopes)))
+	})
+}
+
+// With returns ctx carrying scopes, replacing those of the header.
+func With(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, scopes)
+}
+
+// From returns the scopes of the request context.
+func From(ctx context.Context) []string {
+	scopes, _ := ctx.Value(ctxKey{}).([]string)
+	return scopes
+}
+
+// Require rejects with 403 requests whose scopes include none of scopes.
+// It relies on Middleware having run first.
+func Require(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.ContainsFunc(From(r.Context()), func(s string) bool {
+				return slices.Contains(scopes, s)
+			}) {
+				e.Forbidden(w, RespInsufficientScope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	e "hello/api/resource/common/err"
+)
+
+const (
+	Header  = "X-Tenant-ID"
+	Default = "default"
+)
+
+var (
+	idRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+	RespInvalidTenant = e.New(http.StatusBadRequest, "invalid_tenant", "invalid tenant")
+)
+
+type ctxKey struct{}
+
+// Middleware resolves the tenant from the X-Tenant-ID header, falling back to
+// the default tenant when none is sent.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if id == "" {
+			id = Default
+		}
+		if !idRegexp.MatchString(id) {
+			e.BadRequest(w, RespInvalidTenant)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(With(r.Context(), id)))
+	})
+}
+
+// With returns ctx carrying the tenant id, replacing that of the header.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// From returns the tenant of the request context, or the default tenant.
+func From(ctx context.Context) string {
+	if id, ok := ctx.Value(ctxKey{}).(string); ok {
+		return id
+	}
+	return Default
+}
@@ -0,0 +1,78 @@
+package user
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	e "hello/api/resource/common/err"
+)
+
+// Header carries the ID of the signed-in user. Like X-Scopes it is expected
+// to be set by the gateway in front of the service after authenticating the
+// caller.
+const Header = "X-User-ID"
+
+var RespInvalidUser = e.New(http.StatusBadRequest, "invalid_user", "invalid user")
+
+type (
+	ctxKey        struct{}
+	serviceCtxKey struct{}
+	apiKeyCtxKey  struct{}
+)
+
+// Middleware stores the user from the X-User-ID header in the request
+// context. Requests without the header are anonymous.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get(Header)
+		if v == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := uuid.Parse(v)
+		if err != nil {
+			e.BadRequest(w, RespInvalidUser)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
+
+// WithID returns ctx carrying the user id.
+func WithID(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// From returns the user of the request context, if any.
+func From(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(ctxKey{}).(uuid.UUID)
+	return id, ok
+}
+
+// WithService returns ctx carrying the service account calling, which acts
+// on its own behalf rather than a user's.
+func WithService(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, serviceCtxKey{}, id)
+}
+
+// Service returns the service account of the request context, if any.
+func Service(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(serviceCtxKey{}).(uuid.UUID)
+	return id, ok
+}
+
+// WithAPIKey returns ctx carrying the API key calling, which like a service
+// account acts on its own behalf.
+func WithAPIKey(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, apiKeyCtxKey{}, id)
+}
+
+// APIKey returns the API key of the request context, if any.
+func APIKey(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(apiKeyCtxKey{}).(uuid.UUID)
+	return id, ok
+}
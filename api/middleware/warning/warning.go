@@ -0,0 +1,92 @@
+package warning
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	CodeDeprecated          = "deprecated"
+	CodeLimitClamped        = "limit_clamped"
+	CodeImageURLUnreachable = "image_url_unreachable"
+	CodeCredentialExpiring  = "credential_expiring"
+)
+
+// Warning is a non-fatal problem with an otherwise successful request.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ctxKey struct{}
+
+type collector struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+// Add records a warning for the current request. It is a no-op when the
+// request did not pass through the middleware.
+func Add(r *http.Request, code, message string) {
+	c, ok := r.Context().Value(ctxKey{}).(*collector)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	c.warnings = append(c.warnings, Warning{Code: code, Message: message})
+	c.mu.Unlock()
+}
+
+// From returns the warnings recorded so far, for handlers that embed them in
+// a response body.
+func From(ctx context.Context) []Warning {
+	c, ok := ctx.Value(ctxKey{}).(*collector)
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Warning(nil), c.warnings...)
+}
+
+// Middleware collects warnings raised while handling a request, logs them and
+// surfaces each as a Warning header (RFC 7234 code 299) when the response
+// header is written.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := &collector{}
+		r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, c))
+		next.ServeHTTP(&writer{ResponseWriter: w, r: r, c: c}, r)
+	})
+}
+
+type writer struct {
+	http.ResponseWriter
+	r           *http.Request
+	c           *collector
+	wroteHeader bool
+}
+
+func (w *writer) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for _, wa := range From(w.r.Context()) {
+			log.Printf("warning %s %s: %s: %s", w.r.Method, w.r.URL.Path, wa.Code, wa.Message)
+			w.Header().Add("Warning", "299 - "+strconv.Quote(fmt.Sprintf("%s: %s", wa.Code, wa.Message)))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
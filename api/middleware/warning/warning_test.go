@@ -0,0 +1,25 @@
+package warning_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hello/api/middleware/warning"
+	testUtil "hello/util/test"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	h := warning.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		warning.Add(r, warning.CodeLimitClamped, "limit reduced to 100")
+		w.Write([]byte(`[]`))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/books/search?limit=500", nil))
+
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, `299 - "limit_clamped: limit reduced to 100"`, w.Header().Get("Warning"))
+}
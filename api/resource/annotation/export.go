@@ -0,0 +1,36 @@
+package annotation
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Export formats.
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+// writeMarkdown writes the annotations of the book titled title as a
+// Markdown document: each highlight as a quote, followed by its note and
+// position.
+func writeMarkdown(w io.Writer, title string, as Annotations) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", title)
+	for _, a := range as {
+		b.WriteString("\n")
+		if a.Text != "" {
+			for _, line := range strings.Split(a.Text, "\n") {
+				fmt.Fprintf(&b, "> %s\n", line)
+			}
+			b.WriteString("\n")
+		}
+		if a.Note != "" {
+			fmt.Fprintf(&b, "%s\n\n", a.Note)
+		}
+		fmt.Fprintf(&b, "*%s, %s*\n", a.Position, a.Color)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,370 @@
+package annotation
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+	"hello/moderation"
+	"hello/util/sanitizer"
+	validatorUtil "hello/util/validator"
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	moderator  *moderation.Moderator
+	now        func() time.Time
+}
+
+func New(db *gorm.DB, v *validator.Validate, moderator *moderation.Moderator) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		moderator:  moderator,
+		now:        time.Now,
+	}
+}
+
+// List godoc
+//
+//	@summary        List annotations
+//	@description    List the signed-in user's highlights and notes in a book, or with shared=true those other readers shared, in reading order
+//	@tags           annotations
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          shared  query   bool    false   "List the annotations other users shared"
+//	@success        200 {array}     DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/annotations [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	userID, bookID, _, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	repository := api.repository.WithContext(r.Context())
+	list := repository.List
+	if r.URL.Query().Get("shared") == "true" {
+		list = repository.Shared
+	}
+	annotations, err := list(bookID, userID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(annotations.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Create godoc
+//
+//	@summary        Create annotation
+//	@description    Highlight text in a book, add a note, or both. Annotations are private unless shared is set. Text matching the deny-list or scored as spam is rejected, or stored flagged for moderation
+//	@tags           annotations
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          body    body    Form    true    "Annotation form"
+//	@success        201 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/annotations [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	userID, bookID, _, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	form, err := api.form(r)
+	if err != nil {
+		return err
+	}
+	flagged, err := api.moderate(r, userID, form)
+	if err != nil {
+		return err
+	}
+
+	now := api.now()
+	a := form.ToModel()
+	a.ID = uuid.New()
+	a.BookID = bookID
+	a.UserID = userID
+	a.Flagged = flagged
+	a.CreatedAt, a.UpdatedAt = now, now
+
+	a, err = api.repository.WithContext(r.Context()).Create(a)
+	if err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	w.Header().Set("Location", "/v1/books/"+idcodec.Encode(bookID)+"/annotations/"+idcodec.Encode(a.ID))
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(a.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Read godoc
+//
+//	@summary        Read annotation
+//	@description    Read one of the signed-in user's annotations, or one another user shared
+//	@tags           annotations
+//	@produce        json
+//	@param          id              path    string  true    "Book ID"
+//	@param          annotationID    path    string  true    "Annotation ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/annotations/{annotationID} [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	userID, bookID, id, err := params(r)
+	if err != nil {
+		return err
+	}
+
+	a, err := api.repository.WithContext(r.Context()).Read(bookID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataAccessFailure
+	}
+	// Private annotations of others do not exist as far as the caller knows.
+	if a.UserID != userID && !a.Shared {
+		return e.RespNotFound
+	}
+
+	if err := json.NewEncoder(w).Encode(a.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Update godoc
+//
+//	@summary        Update annotation
+//	@description    Update one of the signed-in user's annotations, including whether it is shared
+//	@tags           annotations
+//	@accept         json
+//	@produce        json
+//	@param          id              path    string  true    "Book ID"
+//	@param          annotationID    path    string  true    "Annotation ID"
+//	@param          body            body    Form    true    "Annotation form"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/annotations/{annotationID} [put]
+func (api *API) Update(w http.ResponseWriter, r *http.Request) error {
+	userID, bookID, id, err := params(r)
+	if err != nil {
+		return err
+	}
+
+	form, err := api.form(r)
+	if err != nil {
+		return err
+	}
+	flagged, err := api.moderate(r, userID, form)
+	if err != nil {
+		return err
+	}
+
+	a := form.ToModel()
+	a.ID = id
+	a.BookID = bookID
+	a.UserID = userID
+	a.Flagged = flagged
+	a.UpdatedAt = api.now()
+
+	rows, err := api.repository.WithContext(r.Context()).Update(a)
+	if err != nil {
+		return e.RespDBDataUpdateFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Delete annotation
+//	@description    Delete one of the signed-in user's annotations
+//	@tags           annotations
+//	@produce        json
+//	@param          id              path    string  true    "Book ID"
+//	@param          annotationID    path    string  true    "Annotation ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/annotations/{annotationID} [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	userID, bookID, id, err := params(r)
+	if err != nil {
+		return err
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).Delete(bookID, id, userID)
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+	return nil
+}
+
+// Export godoc
+//
+//	@summary        Export annotations
+//	@description    Download the signed-in user's annotations in a book as JSON or as a Markdown document
+//	@tags           annotations
+//	@produce        json
+//	@produce        text/markdown
+//	@param          id      path    string  true    "Book ID"
+//	@param          format  query   string  false   "json (default) or markdown"
+//	@param          compress    query   string  false   "gzip or zstd, to download the file compressed"
+//	@success        200 {array}     DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/annotations/export [get]
+func (api *API) Export(w http.ResponseWriter, r *http.Request) error {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatMarkdown {
+		return e.RespInvalidExportFormat
+	}
+
+	userID, bookID, title, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	annotations, err := api.repository.WithContext(r.Context()).List(bookID, userID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	filename := "annotations-" + idcodec.Encode(bookID)
+	if format == FormatMarkdown {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename + ".md"}))
+		writeMarkdown(w, title, annotations)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename + ".json"}))
+	if err := json.NewEncoder(w).Encode(annotations.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// book returns the signed-in user and the id and title of the book in the
+// URL, answering 404 when there is no such book.
+func (api *API) book(r *http.Request) (uuid.UUID, uuid.UUID, string, error) {
+	userID, err := caller(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", err
+	}
+
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", e.RespInvalidURLParamID
+	}
+
+	title, err := api.repository.WithContext(r.Context()).BookTitle(bookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, uuid.Nil, "", e.RespNotFound
+		}
+
+		return uuid.Nil, uuid.Nil, "", e.RespDBDataAccessFailure
+	}
+	return userID, bookID, title, nil
+}
+
+// params returns the signed-in user and the book and annotation in the URL.
+func params(r *http.Request) (uuid.UUID, uuid.UUID, uuid.UUID, error) {
+	userID, err := caller(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, uuid.Nil, err
+	}
+
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, uuid.Nil, e.RespInvalidURLParamID
+	}
+
+	id, err := idcodec.Decode(chi.URLParam(r, "annotationID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, uuid.Nil, e.RespInvalidURLParamID
+	}
+	return userID, bookID, id, nil
+}
+
+// caller returns the signed-in user, answering 401 without one.
+// Annotations belong to users, so service accounts have none.
+func caller(r *http.Request) (uuid.UUID, error) {
+	id, ok := user.From(r.Context())
+	if !ok {
+		return uuid.Nil, e.RespAuthenticationRequired
+	}
+	return id, nil
+}
+
+// moderate checks the text and note of an annotation, answering 422 when
+// they must not be stored, and reports whether to flag it.
+func (api *API) moderate(r *http.Request, userID uuid.UUID, form *Form) (bool, error) {
+	flagged, err := api.moderator.Moderate(r.Context(), moderation.NewSubmission(r, userID.String(), form.Text+"\n"+form.Note))
+	if errors.Is(err, moderation.ErrRejected) {
+		return false, e.RespContentRejected
+	}
+	return flagged, err
+}
+
+func (api *API) form(r *http.Request) (*Form, error) {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return nil, e.RespJSONDecodeFailure
+	}
+	sanitizer.Struct(form)
+
+	if err := api.validator.Struct(form); err != nil {
+		return nil, e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+	return form, nil
+}
@@ -0,0 +1,131 @@
+package annotation_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/annotation"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	mockDB "hello/mock/db"
+	"hello/moderation"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Annotations(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &annotation.Annotation{})
+
+	dune := &book.Book{ID: uuid.New(), Title: "Dune"}
+	testUtil.NoError(t, db.Create(dune).Error)
+
+	api := annotation.New(db, validatorUtil.New(), moderation.NewModerator(moderation.NewFilter(moderation.ActionFlag, "darn"), nil))
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Get("/books/{id}/annotations", e.Handle(api.List))
+	r.Post("/books/{id}/annotations", e.Handle(api.Create))
+	r.Get("/books/{id}/annotations/export", e.Handle(api.Export))
+	r.Get("/books/{id}/annotations/{annotationID}", e.Handle(api.Read))
+	r.Put("/books/{id}/annotations/{annotationID}", e.Handle(api.Update))
+	r.Delete("/books/{id}/annotations/{annotationID}", e.Handle(api.Delete))
+
+	alice, bob := uuid.NewString(), uuid.NewString()
+	base := "/books/" + dune.ID.String() + "/annotations"
+	serve := func(method, target, body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(user.Header, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	create := func(body string) *annotation.DTO {
+		w := serve(http.MethodPost, base, body, alice)
+		testUtil.Equal(t, http.StatusCreated, w.Code)
+		dto := &annotation.DTO{}
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), dto))
+		return dto
+	}
+	list := func(target, userID string) []*annotation.DTO {
+		var dtos []*annotation.DTO
+		testUtil.NoError(t, json.Unmarshal(serve(http.MethodGet, target, "", userID).Body.Bytes(), &dtos))
+		return dtos
+	}
+
+	testUtil.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, base, "", "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/books/"+uuid.NewString()+"/annotations", "", alice).Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, base, `{"position": "p. 2"}`, alice).Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, base, `{"position": "p. 2", "note": "x", "color": "red"}`, alice).Code)
+
+	private := create(`{"position": "p. 9", "text": "Fear is the mind-killer.", "note": "Litany"}`)
+	testUtil.Equal(t, annotation.DefaultColor, private.Color)
+	testUtil.Equal(t, false, private.Shared)
+	shared := create(`{"position": "p. 1", "text": "A beginning is the time", "color": "blue", "shared": true}`)
+
+	mine := list(base, alice)
+	testUtil.Equal(t, 2, len(mine))
+	testUtil.Equal(t, shared.ID, mine[0].ID)
+
+	// Bob sees only what Alice shared, and cannot change it.
+	testUtil.Equal(t, 0, len(list(base, bob)))
+	others := list(base+"?shared=true", bob)
+	testUtil.Equal(t, 1, len(others))
+	testUtil.Equal(t, shared.ID, others[0].ID)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodGet, base+"/"+shared.ID, "", bob).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, base+"/"+private.ID, "", bob).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPut, base+"/"+shared.ID, `{"position": "p. 1", "note": "mine"}`, bob).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, base+"/"+shared.ID, "", bob).Code)
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, base+"/"+shared.ID, `{"position": "p. 1", "text": "A beginning is the time", "color": "green"}`, alice).Code)
+	testUtil.Equal(t, 0, len(list(base+"?shared=true", bob)))
+
+	w := serve(http.MethodGet, base+"/export?format=markdown", "", alice)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+	testUtil.Equal(t, "# Dune\n\n> A beginning is the time\n\n*p. 1, green*\n\n> Fear is the mind-killer.\n\nLitany\n\n*p. 9, yellow*\n", w.Body.String())
+	testUtil.Equal(t, 2, len(list(base+"/export", alice)))
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodGet, base+"/export?format=pdf", "", alice).Code)
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, base+"/"+private.ID, "", alice).Code)
+	testUtil.Equal(t, 1, len(list(base, alice)))
+
+	// Notes matching the deny-list are flagged until they are cleaned up.
+	flagged := create(`{"position": "p. 3", "note": "Darn, what a twist"}`)
+	testUtil.Equal(t, true, flagged.Flagged)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, base+"/"+flagged.ID, `{"position": "p. 3", "note": "What a twist"}`, alice).Code)
+	w = serve(http.MethodGet, base+"/"+flagged.ID, "", alice)
+	testUtil.Equal(t, false, strings.Contains(w.Body.String(), `"flagged"`))
+}
+
+func TestAPI_RejectedAnnotation(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &annotation.Annotation{})
+
+	dune := &book.Book{ID: uuid.New(), Title: "Dune"}
+	testUtil.NoError(t, db.Create(dune).Error)
+
+	api := annotation.New(db, validatorUtil.New(), moderation.NewModerator(moderation.NewFilter(moderation.ActionReject, "darn"), nil))
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Post("/books/{id}/annotations", e.Handle(api.Create))
+
+	req := httptest.NewRequest(http.MethodPost, "/books/"+dune.ID.String()+"/annotations", strings.NewReader(`{"position": "p. 3", "note": "Darn, what a twist"}`))
+	req.Header.Set(user.Header, uuid.NewString())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "content_rejected"))
+
+	var n int64
+	testUtil.NoError(t, db.Model(&annotation.Annotation{}).Count(&n).Error)
+	testUtil.Equal(t, int64(0), n)
+}
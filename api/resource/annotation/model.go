@@ -0,0 +1,95 @@
+package annotation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/idcodec"
+)
+
+// DefaultColor is the color of highlights created without one.
+const DefaultColor = "yellow"
+
+type DTO struct {
+	ID        string    `json:"id"`
+	BookID    string    `json:"book_id"`
+	Owner     string    `json:"owner"`
+	Position  string    `json:"position"`
+	Text      string    `json:"text,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	Color     string    `json:"color"`
+	Shared    bool      `json:"shared"`
+	Flagged   bool      `json:"flagged,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Form is a highlight of Text, a note, or both, at Position. Position is
+// opaque to the API, e.g. an EPUB CFI or a page number, and annotations are
+// sorted by it.
+type Form struct {
+	Position string `json:"position" validate:"required,max=255" sanitize:"singleline"`
+	Text     string `json:"text" validate:"required_without=Note,max=5000"`
+	Note     string `json:"note" validate:"required_without=Text,max=5000"`
+	Color    string `json:"color" validate:"omitempty,oneof=yellow green blue pink purple"`
+	Shared   bool   `json:"shared"`
+}
+
+// Annotation is a user's highlight or note in a book. Annotations are
+// private to their owner unless shared, when every reader of the book can
+// see them. Flagged annotations matched the content filter or were scored
+// as spam or abuse, and await moderation.
+type Annotation struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	BookID    uuid.UUID
+	UserID    uuid.UUID
+	Position  string
+	Text      string
+	Note      string
+	Color     string
+	Shared    bool
+	Flagged   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Annotations []*Annotation
+
+func (a *Annotation) ToDto() *DTO {
+	return &DTO{
+		ID:        idcodec.Encode(a.ID),
+		BookID:    idcodec.Encode(a.BookID),
+		Owner:     a.UserID.String(),
+		Position:  a.Position,
+		Text:      a.Text,
+		Note:      a.Note,
+		Color:     a.Color,
+		Shared:    a.Shared,
+		Flagged:   a.Flagged,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+}
+
+func (as Annotations) ToDto() []*DTO {
+	dtos := make([]*DTO, len(as))
+	for i, v := range as {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
+
+func (f *Form) ToModel() *Annotation {
+	color := f.Color
+	if color == "" {
+		color = DefaultColor
+	}
+	return &Annotation{
+		Position: f.Position,
+		Text:     f.Text,
+		Note:     f.Note,
+		Color:    color,
+		Shared:   f.Shared,
+	}
+}
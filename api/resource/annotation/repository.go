@@ -0,0 +1,85 @@
+package annotation
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// BookTitle returns the title of a book that is not deleted, or
+// gorm.ErrRecordNotFound.
+func (r *Repository) BookTitle(id uuid.UUID) (string, error) {
+	var titles []string
+	if err := r.db.Table("books").Where("id = ? AND deleted_at IS NULL", id).Pluck("title", &titles).Error; err != nil {
+		return "", err
+	}
+	if len(titles) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	return titles[0], nil
+}
+
+// List returns a user's annotations in a book in reading order.
+func (r *Repository) List(bookID, userID uuid.UUID) (Annotations, error) {
+	return r.find(r.db.Where("book_id = ? AND user_id = ?", bookID, userID))
+}
+
+// Shared returns the annotations other users shared in a book in reading
+// order.
+func (r *Repository) Shared(bookID, userID uuid.UUID) (Annotations, error) {
+	return r.find(r.db.Where("book_id = ? AND user_id <> ? AND shared", bookID, userID))
+}
+
+func (r *Repository) find(db *gorm.DB) (Annotations, error) {
+	annotations := make([]*Annotation, 0)
+	if err := db.Order("position, created_at").Find(&annotations).Error; err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+func (r *Repository) Create(a *Annotation) (*Annotation, error) {
+	if err := r.db.Create(a).Error; err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *Repository) Read(bookID, id uuid.UUID) (*Annotation, error) {
+	a := &Annotation{}
+	if err := r.db.Where("book_id = ? AND id = ?", bookID, id).First(a).Error; err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *Repository) Update(a *Annotation) (int64, error) {
+	result := r.db.Model(&Annotation{}).
+		Select("Position", "Text", "Note", "Color", "Shared", "Flagged", "UpdatedAt").
+		Where("id = ? AND book_id = ? AND user_id = ?", a.ID, a.BookID, a.UserID).
+		Updates(a)
+	return result.RowsAffected, result.Error
+}
+
+func (r *Repository) Delete(bookID, id, userID uuid.UUID) (int64, error) {
+	result := r.db.Where("id = ? AND book_id = ? AND user_id = ?", id, bookID, userID).Delete(&Annotation{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,202 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/audit"
+)
+
+// Header carries the key of a request.
+const Header = "X-API-Key"
+
+// Audited actions.
+const (
+	ActionCreated = "api_key.created"
+	ActionRevoked = "api_key.revoked"
+)
+
+const (
+	// keyPrefix marks the keys issued here, which read "ak_<secret>".
+	keyPrefix = "ak_"
+	// shownLength is how much of a key is kept in clear to recognise it.
+	shownLength = len(keyPrefix) + 8
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+}
+
+func New(db *gorm.DB, v *validator.Validate) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+	}
+}
+
+// List godoc
+//
+//	@summary        List API keys
+//	@description    List the tenant's API keys, revoked ones included, without the keys themselves
+//	@tags           admin
+//	@produce        json
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/api-keys [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	keys, err := api.repository.WithContext(r.Context()).List(tenant.From(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(keys.ToDto())
+}
+
+// Create godoc
+//
+//	@summary        Create API key
+//	@description    Create an API key with the given scopes. The response carries the key, which is not shown again.
+//	@tags           admin
+//	@accept         json
+//	@produce        json
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          body        body    Form    true    "API key form"
+//	@success        201 {object}    CreatedDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/api-keys [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return err
+	}
+
+	secret, err := newKey()
+	if err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	k := form.ToModel()
+	k.ID = uuid.New()
+	k.TenantID = tenant.From(r.Context())
+	k.Prefix = secret[:shownLength]
+	k.KeyHash = hashKey(secret)
+	k.CreatedAt = time.Now()
+
+	if err := api.repository.WithContext(r.Context()).Create(k); err != nil {
+		return err
+	}
+
+	if err := audit.Record(r, ActionCreated, k.ID.String(), audit.Details{"name": k.Name, "prefix": k.Prefix, "scopes": k.Scopes}); err != nil {
+		return e.RespAuditFailure
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&CreatedDTO{DTO: k.ToDto(), Key: secret})
+	return nil
+}
+
+// Revoke godoc
+//
+//	@summary        Revoke API key
+//	@description    Revoke an API key. Requests made with it are rejected from then on.
+//	@tags           admin
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          id          path    string  true    "API key ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/api-keys/{id} [delete]
+func (api *API) Revoke(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).Revoke(tenant.From(r.Context()), id, time.Now())
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	if err := audit.Record(r, ActionRevoked, id.String(), nil); err != nil {
+		return e.RespAuditFailure
+	}
+	return nil
+}
+
+// Middleware authenticates requests bearing a key issued here in X-API-Key,
+// acting in the key's tenant with its scopes in place of those of the
+// headers, and rejects with 401 those whose key is unknown, revoked or
+// expired. Other requests, including those with keys issued by the gateway,
+// pass untouched.
+func (api *API) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get(Header)
+		if !strings.HasPrefix(secret, keyPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		k, err := api.repository.WithContext(r.Context()).Authenticate(hashKey(secret), ip, time.Now())
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				e.Unauthorized(w, e.RespInvalidCredential)
+				return
+			}
+
+			e.ServerError(w, e.RespDBDataAccessFailure)
+			return
+		}
+
+		ctx := tenant.With(r.Context(), k.TenantID)
+		ctx = scope.With(ctx, k.ScopeList())
+		ctx = user.WithAPIKey(ctx, k.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package apikey_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+	"hello/api/resource/apikey"
+	e "hello/api/resource/common/err"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &apikey.Key{})
+
+	api := apikey.New(db, validatorUtil.New())
+
+	r := chi.NewRouter()
+	r.Use(tenant.Middleware, scope.Middleware, api.Middleware)
+	r.Use(e.Middleware(validatorUtil.Mapper))
+	r.Get("/admin/api-keys", e.Handle(api.List))
+	r.Post("/admin/api-keys", e.Handle(api.Create))
+	r.Delete("/admin/api-keys/{id}", e.Handle(api.Revoke))
+	r.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		_, ok := user.APIKey(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(tenant.From(r.Context()) + " " + strings.Join(scope.From(r.Context()), ",")))
+	})
+
+	serve := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(tenant.Header, "acme")
+		if key != "" {
+			req.Header.Set(apikey.Header, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/admin/api-keys", "", `{"name": "billing", "scopes": ["books:read"]}`)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	var created apikey.CreatedDTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	testUtil.Equal(t, true, strings.HasPrefix(created.Key, created.Prefix))
+
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, "/admin/api-keys", "", `{"scopes": ["a b"]}`).Code)
+
+	w = serve(http.MethodGet, "/whoami", created.Key, "")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "acme books:read", w.Body.String())
+
+	// Keys are never listed, only their prefix and last use.
+	w = serve(http.MethodGet, "/admin/api-keys", "", "")
+	testUtil.Equal(t, false, strings.Contains(w.Body.String(), created.Key))
+	var keys []*apikey.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	testUtil.Equal(t, 1, len(keys))
+	testUtil.Equal(t, true, keys[0].LastUsedAt != nil)
+	testUtil.Equal(t, "192.0.2.1", keys[0].LastUsedIP)
+
+	tests := []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"unknown", "ak_nope", http.StatusUnauthorized},
+		{"issued by the gateway", "gateway-key", http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		testUtil.Equal(t, tc.status, serve(http.MethodGet, "/whoami", tc.key, "").Code)
+	}
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, "/admin/api-keys/"+created.ID, "", "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/api-keys/"+created.ID, "", "").Code)
+	testUtil.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/whoami", created.Key, "").Code)
+}
@@ -0,0 +1,95 @@
+package apikey
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DTO struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	// LastUsedAt and LastUsedIP are updated at most once a minute.
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedDTO carries a new key. It is only ever shown once.
+type CreatedDTO struct {
+	*DTO
+	Key string `json:"key"`
+}
+
+type Form struct {
+	Name      string     `json:"name" validate:"required,max=255"`
+	Scopes    []string   `json:"scopes" validate:"omitempty,dive,required,max=64,excludesall=0x2C0x20"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Key is an API key a server-to-server client sends in the X-API-Key header
+// to act within a tenant with fixed scopes. Only its SHA-256 is stored,
+// along with its first characters so that it can be recognised in lists.
+// Revoked keys are kept, so that the audit log can still name them.
+type Key struct {
+	ID       uuid.UUID `gorm:"primarykey"`
+	TenantID string
+	Name     string
+	Prefix   string
+	KeyHash  string
+	// Scopes is comma-separated, like the X-Scopes header.
+	Scopes     string
+	LastUsedAt *time.Time
+	LastUsedIP string
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (Key) TableName() string {
+	return "api_keys"
+}
+
+type Keys []*Key
+
+func (k *Key) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *Key) ToDto() *DTO {
+	return &DTO{
+		ID:         k.ID.String(),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.ScopeList(),
+		LastUsedAt: k.LastUsedAt,
+		LastUsedIP: k.LastUsedIP,
+		ExpiresAt:  k.ExpiresAt,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+func (ks Keys) ToDto() []*DTO {
+	dtos := make([]*DTO, len(ks))
+	for i, k := range ks {
+		dtos[i] = k.ToDto()
+	}
+	return dtos
+}
+
+func (f *Form) ToModel() *Key {
+	return &Key{
+		Name:      f.Name,
+		Scopes:    strings.Join(f.Scopes, ","),
+		ExpiresAt: f.ExpiresAt,
+	}
+}
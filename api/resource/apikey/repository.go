@@ -0,0 +1,67 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often a key's last use is written.
+const lastUsedResolution = time.Minute
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) List(tenantID string) (Keys, error) {
+	keys := make([]*Key, 0)
+	if err := r.db.Where("tenant_id = ?", tenantID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *Repository) Create(k *Key) error {
+	return r.db.Create(k).Error
+}
+
+// Revoke revokes a key of the tenant that is not revoked yet. It returns the
+// number of keys revoked.
+func (r *Repository) Revoke(tenantID string, id uuid.UUID, now time.Time) (int64, error) {
+	result := r.db.Model(&Key{}).
+		Where("tenant_id = ? AND id = ? AND revoked_at IS NULL", tenantID, id).
+		Update("revoked_at", now)
+	return result.RowsAffected, result.Error
+}
+
+// Authenticate returns the unrevoked, unexpired key of keyHash, or
+// gorm.ErrRecordNotFound, and records its use from ip.
+func (r *Repository) Authenticate(keyHash, ip string, now time.Time) (*Key, error) {
+	k := &Key{}
+	if err := r.db.Where("key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", keyHash, now).
+		First(k).Error; err != nil {
+		return nil, err
+	}
+
+	if k.LastUsedAt == nil || k.LastUsedAt.Before(now.Add(-lastUsedResolution)) {
+		if err := r.db.Model(k).Updates(map[string]any{"last_used_at": now, "last_used_ip": ip}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
@@ -0,0 +1,410 @@
+package attachment
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/resource/blob"
+	e "hello/api/resource/common/err"
+	"hello/config"
+	"hello/idcodec"
+	"hello/signedurl"
+	"hello/storage"
+)
+
+const (
+	formField      = "file"
+	sniffLen       = 3072
+	maxFilenameLen = 255
+
+	// scanRetryAfter is suggested to clients downloading a pending attachment.
+	scanRetryAfter = "5"
+)
+
+var errTooLarge = errors.New("attachment: file too large")
+
+type API struct {
+	repository   *Repository
+	store        storage.Store
+	blobs        *blob.Store
+	scanWorker   *ScanWorker
+	signer       *signedurl.Signer
+	maxSize      int64
+	allowedTypes []string
+}
+
+func New(db *gorm.DB, store storage.Store, blobs *blob.Store, scanWorker *ScanWorker, signer *signedurl.Signer, conf *config.ConfAttachment) *API {
+	return &API{
+		repository:   NewRepository(db),
+		store:        store,
+		blobs:        blobs,
+		scanWorker:   scanWorker,
+		signer:       signer,
+		maxSize:      conf.MaxSize,
+		allowedTypes: conf.AllowedTypes,
+	}
+}
+
+// List godoc
+//
+//	@summary        List attachments
+//	@description    List the metadata of a book's attachments
+//	@tags           attachments
+//	@produce        json
+//	@param          id	path        string  true    "Book ID"
+//	@success        200 {array}     DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	bookID, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	attachments, err := api.repository.WithContext(r.Context()).List(bookID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(attachments.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Create godoc
+//
+//	@summary        Upload attachment
+//	@description    Upload a file for a book as multipart/form-data in the "file" field. The
+//	@description    attachment stays pending until it has been scanned for viruses.
+//	@tags           attachments
+//	@accept         mpfd
+//	@produce        json
+//	@param          id      path        string  true    "Book ID"
+//	@param          file    formData    file    true    "File"
+//	@success        202 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        413 {object}    err.Problem
+//	@failure        415 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	bookID, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	part, err := filePart(r)
+	if err != nil {
+		return e.RespInvalidUpload
+	}
+	defer part.Close()
+
+	body, contentType, err := sniff(part)
+	if err != nil {
+		return e.RespInvalidUpload
+	}
+	if !allowed(api.allowedTypes, contentType) {
+		return e.RespUnsupportedMediaType
+	}
+
+	a := &Attachment{
+		ID:          uuid.New(),
+		BookID:      bookID,
+		Filename:    filename(part.FileName()),
+		ContentType: contentType.String(),
+		Status:      StatusPending,
+	}
+
+	b, err := api.blobs.Put(r.Context(), &limitedReader{r: body, n: api.maxSize})
+	if err != nil {
+		if errors.Is(err, errTooLarge) {
+			return e.RespFileTooLarge
+		}
+		return e.RespStorageFailure
+	}
+	a.setBlob(b)
+
+	if _, err := api.repository.WithContext(r.Context()).Create(a); err != nil {
+		api.blobs.Release(r.Context(), b.Hash)
+		return e.RespDBDataInsertFailure
+	}
+
+	api.scanWorker.Enqueue(a)
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(a.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Read godoc
+//
+//	@summary        Download attachment
+//	@description    Download an attachment's content. Pending attachments answer 409 until
+//	@description    scanned, infected ones 403.
+//	@tags           attachments
+//	@produce        octet-stream
+//	@param          id              path        string  true    "Book ID"
+//	@param          attachmentID    path        string  true    "Attachment ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        403 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments/{attachmentID} [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	a, err := api.attachment(r)
+	if err != nil {
+		return err
+	}
+	if err := downloadable(w, a); err != nil {
+		return err
+	}
+
+	content, err := api.store.Open(r.Context(), a.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespStorageFailure
+	}
+	defer content.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", a.ContentType)
+	h.Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("attachment %s download: %s", a.ID, err)
+	}
+	return nil
+}
+
+// URL godoc
+//
+//	@summary        Attachment download URL
+//	@description    Issue a short-lived signed URL for downloading an attachment
+//	@tags           attachments
+//	@produce        json
+//	@param          id              path        string  true    "Book ID"
+//	@param          attachmentID    path        string  true    "Attachment ID"
+//	@success        200 {object}    signedurl.DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        403 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments/{attachmentID}/url [get]
+func (api *API) URL(w http.ResponseWriter, r *http.Request) error {
+	a, err := api.attachment(r)
+	if err != nil {
+		return err
+	}
+	if err := downloadable(w, a); err != nil {
+		return err
+	}
+
+	u, expires, err := api.signer.URL(r.Context(), api.store, a.StorageKey, strings.TrimSuffix(r.URL.Path, "/url"))
+	if err != nil {
+		return e.RespStorageFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(&signedurl.DTO{URL: u, ExpiresAt: expires}); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Delete attachment
+//	@description    Delete an attachment and its content
+//	@tags           attachments
+//	@param          id              path        string  true    "Book ID"
+//	@param          attachmentID    path        string  true    "Attachment ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments/{attachmentID} [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	a, err := api.attachment(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.repository.WithContext(r.Context()).Delete(a.BookID, a.ID); err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+
+	if a.BlobHash != "" {
+		api.blobs.Release(r.Context(), a.BlobHash)
+		return nil
+	}
+	discard(r.Context(), api.store, a.StorageKey)
+	return nil
+}
+
+func (api *API) book(r *http.Request) (uuid.UUID, error) {
+	return requireBook(r, api.repository)
+}
+
+// requireBook parses the book id from the URL and checks that the book
+// exists, returning the problem to answer otherwise.
+func requireBook(r *http.Request, repository *Repository) (uuid.UUID, error) {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, e.RespInvalidURLParamID
+	}
+
+	exists, err := repository.BookExists(id)
+	if err != nil {
+		return uuid.Nil, e.RespDBDataAccessFailure
+	}
+	if !exists {
+		return uuid.Nil, e.RespNotFound
+	}
+
+	return id, nil
+}
+
+// attachment loads the attachment addressed by the URL, returning the
+// problem to answer when it cannot.
+func (api *API) attachment(r *http.Request) (*Attachment, error) {
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, e.RespInvalidURLParamID
+	}
+
+	id, err := idcodec.Decode(chi.URLParam(r, "attachmentID"))
+	if err != nil {
+		return nil, e.RespInvalidURLParamID
+	}
+
+	a, err := api.repository.WithContext(r.Context()).Read(bookID, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, e.RespNotFound
+		}
+
+		return nil, e.RespDBDataAccessFailure
+	}
+
+	return a, nil
+}
+
+// downloadable returns RespScanPending, with Retry-After set, for attachments
+// still waiting for their scan and RespInfectedFile for infected ones.
+func downloadable(w http.ResponseWriter, a *Attachment) error {
+	switch a.Status {
+	case StatusPending:
+		w.Header().Set("Retry-After", scanRetryAfter)
+		return e.RespScanPending
+	case StatusInfected:
+		return e.RespInfectedFile
+	}
+	return nil
+}
+
+// sniff detects the media type of r from its first bytes. The returned reader
+// still yields the whole content.
+func sniff(r io.Reader) (io.Reader, *mimetype.MIME, error) {
+	body := bufio.NewReaderSize(r, sniffLen)
+	head, err := body.Peek(sniffLen)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, nil, err
+	}
+	return body, mimetype.Detect(head), nil
+}
+
+func allowed(types []string, t *mimetype.MIME) bool {
+	for _, allowed := range types {
+		if t.Is(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// discard deletes a stored object, logging rather than failing the request
+// when it cannot.
+func discard(ctx context.Context, store storage.Store, key string) {
+	if err := store.Delete(ctx, key); err != nil {
+		log.Printf("attachment storage delete %s: %s", key, err)
+	}
+}
+
+// filePart returns the first multipart part carrying the file form field.
+func filePart(r *http.Request) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == formField && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// filename keeps the base name of a client-supplied file name. Overlong
+// names keep their tail so the extension survives.
+func filename(name string) string {
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." {
+		return "attachment"
+	}
+	for len(name) > maxFilenameLen || !utf8.RuneStart(name[0]) {
+		name = name[1:]
+	}
+	return name
+}
+
+// limitedReader fails with errTooLarge once more than n bytes are read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,92 @@
+package attachment_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/resource/attachment"
+	"hello/api/resource/blob"
+	e "hello/api/resource/common/err"
+	"hello/config"
+	mockDB "hello/mock/db"
+	"hello/scan"
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+func upload(t *testing.T, bookID uuid.UUID, name string, content []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	testUtil.NoError(t, err)
+	_, err = fw.Write(content)
+	testUtil.NoError(t, err)
+	testUtil.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/books/"+bookID.String()+"/attachments", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestAPI_Create(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content []byte
+		status  int
+	}{
+		{"pdf", []byte("%PDF-1.4\n%sample chapter\n"), http.StatusAccepted},
+		{"text", []byte("plain notes"), http.StatusAccepted},
+		{"executable", append([]byte("MZ\x90\x00"), make([]byte, 64)...), http.StatusUnsupportedMediaType},
+		{"too large", []byte(strings.Repeat("a", 65)), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := mockDB.NewMockDB()
+			testUtil.NoError(t, err)
+
+			store, err := storage.NewFS(t.TempDir())
+			testUtil.NoError(t, err)
+
+			bookID := uuid.New()
+			mock.ExpectQuery(`^SELECT count\(\*\) FROM "books"`).
+				WithArgs(bookID).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			if tt.status == http.StatusAccepted {
+				mock.ExpectBegin()
+				mock.ExpectExec(`^INSERT INTO "blobs" .+ ON CONFLICT`).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(`^INSERT INTO "attachments"`).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			}
+
+			blobs := blob.NewStore(db, store)
+			api := attachment.New(db, store, blobs, attachment.NewScanWorker(db, store, blobs, scan.Nop), nil, &config.ConfAttachment{
+				MaxSize:      64,
+				AllowedTypes: []string{"application/pdf", "text/plain"},
+			})
+			router := chi.NewRouter()
+			router.Post("/books/{id}/attachments", e.Handle(api.Create))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, upload(t, bookID, "../chapter.pdf", tt.content))
+			testUtil.Equal(t, tt.status, w.Code)
+			testUtil.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package attachment
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/resource/blob"
+	"hello/idcodec"
+)
+
+// Attachments are pending until scanned, and only clean ones can be
+// downloaded. Infected content is moved to quarantine.
+const (
+	StatusPending  = "pending"
+	StatusClean    = "clean"
+	StatusInfected = "infected"
+)
+
+type DTO struct {
+	ID          string    `json:"id"`
+	BookID      string    `json:"book_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Attachment struct {
+	ID          uuid.UUID `gorm:"primarykey"`
+	BookID      uuid.UUID
+	Filename    string
+	ContentType string
+	Size        int64
+	StorageKey  string
+	// BlobHash is the deduplicated blob holding the content. It is empty for
+	// quarantined content, which is stored under the attachment's own key.
+	BlobHash string
+	Status   string
+	// ScanSignature names the threat found in an infected attachment.
+	ScanSignature string
+	CreatedAt     time.Time
+}
+
+type Attachments []*Attachment
+
+func (a *Attachment) ToDto() *DTO {
+	return &DTO{
+		ID:          idcodec.Encode(a.ID),
+		BookID:      idcodec.Encode(a.BookID),
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Status:      a.Status,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+func (as Attachments) ToDto() []*DTO {
+	dtos := make([]*DTO, len(as))
+	for i, v := range as {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
+
+func (a *Attachment) setBlob(b *blob.Blob) {
+	a.BlobHash = b.Hash
+	a.StorageKey = blob.Key(b.Hash)
+	a.Size = b.Size
+}
+
+// quarantineKey is where infected content is kept, apart from the shared
+// blobs.
+func (a *Attachment) quarantineKey() string {
+	return "quarantine/attachments/" + a.BookID.String() + "/" + a.ID.String()
+}
+
+// Upload tracks a resumable upload. Each PATCH is stored as a separate chunk
+// object; the chunks are joined into the attachment once Received reaches
+// Size. The finished attachment keeps the upload's ID.
+type Upload struct {
+	ID          uuid.UUID `gorm:"primarykey"`
+	BookID      uuid.UUID
+	Filename    string
+	ContentType string
+	Size        int64
+	Received    int64
+	Chunks      int
+	// ChunkKeys are the storage keys of the chunks, in order, separated by
+	// spaces. Each PATCH writes a key of its own, so that the loser of two
+	// PATCHes racing at the same offset only discards its own chunk.
+	ChunkKeys string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type Uploads []*Upload
+
+// newChunkKey returns a key for the next chunk, unique to the PATCH.
+func (u *Upload) newChunkKey() string {
+	return fmt.Sprintf("uploads/%s/%06d-%s", u.ID, u.Chunks, uuid.NewString())
+}
+
+// chunkKeys returns the keys of the chunks received. Uploads started before
+// the keys were recorded have their chunks under numbered keys.
+func (u *Upload) chunkKeys() []string {
+	if u.ChunkKeys != "" {
+		return strings.Fields(u.ChunkKeys)
+	}
+	keys := make([]string, u.Chunks)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("uploads/%s/%06d", u.ID, i)
+	}
+	return keys
+}
@@ -0,0 +1,133 @@
+package attachment
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/resource/book"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// BookExists reports whether a book that is not deleted has the id.
+func (r *Repository) BookExists(id uuid.UUID) (bool, error) {
+	var n int64
+	if err := r.db.Model(&book.Book{}).Where("id = ?", id).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *Repository) List(bookID uuid.UUID) (Attachments, error) {
+	attachments := make([]*Attachment, 0)
+	if err := r.db.Where("book_id = ?", bookID).Order("created_at").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *Repository) Create(a *Attachment) (*Attachment, error) {
+	if err := r.db.Create(a).Error; err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *Repository) Read(bookID, id uuid.UUID) (*Attachment, error) {
+	a := &Attachment{}
+	if err := r.db.Where("book_id = ? AND id = ?", bookID, id).First(a).Error; err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ListPending returns up to limit attachments still waiting for a scan,
+// oldest first.
+func (r *Repository) ListPending(limit int) (Attachments, error) {
+	attachments := make([]*Attachment, 0)
+	if err := r.db.Where("status = ?", StatusPending).Order("created_at").Limit(limit).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// UpdateScan records the scan outcome of an attachment.
+func (r *Repository) UpdateScan(a *Attachment) (int64, error) {
+	result := r.db.Model(a).
+		Select("Status", "ScanSignature", "StorageKey", "BlobHash").
+		Updates(a)
+
+	return result.RowsAffected, result.Error
+}
+
+func (r *Repository) CreateUpload(u *Upload) (*Upload, error) {
+	if err := r.db.Create(u).Error; err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *Repository) ReadUpload(bookID, id uuid.UUID) (*Upload, error) {
+	u := &Upload{}
+	if err := r.db.Where("book_id = ? AND id = ?", bookID, id).First(u).Error; err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// AdvanceUpload records a chunk appended at offset. It only applies while
+// the upload is still at offset, so that of two concurrent PATCHes one wins.
+func (r *Repository) AdvanceUpload(u *Upload, offset int64) (int64, error) {
+	result := r.db.Model(&Upload{}).
+		Where("id = ? AND received = ?", u.ID, offset).
+		Updates(map[string]any{"received": u.Received, "chunks": u.Chunks, "chunk_keys": u.ChunkKeys, "content_type": u.ContentType})
+
+	return result.RowsAffected, result.Error
+}
+
+// ListExpiredUploads returns up to limit uploads that expired before t.
+func (r *Repository) ListExpiredUploads(t time.Time, limit int) (Uploads, error) {
+	uploads := make([]*Upload, 0)
+	if err := r.db.Where("expires_at < ?", t).Order("expires_at").Limit(limit).Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+func (r *Repository) DeleteUpload(id uuid.UUID) (int64, error) {
+	result := r.db.Where("id = ?", id).Delete(&Upload{})
+	return result.RowsAffected, result.Error
+}
+
+// CompleteUpload replaces the finished upload with its attachment.
+func (r *Repository) CompleteUpload(a *Attachment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", a.ID).Delete(&Upload{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(a).Error
+	})
+}
+
+func (r *Repository) Delete(bookID, id uuid.UUID) (int64, error) {
+	result := r.db.Where("book_id = ? AND id = ?", bookID, id).Delete(&Attachment{})
+	return result.RowsAffected, result.Error
+}
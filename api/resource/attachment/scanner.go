@@ -0,0 +1,259 @@
+package attachment
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/resource/blob"
+	"hello/scan"
+	"hello/storage"
+	"hello/worker"
+)
+
+const (
+	scanQueueSize = 64
+	scanBatchSize = 50
+)
+
+type scanJob struct {
+	attachment *Attachment
+	queued     time.Time
+}
+
+// ScanWorker scans uploaded attachments in the background and records
+// whether they are clean or infected. Queued attachments are scanned by a
+// pool of workers, one unless resized.
+type ScanWorker struct {
+	repository *Repository
+	store      storage.Store
+	blobs      *blob.Store
+	scanner    scan.Scanner
+	queue      chan scanJob
+
+	mu   sync.Mutex
+	size int
+	// ctx is that of Run while it runs, and stops the running workers
+	// along with it.
+	ctx   context.Context
+	stops []chan struct{}
+	wg    sync.WaitGroup
+	// scanning holds the attachments being scanned, so that a sweep does
+	// not scan those a worker is already scanning.
+	scanning map[uuid.UUID]bool
+	busy     atomic.Int64
+	meter    worker.Meter
+}
+
+func NewScanWorker(db *gorm.DB, store storage.Store, blobs *blob.Store, scanner scan.Scanner) *ScanWorker {
+	return &ScanWorker{
+		repository: NewRepository(db),
+		store:      store,
+		blobs:      blobs,
+		scanner:    scanner,
+		queue:      make(chan scanJob, scanQueueSize),
+		size:       1,
+		scanning:   make(map[uuid.UUID]bool),
+	}
+}
+
+// Enqueue schedules a scan of a copy of a. When the queue is full the
+// attachment stays pending until the next sweep.
+func (sw *ScanWorker) Enqueue(a *Attachment) {
+	queued := *a
+	select {
+	case sw.queue <- scanJob{attachment: &queued, queued: time.Now()}:
+	default:
+	}
+}
+
+// Run starts the workers scanning queued attachments and returns once ctx
+// is done and they stopped. Every interval, and once at start, it also
+// sweeps attachments left pending by a failed scan or a restart.
+func (sw *ScanWorker) Run(ctx context.Context, interval time.Duration) {
+	sw.mu.Lock()
+	sw.ctx = ctx
+	for len(sw.stops) < sw.size {
+		sw.start()
+	}
+	sw.mu.Unlock()
+	defer func() {
+		sw.wg.Wait()
+		sw.mu.Lock()
+		sw.ctx, sw.stops = nil, nil
+		sw.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sw.sweep(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sw.sweep(ctx)
+		}
+	}
+}
+
+// start runs a worker until Run's context is done or Resize stops it. It is
+// called with sw.mu held.
+func (sw *ScanWorker) start() {
+	ctx, stop := sw.ctx, make(chan struct{})
+	sw.stops = append(sw.stops, stop)
+	sw.wg.Add(1)
+	go func() {
+		defer sw.wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case job := <-sw.queue:
+				sw.process(ctx, job)
+			}
+		}
+	}()
+}
+
+// Resize sets the number of workers. Workers beyond the new size stop
+// once done with their scan.
+func (sw *ScanWorker) Resize(n int) error {
+	if err := worker.Validate(n); err != nil {
+		return err
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.size = n
+	if sw.ctx == nil {
+		return nil
+	}
+	for len(sw.stops) < n {
+		sw.start()
+	}
+	for len(sw.stops) > n {
+		close(sw.stops[len(sw.stops)-1])
+		sw.stops = sw.stops[:len(sw.stops)-1]
+	}
+	return nil
+}
+
+// Stats tells how many attachments are being scanned and queued, and how
+// long their scans waited and took. Busy includes the sweep.
+func (sw *ScanWorker) Stats() worker.Stats {
+	sw.mu.Lock()
+	size := sw.size
+	sw.mu.Unlock()
+
+	s := worker.Stats{
+		Workers:  size,
+		Busy:     int(sw.busy.Load()),
+		Queued:   len(sw.queue),
+		Capacity: cap(sw.queue),
+	}
+	sw.meter.Fill(&s)
+	return s
+}
+
+// process scans the attachment of job unless it is being scanned already.
+func (sw *ScanWorker) process(ctx context.Context, job scanJob) {
+	a := job.attachment
+	sw.mu.Lock()
+	if sw.scanning[a.ID] {
+		sw.mu.Unlock()
+		return
+	}
+	sw.scanning[a.ID] = true
+	sw.mu.Unlock()
+	defer func() {
+		sw.mu.Lock()
+		delete(sw.scanning, a.ID)
+		sw.mu.Unlock()
+	}()
+
+	sw.busy.Add(1)
+	defer sw.busy.Add(-1)
+
+	start := time.Now()
+	if err := sw.Scan(ctx, a); err != nil {
+		log.Printf("attachment %s scan: %s", a.ID, err)
+	}
+	sw.meter.Observe(start.Sub(job.queued), time.Since(start))
+}
+
+func (sw *ScanWorker) sweep(ctx context.Context) {
+	pending, err := sw.repository.WithContext(ctx).ListPending(scanBatchSize)
+	if err != nil {
+		log.Printf("attachment scan sweep: %s", err)
+		return
+	}
+
+	for _, a := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		sw.process(ctx, scanJob{attachment: a, queued: time.Now()})
+	}
+}
+
+// Scan scans a and records the result. Infected content is copied to the
+// quarantine area of the store, out of reach of the download endpoint, and
+// the attachment's blob reference is dropped.
+func (sw *ScanWorker) Scan(ctx context.Context, a *Attachment) error {
+	content, err := sw.store.Open(ctx, a.StorageKey)
+	if err != nil {
+		return err
+	}
+	result, err := sw.scanner.Scan(ctx, content)
+	content.Close()
+	if err != nil {
+		return err
+	}
+
+	if !result.Infected {
+		a.Status = StatusClean
+		_, err := sw.repository.WithContext(ctx).UpdateScan(a)
+		return err
+	}
+
+	log.Printf("attachment %s for book %s infected: %s", a.ID, a.BookID, result.Signature)
+
+	original, hash := a.StorageKey, a.BlobHash
+	if err := sw.copy(ctx, original, a.quarantineKey()); err != nil {
+		return err
+	}
+
+	a.Status = StatusInfected
+	a.ScanSignature = result.Signature
+	a.StorageKey = a.quarantineKey()
+	a.BlobHash = ""
+	if _, err := sw.repository.WithContext(ctx).UpdateScan(a); err != nil {
+		return err
+	}
+
+	if hash != "" {
+		sw.blobs.Release(ctx, hash)
+		return nil
+	}
+	return sw.store.Delete(ctx, original)
+}
+
+func (sw *ScanWorker) copy(ctx context.Context, from, to string) error {
+	content, err := sw.store.Open(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	_, err = sw.store.Put(ctx, to, content)
+	return err
+}
@@ -0,0 +1,146 @@
+package attachment_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"hello/api/resource/attachment"
+	"hello/api/resource/blob"
+	mockDB "hello/mock/db"
+	"hello/scan"
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+func TestScanWorker_Scan(t *testing.T) {
+	t.Parallel()
+
+	eicar := scan.ScannerFunc(func(_ context.Context, r io.Reader) (scan.Result, error) {
+		b, _ := io.ReadAll(r)
+		return scan.Result{Infected: bytes.Contains(b, []byte("EICAR")), Signature: "Eicar-Signature"}, nil
+	})
+
+	hash := strings.Repeat("ab", 32)
+	bookID, id := uuid.New(), uuid.New()
+	quarantine := "quarantine/attachments/" + bookID.String() + "/" + id.String()
+
+	tests := []struct {
+		name    string
+		content string
+		status  string
+		key     string
+	}{
+		{"clean", "sample chapter", attachment.StatusClean, blob.Key(hash)},
+		{"infected", "EICAR test string", attachment.StatusInfected, quarantine},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db, mock, err := mockDB.NewMockDB()
+			testUtil.NoError(t, err)
+
+			store, err := storage.NewFS(t.TempDir())
+			testUtil.NoError(t, err)
+			_, err = store.Put(ctx, blob.Key(hash), strings.NewReader(tt.content))
+			testUtil.NoError(t, err)
+
+			a := &attachment.Attachment{
+				ID:         id,
+				BookID:     bookID,
+				StorageKey: blob.Key(hash),
+				BlobHash:   hash,
+				Status:     attachment.StatusPending,
+			}
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`^UPDATE "attachments" SET`).
+				WithArgs(tt.key, sqlmock.AnyArg(), tt.status, sqlmock.AnyArg(), id).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectCommit()
+			if tt.status == attachment.StatusInfected {
+				mock.ExpectBegin()
+				mock.ExpectExec(`^UPDATE "blobs" SET "ref_count"=ref_count - 1`).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			}
+
+			sw := attachment.NewScanWorker(db, store, blob.NewStore(db, store), eicar)
+			testUtil.NoError(t, sw.Scan(ctx, a))
+			testUtil.NoError(t, mock.ExpectationsWereMet())
+
+			rc, err := store.Open(ctx, tt.key)
+			testUtil.NoError(t, err)
+			rc.Close()
+
+			if tt.status == attachment.StatusInfected {
+				testUtil.Equal(t, "", a.BlobHash)
+			}
+		})
+	}
+}
+
+func TestScanWorker_Resize(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := mockDB.NewSQLite(t, &attachment.Attachment{})
+
+	store, err := storage.NewFS(t.TempDir())
+	testUtil.NoError(t, err)
+	_, err = store.Put(ctx, "chapter", strings.NewReader("sample chapter"))
+	testUtil.NoError(t, err)
+
+	started, release := make(chan struct{}, 4), make(chan struct{})
+	blocking := scan.ScannerFunc(func(_ context.Context, r io.Reader) (scan.Result, error) {
+		started <- struct{}{}
+		<-release
+		return scan.Result{}, nil
+	})
+	sw := attachment.NewScanWorker(db, store, blob.NewStore(db, store), blocking)
+	testUtil.NoError(t, sw.Resize(2))
+
+	stopped := make(chan struct{})
+	go func() {
+		sw.Run(ctx, time.Hour)
+		close(stopped)
+	}()
+	for i := 0; i < 3; i++ {
+		sw.Enqueue(&attachment.Attachment{ID: uuid.New(), StorageKey: "chapter", Status: attachment.StatusPending})
+	}
+
+	<-started
+	<-started
+	s := sw.Stats()
+	testUtil.Equal(t, 2, s.Workers)
+	testUtil.Equal(t, 2, s.Busy)
+	testUtil.Equal(t, 1, s.Queued)
+
+	// A new worker takes the attachment left queued.
+	testUtil.NoError(t, sw.Resize(3))
+	<-started
+	testUtil.Equal(t, 3, sw.Stats().Busy)
+	testUtil.Equal(t, true, sw.Resize(0) != nil)
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for sw.Stats().Processed < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("queued attachments not scanned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-stopped
+}
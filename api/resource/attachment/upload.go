@@ -0,0 +1,512 @@
+package attachment
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/resource/blob"
+	e "hello/api/resource/common/err"
+	"hello/config"
+	"hello/idcodec"
+	"hello/storage"
+)
+
+// Resumable uploads follow the core tus 1.0.0 protocol with the creation,
+// expiration and termination extensions (https://tus.io/protocols/resumable-upload).
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+
+	expiredUploadBatchSize = 100
+)
+
+var (
+	RespTusVersionMismatch = e.New(http.StatusPreconditionFailed, "tus_version_mismatch", "unsupported tus version")
+	RespInvalidOffset      = e.New(http.StatusConflict, "invalid_upload_offset", "upload offset mismatch")
+	RespUploadExpired      = e.New(http.StatusGone, "upload_expired", "upload expired")
+)
+
+// UploadAPI serves resumable uploads of large attachments.
+type UploadAPI struct {
+	repository   *Repository
+	store        storage.Store
+	blobs        *blob.Store
+	scanWorker   *ScanWorker
+	maxSize      int64
+	expiry       time.Duration
+	allowedTypes []string
+}
+
+func NewUploadAPI(db *gorm.DB, store storage.Store, blobs *blob.Store, scanWorker *ScanWorker, conf *config.ConfAttachment) *UploadAPI {
+	return &UploadAPI{
+		repository:   NewRepository(db),
+		store:        store,
+		blobs:        blobs,
+		scanWorker:   scanWorker,
+		maxSize:      conf.ResumableMaxSize,
+		expiry:       conf.UploadExpiry,
+		allowedTypes: conf.AllowedTypes,
+	}
+}
+
+// Options godoc
+//
+//	@summary        Resumable upload capabilities
+//	@description    Report the supported tus version, extensions and maximum size
+//	@tags           attachments
+//	@param          id      path    string  true    "Book ID"
+//	@success        204
+//	@router         /books/{id}/attachments/uploads [options]
+func (api *UploadAPI) Options(w http.ResponseWriter, r *http.Request) error {
+	h := w.Header()
+	h.Set("Tus-Resumable", tusVersion)
+	h.Set("Tus-Version", tusVersion)
+	h.Set("Tus-Extension", tusExtensions)
+	h.Set("Tus-Max-Size", strconv.FormatInt(api.maxSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Create godoc
+//
+//	@summary        Start resumable upload
+//	@description    Create a tus upload of Upload-Length bytes. Upload-Metadata may carry a
+//	@description    base64 "filename". The finished attachment takes the upload's id.
+//	@tags           attachments
+//	@param          id              path    string  true    "Book ID"
+//	@param          Upload-Length   header  int     true    "Total size in bytes"
+//	@param          Upload-Metadata header  string  false   "tus metadata"
+//	@success        201
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        412 {object}    err.Problem
+//	@failure        413 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments/uploads [post]
+func (api *UploadAPI) Create(w http.ResponseWriter, r *http.Request) error {
+	if err := tusRequest(w, r); err != nil {
+		return err
+	}
+
+	bookID, err := requireBook(r, api.repository)
+	if err != nil {
+		return err
+	}
+
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		return e.RespInvalidUpload
+	}
+	if size > api.maxSize {
+		return e.RespFileTooLarge
+	}
+
+	u := &Upload{
+		ID:        uuid.New(),
+		BookID:    bookID,
+		Filename:  filename(uploadMetadata(r.Header.Get("Upload-Metadata"))["filename"]),
+		Size:      size,
+		ExpiresAt: time.Now().Add(api.expiry),
+	}
+	if _, err := api.repository.WithContext(r.Context()).CreateUpload(u); err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+idcodec.Encode(u.ID))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// Head godoc
+//
+//	@summary        Resumable upload progress
+//	@description    Report the bytes received so far in Upload-Offset
+//	@tags           attachments
+//	@param          id          path    string  true    "Book ID"
+//	@param          uploadID    path    string  true    "Upload ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        410
+//	@failure        412 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments/uploads/{uploadID} [head]
+func (api *UploadAPI) Head(w http.ResponseWriter, r *http.Request) error {
+	if err := tusRequest(w, r); err != nil {
+		return err
+	}
+
+	bookID, id, err := uploadIDs(r)
+	if err != nil {
+		return err
+	}
+
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+
+	u, err := api.repository.WithContext(r.Context()).ReadUpload(bookID, id)
+	if err == gorm.ErrRecordNotFound {
+		// A finished upload has become an attachment; report it as complete
+		// so a client that lost the last response can tell.
+		a, err := api.repository.WithContext(r.Context()).Read(bookID, id)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return e.RespNotFound
+			}
+			return e.RespDBDataAccessFailure
+		}
+
+		h.Set("Upload-Offset", strconv.FormatInt(a.Size, 10))
+		h.Set("Upload-Length", strconv.FormatInt(a.Size, 10))
+		return nil
+	}
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if u.ExpiresAt.Before(time.Now()) {
+		return RespUploadExpired
+	}
+
+	h.Set("Upload-Offset", strconv.FormatInt(u.Received, 10))
+	h.Set("Upload-Length", strconv.FormatInt(u.Size, 10))
+	h.Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	return nil
+}
+
+// Patch godoc
+//
+//	@summary        Append to resumable upload
+//	@description    Append the body at Upload-Offset. The upload becomes a pending attachment
+//	@description    once all bytes are received.
+//	@tags           attachments
+//	@accept         application/offset+octet-stream
+//	@param          id              path    string  true    "Book ID"
+//	@param          uploadID        path    string  true    "Upload ID"
+//	@param          Upload-Offset   header  int     true    "Offset of the body"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        410 {object}    err.Problem
+//	@failure        412 {object}    err.Problem
+//	@failure        413 {object}    err.Problem
+//	@failure        415 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments/uploads/{uploadID} [patch]
+func (api *UploadAPI) Patch(w http.ResponseWriter, r *http.Request) error {
+	if err := tusRequest(w, r); err != nil {
+		return err
+	}
+
+	if r.Header.Get("Content-Type") != tusContentType {
+		return e.RespUnsupportedMediaType
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return e.RespInvalidUpload
+	}
+
+	u, err := api.upload(r)
+	if err != nil {
+		return err
+	}
+	if offset != u.Received {
+		return RespInvalidOffset
+	}
+
+	if u.Received < u.Size {
+		var body io.Reader = r.Body
+		if u.Received == 0 {
+			var contentType string
+			body, contentType, err = api.sniff(r, u)
+			if err != nil {
+				return err
+			}
+			u.ContentType = contentType
+		}
+
+		key := u.newChunkKey()
+		n, err := api.store.Put(r.Context(), key, &limitedReader{r: body, n: u.Size - u.Received})
+		if err != nil {
+			discard(r.Context(), api.store, key)
+			if errors.Is(err, errTooLarge) {
+				return e.RespFileTooLarge
+			}
+			return e.RespStorageFailure
+		}
+
+		if n > 0 {
+			u.Received += n
+			u.ChunkKeys = strings.Join(append(u.chunkKeys(), key), " ")
+			u.Chunks++
+
+			rows, err := api.repository.WithContext(r.Context()).AdvanceUpload(u, offset)
+			if err != nil || rows == 0 {
+				discard(r.Context(), api.store, key)
+				if err != nil {
+					return e.RespDBDataUpdateFailure
+				}
+				return RespInvalidOffset
+			}
+		} else {
+			discard(r.Context(), api.store, key)
+		}
+	}
+
+	if u.Received == u.Size {
+		if err := api.complete(r.Context(), u); err != nil {
+			log.Printf("upload %s complete: %s", u.ID, err)
+			return e.RespStorageFailure
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Received, 10))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Cancel resumable upload
+//	@description    Discard an unfinished upload and the bytes received so far
+//	@tags           attachments
+//	@param          id          path    string  true    "Book ID"
+//	@param          uploadID    path    string  true    "Upload ID"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        412 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/attachments/uploads/{uploadID} [delete]
+func (api *UploadAPI) Delete(w http.ResponseWriter, r *http.Request) error {
+	if err := tusRequest(w, r); err != nil {
+		return err
+	}
+
+	u, err := api.upload(r)
+	if err != nil {
+		return err
+	}
+
+	if err := api.remove(r.Context(), u); err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// ExpireUploads removes unfinished uploads past their expiry every interval
+// until ctx is done.
+func (api *UploadAPI) ExpireUploads(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := api.repository.WithContext(ctx).ListExpiredUploads(time.Now(), expiredUploadBatchSize)
+			if err != nil {
+				log.Printf("upload expiry: %s", err)
+				continue
+			}
+
+			for _, u := range expired {
+				if err := api.remove(ctx, u); err != nil {
+					log.Printf("upload %s expiry: %s", u.ID, err)
+				}
+			}
+		}
+	}
+}
+
+// upload loads the upload addressed by the URL, returning the problem to
+// answer when it is missing or expired.
+func (api *UploadAPI) upload(r *http.Request) (*Upload, error) {
+	bookID, id, err := uploadIDs(r)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := api.repository.WithContext(r.Context()).ReadUpload(bookID, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, e.RespNotFound
+		}
+
+		return nil, e.RespDBDataAccessFailure
+	}
+	if u.ExpiresAt.Before(time.Now()) {
+		return nil, RespUploadExpired
+	}
+
+	return u, nil
+}
+
+// sniff checks the media type from the start of the first chunk, discarding
+// the upload if the type is not allowed.
+func (api *UploadAPI) sniff(r *http.Request, u *Upload) (io.Reader, string, error) {
+	body, contentType, err := sniff(r.Body)
+	if err != nil {
+		return nil, "", e.RespInvalidUpload
+	}
+
+	if !allowed(api.allowedTypes, contentType) {
+		if err := api.remove(r.Context(), u); err != nil {
+			log.Printf("upload %s remove: %s", u.ID, err)
+		}
+		return nil, "", e.RespUnsupportedMediaType
+	}
+
+	return body, contentType.String(), nil
+}
+
+// complete joins the chunks of a finished upload into a pending attachment
+// and schedules its scan.
+func (api *UploadAPI) complete(ctx context.Context, u *Upload) error {
+	a := &Attachment{
+		ID:          u.ID,
+		BookID:      u.BookID,
+		Filename:    u.Filename,
+		ContentType: u.ContentType,
+		Status:      StatusPending,
+	}
+
+	chunks := &chunkReader{ctx: ctx, store: api.store, keys: u.chunkKeys()}
+	b, err := api.blobs.Put(ctx, chunks)
+	chunks.Close()
+	if err != nil {
+		return err
+	}
+	a.setBlob(b)
+
+	if err := api.repository.WithContext(ctx).CompleteUpload(a); err != nil {
+		api.blobs.Release(ctx, b.Hash)
+		return err
+	}
+
+	api.discardChunks(ctx, u)
+	api.scanWorker.Enqueue(a)
+	return nil
+}
+
+func (api *UploadAPI) remove(ctx context.Context, u *Upload) error {
+	if _, err := api.repository.WithContext(ctx).DeleteUpload(u.ID); err != nil {
+		return err
+	}
+
+	api.discardChunks(ctx, u)
+	return nil
+}
+
+func (api *UploadAPI) discardChunks(ctx context.Context, u *Upload) {
+	for _, key := range u.chunkKeys() {
+		discard(ctx, api.store, key)
+	}
+}
+
+// tusRequest sets the Tus-Resumable response header and checks that the
+// client speaks the same protocol version, returning RespTusVersionMismatch
+// otherwise.
+func tusRequest(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		return RespTusVersionMismatch
+	}
+	return nil
+}
+
+func uploadIDs(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, e.RespInvalidURLParamID
+	}
+
+	id, err := idcodec.Decode(chi.URLParam(r, "uploadID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, e.RespInvalidURLParamID
+	}
+
+	return bookID, id, nil
+}
+
+// uploadMetadata decodes a tus Upload-Metadata header: comma-separated
+// "key base64value" pairs. Pairs that do not decode are skipped.
+func uploadMetadata(header string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		meta[key] = string(decoded)
+	}
+	return meta
+}
+
+// chunkReader reads an upload's chunks in order, opening one at a time.
+type chunkReader struct {
+	ctx     context.Context
+	store   storage.Store
+	keys    []string
+	current io.ReadCloser
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if cr.current == nil {
+			if len(cr.keys) == 0 {
+				return 0, io.EOF
+			}
+
+			chunk, err := cr.store.Open(cr.ctx, cr.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			cr.current = chunk
+			cr.keys = cr.keys[1:]
+		}
+
+		n, err := cr.current.Read(p)
+		if err == io.EOF {
+			cr.current.Close()
+			cr.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (cr *chunkReader) Close() error {
+	if cr.current == nil {
+		return nil
+	}
+	return cr.current.Close()
+}
@@ -0,0 +1,138 @@
+package attachment_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/resource/attachment"
+	"hello/api/resource/blob"
+	e "hello/api/resource/common/err"
+	"hello/config"
+	mockDB "hello/mock/db"
+	"hello/scan"
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+var uploadColumns = []string{"id", "book_id", "filename", "content_type", "size", "received", "chunks", "chunk_keys", "expires_at"}
+
+func patch(bookID, id uuid.UUID, offset int, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPatch, "/books/"+bookID.String()+"/attachments/uploads/"+id.String(), strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", "1.0.0")
+	r.Header.Set("Content-Type", "application/offset+octet-stream")
+	r.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	return r
+}
+
+func TestUploadAPI_Patch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	root := t.TempDir()
+	store, err := storage.NewFS(root)
+	testUtil.NoError(t, err)
+
+	blobs := blob.NewStore(db, store)
+	api := attachment.NewUploadAPI(db, store, blobs, attachment.NewScanWorker(db, store, blobs, scan.Nop), &config.ConfAttachment{
+		ResumableMaxSize: 1 << 20,
+		UploadExpiry:     time.Hour,
+		AllowedTypes:     []string{"text/plain"},
+	})
+	router := chi.NewRouter()
+	router.Patch("/books/{id}/attachments/uploads/{uploadID}", e.Handle(api.Patch))
+
+	bookID, id := uuid.New(), uuid.New()
+	expires := time.Now().Add(time.Hour)
+
+	// First chunk.
+	mock.ExpectQuery(`^SELECT \* FROM "uploads"`).
+		WillReturnRows(sqlmock.NewRows(uploadColumns).AddRow(id, bookID, "notes.txt", "", 11, 0, 0, "", expires))
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE "uploads" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, patch(bookID, id, 0, "hello "))
+	testUtil.Equal(t, http.StatusNoContent, w.Code)
+	testUtil.Equal(t, "6", w.Header().Get("Upload-Offset"))
+
+	chunks, err := os.ReadDir(filepath.Join(root, "uploads", id.String()))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(chunks))
+	chunkKeys := "uploads/" + id.String() + "/" + chunks[0].Name()
+
+	// A retry that read the upload before the first chunk was advanced
+	// loses the race, and leaves the winner's chunk alone.
+	mock.ExpectQuery(`^SELECT \* FROM "uploads"`).
+		WillReturnRows(sqlmock.NewRows(uploadColumns).AddRow(id, bookID, "notes.txt", "", 11, 0, 0, "", expires))
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE "uploads" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patch(bookID, id, 0, "hello "))
+	testUtil.Equal(t, http.StatusConflict, w.Code)
+	chunks, err = os.ReadDir(filepath.Join(root, "uploads", id.String()))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(chunks))
+
+	// Stale offset.
+	mock.ExpectQuery(`^SELECT \* FROM "uploads"`).
+		WillReturnRows(sqlmock.NewRows(uploadColumns).AddRow(id, bookID, "notes.txt", "text/plain", 11, 6, 1, chunkKeys, expires))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patch(bookID, id, 0, "hello "))
+	testUtil.Equal(t, http.StatusConflict, w.Code)
+
+	// Last chunk completes the upload.
+	mock.ExpectQuery(`^SELECT \* FROM "uploads"`).
+		WillReturnRows(sqlmock.NewRows(uploadColumns).AddRow(id, bookID, "notes.txt", "text/plain", 11, 6, 1, chunkKeys, expires))
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE "uploads" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`^INSERT INTO "blobs" .+ ON CONFLICT`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`^DELETE FROM "uploads"`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^INSERT INTO "attachments"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patch(bookID, id, 6, "world"))
+	testUtil.Equal(t, http.StatusNoContent, w.Code)
+	testUtil.Equal(t, "11", w.Header().Get("Upload-Offset"))
+	testUtil.NoError(t, mock.ExpectationsWereMet())
+
+	sum := sha256.Sum256([]byte("hello world"))
+	rc, err := store.Open(ctx, blob.Key(hex.EncodeToString(sum[:])))
+	testUtil.NoError(t, err)
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "hello world", string(b))
+}
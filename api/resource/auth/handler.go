@@ -0,0 +1,437 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/ratelimit"
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/legalhold"
+	"hello/audit"
+	"hello/config"
+	"hello/event"
+	"hello/mail"
+	"hello/session"
+	validatorUtil "hello/util/validator"
+)
+
+// mailTimeout bounds sending a mail within a request.
+const mailTimeout = 10 * time.Second
+
+// EventPasswordReset is published after a user's password was reset, so
+// that their sessions can be revoked.
+const EventPasswordReset = "user.password_reset"
+
+// Audited actions.
+const (
+	ActionPasswordReset     = "user.password_reset"
+	ActionLogin             = "user.login"
+	ActionLoginFailed       = "user.login_failed"
+	ActionLogout            = "user.logout"
+	ActionInvited           = "invitation.created"
+	ActionInvitationRevoked = "invitation.revoked"
+	ActionInvitationAccept  = "invitation.accepted"
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	mailer     mail.Sender
+	bus        event.Bus
+	conf       *config.ConfAuth
+
+	// sessions is nil unless cookie sessions are enabled.
+	sessions *session.Manager
+	holds    *legalhold.Repository
+
+	// resends and resets limit verification and reset mails per address.
+	resends ratelimit.Limiter
+	resets  ratelimit.Limiter
+}
+
+func New(db *gorm.DB, v *validator.Validate, mailer mail.Sender, bus event.Bus, sessions *session.Manager, c *config.ConfAuth) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		mailer:     mailer,
+		bus:        bus,
+		conf:       c,
+		sessions:   sessions,
+		holds:      legalhold.NewRepository(db),
+		resends:    ratelimit.NewFixedWindow(c.ResendLimit, c.ResendWindow),
+		resets:     ratelimit.NewFixedWindow(c.ResendLimit, c.ResendWindow),
+	}
+}
+
+// Register godoc
+//
+//	@summary        Register
+//	@description    Create a user account and email a verification link
+//	@tags           auth
+//	@accept         json
+//	@produce        json
+//	@param          body    body    RegisterForm    true    "Registration form"
+//	@success        201 {object}    UserDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /auth/register [post]
+func (api *API) Register(w http.ResponseWriter, r *http.Request) error {
+	form := &RegisterForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(form.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return e.RespPasswordHashFailure
+	}
+
+	u, err := api.repository.WithContext(r.Context()).CreateUser(&User{
+		ID:           uuid.New(),
+		Email:        normalizeEmail(form.Email),
+		PasswordHash: string(hash),
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return e.RespDuplicateEmail
+		}
+
+		return e.RespDBDataInsertFailure
+	}
+
+	// The account exists either way; a link that failed to send can be
+	// requested again.
+	if err := api.sendVerification(r.Context(), u); err != nil {
+		log.Printf("verification mail to %s: %s", u.Email, err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(u.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Verify godoc
+//
+//	@summary        Verify email
+//	@description    Verify a user's email with the token of a verification link
+//	@tags           auth
+//	@produce        json
+//	@param          token   query   string  true    "Verification token"
+//	@success        200 {object}    UserDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /auth/verify [get]
+func (api *API) Verify(w http.ResponseWriter, r *http.Request) error {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return e.RespInvalidToken
+	}
+
+	u, err := api.repository.WithContext(r.Context()).Verify(hashToken(token), time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespInvalidToken
+		}
+
+		return e.RespDBDataUpdateFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(u.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Resend godoc
+//
+//	@summary        Resend verification
+//	@description    Email a new verification link. The response does not tell whether the address is registered.
+//	@tags           auth
+//	@accept         json
+//	@produce        json
+//	@param          body    body    ResendForm  true    "Resend form"
+//	@success        202
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        429 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /auth/verify/resend [post]
+func (api *API) Resend(w http.ResponseWriter, r *http.Request) error {
+	form := &ResendForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+	email := normalizeEmail(form.Email)
+
+	if err := allow(w, api.resends, email); err != nil {
+		return err
+	}
+
+	u, err := api.repository.WithContext(r.Context()).ReadUserByEmail(email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err == nil && u.EmailVerifiedAt == nil {
+		if err := api.sendVerification(r.Context(), u); err != nil {
+			log.Printf("verification mail to %s: %s", u.Email, err)
+			return e.RespMailDeliveryFailure
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// Forgot godoc
+//
+//	@summary        Forgot password
+//	@description    Email a password reset link. The response does not tell whether the address is registered.
+//	@tags           auth
+//	@accept         json
+//	@produce        json
+//	@param          body    body    ForgotForm  true    "Forgot password form"
+//	@success        202
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        429 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /auth/forgot [post]
+func (api *API) Forgot(w http.ResponseWriter, r *http.Request) error {
+	form := &ForgotForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+	email := normalizeEmail(form.Email)
+
+	if err := allow(w, api.resets, email); err != nil {
+		return err
+	}
+
+	// The account is looked up and mailed after answering, so that neither
+	// the status nor the timing of the response tells whether it exists.
+	go api.forgot(context.WithoutCancel(r.Context()), email)
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// forgot mails a reset link to the user registered with email, if any.
+func (api *API) forgot(ctx context.Context, email string) {
+	u, err := api.repository.WithContext(ctx).ReadUserByEmail(email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("reset mail: %s", err)
+		}
+		return
+	}
+
+	if err := api.sendReset(ctx, u); err != nil {
+		log.Printf("reset mail to user %s: %s", u.ID, err)
+	}
+}
+
+// Reset godoc
+//
+//	@summary        Reset password
+//	@description    Set a new password with the token of a reset link. The user's sessions are revoked.
+//	@tags           auth
+//	@accept         json
+//	@produce        json
+//	@param          body    body    ResetForm   true    "Reset password form"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /auth/reset [post]
+func (api *API) Reset(w http.ResponseWriter, r *http.Request) error {
+	form := &ResetForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(form.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return e.RespPasswordHashFailure
+	}
+
+	u, err := api.repository.WithContext(r.Context()).ResetPassword(hashToken(form.Token), string(hash), time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespInvalidToken
+		}
+
+		return e.RespDBDataUpdateFailure
+	}
+
+	if err := audit.Record(r, ActionPasswordReset, u.ID.String(), nil); err != nil {
+		return e.RespAuditFailure
+	}
+
+	// The password is changed either way; a failing subscriber is logged.
+	if err := api.bus.Publish(r.Context(), event.New(EventPasswordReset, u.ID.String(), u.ToDto())); err != nil {
+		log.Printf("event %s for %s: %s", EventPasswordReset, u.ID, err)
+	}
+	return nil
+}
+
+// RequireVerified rejects requests from anonymous users with 401 and from
+// users who have not verified their email with 403. Service accounts and
+// API keys have no email and pass.
+func (api *API) RequireVerified(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := user.Service(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := user.APIKey(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, ok := user.From(r.Context())
+		if !ok {
+			e.Unauthorized(w, e.RespAuthenticationRequired)
+			return
+		}
+
+		u, err := api.repository.WithContext(r.Context()).ReadUser(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				e.Unauthorized(w, e.RespAuthenticationRequired)
+				return
+			}
+
+			e.ServerError(w, e.RespDBDataAccessFailure)
+			return
+		}
+		if u.EmailVerifiedAt == nil {
+			e.Forbidden(w, e.RespEmailNotVerified)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (api *API) validate(r *http.Request, form any) error {
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+	return nil
+}
+
+// allow counts a mail to key against l, setting Retry-After and returning
+// RespRateLimitExceeded once the quota is used up.
+func allow(w http.ResponseWriter, l ratelimit.Limiter, key string) error {
+	res := l.Allow(key)
+	if res.Allowed {
+		return nil
+	}
+
+	retryAfter := int(math.Ceil(time.Until(res.Reset).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, retryAfter)))
+	return ratelimit.RespRateLimitExceeded
+}
+
+// sendVerification stores a new verification token for u and mails its
+// link.
+func (api *API) sendVerification(ctx context.Context, u *User) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := api.repository.WithContext(ctx).CreateToken(&VerificationToken{
+		TokenHash: hash,
+		UserID:    u.ID,
+		ExpiresAt: now.Add(api.conf.VerifyTokenTTL),
+		CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+
+	return api.send(ctx, u.Email, "Verify your email address",
+		fmt.Sprintf("Open this link to verify your email address:\n\n%s\n\nThe link expires in %s.\n",
+			link(api.conf.VerifyURL, token), api.conf.VerifyTokenTTL))
+}
+
+// sendReset stores a new reset token for u and mails its link.
+func (api *API) sendReset(ctx context.Context, u *User) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := api.repository.WithContext(ctx).CreateResetToken(&ResetToken{
+		TokenHash: hash,
+		UserID:    u.ID,
+		ExpiresAt: now.Add(api.conf.ResetTokenTTL),
+		CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+
+	return api.send(ctx, u.Email, "Reset your password",
+		fmt.Sprintf("Open this link to choose a new password:\n\n%s\n\nThe link expires in %s. If you did not ask for it, ignore this mail.\n",
+			link(api.conf.ResetURL, token), api.conf.ResetTokenTTL))
+}
+
+func (api *API) send(ctx context.Context, to, subject, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+
+	return api.mailer.Send(ctx, &mail.Message{To: to, Subject: subject, Body: body})
+}
+
+// link appends token to base as a query parameter.
+func link(base, token string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,231 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/auth"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/legalhold"
+	"hello/config"
+	"hello/event"
+	"hello/mail"
+	mockDB "hello/mock/db"
+	"hello/session"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+type outbox struct {
+	mu   sync.Mutex
+	sent []*mail.Message
+}
+
+func (o *outbox) Send(_ context.Context, m *mail.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, m)
+	return nil
+}
+
+// token returns the token of the last link sent.
+func (o *outbox) token(t *testing.T) string {
+	t.Helper()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	testUtil.Equal(t, true, len(o.sent) > 0)
+
+	for _, field := range strings.Fields(o.sent[len(o.sent)-1].Body) {
+		if u, err := url.Parse(field); err == nil && u.Query().Get("token") != "" {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatal("no verification link sent")
+	return ""
+}
+
+// wait waits until n mails have been sent, and returns the last.
+func (o *outbox) wait(t *testing.T, n int) *mail.Message {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		o.mu.Lock()
+		sent := o.sent
+		o.mu.Unlock()
+		if len(sent) >= n {
+			return sent[len(sent)-1]
+		}
+	}
+	t.Fatalf("%d mails not sent", n)
+	return nil
+}
+
+func newAPI(t *testing.T) (*auth.API, *outbox, *gorm.DB) {
+	t.Helper()
+
+	return newAPIWith(t, event.NewBus(), nil)
+}
+
+func newAPIWith(t *testing.T, bus event.Bus, sessions *session.Manager) (*auth.API, *outbox, *gorm.DB) {
+	t.Helper()
+
+	db := mockDB.NewSQLite(t, &auth.User{}, &auth.VerificationToken{}, &auth.ResetToken{}, &auth.Invitation{}, &auth.Role{}, &auth.Membership{}, &legalhold.Hold{})
+
+	o := &outbox{}
+	return auth.New(db, validatorUtil.New(), o, bus, sessions, &config.ConfAuth{
+		VerifyURL:      "http://localhost:8080/v1/auth/verify",
+		VerifyTokenTTL: time.Hour,
+		ResendLimit:    1,
+		ResendWindow:   time.Hour,
+		ResetURL:       "http://localhost:3000/reset-password",
+		ResetTokenTTL:  time.Hour,
+		InviteURL:      "http://localhost:3000/accept-invite",
+		InviteTTL:      time.Hour,
+	}), o, db
+}
+
+func TestAPI_RegisterVerify(t *testing.T) {
+	t.Parallel()
+
+	api, o, _ := newAPI(t)
+
+	w := httptest.NewRecorder()
+	e.Handle(api.Register)(w, httptest.NewRequest(http.MethodPost, "/auth/register",
+		strings.NewReader(`{"email": "Reader@Example.com", "password": "correct horse"}`)))
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"email":"reader@example.com"`))
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"email_verified":false`))
+
+	token := o.token(t)
+
+	w = httptest.NewRecorder()
+	e.Handle(api.Verify)(w, httptest.NewRequest(http.MethodGet, "/auth/verify?token=wrong", nil))
+	testUtil.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	e.Handle(api.Verify)(w, httptest.NewRequest(http.MethodGet, "/auth/verify?token="+token, nil))
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"email_verified":true`))
+
+	// Tokens are single use.
+	w = httptest.NewRecorder()
+	e.Handle(api.Verify)(w, httptest.NewRequest(http.MethodGet, "/auth/verify?token="+token, nil))
+	testUtil.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPI_Resend(t *testing.T) {
+	t.Parallel()
+
+	api, o, _ := newAPI(t)
+
+	w := httptest.NewRecorder()
+	e.Handle(api.Register)(w, httptest.NewRequest(http.MethodPost, "/auth/register",
+		strings.NewReader(`{"email": "reader@example.com", "password": "correct horse"}`)))
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		sent   int
+	}{
+		{"registered", `{"email": "READER@example.com"}`, http.StatusAccepted, 2},
+		{"beyond limit", `{"email": "reader@example.com"}`, http.StatusTooManyRequests, 2},
+		{"unknown", `{"email": "nobody@example.com"}`, http.StatusAccepted, 2},
+		{"invalid", `{"email": "nobody"}`, http.StatusUnprocessableEntity, 2},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		e.Handle(api.Resend)(w, httptest.NewRequest(http.MethodPost, "/auth/verify/resend", strings.NewReader(tc.body)))
+		testUtil.Equal(t, tc.status, w.Code)
+		testUtil.Equal(t, tc.sent, len(o.sent))
+	}
+}
+
+func TestAPI_RequireVerified(t *testing.T) {
+	t.Parallel()
+
+	api, o, _ := newAPI(t)
+
+	w := httptest.NewRecorder()
+	e.Handle(api.Register)(w, httptest.NewRequest(http.MethodPost, "/auth/register",
+		strings.NewReader(`{"email": "reader@example.com", "password": "correct horse"}`)))
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	var dto auth.UserDTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+	id := uuid.MustParse(dto.ID)
+
+	h := api.RequireVerified(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(ctx context.Context) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books", nil).WithContext(ctx))
+		return w.Code
+	}
+
+	testUtil.Equal(t, http.StatusUnauthorized, serve(context.Background()))
+	testUtil.Equal(t, http.StatusUnauthorized, serve(user.WithID(context.Background(), uuid.New())))
+	testUtil.Equal(t, http.StatusForbidden, serve(user.WithID(context.Background(), id)))
+
+	e.Handle(api.Verify)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/auth/verify?token="+o.token(t), nil))
+	testUtil.Equal(t, http.StatusOK, serve(user.WithID(context.Background(), id)))
+}
+
+func TestAPI_ForgotReset(t *testing.T) {
+	t.Parallel()
+
+	bus := event.NewBus()
+	var reset []string
+	bus.Subscribe(auth.EventPasswordReset, func(_ context.Context, e event.Event) error {
+		reset = append(reset, e.AggregateID)
+		return nil
+	})
+	api, o, _ := newAPIWith(t, bus, nil)
+
+	w := httptest.NewRecorder()
+	e.Handle(api.Register)(w, httptest.NewRequest(http.MethodPost, "/auth/register",
+		strings.NewReader(`{"email": "reader@example.com", "password": "correct horse"}`)))
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+
+	o.wait(t, 1)
+
+	// Both requests are answered alike; only the registered address is
+	// mailed, after the response.
+	w = httptest.NewRecorder()
+	e.Handle(api.Forgot)(w, httptest.NewRequest(http.MethodPost, "/auth/forgot", strings.NewReader(`{"email": "nobody@example.com"}`)))
+	testUtil.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	e.Handle(api.Forgot)(w, httptest.NewRequest(http.MethodPost, "/auth/forgot", strings.NewReader(`{"email": "reader@example.com"}`)))
+	testUtil.Equal(t, http.StatusAccepted, w.Code)
+	testUtil.Equal(t, "reader@example.com", o.wait(t, 2).To)
+	token := o.token(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"short password", `{"token": "` + token + `", "password": "short"}`, http.StatusUnprocessableEntity},
+		{"unknown token", `{"token": "wrong", "password": "battery staple"}`, http.StatusBadRequest},
+		{"valid", `{"token": "` + token + `", "password": "battery staple"}`, http.StatusOK},
+		{"used token", `{"token": "` + token + `", "password": "battery staple"}`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		e.Handle(api.Reset)(w, httptest.NewRequest(http.MethodPost, "/auth/reset", strings.NewReader(tc.body)))
+		testUtil.Equal(t, tc.status, w.Code)
+	}
+	testUtil.Equal(t, 1, len(reset))
+}
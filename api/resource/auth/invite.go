@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/audit"
+)
+
+// Invite godoc
+//
+//	@summary        Invite user
+//	@description    Email a link granting a role in the tenant to whoever accepts it before it expires
+//	@tags           admin
+//	@accept         json
+//	@produce        json
+//	@param          X-Tenant-ID header  string      false   "Tenant"
+//	@param          body        body    InviteForm  true    "Invitation form"
+//	@success        201 {object}    InvitationDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/invitations [post]
+func (api *API) Invite(w http.ResponseWriter, r *http.Request) error {
+	form := &InviteForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	now := time.Now()
+	inv := &Invitation{
+		ID:        uuid.New(),
+		TenantID:  tenant.From(r.Context()),
+		Email:     normalizeEmail(form.Email),
+		Role:      form.Role,
+		TokenHash: hash,
+		ExpiresAt: now.Add(api.conf.InviteTTL),
+		CreatedAt: now,
+	}
+	if id, ok := user.From(r.Context()); ok {
+		inv.InvitedBy = &id
+	}
+
+	if err := api.repository.WithContext(r.Context()).CreateInvitation(inv); err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	if err := audit.Record(r, ActionInvited, inv.ID.String(), audit.Details{"email": inv.Email, "role": inv.Role}); err != nil {
+		return e.RespAuditFailure
+	}
+
+	if err := api.send(r.Context(), inv.Email, "You have been invited",
+		fmt.Sprintf("You have been invited to join %s as %s. Open this link to accept:\n\n%s\n\nThe link expires in %s.\n",
+			inv.TenantID, inv.Role, link(api.conf.InviteURL, token), api.conf.InviteTTL)); err != nil {
+		log.Printf("invitation mail to %s: %s", inv.Email, err)
+		return e.RespMailDeliveryFailure
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(inv.ToDto(now)); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// ListInvitations godoc
+//
+//	@summary        List invitations
+//	@description    List the tenant's invitations, newest first
+//	@tags           admin
+//	@produce        json
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          status      query   string  false   "pending, accepted or expired"
+//	@success        200 {array}     InvitationDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/invitations [get]
+func (api *API) ListInvitations(w http.ResponseWriter, r *http.Request) error {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", InvitationPending, InvitationAccepted, InvitationExpired:
+	default:
+		return e.RespInvalidInvitationStatus
+	}
+
+	now := time.Now()
+	invitations, err := api.repository.WithContext(r.Context()).ListInvitations(tenant.From(r.Context()), status, now)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(invitations.ToDto(now)); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// RevokeInvitation godoc
+//
+//	@summary        Revoke invitation
+//	@description    Delete an invitation that has not been accepted
+//	@tags           admin
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          id          path    string  true    "Invitation ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/invitations/{id} [delete]
+func (api *API) RevokeInvitation(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).RevokeInvitation(tenant.From(r.Context()), id)
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	if err := audit.Record(r, ActionInvitationRevoked, id.String(), nil); err != nil {
+		return e.RespAuditFailure
+	}
+	return nil
+}
+
+// AcceptInvite godoc
+//
+//	@summary        Accept invitation
+//	@description    Accept an invitation, creating the invitee's account unless the address is registered
+//	@tags           auth
+//	@accept         json
+//	@produce        json
+//	@param          body    body    AcceptInviteForm    true    "Accept invitation form"
+//	@success        200 {object}    MembershipDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /auth/accept-invite [post]
+func (api *API) AcceptInvite(w http.ResponseWriter, r *http.Request) error {
+	form := &AcceptInviteForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	inv, err := api.repository.WithContext(r.Context()).ReadInvitationByToken(hashToken(form.Token), now)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespInvalidToken
+		}
+
+		return e.RespDBDataAccessFailure
+	}
+
+	u, err := api.repository.WithContext(r.Context()).ReadUserByEmail(inv.Email)
+	create := errors.Is(err, gorm.ErrRecordNotFound)
+	if err != nil && !create {
+		return e.RespDBDataAccessFailure
+	}
+
+	if create {
+		if form.Password == "" {
+			return e.RespValidationFailed.WithErrors([]e.FieldError{{Field: "password", Message: "password is a required field"}})
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(form.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return e.RespPasswordHashFailure
+		}
+		u = &User{ID: uuid.New(), Email: inv.Email, PasswordHash: string(hash)}
+	}
+
+	if err := api.repository.WithContext(r.Context()).AcceptInvitation(inv, u, create, now); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return e.RespInvalidToken
+		case errors.As(err, &pgErr) && pgErr.Code == "23505":
+			return e.RespDuplicateEmail
+		default:
+			return e.RespDBDataUpdateFailure
+		}
+	}
+
+	details := audit.Details{"user_id": u.ID.String(), "tenant_id": inv.TenantID, "role": inv.Role, "new_user": create}
+	if err := audit.Record(r, ActionInvitationAccept, inv.ID.String(), details); err != nil {
+		return e.RespAuditFailure
+	}
+
+	dto := &MembershipDTO{User: u.ToDto(), Tenant: inv.TenantID, Role: inv.Role}
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hello/api/middleware/tenant"
+	"hello/api/resource/auth"
+	e "hello/api/resource/common/err"
+	testUtil "hello/util/test"
+)
+
+func TestAPI_Invitations(t *testing.T) {
+	t.Parallel()
+
+	api, o, _ := newAPI(t)
+	h := tenant.Middleware(e.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/admin/invitations":
+			return api.Invite(w, r)
+		case r.Method == http.MethodGet && r.URL.Path == "/admin/invitations":
+			return api.ListInvitations(w, r)
+		default:
+			return api.AcceptInvite(w, r)
+		}
+	}))
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(tenant.Header, "acme")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	list := func(status string) []*auth.InvitationDTO {
+		w := serve(http.MethodGet, "/admin/invitations?status="+status, "")
+		testUtil.Equal(t, http.StatusOK, w.Code)
+
+		var dtos []*auth.InvitationDTO
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dtos))
+		return dtos
+	}
+
+	w := serve(http.MethodPost, "/admin/invitations", `{"email": "reader@example.com", "role": "owner"}`)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(http.MethodPost, "/admin/invitations", `{"email": "reader@example.com", "role": "editor"}`)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	token := o.token(t)
+	testUtil.Equal(t, 1, len(list(auth.InvitationPending)))
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"unknown token", `{"token": "wrong", "password": "correct horse"}`, http.StatusBadRequest},
+		{"new account without password", `{"token": "` + token + `"}`, http.StatusUnprocessableEntity},
+		{"new account", `{"token": "` + token + `", "password": "correct horse"}`, http.StatusOK},
+		{"accepted", `{"token": "` + token + `", "password": "correct horse"}`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		w := serve(http.MethodPost, "/auth/accept-invite", tc.body)
+		testUtil.Equal(t, tc.status, w.Code)
+
+		if tc.status == http.StatusOK {
+			var dto auth.MembershipDTO
+			testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+			testUtil.Equal(t, "acme", dto.Tenant)
+			testUtil.Equal(t, "editor", dto.Role)
+			testUtil.Equal(t, true, dto.User.EmailVerified)
+		}
+	}
+
+	testUtil.Equal(t, 0, len(list(auth.InvitationPending)))
+	testUtil.Equal(t, 1, len(list(auth.InvitationAccepted)))
+
+	// An existing account only gains the role.
+	w = serve(http.MethodPost, "/admin/invitations", `{"email": "reader@example.com", "role": "admin"}`)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	w = serve(http.MethodPost, "/auth/accept-invite", `{"token": "`+o.token(t)+`"}`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"role":"admin"`))
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserDTO struct {
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	DisplayName   string `json:"display_name,omitempty"`
+}
+
+type RegisterForm struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type LoginForm struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type ResendForm struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+type ForgotForm struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+// ProfileForm changes the fields it carries. A new email has to be
+// verified again.
+type ProfileForm struct {
+	Email       *string `json:"email" validate:"omitempty,email,max=254"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+}
+
+type PasswordForm struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=72"`
+	Password        string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// DeleteAccountForm confirms deleting an account with its password.
+type DeleteAccountForm struct {
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type ResetForm struct {
+	Token    string `json:"token" validate:"required,max=64"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// Roles a user can hold in a tenant, lowest first. Their ranks are kept in
+// the roles table; a role grants everything the roles ranked below it do.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Invitation statuses, derived from the acceptance and expiry times.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationExpired  = "expired"
+)
+
+type InvitationDTO struct {
+	ID         string     `json:"id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	Status     string     `json:"status"`
+	InvitedBy  string     `json:"invited_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type MembershipDTO struct {
+	User   *UserDTO `json:"user"`
+	Tenant string   `json:"tenant"`
+	Role   string   `json:"role"`
+}
+
+type InviteForm struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+	Role  string `json:"role" validate:"required,oneof=viewer editor admin"`
+}
+
+// AcceptInviteForm carries the password of the account to create. Invitees
+// who already have an account leave it out.
+type AcceptInviteForm struct {
+	Token    string `json:"token" validate:"required,max=64"`
+	Password string `json:"password" validate:"omitempty,min=8,max=72"`
+}
+
+type User struct {
+	ID              uuid.UUID `gorm:"primarykey"`
+	Email           string
+	DisplayName     string
+	PasswordHash    string
+	EmailVerifiedAt *time.Time
+	// PasswordChangedAt is set by a password reset or change; sessions
+	// issued before it are no longer valid.
+	PasswordChangedAt *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	// DeletedAt soft deletes an account: it can no longer sign in, and its
+	// email can be registered again.
+	DeletedAt gorm.DeletedAt
+}
+
+// VerificationToken is an emailed verification link. Only the SHA-256 of
+// the token is stored.
+type VerificationToken struct {
+	TokenHash string `gorm:"primarykey"`
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (VerificationToken) TableName() string {
+	return "email_verification_tokens"
+}
+
+// ResetToken is an emailed password reset link. Only the SHA-256 of the
+// token is stored.
+type ResetToken struct {
+	TokenHash string `gorm:"primarykey"`
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (ResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+// Invitation is an emailed link granting a role in a tenant to whoever
+// accepts it before it expires. Only the SHA-256 of the token is stored.
+type Invitation struct {
+	ID         uuid.UUID `gorm:"primarykey"`
+	TenantID   string
+	Email      string
+	Role       string
+	TokenHash  string
+	InvitedBy  *uuid.UUID
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	AcceptedBy *uuid.UUID
+	CreatedAt  time.Time
+}
+
+// Role ranks the permissions of a role against the others.
+type Role struct {
+	Name        string `gorm:"primarykey"`
+	Rank        int
+	Description string
+}
+
+// Membership is a user's role in a tenant.
+type Membership struct {
+	UserID    uuid.UUID `gorm:"primarykey"`
+	TenantID  string    `gorm:"primarykey"`
+	Role      string
+	CreatedAt time.Time
+}
+
+func (u *User) ToDto() *UserDTO {
+	return &UserDTO{
+		ID:            u.ID.String(),
+		Email:         u.Email,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		DisplayName:   u.DisplayName,
+	}
+}
+
+func (i *Invitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case !now.Before(i.ExpiresAt):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
+
+func (i *Invitation) ToDto(now time.Time) *InvitationDTO {
+	dto := &InvitationDTO{
+		ID:         i.ID.String(),
+		Email:      i.Email,
+		Role:       i.Role,
+		Status:     i.Status(now),
+		ExpiresAt:  i.ExpiresAt,
+		AcceptedAt: i.AcceptedAt,
+		CreatedAt:  i.CreatedAt,
+	}
+	if i.InvitedBy != nil {
+		dto.InvitedBy = i.InvitedBy.String()
+	}
+	return dto
+}
+
+type Invitations []*Invitation
+
+func (is Invitations) ToDto(now time.Time) []*InvitationDTO {
+	dtos := make([]*InvitationDTO, len(is))
+	for i, v := range is {
+		dtos[i] = v.ToDto(now)
+	}
+	return dtos
+}
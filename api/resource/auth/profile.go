@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/legalhold"
+	"hello/audit"
+	"hello/event"
+)
+
+// Events published when a user changes their password or deletes their
+// account, so that their sessions can be revoked.
+const (
+	EventPasswordChanged = "user.password_changed"
+	EventUserDeleted     = "user.deleted"
+)
+
+// Audited profile actions.
+const (
+	ActionProfileUpdated  = "user.profile_updated"
+	ActionPasswordChanged = "user.password_changed"
+	ActionUserDeleted     = "user.deleted"
+)
+
+// ReadProfile godoc
+//
+//	@summary        Read profile
+//	@description    Read the signed-in user's account
+//	@tags           auth
+//	@produce        json
+//	@success        200 {object}    UserDTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me [get]
+func (api *API) ReadProfile(w http.ResponseWriter, r *http.Request) error {
+	u, err := api.self(r)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(w).Encode(u.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// UpdateProfile godoc
+//
+//	@summary        Update profile
+//	@description    Change the signed-in user's display name or email. A new email is unverified until the link mailed to it is opened. Accounts under legal hold cannot be changed.
+//	@tags           auth
+//	@accept         json
+//	@produce        json
+//	@param          body    body    ProfileForm true    "Profile form"
+//	@success        200 {object}    UserDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me [patch]
+func (api *API) UpdateProfile(w http.ResponseWriter, r *http.Request) error {
+	form := &ProfileForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	current, err := api.self(r)
+	if err != nil {
+		return err
+	}
+	if err := api.held(r, current.ID, ActionProfileUpdated); err != nil {
+		return err
+	}
+
+	updates := map[string]any{}
+	if form.DisplayName != nil {
+		updates["display_name"] = strings.TrimSpace(*form.DisplayName)
+	}
+	if form.Email != nil && normalizeEmail(*form.Email) != current.Email {
+		updates["email"] = normalizeEmail(*form.Email)
+	}
+	if len(updates) == 0 {
+		if err := json.NewEncoder(w).Encode(current.ToDto()); err != nil {
+			return e.RespJSONEncodeFailure
+		}
+		return nil
+	}
+	updates["updated_at"] = time.Now()
+
+	u, err := api.repository.WithContext(r.Context()).UpdateProfile(current.ID, updates)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.As(err, &pgErr) && pgErr.Code == "23505":
+			return e.RespDuplicateEmail
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return e.RespAuthenticationRequired
+		default:
+			return e.RespDBDataUpdateFailure
+		}
+	}
+
+	details := audit.Details{}
+	if _, ok := updates["email"]; ok {
+		details["old_email"] = current.Email
+		details["email"] = u.Email
+	}
+	if err := audit.Record(r, ActionProfileUpdated, u.ID.String(), details); err != nil {
+		return e.RespAuditFailure
+	}
+
+	if _, ok := updates["email"]; ok {
+		if err := api.sendVerification(r.Context(), u); err != nil {
+			log.Printf("verification mail to %s: %s", u.Email, err)
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(u.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// ChangePassword godoc
+//
+//	@summary        Change password
+//	@description    Set a new password, confirming the current one. The user's sessions, this one included, are revoked.
+//	@tags           auth
+//	@accept         json
+//	@param          body    body    PasswordForm    true    "Password form"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        403 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me/password [put]
+func (api *API) ChangePassword(w http.ResponseWriter, r *http.Request) error {
+	form := &PasswordForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	u, err := api.confirm(r, form.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(form.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return e.RespPasswordHashFailure
+	}
+
+	if err := api.repository.WithContext(r.Context()).ChangePassword(u.ID, string(hash), time.Now()); err != nil {
+		return e.RespDBDataUpdateFailure
+	}
+
+	if err := audit.Record(r, ActionPasswordChanged, u.ID.String(), nil); err != nil {
+		return e.RespAuditFailure
+	}
+
+	// The password is changed either way; a failing subscriber is logged.
+	if err := api.bus.Publish(r.Context(), event.New(EventPasswordChanged, u.ID.String(), u.ToDto())); err != nil {
+		log.Printf("event %s for %s: %s", EventPasswordChanged, u.ID, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// DeleteProfile godoc
+//
+//	@summary        Delete account
+//	@description    Delete the signed-in user's account, confirming its password. The account can no longer sign in and its email can be registered again. Accounts under legal hold cannot be deleted.
+//	@tags           auth
+//	@accept         json
+//	@param          body    body    DeleteAccountForm   true    "Delete account form"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        403 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me [delete]
+func (api *API) DeleteProfile(w http.ResponseWriter, r *http.Request) error {
+	form := &DeleteAccountForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	u, err := api.confirm(r, form.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := api.held(r, u.ID, ActionUserDeleted); err != nil {
+		return err
+	}
+
+	if err := api.repository.WithContext(r.Context()).DeleteUser(u.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespAuthenticationRequired
+		}
+
+		return e.RespDBDataRemoveFailure
+	}
+
+	if err := audit.Record(r, ActionUserDeleted, u.ID.String(), audit.Details{"email": u.Email}); err != nil {
+		return e.RespAuditFailure
+	}
+
+	if err := api.bus.Publish(r.Context(), event.New(EventUserDeleted, u.ID.String(), u.ToDto())); err != nil {
+		log.Printf("event %s for %s: %s", EventUserDeleted, u.ID, err)
+	}
+	if api.sessions != nil {
+		if err := api.sessions.End(w, r); err != nil {
+			log.Printf("session end: %s", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// self returns the signed-in user, or RespAuthenticationRequired for
+// anonymous requests and those of deleted accounts.
+func (api *API) self(r *http.Request) (*User, error) {
+	id, err := caller(r)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := api.repository.WithContext(r.Context()).ReadUser(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.RespAuthenticationRequired
+		}
+
+		return nil, e.RespDBDataAccessFailure
+	}
+	return u, nil
+}
+
+// held returns RespOnLegalHold when the user is under legal hold, and so
+// can be neither changed nor deleted, noting the attempted action.
+func (api *API) held(r *http.Request, id uuid.UUID, action string) error {
+	held, err := api.holds.WithContext(r.Context()).Held(legalhold.KindUser, id)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if len(held) == 0 {
+		return nil
+	}
+
+	audit.Note(r, legalhold.ActionBlocked, id.String(), audit.Details{"kind": legalhold.KindUser, "action": action})
+	return e.RespOnLegalHold
+}
+
+// confirm returns the signed-in user when password is theirs, and
+// RespIncorrectPassword otherwise.
+func (api *API) confirm(r *http.Request, password string) (*User, error) {
+	u, err := api.self(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		audit.Note(r, ActionLoginFailed, u.ID.String(), audit.Details{"email": u.Email})
+		return nil, e.RespIncorrectPassword
+	}
+	return u, nil
+}
+
+func caller(r *http.Request) (uuid.UUID, error) {
+	id, ok := user.From(r.Context())
+	if !ok {
+		return uuid.Nil, e.RespAuthenticationRequired
+	}
+	return id, nil
+}
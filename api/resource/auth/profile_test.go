@@ -0,0 +1,100 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/auth"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/legalhold"
+	"hello/event"
+	testUtil "hello/util/test"
+)
+
+func TestAPI_Profile(t *testing.T) {
+	t.Parallel()
+
+	bus := event.NewBus()
+	var revoked []string
+	for _, name := range []string{auth.EventPasswordChanged, auth.EventUserDeleted} {
+		bus.Subscribe(name, func(_ context.Context, e event.Event) error {
+			revoked = append(revoked, e.Name)
+			return nil
+		})
+	}
+	api, o, db := newAPIWith(t, bus, nil)
+
+	w := httptest.NewRecorder()
+	e.Handle(api.Register)(w, httptest.NewRequest(http.MethodPost, "/auth/register",
+		strings.NewReader(`{"email": "reader@example.com", "password": "correct horse"}`)))
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	var dto auth.UserDTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+	e.Handle(api.Verify)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/auth/verify?token="+o.token(t), nil))
+
+	as := func(id string, method, body string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/me", strings.NewReader(body))
+		if id != "" {
+			r = r.WithContext(user.WithID(r.Context(), uuid.MustParse(id)))
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	testUtil.Equal(t, http.StatusUnauthorized, as("", http.MethodGet, "", e.Handle(api.ReadProfile)).Code)
+	testUtil.Equal(t, http.StatusUnauthorized, as(uuid.NewString(), http.MethodGet, "", e.Handle(api.ReadProfile)).Code)
+
+	w = as(dto.ID, http.MethodGet, "", e.Handle(api.ReadProfile))
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"email_verified":true`))
+
+	// A new email has to be verified again.
+	sent := len(o.sent)
+	w = as(dto.ID, http.MethodPatch, `{"display_name": " Reader ", "email": "New@Example.com"}`, e.Handle(api.UpdateProfile))
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"email":"new@example.com"`))
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"email_verified":false`))
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"display_name":"Reader"`))
+	testUtil.Equal(t, sent+1, len(o.sent))
+	testUtil.Equal(t, "new@example.com", o.sent[len(o.sent)-1].To)
+
+	testUtil.Equal(t, http.StatusUnprocessableEntity, as(dto.ID, http.MethodPatch, `{"email": "nobody"}`, e.Handle(api.UpdateProfile)).Code)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"short password", `{"current_password": "correct horse", "password": "short"}`, http.StatusUnprocessableEntity},
+		{"wrong password", `{"current_password": "wrong horse", "password": "battery staple"}`, http.StatusForbidden},
+		{"valid", `{"current_password": "correct horse", "password": "battery staple"}`, http.StatusNoContent},
+		{"old password", `{"current_password": "correct horse", "password": "battery staple"}`, http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		testUtil.Equal(t, tc.status, as(dto.ID, http.MethodPut, tc.body, e.Handle(api.ChangePassword)).Code)
+	}
+
+	testUtil.Equal(t, http.StatusForbidden, as(dto.ID, http.MethodDelete, `{"password": "correct horse"}`, e.Handle(api.DeleteProfile)).Code)
+
+	// Accounts under legal hold are kept as they are.
+	holds := legalhold.NewRepository(db)
+	testUtil.NoError(t, holds.Place(&legalhold.Hold{Kind: legalhold.KindUser, TargetID: uuid.MustParse(dto.ID), Reason: "litigation", PlacedAt: time.Now()}))
+	testUtil.Equal(t, http.StatusConflict, as(dto.ID, http.MethodDelete, `{"password": "battery staple"}`, e.Handle(api.DeleteProfile)).Code)
+	testUtil.Equal(t, http.StatusConflict, as(dto.ID, http.MethodPatch, `{"display_name": "Someone"}`, e.Handle(api.UpdateProfile)).Code)
+	testUtil.Equal(t, http.StatusOK, as(dto.ID, http.MethodGet, "", e.Handle(api.ReadProfile)).Code)
+	_, err := holds.Release(legalhold.KindUser, uuid.MustParse(dto.ID))
+	testUtil.NoError(t, err)
+
+	testUtil.Equal(t, http.StatusNoContent, as(dto.ID, http.MethodDelete, `{"password": "battery staple"}`, e.Handle(api.DeleteProfile)).Code)
+	testUtil.Equal(t, http.StatusUnauthorized, as(dto.ID, http.MethodGet, "", e.Handle(api.ReadProfile)).Code)
+	testUtil.Equal(t, auth.EventPasswordChanged+","+auth.EventUserDeleted, strings.Join(revoked, ","))
+}
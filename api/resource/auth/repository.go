@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) CreateUser(u *User) (*User, error) {
+	if err := r.db.Create(u).Error; err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *Repository) ReadUser(id uuid.UUID) (*User, error) {
+	u := &User{}
+	if err := r.db.Where("id = ?", id).First(u).Error; err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *Repository) ReadUserByEmail(email string) (*User, error) {
+	u := &User{}
+	if err := r.db.Where("email = ?", email).First(u).Error; err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *Repository) CreateToken(t *VerificationToken) error {
+	return r.db.Create(t).Error
+}
+
+func (r *Repository) CreateResetToken(t *ResetToken) error {
+	return r.db.Create(t).Error
+}
+
+// Verify marks the user of an unexpired token verified and drops all of the
+// user's tokens. It returns gorm.ErrRecordNotFound for unknown or expired
+// tokens.
+func (r *Repository) Verify(tokenHash string, now time.Time) (*User, error) {
+	u := &User{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		t := &VerificationToken{}
+		if err := tx.Where("token_hash = ? AND expires_at > ?", tokenHash, now).First(t).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&User{}).Where("id = ? AND email_verified_at IS NULL", t.UserID).
+			Update("email_verified_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", t.UserID).Delete(&VerificationToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", t.UserID).First(u).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ResetPassword sets the password of the user of an unexpired reset token
+// and drops all of the user's reset tokens. It returns
+// gorm.ErrRecordNotFound for unknown or expired tokens, and for tokens
+// spent by a concurrent reset.
+func (r *Repository) ResetPassword(tokenHash, passwordHash string, now time.Time) (*User, error) {
+	u := &User{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		t := &ResetToken{}
+		if err := tx.Where("token_hash = ? AND expires_at > ?", tokenHash, now).First(t).Error; err != nil {
+			return err
+		}
+		// The token is claimed by deleting it: of two resets racing with
+		// it, the second waits for the first to commit and deletes nothing.
+		res := tx.Where("token_hash = ? AND expires_at > ?", tokenHash, now).Delete(&ResetToken{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Model(&User{}).Where("id = ?", t.UserID).Updates(map[string]any{
+			"password_hash":       passwordHash,
+			"password_changed_at": now,
+			"updated_at":          now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", t.UserID).Delete(&ResetToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", t.UserID).First(u).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// UpdateProfile sets the given columns of a user. A new email is
+// unverified, and the verification links sent to the old one are dropped.
+func (r *Repository) UpdateProfile(id uuid.UUID, updates map[string]any) (*User, error) {
+	u := &User{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if _, ok := updates["email"]; ok {
+			updates["email_verified_at"] = nil
+			if err := tx.Where("user_id = ?", id).Delete(&VerificationToken{}).Error; err != nil {
+				return err
+			}
+		}
+
+		res := tx.Model(&User{}).Where("id = ?", id).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Where("id = ?", id).First(u).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ChangePassword sets the password of a user and drops their reset tokens.
+func (r *Repository) ChangePassword(id uuid.UUID, passwordHash string, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", id).Updates(map[string]any{
+			"password_hash":       passwordHash,
+			"password_changed_at": now,
+			"updated_at":          now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", id).Delete(&ResetToken{}).Error
+	})
+}
+
+// DeleteUser soft deletes a user and drops their tokens and memberships, so
+// that nothing issued to the account outlives it.
+func (r *Repository) DeleteUser(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ?", id).Delete(&User{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		for _, model := range []any{&VerificationToken{}, &ResetToken{}, &Membership{}} {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Repository) CreateInvitation(i *Invitation) error {
+	return r.db.Create(i).Error
+}
+
+// ListInvitations lists a tenant's invitations, newest first, optionally only
+// those with the given status.
+func (r *Repository) ListInvitations(tenantID, status string, now time.Time) (Invitations, error) {
+	q := r.db.Where("tenant_id = ?", tenantID)
+	switch status {
+	case InvitationPending:
+		q = q.Where("accepted_at IS NULL AND expires_at > ?", now)
+	case InvitationAccepted:
+		q = q.Where("accepted_at IS NOT NULL")
+	case InvitationExpired:
+		q = q.Where("accepted_at IS NULL AND expires_at <= ?", now)
+	}
+
+	invitations := make([]*Invitation, 0)
+	if err := q.Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// ReadInvitationByToken returns the pending invitation of a token, or
+// gorm.ErrRecordNotFound.
+func (r *Repository) ReadInvitationByToken(tokenHash string, now time.Time) (*Invitation, error) {
+	i := &Invitation{}
+	if err := r.db.Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", tokenHash, now).First(i).Error; err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// RevokeInvitation deletes a tenant's invitation unless it was accepted.
+func (r *Repository) RevokeInvitation(tenantID string, id uuid.UUID) (int64, error) {
+	result := r.db.Where("tenant_id = ? AND id = ? AND accepted_at IS NULL", tenantID, id).Delete(&Invitation{})
+	return result.RowsAffected, result.Error
+}
+
+// ReadMembership returns u's membership of a tenant, or
+// gorm.ErrRecordNotFound.
+func (r *Repository) ReadMembership(userID uuid.UUID, tenantID string) (*Membership, error) {
+	m := &Membership{}
+	if err := r.db.Where("user_id = ? AND tenant_id = ?", userID, tenantID).First(m).Error; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RoleRanks returns the ranks of the known roles among names.
+func (r *Repository) RoleRanks(names []string) (map[string]int, error) {
+	var roles []*Role
+	if err := r.db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	ranks := make(map[string]int, len(roles))
+	for _, role := range roles {
+		ranks[role.Name] = role.Rank
+	}
+	return ranks, nil
+}
+
+// AcceptInvitation marks i accepted by u, creating u first when create is
+// set, and grants u the invited role in the tenant. The invitee proved
+// ownership of the address, so it counts as verified. It returns
+// gorm.ErrRecordNotFound when the invitation was accepted or expired in the
+// meantime.
+func (r *Repository) AcceptInvitation(i *Invitation, u *User, create bool, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if create {
+			u.EmailVerifiedAt = &now
+			if err := tx.Create(u).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&User{}).Where("id = ? AND email_verified_at IS NULL", u.ID).
+			Update("email_verified_at", now).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND expires_at > ?", i.ID, now).
+			Updates(map[string]any{"accepted_at": now, "accepted_by": u.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "tenant_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role"}),
+		}).Create(&Membership{
+			UserID:    u.ID,
+			TenantID:  i.TenantID,
+			Role:      i.Role,
+			CreatedAt: now,
+		}).Error
+	})
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"gorm.io/gorm"
+
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+)
+
+// RequireRole rejects requests whose caller holds no role ranked at least as
+// high as role in the request's tenant. A caller holds the role of their
+// membership, and any role named among their scopes, which is how the gateway
+// and service accounts grant them. Callers with neither an identity nor a
+// role scope get 401, the others 403.
+func (api *API) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			repository := api.repository.WithContext(ctx)
+			held := slices.Clone(scope.From(ctx))
+
+			id, signedIn := user.From(ctx)
+			if signedIn {
+				m, err := repository.ReadMembership(id, tenant.From(ctx))
+				switch {
+				case err == nil:
+					held = append(held, m.Role)
+				case !errors.Is(err, gorm.ErrRecordNotFound):
+					e.ServerError(w, e.RespDBDataAccessFailure)
+					return
+				}
+			}
+
+			ranks, err := repository.RoleRanks(append(held, role))
+			if err != nil {
+				e.ServerError(w, e.RespDBDataAccessFailure)
+				return
+			}
+
+			best, hasRole := 0, false
+			for _, h := range held {
+				if rank, ok := ranks[h]; ok && (!hasRole || rank > best) {
+					best, hasRole = rank, true
+				}
+			}
+			_, service := user.Service(ctx)
+			_, apiKey := user.APIKey(ctx)
+			if !hasRole && !signedIn && !service && !apiKey {
+				e.Unauthorized(w, e.RespAuthenticationRequired)
+				return
+			}
+
+			// An unknown required role is never satisfied.
+			need, ok := ranks[role]
+			if !ok || !hasRole || best < need {
+				e.Forbidden(w, e.RespInsufficientRole)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+	"hello/api/resource/auth"
+	testUtil "hello/util/test"
+)
+
+func TestAPI_RequireRole(t *testing.T) {
+	t.Parallel()
+
+	api, _, db := newAPI(t)
+	testUtil.NoError(t, db.Create([]*auth.Role{
+		{Name: auth.RoleViewer, Rank: 10},
+		{Name: auth.RoleEditor, Rank: 20},
+		{Name: auth.RoleAdmin, Rank: 30},
+	}).Error)
+
+	viewer, editor, stranger := uuid.New(), uuid.New(), uuid.New()
+	testUtil.NoError(t, db.Create([]*auth.Membership{
+		{UserID: viewer, TenantID: "acme", Role: auth.RoleViewer, CreatedAt: time.Now()},
+		{UserID: editor, TenantID: "acme", Role: auth.RoleEditor, CreatedAt: time.Now()},
+		{UserID: stranger, TenantID: "other", Role: auth.RoleAdmin, CreatedAt: time.Now()},
+	}).Error)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	anon := tenant.With(context.Background(), "acme")
+
+	tests := []struct {
+		name   string
+		role   string
+		ctx    context.Context
+		status int
+	}{
+		{"anonymous", auth.RoleViewer, anon, http.StatusUnauthorized},
+		{"viewer reads", auth.RoleViewer, user.WithID(anon, viewer), http.StatusOK},
+		{"viewer writes", auth.RoleEditor, user.WithID(anon, viewer), http.StatusForbidden},
+		{"editor writes", auth.RoleEditor, user.WithID(anon, editor), http.StatusOK},
+		{"editor deletes", auth.RoleAdmin, user.WithID(anon, editor), http.StatusForbidden},
+		{"admin of another tenant", auth.RoleViewer, user.WithID(anon, stranger), http.StatusForbidden},
+		{"admin scope", auth.RoleAdmin, scope.With(anon, []string{"books:read", "admin"}), http.StatusOK},
+		{"scope raises membership", auth.RoleAdmin, scope.With(user.WithID(anon, viewer), []string{"admin"}), http.StatusOK},
+		{"service account without role", auth.RoleViewer, user.WithService(anon, uuid.New()), http.StatusForbidden},
+		{"unknown role", "owner", scope.With(anon, []string{"admin"}), http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			api.RequireRole(tc.role)(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books", nil).WithContext(tc.ctx))
+			testUtil.Equal(t, tc.status, w.Code)
+		})
+	}
+}
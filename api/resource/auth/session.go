@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/audit"
+	"hello/event"
+	"hello/session"
+)
+
+// dummyHash is compared against for unknown addresses, so that login takes
+// as long whether or not an address is registered.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// SubscribeSessions revokes a user's sessions when their password is reset
+// or changed, or their account deleted.
+func SubscribeSessions(bus event.Bus, sessions *session.Manager) {
+	revoke := func(ctx context.Context, ev event.Event) error {
+		id, err := uuid.Parse(ev.AggregateID)
+		if err != nil {
+			return err
+		}
+		return sessions.RevokeUser(ctx, id)
+	}
+	for _, name := range []string{EventPasswordReset, EventPasswordChanged, EventUserDeleted} {
+		bus.Subscribe(name, revoke)
+	}
+}
+
+// Login godoc
+//
+//	@summary        Log in
+//	@description    Start a cookie session for browser clients
+//	@tags           auth
+//	@accept         json
+//	@produce        json
+//	@param          body    body    LoginForm   true    "Login form"
+//	@success        200 {object}    UserDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /auth/login [post]
+func (api *API) Login(w http.ResponseWriter, r *http.Request) error {
+	form := &LoginForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	u, err := api.repository.WithContext(r.Context()).ReadUserByEmail(normalizeEmail(form.Email))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(form.Password))
+			audit.Note(r, ActionLoginFailed, "", audit.Details{"email": normalizeEmail(form.Email)})
+			return e.RespInvalidCredential
+		}
+
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(form.Password)); err != nil {
+		audit.Note(r, ActionLoginFailed, u.ID.String(), audit.Details{"email": u.Email})
+		return e.RespInvalidCredential
+	}
+
+	if err := api.sessions.Start(r.Context(), w, u.ID); err != nil {
+		log.Printf("session for %s: %s", u.ID, err)
+		return e.RespSessionFailure
+	}
+	audit.Note(r, ActionLogin, u.ID.String(), nil)
+
+	if err := json.NewEncoder(w).Encode(u.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Logout godoc
+//
+//	@summary        Log out
+//	@description    End the cookie session
+//	@tags           auth
+//	@success        200
+//	@failure        500 {object}    err.Problem
+//	@router         /auth/logout [post]
+func (api *API) Logout(w http.ResponseWriter, r *http.Request) error {
+	if err := api.sessions.End(w, r); err != nil {
+		log.Printf("session end: %s", err)
+		return e.RespSessionFailure
+	}
+	audit.Note(r, ActionLogout, "", nil)
+	return nil
+}
@@ -0,0 +1,89 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/auth"
+	e "hello/api/resource/common/err"
+	"hello/config"
+	"hello/event"
+	"hello/session"
+	testUtil "hello/util/test"
+)
+
+func TestAPI_LoginLogout(t *testing.T) {
+	t.Parallel()
+
+	bus := event.NewBus()
+	sessions := session.NewManager(session.NewMemory(), []byte("key"), &config.ConfSession{
+		TTL:         time.Hour,
+		MaxLifetime: 24 * time.Hour,
+		CookieName:  "session",
+	})
+	auth.SubscribeSessions(bus, sessions)
+	api, o, _ := newAPIWith(t, bus, sessions)
+
+	w := httptest.NewRecorder()
+	e.Handle(api.Register)(w, httptest.NewRequest(http.MethodPost, "/auth/register",
+		strings.NewReader(`{"email": "reader@example.com", "password": "correct horse"}`)))
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"unknown email", `{"email": "nobody@example.com", "password": "correct horse"}`, http.StatusUnauthorized},
+		{"wrong password", `{"email": "reader@example.com", "password": "battery staple"}`, http.StatusUnauthorized},
+		{"valid", `{"email": "Reader@example.com", "password": "correct horse"}`, http.StatusOK},
+	}
+	var cookie *http.Cookie
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		e.Handle(api.Login)(w, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(tc.body)))
+		testUtil.Equal(t, tc.status, w.Code)
+
+		if tc.status == http.StatusOK {
+			cookie = w.Result().Cookies()[0]
+		}
+	}
+
+	signedIn := func() bool {
+		var ok bool
+		h := sessions.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, ok = user.From(r.Context())
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		return ok
+	}
+	testUtil.Equal(t, true, signedIn())
+
+	// A password reset ends the session.
+	e.Handle(api.Forgot)(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/auth/forgot",
+		strings.NewReader(`{"email": "reader@example.com"}`)))
+	o.wait(t, 2)
+	w = httptest.NewRecorder()
+	e.Handle(api.Reset)(w, httptest.NewRequest(http.MethodPost, "/auth/reset",
+		strings.NewReader(`{"token": "`+o.token(t)+`", "password": "battery staple"}`)))
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, false, signedIn())
+
+	w = httptest.NewRecorder()
+	e.Handle(api.Login)(w, httptest.NewRequest(http.MethodPost, "/auth/login",
+		strings.NewReader(`{"email": "reader@example.com", "password": "battery staple"}`)))
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	cookie = w.Result().Cookies()[0]
+	testUtil.Equal(t, true, signedIn())
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	req.AddCookie(cookie)
+	e.Handle(api.Logout)(httptest.NewRecorder(), req)
+	testUtil.Equal(t, false, signedIn())
+}
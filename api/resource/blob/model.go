@@ -0,0 +1,27 @@
+package blob
+
+import "time"
+
+// Blob is content stored once under its SHA-256 hash and shared by every
+// record that references it. RefCount counts those references; blobs left
+// at zero are removed by the collector.
+type Blob struct {
+	Hash      string `gorm:"primarykey"`
+	Size      int64
+	RefCount  int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Blobs []*Blob
+
+// Key is where a blob's content lives in the store.
+func Key(hash string) string {
+	return "blobs/" + hash[:2] + "/" + hash
+}
+
+// DerivedKey is where content derived from a blob, such as a thumbnail, is
+// kept under name.
+func DerivedKey(hash, name string) string {
+	return "derived/" + hash[:2] + "/" + hash + "/" + name
+}
@@ -0,0 +1,89 @@
+package blob
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// Acquire adds a reference to b, creating the blob on first use.
+func (r *Repository) Acquire(b *Blob) error {
+	b.RefCount = 1
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"ref_count":  gorm.Expr("blobs.ref_count + 1"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(b).Error
+}
+
+// Release drops a reference to the blob.
+func (r *Repository) Release(hash string) error {
+	return r.db.Model(&Blob{}).
+		Where("hash = ? AND ref_count > 0", hash).
+		Updates(map[string]any{
+			"ref_count":  gorm.Expr("ref_count - 1"),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// Collect removes up to limit unreferenced blobs. The rows stay locked while
+// remove deletes their content, so a concurrent Acquire of the same hash
+// waits and then recreates both.
+func (r *Repository) Collect(limit int, remove func(*Blob) error) (int, error) {
+	var n int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		blobs := make([]*Blob, 0)
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("ref_count = 0").
+			Limit(limit).
+			Find(&blobs).Error; err != nil {
+			return err
+		}
+
+		for _, b := range blobs {
+			if err := remove(b); err != nil {
+				return err
+			}
+			if err := tx.Delete(b).Error; err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Referenced returns up to limit referenced blobs whose hash sorts after
+// after, in hash order, for walking all of them page by page.
+func (r *Repository) Referenced(after string, limit int) (Blobs, error) {
+	blobs := make([]*Blob, 0)
+	if err := r.db.Where("ref_count > 0 AND hash > ?", after).
+		Order("hash").
+		Limit(limit).
+		Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
@@ -0,0 +1,134 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/storage"
+)
+
+const collectBatchSize = 100
+
+// Store deduplicates content by SHA-256 on top of a storage.Store. Identical
+// uploads share one stored object.
+type Store struct {
+	repository *Repository
+	store      storage.Store
+	derived    []string
+}
+
+// NewStore returns a store whose collector also removes the content derived
+// from blobs under each of derived, see DerivedKey.
+func NewStore(db *gorm.DB, store storage.Store, derived ...string) *Store {
+	return &Store{
+		repository: NewRepository(db),
+		store:      store,
+		derived:    derived,
+	}
+}
+
+// Put stores r and returns the blob holding it, with a reference taken for
+// the caller. Content is written to a temporary object while it is hashed,
+// and only copied into place if no blob with the same hash exists yet.
+func (s *Store) Put(ctx context.Context, r io.Reader) (*Blob, error) {
+	tmp := "tmp/" + uuid.NewString()
+	defer s.discard(ctx, tmp)
+
+	h := sha256.New()
+	size, err := s.store.Put(ctx, tmp, io.TeeReader(r, h))
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Blob{Hash: hex.EncodeToString(h.Sum(nil)), Size: size}
+	if err := s.repository.WithContext(ctx).Acquire(b); err != nil {
+		return nil, err
+	}
+
+	// The reference now keeps the collector away, so it is safe to check
+	// whether the content is already in place.
+	existing, err := s.store.Open(ctx, Key(b.Hash))
+	if err == nil {
+		existing.Close()
+		return b, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		s.Release(ctx, b.Hash)
+		return nil, err
+	}
+
+	if err := s.copy(ctx, tmp, Key(b.Hash)); err != nil {
+		s.Release(ctx, b.Hash)
+		return nil, err
+	}
+	return b, nil
+}
+
+// Release drops a reference taken by Put. The content is removed by the
+// collector once nothing references it. Callers release as they clean up
+// after a failure, so the release is not cancelled with ctx.
+func (s *Store) Release(ctx context.Context, hash string) {
+	if err := s.repository.WithContext(context.WithoutCancel(ctx)).Release(hash); err != nil {
+		log.Printf("blob %s release: %s", hash, err)
+	}
+}
+
+// Collect removes unreferenced blobs and returns how many it removed.
+func (s *Store) Collect(ctx context.Context) (int, error) {
+	return s.repository.WithContext(ctx).Collect(collectBatchSize, func(b *Blob) error {
+		for _, name := range s.derived {
+			if err := s.store.Delete(ctx, DerivedKey(b.Hash, name)); err != nil {
+				return err
+			}
+		}
+		return s.store.Delete(ctx, Key(b.Hash))
+	})
+}
+
+// RunCollector collects unreferenced blobs every interval until ctx is done.
+func (s *Store) RunCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := s.Collect(ctx)
+				if err != nil {
+					log.Printf("blob collect: %s", err)
+				}
+				if err != nil || n < collectBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (s *Store) copy(ctx context.Context, from, to string) error {
+	content, err := s.store.Open(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	_, err = s.store.Put(ctx, to, content)
+	return err
+}
+
+func (s *Store) discard(ctx context.Context, key string) {
+	if err := s.store.Delete(ctx, key); err != nil {
+		log.Printf("blob storage delete %s: %s", key, err)
+	}
+}
@@ -0,0 +1,90 @@
+package blob_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"hello/api/resource/blob"
+	mockDB "hello/mock/db"
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+func TestStore_Put(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	fs, err := storage.NewFS(t.TempDir())
+	testUtil.NoError(t, err)
+	s := blob.NewStore(db, fs)
+
+	sum := sha256.Sum256([]byte("cover"))
+	hash := hex.EncodeToString(sum[:])
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec(`^INSERT INTO "blobs" .+ ON CONFLICT \("hash"\) DO UPDATE SET "ref_count"=blobs.ref_count \+ 1`).
+			WithArgs(hash, int64(5), int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		b, err := s.Put(ctx, strings.NewReader("cover"))
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, hash, b.Hash)
+		testUtil.Equal(t, int64(5), b.Size)
+	}
+	testUtil.NoError(t, mock.ExpectationsWereMet())
+
+	rc, err := fs.Open(ctx, blob.Key(hash))
+	testUtil.NoError(t, err)
+	content, err := io.ReadAll(rc)
+	rc.Close()
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "cover", string(content))
+}
+
+func TestStore_Collect(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	fs, err := storage.NewFS(t.TempDir())
+	testUtil.NoError(t, err)
+	s := blob.NewStore(db, fs, "small")
+
+	hash := strings.Repeat("cd", 32)
+	_, err = fs.Put(ctx, blob.Key(hash), strings.NewReader("old cover"))
+	testUtil.NoError(t, err)
+	_, err = fs.Put(ctx, blob.DerivedKey(hash, "small"), strings.NewReader("old thumbnail"))
+	testUtil.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM "blobs" WHERE ref_count = 0 LIMIT \$1 FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(sqlmock.NewRows([]string{"hash", "size", "ref_count"}).AddRow(hash, 9, 0))
+	mock.ExpectExec(`^DELETE FROM "blobs" WHERE "blobs"."hash" = \$1`).
+		WithArgs(hash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := s.Collect(ctx)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, n)
+	testUtil.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = fs.Open(ctx, blob.Key(hash))
+	testUtil.Equal(t, true, errors.Is(err, storage.ErrNotFound))
+	_, err = fs.Open(ctx, blob.DerivedKey(hash, "small"))
+	testUtil.Equal(t, true, errors.Is(err, storage.ErrNotFound))
+}
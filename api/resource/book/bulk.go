@@ -0,0 +1,400 @@
+package book
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/dryrun"
+	"hello/api/middleware/tenant"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/legalhold"
+	"hello/audit"
+	"hello/idcodec"
+	"hello/util/jsonpatch"
+	"hello/util/sanitizer"
+	validatorUtil "hello/util/validator"
+)
+
+// MaxBulk is the number of items one bulk request may carry.
+const MaxBulk = 500
+
+// BulkResultDTO is the outcome for one item of a bulk request, whose
+// position in the request Index gives.
+type BulkResultDTO struct {
+	Index  int      `json:"index"`
+	ID     string   `json:"id,omitempty"`
+	Status int      `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ValidationResultDTO is the outcome of validating one form of a bulk
+// validation, whose position in the request Index gives.
+type ValidationResultDTO struct {
+	Index  int            `json:"index"`
+	Valid  bool           `json:"valid"`
+	Errors []e.FieldError `json:"errors,omitempty"`
+}
+
+// BulkCreate godoc
+//
+//	@summary        Create books in bulk
+//	@description    Create up to 500 books at once, all or none. Each form is validated as for a single create; when any is invalid nothing is created and the errors of each invalid form are returned. Image URLs are not probed
+//	@tags           books
+//	@accept         json
+//	@produce        json
+//	@param          body    body    []Form  true    "Book forms"
+//	@param          dry_run query   bool    false   "Run validation and business rules and answer as the write would, without committing it; also accepted as a Dry-Run header"
+//	@success        201 {array}     BulkResultDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/bulk [post]
+func (api *API) BulkCreate(w http.ResponseWriter, r *http.Request) error {
+	var forms []*Form
+	if err := json.NewDecoder(r.Body).Decode(&forms); err != nil {
+		return e.RespInvalidBody
+	}
+	if len(forms) == 0 || len(forms) > MaxBulk {
+		return e.RespInvalidBulkSize
+	}
+
+	var invalid []e.FieldError
+	for i, form := range forms {
+		msgs, err := api.formErrors(r, form)
+		if err != nil {
+			return e.RespDBDataAccessFailure
+		}
+		for _, msg := range msgs {
+			invalid = append(invalid, e.FieldError{Field: fmt.Sprintf("[%d]", i), Message: msg})
+		}
+	}
+	if len(invalid) > 0 {
+		return e.RespValidationFailed.WithErrors(invalid)
+	}
+
+	if dryrun.Requested(r) {
+		results := make([]*BulkResultDTO, len(forms))
+		for i := range forms {
+			results[i] = &BulkResultDTO{Index: i, Status: http.StatusCreated}
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			return e.RespJSONEncodeFailure
+		}
+		return nil
+	}
+
+	books := make(Books, len(forms))
+	for i, form := range forms {
+		books[i] = form.ToModel()
+		books[i].ID = uuid.New()
+	}
+
+	if err := api.repository.WithContext(r.Context()).CreateMany(books); err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	results := make([]*BulkResultDTO, len(books))
+	for i, b := range books {
+		api.publish(r.Context(), EventCreated, b.ID, b)
+		results[i] = &BulkResultDTO{Index: i, ID: idcodec.Encode(b.ID), Status: http.StatusCreated}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// BulkValidate godoc
+//
+//	@summary        Validate books in bulk
+//	@description    Validate up to 500 book forms as a bulk create would, without creating anything, and return the outcome of each: whether it is valid and, if not, what is wrong with which field. Meant for importers to show every error before committing
+//	@tags           books
+//	@accept         json
+//	@produce        json
+//	@param          body    body    []Form  true    "Book forms"
+//	@success        200 {array}     ValidationResultDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/validate [post]
+func (api *API) BulkValidate(w http.ResponseWriter, r *http.Request) error {
+	var forms []*Form
+	if err := json.NewDecoder(r.Body).Decode(&forms); err != nil {
+		return e.RespInvalidBody
+	}
+	if len(forms) == 0 || len(forms) > MaxBulk {
+		return e.RespInvalidBulkSize
+	}
+
+	results := make([]*ValidationResultDTO, len(forms))
+	for i, form := range forms {
+		errs, err := api.formFieldErrors(r, form)
+		if err != nil {
+			return e.RespDBDataAccessFailure
+		}
+		results[i] = &ValidationResultDTO{Index: i, Valid: len(errs) == 0, Errors: errs}
+	}
+
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// BulkDelete godoc
+//
+//	@summary        Delete books in bulk
+//	@description    Delete up to 500 books at once. Each ID is handled on its own, with a status of 200 when the book was deleted, 400 when the ID is invalid, 404 when there is no such book and 409 when the book is under legal hold
+//	@tags           books
+//	@accept         json
+//	@produce        json
+//	@param          body    body    []string    true    "Book IDs"
+//	@param          dry_run query   bool    false   "Run validation and business rules and answer as the write would, without committing it; also accepted as a Dry-Run header"
+//	@success        200 {array}     BulkResultDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/bulk [delete]
+func (api *API) BulkDelete(w http.ResponseWriter, r *http.Request) error {
+	var params []string
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return e.RespInvalidBody
+	}
+	if len(params) == 0 || len(params) > MaxBulk {
+		return e.RespInvalidBulkSize
+	}
+
+	results := make([]*BulkResultDTO, len(params))
+	ids := make([]uuid.UUID, 0, len(params))
+	for i, param := range params {
+		results[i] = &BulkResultDTO{Index: i, ID: param, Status: http.StatusNotFound}
+		id, err := idcodec.Decode(param)
+		if err != nil {
+			results[i].Status = http.StatusBadRequest
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	dryRun := dryrun.Requested(r)
+	held, err := api.holds.WithContext(r.Context()).Held(legalhold.KindBook, ids...)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	onHold := make(map[uuid.UUID]bool, len(held))
+	for _, id := range held {
+		onHold[id] = true
+		if !dryRun {
+			audit.Note(r, legalhold.ActionBlocked, id.String(), audit.Details{"kind": legalhold.KindBook, "action": ActionDeleted})
+		}
+	}
+	ids = slices.DeleteFunc(ids, func(id uuid.UUID) bool { return onHold[id] })
+
+	var deleted []uuid.UUID
+	if dryRun {
+		deleted, err = api.repository.WithContext(r.Context()).Existing(ids)
+	} else {
+		deleted, err = api.repository.WithContext(r.Context()).DeleteMany(ids)
+	}
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+
+	gone := make(map[uuid.UUID]bool, len(deleted))
+	for _, id := range deleted {
+		gone[id] = true
+		if dryRun {
+			continue
+		}
+		audit.Note(r, ActionDeleted, id.String(), nil)
+		api.publish(r.Context(), EventDeleted, id, nil)
+	}
+	for _, result := range results {
+		if id, err := idcodec.Decode(result.ID); err == nil && gone[id] {
+			result.Status = http.StatusOK
+		} else if err == nil && onHold[id] {
+			result.Status = http.StatusConflict
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// BulkPatchForm applies one JSON Patch to many books.
+type BulkPatchForm struct {
+	IDs        []string        `json:"ids"`
+	Operations jsonpatch.Patch `json:"operations"`
+}
+
+// BulkPatch godoc
+//
+//	@summary        Patch books in bulk
+//	@description    Apply the same JSON Patch (RFC 6902) to up to 500 books. The patch is checked once, as for a single patch; then each ID is handled on its own, with a status of 200 when the book was patched or needed no change, 400 when the ID is invalid, 404 when there is no such book, 409 when a test operation failed, a path is missing from the book or the book is under legal hold and 422 when the patched book is invalid. Image URLs are not probed
+//	@tags           books
+//	@accept         json
+//	@produce        json
+//	@param          body    body    BulkPatchForm   true    "Book IDs and the patch to apply to each"
+//	@param          dry_run query   bool    false   "Run validation and business rules and answer as the write would, without committing it; also accepted as a Dry-Run header"
+//	@success        200 {array}     BulkResultDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/bulk [patch]
+func (api *API) BulkPatch(w http.ResponseWriter, r *http.Request) error {
+	form := &BulkPatchForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespInvalidBody
+	}
+	if len(form.IDs) == 0 || len(form.IDs) > MaxBulk {
+		return e.RespInvalidBulkSize
+	}
+	if msgs := checkJSONPatch(form.Operations); len(msgs) > 0 {
+		return e.RespValidationFailed.WithErrors(e.Messages(msgs))
+	}
+
+	ids := make([]uuid.UUID, 0, len(form.IDs))
+	for _, param := range form.IDs {
+		if id, err := idcodec.Decode(param); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	held, err := api.holds.WithContext(r.Context()).Held(legalhold.KindBook, ids...)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	repository := api.repository.WithContext(r.Context())
+	results := make([]*BulkResultDTO, len(form.IDs))
+	for i, param := range form.IDs {
+		result := &BulkResultDTO{Index: i, ID: param, Status: http.StatusOK}
+		results[i] = result
+
+		id, err := idcodec.Decode(param)
+		if err != nil {
+			result.Status = http.StatusBadRequest
+			continue
+		}
+		if slices.Contains(held, id) {
+			if !dryrun.Requested(r) {
+				audit.Note(r, legalhold.ActionBlocked, id.String(), audit.Details{"kind": legalhold.KindBook, "action": ActionUpdated})
+			}
+			result.Status = http.StatusConflict
+			continue
+		}
+		book, err := repository.Read(id)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				result.Status = http.StatusNotFound
+				continue
+			}
+
+			return e.RespDBDataAccessFailure
+		}
+
+		bookForm := book.ToForm()
+		fields, err := applyJSONPatch(bookForm, form.Operations)
+		if err != nil {
+			if errors.Is(err, jsonpatch.ErrTestFailed) || errors.Is(err, jsonpatch.ErrPath) {
+				result.Status = http.StatusConflict
+				continue
+			}
+			result.Status, result.Errors = http.StatusUnprocessableEntity, []string{err.Error()}
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		msgs, err := api.formErrors(r, bookForm, fields...)
+		if err != nil {
+			return e.RespDBDataAccessFailure
+		}
+		if len(msgs) > 0 {
+			result.Status, result.Errors = http.StatusUnprocessableEntity, msgs
+			continue
+		}
+
+		next := bookForm.ToModel()
+		next.ID = id
+		columns := book.changes(next)
+		if len(columns) == 0 || dryrun.Requested(r) {
+			continue
+		}
+		rows, err := repository.Patch(id, columns)
+		if err != nil {
+			return e.RespDBDataUpdateFailure
+		}
+		if rows == 0 {
+			result.Status = http.StatusNotFound
+			continue
+		}
+
+		api.publish(r.Context(), EventUpdated, id, next)
+	}
+
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// formErrors sanitizes form and returns what is wrong with it, checking
+// custom fields against the tenant's schema. Given fields, only those are
+// checked.
+func (api *API) formErrors(r *http.Request, form *Form, fields ...string) ([]string, error) {
+	errs, err := api.formFieldErrors(r, form, fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []string
+	for _, fe := range errs {
+		msgs = append(msgs, fe.Message)
+	}
+	return msgs, nil
+}
+
+// formFieldErrors is formErrors naming the field of each error where there
+// is one.
+func (api *API) formFieldErrors(r *http.Request, form *Form, fields ...string) ([]e.FieldError, error) {
+	if form == nil {
+		return []e.FieldError{{Message: "book form is required"}}, nil
+	}
+
+	sanitizer.Struct(form)
+	var err error
+	if len(fields) > 0 {
+		err = api.validator.StructPartial(form, fields...)
+	} else {
+		err = api.validator.Struct(form)
+	}
+	if err != nil {
+		if errs := validatorUtil.ToFieldErrors(err, r); errs != nil {
+			return errs, nil
+		}
+		return nil, err
+	}
+	if len(fields) > 0 && !slices.Contains(fields, "CustomFields") {
+		return nil, nil
+	}
+
+	msgs, err := api.customFields.Validate(r.Context(), tenant.From(r.Context()), form.CustomFields)
+	if err != nil {
+		return nil, err
+	}
+	var errs []e.FieldError
+	for _, msg := range msgs {
+		errs = append(errs, e.FieldError{Field: "custom_fields", Message: msg})
+	}
+	return errs, nil
+}
@@ -0,0 +1,73 @@
+package book
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+var ErrUnsupportedLocale = errors.New("book: unsupported locale")
+
+// Collator maps a requested locale onto one of the ICU collations the
+// database is known to provide.
+type Collator struct {
+	tags    []language.Tag
+	matcher language.Matcher
+}
+
+func NewCollator(locales []string) *Collator {
+	tags := make([]language.Tag, 0, len(locales))
+	for _, l := range locales {
+		if tag, err := language.Parse(l); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+
+	return &Collator{
+		tags:    tags,
+		matcher: language.NewMatcher(tags),
+	}
+}
+
+// Resolve returns the locale to sort titles by, or "" when the request does
+// not ask for one. An explicit ?locale= must be supported; an Accept-Language
+// header that matches nothing is ignored.
+func (c *Collator) Resolve(r *http.Request) (string, error) {
+	if len(c.tags) == 0 {
+		return "", nil
+	}
+
+	if l := r.URL.Query().Get("locale"); l != "" {
+		tag, err := language.Parse(l)
+		if err != nil {
+			return "", ErrUnsupportedLocale
+		}
+
+		_, i, conf := c.matcher.Match(tag)
+		if conf < language.High {
+			return "", ErrUnsupportedLocale
+		}
+		return c.tags[i].String(), nil
+	}
+
+	if al := r.Header.Get("Accept-Language"); al != "" {
+		accepted, _, err := language.ParseAcceptLanguage(al)
+		if err != nil || len(accepted) == 0 {
+			return "", nil
+		}
+
+		_, i, conf := c.matcher.Match(accepted...)
+		if conf >= language.High {
+			return c.tags[i].String(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// collationName is the Postgres ICU collation for locale. locale always comes
+// from Resolve, so it is safe to quote into SQL.
+func collationName(locale string) string {
+	return `"` + locale + `-x-icu"`
+}
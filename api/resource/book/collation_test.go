@@ -0,0 +1,43 @@
+package book_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"hello/api/resource/book"
+	testUtil "hello/util/test"
+)
+
+func TestCollator_Resolve(t *testing.T) {
+	t.Parallel()
+
+	c := book.NewCollator([]string{"en", "de", "sv"})
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptLanguage string
+		want           string
+		wantErr        error
+	}{
+		{"none", "/books", "", "", nil},
+		{"explicit", "/books?locale=sv", "de", "sv", nil},
+		{"explicit region", "/books?locale=de-AT", "", "de", nil},
+		{"explicit unsupported", "/books?locale=pl", "", "", book.ErrUnsupportedLocale},
+		{"accept-language", "/books", "de-DE,de;q=0.9,en;q=0.5", "de", nil},
+		{"accept-language unsupported", "/books", "pl", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+
+			got, err := c.Resolve(r)
+			testUtil.Equal(t, tt.wantErr, err)
+			testUtil.Equal(t, tt.want, got)
+		})
+	}
+}
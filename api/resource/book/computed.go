@@ -0,0 +1,48 @@
+package book
+
+import (
+	"context"
+	"time"
+
+	"hello/util/computed"
+)
+
+// Computed holds the derived fields added to book DTOs. Other packages may
+// register more fields while the router is being wired.
+var Computed = newComputed()
+
+func newComputed() *computed.Registry[*Book] {
+	r := computed.NewRegistry[*Book]()
+	r.Register("age_years", computed.Field(ageYears))
+	return r
+}
+
+// ageYears is the number of whole years since publication, or nil when the
+// publication date is unknown.
+func ageYears(b *Book) any {
+	if b.PublishedDate.IsZero() {
+		return nil
+	}
+
+	now := time.Now()
+	years := now.Year() - b.PublishedDate.Year()
+	if now.YearDay() < b.PublishedDate.YearDay() {
+		years--
+	}
+	return years
+}
+
+// toDtos converts books to DTOs with their computed fields resolved in one
+// batch.
+func toDtos(ctx context.Context, bs Books) ([]*DTO, error) {
+	fields, err := Computed.Resolve(ctx, bs)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := bs.ToDto()
+	for i, f := range fields {
+		dtos[i].Computed = f
+	}
+	return dtos, nil
+}
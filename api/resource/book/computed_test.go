@@ -0,0 +1,24 @@
+package book_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"hello/api/resource/book"
+	testUtil "hello/util/test"
+)
+
+func TestComputed_AgeYears(t *testing.T) {
+	t.Parallel()
+
+	books := book.Books{
+		{PublishedDate: time.Now().AddDate(-10, 0, -1)},
+		{},
+	}
+
+	fields, err := book.Computed.Resolve(context.Background(), books)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 10, fields[0]["age_years"].(int))
+	testUtil.Equal(t, 0, len(fields[1]))
+}
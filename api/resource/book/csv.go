@@ -0,0 +1,309 @@
+package book
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/scope"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+)
+
+const (
+	// MaxImportRows is the number of books one import may carry.
+	MaxImportRows = 10_000
+
+	maxImportSize = 10 << 20
+	exportBatch   = 500
+	importField   = "file"
+)
+
+// csvColumns are the columns of an export, in order, with the DTO field
+// each is taken from so that the field policy hides it alike. Imports read
+// the same columns by name and ignore id.
+var csvColumns = []struct {
+	name, field string
+}{
+	{"id", "id"},
+	{"title", "title"},
+	{"author", "Author"},
+	{"published_date", "published_date"},
+	{"image_url", "image_url"},
+	{"description", "description"},
+	{"price_amount", "price"},
+	{"price_currency", "price"},
+	{"custom_fields", "custom_fields"},
+}
+
+var (
+	errTooManyRows    = errors.New("book: too many import rows")
+	errMissingColumns = errors.New("book: import lacks required columns")
+)
+
+// ImportDTO reports a successful import.
+type ImportDTO struct {
+	Imported int `json:"imported"`
+}
+
+// Export godoc
+//
+//	@summary        Export books
+//	@description    Download the catalog as CSV, one book per row after a header row. Prices are in the currency's major unit and custom fields are a JSON object. Columns the caller's scopes may not see are left out
+//	@tags           books
+//	@produce        text/csv
+//	@param          format  query   string  false   "csv (default)"
+//	@param          compress    query   string  false   "gzip or zstd, to download books.csv.gz or books.csv.zst"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@router         /books/export [get]
+func (api *API) Export(w http.ResponseWriter, r *http.Request) error {
+	if format := r.URL.Query().Get("format"); format != "" && format != "csv" {
+		return e.RespInvalidCatalogFormat
+	}
+
+	hidden := api.policy.Hidden(Resource, scope.From(r.Context()))
+	var columns []int
+	header := make([]string, 0, len(csvColumns))
+	for i, c := range csvColumns {
+		if !slices.Contains(hidden, c.field) {
+			columns = append(columns, i)
+			header = append(header, c.name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "books.csv"}))
+
+	// Rows are flushed batch by batch; once the first is out the status is
+	// sent, so a failure later on can only cut the file short.
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	err := api.repository.WithContext(r.Context()).Each(exportBatch, func(books Books) error {
+		row := make([]string, len(columns))
+		for _, b := range books {
+			values := csvRow(b)
+			for i, c := range columns {
+				row[i] = values[c]
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return cw.Error()
+	})
+	if err != nil {
+		log.Printf("book export: %s", err)
+		return nil
+	}
+	cw.Flush()
+	return nil
+}
+
+// csvRow returns the values of b in the order of csvColumns.
+func csvRow(b *Book) []string {
+	var amount, currency, fields string
+	if !b.Price.IsZero() {
+		amount, currency = b.Price.Decimal(), b.Price.Currency
+	}
+	if len(b.CustomFields) > 0 {
+		encoded, _ := json.Marshal(b.CustomFields)
+		fields = string(encoded)
+	}
+
+	return []string{
+		idcodec.Encode(b.ID),
+		escapeCell(b.Title),
+		escapeCell(b.Author),
+		b.PublishedDate.Format("2006-01-02"),
+		escapeCell(b.ImageURL),
+		escapeCell(b.Description),
+		amount,
+		currency,
+		fields,
+	}
+}
+
+// escapeCell keeps spreadsheets from taking text for a formula, by quoting
+// it with a leading apostrophe as they do themselves. Imports drop it.
+func escapeCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func unescapeCell(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// Import godoc
+//
+//	@summary        Import books
+//	@description    Create books from a CSV file in the file field of a multipart upload, in the format of the export. The title, author and published_date columns are required and the id column is ignored, so every row creates a book. Each row is validated as a book form; when any is invalid nothing is imported and the errors of each invalid row are returned
+//	@tags           books
+//	@accept         multipart/form-data
+//	@produce        json
+//	@param          file    formData    file    true    "CSV file"
+//	@success        201 {object}    ImportDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        413 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/import [post]
+func (api *API) Import(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	part, err := importPart(r)
+	if err != nil {
+		return e.RespInvalidImportFile
+	}
+	defer part.Close()
+
+	forms, err := readForms(part)
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) || errors.Is(err, errTooManyRows) {
+			return e.RespImportTooLarge
+		}
+
+		return e.RespInvalidCSV
+	}
+
+	// Rows are numbered as lines of the file, the header being row 1.
+	var invalid []e.FieldError
+	for i, form := range forms {
+		msgs, err := api.formErrors(r, form.form)
+		if err != nil {
+			return e.RespDBDataAccessFailure
+		}
+		for _, msg := range append(form.errors, msgs...) {
+			invalid = append(invalid, e.FieldError{Field: fmt.Sprintf("row %d", i+2), Message: msg})
+		}
+	}
+	if len(invalid) > 0 {
+		return e.RespValidationFailed.WithErrors(invalid)
+	}
+
+	books := make(Books, len(forms))
+	for i, form := range forms {
+		books[i] = form.form.ToModel()
+		books[i].ID = uuid.New()
+	}
+
+	if len(books) > 0 {
+		if err := api.repository.WithContext(r.Context()).CreateMany(books); err != nil {
+			return e.RespDBDataInsertFailure
+		}
+	}
+	for _, b := range books {
+		api.publish(r.Context(), EventCreated, b.ID, b)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(&ImportDTO{Imported: len(books)}); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// importPart returns the multipart part carrying the CSV file.
+func importPart(r *http.Request) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == importField {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// rowForm is a row read as a book form, with the problems found reading it
+// that validation cannot catch.
+type rowForm struct {
+	form   *Form
+	errors []string
+}
+
+// readForms reads the rows of a CSV file with a header row naming its
+// columns.
+func readForms(src io.Reader) ([]*rowForm, error) {
+	// Short rows read as empty cells, left for validation to report.
+	cr := csv.NewReader(src)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"title", "author", "published_date"} {
+		if _, ok := index[required]; !ok {
+			return nil, errMissingColumns
+		}
+	}
+
+	var forms []*rowForm
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return forms, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(forms) == MaxImportRows {
+			return nil, errTooManyRows
+		}
+
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return unescapeCell(record[i])
+			}
+			return ""
+		}
+		row := &rowForm{form: &Form{
+			Title:         get("title"),
+			Author:        get("author"),
+			PublishedDate: get("published_date"),
+			ImageURL:      get("image_url"),
+			Description:   get("description"),
+		}}
+		if amount, currency := get("price_amount"), get("price_currency"); amount != "" || currency != "" {
+			row.form.Price = &PriceForm{Amount: json.Number(amount), Currency: currency}
+		}
+		if fields := get("custom_fields"); fields != "" {
+			if err := json.Unmarshal([]byte(fields), &row.form.CustomFields); err != nil {
+				row.errors = append(row.errors, "custom_fields must be a JSON object")
+			}
+		}
+		forms = append(forms, row)
+	}
+}
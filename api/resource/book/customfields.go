@@ -0,0 +1,50 @@
+package book
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const customFieldParamPrefix = "cf."
+
+// CustomFields holds tenant-defined values, stored as a JSONB object.
+type CustomFields map[string]any
+
+func (cf CustomFields) Value() (driver.Value, error) {
+	if cf == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(cf)
+	return string(b), err
+}
+
+func (cf *CustomFields) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*cf = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, cf)
+	case string:
+		return json.Unmarshal([]byte(v), cf)
+	default:
+		return errors.New("book: unsupported CustomFields source")
+	}
+}
+
+// customFieldParams collects the cf.<name>=<value> query params.
+func customFieldParams(q url.Values) map[string]string {
+	var raw map[string]string
+	for k, v := range q {
+		if name, ok := strings.CutPrefix(k, customFieldParamPrefix); ok && name != "" {
+			if raw == nil {
+				raw = make(map[string]string)
+			}
+			raw[name] = v[0]
+		}
+	}
+	return raw
+}
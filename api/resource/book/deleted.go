@@ -0,0 +1,162 @@
+package book
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"hello/api/middleware/dryrun"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/legalhold"
+	"hello/audit"
+	"hello/idcodec"
+)
+
+// Audited actions. Soft deletes and restores can be undone and are noted;
+// purges cannot and are recorded before the response. Updates are only
+// audited when refused under legal hold.
+const (
+	ActionUpdated  = "book.updated"
+	ActionDeleted  = "book.deleted"
+	ActionRestored = "book.restored"
+	ActionPurged   = "book.purged"
+)
+
+// DeletedDTO is a soft-deleted book.
+type DeletedDTO struct {
+	*DTO
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// ListDeleted godoc
+//
+//	@summary        List deleted books
+//	@description    List soft-deleted books, which can be restored or purged, most recently deleted first
+//	@tags           books
+//	@produce        json
+//	@success        200 {array}     DeletedDTO
+//	@failure        500 {object}    err.Problem
+//	@router         /books/deleted [get]
+func (api *API) ListDeleted(w http.ResponseWriter, r *http.Request) error {
+	books, err := api.repository.WithContext(r.Context()).ListDeleted()
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	dtos := make([]*DeletedDTO, len(books))
+	for i, b := range books {
+		dtos[i] = &DeletedDTO{DTO: b.ToDto(), DeletedAt: b.DeletedAt.Time}
+	}
+	if err := encode(w, r, api.policy, dtos); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Restore godoc
+//
+//	@summary        Restore book
+//	@description    Bring back a soft-deleted book
+//	@tags           books
+//	@produce        json
+//	@param          id  path    string  true    "Book ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/restore [post]
+func (api *API) Restore(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	repository := api.repository.WithContext(r.Context())
+	rows, err := repository.Restore(id)
+	if err != nil {
+		return e.RespDBDataUpdateFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	book, err := repository.Read(id)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	audit.Note(r, ActionRestored, id.String(), nil)
+	api.publish(r.Context(), EventRestored, id, book)
+
+	if err := encode(w, r, api.policy, book.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Purge godoc
+//
+//	@summary        Purge book
+//	@description    Permanently delete a book, whether soft-deleted or not, together with its stock, orders, copies, reading progress and annotations. Books with attachments or uploads cannot be purged until those are deleted, nor books under legal hold until it is released
+//	@tags           books
+//	@produce        json
+//	@param          id  path    string  true    "Book ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/purge [delete]
+func (api *API) Purge(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	if err := api.held(r, id, ActionPurged); err != nil {
+		return err
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).Purge(id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return e.RespBookHasAttachments
+		}
+
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	if err := audit.Record(r, ActionPurged, id.String(), nil); err != nil {
+		return e.RespAuditFailure
+	}
+
+	// Read models drop the book on the soft delete already; for a book purged
+	// without one this is their only notice.
+	api.publish(r.Context(), EventDeleted, id, nil)
+	return nil
+}
+
+// held returns RespOnLegalHold when the book is under legal hold, and so
+// can be neither changed nor deleted, noting the attempted action.
+func (api *API) held(r *http.Request, id uuid.UUID, action string) error {
+	held, err := api.holds.WithContext(r.Context()).Held(legalhold.KindBook, id)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if len(held) == 0 {
+		return nil
+	}
+
+	if !dryrun.Requested(r) {
+		audit.Note(r, legalhold.ActionBlocked, id.String(), audit.Details{"kind": legalhold.KindBook, "action": action})
+	}
+	return e.RespOnLegalHold
+}
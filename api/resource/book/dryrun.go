@@ -0,0 +1,40 @@
+package book
+
+import (
+	"bytes"
+	"net/http"
+
+	e "hello/api/resource/common/err"
+)
+
+// preview answers a dry run with the status the write would have had and
+// the book as it would be after it, computed fields included.
+func (api *API) preview(w http.ResponseWriter, r *http.Request, status int, b *Book) error {
+	dtos, err := toDtos(r.Context(), Books{b})
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	var body bytes.Buffer
+	if err := encode(&body, r, api.policy, dtos[0]); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+	return nil
+}
+
+// with returns a copy of b carrying the fields of a form-made next, for a
+// preview of an update: the ID, cover, genres and timestamps are b's.
+func (b *Book) with(next *Book) *Book {
+	c := *b
+	c.Title = next.Title
+	c.Author = next.Author
+	c.PublishedDate = next.PublishedDate
+	c.ImageURL = next.ImageURL
+	c.Description = next.Description
+	c.Price = next.Price
+	c.CustomFields = next.CustomFields
+	return &c
+}
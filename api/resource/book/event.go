@@ -0,0 +1,26 @@
+package book
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+
+	"hello/event"
+)
+
+const (
+	EventCreated = "book.created"
+	EventUpdated = "book.updated"
+	EventDeleted = "book.deleted"
+	// EventRestored carries the book brought back from a soft delete.
+	EventRestored = "book.restored"
+)
+
+// publish notifies subscribers of a committed change. Failures are logged
+// rather than returned because the write itself has already succeeded.
+func (api *API) publish(ctx context.Context, name string, id uuid.UUID, payload any) {
+	if err := api.bus.Publish(ctx, event.New(name, id.String(), payload)); err != nil {
+		log.Printf("event %s for %s: %s", name, id, err)
+	}
+}
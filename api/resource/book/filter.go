@@ -0,0 +1,260 @@
+package book
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"hello/money"
+)
+
+var ErrInvalidFilter = errors.New("book: invalid filter")
+
+// sortable lists the columns books can be sorted by, keyed by the name used
+// in the sort parameter.
+var sortable = map[string]string{
+	"title":          "title",
+	"author":         "author",
+	"published_date": "published_date",
+	"created_at":     "created_at",
+	"price":          "price_amount",
+}
+
+// maxSortFields caps the number of sort keys of a query.
+const maxSortFields = 3
+
+// Sort is one key of a list order.
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// Filter narrows list and facet queries. The zero value matches every book.
+type Filter struct {
+	Author string
+	Title  string
+	Decade int
+
+	// PublishedFrom and PublishedTo bound the publication date, inclusive.
+	PublishedFrom *time.Time
+	PublishedTo   *time.Time
+
+	// Currency matches books priced in that currency. PriceMin and PriceMax
+	// bound the price, inclusive, and need a currency.
+	Currency string
+	PriceMin *money.Money
+	PriceMax *money.Money
+
+	// Genres matches books tagged with any of these genre slugs.
+	Genres []string
+
+	// CustomFields matches books whose custom fields contain these values.
+	CustomFields map[string]any
+
+	// Sort orders list results. Without it results are ordered by title
+	// when Locale is set.
+	Sort []Sort
+
+	// Locale, when set, orders results by title using that locale's collation.
+	Locale string
+}
+
+func NewFilter(q url.Values) (*Filter, error) {
+	f := &Filter{
+		Author: strings.TrimSpace(q.Get("author")),
+		Title:  strings.TrimSpace(q.Get("title")),
+	}
+
+	if d := q.Get("decade"); d != "" {
+		decade, err := strconv.Atoi(d)
+		if err != nil || decade%10 != 0 || decade < 0 {
+			return nil, ErrInvalidFilter
+		}
+		f.Decade = decade
+	}
+
+	var err error
+	if f.PublishedFrom, err = parseDate(q.Get("published_from")); err != nil {
+		return nil, err
+	}
+	if f.PublishedTo, err = parseDate(q.Get("published_to")); err != nil {
+		return nil, err
+	}
+	if f.PublishedFrom != nil && f.PublishedTo != nil && f.PublishedTo.Before(*f.PublishedFrom) {
+		return nil, ErrInvalidFilter
+	}
+
+	if c := strings.ToUpper(strings.TrimSpace(q.Get("currency"))); c != "" {
+		if _, ok := money.Exponent(c); !ok {
+			return nil, ErrInvalidFilter
+		}
+		f.Currency = c
+	}
+	if f.PriceMin, err = parsePrice(q.Get("price_min"), f.Currency); err != nil {
+		return nil, err
+	}
+	if f.PriceMax, err = parsePrice(q.Get("price_max"), f.Currency); err != nil {
+		return nil, err
+	}
+	if f.PriceMin != nil && f.PriceMax != nil && f.PriceMax.Amount < f.PriceMin.Amount {
+		return nil, ErrInvalidFilter
+	}
+
+	for _, slug := range strings.Split(q.Get("genre"), ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			f.Genres = append(f.Genres, slug)
+		}
+	}
+
+	if f.Sort, err = parseSort(q.Get("sort")); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// parsePrice reads a price bound, which is only meaningful in a currency.
+func parsePrice(s, currency string) (*money.Money, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if currency == "" {
+		return nil, ErrInvalidFilter
+	}
+
+	m, err := money.Parse(s, currency)
+	if err != nil {
+		return nil, ErrInvalidFilter
+	}
+	return &m, nil
+}
+
+func parseDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, ErrInvalidFilter
+	}
+	return &t, nil
+}
+
+// parseSort reads a comma-separated list of sortable fields, each with an
+// optional :asc or :desc suffix, e.g. "published_date:desc,title".
+func parseSort(s string) ([]Sort, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(s, ",")
+	if len(fields) > maxSortFields {
+		return nil, ErrInvalidFilter
+	}
+
+	sorts := make([]Sort, 0, len(fields))
+	for _, field := range fields {
+		name, dir, _ := strings.Cut(strings.TrimSpace(field), ":")
+		column, ok := sortable[name]
+		if !ok {
+			return nil, ErrInvalidFilter
+		}
+
+		switch strings.ToLower(dir) {
+		case "", "asc":
+			sorts = append(sorts, Sort{Column: column})
+		case "desc":
+			sorts = append(sorts, Sort{Column: column, Desc: true})
+		default:
+			return nil, ErrInvalidFilter
+		}
+	}
+	return sorts, nil
+}
+
+func (f *Filter) scope(db *gorm.DB) *gorm.DB {
+	if f == nil {
+		return db
+	}
+
+	if f.Author != "" {
+		db = db.Where("LOWER(author) = LOWER(?)", f.Author)
+	}
+	if f.Title != "" {
+		db = db.Where("LOWER(title) LIKE ?", "%"+escapeLike(strings.ToLower(f.Title))+"%")
+	}
+	if f.Decade != 0 {
+		from := time.Date(f.Decade, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db = db.Where("published_date >= ? AND published_date < ?", from, from.AddDate(10, 0, 0))
+	}
+
+	if f.PublishedFrom != nil {
+		db = db.Where("published_date >= ?", *f.PublishedFrom)
+	}
+	if f.PublishedTo != nil {
+		db = db.Where("published_date < ?", f.PublishedTo.AddDate(0, 0, 1))
+	}
+
+	if f.Currency != "" {
+		db = db.Where("price_currency = ?", f.Currency)
+	}
+	if f.PriceMin != nil {
+		db = db.Where("price_amount >= ?", f.PriceMin.Amount)
+	}
+	if f.PriceMax != nil {
+		db = db.Where("price_amount <= ?", f.PriceMax.Amount)
+	}
+
+	if len(f.Genres) > 0 {
+		db = db.Where("id IN (SELECT bg.book_id FROM book_genres bg JOIN genres g ON g.id = bg.genre_id WHERE g.slug IN ?)", f.Genres)
+	}
+
+	for name, v := range f.CustomFields {
+		b, _ := json.Marshal(map[string]any{name: v})
+		db = db.Where("custom_fields @> ?::jsonb", string(b))
+	}
+
+	return db
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// order applies the list order. Titles sort by the locale's collation when
+// a locale is set.
+func (f *Filter) order(db *gorm.DB) *gorm.DB {
+	if f == nil {
+		return db
+	}
+
+	if len(f.Sort) == 0 {
+		if f.Locale != "" {
+			db = db.Order("title COLLATE " + collationName(f.Locale))
+		}
+		return db
+	}
+
+	for _, s := range f.Sort {
+		if s.Column == "title" && f.Locale != "" {
+			expr := "title COLLATE " + collationName(f.Locale)
+			if s.Desc {
+				expr += " DESC"
+			}
+			db = db.Order(expr)
+			continue
+		}
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc})
+	}
+	// Ties keep a stable order across requests.
+	return db.Order("id")
+}
@@ -0,0 +1,144 @@
+package book
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/api/resource/genre"
+	"hello/idcodec"
+	"hello/util/sanitizer"
+	validatorUtil "hello/util/validator"
+)
+
+// AddGenres godoc
+//
+//	@summary        Tag book with genres
+//	@description    Tag a book with genres by slug, keeping those it has
+//	@tags           books
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string      true    "Book ID"
+//	@param          body    body    GenresForm  true    "Genre slugs"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/genres [post]
+func (api *API) AddGenres(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	if err := api.held(r, id, ActionUpdated); err != nil {
+		return err
+	}
+
+	form := &GenresForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	sanitizer.Struct(form)
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	genres, err := api.genres.WithContext(r.Context()).BySlug(form.Genres)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if msgs := unknownGenres(form.Genres, genres); len(msgs) > 0 {
+		return e.RespValidationFailed.WithErrors(e.Messages(msgs))
+	}
+
+	err = api.repository.WithTx(r.Context(), func(repository *Repository) error {
+		if _, err := repository.Read(id); err != nil {
+			return err
+		}
+		return repository.AddGenres(id, genres)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataInsertFailure
+	}
+
+	return api.genresChanged(w, r, id)
+}
+
+// RemoveGenre godoc
+//
+//	@summary        Untag book of genre
+//	@description    Remove a genre from a book
+//	@tags           books
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          slug    path    string  true    "Genre slug"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/genres/{slug} [delete]
+func (api *API) RemoveGenre(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	if err := api.held(r, id, ActionUpdated); err != nil {
+		return err
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).RemoveGenre(id, chi.URLParam(r, "slug"))
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	return api.genresChanged(w, r, id)
+}
+
+// genresChanged publishes the book with its new genres and answers with it.
+func (api *API) genresChanged(w http.ResponseWriter, r *http.Request, id uuid.UUID) error {
+	book, err := api.repository.WithContext(r.Context()).Read(id)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	api.publish(r.Context(), EventUpdated, id, book)
+
+	dtos, err := toDtos(r.Context(), Books{book})
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := encode(w, r, api.policy, dtos[0]); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+func unknownGenres(slugs []string, genres genre.Genres) []string {
+	var msgs []string
+	for _, slug := range slugs {
+		if !slices.ContainsFunc(genres, func(g *genre.Genre) bool { return g.Slug == slug }) {
+			msgs = append(msgs, "unknown genre "+slug)
+		}
+	}
+	return msgs
+}
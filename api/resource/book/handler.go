@@ -2,52 +2,198 @@ package book
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"slices"
 	"time"
 
-	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"hello/api/middleware/apiversion"
+	"hello/api/middleware/dryrun"
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/warning"
 	e "hello/api/resource/common/err"
+	"hello/api/resource/customfield"
+	"hello/api/resource/genre"
+	"hello/api/resource/legalhold"
+	"hello/audit"
+	"hello/event"
+	"hello/fieldpolicy"
+	"hello/idcodec"
+	"hello/money"
+	"hello/util/jsonpatch"
+	"hello/util/sanitizer"
 	validatorUtil "hello/util/validator"
 )
 
 type API struct {
 	repository *Repository
 	validator  *validator.Validate
+	bus        event.Bus
+	collator   *Collator
+
+	// imageChecker is nil when image URL probing is disabled.
+	imageChecker *ImageChecker
+	customFields *customfield.Schema
+	policy       fieldpolicy.Policy
+	holds        *legalhold.Repository
+	genres       *genre.Repository
+	// cache is nil unless set with UseCache.
+	cache *ReadCache
 }
 
-func New(db *gorm.DB, v *validator.Validate) *API {
+// Resource names books in the field policy.
+const Resource = "book"
+
+func New(db *gorm.DB, v *validator.Validate, bus event.Bus, collator *Collator, imageChecker *ImageChecker, policy fieldpolicy.Policy) *API {
 	return &API{
-		repository: NewRepository(db),
-		validator:  v,
+		repository:   NewRepository(db),
+		validator:    v,
+		bus:          bus,
+		collator:     collator,
+		imageChecker: imageChecker,
+		customFields: customfield.NewSchema(db),
+		policy:       policy,
+		holds:        legalhold.NewRepository(db),
+		genres:       genre.NewRepository(db),
+	}
+}
+
+// versions renders book DTOs for clients of earlier API versions.
+var versions = apiversion.Serializers{
+	// Version 2 fixed the casing of author, which version 1 sent as Author.
+	2: apiversion.Rename(map[string]string{"author": "Author"}),
+}
+
+// encode writes v as JSON without the fields the caller's scopes may not see,
+// in the shape of the API version the caller asked for.
+func encode(w io.Writer, r *http.Request, policy fieldpolicy.Policy, v any) error {
+	masked, err := policy.Mask(Resource, scope.From(r.Context()), v)
+	if err != nil {
+		return err
+	}
+	rendered, err := versions.Render(masked, apiversion.From(r.Context()))
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(rendered)
+}
+
+// validate runs struct validation and then checks custom fields against the
+// tenant's schema, returning the problem to answer on failure. When fields
+// are given, only those are validated.
+func (api *API) validate(r *http.Request, form *Form, fields ...string) error {
+	var err error
+	if len(fields) > 0 {
+		err = api.validator.StructPartial(form, fields...)
+	} else {
+		err = api.validator.Struct(form)
+	}
+	if err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	if len(fields) > 0 && !slices.Contains(fields, "CustomFields") {
+		return nil
+	}
+
+	msgs, err := api.customFields.Validate(r.Context(), tenant.From(r.Context()), form.CustomFields)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if len(msgs) > 0 {
+		return e.RespValidationFailed.WithErrors(e.Messages(msgs))
+	}
+
+	return nil
+}
+
+// filter parses the list filter from the query, resolving cf.<name> params
+// against the tenant's custom field schema.
+func (api *API) filter(r *http.Request) (*Filter, error) {
+	q := r.URL.Query()
+
+	filter, err := NewFilter(q)
+	if err != nil {
+		return nil, e.RespInvalidFilter
+	}
+
+	values, msgs, err := api.customFields.FilterValues(r.Context(), tenant.From(r.Context()), customFieldParams(q))
+	if err != nil {
+		return nil, e.RespDBDataAccessFailure
+	}
+	if len(msgs) > 0 {
+		return nil, e.RespInvalidFilter.WithErrors(e.Messages(msgs))
+	}
+	filter.CustomFields = values
+
+	return filter, nil
+}
+
+func (api *API) checkImageURL(r *http.Request, url string) {
+	if api.imageChecker == nil || url == "" {
+		return
+	}
+
+	if problem := api.imageChecker.Check(r.Context(), url); problem != "" {
+		warning.Add(r, warning.CodeImageURLUnreachable, problem)
 	}
 }
 
 func (f *Form) ToModel() *Book {
 	pubDate, _ := time.Parse("2006-01-02", f.PublishedDate)
 
+	var price money.Money
+	if f.Price != nil {
+		price, _ = money.Parse(f.Price.Amount.String(), f.Price.Currency)
+	}
+
 	return &Book{
 		Title:         f.Title,
 		Author:        f.Author,
 		PublishedDate: pubDate,
 		ImageURL:      f.ImageURL,
 		Description:   f.Description,
+		Price:         price,
+		CustomFields:  f.CustomFields,
 	}
 }
 
 func (b *Book) ToDto() *DTO {
-	return &DTO{
-		ID:            b.ID.String(),
+	dto := &DTO{
+		ID:            idcodec.Encode(b.ID),
 		Title:         b.Title,
 		Author:        b.Author,
 		PublishedDate: b.PublishedDate.Format("2006-01-02"),
 		ImageURL:      b.ImageURL,
 		Description:   b.Description,
+		CustomFields:  b.CustomFields,
+		ReviewCount:   b.ReviewCount,
 	}
+	if !b.Price.IsZero() {
+		price := b.Price
+		dto.Price = &price
+	}
+	if b.CoverHash != "" {
+		dto.CoverURL = "/v1/books/" + dto.ID + "/cover"
+	}
+	if b.ReviewCount > 0 {
+		// Averages are shown to two decimals, as more is noise.
+		avg := math.Round(float64(b.RatingSum)/float64(b.ReviewCount)*100) / 100
+		dto.AverageRating = &avg
+	}
+	if len(b.Genres) > 0 {
+		dto.Genres = b.Genres.ToDto()
+	}
+	return dto
 }
 
 func (bs Books) ToDto() []*DTO {
@@ -59,6 +205,24 @@ func (bs Books) ToDto() []*DTO {
 	return dtos
 }
 
+func (fc FacetCounts) ToDto() []*FacetCountDTO {
+	dtos := make([]*FacetCountDTO, len(fc))
+	for i, v := range fc {
+		dtos[i] = &FacetCountDTO{Value: v.Value, Count: v.Count}
+	}
+
+	return dtos
+}
+
+func (f *Facets) ToDto() *FacetsDTO {
+	return &FacetsDTO{
+		Author:       f.Author.ToDto(),
+		Decade:       f.Decade.ToDto(),
+		Genre:        f.Genre.ToDto(),
+		Availability: f.Availability.ToDto(),
+	}
+}
+
 // List godoc
 //
 //	@summary        List books
@@ -66,25 +230,82 @@ func (bs Books) ToDto() []*DTO {
 //	@tags           books
 //	@accept         json
 //	@produce        json
+//	@param          author  query   string  false   "Author name"
+//	@param          title   query   string  false   "Title substring"
+//	@param          decade  query   int     false   "Publication decade, e.g. 1990"
+//	@param          published_from  query   string  false   "Published on or after, YYYY-MM-DD"
+//	@param          published_to    query   string  false   "Published on or before, YYYY-MM-DD"
+//	@param          currency        query   string  false   "ISO 4217 price currency, e.g. EUR"
+//	@param          price_min       query   string  false   "Minimum price in the currency, e.g. 9.99"
+//	@param          price_max       query   string  false   "Maximum price in the currency"
+//	@param          genre   query   string  false   "Comma-separated genre slugs; books tagged with any of them match"
+//	@param          sort    query   string  false   "Comma-separated title, author, published_date, created_at or price, each optionally :asc or :desc, e.g. published_date:desc"
+//	@param          cf.name query   string  false   "Indexed custom field value, e.g. cf.shelf=A3"
+//	@param          locale  query   string  false   "Sort titles by this locale's collation (defaults from Accept-Language)"
+//	@param          If-None-Match   header  string  false   "ETag of a previous response; 304 if the list is unchanged"
 //	@success        200 {array}     DTO
-//	@failure        500 {object}    err.Error
+//	@success        304
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
 //	@router         /books [get]
-func (api *API) List(w http.ResponseWriter, r *http.Request) {
-	books, err := api.repository.List()
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	filter, err := api.filter(r)
 	if err != nil {
-		e.ServerError(w, e.RespDBDataAccessFailure)
-		return
+		return err
 	}
 
-	if len(books) == 0 {
-		fmt.Fprint(w, "[]")
-		return
+	filter.Locale, err = api.collator.Resolve(r)
+	if err != nil {
+		return e.RespUnsupportedLocale
+	}
+	if filter.Locale != "" {
+		w.Header().Set("Content-Language", filter.Locale)
 	}
 
-	if err := json.NewEncoder(w).Encode(books.ToDto()); err != nil {
-		e.ServerError(w, e.RespJSONEncodeFailure)
-		return
+	books, err := api.list(r.Context(), filter)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	dtos, err := toDtos(r.Context(), books)
+	if err != nil {
+		return e.RespDBDataAccessFailure
 	}
+
+	return api.writeTagged(w, r, dtos)
+}
+
+// Facets godoc
+//
+//	@summary        Book facets
+//	@description    Counts per author, decade, genre and availability (available or on_loan) for books matching the filter
+//	@tags           books
+//	@accept         json
+//	@produce        json
+//	@param          author  query   string  false   "Author name"
+//	@param          title   query   string  false   "Title substring"
+//	@param          decade  query   int     false   "Publication decade, e.g. 1990"
+//	@param          genre   query   string  false   "Comma-separated genre slugs; books tagged with any of them match"
+//	@param          cf.name query   string  false   "Indexed custom field value, e.g. cf.shelf=A3"
+//	@success        200 {object}    FacetsDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/facets [get]
+func (api *API) Facets(w http.ResponseWriter, r *http.Request) error {
+	filter, err := api.filter(r)
+	if err != nil {
+		return err
+	}
+
+	facets, err := api.repository.WithContext(r.Context()).Facets(filter)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(facets.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
 }
 
 // Create godoc
@@ -95,39 +316,43 @@ func (api *API) List(w http.ResponseWriter, r *http.Request) {
 //	@accept         json
 //	@produce        json
 //	@param          body    body    Form    true    "Book form"
+//	@param          dry_run query   bool    false   "Run validation and business rules and answer as the write would, without committing it; also accepted as a Dry-Run header"
 //	@success        201
-//	@failure        400 {object}    err.Error
-//	@failure        422 {object}    err.Errors
-//	@failure        500 {object}    err.Error
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
 //	@router         /books [post]
-func (api *API) Create(w http.ResponseWriter, r *http.Request) {
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
 	form := &Form{}
 	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
-		e.ServerError(w, e.RespJSONDecodeFailure)
-		return
+		return e.RespJSONDecodeFailure
 	}
 
-	if err := api.validator.Struct(form); err != nil {
-		respBody, err := json.Marshal(validatorUtil.ToErrResponse(err))
-		if err != nil {
-			e.ServerError(w, e.RespJSONEncodeFailure)
-			return
-		}
-
-		e.ValidationErrors(w, respBody)
-		return
+	sanitizer.Struct(form)
+	if err := api.validate(r, form); err != nil {
+		return err
 	}
 
+	api.checkImageURL(r, form.ImageURL)
+
 	newBook := form.ToModel()
 	newBook.ID = uuid.New()
 
-	_, err := api.repository.Create(newBook)
+	if dryrun.Requested(r) {
+		newBook.CreatedAt = time.Now()
+		newBook.UpdatedAt = newBook.CreatedAt
+		return api.preview(w, r, http.StatusCreated, newBook)
+	}
+
+	_, err := api.repository.WithContext(r.Context()).Create(newBook)
 	if err != nil {
-		e.ServerError(w, e.RespDBDataInsertFailure)
-		return
+		return e.RespDBDataInsertFailure
 	}
 
+	api.publish(r.Context(), EventCreated, newBook.ID, newBook)
+
 	w.WriteHeader(http.StatusCreated)
+	return nil
 }
 
 // Read godoc
@@ -138,34 +363,46 @@ func (api *API) Create(w http.ResponseWriter, r *http.Request) {
 //	@accept         json
 //	@produce        json
 //	@param          id	path        string  true    "Book ID"
+//	@param          If-None-Match   header  string  false   "ETag of a previous response; 304 if the book is unchanged"
+//	@param          as_of   query   string  false   "RFC 3339 timestamp; read the book as it was then, 404 if it did not exist or its history does not reach back that far"
 //	@success        200 {object}    DTO
-//	@failure        400 {object}    err.Error
+//	@success        301 {object}    MovedDTO
+//	@success        304
+//	@failure        400 {object}    err.Problem
 //	@failure        404
-//	@failure        500 {object}    err.Error
+//	@failure        500 {object}    err.Problem
 //	@router         /books/{id} [get]
-func (api *API) Read(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(chi.URLParam(r, "id"))
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
 	if err != nil {
-		e.BadRequest(w, e.RespInvalidURLParamID)
-		return
+		return e.RespInvalidURLParamID
 	}
 
-	book, err := api.repository.Read(id)
+	asOf, past, err := parseAsOf(r.URL.Query().Get("as_of"))
+	if err != nil {
+		return e.RespInvalidAsOf
+	}
+
+	var book *Book
+	if past {
+		book, err = api.repository.WithContext(r.Context()).ReadAsOf(id, asOf)
+	} else {
+		book, err = api.read(r.Context(), id)
+	}
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			w.WriteHeader(http.StatusNotFound)
-			return
+			return e.RespNotFound
 		}
 
-		e.ServerError(w, e.RespDBDataAccessFailure)
-		return
+		return e.RespDBDataAccessFailure
 	}
 
-	dto := book.ToDto()
-	if err := json.NewEncoder(w).Encode(dto); err != nil {
-		e.ServerError(w, e.RespJSONEncodeFailure)
-		return
+	dtos, err := toDtos(r.Context(), Books{book})
+	if err != nil {
+		return e.RespDBDataAccessFailure
 	}
+
+	return api.writeTagged(w, r, dtos[0])
 }
 
 // Update godoc
@@ -177,48 +414,188 @@ func (api *API) Read(w http.ResponseWriter, r *http.Request) {
 //	@produce        json
 //	@param          id      path    string  true    "Book ID"
 //	@param          body    body    Form    true    "Book form"
+//	@param          If-Match    header  string  false   "ETag the book was read with; 412 if it has changed since"
+//	@param          dry_run query   bool    false   "Run validation and business rules and answer as the write would, without committing it; also accepted as a Dry-Run header"
 //	@success        200
-//	@failure        400 {object}    err.Error
+//	@failure        400 {object}    err.Problem
 //	@failure        404
-//	@failure        422 {object}    err.Errors
-//	@failure        500 {object}    err.Error
+//	@failure        409 {object}    err.Problem
+//	@failure        412 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
 //	@router         /books/{id} [put]
-func (api *API) Update(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(chi.URLParam(r, "id"))
+func (api *API) Update(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
 	if err != nil {
-		e.BadRequest(w, e.RespInvalidURLParamID)
-		return
+		return e.RespInvalidURLParamID
+	}
+
+	if err := api.held(r, id, ActionUpdated); err != nil {
+		return err
 	}
 
 	form := &Form{}
 	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
-		e.ServerError(w, e.RespJSONDecodeFailure)
-		return
+		return e.RespJSONDecodeFailure
 	}
 
-	if err := api.validator.Struct(form); err != nil {
-		respBody, err := json.Marshal(validatorUtil.ToErrResponse(err))
-		if err != nil {
-			e.ServerError(w, e.RespJSONEncodeFailure)
-			return
-		}
+	sanitizer.Struct(form)
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
 
-		e.ValidationErrors(w, respBody)
-		return
+	repository, err := api.precondition(r, id)
+	if err != nil {
+		return err
 	}
 
+	api.checkImageURL(r, form.ImageURL)
+
 	book := form.ToModel()
 	book.ID = id
 
-	rows, err := api.repository.Update(book)
+	if dryrun.Requested(r) {
+		current, err := repository.Read(id)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return notWritten(r)
+			}
+
+			return e.RespDBDataAccessFailure
+		}
+
+		return api.preview(w, r, http.StatusOK, current.with(book))
+	}
+
+	rows, err := repository.Update(book)
 	if err != nil {
-		e.ServerError(w, e.RespDBDataUpdateFailure)
-		return
+		return e.RespDBDataUpdateFailure
 	}
 	if rows == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		return
+		return notWritten(r)
 	}
+
+	api.publish(r.Context(), EventUpdated, book.ID, book)
+	return nil
+}
+
+// Patch godoc
+//
+//	@summary        Patch book
+//	@description    Update only the fields present in a JSON Merge Patch (RFC 7386), where null clears a field, or changed by a JSON Patch (RFC 6902), whose paths must point to book form members or into price and custom_fields. A failed test operation, a missing path or a book under legal hold answers 409
+//	@tags           books
+//	@accept         json
+//	@accept         application/merge-patch+json
+//	@accept         application/json-patch+json
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          body    body    Form    true    "Partial book form"
+//	@param          If-Match    header  string  false   "ETag the book was read with; 412 if it has changed since"
+//	@param          dry_run query   bool    false   "Run validation and business rules and answer as the write would, without committing it; also accepted as a Dry-Run header"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        412 {object}    err.Problem
+//	@failure        415 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id} [patch]
+func (api *API) Patch(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	if err := api.held(r, id, ActionUpdated); err != nil {
+		return err
+	}
+
+	var jsonPatch bool
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/json" && mediaType != "application/merge-patch+json" && mediaType != "application/json-patch+json") {
+			return e.RespUnsupportedMediaType
+		}
+		jsonPatch = mediaType == "application/json-patch+json"
+	}
+
+	var patch map[string]json.RawMessage
+	var ops jsonpatch.Patch
+	if jsonPatch {
+		if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+			return e.RespJSONDecodeFailure
+		}
+		if msgs := checkJSONPatch(ops); len(msgs) > 0 {
+			return e.RespValidationFailed.WithErrors(e.Messages(msgs))
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	repository, err := api.precondition(r, id)
+	if err != nil {
+		return err
+	}
+
+	book, err := api.repository.WithContext(r.Context()).Read(id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataAccessFailure
+	}
+
+	form := book.ToForm()
+	var fields []string
+	if jsonPatch {
+		fields, err = applyJSONPatch(form, ops)
+	} else {
+		fields, err = applyPatch(form, patch)
+	}
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) || errors.Is(err, jsonpatch.ErrPath) {
+			return e.RespPatchConflict
+		}
+
+		return e.RespJSONDecodeFailure
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	sanitizer.Struct(form)
+	if err := api.validate(r, form, fields...); err != nil {
+		return err
+	}
+
+	if slices.Contains(fields, "ImageURL") {
+		api.checkImageURL(r, form.ImageURL)
+	}
+
+	next := form.ToModel()
+	next.ID = id
+
+	if dryrun.Requested(r) {
+		return api.preview(w, r, http.StatusOK, book.with(next))
+	}
+
+	columns := book.changes(next)
+	if len(columns) == 0 {
+		return nil
+	}
+
+	rows, err := repository.Patch(id, columns)
+	if err != nil {
+		return e.RespDBDataUpdateFailure
+	}
+	if rows == 0 {
+		return notWritten(r)
+	}
+
+	api.publish(r.Context(), EventUpdated, id, next)
+	return nil
 }
 
 // Delete godoc
@@ -229,25 +606,52 @@ func (api *API) Update(w http.ResponseWriter, r *http.Request) {
 //	@accept         json
 //	@produce        json
 //	@param          id  path    string  true    "Book ID"
+//	@param          If-Match    header  string  false   "ETag the book was read with; 412 if it has changed since"
+//	@param          dry_run query   bool    false   "Run validation and business rules and answer as the write would, without committing it; also accepted as a Dry-Run header"
 //	@success        200
-//	@failure        400 {object}    err.Error
+//	@failure        400 {object}    err.Problem
 //	@failure        404
-//	@failure        500 {object}    err.Error
+//	@failure        412 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
 //	@router         /books/{id} [delete]
-func (api *API) Delete(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(chi.URLParam(r, "id"))
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
 	if err != nil {
-		e.BadRequest(w, e.RespInvalidURLParamID)
-		return
+		return e.RespInvalidURLParamID
 	}
 
-	rows, err := api.repository.Delete(id)
+	if err := api.held(r, id, ActionDeleted); err != nil {
+		return err
+	}
+
+	repository, err := api.precondition(r, id)
 	if err != nil {
-		e.BadRequest(w, e.RespDBDataRemoveFailure)
-		return
+		return err
+	}
+
+	if dryrun.Requested(r) {
+		book, err := repository.Read(id)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return notWritten(r)
+			}
+
+			return e.RespDBDataAccessFailure
+		}
+
+		return api.preview(w, r, http.StatusOK, book)
+	}
+
+	rows, err := repository.Delete(id)
+	if err != nil {
+		return e.RespDBDataRemoveFailure
 	}
 	if rows == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		return
+		return notWritten(r)
 	}
+
+	audit.Note(r, ActionDeleted, id.String(), nil)
+	api.publish(r.Context(), EventDeleted, id, nil)
+	return nil
 }
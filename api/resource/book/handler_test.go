@@ -0,0 +1,1005 @@
+package book_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/apiversion"
+	"hello/api/middleware/dryrun"
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	"hello/api/resource/annotation"
+	"hello/api/resource/attachment"
+	"hello/api/resource/blob"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/customfield"
+	"hello/api/resource/genre"
+	"hello/api/resource/interaction"
+	"hello/api/resource/legalhold"
+	"hello/api/resource/loan"
+	"hello/api/resource/order"
+	"hello/api/resource/progress"
+	"hello/api/resource/review"
+	"hello/cache"
+	"hello/event"
+	"hello/fieldpolicy"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Patch(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &legalhold.Hold{})
+
+	for name, typ := range map[string]string{"shelf": customfield.TypeString, "signed": customfield.TypeBoolean, "copies": customfield.TypeNumber} {
+		testUtil.NoError(t, db.Create(&customfield.Definition{ID: uuid.New(), TenantID: tenant.Default, Name: name, Type: typ}).Error)
+	}
+
+	repo := book.NewRepository(db)
+	id := uuid.New()
+	_, err := repo.Create(&book.Book{
+		ID:            id,
+		Title:         "Dune",
+		Author:        "Frank Herbert",
+		PublishedDate: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC),
+		ImageURL:      "https://example.com/dune.png",
+		Description:   "Spice",
+		CustomFields:  book.CustomFields{"shelf": "A", "signed": true},
+	})
+	testUtil.NoError(t, err)
+
+	var updated []event.Event
+	bus := event.NewBus()
+	bus.Subscribe(book.EventUpdated, func(_ context.Context, ev event.Event) error {
+		updated = append(updated, ev)
+		return nil
+	})
+
+	api := book.New(db, validatorUtil.New(), bus, book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Patch("/books/{id}", e.Handle(api.Patch))
+
+	tests := []struct {
+		name        string
+		id          uuid.UUID
+		contentType string
+		body        string
+		status      int
+	}{
+		{"title only", id, "application/merge-patch+json", `{"title": "Dune Messiah"}`, http.StatusOK},
+		{"clear description", id, "application/json", `{"description": null}`, http.StatusOK},
+		{"merge custom fields", id, "", `{"custom_fields": {"shelf": null, "copies": 2}}`, http.StatusOK},
+		{"unchanged", id, "", `{"author": "Frank Herbert", "unknown": 1}`, http.StatusOK},
+		{"set price", id, "", `{"price": {"amount": "12.50", "currency": "EUR"}}`, http.StatusOK},
+		{"merge price amount", id, "", `{"price": {"amount": 9}}`, http.StatusOK},
+		{"too many decimals", id, "", `{"price": {"amount": "9.999"}}`, http.StatusUnprocessableEntity},
+		{"negative price", id, "", `{"price": {"amount": "-1"}}`, http.StatusUnprocessableEntity},
+		{"amount invalid in new currency", id, "", `{"price": {"amount": "9.50", "currency": "JPY"}}`, http.StatusUnprocessableEntity},
+		{"unknown currency", id, "", `{"price": {"currency": "ABC"}}`, http.StatusUnprocessableEntity},
+		{"invalid date", id, "", `{"published_date": "1965"}`, http.StatusUnprocessableEntity},
+		{"required field cleared", id, "", `{"title": null}`, http.StatusUnprocessableEntity},
+		{"wrong type", id, "", `{"title": 5}`, http.StatusInternalServerError},
+		{"not an object", id, "", `["title"]`, http.StatusInternalServerError},
+		{"unsupported media type", id, "text/plain", `{"title": "x"}`, http.StatusUnsupportedMediaType},
+		{"missing book", uuid.New(), "", `{"title": "x"}`, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/books/"+tc.id.String(), strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			testUtil.Equal(t, w.Code, tc.status)
+		})
+	}
+
+	b, err := repo.Read(id)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, b.Title, "Dune Messiah")
+	testUtil.Equal(t, b.Author, "Frank Herbert")
+	testUtil.Equal(t, b.ImageURL, "https://example.com/dune.png")
+	testUtil.Equal(t, b.Description, "")
+	testUtil.Equal(t, len(b.CustomFields), 2)
+	testUtil.Equal(t, b.CustomFields["signed"], any(true))
+	testUtil.Equal(t, b.CustomFields["copies"], any(float64(2)))
+	testUtil.Equal(t, b.Price.String(), "9.00 EUR")
+
+	// The unchanged patch writes nothing and publishes nothing.
+	testUtil.Equal(t, len(updated), 5)
+}
+
+func TestAPI_DeletedBooks(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &book.Revision{}, &blob.Blob{}, &legalhold.Hold{})
+
+	repo := book.NewRepository(db)
+	dune := &book.Book{ID: uuid.New(), Title: "Dune", CoverHash: "c0ffee"}
+	emma := &book.Book{ID: uuid.New(), Title: "Emma"}
+	for _, b := range []*book.Book{dune, emma} {
+		_, err := repo.Create(b)
+		testUtil.NoError(t, err)
+	}
+	testUtil.NoError(t, db.Create(&blob.Blob{Hash: "c0ffee", RefCount: 1}).Error)
+
+	var published []string
+	bus := event.NewBus()
+	for _, name := range []string{book.EventRestored, book.EventDeleted} {
+		bus.Subscribe(name, func(_ context.Context, ev event.Event) error {
+			published = append(published, ev.Name)
+			return nil
+		})
+	}
+
+	api := book.New(db, validatorUtil.New(), bus, book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Get("/books/deleted", e.Handle(api.ListDeleted))
+	r.Post("/books/{id}/restore", e.Handle(api.Restore))
+	r.Put("/books/{id}", e.Handle(api.Update))
+	r.Patch("/books/{id}", e.Handle(api.Patch))
+	r.Delete("/books/{id}", e.Handle(api.Delete))
+	r.Delete("/books/{id}/purge", e.Handle(api.Purge))
+
+	serveBody := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		return serveBody(method, target, "")
+	}
+	deleted := func() []*book.DeletedDTO {
+		var dtos []*book.DeletedDTO
+		testUtil.NoError(t, json.Unmarshal(serve(http.MethodGet, "/books/deleted").Body.Bytes(), &dtos))
+		return dtos
+	}
+
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/books/"+emma.ID.String()+"/restore").Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, "/books/"+emma.ID.String()).Code)
+	dtos := deleted()
+	testUtil.Equal(t, 1, len(dtos))
+	testUtil.Equal(t, emma.ID.String(), dtos[0].ID)
+	testUtil.Equal(t, false, dtos[0].DeletedAt.IsZero())
+
+	w := serve(http.MethodPost, "/books/"+emma.ID.String()+"/restore")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"title":"Emma"`))
+	testUtil.Equal(t, 0, len(deleted()))
+	_, err := repo.Read(emma.ID)
+	testUtil.NoError(t, err)
+
+	// A book under legal hold can be neither changed, deleted nor purged.
+	hold := &legalhold.Hold{Kind: legalhold.KindBook, TargetID: dune.ID, Reason: "litigation"}
+	testUtil.NoError(t, legalhold.NewRepository(db).Place(hold))
+	testUtil.Equal(t, http.StatusConflict, serveBody(http.MethodPut, "/books/"+dune.ID.String(),
+		`{"title": "Dune Messiah", "author": "Frank Herbert", "published_date": "1969-10-15", "image_url": "https://example.com/dune.png"}`).Code)
+	testUtil.Equal(t, http.StatusConflict, serveBody(http.MethodPatch, "/books/"+dune.ID.String(), `{"title": "Dune Messiah"}`).Code)
+	b, err := repo.Read(dune.ID)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "Dune", b.Title)
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/books/"+dune.ID.String()).Code)
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/books/"+dune.ID.String()+"/purge").Code)
+	_, err = legalhold.NewRepository(db).Release(legalhold.KindBook, dune.ID)
+	testUtil.NoError(t, err)
+
+	// Purging works on live and soft-deleted books alike, and releases the
+	// cover.
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, "/books/"+dune.ID.String()+"/purge").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/books/"+dune.ID.String()+"/purge").Code)
+	var n int64
+	testUtil.NoError(t, db.Unscoped().Model(&book.Book{}).Where("id = ?", dune.ID).Count(&n).Error)
+	testUtil.Equal(t, int64(0), n)
+	cover := &blob.Blob{}
+	testUtil.NoError(t, db.Where("hash = ?", "c0ffee").First(cover).Error)
+	testUtil.Equal(t, int64(0), cover.RefCount)
+
+	testUtil.Equal(t, "book.deleted book.restored book.deleted", strings.Join(published, " "))
+}
+
+func TestAPI_Bulk(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &legalhold.Hold{})
+
+	created := 0
+	bus := event.NewBus()
+	bus.Subscribe(book.EventCreated, func(_ context.Context, _ event.Event) error {
+		created++
+		return nil
+	})
+
+	api := book.New(db, validatorUtil.New(), bus, book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Post("/books/bulk", e.Handle(api.BulkCreate))
+	r.Delete("/books/bulk", e.Handle(api.BulkDelete))
+
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/books/bulk", strings.NewReader(body)))
+		return w
+	}
+	results := func(w *httptest.ResponseRecorder) []*book.BulkResultDTO {
+		var dtos []*book.BulkResultDTO
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dtos))
+		return dtos
+	}
+	count := func() int64 {
+		var n int64
+		testUtil.NoError(t, db.Model(&book.Book{}).Count(&n).Error)
+		return n
+	}
+
+	dune := `{"title": "Dune", "author": "Frank Herbert", "published_date": "1965-08-01", "image_url": "https://example.com/dune.png"}`
+	emma := `{"title": "Emma", "author": "Jane Austen", "published_date": "1815-12-23", "image_url": "https://example.com/emma.png"}`
+
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodPost, `[]`).Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodPost, `[{"title": "Dune"`).Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodDelete, `{"ids": []}`).Code)
+
+	// One invalid form fails the whole batch.
+	w := serve(http.MethodPost, `[`+dune+`, {"title": "No author"}, null]`)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var problem e.Problem
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	testUtil.Equal(t, "[1]", problem.Errors[0].Field)
+	testUtil.Equal(t, "[2]", problem.Errors[len(problem.Errors)-1].Field)
+	testUtil.Equal(t, int64(0), count())
+
+	w = serve(http.MethodPost, `[`+dune+`, `+emma+`]`)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	done := results(w)
+	testUtil.Equal(t, 2, len(done))
+	testUtil.Equal(t, http.StatusCreated, done[1].Status)
+	testUtil.Equal(t, int64(2), count())
+	testUtil.Equal(t, 2, created)
+
+	held, err := uuid.Parse(done[1].ID)
+	testUtil.NoError(t, err)
+	testUtil.NoError(t, legalhold.NewRepository(db).Place(&legalhold.Hold{Kind: legalhold.KindBook, TargetID: held, Reason: "audit"}))
+
+	w = serve(http.MethodDelete, `["`+done[0].ID+`", "nope", "`+uuid.NewString()+`", "`+done[1].ID+`"]`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	deleted := results(w)
+	testUtil.Equal(t, http.StatusOK, deleted[0].Status)
+	testUtil.Equal(t, http.StatusBadRequest, deleted[1].Status)
+	testUtil.Equal(t, http.StatusNotFound, deleted[2].Status)
+	testUtil.Equal(t, http.StatusConflict, deleted[3].Status)
+	testUtil.Equal(t, int64(1), count())
+}
+
+func TestAPI_BulkValidate(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &legalhold.Hold{})
+
+	api := book.New(db, validatorUtil.New(), event.NewBus(), book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Post("/books/validate", e.Handle(api.BulkValidate))
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books/validate", strings.NewReader(body)))
+		return w
+	}
+
+	testUtil.Equal(t, http.StatusBadRequest, serve(`[]`).Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(`{`).Code)
+
+	dune := `{"title": "Dune", "author": "Frank Herbert", "published_date": "1965-08-01", "image_url": "https://example.com/dune.png"}`
+	w := serve(`[` + dune + `, {"title": "No author", "published_date": "1965-08-01", "image_url": "https://example.com/x.png"}, null]`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var results []*book.ValidationResultDTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	testUtil.Equal(t, 3, len(results))
+	testUtil.Equal(t, true, results[0].Valid)
+	testUtil.Equal(t, 0, len(results[0].Errors))
+	testUtil.Equal(t, false, results[1].Valid)
+	testUtil.Equal(t, 1, len(results[1].Errors))
+	testUtil.Equal(t, "author", results[1].Errors[0].Field)
+	testUtil.Equal(t, 2, results[2].Index)
+	testUtil.Equal(t, false, results[2].Valid)
+
+	// Nothing is created.
+	var n int64
+	testUtil.NoError(t, db.Model(&book.Book{}).Count(&n).Error)
+	testUtil.Equal(t, int64(0), n)
+}
+
+func TestAPI_Genres(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &genre.Genre{}, &legalhold.Hold{})
+
+	dune := &book.Book{ID: uuid.New(), Title: "Dune"}
+	emma := &book.Book{ID: uuid.New(), Title: "Emma"}
+	for _, b := range []*book.Book{dune, emma} {
+		_, err := book.NewRepository(db).Create(b)
+		testUtil.NoError(t, err)
+	}
+
+	v := validatorUtil.New()
+	bus := event.NewBus()
+	var deleted *genre.DeletedDTO
+	bus.Subscribe(genre.EventDeleted, func(_ context.Context, e event.Event) error {
+		deleted = e.Payload.(*genre.DeletedDTO)
+		return nil
+	})
+	api := book.New(db, v, bus, book.NewCollator(nil), nil, nil)
+	genreAPI := genre.New(db, v, bus)
+	r := chi.NewRouter()
+	r.Use(e.Middleware(validatorUtil.Mapper))
+	r.Get("/books", e.Handle(api.List))
+	r.Post("/books/{id}/genres", e.Handle(api.AddGenres))
+	r.Delete("/books/{id}/genres/{slug}", e.Handle(api.RemoveGenre))
+	r.Post("/genres", e.Handle(genreAPI.Create))
+	r.Delete("/genres/{slug}", e.Handle(genreAPI.Delete))
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	list := func(query string) []*book.DTO {
+		var dtos []*book.DTO
+		testUtil.NoError(t, json.Unmarshal(serve(http.MethodGet, "/books"+query, "").Body.Bytes(), &dtos))
+		return dtos
+	}
+
+	testUtil.Equal(t, http.StatusCreated, serve(http.MethodPost, "/genres", `{"slug": "science_fiction", "name": "Science fiction"}`).Code)
+	testUtil.Equal(t, http.StatusCreated, serve(http.MethodPost, "/genres", `{"slug": "classic", "name": "Classic"}`).Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, "/genres", `{"slug": "Not A Slug", "name": "x"}`).Code)
+
+	target := "/books/" + dune.ID.String() + "/genres"
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, target, `{"genres": ["classic", "romance"]}`).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/books/"+uuid.NewString()+"/genres", `{"genres": ["classic"]}`).Code)
+
+	w := serve(http.MethodPost, target, `{"genres": ["science_fiction", "classic"]}`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var dto book.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+	testUtil.Equal(t, 2, len(dto.Genres))
+	testUtil.Equal(t, "classic", dto.Genres[0].Slug)
+	// Adding a genre again keeps the book's genres as they are.
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPost, target, `{"genres": ["classic"]}`).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPost, "/books/"+emma.ID.String()+"/genres", `{"genres": ["classic"]}`).Code)
+
+	testUtil.Equal(t, 2, len(list("?genre=classic")))
+	scifi := list("?genre=science_fiction")
+	testUtil.Equal(t, 1, len(scifi))
+	testUtil.Equal(t, "Dune", scifi[0].Title)
+	testUtil.Equal(t, 2, len(scifi[0].Genres))
+	testUtil.Equal(t, 0, len(list("?genre=romance")))
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, target+"/science_fiction", "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, target+"/science_fiction", "").Code)
+	testUtil.Equal(t, 0, len(list("?genre=science_fiction")))
+
+	// Deleting a genre untags its books, and tells which.
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, "/genres/classic", "").Code)
+	testUtil.Equal(t, 0, len(list("?genre=classic")))
+	testUtil.Equal(t, 0, len(list("")[0].Genres))
+	testUtil.Equal(t, "classic", deleted.Slug)
+	testUtil.Equal(t, 2, len(deleted.BookIDs))
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/genres/classic", "").Code)
+}
+
+func TestAPI_ImportExport(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &legalhold.Hold{})
+	testUtil.NoError(t, db.Create(&customfield.Definition{ID: uuid.New(), TenantID: tenant.Default, Name: "shelf", Type: customfield.TypeString}).Error)
+
+	policy := fieldpolicy.Policy{book.Resource: {"custom_fields": {"staff"}}}
+	api := book.New(db, validatorUtil.New(), event.NewBus(), book.NewCollator(nil), nil, policy)
+	r := chi.NewRouter()
+	r.Use(scope.Middleware)
+	r.Get("/books/export", e.Handle(api.Export))
+	r.Post("/books/import", e.Handle(api.Import))
+
+	upload := func(content string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		part, err := mw.CreateFormFile("file", "books.csv")
+		testUtil.NoError(t, err)
+		part.Write([]byte(content))
+		testUtil.NoError(t, mw.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/books/import", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	export := func(scopes string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/books/export?format=csv", nil)
+		req.Header.Set(scope.Header, scopes)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	count := func() int64 {
+		var n int64
+		testUtil.NoError(t, db.Model(&book.Book{}).Count(&n).Error)
+		return n
+	}
+
+	testUtil.Equal(t, http.StatusBadRequest, upload("name\nDune\n").Code)
+
+	w := upload("title,author,published_date,image_url,custom_fields\nDune,Frank Herbert,1965-08-01,https://example.com/dune.png,\nEmma,Jane Austen,not a date,https://example.com/emma.png,\n,Nobody,1900-01-01,https://example.com/x.png,{oops\n")
+	testUtil.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var problem e.Problem
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	testUtil.Equal(t, "validation_failed", problem.Code)
+	testUtil.Equal(t, 3, len(problem.Errors))
+	testUtil.Equal(t, "row 3", problem.Errors[0].Field)
+	testUtil.Equal(t, "row 4", problem.Errors[1].Field)
+	testUtil.Equal(t, "custom_fields must be a JSON object", problem.Errors[1].Message)
+	testUtil.Equal(t, "row 4", problem.Errors[2].Field)
+	testUtil.Equal(t, int64(0), count())
+
+	csv := "title,author,published_date,image_url,description,price_amount,price_currency,custom_fields\n" +
+		`Dune,Frank Herbert,1965-08-01,https://example.com/dune.png,'=SUM(A1),12.50,EUR,"{""shelf"": ""sf""}"` + "\n"
+	w = upload(csv)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	testUtil.Equal(t, `{"imported":1}`, strings.TrimSpace(w.Body.String()))
+	imported := &book.Book{}
+	testUtil.NoError(t, db.First(imported).Error)
+	testUtil.Equal(t, "=SUM(A1)", imported.Description)
+	testUtil.Equal(t, int64(1250), imported.Price.Amount)
+
+	w = export("staff")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, `attachment; filename=books.csv`, w.Header().Get("Content-Disposition"))
+	testUtil.Equal(t, "id,title,author,published_date,image_url,description,price_amount,price_currency,custom_fields\n"+
+		imported.ID.String()+`,Dune,Frank Herbert,1965-08-01,https://example.com/dune.png,'=SUM(A1),12.50,EUR,"{""shelf"":""sf""}"`+"\n", w.Body.String())
+
+	// Without the staff scope custom fields are left out; the export can be
+	// imported again.
+	w = export("")
+	testUtil.Equal(t, false, strings.Contains(w.Body.String(), "custom_fields"))
+	testUtil.Equal(t, http.StatusCreated, upload(w.Body.String()).Code)
+	testUtil.Equal(t, int64(2), count())
+}
+
+func TestAPI_Merge(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &book.Redirect{}, &blob.Blob{}, &legalhold.Hold{}, &order.Stock{}, &order.CartItem{}, &order.Item{}, &order.Copy{}, &progress.Progress{}, &progress.Entry{}, &annotation.Annotation{}, &attachment.Attachment{}, &attachment.Upload{}, &interaction.Event{}, &review.Review{}, &loan.Loan{}, &loan.Hold{})
+
+	repo := book.NewRepository(db)
+	now := time.Now()
+	dune := &book.Book{ID: uuid.New(), Title: "Dune", Author: "Frank Herbert", CreatedAt: now.Add(-2 * time.Hour)}
+	dupe := &book.Book{ID: uuid.New(), Title: "The Dune.", Author: "Herbert, Frank", CoverHash: "c0ffee", CreatedAt: now.Add(-time.Hour)}
+	emma := &book.Book{ID: uuid.New(), Title: "Emma", Author: "Jane Austen", CreatedAt: now}
+	for _, b := range []*book.Book{dune, dupe, emma} {
+		_, err := repo.Create(b)
+		testUtil.NoError(t, err)
+	}
+
+	reader := uuid.New()
+	testUtil.NoError(t, db.Create(&[]*order.Stock{{BookID: dune.ID, Available: 2}, {BookID: dupe.ID, Available: 3}}).Error)
+	testUtil.NoError(t, db.Create(&[]*order.CartItem{{UserID: reader, BookID: dune.ID, Quantity: 1}, {UserID: reader, BookID: dupe.ID, Quantity: 1}}).Error)
+	note := &annotation.Annotation{ID: uuid.New(), BookID: dupe.ID, UserID: reader, Text: "spice"}
+	testUtil.NoError(t, db.Create(note).Error)
+	// The reader reviewed both books, so only their review of the target is
+	// kept; the critic's review moves.
+	critic := uuid.New()
+	testUtil.NoError(t, db.Create(&[]*review.Review{
+		{ID: uuid.New(), BookID: dune.ID, UserID: reader, Rating: 5},
+		{ID: uuid.New(), BookID: dupe.ID, UserID: reader, Rating: 1},
+		{ID: uuid.New(), BookID: dupe.ID, UserID: critic, Rating: 2},
+	}).Error)
+	lent := &loan.Loan{ID: uuid.New(), BookID: dupe.ID, UserID: reader, CheckedOutAt: now, DueAt: now.Add(time.Hour)}
+	testUtil.NoError(t, db.Create(lent).Error)
+
+	api := book.New(db, validatorUtil.New(), event.NewBus(), book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Get("/books/{id}", api.FollowMerges(e.Handle(api.Read)))
+	r.Delete("/books/{id}", api.FollowMerges(e.Handle(api.Delete)))
+	r.Get("/admin/books/duplicates", e.Handle(api.Duplicates))
+	r.Post("/admin/books/{id}/merge-into/{target}", e.Handle(api.MergeInto))
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	merge := func(from, to uuid.UUID) int {
+		return serve(http.MethodPost, "/admin/books/"+from.String()+"/merge-into/"+to.String()).Code
+	}
+
+	w := serve(http.MethodGet, "/admin/books/duplicates")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var groups []*book.DuplicatesDTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &groups))
+	testUtil.Equal(t, 1, len(groups))
+	testUtil.Equal(t, 2, len(groups[0].Books))
+	testUtil.Equal(t, dune.ID.String(), groups[0].Books[0].ID)
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/admin/books/duplicates?threshold=2").Code)
+
+	testUtil.Equal(t, http.StatusBadRequest, merge(dune.ID, dune.ID))
+	testUtil.Equal(t, http.StatusNotFound, merge(dupe.ID, uuid.New()))
+	testUtil.Equal(t, http.StatusOK, merge(dupe.ID, dune.ID))
+	testUtil.Equal(t, http.StatusNotFound, merge(dupe.ID, dune.ID))
+
+	// Rows of both books are combined on the target, and the target takes
+	// the cover it lacked.
+	stock := &order.Stock{}
+	testUtil.NoError(t, db.Where("book_id = ?", dune.ID).First(stock).Error)
+	testUtil.Equal(t, 5, stock.Available)
+	var items []*order.CartItem
+	testUtil.NoError(t, db.Where("user_id = ?", reader).Find(&items).Error)
+	testUtil.Equal(t, 1, len(items))
+	testUtil.Equal(t, 2, items[0].Quantity)
+	testUtil.NoError(t, db.First(note, "id = ?", note.ID).Error)
+	testUtil.Equal(t, dune.ID, note.BookID)
+	merged, err := repo.Read(dune.ID)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "c0ffee", merged.CoverHash)
+	testUtil.Equal(t, "Dune", merged.Title)
+	testUtil.Equal(t, 2, merged.ReviewCount)
+	testUtil.Equal(t, int64(7), merged.RatingSum)
+	testUtil.NoError(t, db.First(lent, "id = ?", lent.ID).Error)
+	testUtil.Equal(t, dune.ID, lent.BookID)
+
+	// The merged book redirects to the target, and keeps doing so when the
+	// target is merged in turn. Writes are redirected keeping their method.
+	w = serve(http.MethodGet, "/books/"+dupe.ID.String()+"?fields=title")
+	testUtil.Equal(t, http.StatusMovedPermanently, w.Code)
+	testUtil.Equal(t, "/books/"+dune.ID.String()+"?fields=title", w.Header().Get("Location"))
+	var moved book.MovedDTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &moved))
+	testUtil.Equal(t, dune.ID.String(), moved.MovedTo)
+	// A book is lent to one borrower at a time, so books both on loan are
+	// merged once one is returned.
+	other := &loan.Loan{ID: uuid.New(), BookID: emma.ID, UserID: critic, CheckedOutAt: now, DueAt: now.Add(time.Hour)}
+	testUtil.NoError(t, db.Create(other).Error)
+	testUtil.Equal(t, http.StatusConflict, merge(dune.ID, emma.ID))
+	testUtil.NoError(t, db.Model(other).Update("returned_at", now).Error)
+	// A book under legal hold is not merged into either.
+	holds := legalhold.NewRepository(db)
+	testUtil.NoError(t, holds.Place(&legalhold.Hold{Kind: legalhold.KindBook, TargetID: emma.ID, Reason: "litigation"}))
+	w = serve(http.MethodPost, "/admin/books/"+dune.ID.String()+"/merge-into/"+emma.ID.String())
+	testUtil.Equal(t, http.StatusConflict, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "on_legal_hold"))
+	_, err = holds.Release(legalhold.KindBook, emma.ID)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, http.StatusOK, merge(dune.ID, emma.ID))
+	testUtil.Equal(t, "/books/"+emma.ID.String(), serve(http.MethodGet, "/books/"+dupe.ID.String()).Header().Get("Location"))
+	w = serve(http.MethodDelete, "/books/"+dune.ID.String())
+	testUtil.Equal(t, http.StatusPermanentRedirect, w.Code)
+	testUtil.Equal(t, "/books/"+emma.ID.String(), w.Header().Get("Location"))
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/books/"+uuid.New().String()).Code)
+}
+
+func TestAPI_ETag(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &genre.Genre{}, &legalhold.Hold{})
+
+	repo := book.NewRepository(db)
+	dune := &book.Book{ID: uuid.New(), Title: "Dune", Author: "Frank Herbert"}
+	_, err := repo.Create(dune)
+	testUtil.NoError(t, err)
+
+	api := book.New(db, validatorUtil.New(), event.NewBus(), book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Get("/books", e.Handle(api.List))
+	r.Get("/books/{id}", e.Handle(api.Read))
+	r.Put("/books/{id}", e.Handle(api.Update))
+	r.Delete("/books/{id}", e.Handle(api.Delete))
+
+	serve := func(method, target, header, tag, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if tag != "" {
+			req.Header.Set(header, tag)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	path := "/books/" + dune.ID.String()
+	form := `{"title": "Dune Messiah", "author": "Frank Herbert", "published_date": "1969-10-15", "image_url": "https://example.com/d.png"}`
+
+	w := serve(http.MethodGet, path, "", "", "")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	tag := w.Header().Get("ETag")
+	testUtil.Equal(t, true, strings.HasPrefix(tag, `W/"`))
+
+	w = serve(http.MethodGet, path, "If-None-Match", `"other", `+tag, "")
+	testUtil.Equal(t, http.StatusNotModified, w.Code)
+	testUtil.Equal(t, 0, w.Body.Len())
+
+	list := serve(http.MethodGet, "/books", "", "", "").Header().Get("ETag")
+	testUtil.Equal(t, http.StatusNotModified, serve(http.MethodGet, "/books", "If-None-Match", list, "").Code)
+
+	// Writes conditional on a tag apply only while the book is unchanged.
+	testUtil.Equal(t, http.StatusPreconditionFailed, serve(http.MethodPut, path, "If-Match", `W/"stale"`, form).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, path, "If-Match", tag, form).Code)
+	testUtil.Equal(t, http.StatusPreconditionFailed, serve(http.MethodPut, path, "If-Match", tag, form).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodGet, path, "If-None-Match", tag, "").Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodGet, "/books", "If-None-Match", list, "").Code)
+
+	testUtil.Equal(t, http.StatusPreconditionFailed, serve(http.MethodDelete, path, "If-Match", tag, "").Code)
+	current := serve(http.MethodGet, path, "", "", "").Header().Get("ETag")
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, path, "If-Match", current, "").Code)
+	testUtil.Equal(t, http.StatusPreconditionFailed, serve(http.MethodDelete, path, "If-Match", current, "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, path, "", "", "").Code)
+}
+
+func TestAPI_JSONPatch(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &legalhold.Hold{})
+	testUtil.NoError(t, db.Create(&customfield.Definition{ID: uuid.New(), TenantID: tenant.Default, Name: "shelf", Type: customfield.TypeString}).Error)
+
+	repo := book.NewRepository(db)
+	var ids []uuid.UUID
+	for _, title := range []string{"Dune", "Emma", "Ulysses"} {
+		id := uuid.New()
+		_, err := repo.Create(&book.Book{
+			ID:            id,
+			Title:         title,
+			Author:        "Someone",
+			PublishedDate: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC),
+			ImageURL:      "https://example.com/" + title + ".png",
+			CustomFields:  book.CustomFields{"shelf": "A"},
+		})
+		testUtil.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	updated := 0
+	bus := event.NewBus()
+	bus.Subscribe(book.EventUpdated, func(_ context.Context, _ event.Event) error {
+		updated++
+		return nil
+	})
+
+	api := book.New(db, validatorUtil.New(), bus, book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Patch("/books/bulk", e.Handle(api.BulkPatch))
+	r.Patch("/books/{id}", e.Handle(api.Patch))
+
+	serve := func(target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	dune := "/books/" + ids[0].String()
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"replace and test", `[{"op": "test", "path": "/title", "value": "Dune"}, {"op": "replace", "path": "/title", "value": "Dune Messiah"}]`, http.StatusOK},
+		{"set price", `[{"op": "add", "path": "/price", "value": {"amount": "12.50", "currency": "EUR"}}]`, http.StatusOK},
+		{"custom field", `[{"op": "replace", "path": "/custom_fields/shelf", "value": "B"}]`, http.StatusOK},
+		{"test fails", `[{"op": "test", "path": "/title", "value": "Dune"}, {"op": "replace", "path": "/title", "value": "x"}]`, http.StatusConflict},
+		{"missing path", `[{"op": "remove", "path": "/custom_fields/unknown"}]`, http.StatusConflict},
+		{"path not allowed", `[{"op": "replace", "path": "/id", "value": "x"}]`, http.StatusUnprocessableEntity},
+		{"sub-path not allowed", `[{"op": "replace", "path": "/title/0", "value": "x"}]`, http.StatusUnprocessableEntity},
+		{"malformed", `[{"op": "replace", "path": "/title"}]`, http.StatusUnprocessableEntity},
+		{"invalid result", `[{"op": "remove", "path": "/author"}]`, http.StatusUnprocessableEntity},
+		{"invalid price", `[{"op": "replace", "path": "/price/amount", "value": "9.999"}]`, http.StatusUnprocessableEntity},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testUtil.Equal(t, tc.status, serve(dune, "application/json-patch+json", tc.body).Code)
+		})
+	}
+
+	b, err := repo.Read(ids[0])
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "Dune Messiah", b.Title)
+	testUtil.Equal(t, "Someone", b.Author)
+	testUtil.Equal(t, "12.50 EUR", b.Price.String())
+	testUtil.Equal(t, any("B"), b.CustomFields["shelf"])
+	testUtil.Equal(t, 3, updated)
+
+	// The batch variant checks the patch once and reports on each book.
+	testUtil.Equal(t, http.StatusBadRequest, serve("/books/bulk", "", `{"ids": [], "operations": []}`).Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve("/books/bulk", "", `{"ids": "nope"}`).Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve("/books/bulk", "", `{"ids": ["`+ids[1].String()+`"], "operations": [{"op": "remove", "path": "/id"}]}`).Code)
+
+	w := serve("/books/bulk", "", `{"ids": ["`+ids[1].String()+`", "nope", "`+uuid.NewString()+`", "`+ids[2].String()+`", "`+ids[0].String()+`"], "operations": [
+		{"op": "test", "path": "/custom_fields/shelf", "value": "A"},
+		{"op": "replace", "path": "/author", "value": "Anonymous"}
+	]}`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var results []*book.BulkResultDTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	testUtil.Equal(t, 5, len(results))
+	testUtil.Equal(t, http.StatusOK, results[0].Status)
+	testUtil.Equal(t, http.StatusBadRequest, results[1].Status)
+	testUtil.Equal(t, http.StatusNotFound, results[2].Status)
+	testUtil.Equal(t, http.StatusOK, results[3].Status)
+	testUtil.Equal(t, http.StatusConflict, results[4].Status)
+	testUtil.Equal(t, 5, updated)
+
+	// Books under legal hold are left as they are.
+	testUtil.NoError(t, legalhold.NewRepository(db).Place(&legalhold.Hold{Kind: legalhold.KindBook, TargetID: ids[2], Reason: "audit"}))
+	w = serve("/books/bulk", "", `{"ids": ["`+ids[2].String()+`"], "operations": [{"op": "replace", "path": "/author", "value": "Someone else"}]}`)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	testUtil.Equal(t, http.StatusConflict, results[0].Status)
+	testUtil.Equal(t, http.StatusConflict, serve("/books/"+ids[2].String(), "", `{"author": "Someone else"}`).Code)
+	testUtil.Equal(t, 5, updated)
+
+	w = serve("/books/bulk", "", `{"ids": ["`+ids[1].String()+`"], "operations": [{"op": "replace", "path": "/published_date", "value": "1815"}]}`)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	testUtil.Equal(t, http.StatusUnprocessableEntity, results[0].Status)
+	testUtil.Equal(t, 1, len(results[0].Errors))
+
+	for _, id := range ids[1:] {
+		b, err := repo.Read(id)
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, "Anonymous", b.Author)
+		testUtil.Equal(t, "1965-08-01", b.PublishedDate.Format("2006-01-02"))
+	}
+}
+
+func TestAPI_DryRun(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &legalhold.Hold{})
+
+	repo := book.NewRepository(db)
+	id := uuid.New()
+	_, err := repo.Create(&book.Book{
+		ID:            id,
+		Title:         "Dune",
+		Author:        "Frank Herbert",
+		PublishedDate: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC),
+		ImageURL:      "https://example.com/dune.png",
+	})
+	testUtil.NoError(t, err)
+
+	published := 0
+	bus := event.NewBus()
+	for _, name := range []string{book.EventCreated, book.EventUpdated, book.EventDeleted} {
+		bus.Subscribe(name, func(_ context.Context, _ event.Event) error {
+			published++
+			return nil
+		})
+	}
+
+	api := book.New(db, validatorUtil.New(), bus, book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Use(dryrun.Guard(true))
+	r.Post("/books", e.Handle(api.Create))
+	r.Put("/books/{id}", e.Handle(api.Update))
+	r.Patch("/books/{id}", e.Handle(api.Patch))
+	r.Delete("/books/{id}", e.Handle(api.Delete))
+	r.Post("/books/bulk", e.Handle(api.BulkCreate))
+	r.Delete("/books/bulk", e.Handle(api.BulkDelete))
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(dryrun.Header, "true")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		testUtil.Equal(t, "true", w.Header().Get(dryrun.Header))
+		return w
+	}
+	preview := func(w *httptest.ResponseRecorder) *book.DTO {
+		dto := &book.DTO{}
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), dto))
+		return dto
+	}
+
+	emma := `{"title": "Emma", "author": "Jane Austen", "published_date": "1815-12-23", "image_url": "https://example.com/emma.png"}`
+	w := serve(http.MethodPost, "/books", emma)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	testUtil.Equal(t, "Emma", preview(w).Title)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, "/books", `{"title": "Emma"}`).Code)
+
+	dune := "/books/" + id.String()
+	w = serve(http.MethodPut, dune, `{"title": "Dune Messiah", "author": "Frank Herbert", "published_date": "1969-10-15", "image_url": "https://example.com/dune.png"}`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "Dune Messiah", preview(w).Title)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPut, "/books/"+uuid.NewString(), emma).Code)
+
+	w = serve(http.MethodPatch, dune, `{"description": "Spice"}`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "Spice", preview(w).Description)
+	testUtil.Equal(t, "Dune", preview(w).Title)
+
+	w = serve(http.MethodDelete, dune, "")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "Dune", preview(w).Title)
+
+	w = serve(http.MethodPost, "/books/bulk", `[`+emma+`]`)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	w = serve(http.MethodDelete, "/books/bulk", `["`+id.String()+`", "`+uuid.NewString()+`"]`)
+	var results []*book.BulkResultDTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	testUtil.Equal(t, http.StatusOK, results[0].Status)
+	testUtil.Equal(t, http.StatusNotFound, results[1].Status)
+
+	// Nothing was written or published.
+	var n int64
+	testUtil.NoError(t, db.Model(&book.Book{}).Count(&n).Error)
+	testUtil.Equal(t, int64(1), n)
+	b, err := repo.Read(id)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "Dune", b.Title)
+	testUtil.Equal(t, "", b.Description)
+	testUtil.Equal(t, 0, published)
+}
+
+func TestAPI_AsOf(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &book.Revision{}, &customfield.Definition{}, &genre.Genre{}, &legalhold.Hold{})
+
+	bus := event.NewBus()
+	book.SubscribeHistory(bus, book.NewRepository(db))
+	api := book.New(db, validatorUtil.New(), bus, book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Post("/books", e.Handle(api.Create))
+	r.Get("/books/{id}", e.Handle(api.Read))
+	r.Put("/books/{id}", e.Handle(api.Update))
+	r.Delete("/books/{id}", e.Handle(api.Delete))
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	// Revisions are told apart by the time they were recorded.
+	mark := func() string {
+		time.Sleep(5 * time.Millisecond)
+		at := time.Now().UTC().Format(time.RFC3339Nano)
+		time.Sleep(5 * time.Millisecond)
+		return at
+	}
+	title := func(w *httptest.ResponseRecorder) string {
+		testUtil.Equal(t, http.StatusOK, w.Code)
+		var dto book.DTO
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+		return dto.Title
+	}
+
+	before := mark()
+	w := serve(http.MethodPost, "/books", `{"title": "Dune", "author": "Frank Herbert", "published_date": "1965-08-01", "image_url": "https://example.com/d.png"}`)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	created := &book.Book{}
+	testUtil.NoError(t, db.First(created).Error)
+	path := "/books/" + created.ID.String()
+
+	afterCreate := mark()
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, path, `{"title": "Dune Messiah", "author": "Frank Herbert", "published_date": "1969-10-15", "image_url": "https://example.com/d.png"}`).Code)
+	afterUpdate := mark()
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, path, "").Code)
+
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, path+"?as_of="+before, "").Code)
+	testUtil.Equal(t, "Dune", title(serve(http.MethodGet, path+"?as_of="+afterCreate, "")))
+	testUtil.Equal(t, "Dune Messiah", title(serve(http.MethodGet, path+"?as_of="+afterUpdate, "")))
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, path+"?as_of="+mark(), "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, path, "").Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodGet, path+"?as_of=yesterday", "").Code)
+
+	// Revisions are recorded even once the writer has gone away.
+	count := func() int64 {
+		var n int64
+		testUtil.NoError(t, db.Model(&book.Revision{}).Where("book_id = ?", created.ID).Count(&n).Error)
+		return n
+	}
+	n := count()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testUtil.NoError(t, bus.Publish(ctx, event.New(book.EventRestored, created.ID.String(), created)))
+	testUtil.Equal(t, n+1, count())
+}
+
+func TestAPI_Versions(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &genre.Genre{}, &legalhold.Hold{})
+
+	dune := &book.Book{ID: uuid.New(), Title: "Dune", Author: "Frank Herbert"}
+	testUtil.NoError(t, db.Create(dune).Error)
+
+	api := book.New(db, validatorUtil.New(), event.NewBus(), book.NewCollator(nil), nil, nil)
+	r := chi.NewRouter()
+	r.Use(apiversion.Middleware)
+	r.Get("/books/{id}", e.Handle(api.Read))
+
+	read := func(target string) map[string]any {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		testUtil.Equal(t, http.StatusOK, w.Code)
+		var body map[string]any
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	// Version 1 clients keep the field casing they were written against.
+	v1 := read("/books/" + dune.ID.String())
+	testUtil.Equal(t, any("Frank Herbert"), v1["Author"])
+	_, ok := v1["author"]
+	testUtil.Equal(t, false, ok)
+
+	v2 := read("/books/" + dune.ID.String() + "?v=2")
+	testUtil.Equal(t, any("Frank Herbert"), v2["author"])
+	_, ok = v2["Author"]
+	testUtil.Equal(t, false, ok)
+}
+
+func TestAPI_ReadCache(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &customfield.Definition{}, &genre.Genre{}, &legalhold.Hold{})
+
+	repo := book.NewRepository(db)
+	dune := &book.Book{ID: uuid.New(), Title: "Dune", Author: "Frank Herbert"}
+	_, err := repo.Create(dune)
+	testUtil.NoError(t, err)
+
+	bus := event.NewBus()
+	store := cache.Count(cache.NewMemory())
+	api := book.New(db, validatorUtil.New(), bus, book.NewCollator(nil), nil, nil)
+	api.UseCache(book.NewReadCache(store, bus, time.Minute, time.Minute))
+	r := chi.NewRouter()
+	r.Get("/books", e.Handle(api.List))
+	r.Get("/books/{id}", e.Handle(api.Read))
+	r.Put("/books/{id}", e.Handle(api.Update))
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	title := func(target string) string {
+		w := serve(http.MethodGet, target, "")
+		testUtil.Equal(t, http.StatusOK, w.Code)
+		var b struct{ Title string }
+		if strings.HasPrefix(w.Body.String(), "[") {
+			var bs []struct{ Title string }
+			testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &bs))
+			testUtil.Equal(t, 1, len(bs))
+			return bs[0].Title
+		}
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+		return b.Title
+	}
+	path := "/books/" + dune.ID.String()
+
+	testUtil.Equal(t, "Dune", title(path))
+	testUtil.Equal(t, "Dune", title("/books"))
+
+	// Changes made without an event are not seen until the TTL is over.
+	testUtil.NoError(t, db.Model(&book.Book{}).Where("id = ?", dune.ID).Update("title", "Untitled").Error)
+	testUtil.Equal(t, "Dune", title(path))
+	testUtil.Equal(t, "Dune", title("/books"))
+
+	form := `{"title": "Dune Messiah", "author": "Frank Herbert", "published_date": "1969-10-15", "image_url": "https://example.com/d.png"}`
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, path, form).Code)
+	testUtil.Equal(t, "Dune Messiah", title(path))
+	testUtil.Equal(t, "Dune Messiah", title("/books"))
+
+	stats := store.Stats()
+	testUtil.Equal(t, uint64(2), stats.Hits)
+	testUtil.Equal(t, uint64(0), stats.Errors)
+}
@@ -0,0 +1,100 @@
+package book
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/event"
+)
+
+// Revision is the state of a book after a committed write, kept so that the
+// book can be read as it was at an earlier time. Deleted revisions mark the
+// book gone and carry no snapshot.
+type Revision struct {
+	ID     uint `gorm:"primarykey"`
+	BookID uuid.UUID
+	// Snapshot is the book, genres included, as JSON.
+	Snapshot   *string `gorm:"type:jsonb"`
+	Deleted    bool
+	RecordedAt time.Time
+}
+
+func (Revision) TableName() string {
+	return "book_revisions"
+}
+
+// SubscribeHistory records a revision of a book on each of its events. The
+// book is read back rather than taken from the payload, which some writes
+// fill only in part. History starts when this is first deployed; earlier
+// states of a book cannot be read.
+func SubscribeHistory(bus event.Bus, repository *Repository) {
+	record := func(ctx context.Context, ev event.Event) error {
+		id, err := uuid.Parse(ev.AggregateID)
+		if err != nil {
+			return err
+		}
+		// The book has changed whether or not its writer is still
+		// connected, so the revision is not cancelled with the request.
+		return repository.WithContext(context.WithoutCancel(ctx)).recordRevision(id, ev.Name == EventDeleted, ev.OccurredAt)
+	}
+
+	bus.Subscribe(EventCreated, record)
+	bus.Subscribe(EventUpdated, record)
+	bus.Subscribe(EventRestored, record)
+	bus.Subscribe(EventDeleted, record)
+}
+
+func (r *Repository) recordRevision(id uuid.UUID, deleted bool, at time.Time) error {
+	rev := &Revision{BookID: id, Deleted: deleted, RecordedAt: at.UTC()}
+	if !deleted {
+		book := &Book{}
+		if err := r.db.Unscoped().Scopes(preloadGenres).Where("id = ?", id).First(book).Error; err != nil {
+			return err
+		}
+		snapshot, err := json.Marshal(book)
+		if err != nil {
+			return err
+		}
+		s := string(snapshot)
+		rev.Snapshot = &s
+	}
+	return r.db.Create(rev).Error
+}
+
+// ReadAsOf returns the book as it was at t, or gorm.ErrRecordNotFound when
+// it did not exist then, was deleted, or its history does not reach back
+// that far.
+func (r *Repository) ReadAsOf(id uuid.UUID, t time.Time) (*Book, error) {
+	rev := &Revision{}
+	if err := r.db.Where("book_id = ? AND recorded_at <= ?", id, t.UTC()).
+		Order("recorded_at DESC, id DESC").First(rev).Error; err != nil {
+		return nil, err
+	}
+	if rev.Deleted || rev.Snapshot == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	book := &Book{}
+	if err := json.Unmarshal([]byte(*rev.Snapshot), book); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// parseAsOf reads the as_of query parameter, an RFC 3339 timestamp. It
+// reports false when the parameter is absent.
+func parseAsOf(q string) (time.Time, bool, error) {
+	if q == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, q)
+	if err != nil {
+		return time.Time{}, false, errors.New("book: as_of must be an RFC 3339 timestamp")
+	}
+	return t, true, nil
+}
@@ -0,0 +1,40 @@
+package book
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// ImageChecker probes image URLs on write so that clients learn about broken
+// covers without the write failing.
+type ImageChecker struct {
+	client *http.Client
+}
+
+func NewImageChecker(timeout time.Duration) *ImageChecker {
+	return &ImageChecker{client: &http.Client{Timeout: timeout, Transport: otelhttp.NewTransport(http.DefaultTransport)}}
+}
+
+// Check returns a description of the problem, or "" when url answered a HEAD
+// request successfully.
+func (ic *ImageChecker) Check(ctx context.Context, url string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err.Error()
+	}
+
+	resp, err := ic.client.Do(req)
+	if err != nil {
+		return "image_url could not be reached"
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Sprintf("image_url responded with status %d", resp.StatusCode)
+	}
+	return ""
+}
@@ -0,0 +1,246 @@
+package book
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/audit"
+	"hello/idcodec"
+)
+
+// ActionMerged is recorded before the response, as a merge cannot be undone.
+const ActionMerged = "book.merged"
+
+// DefaultDuplicateThreshold is the title similarity above which books by
+// the same author are taken for duplicates.
+const DefaultDuplicateThreshold = 0.8
+
+// Duplicates godoc
+//
+//	@summary        List duplicate books
+//	@description    List groups of books by the same author with similar titles, ignoring case, punctuation, leading articles and the order of author names
+//	@tags           books
+//	@produce        json
+//	@param          threshold   query   number  false   "Title similarity from 0 to 1 above which books are duplicates, 0.8 by default"
+//	@success        200 {array}     DuplicatesDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/books/duplicates [get]
+func (api *API) Duplicates(w http.ResponseWriter, r *http.Request) error {
+	threshold := DefaultDuplicateThreshold
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 || t > 1 {
+			return e.RespInvalidThreshold
+		}
+		threshold = t
+	}
+
+	books, err := api.repository.WithContext(r.Context()).ListAll()
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	groups := FindDuplicates(books, threshold)
+	dtos := make([]*DuplicatesDTO, len(groups))
+	for i, g := range groups {
+		dtos[i] = &DuplicatesDTO{Books: make([]*DTO, len(g.Books)), Score: g.Score}
+		for j, b := range g.Books {
+			dtos[i].Books[j] = b.ToDto()
+		}
+	}
+	if err := encode(w, r, api.policy, dtos); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// MergeInto godoc
+//
+//	@summary        Merge book
+//	@description    Merge a duplicate book into another: its copies, annotations, attachments, uploads, stock, cart and order items, reading progress, genres, interactions, reviews and loans move to the target, and the book is replaced by a redirect to it. The target keeps its own details, and the cover of the merged book if it has none
+//	@tags           books
+//	@produce        json
+//	@param          id      path    string  true    "ID of the book to merge"
+//	@param          target  path    string  true    "ID of the book to merge into"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/books/{id}/merge-into/{target} [post]
+func (api *API) MergeInto(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+	target, err := idcodec.Decode(chi.URLParam(r, "target"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+	if id == target {
+		return e.RespMergeIntoSelf
+	}
+
+	// The target takes over the rows of the merged book, so a hold on
+	// either book blocks the merge.
+	for _, held := range []uuid.UUID{id, target} {
+		if err := api.held(r, held, ActionMerged); err != nil {
+			return err
+		}
+	}
+
+	repository := api.repository.WithContext(r.Context())
+	if err := repository.Merge(id, target, time.Now()); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return e.RespNotFound
+		}
+		if errors.Is(err, ErrBothOnLoan) {
+			return e.RespBothOnLoan
+		}
+
+		return e.RespDBDataUpdateFailure
+	}
+
+	if err := audit.Record(r, ActionMerged, id.String(), audit.Details{"into": target.String()}); err != nil {
+		return e.RespAuditFailure
+	}
+
+	book, err := repository.Read(target)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	api.publish(r.Context(), EventDeleted, id, nil)
+	api.publish(r.Context(), EventUpdated, target, book)
+
+	if err := encode(w, r, api.policy, book.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Duplicates is a group of books that look like the same book.
+type Duplicates struct {
+	Books Books
+	Score float64
+}
+
+// FindDuplicates groups books by the same author whose titles are at least
+// threshold similar. Books are linked transitively, so a group may hold two
+// books less similar than threshold through a third. Groups and the books
+// within them keep the order of books, which is expected oldest first.
+func FindDuplicates(books Books, threshold float64) []*Duplicates {
+	byAuthor := map[string][]int{}
+	titles := make([]map[string]int, len(books))
+	for i, b := range books {
+		author := normalizeAuthor(b.Author)
+		byAuthor[author] = append(byAuthor[author], i)
+		titles[i] = trigrams(normalizeTitle(b.Title))
+	}
+
+	parent := make([]int, len(books))
+	score := make([]float64, len(books))
+	for i := range parent {
+		parent[i], score[i] = i, 1
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+
+	for _, idx := range byAuthor {
+		for a := 0; a < len(idx); a++ {
+			for b := a + 1; b < len(idx); b++ {
+				s := similarity(titles[idx[a]], titles[idx[b]])
+				if s < threshold {
+					continue
+				}
+				ra, rb := root(idx[a]), root(idx[b])
+				if ra > rb {
+					ra, rb = rb, ra
+				}
+				parent[rb] = ra
+				score[ra] = min(score[ra], score[rb], s)
+			}
+		}
+	}
+
+	groups := map[int]*Duplicates{}
+	var out []*Duplicates
+	for i, b := range books {
+		r := root(i)
+		g, ok := groups[r]
+		if !ok {
+			g = &Duplicates{}
+			groups[r] = g
+			out = append(out, g)
+		}
+		g.Books = append(g.Books, b)
+		g.Score = score[r]
+	}
+	return slices.DeleteFunc(out, func(g *Duplicates) bool { return len(g.Books) < 2 })
+}
+
+// words lowercases s and splits it into words, dropping punctuation.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalizeAuthor makes "Tolkien, J. R. R." and "J.R.R. Tolkien" equal.
+func normalizeAuthor(author string) string {
+	ws := words(author)
+	slices.Sort(ws)
+	return strings.Join(ws, "")
+}
+
+func normalizeTitle(title string) string {
+	ws := words(title)
+	if len(ws) > 1 && slices.Contains([]string{"the", "a", "an"}, ws[0]) {
+		ws = ws[1:]
+	}
+	return strings.Join(ws, " ")
+}
+
+// trigrams counts the three-letter sequences of s, padded so that short
+// words and word boundaries count too.
+func trigrams(s string) map[string]int {
+	grams := map[string]int{}
+	padded := []rune("  " + s + " ")
+	for i := 0; i+3 <= len(padded); i++ {
+		grams[string(padded[i:i+3])]++
+	}
+	return grams
+}
+
+// similarity is the Dice coefficient of two trigram sets, from 0 for
+// nothing in common to 1 for the same.
+func similarity(a, b map[string]int) float64 {
+	total, common := 0, 0
+	for g, n := range a {
+		total += n
+		common += min(n, b[g])
+	}
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 1
+	}
+	return 2 * float64(common) / float64(total)
+}
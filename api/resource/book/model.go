@@ -1,27 +1,64 @@
 package book
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+
+	"hello/api/resource/genre"
+	"hello/money"
 )
 
 type DTO struct {
-	ID            string `json:"id"`
-	Title         string `json:"title"`
-	Author        string `json:"Author"`
-	PublishedDate string `json:"published_date"`
-	ImageURL      string `json:"image_url"`
-	Description   string `json:"description"`
+	ID            string       `json:"id"`
+	Title         string       `json:"title"`
+	Author        string       `json:"author"`
+	PublishedDate string       `json:"published_date"`
+	ImageURL      string       `json:"image_url"`
+	CoverURL      string       `json:"cover_url,omitempty"`
+	Description   string       `json:"description"`
+	Price         *money.Money `json:"price,omitempty"`
+	Genres        []*genre.DTO `json:"genres,omitempty"`
+	// AverageRating is unset for books without reviews.
+	AverageRating *float64 `json:"average_rating,omitempty"`
+	ReviewCount   int      `json:"review_count"`
+
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+	Computed     map[string]any `json:"computed,omitempty"`
+}
+
+type FacetCountDTO struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type FacetsDTO struct {
+	Author       []*FacetCountDTO `json:"author"`
+	Decade       []*FacetCountDTO `json:"decade"`
+	Genre        []*FacetCountDTO `json:"genre"`
+	Availability []*FacetCountDTO `json:"availability"`
 }
 
 type Form struct {
-	Title         string `json:"title" validate:"required,max=255"`
-	Author        string `json:"author" validate:"required,alphaspace,max=255"`
-	PublishedDate string `json:"published_date" validate:"required,datetime=2006-01-02"`
-	ImageURL      string `json:"image_url" validate:"url"`
-	Description   string `json:"description"`
+	Title         string     `json:"title" validate:"required,max=255" sanitize:"singleline"`
+	Author        string     `json:"author" validate:"required,alphaspace,max=255" sanitize:"singleline"`
+	PublishedDate string     `json:"published_date" validate:"required,datetime=2006-01-02,notfuture"`
+	ImageURL      string     `json:"image_url" validate:"url,urlscheme=http https"`
+	Description   string     `json:"description"`
+	Price         *PriceForm `json:"price"`
+
+	// CustomFields are validated against the tenant's definitions.
+	CustomFields map[string]any `json:"custom_fields"`
+}
+
+// PriceForm is a price in a currency's major unit, e.g. {"amount": "12.50",
+// "currency": "EUR"}. The amount may be given as a string or a number and
+// may have no more decimals than the currency has minor units.
+type PriceForm struct {
+	Amount   json.Number `json:"amount" validate:"required,money=Currency"`
+	Currency string      `json:"currency" validate:"required,currency"`
 }
 
 type Book struct {
@@ -31,9 +68,77 @@ type Book struct {
 	PublishedDate time.Time
 	ImageURL      string
 	Description   string
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	DeletedAt     gorm.DeletedAt
+	// Price is stored in the currency's minor unit; without a currency the
+	// book has no price.
+	Price        money.Money  `gorm:"embedded;embeddedPrefix:price_"`
+	CustomFields CustomFields `gorm:"type:jsonb"`
+	// CoverHash is the blob holding the uploaded cover, if any.
+	CoverHash string
+	CoverType string
+	// ReviewCount and RatingSum total the book's reviews. The review API
+	// keeps them; other writes leave them alone.
+	ReviewCount int
+	RatingSum   int64
+	// Genres are loaded by List and Read only.
+	Genres    genre.Genres `gorm:"many2many:book_genres"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
 }
 
 type Books []*Book
+
+// bookGenre tags a book with a genre.
+type bookGenre struct {
+	BookID  uuid.UUID `gorm:"primarykey"`
+	GenreID uuid.UUID `gorm:"primarykey"`
+}
+
+func (bookGenre) TableName() string {
+	return "book_genres"
+}
+
+// GenresForm lists genres by slug.
+type GenresForm struct {
+	Genres []string `json:"genres" validate:"required,min=1,max=20,dive,required"`
+}
+
+type FacetCount struct {
+	Value string
+	Count int64
+}
+
+type FacetCounts []*FacetCount
+
+// Values of the availability facet.
+const (
+	AvailabilityAvailable = "available"
+	AvailabilityOnLoan    = "on_loan"
+)
+
+type Facets struct {
+	Author       FacetCounts
+	Decade       FacetCounts
+	Genre        FacetCounts
+	Availability FacetCounts
+}
+
+// Redirect is left behind by a book merged into another, so that links to
+// the merged book lead to the one it was merged into.
+type Redirect struct {
+	FromID   uuid.UUID `gorm:"primarykey"`
+	ToID     uuid.UUID
+	MergedAt time.Time
+}
+
+func (Redirect) TableName() string {
+	return "book_redirects"
+}
+
+// DuplicatesDTO is a group of books that look like the same book. The
+// oldest comes first, as the natural one to merge the others into.
+type DuplicatesDTO struct {
+	Books []*DTO `json:"books"`
+	// Score is the lowest title similarity, from 0 to 1, linking the group.
+	Score float64 `json:"score"`
+}
@@ -0,0 +1,242 @@
+package book
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+
+	"hello/util/jsonpatch"
+)
+
+// patchable maps the members of a merge patch to the Form fields they set.
+var patchable = map[string]string{
+	"title":          "Title",
+	"author":         "Author",
+	"published_date": "PublishedDate",
+	"image_url":      "ImageURL",
+	"description":    "Description",
+	"price":          "Price",
+	"custom_fields":  "CustomFields",
+}
+
+// ToForm returns the form b was made from, for a patch to start from.
+func (b *Book) ToForm() *Form {
+	form := &Form{
+		Title:         b.Title,
+		Author:        b.Author,
+		PublishedDate: b.PublishedDate.Format("2006-01-02"),
+		ImageURL:      b.ImageURL,
+		Description:   b.Description,
+		CustomFields:  maps.Clone(b.CustomFields),
+	}
+	if !b.Price.IsZero() {
+		form.Price = &PriceForm{Amount: json.Number(b.Price.Decimal()), Currency: b.Price.Currency}
+	}
+	return form
+}
+
+// applyPatch applies a JSON Merge Patch (RFC 7386) to form and returns the
+// names of the Form fields it sets. Null clears a field, and the price and
+// custom fields are merged member by member. Unknown members are ignored, as
+// they are by a full update.
+func applyPatch(form *Form, patch map[string]json.RawMessage) ([]string, error) {
+	var fields []string
+	for member, raw := range patch {
+		field, ok := patchable[member]
+		if !ok {
+			continue
+		}
+		fields = append(fields, field)
+
+		if string(bytes.TrimSpace(raw)) == "null" {
+			raw = nil
+		}
+
+		if member == "price" {
+			if raw == nil {
+				form.Price = nil
+				continue
+			}
+			if form.Price == nil {
+				form.Price = &PriceForm{}
+			}
+			if err := json.Unmarshal(raw, form.Price); err != nil {
+				return nil, err
+			}
+			fields = append(fields, "Price.Amount", "Price.Currency")
+			continue
+		}
+
+		if member == "custom_fields" {
+			var cf map[string]any
+			if raw != nil {
+				if err := json.Unmarshal(raw, &cf); err != nil {
+					return nil, err
+				}
+			}
+			form.CustomFields = mergePatch(form.CustomFields, cf)
+			continue
+		}
+
+		var s string
+		if raw != nil {
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, err
+			}
+		}
+		reflect.ValueOf(form).Elem().FieldByName(field).SetString(s)
+	}
+
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// checkJSONPatch returns what is wrong with a JSON Patch (RFC 6902) for a
+// book. Besides being well-formed, every path and from must point to a
+// patchable member or, for the price and custom fields, into one.
+func checkJSONPatch(ops jsonpatch.Patch) []string {
+	if err := ops.Validate(); err != nil {
+		return []string{err.Error()}
+	}
+
+	var msgs []string
+	for i, op := range ops {
+		pointers := []string{op.Path}
+		if op.Op == jsonpatch.OpMove || op.Op == jsonpatch.OpCopy {
+			pointers = append(pointers, op.From)
+		}
+		for _, pointer := range pointers {
+			tokens, _ := jsonpatch.Parse(pointer)
+			if !patchablePath(tokens) {
+				msgs = append(msgs, fmt.Sprintf("operation %d: %q is not a path that can be patched", i, pointer))
+			}
+		}
+	}
+	return msgs
+}
+
+func patchablePath(tokens []string) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	if _, ok := patchable[tokens[0]]; !ok {
+		return false
+	}
+	switch tokens[0] {
+	case "price":
+		return len(tokens) == 1 || len(tokens) == 2 && (tokens[1] == "amount" || tokens[1] == "currency")
+	case "custom_fields":
+		return true
+	default:
+		return len(tokens) == 1
+	}
+}
+
+// applyJSONPatch applies ops, which checkJSONPatch passed, to form and
+// returns the names of the Form fields they change. The form is patched as
+// the JSON a client would send for it, so paths are those of its members.
+// A failed test or a path missing from the form fails the whole patch with
+// jsonpatch.ErrTestFailed or jsonpatch.ErrPath, leaving form as it was.
+func applyJSONPatch(form *Form, ops jsonpatch.Patch) ([]string, error) {
+	raw, err := json.Marshal(form)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	if doc, err = ops.Apply(doc); err != nil {
+		return nil, err
+	}
+	if raw, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+	patched := &Form{}
+	if err := json.Unmarshal(raw, patched); err != nil {
+		return nil, err
+	}
+	*form = *patched
+
+	var fields []string
+	for _, op := range ops {
+		pointers := []string{op.Path}
+		switch op.Op {
+		case jsonpatch.OpTest:
+			continue
+		case jsonpatch.OpMove:
+			pointers = append(pointers, op.From)
+		}
+		for _, pointer := range pointers {
+			tokens, _ := jsonpatch.Parse(pointer)
+			field := patchable[tokens[0]]
+			if slices.Contains(fields, field) {
+				continue
+			}
+			fields = append(fields, field)
+		}
+	}
+	if slices.Contains(fields, "Price") && form.Price != nil {
+		fields = append(fields, "Price.Amount", "Price.Currency")
+	}
+
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// mergePatch merges patch into a copy of target. A nil patch removes the
+// target altogether.
+func mergePatch(target, patch map[string]any) map[string]any {
+	if patch == nil {
+		return nil
+	}
+
+	merged := maps.Clone(target)
+	if merged == nil {
+		merged = make(map[string]any, len(patch))
+	}
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(merged, k)
+		case map[string]any:
+			t, _ := merged[k].(map[string]any)
+			merged[k] = mergePatch(t, v)
+		default:
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// changes returns the columns that differ between b and next.
+func (b *Book) changes(next *Book) map[string]any {
+	columns := make(map[string]any)
+	if next.Title != b.Title {
+		columns["title"] = next.Title
+	}
+	if next.Author != b.Author {
+		columns["author"] = next.Author
+	}
+	if !next.PublishedDate.Equal(b.PublishedDate) {
+		columns["published_date"] = next.PublishedDate
+	}
+	if next.ImageURL != b.ImageURL {
+		columns["image_url"] = next.ImageURL
+	}
+	if next.Description != b.Description {
+		columns["description"] = next.Description
+	}
+	if next.Price != b.Price {
+		columns["price_amount"] = next.Price.Amount
+		columns["price_currency"] = next.Price.Currency
+	}
+	if !reflect.DeepEqual(next.CustomFields, b.CustomFields) {
+		columns["custom_fields"] = next.CustomFields
+	}
+	return columns
+}
@@ -0,0 +1,165 @@
+package book
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/cache"
+	"hello/event"
+)
+
+// listGenerationKey holds the generation of cached lists. Lists are cached
+// under it, so that replacing it drops every list at once, whatever filters
+// they were read with.
+const listGenerationKey = "books:lists:generation"
+
+// ReadCache serves book reads and lists from a cache, reading through to
+// the repository on a miss. Books are dropped and lists replaced on every
+// book event, so after a write this instance reads its own changes; other
+// instances only do when they share the cache, as with Redis, and see
+// changes made without an event, such as a new cover, once the TTL is over.
+type ReadCache struct {
+	store   cache.Cache
+	ttl     time.Duration
+	listTTL time.Duration
+}
+
+// NewReadCache returns a cache keeping books for ttl and lists for
+// listTTL; a zero TTL leaves them uncached.
+func NewReadCache(store cache.Cache, bus event.Bus, ttl, listTTL time.Duration) *ReadCache {
+	c := &ReadCache{store: store, ttl: ttl, listTTL: listTTL}
+	invalidate := func(ctx context.Context, ev event.Event) error {
+		return c.invalidate(ctx, ev.AggregateID)
+	}
+	for _, name := range []string{EventCreated, EventUpdated, EventDeleted, EventRestored} {
+		bus.Subscribe(name, invalidate)
+	}
+	return c
+}
+
+// UseCache has Read and List go through c. Reads made for writes, such as
+// checking preconditions, always go to the repository.
+func (api *API) UseCache(c *ReadCache) {
+	api.cache = c
+}
+
+func bookKey(id string) string {
+	return "books:" + id
+}
+
+func (c *ReadCache) invalidate(ctx context.Context, id string) error {
+	ctx = context.WithoutCancel(ctx)
+	if err := c.store.Delete(ctx, bookKey(id)); err != nil {
+		return err
+	}
+	return c.store.Set(ctx, listGenerationKey, []byte(uuid.NewString()), 0)
+}
+
+// read returns the book of id, from the cache when it holds it. Missing
+// books are not cached.
+func (api *API) read(ctx context.Context, id uuid.UUID) (*Book, error) {
+	repository := api.repository.WithContext(ctx)
+	c := api.cache
+	if c == nil || c.ttl <= 0 {
+		return repository.Read(id)
+	}
+
+	key := bookKey(id.String())
+	book := &Book{}
+	if c.get(ctx, key, book) {
+		return book, nil
+	}
+	book, err := repository.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	c.set(ctx, key, book, c.ttl)
+	return book, nil
+}
+
+// list returns the books matching f, from the cache when it holds them.
+func (api *API) list(ctx context.Context, f *Filter) (Books, error) {
+	repository := api.repository.WithContext(ctx)
+	c := api.cache
+	if c == nil || c.listTTL <= 0 {
+		return repository.List(f)
+	}
+
+	key, err := c.listKey(ctx, f)
+	if err != nil {
+		log.Printf("book cache: %s", err)
+		return repository.List(f)
+	}
+	var books Books
+	if c.get(ctx, key, &books) {
+		return books, nil
+	}
+	books, err = repository.List(f)
+	if err != nil {
+		return nil, err
+	}
+	c.set(ctx, key, books, c.listTTL)
+	return books, nil
+}
+
+// listKey names the list of f in the current generation, starting one
+// when there is none. The generation is looked up past a counted store, so
+// that its stats only count lookups of books and lists.
+func (c *ReadCache) listKey(ctx context.Context, f *Filter) (string, error) {
+	store := c.store
+	if u, ok := store.(interface{ Unwrap() cache.Cache }); ok {
+		store = u.Unwrap()
+	}
+	generation, err := store.Get(ctx, listGenerationKey)
+	if errors.Is(err, cache.ErrMiss) {
+		generation = []byte(uuid.NewString())
+		err = store.Set(ctx, listGenerationKey, generation, 0)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Filters hold no unexported state and maps encode in key order, so
+	// equal filters encode alike.
+	b, err := json.Marshal(f)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "books:lists:" + string(generation) + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// get decodes the value of key into v, reporting whether the cache held
+// it. Failures are logged and count as misses, so that reads go on when
+// the cache is down.
+func (c *ReadCache) get(ctx context.Context, key string, v any) bool {
+	b, err := c.store.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrMiss) {
+			log.Printf("book cache: %s", err)
+		}
+		return false
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		log.Printf("book cache: %s: %s", key, err)
+		return false
+	}
+	return true
+}
+
+func (c *ReadCache) set(ctx context.Context, key string, v any, ttl time.Duration) {
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = c.store.Set(ctx, key, b, ttl)
+	}
+	if err != nil {
+		log.Printf("book cache: %s: %s", key, err)
+	}
+}
@@ -0,0 +1,125 @@
+package book
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+)
+
+// MovedDTO points a request for a merged book to the book it was merged
+// into.
+type MovedDTO struct {
+	Error    string `json:"error"`
+	MovedTo  string `json:"moved_to"`
+	Location string `json:"location"`
+}
+
+// FollowMerges wraps a handler of a /books/{id} route so that, when it
+// answers 404 for a book that was merged into another, the caller is sent
+// to the same route of that book instead: with 301 for reads and 308 for
+// writes, which keeps the method and body. The Location header and a
+// MovedDTO body both point there.
+//
+// Everything referencing a merged book moves with it, so the route of the
+// target serves what the old one did, sub-resources included.
+func (api *API) FollowMerges(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := &notFoundCatcher{ResponseWriter: w}
+		next(c, r)
+		if !c.caught {
+			return
+		}
+
+		raw := chi.URLParam(r, "id")
+		id, err := idcodec.Decode(raw)
+		if err != nil {
+			c.release()
+			return
+		}
+		redirect, err := api.repository.WithContext(r.Context()).Redirect(id)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.release()
+				return
+			}
+
+			e.ServerError(w, e.RespDBDataAccessFailure)
+			return
+		}
+
+		moved := idcodec.Encode(redirect.ToID)
+		location := movedPath(r.URL.Path, raw, moved)
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		h := w.Header()
+		h.Set("Location", location)
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(&MovedDTO{Error: "book was merged into another", MovedTo: moved, Location: location})
+	}
+}
+
+// movedPath replaces the book ID following the books segment of path.
+func movedPath(path, from, to string) string {
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		if segments[i-1] == "books" && segments[i] == from {
+			segments[i] = to
+			break
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// notFoundCatcher holds back a 404 response, passing any other through.
+type notFoundCatcher struct {
+	http.ResponseWriter
+	wroteHeader bool
+	caught      bool
+	body        []byte
+}
+
+func (c *notFoundCatcher) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	if status == http.StatusNotFound {
+		c.caught = true
+		return
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *notFoundCatcher) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.caught {
+		c.body = append(c.body, b...)
+		return len(b), nil
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *notFoundCatcher) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// release sends the held back 404.
+func (c *notFoundCatcher) release() {
+	c.ResponseWriter.WriteHeader(http.StatusNotFound)
+	c.ResponseWriter.Write(c.body)
+}
@@ -1,10 +1,21 @@
 package book
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"hello/api/resource/blob"
+	"hello/api/resource/genre"
 )
 
+// ErrBothOnLoan is returned when merging two books that are both on loan.
+var ErrBothOnLoan = errors.New("book: both books on loan")
+
 type Repository struct {
 	db *gorm.DB
 }
@@ -15,14 +26,83 @@ func NewRepository(db *gorm.DB) *Repository {
 	}
 }
 
-func (r *Repository) List() (Books, error) {
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// WithTx runs fn with a repository whose queries run in ctx and in one
+// transaction, so that steps fn takes apply together or not at all. The
+// transaction is committed when fn returns nil, and rolled back when it
+// returns an error, panics, or ctx is done before it is committed. Called
+// on a repository given to fn, WithTx nests in a savepoint.
+func (r *Repository) WithTx(ctx context.Context, fn func(r *Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := fn(&Repository{db: tx}); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
+func (r *Repository) List(f *Filter) (Books, error) {
+	db := r.db.Scopes(f.scope, f.order, preloadGenres)
+
 	books := make([]*Book, 0)
-	if err := r.db.Find(&books).Error; err != nil {
+	if err := db.Find(&books).Error; err != nil {
 		return nil, err
 	}
 	return books, nil
 }
 
+// maxFacetValues caps high-cardinality facets such as author.
+const maxFacetValues = 50
+
+func (r *Repository) Facets(f *Filter) (*Facets, error) {
+	facets := &Facets{}
+
+	if err := r.db.Model(&Book{}).Scopes(f.scope).
+		Select("author AS value, COUNT(*) AS count").
+		Group("author").
+		Order("count DESC, value").
+		Limit(maxFacetValues).
+		Scan(&facets.Author).Error; err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Model(&Book{}).Scopes(f.scope).
+		Select("CAST(FLOOR(EXTRACT(YEAR FROM published_date) / 10) * 10 AS INTEGER) AS value, COUNT(*) AS count").
+		Group("value").
+		Order("value").
+		Scan(&facets.Decade).Error; err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Table("genres").
+		Joins("JOIN book_genres ON book_genres.genre_id = genres.id").
+		Where("book_genres.book_id IN (?)", r.db.Model(&Book{}).Scopes(f.scope).Select("id")).
+		Select("genres.slug AS value, COUNT(*) AS count").
+		Group("genres.slug").
+		Order("count DESC, value").
+		Limit(maxFacetValues).
+		Scan(&facets.Genre).Error; err != nil {
+		return nil, err
+	}
+
+	// A book is on loan while it has a loan that is not returned.
+	if err := r.db.Model(&Book{}).Scopes(f.scope).
+		Select("CASE WHEN id IN (SELECT book_id FROM loans WHERE returned_at IS NULL) THEN ? ELSE ? END AS value, COUNT(*) AS count", AvailabilityOnLoan, AvailabilityAvailable).
+		Group("value").
+		Order("value").
+		Scan(&facets.Availability).Error; err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
 func (r *Repository) Create(book *Book) (*Book, error) {
 	if err := r.db.Create(book).Error; err != nil {
 		return nil, err
@@ -30,24 +110,285 @@ func (r *Repository) Create(book *Book) (*Book, error) {
 	return book, nil
 }
 
+// CreateMany inserts books in one transaction, so either all of them are
+// created or none.
+func (r *Repository) CreateMany(books Books) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&books, 100).Error
+	})
+}
+
+// Each calls fn with the books in batches of size, in ID order, so that the
+// catalog can be streamed without loading it whole.
+func (r *Repository) Each(size int, fn func(Books) error) error {
+	var batch Books
+	return r.db.FindInBatches(&batch, size, func(*gorm.DB, int) error {
+		return fn(batch)
+	}).Error
+}
+
 func (r *Repository) Read(id uuid.UUID) (*Book, error) {
 	book := &Book{}
-	if err := r.db.Where("id = ?", id).First(&book).Error; err != nil {
+	if err := r.db.Scopes(preloadGenres).Where("id = ?", id).First(&book).Error; err != nil {
 		return nil, err
 	}
 
 	return book, nil
 }
+
+func (r *Repository) ReadMany(ids []uuid.UUID) (Books, error) {
+	books := make([]*Book, 0, len(ids))
+	if len(ids) == 0 {
+		return books, nil
+	}
+
+	if err := r.db.Where("id IN ?", ids).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
 func (r *Repository) Update(book *Book) (int64, error) {
 	result := r.db.Model(&Book{}).
-		Select("Title", "Author", "PublishedDate", "ImageURL", "Description", "UpdatedAt").
+		Select("Title", "Author", "PublishedDate", "ImageURL", "Description", "price_amount", "price_currency", "CustomFields", "UpdatedAt").
 		Where("id=?", book.ID).
 		Updates(book)
 
 	return result.RowsAffected, result.Error
 }
 
+// Patch updates only the given columns of a book.
+func (r *Repository) Patch(id uuid.UUID, columns map[string]any) (int64, error) {
+	result := r.db.Model(&Book{}).Where("id=?", id).Updates(columns)
+	return result.RowsAffected, result.Error
+}
+
 func (r *Repository) Delete(id uuid.UUID) (int64, error) {
 	result := r.db.Where("id=?", id).Delete(&Book{})
 	return result.RowsAffected, result.Error
 }
+
+// DeleteMany soft-deletes the books of ids and returns the IDs of those
+// that existed.
+func (r *Repository) DeleteMany(ids []uuid.UUID) ([]uuid.UUID, error) {
+	var deleted []uuid.UUID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Book{}).Where("id IN ?", ids).Pluck("id", &deleted).Error; err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", deleted).Delete(&Book{}).Error
+	})
+	return deleted, err
+}
+
+// Existing returns those of ids that are live books.
+func (r *Repository) Existing(ids []uuid.UUID) ([]uuid.UUID, error) {
+	var existing []uuid.UUID
+	err := r.db.Model(&Book{}).Where("id IN ?", ids).Pluck("id", &existing).Error
+	return existing, err
+}
+
+// ListDeleted returns the soft-deleted books, most recently deleted first.
+func (r *Repository) ListDeleted() (Books, error) {
+	books := make([]*Book, 0)
+	if err := r.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&books).Error; err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// Restore undoes the soft delete of a book.
+func (r *Repository) Restore(id uuid.UUID) (int64, error) {
+	result := r.db.Unscoped().Model(&Book{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	return result.RowsAffected, result.Error
+}
+
+// Purge permanently deletes a book, soft-deleted or not, with its history,
+// and releases its cover. Rows of other resources referencing the book are
+// deleted with it, except attachments and uploads, which make the delete
+// fail.
+func (r *Repository) Purge(id uuid.UUID) (int64, error) {
+	var rows int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var books []*Book
+		if err := tx.Unscoped().Where("id = ?", id).Limit(1).Find(&books).Error; err != nil {
+			return err
+		}
+		if len(books) == 0 {
+			return nil
+		}
+
+		result := tx.Unscoped().Where("id = ?", id).Delete(&Book{})
+		if result.Error != nil {
+			return result.Error
+		}
+		rows = result.RowsAffected
+
+		if err := tx.Where("book_id = ?", id).Delete(&Revision{}).Error; err != nil {
+			return err
+		}
+		if hash := books[0].CoverHash; hash != "" {
+			return blob.NewRepository(tx).Release(hash)
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func preloadGenres(db *gorm.DB) *gorm.DB {
+	return db.Preload("Genres", func(db *gorm.DB) *gorm.DB {
+		return db.Order("slug")
+	})
+}
+
+// AddGenres tags the book with genres, keeping the genres it has.
+func (r *Repository) AddGenres(id uuid.UUID, genres genre.Genres) error {
+	links := make([]*bookGenre, len(genres))
+	for i, g := range genres {
+		links[i] = &bookGenre{BookID: id, GenreID: g.ID}
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// RemoveGenre untags the book of the genre of slug.
+func (r *Repository) RemoveGenre(id uuid.UUID, slug string) (int64, error) {
+	result := r.db.Where("book_id = ? AND genre_id IN (?)", id, r.db.Model(&genre.Genre{}).Select("id").Where("slug = ?", slug)).
+		Delete(&bookGenre{})
+	return result.RowsAffected, result.Error
+}
+
+// ListAll returns every book without its genres, oldest first.
+func (r *Repository) ListAll() (Books, error) {
+	books := make([]*Book, 0)
+	if err := r.db.Order("created_at, id").Find(&books).Error; err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// Redirect returns the redirect left by the book of id, if it was merged.
+func (r *Repository) Redirect(id uuid.UUID) (*Redirect, error) {
+	redirect := &Redirect{}
+	if err := r.db.Where("from_id = ?", id).First(redirect).Error; err != nil {
+		return nil, err
+	}
+	return redirect, nil
+}
+
+// merged lists the tables referencing books whose rows a merge moves. Key
+// columns identify a row together with book_id; where the target already
+// has a row of the same key, sum columns are added to it and the row of the
+// merged book is dropped.
+var merged = []struct {
+	table string
+	keys  []string
+	sum   []string
+}{
+	{table: "copies"},
+	{table: "annotations"},
+	{table: "attachments"},
+	{table: "uploads"},
+	{table: "interaction_events"},
+	{table: "stock_levels", sum: []string{"available"}},
+	{table: "cart_items", keys: []string{"user_id"}, sum: []string{"quantity"}},
+	{table: "order_items", keys: []string{"order_id"}, sum: []string{"quantity"}},
+	{table: "reading_progress", keys: []string{"user_id"}},
+	{table: "reading_progress_history", keys: []string{"user_id", "device", "recorded_at"}},
+	{table: "book_genres", keys: []string{"genre_id"}},
+	{table: "reviews", keys: []string{"user_id"}},
+	{table: "loans"},
+	{table: "holds", keys: []string{"user_id"}},
+}
+
+// recountReviews sets the review totals of a book from its reviews.
+const recountReviews = "UPDATE books SET review_count = (SELECT COUNT(*) FROM reviews WHERE book_id = @to)," +
+	" rating_sum = (SELECT COALESCE(SUM(rating), 0) FROM reviews WHERE book_id = @to) WHERE id = @to"
+
+// Merge moves everything referencing the book of from to the book of to,
+// deletes from and leaves a redirect to to in its place. The target keeps
+// its own details; it takes the cover of from only if it has none. Merge
+// returns gorm.ErrRecordNotFound when either book does not exist, and
+// ErrBothOnLoan when both are on loan, as a book is lent to one borrower
+// at a time.
+func (r *Repository) Merge(from, to uuid.UUID, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var books []*Book
+		if err := tx.Where("id IN ?", []uuid.UUID{from, to}).Find(&books).Error; err != nil {
+			return err
+		}
+		if len(books) != 2 {
+			return gorm.ErrRecordNotFound
+		}
+		source, target := books[0], books[1]
+		if source.ID != from {
+			source, target = target, source
+		}
+
+		var onLoan int64
+		if err := tx.Table("loans").Where("book_id IN ? AND returned_at IS NULL", []uuid.UUID{from, to}).Count(&onLoan).Error; err != nil {
+			return err
+		}
+		if onLoan > 1 {
+			return ErrBothOnLoan
+		}
+
+		ids := map[string]any{"from": from, "to": to}
+		for _, m := range merged {
+			match := ""
+			for _, k := range m.keys {
+				match += " AND s." + k + " = " + m.table + "." + k
+			}
+			for _, col := range m.sum {
+				if err := tx.Exec("UPDATE "+m.table+" SET "+col+" = "+col+
+					" + (SELECT s."+col+" FROM "+m.table+" s WHERE s.book_id = @from"+match+")"+
+					" WHERE book_id = @to AND EXISTS (SELECT 1 FROM "+m.table+" s WHERE s.book_id = @from"+match+")", ids).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("DELETE FROM "+m.table+
+				" WHERE book_id = @from AND EXISTS (SELECT 1 FROM "+m.table+" s WHERE s.book_id = @to"+match+")", ids).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE "+m.table+" SET book_id = @to WHERE book_id = @from", ids).Error; err != nil {
+				return err
+			}
+		}
+		// Reviews of users who reviewed both books were dropped above.
+		if err := tx.Exec(recountReviews, ids).Error; err != nil {
+			return err
+		}
+
+		if source.CoverHash != "" && target.CoverHash == "" {
+			if err := tx.Model(target).Updates(map[string]any{"cover_hash": source.CoverHash, "cover_type": source.CoverType}).Error; err != nil {
+				return err
+			}
+		} else if source.CoverHash != "" {
+			if err := blob.NewRepository(tx).Release(source.CoverHash); err != nil {
+				return err
+			}
+		}
+
+		// Earlier redirects to from are moved first, as deleting from would
+		// delete them with it.
+		if err := tx.Model(&Redirect{}).Where("to_id = ?", from).Update("to_id", to).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(source).Error; err != nil {
+			return err
+		}
+		return tx.Create(&Redirect{FromID: from, ToID: to, MergedAt: at}).Error
+	})
+}
+
+// unchangedSince returns a repository whose writes only apply to the book
+// while its row is as in b. Writes to a book changed since report no rows.
+func (r *Repository) unchangedSince(b *Book) *Repository {
+	return &Repository{
+		db: r.db.Where("updated_at = ?", b.UpdatedAt),
+	}
+}
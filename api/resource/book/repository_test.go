@@ -1,6 +1,9 @@
 package book_test
 
 import (
+	"context"
+	"errors"
+	"net/url"
 	"testing"
 	"time"
 
@@ -20,15 +23,188 @@ func TestRepository_List(t *testing.T) {
 
 	repo := book.NewRepository(db)
 
+	id, genreID := uuid.New(), uuid.New()
 	mockRows := sqlmock.NewRows([]string{"id", "title", "author"}).
-		AddRow(uuid.New(), "Book1", "Author1").
+		AddRow(id, "Book1", "Author1").
 		AddRow(uuid.New(), "Book2", "Author2")
 
 	mock.ExpectQuery("^SELECT (.+) FROM \"books\"").WillReturnRows(mockRows)
+	mock.ExpectQuery(`^SELECT (.+) FROM "book_genres"`).
+		WillReturnRows(sqlmock.NewRows([]string{"book_id", "genre_id"}).AddRow(id, genreID))
+	mock.ExpectQuery(`^SELECT (.+) FROM "genres" WHERE "genres"."id" = \$1 ORDER BY slug`).
+		WithArgs(genreID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "slug", "name"}).AddRow(genreID, "fantasy", "Fantasy"))
 
-	books, err := repo.List()
+	books, err := repo.List(nil)
 	testUtil.NoError(t, err)
 	testUtil.Equal(t, len(books), 2)
+	testUtil.Equal(t, len(books[0].Genres), 1)
+	testUtil.Equal(t, books[0].Genres[0].Slug, "fantasy")
+	testUtil.Equal(t, len(books[1].Genres), 0)
+}
+
+func TestRepository_ListFiltered(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	repo := book.NewRepository(db)
+
+	mockRows := sqlmock.NewRows([]string{"id", "title", "author"}).
+		AddRow(uuid.New(), "Book1", "Author1")
+
+	mock.ExpectQuery(`^SELECT (.+) FROM "books" WHERE LOWER\(author\) = LOWER\(\$1\) AND \(published_date >= \$2 AND published_date < \$3\)`).
+		WithArgs("Author1", mockDB.AnyTime{}, mockDB.AnyTime{}).
+		WillReturnRows(mockRows)
+	mock.ExpectQuery(`^SELECT (.+) FROM "book_genres"`).WillReturnRows(sqlmock.NewRows([]string{"book_id", "genre_id"}))
+
+	books, err := repo.List(&book.Filter{Author: "Author1", Decade: 1990})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, len(books), 1)
+}
+
+func TestRepository_ListSorted(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	repo := book.NewRepository(db)
+
+	mockRows := sqlmock.NewRows([]string{"id", "title", "author"}).
+		AddRow(uuid.New(), "Book1", "Author1")
+
+	mock.ExpectQuery(`^SELECT (.+) FROM "books" WHERE published_date >= \$1 AND published_date < \$2 (.+) ORDER BY "published_date" DESC,"title",id`).
+		WithArgs(mockDB.AnyTime{}, mockDB.AnyTime{}).
+		WillReturnRows(mockRows)
+	mock.ExpectQuery(`^SELECT (.+) FROM "book_genres"`).WillReturnRows(sqlmock.NewRows([]string{"book_id", "genre_id"}))
+
+	filter, err := book.NewFilter(url.Values{
+		"published_from": {"1990-01-01"},
+		"published_to":   {"1999-12-31"},
+		"sort":           {"published_date:desc,title"},
+	})
+	testUtil.NoError(t, err)
+
+	books, err := repo.List(filter)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, len(books), 1)
+}
+
+func TestRepository_ListPriced(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	repo := book.NewRepository(db)
+
+	mockRows := sqlmock.NewRows([]string{"id", "title", "price_amount", "price_currency"}).
+		AddRow(uuid.New(), "Book1", 1250, "EUR")
+
+	mock.ExpectQuery(`^SELECT (.+) FROM "books" WHERE price_currency = \$1 AND price_amount >= \$2 AND price_amount <= \$3 (.+) ORDER BY "price_amount",id`).
+		WithArgs("EUR", 999, 2000).
+		WillReturnRows(mockRows)
+	mock.ExpectQuery(`^SELECT (.+) FROM "book_genres"`).WillReturnRows(sqlmock.NewRows([]string{"book_id", "genre_id"}))
+
+	filter, err := book.NewFilter(url.Values{
+		"currency":  {"eur"},
+		"price_min": {"9.99"},
+		"price_max": {"20"},
+		"sort":      {"price"},
+	})
+	testUtil.NoError(t, err)
+
+	books, err := repo.List(filter)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, len(books), 1)
+	testUtil.Equal(t, books[0].Price.String(), "12.50 EUR")
+}
+
+func TestNewFilter_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"unknown sort field", url.Values{"sort": {"description"}}},
+		{"injected sort", url.Values{"sort": {"title; DROP TABLE books"}}},
+		{"unknown direction", url.Values{"sort": {"title:up"}}},
+		{"too many sort fields", url.Values{"sort": {"title,author,published_date,created_at"}}},
+		{"invalid date", url.Values{"published_from": {"1990"}}},
+		{"inverted range", url.Values{"published_from": {"2000-01-01"}, "published_to": {"1999-01-01"}}},
+		{"unknown currency", url.Values{"currency": {"ABC"}}},
+		{"price without currency", url.Values{"price_min": {"10"}}},
+		{"too many decimals", url.Values{"currency": {"JPY"}, "price_min": {"10.5"}}},
+		{"inverted price range", url.Values{"currency": {"EUR"}, "price_min": {"20"}, "price_max": {"10"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := book.NewFilter(tt.query)
+			testUtil.Equal(t, book.ErrInvalidFilter, err)
+		})
+	}
+}
+
+func TestRepository_Facets(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	repo := book.NewRepository(db)
+
+	mock.ExpectQuery(`^SELECT author AS value, COUNT\(\*\) AS count FROM "books" (.+) GROUP BY "author"`).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).AddRow("Author1", 2).AddRow("Author2", 1))
+	mock.ExpectQuery(`^SELECT CAST(.+) AS value, COUNT\(\*\) AS count FROM "books" (.+) GROUP BY "value"`).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).AddRow("1990", 3))
+	mock.ExpectQuery(`^SELECT genres.slug AS value, COUNT\(\*\) AS count FROM "genres" JOIN book_genres (.+) GROUP BY "genres"."slug"`).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).AddRow("fantasy", 2))
+	mock.ExpectQuery(`^SELECT CASE WHEN id IN \(SELECT book_id FROM loans WHERE returned_at IS NULL\) (.+) FROM "books" (.+) GROUP BY "value"`).
+		WithArgs(book.AvailabilityOnLoan, book.AvailabilityAvailable).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).AddRow("available", 2).AddRow("on_loan", 1))
+
+	facets, err := repo.Facets(nil)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 2, len(facets.Author))
+	testUtil.Equal(t, "1990", facets.Decade[0].Value)
+	testUtil.Equal(t, int64(3), facets.Decade[0].Count)
+	testUtil.Equal(t, "fantasy", facets.Genre[0].Value)
+	testUtil.Equal(t, int64(2), facets.Genre[0].Count)
+	testUtil.Equal(t, book.AvailabilityOnLoan, facets.Availability[1].Value)
+	testUtil.Equal(t, int64(1), facets.Availability[1].Count)
+}
+
+func TestRepository_FacetsFiltered(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	repo := book.NewRepository(db)
+
+	// Every facet counts only the books matching the filter.
+	filter, err := book.NewFilter(url.Values{"author": {"Author1"}})
+	testUtil.NoError(t, err)
+	mock.ExpectQuery(`^SELECT author AS value, (.+) WHERE LOWER\(author\) = LOWER\(\$1\)`).
+		WithArgs("Author1", 50).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).AddRow("Author1", 2))
+	mock.ExpectQuery(`^SELECT CAST(.+) WHERE LOWER\(author\) = LOWER\(\$1\)`).
+		WithArgs("Author1").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}))
+	mock.ExpectQuery(`^SELECT genres.slug (.+) WHERE book_genres.book_id IN \(SELECT "id" FROM "books" WHERE LOWER\(author\) = LOWER\(\$1\)`).
+		WithArgs("Author1", 50).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}))
+	mock.ExpectQuery(`^SELECT CASE (.+) WHERE LOWER\(author\) = LOWER\(\$3\)`).
+		WithArgs(book.AvailabilityOnLoan, book.AvailabilityAvailable, "Author1").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).AddRow("available", 2))
+
+	facets, err := repo.Facets(filter)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(facets.Availability))
+	testUtil.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestRepository_Create(t *testing.T) {
@@ -42,7 +218,7 @@ func TestRepository_Create(t *testing.T) {
 	id := uuid.New()
 	mock.ExpectBegin()
 	mock.ExpectExec("^INSERT INTO \"books\" ").
-		WithArgs(id, "Title", "Author", mockDB.AnyTime{}, "", "", mockDB.AnyTime{}, mockDB.AnyTime{}, nil).
+		WithArgs(id, "Title", "Author", mockDB.AnyTime{}, "", "", 0, "", "{}", "", "", 0, 0, mockDB.AnyTime{}, mockDB.AnyTime{}, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -64,8 +240,9 @@ func TestRepository_Read(t *testing.T) {
 		AddRow(id, "Book1", "Author1")
 
 	mock.ExpectQuery("^SELECT (.+) FROM \"books\" WHERE (.+)").
-		WithArgs(id).
+		WithArgs(id, 1).
 		WillReturnRows(mockRows)
+	mock.ExpectQuery(`^SELECT (.+) FROM "book_genres"`).WillReturnRows(sqlmock.NewRows([]string{"book_id", "genre_id"}))
 
 	book, err := repo.Read(id)
 	testUtil.NoError(t, err)
@@ -86,7 +263,7 @@ func TestRepository_Update(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectExec("^UPDATE \"books\" SET").
-		WithArgs("Title", "Author", mockDB.AnyTime{}, "", "", mockDB.AnyTime{}, id).
+		WithArgs("Title", "Author", mockDB.AnyTime{}, "", "", 0, "", "{}", mockDB.AnyTime{}, id).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -118,3 +295,65 @@ func TestRepository_Delete(t *testing.T) {
 	testUtil.NoError(t, err)
 	testUtil.Equal(t, 1, rows)
 }
+
+func TestRepository_WithTx(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	repo := book.NewRepository(db)
+	ctx := context.Background()
+
+	// The steps run in one transaction, committed when they succeed.
+	id := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec(`^INSERT INTO "books" `).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`^UPDATE "books" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	err = repo.WithTx(ctx, func(r *book.Repository) error {
+		if _, err := r.Create(&book.Book{ID: id, Title: "Title", Author: "Author"}); err != nil {
+			return err
+		}
+		_, err := r.Delete(id)
+		return err
+	})
+	testUtil.NoError(t, err)
+
+	// An error rolls them back.
+	errStep := errors.New("step failed")
+	mock.ExpectBegin()
+	mock.ExpectExec(`^INSERT INTO "books" `).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+	err = repo.WithTx(ctx, func(r *book.Repository) error {
+		if _, err := r.Create(&book.Book{ID: uuid.New(), Title: "Title", Author: "Author"}); err != nil {
+			return err
+		}
+		return errStep
+	})
+	testUtil.Equal(t, errStep, err)
+
+	// So does a panic, which goes on up the stack.
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	func() {
+		defer func() {
+			testUtil.Equal(t, any("boom"), recover())
+		}()
+		repo.WithTx(ctx, func(r *book.Repository) error {
+			panic("boom")
+		})
+	}()
+
+	// And a context done before the commit.
+	cctx, cancel := context.WithCancel(ctx)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	err = repo.WithTx(cctx, func(r *book.Repository) error {
+		cancel()
+		return nil
+	})
+	testUtil.Equal(t, context.Canceled, err)
+
+	testUtil.NoError(t, mock.ExpectationsWereMet())
+}
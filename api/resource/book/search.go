@@ -0,0 +1,178 @@
+package book
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/scope"
+	"hello/api/middleware/warning"
+	e "hello/api/resource/common/err"
+	"hello/event"
+	"hello/fieldpolicy"
+	"hello/search"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type SearchDTO struct {
+	*DTO
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+type SearchAPI struct {
+	repository *Repository
+	index      search.Index
+	policy     fieldpolicy.Policy
+}
+
+func NewSearchAPI(db *gorm.DB, idx search.Index, policy fieldpolicy.Policy) *SearchAPI {
+	return &SearchAPI{
+		repository: NewRepository(db),
+		index:      idx,
+		policy:     policy,
+	}
+}
+
+func (b *Book) ToDocument() search.Document {
+	return search.Document{
+		ID:          b.ID.String(),
+		Title:       b.Title,
+		Author:      b.Author,
+		Description: b.Description,
+	}
+}
+
+// SubscribeSearch keeps idx in step with book writes.
+func SubscribeSearch(bus event.Bus, idx search.Index) {
+	index := func(ctx context.Context, ev event.Event) error {
+		b, ok := ev.Payload.(*Book)
+		if !ok {
+			return fmt.Errorf("search: unexpected payload %T for %s", ev.Payload, ev.Name)
+		}
+		return idx.Index(ctx, b.ToDocument())
+	}
+
+	bus.Subscribe(EventCreated, index)
+	bus.Subscribe(EventUpdated, index)
+	bus.Subscribe(EventRestored, index)
+	bus.Subscribe(EventDeleted, func(ctx context.Context, ev event.Event) error {
+		return idx.Delete(ctx, ev.AggregateID)
+	})
+}
+
+// Search godoc
+//
+//	@summary        Search books
+//	@description    Full-text search over title, author and description, best matches first, with the matched words of each field marked in highlights
+//	@tags           books
+//	@accept         json
+//	@produce        json
+//	@param          q       query   string  true    "Search query"
+//	@param          limit   query   int     false   "Max results (default 20, max 100)"
+//	@success        200 {array}     SearchDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/search [get]
+func (api *SearchAPI) Search(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query().Get("q")
+	limit := defaultSearchLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxSearchLimit)
+		if l > maxSearchLimit {
+			warning.Add(r, warning.CodeLimitClamped, fmt.Sprintf("limit reduced to %d", maxSearchLimit))
+		}
+	}
+
+	hits, err := api.index.Search(r.Context(), q, limit)
+	if err != nil {
+		if errors.Is(err, search.ErrInvalidQuery) {
+			return e.RespInvalidSearchQuery
+		}
+
+		return e.RespSearchIndexFailure
+	}
+
+	ids := make([]uuid.UUID, 0, len(hits))
+	for _, h := range hits {
+		if id, err := uuid.Parse(h.ID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	books, err := api.repository.WithContext(r.Context()).ReadMany(ids)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	byID := make(map[string]*Book, len(books))
+	for _, b := range books {
+		byID[b.ID.String()] = b
+	}
+
+	ranked := make(Books, 0, len(hits))
+	matched := make([]search.Hit, 0, len(hits))
+	for _, h := range hits {
+		if b, ok := byID[h.ID]; ok {
+			ranked = append(ranked, b)
+			matched = append(matched, h)
+		}
+	}
+
+	bookDtos, err := toDtos(r.Context(), ranked)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	// Highlights quote field contents, so drop those of hidden fields too.
+	hidden := api.policy.Hidden(Resource, scope.From(r.Context()))
+
+	dtos := make([]*SearchDTO, len(matched))
+	for i, h := range matched {
+		for _, field := range hidden {
+			delete(h.Fragments, field)
+		}
+		dtos[i] = &SearchDTO{DTO: bookDtos[i], Score: h.Score, Highlights: h.Fragments}
+	}
+
+	if err := encode(w, r, api.policy, dtos); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Rebuild godoc
+//
+//	@summary        Rebuild search index
+//	@description    Rebuild the search index from the database
+//	@tags           admin
+//	@success        204
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/search/rebuild [post]
+func (api *SearchAPI) Rebuild(w http.ResponseWriter, r *http.Request) error {
+	books, err := api.repository.WithContext(r.Context()).List(nil)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	docs := make([]search.Document, len(books))
+	for i, b := range books {
+		docs[i] = b.ToDocument()
+	}
+
+	if err := api.index.Rebuild(r.Context(), docs); err != nil {
+		return e.RespSearchIndexFailure
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
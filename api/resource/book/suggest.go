@@ -0,0 +1,138 @@
+package book
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hello/api/middleware/warning"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+	"hello/util/trie"
+)
+
+const (
+	SuggestionKindTitle  = "title"
+	SuggestionKindAuthor = "author"
+
+	defaultSuggestLimit = 8
+	maxSuggestLimit     = 20
+)
+
+type Suggestion struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Kind  string `json:"kind"`
+}
+
+// Suggester answers typeahead queries from an in-memory trie that is rebuilt
+// from the database on an interval, so lookups never touch the DB.
+type Suggester struct {
+	repository *Repository
+	trie       atomic.Pointer[trie.Trie[Suggestion]]
+}
+
+func NewSuggester(db *gorm.DB) *Suggester {
+	s := &Suggester{repository: NewRepository(db)}
+	s.trie.Store(trie.New[Suggestion]())
+	return s
+}
+
+// Refresh rebuilds the trie. Every word of a title or author name is indexed
+// so that "pot" matches "Harry Potter".
+func (s *Suggester) Refresh() error {
+	books, err := s.repository.List(nil)
+	if err != nil {
+		return err
+	}
+
+	t := trie.New[Suggestion]()
+	authors := make(map[string]bool)
+	for _, b := range books {
+		insertWords(t, b.Title, Suggestion{ID: idcodec.Encode(b.ID), Label: b.Title, Kind: SuggestionKindTitle})
+
+		if key := strings.ToLower(b.Author); !authors[key] {
+			authors[key] = true
+			insertWords(t, b.Author, Suggestion{ID: idcodec.Encode(b.ID), Label: b.Author, Kind: SuggestionKindAuthor})
+		}
+	}
+
+	s.trie.Store(t)
+	return nil
+}
+
+func insertWords(t *trie.Trie[Suggestion], label string, sg Suggestion) {
+	words := strings.Fields(label)
+	for i := range words {
+		t.Insert(strings.Join(words[i:], " "), sg)
+	}
+}
+
+// Run refreshes the trie immediately and then every interval until ctx is done.
+func (s *Suggester) Run(ctx context.Context, interval time.Duration) {
+	if err := s.Refresh(); err != nil {
+		log.Printf("suggest refresh: %s", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil {
+				log.Printf("suggest refresh: %s", err)
+			}
+		}
+	}
+}
+
+// Suggest godoc
+//
+//	@summary        Suggest books
+//	@description    Typeahead suggestions of titles and authors by prefix
+//	@tags           books
+//	@produce        json
+//	@param          q       query   string  true    "Prefix"
+//	@param          limit   query   int     false   "Max suggestions (default 8, max 20)"
+//	@success        200 {array}     Suggestion
+//	@failure        500 {object}    err.Problem
+//	@router         /books/suggest [get]
+func (s *Suggester) Suggest(w http.ResponseWriter, r *http.Request) error {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit := defaultSuggestLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxSuggestLimit)
+		if l > maxSuggestLimit {
+			warning.Add(r, warning.CodeLimitClamped, fmt.Sprintf("limit reduced to %d", maxSuggestLimit))
+		}
+	}
+
+	suggestions := make([]Suggestion, 0, limit)
+	if q != "" {
+		// A book can match through several of its words, so over-fetch and
+		// drop duplicates.
+		seen := make(map[Suggestion]bool)
+		for _, sg := range s.trie.Load().Find(q, limit*4) {
+			if !seen[sg] && len(suggestions) < limit {
+				seen[sg] = true
+				suggestions = append(suggestions, sg)
+			}
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
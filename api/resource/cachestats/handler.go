@@ -0,0 +1,37 @@
+package cachestats
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "hello/api/resource/common/err"
+	"hello/cache"
+)
+
+// API shows how often cached reads are answered from the cache, so that
+// operators can tell whether its TTLs pay off.
+type API struct {
+	cache *cache.Counted
+}
+
+func New(c *cache.Counted) *API {
+	return &API{
+		cache: c,
+	}
+}
+
+// Read godoc
+//
+//	@summary        Cache statistics
+//	@description    Hits, misses and failed lookups of the book read cache since the instance started
+//	@tags           admin
+//	@produce        json
+//	@success        200 {object}    cache.Stats
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/cache [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	if err := json.NewEncoder(w).Encode(api.cache.Stats()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/cache"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+)
+
+type API struct {
+	repository *Repository
+}
+
+func New(db *gorm.DB) *API {
+	return &API{
+		repository: NewRepository(db),
+	}
+}
+
+func (en *Entry) ToDto() *DTO {
+	return &DTO{
+		ID:            idcodec.Encode(en.ID),
+		Title:         en.Title,
+		Author:        en.Author,
+		PublishedDate: en.PublishedDate.Format("2006-01-02"),
+		ImageURL:      en.ImageURL,
+		Description:   en.Description,
+		Genres:        en.GenreSlugs(),
+	}
+}
+
+// GenreSlugs returns the slugs of the book's genres.
+func (en *Entry) GenreSlugs() []string {
+	if en.Genres == "" {
+		return []string{}
+	}
+	return strings.Split(en.Genres, ",")
+}
+
+func (es Entries) ToDto() []*DTO {
+	dtos := make([]*DTO, len(es))
+	for i, v := range es {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
+
+// List godoc
+//
+//	@summary        List catalog books
+//	@description    List books from the denormalized public catalog
+//	@tags           catalog
+//	@accept         json
+//	@produce        json
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /catalog/books [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	entries, err := api.repository.WithContext(r.Context()).List()
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprint(w, "[]")
+		return nil
+	}
+
+	if err := json.NewEncoder(w).Encode(entries.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Read godoc
+//
+//	@summary        Read catalog book
+//	@description    Read a book from the denormalized public catalog
+//	@tags           catalog
+//	@accept         json
+//	@produce        json
+//	@param          id	path        string  true    "Book ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /catalog/books/{id} [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	entry, err := api.repository.WithContext(r.Context()).Read(id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataAccessFailure
+	}
+
+	// Entries only change with their book's events, which set UpdatedAt.
+	cache.LastModified(w, entry.UpdatedAt)
+	if err := json.NewEncoder(w).Encode(entry.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
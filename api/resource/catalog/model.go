@@ -0,0 +1,38 @@
+package catalog
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DTO struct {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	Author        string   `json:"author"`
+	PublishedDate string   `json:"published_date"`
+	ImageURL      string   `json:"image_url"`
+	Description   string   `json:"description"`
+	Genres        []string `json:"genres"`
+}
+
+// Entry is a denormalized, read-only row of the public catalog. It is written
+// only by the projector in response to book events.
+type Entry struct {
+	ID            uuid.UUID `gorm:"primarykey"`
+	Title         string
+	Author        string
+	PublishedDate time.Time
+	ImageURL      string
+	Description   string
+	// Genres are the slugs of the book's genres, sorted and joined with
+	// commas.
+	Genres    string
+	UpdatedAt time.Time
+}
+
+type Entries []*Entry
+
+func (Entry) TableName() string {
+	return "catalog_books"
+}
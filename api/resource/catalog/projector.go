@@ -0,0 +1,85 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"hello/api/resource/book"
+	"hello/api/resource/genre"
+	"hello/event"
+)
+
+type projector struct {
+	repository *Repository
+}
+
+// Subscribe keeps the catalog read model in step with the book write model,
+// and with the genres books are tagged with.
+func Subscribe(bus event.Bus, repository *Repository) {
+	p := &projector{repository: repository}
+
+	bus.Subscribe(book.EventCreated, p.upsert)
+	bus.Subscribe(book.EventUpdated, p.upsert)
+	bus.Subscribe(book.EventRestored, p.upsert)
+	bus.Subscribe(book.EventDeleted, p.delete)
+	bus.Subscribe(genre.EventDeleted, p.regenre)
+}
+
+// The projection keeps the catalog in line with books already written, so
+// it is not cancelled with the request that published the event.
+func (p *projector) upsert(ctx context.Context, e event.Event) error {
+	b, ok := e.Payload.(*book.Book)
+	if !ok {
+		return fmt.Errorf("catalog: unexpected payload %T for %s", e.Payload, e.Name)
+	}
+
+	repository := p.repository.WithContext(context.WithoutCancel(ctx))
+	slugs, err := repository.GenreSlugs(b.ID)
+	if err != nil {
+		return fmt.Errorf("catalog: genres of %s: %w", b.ID, err)
+	}
+
+	return repository.Upsert(&Entry{
+		ID:            b.ID,
+		Title:         b.Title,
+		Author:        b.Author,
+		PublishedDate: b.PublishedDate,
+		ImageURL:      b.ImageURL,
+		Description:   b.Description,
+		Genres:        strings.Join(slugs, ","),
+		UpdatedAt:     e.OccurredAt,
+	})
+}
+
+func (p *projector) delete(ctx context.Context, e event.Event) error {
+	id, err := uuid.Parse(e.AggregateID)
+	if err != nil {
+		return fmt.Errorf("catalog: %w", err)
+	}
+
+	return p.repository.WithContext(context.WithoutCancel(ctx)).Delete(id)
+}
+
+// regenre reprojects the genres of the books a deleted genre was removed
+// from.
+func (p *projector) regenre(ctx context.Context, e event.Event) error {
+	d, ok := e.Payload.(*genre.DeletedDTO)
+	if !ok {
+		return fmt.Errorf("catalog: unexpected payload %T for %s", e.Payload, e.Name)
+	}
+
+	repository := p.repository.WithContext(context.WithoutCancel(ctx))
+	for _, id := range d.BookIDs {
+		slugs, err := repository.GenreSlugs(id)
+		if err != nil {
+			return fmt.Errorf("catalog: genres of %s: %w", id, err)
+		}
+		if err := repository.UpdateGenres(id, strings.Join(slugs, ","), e.OccurredAt); err != nil {
+			return fmt.Errorf("catalog: genres of %s: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package catalog_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"hello/api/resource/book"
+	"hello/api/resource/catalog"
+	"hello/api/resource/genre"
+	"hello/event"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+)
+
+func TestSubscribe_Created(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	bus := event.NewBus()
+	catalog.Subscribe(bus, catalog.NewRepository(db))
+
+	id := uuid.New()
+	mock.ExpectQuery("^SELECT \"genres\".\"slug\" FROM \"book_genres\"").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"slug"}).AddRow("classics").AddRow("science-fiction"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO \"catalog_books\" (.+) ON CONFLICT").
+		WithArgs(id, "Title", "Author", mockDB.AnyTime{}, "", "", "classics,science-fiction", mockDB.AnyTime{}, mockDB.AnyTime{}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	b := &book.Book{ID: id, Title: "Title", Author: "Author", PublishedDate: time.Now()}
+	err = bus.Publish(context.Background(), event.New(book.EventCreated, id.String(), b))
+	testUtil.NoError(t, err)
+	testUtil.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubscribe_Deleted(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	bus := event.NewBus()
+	catalog.Subscribe(bus, catalog.NewRepository(db))
+
+	id := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM \"catalog_books\"").
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = bus.Publish(context.Background(), event.New(book.EventDeleted, id.String(), nil))
+	testUtil.NoError(t, err)
+	testUtil.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubscribe_GenreDeleted(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+
+	bus := event.NewBus()
+	catalog.Subscribe(bus, catalog.NewRepository(db))
+
+	id := uuid.New()
+	mock.ExpectQuery("^SELECT \"genres\".\"slug\" FROM \"book_genres\"").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"slug"}).AddRow("science-fiction"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE \"catalog_books\" SET \"genres\"=\\$1,\"updated_at\"=\\$2 WHERE id = \\$3").
+		WithArgs("science-fiction", mockDB.AnyTime{}, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	d := &genre.DeletedDTO{Slug: "classics", BookIDs: []uuid.UUID{id}}
+	err = bus.Publish(context.Background(), event.New(genre.EventDeleted, uuid.NewString(), d))
+	testUtil.NoError(t, err)
+	testUtil.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEntry_ToDto(t *testing.T) {
+	t.Parallel()
+
+	dto := (&catalog.Entry{ID: uuid.New(), Genres: "classics,science-fiction"}).ToDto()
+	testUtil.Equal(t, 2, len(dto.Genres))
+	testUtil.Equal(t, "science-fiction", dto.Genres[1])
+	testUtil.Equal(t, 0, len((&catalog.Entry{ID: uuid.New()}).ToDto().Genres))
+}
@@ -0,0 +1,70 @@
+package catalog
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) List() (Entries, error) {
+	entries := make([]*Entry, 0)
+	if err := r.db.Order("title").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *Repository) Read(id uuid.UUID) (*Entry, error) {
+	entry := &Entry{}
+	if err := r.db.Where("id = ?", id).First(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// GenreSlugs returns the slugs of the genres of the book id, sorted. They
+// are read from the book write model, since book events carry genres for
+// some writes only.
+func (r *Repository) GenreSlugs(id uuid.UUID) ([]string, error) {
+	slugs := make([]string, 0)
+	err := r.db.Table("book_genres").
+		Joins("JOIN genres ON genres.id = book_genres.genre_id").
+		Where("book_genres.book_id = ?", id).
+		Order("genres.slug").
+		Pluck("genres.slug", &slugs).Error
+	return slugs, err
+}
+
+func (r *Repository) Upsert(entry *Entry) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error
+}
+
+// UpdateGenres sets the genres of the entry of the book id, if it has one.
+func (r *Repository) UpdateGenres(id uuid.UUID, genres string, at time.Time) error {
+	return r.db.Model(&Entry{}).Where("id = ?", id).Updates(map[string]any{"genres": genres, "updated_at": at}).Error
+}
+
+func (r *Repository) Delete(id uuid.UUID) error {
+	return r.db.Where("id = ?", id).Delete(&Entry{}).Error
+}
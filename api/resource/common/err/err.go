@@ -1,38 +1,258 @@
+// Package err writes error responses in the problem details format of
+// RFC 7807. Every error has a machine-readable code next to its HTTP status
+// and a message for people; behind the request logger it also carries the
+// request ID, so that a reported error can be found in the logs.
+//
+// Handlers either answer with one of the helpers, e.g.
+// BadRequest(w, RespInvalidFilter), or are HandlerFuncs returning their
+// errors for Handle to map to a response.
 package err
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
 
-type Error struct {
-	Error string `json:"error"`
+	"hello/api/middleware/logger"
+)
+
+// ContentType is the media type of error responses.
+const ContentType = "application/problem+json"
+
+// StatusClientClosedRequest is the status, borrowed from nginx, of requests
+// abandoned by their client before they were answered. The client never
+// sees it; it is there for the logs.
+const StatusClientClosedRequest = 499
+
+// Problem is an error response. Type is always "about:blank", making Title
+// the text of Status; Code tells errors of the same status apart.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is one reason a request failed validation. Field is empty
+// when the reason is not tied to a single field.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// New returns a problem with a code and a message. Status is the one Write
+// answers with; the helpers below answer with their own.
+func New(status int, code, detail string) *Problem {
+	return &Problem{Type: "about:blank", Title: statusText(status), Status: status, Code: code, Detail: detail}
 }
 
-type Errors struct {
-	Error []string `json:"errors"`
+func (p *Problem) Error() string {
+	return p.Code + ": " + p.Detail
+}
+
+// WithErrors returns a copy of p listing errs.
+func (p *Problem) WithErrors(errs []FieldError) *Problem {
+	c := *p
+	c.Errors = errs
+	return &c
+}
+
+// Messages returns field errors for messages not tied to a single field.
+func Messages(msgs []string) []FieldError {
+	errs := make([]FieldError, len(msgs))
+	for i, msg := range msgs {
+		errs[i] = FieldError{Message: msg}
+	}
+	return errs
 }
 
 var (
-	RespDBDataInsertFailure = []byte(`{"error": "db data insert failure"}`)
-	RespDBDataAccessFailure = []byte(`{"error": "db data access failure"}`)
-	RespDBDataUpdateFailure = []byte(`{"error": "db data update failure"}`)
-	RespDBDataRemoveFailure = []byte(`{"error": "db data remove failure"}`)
+	RespInternal         = New(http.StatusInternalServerError, "internal", "internal server error")
+	RespNotFound         = New(http.StatusNotFound, "not_found", "resource not found")
+	RespConflict         = New(http.StatusConflict, "conflict", "the request conflicts with the current state of the resource")
+	RespValidationFailed = New(http.StatusUnprocessableEntity, "validation_failed", "the request failed validation")
+	RespInvalidParams    = New(http.StatusBadRequest, "invalid_params", "the request parameters do not match the API spec")
+	RespClientClosed     = New(StatusClientClosedRequest, "client_closed_request", "the client closed the request before it was answered")
+	RespTimeout          = New(http.StatusServiceUnavailable, "timeout", "the database did not answer in time, retry later")
+
+	RespDBDataInsertFailure = New(http.StatusInternalServerError, "db_data_insert_failure", "db data insert failure")
+	RespDBDataAccessFailure = New(http.StatusInternalServerError, "db_data_access_failure", "db data access failure")
+	RespDBDataUpdateFailure = New(http.StatusInternalServerError, "db_data_update_failure", "db data update failure")
+	RespDBDataRemoveFailure = New(http.StatusInternalServerError, "db_data_remove_failure", "db data remove failure")
+
+	RespJSONEncodeFailure = New(http.StatusInternalServerError, "json_encode_failure", "json encode failure")
+	RespInvalidBody       = New(http.StatusBadRequest, "invalid_body", "request body could not be read")
+	RespJSONDecodeFailure = New(http.StatusInternalServerError, "json_decode_failure", "json decode failure")
+
+	RespInvalidURLParamID = New(http.StatusBadRequest, "invalid_url_param_id", "invalid url param-id")
+	RespInvalidFilter     = New(http.StatusBadRequest, "invalid_filter", "invalid filter")
+	RespUnsupportedLocale = New(http.StatusBadRequest, "unsupported_locale", "unsupported locale")
+
+	RespDuplicateCustomField = New(http.StatusConflict, "duplicate_custom_field", "custom field already defined")
+
+	RespInvalidSearchQuery = New(http.StatusBadRequest, "invalid_search_query", "invalid search query")
+	RespSearchIndexFailure = New(http.StatusInternalServerError, "search_index_failure", "search index failure")
+
+	RespInvalidUpload        = New(http.StatusBadRequest, "invalid_upload", "invalid upload")
+	RespUnsupportedMediaType = New(http.StatusUnsupportedMediaType, "unsupported_media_type", "unsupported media type")
+	RespFileTooLarge         = New(http.StatusRequestEntityTooLarge, "file_too_large", "file too large")
+	RespInfectedFile         = New(http.StatusForbidden, "infected_file", "file failed virus scan")
+	RespScanPending          = New(http.StatusConflict, "scan_pending", "file not yet scanned")
+	RespStorageFailure       = New(http.StatusInternalServerError, "storage_failure", "storage failure")
+
+	RespAuthenticationRequired  = New(http.StatusUnauthorized, "authentication_required", "authentication required")
+	RespDuplicateEmail          = New(http.StatusConflict, "duplicate_email", "email already registered")
+	RespInvalidToken            = New(http.StatusBadRequest, "invalid_token", "invalid or expired token")
+	RespEmailNotVerified        = New(http.StatusForbidden, "email_not_verified", "email not verified")
+	RespInsufficientRole        = New(http.StatusForbidden, "insufficient_role", "insufficient role")
+	RespPasswordHashFailure     = New(http.StatusInternalServerError, "password_hash_failure", "password hash failure")
+	RespMailDeliveryFailure     = New(http.StatusInternalServerError, "mail_delivery_failure", "mail delivery failure")
+	RespInvalidInvitationStatus = New(http.StatusBadRequest, "invalid_invitation_status", "invalid invitation status")
+	RespInvalidCredential       = New(http.StatusUnauthorized, "invalid_credential", "invalid credential")
+	RespIncorrectPassword       = New(http.StatusForbidden, "incorrect_password", "incorrect password")
+	RespDuplicateServiceAccount = New(http.StatusConflict, "duplicate_service_account", "service account already exists")
+	RespSessionFailure          = New(http.StatusInternalServerError, "session_failure", "session failure")
+
+	RespInvalidISBN         = New(http.StatusBadRequest, "invalid_isbn", "invalid isbn")
+	RespMetadataRateLimited = New(http.StatusServiceUnavailable, "metadata_rate_limited", "metadata provider quota exhausted")
+	RespMetadataFailure     = New(http.StatusBadGateway, "metadata_failure", "metadata provider failure")
+
+	RespEmptyCart          = New(http.StatusConflict, "empty_cart", "cart is empty")
+	RespUnpricedBook       = New(http.StatusConflict, "unpriced_book", "cart holds a book without a price")
+	RespMixedCurrencies    = New(http.StatusConflict, "mixed_currencies", "cart holds books priced in different currencies")
+	RespOutOfStock         = New(http.StatusConflict, "out_of_stock", "not enough copies in stock")
+	RespInvalidTransition  = New(http.StatusConflict, "invalid_transition", "event not allowed in the order status")
+	RespOrderStatusChanged = New(http.StatusConflict, "order_status_changed", "order status changed concurrently")
+
+	RespInvalidWebhookSignature = New(http.StatusBadRequest, "invalid_webhook_signature", "missing, invalid or stale webhook signature")
+	RespInvalidPaymentEvent     = New(http.StatusBadRequest, "invalid_payment_event", "invalid payment event")
+	RespPaymentModeMismatch     = New(http.StatusBadRequest, "payment_mode_mismatch", "payment event mode does not match the sandbox setting")
+
+	RespUnknownRecipient = New(http.StatusBadRequest, "unknown_recipient", "unknown recipient")
+	RespSelfTransfer     = New(http.StatusBadRequest, "self_transfer", "cannot transfer a copy to its owner")
+	RespTransferPending  = New(http.StatusConflict, "transfer_pending", "copy already has a pending transfer")
+	RespTransferClosed   = New(http.StatusConflict, "transfer_closed", "transfer is no longer pending")
+	RespCopyChangedHands = New(http.StatusConflict, "copy_changed_hands", "copy no longer belongs to the sender")
+
+	RespInvalidExportFormat = New(http.StatusBadRequest, "invalid_export_format", "export format must be json or markdown")
+
+	RespContentRejected = New(http.StatusUnprocessableEntity, "content_rejected", "the text contains terms that are not allowed")
+
+	RespBookHasAttachments = New(http.StatusConflict, "book_has_attachments", "book has attachments or uploads; delete them first")
+	RespInvalidBulkSize    = New(http.StatusBadRequest, "invalid_bulk_size", "bulk requests take 1 to 500 items")
+
+	RespInvalidCatalogFormat = New(http.StatusBadRequest, "invalid_catalog_format", "export format must be csv")
+	RespInvalidImportFile    = New(http.StatusBadRequest, "invalid_import_file", "import must be a multipart upload with a CSV file in the file field")
+	RespInvalidCSV           = New(http.StatusBadRequest, "invalid_csv", "file is not valid CSV with title, author and published_date columns")
+	RespImportTooLarge       = New(http.StatusRequestEntityTooLarge, "import_too_large", "import is limited to 10000 rows and 10 MiB")
+
+	RespInvalidEventID  = New(http.StatusBadRequest, "invalid_event_id", "invalid book_id or order_id in event")
+	RespEventBufferFull = New(http.StatusServiceUnavailable, "event_buffer_full", "event buffer full, retry later")
+
+	RespAuditFailure = New(http.StatusInternalServerError, "audit_failure", "the change was saved but could not be written to the audit log")
+
+	RespInvalidExperiment = New(http.StatusBadRequest, "invalid_experiment", "experiment key and variant names must be unique lowercase identifiers")
+
+	RespInvalidHoldKind = New(http.StatusBadRequest, "invalid_hold_kind", "legal hold kind must be book or user")
+	RespAlreadyOnHold   = New(http.StatusConflict, "already_on_hold", "record is already under legal hold")
+	RespOnLegalHold     = New(http.StatusConflict, "on_legal_hold", "record is under legal hold and cannot be changed or deleted")
+
+	RespDuplicateGenre = New(http.StatusConflict, "duplicate_genre", "genre already exists")
 
-	RespJSONEncodeFailure = []byte(`{"error": "json encode failure"}`)
-	RespJSONDecodeFailure = []byte(`{"error": "json decode failure"}`)
+	RespDuplicateReview = New(http.StatusConflict, "duplicate_review", "you already reviewed this book; delete the review to write another")
 
-	RespInvalidURLParamID = []byte(`{"error": "invalid url param-id"}`)
+	RespBookOnLoan    = New(http.StatusConflict, "book_on_loan", "book is on loan; place a hold to borrow it once it is returned")
+	RespLoanReturned  = New(http.StatusConflict, "loan_returned", "loan already returned")
+	RespBookHeld      = New(http.StatusConflict, "book_held", "book is held for another reader until they check it out or their hold expires")
+	RespBookAvailable = New(http.StatusConflict, "book_available", "book is available; check it out instead of placing a hold")
+	RespBorrowedByYou = New(http.StatusConflict, "borrowed_by_you", "you have this book on loan")
+	RespDuplicateHold = New(http.StatusConflict, "duplicate_hold", "you already hold this book")
+
+	RespMergeIntoSelf    = New(http.StatusBadRequest, "merge_into_self", "a book cannot be merged into itself")
+	RespBothOnLoan       = New(http.StatusConflict, "both_on_loan", "both books are on loan; merge them once one is returned")
+	RespInvalidThreshold = New(http.StatusBadRequest, "invalid_threshold", "threshold must be a number above 0 and at most 1")
+
+	RespPreconditionFailed = New(http.StatusPreconditionFailed, "precondition_failed", "the resource has changed since it was read; read it again and retry")
+
+	RespPatchConflict = New(http.StatusConflict, "patch_conflict", "patch test failed or a path it changes does not exist")
+
+	RespInvalidAsOf = New(http.StatusBadRequest, "invalid_as_of", "as_of must be an RFC 3339 timestamp")
 )
 
-func ServerError(w http.ResponseWriter, reps []byte) {
-	w.WriteHeader(http.StatusInternalServerError)
-	w.Write(reps)
+func ServerError(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusInternalServerError, p)
+}
+
+func BadRequest(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusBadRequest, p)
+}
+
+func Unauthorized(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusUnauthorized, p)
+}
+
+func Forbidden(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusForbidden, p)
+}
+
+func NotFound(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusNotFound, p)
+}
+
+func Conflict(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusConflict, p)
+}
+
+func Gone(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusGone, p)
 }
 
-func BadRequest(w http.ResponseWriter, reps []byte) {
-	w.WriteHeader(http.StatusBadRequest)
-	w.Write(reps)
+func PreconditionFailed(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusPreconditionFailed, p)
+}
+
+func PayloadTooLarge(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusRequestEntityTooLarge, p)
+}
+
+func UnsupportedMediaType(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusUnsupportedMediaType, p)
+}
+
+func TooManyRequests(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusTooManyRequests, p)
+}
+
+func BadGateway(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusBadGateway, p)
+}
+
+func ServiceUnavailable(w http.ResponseWriter, p *Problem) {
+	write(w, http.StatusServiceUnavailable, p)
+}
+
+// ValidationErrors answers 422 listing errs.
+func ValidationErrors(w http.ResponseWriter, errs []FieldError) {
+	write(w, http.StatusUnprocessableEntity, RespValidationFailed.WithErrors(errs))
+}
+
+// write sends p with status, and with the request ID set by the request
+// logger.
+func write(w http.ResponseWriter, status int, p *Problem) {
+	c := *p
+	c.Status, c.Title = status, statusText(status)
+	c.RequestID = w.Header().Get(logger.Header)
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&c)
 }
 
-func ValidationErrors(w http.ResponseWriter, reps []byte) {
-	w.WriteHeader(http.StatusUnprocessableEntity)
-	w.Write(reps)
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
 }
@@ -0,0 +1,84 @@
+package err_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"hello/api/middleware/logger"
+	e "hello/api/resource/common/err"
+	testUtil "hello/util/test"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"problem", e.RespInvalidFilter, http.StatusBadRequest, "invalid_filter"},
+		{"wrapped problem", fmt.Errorf("list: %w", e.RespDuplicateGenre), http.StatusConflict, "duplicate_genre"},
+		{"record not found", fmt.Errorf("read: %w", gorm.ErrRecordNotFound), http.StatusNotFound, "not_found"},
+		{"client gone", fmt.Errorf("list: %w", context.Canceled), e.StatusClientClosedRequest, "client_closed_request"},
+		{"timed out", fmt.Errorf("list: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, "timeout"},
+		{"other error", errors.New("boom"), http.StatusInternalServerError, "internal"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			w.Header().Set(logger.Header, "req-1")
+			e.Write(w, tc.err)
+
+			testUtil.Equal(t, tc.status, w.Code)
+			testUtil.Equal(t, e.ContentType, w.Header().Get("Content-Type"))
+
+			var p e.Problem
+			testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			testUtil.Equal(t, "about:blank", p.Type)
+			title := http.StatusText(tc.status)
+			if tc.status == e.StatusClientClosedRequest {
+				title = "Client Closed Request"
+			}
+			testUtil.Equal(t, title, p.Title)
+			testUtil.Equal(t, tc.status, p.Status)
+			testUtil.Equal(t, tc.code, p.Code)
+			testUtil.Equal(t, "req-1", p.RequestID)
+		})
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	t.Parallel()
+
+	// Helpers answer with their own status, whatever the problem's.
+	w := httptest.NewRecorder()
+	e.BadRequest(w, e.RespJSONDecodeFailure)
+	var p e.Problem
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	testUtil.Equal(t, http.StatusBadRequest, w.Code)
+	testUtil.Equal(t, http.StatusBadRequest, p.Status)
+	testUtil.Equal(t, "json_decode_failure", p.Code)
+	testUtil.Equal(t, "", p.RequestID)
+
+	w = httptest.NewRecorder()
+	e.ValidationErrors(w, []e.FieldError{{Field: "title", Message: "title is a required field"}})
+	p = e.Problem{}
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	testUtil.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	testUtil.Equal(t, "validation_failed", p.Code)
+	testUtil.Equal(t, 1, len(p.Errors))
+	testUtil.Equal(t, "title", p.Errors[0].Field)
+
+	// Shared problems are not changed by what is written.
+	testUtil.Equal(t, 0, len(e.RespValidationFailed.Errors))
+	testUtil.Equal(t, http.StatusInternalServerError, e.RespJSONDecodeFailure.Status)
+}
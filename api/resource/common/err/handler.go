@@ -0,0 +1,106 @@
+package err
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// HandlerFunc is a handler returning its error for Handle to answer. It
+// must not have written a response when it returns one.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handle adapts h to an http.HandlerFunc, answering its errors with Respond.
+func Handle(h HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			Respond(w, r, err)
+		}
+	}
+}
+
+// Mapper returns the problem err stands for, or nil for errors it does not
+// know.
+type Mapper func(r *http.Request, err error) *Problem
+
+// Is returns a mapper answering p for errors that are target or wrap it.
+// Resources map their domain errors with it, e.g.
+// Is(ErrSelfTransfer, RespSelfTransfer).
+func Is(target error, p *Problem) Mapper {
+	return func(_ *http.Request, err error) *Problem {
+		if errors.Is(err, target) {
+			return p
+		}
+		return nil
+	}
+}
+
+// Mappers returns a mapper answering with the first of ms knowing err.
+func Mappers(ms ...Mapper) Mapper {
+	return func(r *http.Request, err error) *Problem {
+		for _, m := range ms {
+			if p := m(r, err); p != nil {
+				return p
+			}
+		}
+		return nil
+	}
+}
+
+type ctxKey struct{}
+
+// Middleware has Respond try ms, in order, on the errors of the handlers
+// below it before its own mapping. Mappers of outer middleware are tried
+// after those of inner ones.
+func Middleware(ms ...Mapper) func(http.Handler) http.Handler {
+	m := Mappers(ms...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mappers, _ := r.Context().Value(ctxKey{}).([]Mapper)
+			mappers = append([]Mapper{m}, mappers...)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, mappers)))
+		})
+	}
+}
+
+// Respond answers err with the problem the request's mappers give it, or
+// else maps it as Write does.
+func Respond(w http.ResponseWriter, r *http.Request, err error) {
+	mappers, _ := r.Context().Value(ctxKey{}).([]Mapper)
+	for _, m := range mappers {
+		if p := m(r, err); p != nil {
+			write(w, p.Status, p)
+			return
+		}
+	}
+	Write(w, err)
+}
+
+// Write answers with the problem err is or wraps. Repository errors map to
+// RespNotFound for missing records and RespConflict for unique violations,
+// and queries cut short to RespClientClosed when the client went away and
+// RespTimeout when they ran out of time. Any other error is logged rather
+// than shown to the client and answers RespInternal.
+func Write(w http.ResponseWriter, err error) {
+	var p *Problem
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &p):
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		p = RespNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.As(err, &pgErr) && pgErr.Code == "23505":
+		p = RespConflict
+	case errors.Is(err, context.Canceled):
+		p = RespClientClosed
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &pgErr) && pgErr.Code == "57014":
+		p = RespTimeout
+	default:
+		log.Printf("err: unexpected error: %s", err)
+		p = RespInternal
+	}
+	write(w, p.Status, p)
+}
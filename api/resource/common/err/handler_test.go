@@ -0,0 +1,64 @@
+package err_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	e "hello/api/resource/common/err"
+	testUtil "hello/util/test"
+)
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	errSelf := errors.New("self transfer")
+	errPending := errors.New("pending")
+	var handlerErr error
+	h := e.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		if handlerErr == nil {
+			w.Write([]byte("ok"))
+		}
+		return handlerErr
+	})
+	// Inner mappers are tried first.
+	outer := e.Middleware(e.Is(errSelf, e.RespSelfTransfer), e.Is(errPending, e.RespConflict))
+	inner := e.Middleware(e.Is(errPending, e.RespTransferPending))
+	mapped := outer(inner(h))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		err     error
+		status  int
+		code    string
+	}{
+		{"no error", mapped, nil, http.StatusOK, ""},
+		{"domain error", mapped, fmt.Errorf("offer: %w", errSelf), http.StatusBadRequest, "self_transfer"},
+		{"inner mapper first", mapped, errPending, http.StatusConflict, "transfer_pending"},
+		{"problem", mapped, e.RespInvalidURLParamID, http.StatusBadRequest, "invalid_url_param_id"},
+		{"unique violation", mapped, &pgconn.PgError{Code: "23505"}, http.StatusConflict, "conflict"},
+		{"unmapped without middleware", h, errSelf, http.StatusInternalServerError, "internal"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handlerErr = tc.err
+			w := httptest.NewRecorder()
+			tc.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			testUtil.Equal(t, tc.status, w.Code)
+			if tc.err == nil {
+				testUtil.Equal(t, "ok", w.Body.String())
+				return
+			}
+
+			var p e.Problem
+			testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			testUtil.Equal(t, tc.code, p.Code)
+		})
+	}
+}
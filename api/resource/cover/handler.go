@@ -0,0 +1,314 @@
+package cover
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"hello/api/resource/blob"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/config"
+	"hello/idcodec"
+	"hello/imaging"
+	"hello/signedurl"
+	"hello/storage"
+)
+
+const (
+	formField = "file"
+	// multipartOverhead is allowed on top of the image in multipart
+	// uploads, for boundaries and part headers.
+	multipartOverhead = 64 << 10
+)
+
+var RespInvalidImage = e.New(http.StatusUnprocessableEntity, "invalid_image", "not a valid jpeg, png or gif image")
+
+// API stores uploaded book covers. Every cover is decoded and re-encoded
+// before it is stored, so only pixels survive: no EXIF data, no embedded
+// payloads and nothing larger than the configured dimensions.
+type API struct {
+	repository *Repository
+	store      storage.Store
+	blobs      *blob.Store
+	signer     *signedurl.Signer
+	maxSize    int64
+	limits     imaging.Limits
+}
+
+func New(db *gorm.DB, store storage.Store, blobs *blob.Store, signer *signedurl.Signer, conf *config.ConfCover) *API {
+	return &API{
+		repository: NewRepository(db),
+		store:      store,
+		blobs:      blobs,
+		signer:     signer,
+		maxSize:    conf.MaxSize,
+		limits:     imaging.Limits{MaxWidth: conf.MaxWidth, MaxHeight: conf.MaxHeight},
+	}
+}
+
+// Update godoc
+//
+//	@summary        Upload cover
+//	@description    Replace a book's cover with the JPEG, PNG or GIF image in the body
+//	@tags           books
+//	@accept         image/jpeg,image/png,image/gif
+//	@param          id	path        string  true    "Book ID"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        413 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/cover [put]
+func (api *API) Update(w http.ResponseWriter, r *http.Request) error {
+	b, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, api.maxSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return e.RespFileTooLarge
+		}
+		return e.RespInvalidUpload
+	}
+
+	return api.put(w, r, b, data)
+}
+
+// Upload godoc
+//
+//	@summary        Upload cover form
+//	@description    Replace a book's cover with the JPEG, PNG or GIF image uploaded as multipart/form-data in the "file" field
+//	@tags           books
+//	@accept         mpfd
+//	@param          id      path        string  true    "Book ID"
+//	@param          file    formData    file    true    "Image"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        413 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/cover [post]
+func (api *API) Upload(w http.ResponseWriter, r *http.Request) error {
+	b, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	// The image may come with other fields; they are skipped, but count
+	// towards the limit with some room for the part headers.
+	r.Body = http.MaxBytesReader(w, r.Body, api.maxSize+multipartOverhead)
+	part, err := filePart(r)
+	if err != nil {
+		return e.RespInvalidUpload
+	}
+	defer part.Close()
+
+	data, err := io.ReadAll(io.LimitReader(part, api.maxSize+1))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return e.RespFileTooLarge
+		}
+		return e.RespInvalidUpload
+	}
+	if int64(len(data)) > api.maxSize {
+		return e.RespFileTooLarge
+	}
+
+	return api.put(w, r, b, data)
+}
+
+// filePart returns the first multipart part carrying the file form field.
+func filePart(r *http.Request) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == formField && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// put sanitizes the image data and makes it b's cover, with its thumbnails.
+func (api *API) put(w http.ResponseWriter, r *http.Request, b *book.Book, data []byte) error {
+	img, contentType, err := imaging.Sanitize(data, api.limits)
+	if err != nil {
+		switch err {
+		case imaging.ErrNotImage:
+			return RespInvalidImage
+		case imaging.ErrTooLarge:
+			return e.RespFileTooLarge
+		default:
+			return e.RespStorageFailure
+		}
+	}
+
+	newBlob, err := api.blobs.Put(r.Context(), bytes.NewReader(img))
+	if err != nil {
+		return e.RespStorageFailure
+	}
+
+	api.storeThumbnails(r.Context(), newBlob.Hash, img)
+
+	if _, err := api.repository.WithContext(r.Context()).Set(b.ID, newBlob.Hash, contentType); err != nil {
+		api.blobs.Release(r.Context(), newBlob.Hash)
+		return e.RespDBDataUpdateFailure
+	}
+
+	if b.CoverHash != "" {
+		api.blobs.Release(r.Context(), b.CoverHash)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Read godoc
+//
+//	@summary        Read cover
+//	@description    Download a book's cover image
+//	@tags           books
+//	@produce        image/jpeg,image/png
+//	@param          id	path        string  true    "Book ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/cover [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	b, err := api.book(r)
+	if err != nil {
+		return err
+	}
+	if b.CoverHash == "" {
+		return e.RespNotFound
+	}
+
+	content, err := api.store.Open(r.Context(), blob.Key(b.CoverHash))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespStorageFailure
+	}
+	defer content.Close()
+
+	// The hash names the content, so the response never changes for an ETag.
+	etag := strconv.Quote(b.CoverHash)
+	h := w.Header()
+	h.Set("Content-Type", b.CoverType)
+	h.Set("ETag", etag)
+	h.Set("X-Content-Type-Options", "nosniff")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("cover %s download: %s", b.ID, err)
+	}
+	return nil
+}
+
+// URL godoc
+//
+//	@summary        Cover download URL
+//	@description    Issue a short-lived signed URL for downloading a book's cover
+//	@tags           books
+//	@produce        json
+//	@param          id	path        string  true    "Book ID"
+//	@success        200 {object}    signedurl.DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/cover/url [get]
+func (api *API) URL(w http.ResponseWriter, r *http.Request) error {
+	b, err := api.book(r)
+	if err != nil {
+		return err
+	}
+	if b.CoverHash == "" {
+		return e.RespNotFound
+	}
+
+	u, expires, err := api.signer.URL(r.Context(), api.store, blob.Key(b.CoverHash), strings.TrimSuffix(r.URL.Path, "/url"))
+	if err != nil {
+		return e.RespStorageFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(&signedurl.DTO{URL: u, ExpiresAt: expires}); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Delete cover
+//	@description    Remove a book's uploaded cover
+//	@tags           books
+//	@param          id	path        string  true    "Book ID"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/cover [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	b, err := api.book(r)
+	if err != nil {
+		return err
+	}
+	if b.CoverHash == "" {
+		return e.RespNotFound
+	}
+
+	if _, err := api.repository.WithContext(r.Context()).Set(b.ID, "", ""); err != nil {
+		return e.RespDBDataUpdateFailure
+	}
+	api.blobs.Release(r.Context(), b.CoverHash)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (api *API) book(r *http.Request) (*book.Book, error) {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, e.RespInvalidURLParamID
+	}
+
+	b, err := api.repository.WithContext(r.Context()).Read(id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, e.RespNotFound
+		}
+
+		return nil, e.RespDBDataAccessFailure
+	}
+
+	return b, nil
+}
@@ -0,0 +1,165 @@
+package cover_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/resource/blob"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/cover"
+	"hello/config"
+	"hello/imaging"
+	mockDB "hello/mock/db"
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+func pngImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	testUtil.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func imageSize(t *testing.T, r io.Reader) image.Point {
+	t.Helper()
+
+	conf, _, err := image.DecodeConfig(r)
+	testUtil.NoError(t, err)
+	return image.Pt(conf.Width, conf.Height)
+}
+
+func TestAPI_Upload(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := mockDB.NewMockDB()
+	testUtil.NoError(t, err)
+	store, err := storage.NewFS(t.TempDir())
+	testUtil.NoError(t, err)
+
+	bookID := uuid.New()
+	mock.ExpectQuery(`^SELECT "id","cover_hash","cover_type" FROM "books"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cover_hash", "cover_type"}).AddRow(bookID, "", ""))
+	mock.ExpectBegin()
+	mock.ExpectExec(`^INSERT INTO "blobs" .+ ON CONFLICT`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE "books" SET "cover_hash"=\$1,"cover_type"=\$2`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	api := cover.New(db, store, blob.NewStore(db, store, cover.ThumbnailNames()...), nil, &config.ConfCover{MaxSize: 1 << 20, MaxWidth: 1000, MaxHeight: 1000})
+	router := chi.NewRouter()
+	router.Post("/books/{id}/cover", e.Handle(api.Upload))
+
+	data := pngImage(t, 600, 300)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	testUtil.NoError(t, mw.WriteField("note", "ignored"))
+	fw, err := mw.CreateFormFile("file", "cover.png")
+	testUtil.NoError(t, err)
+	_, err = fw.Write(data)
+	testUtil.NoError(t, err)
+	testUtil.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/books/"+bookID.String()+"/cover", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	testUtil.Equal(t, http.StatusNoContent, w.Code)
+	testUtil.NoError(t, mock.ExpectationsWereMet())
+
+	// The thumbnails are stored next to the sanitized cover's blob.
+	img, _, err := imaging.Sanitize(data, imaging.Limits{MaxWidth: 1000, MaxHeight: 1000})
+	testUtil.NoError(t, err)
+	sum := sha256.Sum256(img)
+	hash := hex.EncodeToString(sum[:])
+	for _, tt := range []struct {
+		name string
+		want image.Point
+	}{
+		{"thumbnail-small", image.Pt(160, 80)},
+		{"thumbnail-medium", image.Pt(480, 240)},
+	} {
+		content, err := store.Open(context.Background(), blob.DerivedKey(hash, tt.name))
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, tt.want, imageSize(t, content))
+		content.Close()
+	}
+}
+
+func TestAPI_ReadThumbnail(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		size   string
+		status int
+		want   image.Point
+	}{
+		{"small", "small", http.StatusOK, image.Pt(80, 160)},
+		{"medium", "medium", http.StatusOK, image.Pt(240, 480)},
+		{"unknown size", "huge", http.StatusNotFound, image.Point{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := mockDB.NewMockDB()
+			testUtil.NoError(t, err)
+			store, err := storage.NewFS(t.TempDir())
+			testUtil.NoError(t, err)
+
+			// A cover stored without thumbnails has them generated on read.
+			hash := strings.Repeat("ab", 32)
+			_, err = store.Put(context.Background(), blob.Key(hash), bytes.NewReader(pngImage(t, 300, 600)))
+			testUtil.NoError(t, err)
+
+			bookID := uuid.New()
+			if tt.status == http.StatusOK {
+				mock.ExpectQuery(`^SELECT "id","cover_hash","cover_type" FROM "books"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "cover_hash", "cover_type"}).AddRow(bookID, hash, "image/png"))
+			}
+
+			api := cover.New(db, store, blob.NewStore(db, store), nil, &config.ConfCover{})
+			router := chi.NewRouter()
+			router.Get("/books/{id}/cover/thumbnails/{size}", e.Handle(api.ReadThumbnail))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/"+bookID.String()+"/cover/thumbnails/"+tt.size, nil))
+			testUtil.Equal(t, tt.status, w.Code)
+			testUtil.NoError(t, mock.ExpectationsWereMet())
+			if tt.status != http.StatusOK {
+				return
+			}
+			testUtil.Equal(t, "image/png", w.Header().Get("Content-Type"))
+			testUtil.Equal(t, tt.want, imageSize(t, w.Body))
+
+			content, err := store.Open(context.Background(), blob.DerivedKey(hash, "thumbnail-"+tt.size))
+			testUtil.NoError(t, err)
+			content.Close()
+		})
+	}
+}
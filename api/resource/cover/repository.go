@@ -0,0 +1,44 @@
+package cover
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/resource/book"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) Read(id uuid.UUID) (*book.Book, error) {
+	b := &book.Book{}
+	if err := r.db.Select("id", "cover_hash", "cover_type").Where("id = ?", id).First(b).Error; err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Set points the book at a new cover blob, leaving updated_at alone since
+// the book's own fields are unchanged.
+func (r *Repository) Set(id uuid.UUID, hash, contentType string) (int64, error) {
+	result := r.db.Model(&book.Book{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]any{"cover_hash": hash, "cover_type": contentType})
+
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,152 @@
+package cover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"hello/api/resource/blob"
+	e "hello/api/resource/common/err"
+	"hello/imaging"
+	"hello/storage"
+)
+
+// Thumbnail is a smaller rendition of covers, fitting within Size×Size
+// pixels.
+type Thumbnail struct {
+	Name string
+	Size int
+}
+
+// Thumbnails are generated for every cover as it is uploaded, and stored
+// next to its blob, see blob.DerivedKey.
+var Thumbnails = []Thumbnail{
+	{Name: "small", Size: 160},
+	{Name: "medium", Size: 480},
+}
+
+// ThumbnailNames returns the derived names thumbnails are stored under, for
+// the blob collector to remove them with their cover.
+func ThumbnailNames() []string {
+	names := make([]string, len(Thumbnails))
+	for i, t := range Thumbnails {
+		names[i] = derivedName(t)
+	}
+	return names
+}
+
+func derivedName(t Thumbnail) string {
+	return "thumbnail-" + t.Name
+}
+
+func thumbnail(name string) (Thumbnail, bool) {
+	for _, t := range Thumbnails {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Thumbnail{}, false
+}
+
+// storeThumbnails generates and stores the thumbnails of the cover img. A
+// thumbnail failing is only logged: ReadThumbnail generates missing ones.
+func (api *API) storeThumbnails(ctx context.Context, hash string, img []byte) {
+	for _, t := range Thumbnails {
+		if _, err := api.storeThumbnail(ctx, hash, img, t); err != nil {
+			log.Printf("cover %s thumbnail %s: %s", hash, t.Name, err)
+		}
+	}
+}
+
+func (api *API) storeThumbnail(ctx context.Context, hash string, img []byte, t Thumbnail) ([]byte, error) {
+	data, _, err := imaging.Thumbnail(img, t.Size)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := api.store.Put(ctx, blob.DerivedKey(hash, derivedName(t)), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ReadThumbnail godoc
+//
+//	@summary        Read cover thumbnail
+//	@description    Download a smaller rendition of a book's cover: small fits within 160 pixels, medium within 480
+//	@tags           books
+//	@produce        image/jpeg,image/png
+//	@param          id      path        string  true    "Book ID"
+//	@param          size    path        string  true    "Thumbnail size"    Enums(small, medium)
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/cover/thumbnails/{size} [get]
+func (api *API) ReadThumbnail(w http.ResponseWriter, r *http.Request) error {
+	t, ok := thumbnail(chi.URLParam(r, "size"))
+	if !ok {
+		return e.RespNotFound
+	}
+	b, err := api.book(r)
+	if err != nil {
+		return err
+	}
+	if b.CoverHash == "" {
+		return e.RespNotFound
+	}
+
+	data, err := api.thumbnailData(r.Context(), b.CoverHash, t)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespStorageFailure
+	}
+
+	// Thumbnails keep the format of their cover, and are named by its hash.
+	etag := strconv.Quote(b.CoverHash + "-" + t.Name)
+	h := w.Header()
+	h.Set("Content-Type", b.CoverType)
+	h.Set("ETag", etag)
+	h.Set("X-Content-Type-Options", "nosniff")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	if _, err := w.Write(data); err != nil {
+		log.Printf("cover %s thumbnail %s download: %s", b.ID, t.Name, err)
+	}
+	return nil
+}
+
+// thumbnailData reads a stored thumbnail, generating it from the cover when
+// it is missing, as for covers uploaded before thumbnails were.
+func (api *API) thumbnailData(ctx context.Context, hash string, t Thumbnail) ([]byte, error) {
+	content, err := api.store.Open(ctx, blob.DerivedKey(hash, derivedName(t)))
+	if err == nil {
+		defer content.Close()
+		return io.ReadAll(content)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	content, err = api.store.Open(ctx, blob.Key(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	img, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	return api.storeThumbnail(ctx, hash, img, t)
+}
@@ -0,0 +1,59 @@
+package cover
+
+import (
+	"context"
+	"time"
+
+	"hello/api/resource/blob"
+	"hello/api/resource/book"
+	"hello/idcodec"
+	"hello/signedurl"
+	"hello/storage"
+	"hello/util/computed"
+)
+
+// URLs are the signed download URLs of a book's cover and of its
+// thumbnails, by name.
+type URLs struct {
+	URL        string            `json:"url"`
+	Thumbnails map[string]string `json:"thumbnails"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+}
+
+// Resolver returns the computed field giving books with a cover its signed
+// URLs. They stay the same within a window of the signer's TTL, see
+// signedurl.Signer.StableURL, so that book ETags do too.
+func Resolver(signer *signedurl.Signer, store storage.Store) computed.Resolver[*book.Book] {
+	return func(ctx context.Context, bs []*book.Book) ([]any, error) {
+		values := make([]any, len(bs))
+		for i, b := range bs {
+			if b.CoverHash == "" {
+				continue
+			}
+			urls, err := coverURLs(ctx, signer, store, b)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = urls
+		}
+		return values, nil
+	}
+}
+
+func coverURLs(ctx context.Context, signer *signedurl.Signer, store storage.Store, b *book.Book) (*URLs, error) {
+	path := "/v1/books/" + idcodec.Encode(b.ID) + "/cover"
+	u, expires, err := signer.StableURL(ctx, store, blob.Key(b.CoverHash), path)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := &URLs{URL: u, Thumbnails: make(map[string]string, len(Thumbnails)), ExpiresAt: expires}
+	for _, t := range Thumbnails {
+		u, _, err := signer.StableURL(ctx, store, blob.DerivedKey(b.CoverHash, derivedName(t)), path+"/thumbnails/"+t.Name)
+		if err != nil {
+			return nil, err
+		}
+		urls.Thumbnails[t.Name] = u
+	}
+	return urls, nil
+}
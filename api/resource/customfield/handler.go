@@ -0,0 +1,148 @@
+package customfield
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/tenant"
+	e "hello/api/resource/common/err"
+	validatorUtil "hello/util/validator"
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+}
+
+func New(db *gorm.DB, v *validator.Validate) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+	}
+}
+
+func (f *Form) ToModel() *Definition {
+	return &Definition{
+		Name:     f.Name,
+		Type:     f.Type,
+		Required: f.Required,
+		Indexed:  f.Indexed,
+		Min:      f.Min,
+		Max:      f.Max,
+		Enum:     f.Enum,
+	}
+}
+
+func (d *Definition) ToDto() *DTO {
+	return &DTO{
+		Name:     d.Name,
+		Type:     d.Type,
+		Required: d.Required,
+		Indexed:  d.Indexed,
+		Min:      d.Min,
+		Max:      d.Max,
+		Enum:     d.Enum,
+	}
+}
+
+func (ds Definitions) ToDto() []*DTO {
+	dtos := make([]*DTO, len(ds))
+	for i, v := range ds {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
+
+// List godoc
+//
+//	@summary        List custom fields
+//	@description    List the custom book fields defined for the tenant
+//	@tags           custom-fields
+//	@produce        json
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /custom-fields [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	defs, err := api.repository.WithContext(r.Context()).List(tenant.From(r.Context()))
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(defs.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Create godoc
+//
+//	@summary        Create custom field
+//	@description    Define a custom book field for the tenant
+//	@tags           custom-fields
+//	@accept         json
+//	@produce        json
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          body    body    Form    true    "Custom field form"
+//	@success        201
+//	@failure        400 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /custom-fields [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	def := form.ToModel()
+	def.ID = uuid.New()
+	def.TenantID = tenant.From(r.Context())
+
+	if _, err := api.repository.WithContext(r.Context()).Create(def); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return e.RespDuplicateCustomField
+		}
+
+		return e.RespDBDataInsertFailure
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Delete custom field
+//	@description    Delete a custom field definition; stored values are kept
+//	@tags           custom-fields
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          name    path    string  true    "Field name"
+//	@success        200
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /custom-fields/{name} [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	rows, err := api.repository.WithContext(r.Context()).Delete(tenant.From(r.Context()), chi.URLParam(r, "name"))
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+	return nil
+}
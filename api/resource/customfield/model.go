@@ -0,0 +1,84 @@
+package customfield
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+)
+
+type DTO struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Indexed  bool     `json:"indexed"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+}
+
+// Form defines a custom field. For strings Min and Max bound the length; for
+// numbers they bound the value. Indexed fields can be filtered on.
+type Form struct {
+	Name     string   `json:"name" validate:"required,identifier,max=63"`
+	Type     string   `json:"type" validate:"required,oneof=string number boolean date"`
+	Required bool     `json:"required"`
+	Indexed  bool     `json:"indexed"`
+	Min      *float64 `json:"min"`
+	Max      *float64 `json:"max"`
+	Enum     []string `json:"enum" validate:"omitempty,dive,required,max=255"`
+}
+
+type Definition struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	TenantID  string
+	Name      string
+	Type      string
+	Required  bool
+	Indexed   bool
+	Min       *float64
+	Max       *float64
+	Enum      StringList `gorm:"type:jsonb"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Definitions []*Definition
+
+func (Definition) TableName() string {
+	return "custom_field_definitions"
+}
+
+// StringList is stored as a JSON array.
+type StringList []string
+
+func (sl StringList) Value() (driver.Value, error) {
+	if sl == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(sl)
+	return string(b), err
+}
+
+func (sl *StringList) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*sl = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, sl)
+	case string:
+		return json.Unmarshal([]byte(v), sl)
+	default:
+		return errors.New("customfield: unsupported StringList source")
+	}
+}
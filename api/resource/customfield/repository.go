@@ -0,0 +1,43 @@
+package customfield
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) List(tenantID string) (Definitions, error) {
+	defs := make([]*Definition, 0)
+	if err := r.db.Where("tenant_id = ?", tenantID).Order("name").Find(&defs).Error; err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+func (r *Repository) Create(def *Definition) (*Definition, error) {
+	if err := r.db.Create(def).Error; err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+func (r *Repository) Delete(tenantID, name string) (int64, error) {
+	result := r.db.Where("tenant_id = ? AND name = ?", tenantID, name).Delete(&Definition{})
+	return result.RowsAffected, result.Error
+}
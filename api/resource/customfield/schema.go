@@ -0,0 +1,168 @@
+package customfield
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// Schema validates and interprets custom field values against a tenant's
+// definitions.
+type Schema struct {
+	repository *Repository
+}
+
+func NewSchema(db *gorm.DB) *Schema {
+	return &Schema{
+		repository: NewRepository(db),
+	}
+}
+
+// Validate returns one message per invalid or undefined field in values.
+func (s *Schema) Validate(ctx context.Context, tenantID string, values map[string]any) ([]string, error) {
+	defs, err := s.repository.WithContext(ctx).List(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return defs.Validate(values), nil
+}
+
+// FilterValues converts raw query values into typed values for filtering.
+// Only indexed fields may be filtered on.
+func (s *Schema) FilterValues(ctx context.Context, tenantID string, raw map[string]string) (map[string]any, []string, error) {
+	if len(raw) == 0 {
+		return nil, nil, nil
+	}
+
+	defs, err := s.repository.WithContext(ctx).List(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values, msgs := defs.FilterValues(raw)
+	return values, msgs, nil
+}
+
+func (ds Definitions) byName() map[string]*Definition {
+	m := make(map[string]*Definition, len(ds))
+	for _, d := range ds {
+		m[d.Name] = d
+	}
+	return m
+}
+
+func (ds Definitions) Validate(values map[string]any) []string {
+	defs := ds.byName()
+
+	var msgs []string
+	for _, d := range ds {
+		v, ok := values[d.Name]
+		if !ok || v == nil {
+			if d.Required {
+				msgs = append(msgs, fmt.Sprintf("custom_fields.%s is a required field", d.Name))
+			}
+			continue
+		}
+		if msg := d.check(v); msg != "" {
+			msgs = append(msgs, fmt.Sprintf("custom_fields.%s %s", d.Name, msg))
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if _, ok := defs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("custom_fields.%s is not a defined custom field", name))
+	}
+
+	return msgs
+}
+
+func (d *Definition) check(v any) string {
+	switch d.Type {
+	case TypeString:
+		s, ok := v.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if len(d.Enum) > 0 && !slices.Contains(d.Enum, s) {
+			return fmt.Sprintf("must be one of %v", []string(d.Enum))
+		}
+		return d.checkRange(float64(utf8.RuneCountInString(s)), "length")
+	case TypeNumber:
+		n, ok := v.(float64)
+		if !ok {
+			return "must be a number"
+		}
+		return d.checkRange(n, "value")
+	case TypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return "must be a boolean"
+		}
+	case TypeDate:
+		s, ok := v.(string)
+		if !ok {
+			return "must be a valid date"
+		}
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			return "must be a valid date"
+		}
+	}
+	return ""
+}
+
+func (d *Definition) checkRange(n float64, what string) string {
+	if d.Min != nil && n < *d.Min {
+		return fmt.Sprintf("%s must be at least %v", what, *d.Min)
+	}
+	if d.Max != nil && n > *d.Max {
+		return fmt.Sprintf("%s must be at most %v", what, *d.Max)
+	}
+	return ""
+}
+
+func (ds Definitions) FilterValues(raw map[string]string) (map[string]any, []string) {
+	defs := ds.byName()
+
+	values := make(map[string]any, len(raw))
+	var msgs []string
+	for name, s := range raw {
+		d, ok := defs[name]
+		if !ok || !d.Indexed {
+			msgs = append(msgs, fmt.Sprintf("cf.%s is not a filterable custom field", name))
+			continue
+		}
+
+		switch d.Type {
+		case TypeNumber:
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				msgs = append(msgs, fmt.Sprintf("cf.%s must be a number", name))
+				continue
+			}
+			values[name] = n
+		case TypeBoolean:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				msgs = append(msgs, fmt.Sprintf("cf.%s must be a boolean", name))
+				continue
+			}
+			values[name] = b
+		default:
+			values[name] = s
+		}
+	}
+	sort.Strings(msgs)
+
+	return values, msgs
+}
@@ -0,0 +1,67 @@
+package customfield_test
+
+import (
+	"testing"
+
+	"hello/api/resource/customfield"
+	testUtil "hello/util/test"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+func TestDefinitions_Validate(t *testing.T) {
+	t.Parallel()
+
+	defs := customfield.Definitions{
+		{Name: "shelf", Type: customfield.TypeString, Required: true, Max: ptr(4)},
+		{Name: "condition", Type: customfield.TypeString, Enum: customfield.StringList{"new", "used"}},
+		{Name: "copies", Type: customfield.TypeNumber, Min: ptr(0)},
+		{Name: "signed", Type: customfield.TypeBoolean},
+		{Name: "acquired", Type: customfield.TypeDate},
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]any
+		want   []string
+	}{
+		{"valid", map[string]any{"shelf": "A3", "condition": "new", "copies": 2.0, "signed": true, "acquired": "2020-01-31"}, nil},
+		{"missing required", map[string]any{}, []string{"custom_fields.shelf is a required field"}},
+		{"too long", map[string]any{"shelf": "A3-B4"}, []string{"custom_fields.shelf length must be at most 4"}},
+		{"not in enum", map[string]any{"shelf": "A3", "condition": "mint"}, []string{"custom_fields.condition must be one of [new used]"}},
+		{"wrong type", map[string]any{"shelf": "A3", "signed": "yes"}, []string{"custom_fields.signed must be a boolean"}},
+		{"below min", map[string]any{"shelf": "A3", "copies": -1.0}, []string{"custom_fields.copies value must be at least 0"}},
+		{"bad date", map[string]any{"shelf": "A3", "acquired": "31/01/2020"}, []string{"custom_fields.acquired must be a valid date"}},
+		{"undefined", map[string]any{"shelf": "A3", "colour": "red"}, []string{"custom_fields.colour is not a defined custom field"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := defs.Validate(tc.values)
+			testUtil.Equal(t, len(tc.want), len(got))
+			for i := range tc.want {
+				testUtil.Equal(t, tc.want[i], got[i])
+			}
+		})
+	}
+}
+
+func TestDefinitions_FilterValues(t *testing.T) {
+	t.Parallel()
+
+	defs := customfield.Definitions{
+		{Name: "shelf", Type: customfield.TypeString, Indexed: true},
+		{Name: "copies", Type: customfield.TypeNumber, Indexed: true},
+		{Name: "notes", Type: customfield.TypeString},
+	}
+
+	values, msgs := defs.FilterValues(map[string]string{"shelf": "A3", "copies": "2"})
+	testUtil.Equal(t, 0, len(msgs))
+	testUtil.Equal(t, "A3", values["shelf"].(string))
+	testUtil.Equal(t, 2.0, values["copies"].(float64))
+
+	_, msgs = defs.FilterValues(map[string]string{"notes": "x", "copies": "two"})
+	testUtil.Equal(t, 2, len(msgs))
+	testUtil.Equal(t, "cf.copies must be a number", msgs[0])
+	testUtil.Equal(t, "cf.notes is not a filterable custom field", msgs[1])
+}
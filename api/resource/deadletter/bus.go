@@ -0,0 +1,179 @@
+// Package deadletter keeps the event deliveries subscribers fail, so that
+// read models, search and caches left behind by a failing subscriber can be
+// caught up once it is fixed, and the background jobs given up on, so that
+// they can be run again. Letters are inspected, edited, retried and
+// discarded at /admin/dead-letters.
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/event"
+	"hello/worker"
+)
+
+var (
+	// ErrUnknownHandler is returned when retrying a letter whose subscriber
+	// is no longer subscribed to its event, e.g. after a release renamed it.
+	ErrUnknownHandler = errors.New("deadletter: handler not subscribed")
+	// ErrInvalidPayload is returned when retrying a letter whose payload
+	// does not decode into the payload type of its event.
+	ErrInvalidPayload = errors.New("deadletter: invalid payload")
+)
+
+type subscriber struct {
+	event, handler string
+}
+
+// Bus is an event.Bus keeping each delivery a subscriber fails as a
+// letter. The publisher still gets the error. Subscribers are named after
+// their function, so that a letter can be delivered again to the
+// subscriber that failed it, and to it only.
+type Bus struct {
+	next       event.Bus
+	repository *Repository
+
+	mu       sync.RWMutex
+	handlers map[subscriber]event.Handler
+	types    map[string]reflect.Type
+}
+
+func NewBus(next event.Bus, db *gorm.DB) *Bus {
+	return &Bus{
+		next:       next,
+		repository: NewRepository(db),
+		handlers:   make(map[subscriber]event.Handler),
+		types:      make(map[string]reflect.Type),
+	}
+}
+
+// Payload sets the type payloads of the named events decode into on retry
+// to that of v. Payloads of other events are passed to handlers as
+// json.RawMessage.
+func (b *Bus) Payload(v any, names ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, name := range names {
+		b.types[name] = reflect.TypeOf(v)
+	}
+}
+
+func (b *Bus) Publish(ctx context.Context, e event.Event) error {
+	return b.next.Publish(ctx, e)
+}
+
+func (b *Bus) Subscribe(name string, h event.Handler) {
+	s := subscriber{event: name, handler: handlerName(h)}
+
+	b.mu.Lock()
+	// The same function subscribed twice to an event is told apart by the
+	// order of subscription, which is that of the wiring at startup.
+	base := s.handler
+	for i := 2; b.handlers[s] != nil; i++ {
+		s.handler = fmt.Sprintf("%s#%d", base, i)
+	}
+	b.handlers[s] = h
+	b.mu.Unlock()
+
+	b.next.Subscribe(name, func(ctx context.Context, e event.Event) error {
+		err := h(ctx, e)
+		if err != nil {
+			b.keep(ctx, s, e, err)
+		}
+		return err
+	})
+}
+
+// keep records a failed delivery. The letter outlives the request that
+// published the event, so it is not cancelled with it.
+func (b *Bus) keep(ctx context.Context, s subscriber, e event.Event, cause error) {
+	payload, err := json.Marshal(e.Payload)
+	if err != nil {
+		log.Printf("dead letter %s for %s: payload: %s", e.Name, e.AggregateID, err)
+		payload = []byte("null")
+	}
+
+	l := &Letter{
+		ID:          uuid.New(),
+		Kind:        KindEvent,
+		Event:       e.Name,
+		Handler:     s.handler,
+		AggregateID: e.AggregateID,
+		OccurredAt:  e.OccurredAt,
+		Payload:     string(payload),
+		Error:       cause.Error(),
+		Attempts:    1,
+	}
+	if err := b.repository.WithContext(context.WithoutCancel(ctx)).Create(l); err != nil {
+		log.Printf("dead letter %s for %s: %s", e.Name, e.AggregateID, err)
+	}
+}
+
+// KeepJob records a job the runner gave up on, for Runner.OnAbandon. The
+// letter is retried by enqueueing the job again.
+func (b *Bus) KeepJob(ctx context.Context, j *worker.Job, cause error) {
+	payload := string(j.Payload)
+	if payload == "" {
+		payload = "null"
+	}
+
+	l := &Letter{
+		ID:          uuid.New(),
+		Kind:        KindJob,
+		Event:       j.Type,
+		AggregateID: j.ID,
+		OccurredAt:  j.EnqueuedAt,
+		Payload:     payload,
+		Error:       cause.Error(),
+		Attempts:    j.Attempt,
+	}
+	if err := b.repository.WithContext(ctx).Create(l); err != nil {
+		log.Printf("dead letter job %s %s: %s", j.Type, j.ID, err)
+	}
+}
+
+// Deliver passes the event of a letter to the subscriber that failed it,
+// returning its error. Other subscribers of the event are not called.
+func (b *Bus) Deliver(ctx context.Context, l *Letter) error {
+	b.mu.RLock()
+	h, ok := b.handlers[subscriber{event: l.Event, handler: l.Handler}]
+	t := b.types[l.Event]
+	b.mu.RUnlock()
+	if !ok {
+		return ErrUnknownHandler
+	}
+
+	var payload any = json.RawMessage(l.Payload)
+	if t != nil {
+		v := reflect.New(t)
+		if err := json.Unmarshal([]byte(l.Payload), v.Interface()); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidPayload, err)
+		}
+		payload = v.Elem().Interface()
+	}
+
+	return h(ctx, event.Event{
+		Name:        l.Event,
+		AggregateID: l.AggregateID,
+		OccurredAt:  l.OccurredAt,
+		Payload:     payload,
+	})
+}
+
+// handlerName names h after its function, e.g.
+// "hello/api/resource/catalog.(*projector).upsert".
+func handlerName(h event.Handler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	return strings.TrimSuffix(name, "-fm")
+}
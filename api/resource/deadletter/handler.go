@@ -0,0 +1,292 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/audit"
+	validatorUtil "hello/util/validator"
+	"hello/worker"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// Audited dead letter actions.
+const (
+	ActionUpdated   = "dead_letter.updated"
+	ActionRetried   = "dead_letter.retried"
+	ActionDiscarded = "dead_letter.discarded"
+)
+
+var (
+	RespUnknownHandler = e.New(http.StatusConflict, "unknown_handler", "the handler of this letter is no longer subscribed to its event; discard it")
+	RespInvalidPayload = e.New(http.StatusBadRequest, "invalid_payload", "payload does not decode into the payload of its event; edit it")
+	RespRetryFailed    = e.New(http.StatusConflict, "retry_failed", "the handler failed again; the letter is kept with the new error")
+	RespUnknownJob     = e.New(http.StatusConflict, "unknown_job", "the job type of this letter is no longer handled; discard it")
+	RespJobQueueFull   = e.New(http.StatusServiceUnavailable, "job_queue_full", "the job queue is full, retry later")
+)
+
+type API struct {
+	bus        *Bus
+	jobs       *worker.Runner
+	repository *Repository
+	validator  *validator.Validate
+	now        func() time.Time
+}
+
+// New returns the API of the letters kept by bus. Job letters are retried
+// on jobs.
+func New(bus *Bus, jobs *worker.Runner, v *validator.Validate) *API {
+	return &API{
+		bus:        bus,
+		jobs:       jobs,
+		repository: bus.repository,
+		validator:  v,
+		now:        time.Now,
+	}
+}
+
+// List godoc
+//
+//	@summary        List dead letters
+//	@description    List the event deliveries subscribers failed, the oldest first, with the error of their last attempt
+//	@tags           admin
+//	@produce        json
+//	@param          event   query   string  false   "Event name"
+//	@param          handler query   string  false   "Handler name"
+//	@param          limit   query   int     false   "Maximum number of letters (default 50, at most 500)"
+//	@param          offset  query   int     false   "Number of letters to skip"
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/dead-letters [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	limit, offset := defaultListLimit, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxListLimit)
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	letters, err := api.repository.WithContext(r.Context()).
+		List(r.URL.Query().Get("event"), r.URL.Query().Get("handler"), limit, offset)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(letters.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Read godoc
+//
+//	@summary        Read dead letter
+//	@description    Read a failed event delivery with its payload and error
+//	@tags           admin
+//	@produce        json
+//	@param          id      path    string  true    "Letter ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/dead-letters/{id} [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	l, err := api.letter(r)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(w).Encode(l.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Update godoc
+//
+//	@summary        Edit dead letter
+//	@description    Replace the payload of a failed event delivery before retrying it
+//	@tags           admin
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string  true    "Letter ID"
+//	@param          body    body    Form    true    "Payload"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/dead-letters/{id} [put]
+func (api *API) Update(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	l, err := api.repository.WithContext(r.Context()).UpdatePayload(id, string(form.Payload), api.now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataUpdateFailure
+	}
+	audit.Note(r, ActionUpdated, l.ID.String(), audit.Details{"event": l.Event, "handler": l.Handler})
+
+	if err := json.NewEncoder(w).Encode(l.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Retry godoc
+//
+//	@summary        Retry dead letter
+//	@description    Deliver a failed event again to the handler that failed it, or enqueue a job given up on again. The letter is removed once delivered or enqueued, and otherwise kept with the new error; a job that fails again is kept as a new letter
+//	@tags           admin
+//	@produce        json
+//	@param          id      path    string  true    "Letter ID"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@failure        503 {object}    err.Problem
+//	@router         /admin/dead-letters/{id}/retry [post]
+func (api *API) Retry(w http.ResponseWriter, r *http.Request) error {
+	l, err := api.letter(r)
+	if err != nil {
+		return err
+	}
+
+	retry := api.deliver
+	if l.Kind == KindJob {
+		retry = api.enqueue
+	}
+	if err := retry(r, l); err != nil {
+		return err
+	}
+
+	if err := api.repository.WithContext(r.Context()).Delete(l.ID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.RespDBDataRemoveFailure
+	}
+	audit.Note(r, ActionRetried, l.ID.String(), audit.Details{"event": l.Event, "handler": l.Handler})
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Discard godoc
+//
+//	@summary        Discard dead letter
+//	@description    Remove a failed event delivery without retrying it
+//	@tags           admin
+//	@param          id      path    string  true    "Letter ID"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/dead-letters/{id} [delete]
+func (api *API) Discard(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	if err := api.repository.WithContext(r.Context()).Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataRemoveFailure
+	}
+	audit.Note(r, ActionDiscarded, id.String(), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// deliver passes the event of l to the handler that failed it, keeping the
+// new error when it fails again.
+func (api *API) deliver(r *http.Request, l *Letter) error {
+	err := api.bus.Deliver(r.Context(), l)
+	switch {
+	case errors.Is(err, ErrUnknownHandler):
+		return RespUnknownHandler
+	case errors.Is(err, ErrInvalidPayload):
+		return RespInvalidPayload
+	case err != nil:
+		log.Printf("dead letter %s retry: %s", l.ID, err)
+		if _, err := api.repository.WithContext(r.Context()).Failed(l.ID, err.Error(), api.now()); err != nil {
+			return e.RespDBDataUpdateFailure
+		}
+		return RespRetryFailed
+	}
+	return nil
+}
+
+// enqueue queues the job of l again, with its payload.
+func (api *API) enqueue(r *http.Request, l *Letter) error {
+	if api.jobs == nil {
+		return RespUnknownJob
+	}
+
+	var payload any
+	if l.Payload != "null" {
+		payload = json.RawMessage(l.Payload)
+	}
+	err := api.jobs.Enqueue(r.Context(), l.Event, payload)
+	switch {
+	case errors.Is(err, worker.ErrUnknownJob):
+		return RespUnknownJob
+	case errors.Is(err, worker.ErrQueueFull):
+		return RespJobQueueFull
+	case err != nil:
+		log.Printf("dead letter %s retry: %s", l.ID, err)
+		return e.RespInternal
+	}
+	return nil
+}
+
+// letter returns the letter in the URL, answering 404 when there is no
+// such letter.
+func (api *API) letter(r *http.Request) (*Letter, error) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, e.RespInvalidURLParamID
+	}
+
+	l, err := api.repository.WithContext(r.Context()).Read(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.RespNotFound
+		}
+
+		return nil, e.RespDBDataAccessFailure
+	}
+	return l, nil
+}
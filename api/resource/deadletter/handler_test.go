@@ -0,0 +1,182 @@
+package deadletter_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	e "hello/api/resource/common/err"
+	"hello/api/resource/deadletter"
+	"hello/event"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+	"hello/worker"
+)
+
+type payload struct {
+	Title string `json:"title"`
+}
+
+func TestAPI(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &deadletter.Letter{})
+
+	bus := deadletter.NewBus(event.NewBus(), db)
+	bus.Payload(&payload{}, "book.created")
+
+	// The indexer fails on empty titles; the projector always succeeds.
+	var indexed []string
+	projected := 0
+	bus.Subscribe("book.created", func(context.Context, event.Event) error {
+		projected++
+		return nil
+	})
+	bus.Subscribe("book.created", func(_ context.Context, ev event.Event) error {
+		p, ok := ev.Payload.(*payload)
+		if !ok {
+			return errors.New("unexpected payload")
+		}
+		if p.Title == "" {
+			return errors.New("empty title")
+		}
+		indexed = append(indexed, p.Title)
+		return nil
+	})
+
+	err := bus.Publish(context.Background(), event.New("book.created", "b1", &payload{}))
+	testUtil.Equal(t, "empty title", err.Error())
+	testUtil.Equal(t, 1, projected)
+
+	api := deadletter.New(bus, nil, validatorUtil.New())
+	r := chi.NewRouter()
+	r.Get("/admin/dead-letters", e.Handle(api.List))
+	r.Get("/admin/dead-letters/{id}", e.Handle(api.Read))
+	r.Put("/admin/dead-letters/{id}", e.Handle(api.Update))
+	r.Post("/admin/dead-letters/{id}/retry", e.Handle(api.Retry))
+	r.Delete("/admin/dead-letters/{id}", e.Handle(api.Discard))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do(http.MethodGet, "/admin/dead-letters?event=book.created", "")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var dtos []*deadletter.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dtos))
+	testUtil.Equal(t, 1, len(dtos))
+	letter := dtos[0]
+	testUtil.Equal(t, "b1", letter.AggregateID)
+	testUtil.Equal(t, deadletter.KindEvent, letter.Kind)
+	testUtil.Equal(t, "empty title", letter.Error)
+	testUtil.Equal(t, 1, letter.Attempts)
+	testUtil.Equal(t, true, strings.HasPrefix(letter.Handler, "hello/api/resource/deadletter_test.TestAPI.func"))
+	testUtil.Equal(t, "[]\n", do(http.MethodGet, "/admin/dead-letters?event=book.deleted", "").Body.String())
+
+	path := "/admin/dead-letters/" + letter.ID
+	testUtil.Equal(t, http.StatusOK, do(http.MethodGet, path, "").Code)
+	testUtil.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/dead-letters/nope", "").Code)
+
+	// Retrying unchanged fails again and keeps the letter.
+	testUtil.Equal(t, http.StatusConflict, do(http.MethodPost, path+"/retry", "").Code)
+	w = do(http.MethodGet, path, "")
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"attempts":2`))
+
+	testUtil.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPut, path, `{}`).Code)
+	testUtil.Equal(t, http.StatusOK, do(http.MethodPut, path, `{"payload": 7}`).Code)
+	testUtil.Equal(t, http.StatusBadRequest, do(http.MethodPost, path+"/retry", "").Code)
+
+	w = do(http.MethodPut, path, `{"payload": {"title": "Dune"}}`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"title":"Dune"`))
+
+	// Only the handler that failed is called again.
+	testUtil.Equal(t, http.StatusNoContent, do(http.MethodPost, path+"/retry", "").Code)
+	testUtil.Equal(t, "Dune", strings.Join(indexed, ","))
+	testUtil.Equal(t, 1, projected)
+	testUtil.Equal(t, http.StatusNotFound, do(http.MethodGet, path, "").Code)
+
+	// A letter whose handler is gone can only be discarded.
+	testUtil.NoError(t, db.Create(&deadletter.Letter{ID: uuid.MustParse(letter.ID), Event: "book.created", Handler: "removed", Payload: "null", Attempts: 1}).Error)
+	testUtil.Equal(t, http.StatusConflict, do(http.MethodPost, path+"/retry", "").Code)
+	testUtil.Equal(t, http.StatusNoContent, do(http.MethodDelete, path, "").Code)
+	testUtil.Equal(t, http.StatusNotFound, do(http.MethodDelete, path, "").Code)
+}
+
+func TestAPI_Job(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &deadletter.Letter{})
+	bus := deadletter.NewBus(event.NewBus(), db)
+
+	jobs := worker.NewRunner(worker.NewMemoryBackend(1), worker.Retry{Attempts: 1})
+	var expired []string
+	jobs.Handle("loan.expire_holds", func(_ context.Context, j *worker.Job) error {
+		var p payload
+		if err := j.Decode(&p); err != nil {
+			return err
+		}
+		expired = append(expired, p.Title)
+		return nil
+	}, nil)
+
+	ctx := context.Background()
+	j := &worker.Job{ID: "j1", Type: "loan.expire_holds", Payload: json.RawMessage(`{"title":"Dune"}`), Attempt: 3, EnqueuedAt: time.Now()}
+	bus.KeepJob(ctx, j, errors.New("db down"))
+
+	api := deadletter.New(bus, jobs, validatorUtil.New())
+	r := chi.NewRouter()
+	r.Get("/admin/dead-letters", e.Handle(api.List))
+	r.Post("/admin/dead-letters/{id}/retry", e.Handle(api.Retry))
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	var dtos []*deadletter.DTO
+	testUtil.NoError(t, json.Unmarshal(do(http.MethodGet, "/admin/dead-letters").Body.Bytes(), &dtos))
+	testUtil.Equal(t, 1, len(dtos))
+	letter := dtos[0]
+	testUtil.Equal(t, deadletter.KindJob, letter.Kind)
+	testUtil.Equal(t, "loan.expire_holds", letter.Event)
+	testUtil.Equal(t, "j1", letter.AggregateID)
+	testUtil.Equal(t, "db down", letter.Error)
+	testUtil.Equal(t, 3, letter.Attempts)
+
+	// Retrying enqueues the job again, and removes the letter.
+	path := "/admin/dead-letters/" + letter.ID + "/retry"
+	testUtil.Equal(t, http.StatusNoContent, do(http.MethodPost, path).Code)
+	testUtil.Equal(t, 1, jobs.Stats().Queued)
+	testUtil.Equal(t, http.StatusNotFound, do(http.MethodPost, path).Code)
+
+	// A full queue keeps the letter.
+	bus.KeepJob(ctx, j, errors.New("db down"))
+	testUtil.NoError(t, json.Unmarshal(do(http.MethodGet, "/admin/dead-letters").Body.Bytes(), &dtos))
+	testUtil.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "/admin/dead-letters/"+dtos[0].ID+"/retry").Code)
+
+	// The job enqueued again runs with its payload.
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jobs.Run(runCtx)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for jobs.JobStats()[0].Succeeded == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	testUtil.Equal(t, "Dune", strings.Join(expired, ","))
+}
@@ -0,0 +1,83 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of letters: failed event deliveries, and background jobs given up
+// on, whose Event is the job type and AggregateID the job ID.
+const (
+	KindEvent = "event"
+	KindJob   = "job"
+)
+
+type DTO struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Event       string          `json:"event"`
+	Handler     string          `json:"handler"`
+	AggregateID string          `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Payload     json.RawMessage `json:"payload"`
+	Error       string          `json:"error"`
+	Attempts    int             `json:"attempts"`
+	FailedAt    time.Time       `json:"failed_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Form replaces the payload of a letter before it is retried, e.g. to fix
+// a field its handler rejects.
+type Form struct {
+	Payload json.RawMessage `json:"payload" validate:"required"`
+}
+
+// Letter is an event delivery a subscriber failed, or a job the runner
+// gave up on, kept until it is retried successfully or discarded. Attempts
+// counts the failed deliveries or runs, the first included; Error is that
+// of the last.
+type Letter struct {
+	ID          uuid.UUID `gorm:"primarykey"`
+	Kind        string    `gorm:"default:event"`
+	Event       string
+	Handler     string
+	AggregateID string
+	OccurredAt  time.Time
+	Payload     string `gorm:"type:jsonb"`
+	Error       string
+	Attempts    int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (Letter) TableName() string {
+	return "dead_letters"
+}
+
+type Letters []*Letter
+
+func (l *Letter) ToDto() *DTO {
+	return &DTO{
+		ID:          l.ID.String(),
+		Kind:        l.Kind,
+		Event:       l.Event,
+		Handler:     l.Handler,
+		AggregateID: l.AggregateID,
+		OccurredAt:  l.OccurredAt,
+		Payload:     json.RawMessage(l.Payload),
+		Error:       l.Error,
+		Attempts:    l.Attempts,
+		FailedAt:    l.CreatedAt,
+		UpdatedAt:   l.UpdatedAt,
+	}
+}
+
+func (ls Letters) ToDto() []*DTO {
+	dtos := make([]*DTO, len(ls))
+	for i, v := range ls {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
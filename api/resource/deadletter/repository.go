@@ -0,0 +1,99 @@
+package deadletter
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) Create(l *Letter) error {
+	return r.db.Create(l).Error
+}
+
+// List returns letters, the oldest first, of event and handler when they
+// are set.
+func (r *Repository) List(event, handler string, limit, offset int) (Letters, error) {
+	q := r.db.Order("created_at, id").Limit(limit).Offset(offset)
+	if event != "" {
+		q = q.Where("event = ?", event)
+	}
+	if handler != "" {
+		q = q.Where("handler = ?", handler)
+	}
+
+	letters := make([]*Letter, 0)
+	if err := q.Find(&letters).Error; err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+func (r *Repository) Read(id uuid.UUID) (*Letter, error) {
+	l := &Letter{}
+	if err := r.db.Where("id = ?", id).First(l).Error; err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// UpdatePayload replaces the payload of a letter, returning
+// gorm.ErrRecordNotFound when there is no such letter.
+func (r *Repository) UpdatePayload(id uuid.UUID, payload string, at time.Time) (*Letter, error) {
+	res := r.db.Model(&Letter{}).Where("id = ?", id).
+		Updates(map[string]any{"payload": payload, "updated_at": at})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.Read(id)
+}
+
+// Failed counts another failed delivery of a letter, keeping its error.
+func (r *Repository) Failed(id uuid.UUID, cause string, at time.Time) (*Letter, error) {
+	res := r.db.Model(&Letter{}).Where("id = ?", id).Updates(map[string]any{
+		"error":      cause,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"updated_at": at,
+	})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.Read(id)
+}
+
+// Delete removes a letter, returning gorm.ErrRecordNotFound when there is
+// no such letter.
+func (r *Repository) Delete(id uuid.UUID) error {
+	res := r.db.Where("id = ?", id).Delete(&Letter{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package denylist
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/moderation"
+	validatorUtil "hello/util/validator"
+)
+
+// API manages the content filter's deny-list at runtime. Changes are applied
+// to the in-memory filter immediately and persisted so they survive restarts.
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	filter     *moderation.Filter
+}
+
+func New(db *gorm.DB, v *validator.Validate, filter *moderation.Filter) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		filter:     filter,
+	}
+}
+
+// Load adds the persisted terms to filter.
+func Load(db *gorm.DB, filter *moderation.Filter) {
+	terms, err := NewRepository(db).List()
+	if err != nil {
+		log.Printf("deny-list load: %s", err)
+		return
+	}
+
+	for _, t := range terms {
+		filter.Add(t.Term)
+	}
+}
+
+// List godoc
+//
+//	@summary        List deny-list
+//	@description    List the content filter's deny-listed terms and action
+//	@tags           admin
+//	@produce        json
+//	@success        200 {object}    DTO
+//	@router         /admin/moderation/deny-list [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	dto := &DTO{
+		Action: string(api.filter.Action()),
+		Terms:  api.filter.Terms(),
+	}
+
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Create godoc
+//
+//	@summary        Add deny-list terms
+//	@description    Add words or phrases to the content filter's deny-list
+//	@tags           admin
+//	@accept         json
+//	@produce        json
+//	@param          body    body    Form    true    "Terms"
+//	@success        201
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/moderation/deny-list [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	terms := make(Terms, 0, len(form.Terms))
+	for _, t := range form.Terms {
+		if t = moderation.Normalize(t); t != "" {
+			terms = append(terms, &Term{Term: t})
+		}
+	}
+
+	if err := api.repository.WithContext(r.Context()).Create(terms); err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	for _, t := range terms {
+		api.filter.Add(t.Term)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Remove deny-list term
+//	@description    Remove a word or phrase from the content filter's deny-list
+//	@tags           admin
+//	@param          term    path    string  true    "Term"
+//	@success        200
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/moderation/deny-list/{term} [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	term := moderation.Normalize(chi.URLParam(r, "term"))
+
+	rows, err := api.repository.WithContext(r.Context()).Delete(term)
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+
+	// Terms seeded from config are not persisted, so a term may live only in
+	// the in-memory filter.
+	if removed := api.filter.Remove(term); rows == 0 && !removed {
+		return e.RespNotFound
+	}
+	return nil
+}
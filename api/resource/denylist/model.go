@@ -0,0 +1,23 @@
+package denylist
+
+import "time"
+
+type DTO struct {
+	Action string   `json:"action"`
+	Terms  []string `json:"terms"`
+}
+
+type Form struct {
+	Terms []string `json:"terms" validate:"required,min=1,dive,required,max=100"`
+}
+
+type Term struct {
+	Term      string `gorm:"primarykey"`
+	CreatedAt time.Time
+}
+
+type Terms []*Term
+
+func (Term) TableName() string {
+	return "deny_list_terms"
+}
@@ -0,0 +1,41 @@
+package denylist
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) List() (Terms, error) {
+	terms := make([]*Term, 0)
+	if err := r.db.Order("term").Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+func (r *Repository) Create(terms Terms) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&terms).Error
+}
+
+func (r *Repository) Delete(term string) (int64, error) {
+	result := r.db.Where("term = ?", term).Delete(&Term{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,70 @@
+package deprecation
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+)
+
+const defaultReportDays = 30
+
+type API struct {
+	repository *Repository
+}
+
+func New(db *gorm.DB) *API {
+	return &API{
+		repository: NewRepository(db),
+	}
+}
+
+func (rr *ReportRow) ToDto() *DTO {
+	return &DTO{
+		Item:       rr.Item,
+		Client:     rr.Client,
+		Requests:   rr.Requests,
+		FirstDay:   rr.FirstDay.Format("2006-01-02"),
+		LastSeenAt: rr.LastSeenAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func (rp Report) ToDto() []*DTO {
+	dtos := make([]*DTO, len(rp))
+	for i, v := range rp {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
+
+// Report godoc
+//
+//	@summary        Deprecation usage report
+//	@description    Requests per client to deprecated routes and parameters
+//	@tags           admin
+//	@produce        json
+//	@param          days    query   int     false   "Look-back window in days (default 30)"
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/deprecations [get]
+func (api *API) Report(w http.ResponseWriter, r *http.Request) error {
+	days := defaultReportDays
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 {
+		days = d
+	}
+
+	report, err := api.repository.WithContext(r.Context()).Report(time.Now().UTC().AddDate(0, 0, -days))
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(report.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package deprecation
+
+import "time"
+
+type DTO struct {
+	Item       string `json:"item"`
+	Client     string `json:"client"`
+	Requests   int64  `json:"requests"`
+	FirstDay   string `json:"first_day"`
+	LastSeenAt string `json:"last_seen_at"`
+}
+
+// Usage is a daily rollup of requests by one client to one deprecated item.
+type Usage struct {
+	Day        time.Time `gorm:"primarykey;type:date"`
+	Item       string    `gorm:"primarykey"`
+	Client     string    `gorm:"primarykey"`
+	Count      int64
+	LastSeenAt time.Time
+}
+
+type Usages []*Usage
+
+func (Usage) TableName() string {
+	return "deprecation_usages"
+}
+
+type ReportRow struct {
+	Item       string
+	Client     string
+	Requests   int64
+	FirstDay   time.Time
+	LastSeenAt time.Time
+}
+
+type Report []*ReportRow
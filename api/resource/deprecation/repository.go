@@ -0,0 +1,51 @@
+package deprecation
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// Increment adds usages onto the existing daily rollups.
+func (r *Repository) Increment(usages Usages) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "item"}, {Name: "client"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"count":        gorm.Expr("deprecation_usages.count + excluded.count"),
+			"last_seen_at": gorm.Expr("GREATEST(deprecation_usages.last_seen_at, excluded.last_seen_at)"),
+		}),
+	}).Create(&usages).Error
+}
+
+func (r *Repository) Report(since time.Time) (Report, error) {
+	report := make(Report, 0)
+	err := r.db.Model(&Usage{}).
+		Select("item, client, SUM(count) AS requests, MIN(day) AS first_day, MAX(last_seen_at) AS last_seen_at").
+		Where("day >= ?", since).
+		Group("item, client").
+		Order("item, requests DESC").
+		Scan(&report).Error
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
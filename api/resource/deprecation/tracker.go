@@ -0,0 +1,101 @@
+package deprecation
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type usageKey struct {
+	day    time.Time
+	item   string
+	client string
+}
+
+// Tracker buffers deprecated-item usage in memory and periodically folds it
+// into the daily rollups, keeping the request path free of DB writes.
+type Tracker struct {
+	repository *Repository
+
+	mu      sync.Mutex
+	pending map[usageKey]*Usage
+}
+
+func NewTracker(db *gorm.DB) *Tracker {
+	return &Tracker{
+		repository: NewRepository(db),
+		pending:    make(map[usageKey]*Usage),
+	}
+}
+
+func (t *Tracker) Record(item, client string) {
+	now := time.Now().UTC()
+	k := usageKey{day: now.Truncate(24 * time.Hour), item: item, client: client}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u, ok := t.pending[k]
+	if !ok {
+		u = &Usage{Day: k.day, Item: item, Client: client}
+		t.pending[k] = u
+	}
+	u.Count++
+	u.LastSeenAt = now
+}
+
+// Flush writes the buffered usage. On failure the usage is put back so the
+// next flush retries it.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[usageKey]*Usage)
+	t.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	usages := make(Usages, 0, len(pending))
+	for _, u := range pending {
+		usages = append(usages, u)
+	}
+
+	if err := t.repository.Increment(usages); err != nil {
+		t.mu.Lock()
+		for k, u := range pending {
+			if cur, ok := t.pending[k]; ok {
+				cur.Count += u.Count
+			} else {
+				t.pending[k] = u
+			}
+		}
+		t.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Run flushes every interval until ctx is done, then flushes once more.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				log.Printf("deprecation usage flush: %s", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				log.Printf("deprecation usage flush: %s", err)
+			}
+		}
+	}
+}
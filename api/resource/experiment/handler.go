@@ -0,0 +1,161 @@
+package experiment
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	exp "hello/experiment"
+	validatorUtil "hello/util/validator"
+)
+
+// API manages the experiments stored in the database. Changes are applied
+// to this instance's service immediately; other instances pick them up when
+// they next reload.
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	service    *exp.Service
+}
+
+func New(db *gorm.DB, v *validator.Validate, service *exp.Service) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		service:    service,
+	}
+}
+
+// Loader returns a function reading the stored experiments, for the
+// service to reload them with.
+func Loader(db *gorm.DB) func(context.Context) (exp.Experiments, error) {
+	repository := NewRepository(db)
+	return func(ctx context.Context) (exp.Experiments, error) {
+		experiments, err := repository.WithContext(ctx).List()
+		if err != nil {
+			return nil, err
+		}
+		return experiments.ToExperiments(), nil
+	}
+}
+
+// Assignments godoc
+//
+//	@summary        List experiment assignments
+//	@description    List the variant of every running experiment the caller is assigned to: the signed-in user, or else the anonymous client sending X-Experiment-Key. Each assignment is logged as an exposure
+//	@tags           experiments
+//	@produce        json
+//	@param          X-Experiment-Key    header  string  false   "Stable key of an anonymous client"
+//	@success        200 {object}    AssignmentsDTO
+//	@failure        500 {object}    err.Problem
+//	@router         /experiments [get]
+func (api *API) Assignments(w http.ResponseWriter, r *http.Request) error {
+	dto := &AssignmentsDTO{Assignments: exp.All(r.Context())}
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// List godoc
+//
+//	@summary        List experiments
+//	@description    List the running experiments, whether defined in the experiments file or stored
+//	@tags           admin
+//	@produce        json
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/experiments [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	experiments := api.service.List()
+	dtos := make([]*DTO, len(experiments))
+	for i, ex := range experiments {
+		source := SourceFile
+		if api.service.Stored(ex.Key) {
+			source = SourceDatabase
+		}
+		dtos[i] = &DTO{Key: ex.Key, Variants: ex.Variants, Source: source}
+	}
+
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Put godoc
+//
+//	@summary        Put experiment
+//	@description    Create an experiment, or replace its variants. A stored experiment takes precedence over one of the same key in the experiments file
+//	@tags           admin
+//	@accept         json
+//	@produce        json
+//	@param          key     path    string  true    "Experiment key"
+//	@param          body    body    Form    true    "Variants"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/experiments/{key} [put]
+func (api *API) Put(w http.ResponseWriter, r *http.Request) error {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	ex := form.ToModel(chi.URLParam(r, "key"))
+	if err := ex.ToExperiment().Validate(); err != nil {
+		return e.RespInvalidExperiment
+	}
+
+	if err := api.repository.WithContext(r.Context()).Put(ex); err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	api.reload(r.Context())
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Delete experiment
+//	@description    Delete a stored experiment. An experiment of the same key in the experiments file runs again; those cannot be deleted
+//	@tags           admin
+//	@param          key path    string  true    "Experiment key"
+//	@success        200
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/experiments/{key} [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	rows, err := api.repository.WithContext(r.Context()).Delete(chi.URLParam(r, "key"))
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	api.reload(r.Context())
+	return nil
+}
+
+// reload applies the stored experiments after a change. The change is
+// saved either way, so a failure only delays it until the next reload.
+func (api *API) reload(ctx context.Context) {
+	experiments, err := api.repository.WithContext(ctx).List()
+	if err != nil {
+		log.Printf("experiment reload: %s", err)
+		return
+	}
+	api.service.Set(experiments.ToExperiments())
+}
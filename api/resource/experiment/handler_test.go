@@ -0,0 +1,78 @@
+package experiment_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	e "hello/api/resource/common/err"
+	"hello/api/resource/experiment"
+	exp "hello/experiment"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Experiments(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &experiment.Experiment{})
+
+	service := exp.New(exp.Experiments{
+		"shelf": {Key: "shelf", Variants: exp.Variants{{Name: "list", Weight: 1}}},
+	}, nil)
+	api := experiment.New(db, validatorUtil.New(), service)
+	r := chi.NewRouter()
+	r.Use(service.Middleware)
+	r.Get("/experiments", e.Handle(api.Assignments))
+	r.Get("/admin/experiments", e.Handle(api.List))
+	r.Put("/admin/experiments/{key}", e.Handle(api.Put))
+	r.Delete("/admin/experiments/{key}", e.Handle(api.Delete))
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(exp.HeaderKey, "device-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	list := func() []*experiment.DTO {
+		var dtos []*experiment.DTO
+		testUtil.NoError(t, json.Unmarshal(serve(http.MethodGet, "/admin/experiments", "").Body.Bytes(), &dtos))
+		return dtos
+	}
+
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPut, "/admin/experiments/banner", `{"variants": []}`).Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPut, "/admin/experiments/banner", `{"variants": [{"name": "on", "weight": 0}]}`).Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "/admin/experiments/Banner", `{"variants": [{"name": "on", "weight": 1}]}`).Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "/admin/experiments/banner", `{"variants": [{"name": "on", "weight": 1}, {"name": "on", "weight": 2}]}`).Code)
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/admin/experiments/banner", `{"variants": [{"name": "on", "weight": 1}]}`).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/admin/experiments/shelf", `{"variants": [{"name": "grid", "weight": 1}]}`).Code)
+
+	dtos := list()
+	testUtil.Equal(t, 2, len(dtos))
+	testUtil.Equal(t, "banner", dtos[0].Key)
+	testUtil.Equal(t, experiment.SourceDatabase, dtos[1].Source)
+	testUtil.Equal(t, "grid", dtos[1].Variants[0].Name)
+
+	w := serve(http.MethodGet, "/experiments", "")
+	testUtil.Equal(t, `{"assignments":[{"experiment":"banner","variant":"on"},{"experiment":"shelf","variant":"grid"}]}`, strings.TrimSpace(w.Body.String()))
+	testUtil.Equal(t, "banner=on, shelf=grid", w.Header().Get(exp.HeaderAssignments))
+
+	// Deleting the stored shelf brings back the one of the experiments file.
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, "/admin/experiments/shelf", "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/experiments/shelf", "").Code)
+	dtos = list()
+	testUtil.Equal(t, experiment.SourceFile, dtos[1].Source)
+	testUtil.Equal(t, "list", dtos[1].Variants[0].Name)
+
+	stored, err := experiment.Loader(db)(context.Background())
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(stored))
+}
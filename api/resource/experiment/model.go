@@ -0,0 +1,70 @@
+package experiment
+
+import (
+	"time"
+
+	exp "hello/experiment"
+)
+
+// Sources of experiment definitions.
+const (
+	SourceFile     = "file"
+	SourceDatabase = "database"
+)
+
+type DTO struct {
+	Key      string        `json:"key"`
+	Variants []exp.Variant `json:"variants"`
+	Source   string        `json:"source"`
+}
+
+// Form defines the variants of an experiment. Changing the variants or
+// their weights reassigns some units, so an experiment should be left alone
+// while it runs.
+type Form struct {
+	Variants []*VariantForm `json:"variants" validate:"required,min=1,max=20,dive,required"`
+}
+
+type VariantForm struct {
+	Name   string `json:"name" validate:"required,identifier,max=63"`
+	Weight int    `json:"weight" validate:"required,min=1,max=10000"`
+}
+
+type AssignmentsDTO struct {
+	Assignments []exp.Assignment `json:"assignments"`
+}
+
+// Experiment is an experiment defined at runtime. It takes precedence over
+// one of the same key in the experiments file.
+type Experiment struct {
+	Key       string       `gorm:"primarykey"`
+	Variants  exp.Variants `gorm:"type:jsonb"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Experiments []*Experiment
+
+func (Experiment) TableName() string {
+	return "experiments"
+}
+
+func (f *Form) ToModel(key string) *Experiment {
+	variants := make(exp.Variants, len(f.Variants))
+	for i, v := range f.Variants {
+		variants[i] = exp.Variant{Name: v.Name, Weight: v.Weight}
+	}
+	return &Experiment{Key: key, Variants: variants}
+}
+
+func (e *Experiment) ToExperiment() *exp.Experiment {
+	return &exp.Experiment{Key: e.Key, Variants: e.Variants}
+}
+
+func (es Experiments) ToExperiments() exp.Experiments {
+	m := make(exp.Experiments, len(es))
+	for _, e := range es {
+		m[e.Key] = e.ToExperiment()
+	}
+	return m
+}
@@ -0,0 +1,46 @@
+package experiment
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) List() (Experiments, error) {
+	experiments := make([]*Experiment, 0)
+	if err := r.db.Order("key").Find(&experiments).Error; err != nil {
+		return nil, err
+	}
+	return experiments, nil
+}
+
+// Put creates or replaces the experiment of its key.
+func (r *Repository) Put(e *Experiment) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"variants", "updated_at"}),
+	}).Create(e).Error
+}
+
+func (r *Repository) Delete(key string) (int64, error) {
+	result := r.db.Where("key = ?", key).Delete(&Experiment{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,28 @@
+package genre
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+
+	"hello/event"
+)
+
+// EventDeleted carries the books a deleted genre was removed from, so that
+// read models listing their genres follow.
+const EventDeleted = "genre.deleted"
+
+// DeletedDTO is the payload of EventDeleted.
+type DeletedDTO struct {
+	Slug    string      `json:"slug"`
+	BookIDs []uuid.UUID `json:"book_ids"`
+}
+
+// publish notifies subscribers of a committed change. Failures are logged
+// rather than returned because the write itself has already succeeded.
+func (api *API) publish(ctx context.Context, name string, id uuid.UUID, payload any) {
+	if err := api.bus.Publish(ctx, event.New(name, id.String(), payload)); err != nil {
+		log.Printf("event %s for %s: %s", name, id, err)
+	}
+}
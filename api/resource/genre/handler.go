@@ -0,0 +1,107 @@
+package genre
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/event"
+	"hello/util/sanitizer"
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	bus        event.Bus
+}
+
+// Problems maps the errors of genres to responses, for err.Middleware.
+var Problems = e.Is(ErrDuplicate, e.RespDuplicateGenre)
+
+func New(db *gorm.DB, v *validator.Validate, bus event.Bus) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		bus:        bus,
+	}
+}
+
+// List godoc
+//
+//	@summary        List genres
+//	@description    List the genres books can be tagged with
+//	@tags           genres
+//	@produce        json
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /genres [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	genres, err := api.repository.WithContext(r.Context()).List()
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(genres.ToDto())
+}
+
+// Create godoc
+//
+//	@summary        Create genre
+//	@description    Add a genre books can be tagged with
+//	@tags           genres
+//	@accept         json
+//	@param          body    body    Form    true    "Genre form"
+//	@success        201
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /genres [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	sanitizer.Struct(form)
+	if err := api.validator.Struct(form); err != nil {
+		return err
+	}
+
+	genre := form.ToModel()
+	genre.ID = uuid.New()
+
+	if _, err := api.repository.WithContext(r.Context()).Create(genre); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Delete genre
+//	@description    Delete a genre, removing it from the books tagged with it
+//	@tags           genres
+//	@param          slug    path    string  true    "Genre slug"
+//	@success        200
+//	@failure        404 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /genres/{slug} [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	genre, bookIDs, err := api.repository.WithContext(r.Context()).Delete(chi.URLParam(r, "slug"))
+	if err != nil {
+		return err
+	}
+	if genre == nil {
+		return e.RespNotFound
+	}
+
+	api.publish(r.Context(), EventDeleted, genre.ID, &DeletedDTO{Slug: genre.Slug, BookIDs: bookIDs})
+	return nil
+}
@@ -0,0 +1,56 @@
+package genre
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DTO struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type Form struct {
+	Slug string `json:"slug" validate:"required,identifier,max=63"`
+	Name string `json:"name" validate:"required,max=255" sanitize:"singleline"`
+}
+
+// Genre is a category books are tagged with. A book may have many genres;
+// they are linked in the book_genres table.
+type Genre struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	Slug      string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Genres []*Genre
+
+func (Genre) TableName() string {
+	return "genres"
+}
+
+func (f *Form) ToModel() *Genre {
+	return &Genre{
+		Slug: f.Slug,
+		Name: f.Name,
+	}
+}
+
+func (g *Genre) ToDto() *DTO {
+	return &DTO{
+		Slug: g.Slug,
+		Name: g.Name,
+	}
+}
+
+func (gs Genres) ToDto() []*DTO {
+	dtos := make([]*DTO, len(gs))
+	for i, v := range gs {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
@@ -0,0 +1,93 @@
+package genre
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) List() (Genres, error) {
+	genres := make([]*Genre, 0)
+	if err := r.db.Order("slug").Find(&genres).Error; err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// BySlug returns the genres of slugs that exist, in slug order.
+func (r *Repository) BySlug(slugs []string) (Genres, error) {
+	genres := make([]*Genre, 0, len(slugs))
+	if len(slugs) == 0 {
+		return genres, nil
+	}
+
+	if err := r.db.Where("slug IN ?", slugs).Order("slug").Find(&genres).Error; err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// ErrDuplicate is returned when creating a genre whose slug is taken.
+var ErrDuplicate = errors.New("genre: slug already exists")
+
+func (r *Repository) Create(g *Genre) (*Genre, error) {
+	if err := r.db.Create(g).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrDuplicate
+		}
+		return nil, err
+	}
+	return g, nil
+}
+
+// Delete removes the genre of slug, untagging the books tagged with it. It
+// returns the genre and the books untagged, or a nil genre if there is none
+// of slug.
+func (r *Repository) Delete(slug string) (*Genre, []uuid.UUID, error) {
+	var deleted *Genre
+	bookIDs := make([]uuid.UUID, 0)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		genre := &Genre{}
+		result := tx.Where("slug = ?", slug).Limit(1).Find(genre)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := tx.Table("book_genres").Where("genre_id = ?", genre.ID).Order("book_id").Pluck("book_id", &bookIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM book_genres WHERE genre_id = ?", genre.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(genre).Error; err != nil {
+			return err
+		}
+		deleted = genre
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return deleted, bookIDs, nil
+}
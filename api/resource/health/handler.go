@@ -1,6 +1,44 @@
 package health
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hello/config"
+)
+
+// replicationLagQuery returns the replay lag in seconds on a standby and zero
+// on a primary. A standby that has replayed all it received is caught up,
+// however long ago the primary last wrote; otherwise the lag is the age of
+// the last transaction replayed.
+const replicationLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+type API struct {
+	db     *gorm.DB
+	region *config.ConfRegion
+}
+
+type ReadinessDTO struct {
+	Status                string  `json:"status"`
+	Region                string  `json:"region"`
+	Role                  string  `json:"role"`
+	ReplicationLagSeconds float64 `json:"replication_lag_seconds"`
+	Reason                string  `json:"reason,omitempty"`
+}
+
+func New(db *gorm.DB, c *config.ConfRegion) *API {
+	return &API{
+		db:     db,
+		region: c,
+	}
+}
 
 // Read godoc
 //
@@ -10,3 +48,38 @@ import "net/http"
 //	@success        200
 //	@router         /../livez [get]
 func Read(w http.ResponseWriter, r *http.Request) {}
+
+// Ready godoc
+//
+//	@summary        Read readiness
+//	@description    Read readiness, including replication lag when running as a replica
+//	@tags           health
+//	@produce        json
+//	@success        200 {object}    ReadinessDTO
+//	@failure        503 {object}    ReadinessDTO
+//	@router         /../readyz [get]
+func (api *API) Ready(w http.ResponseWriter, r *http.Request) {
+	dto := &ReadinessDTO{
+		Status: "ready",
+		Region: api.region.Name,
+		Role:   api.region.Role,
+	}
+
+	var lag float64
+	if err := api.db.WithContext(r.Context()).Raw(replicationLagQuery).Scan(&lag).Error; err != nil {
+		dto.Status = "not_ready"
+		dto.Reason = "db unreachable"
+	} else {
+		dto.ReplicationLagSeconds = lag
+		if api.region.MaxReplicationLag > 0 && time.Duration(lag*float64(time.Second)) > api.region.MaxReplicationLag {
+			dto.Status = "not_ready"
+			dto.Reason = "replication lag exceeds threshold"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if dto.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(dto)
+}
@@ -0,0 +1,44 @@
+package health_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"hello/api/resource/health"
+	"hello/config"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+)
+
+func TestAPI_Ready(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		lag    float64
+		status int
+	}{
+		{"within threshold", 1.5, http.StatusOK},
+		{"exceeds threshold", 45, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := mockDB.NewMockDB()
+			testUtil.NoError(t, err)
+
+			mock.ExpectQuery(`^SELECT CASE\s+WHEN NOT pg_is_in_recovery\(\) THEN 0\s+WHEN pg_last_wal_receive_lsn\(\) = pg_last_wal_replay_lsn\(\) THEN 0`).
+				WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(tt.lag))
+
+			api := health.New(db, &config.ConfRegion{Name: "eu-west-1", Role: "passive", MaxReplicationLag: 30 * time.Second})
+
+			w := httptest.NewRecorder()
+			api.Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			testUtil.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package interaction
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+	"hello/util/sanitizer"
+	validatorUtil "hello/util/validator"
+)
+
+type API struct {
+	recorder  *Recorder
+	validator *validator.Validate
+	now       func() time.Time
+}
+
+func New(recorder *Recorder, v *validator.Validate) *API {
+	return &API{
+		recorder:  recorder,
+		validator: v,
+		now:       time.Now,
+	}
+}
+
+// Create godoc
+//
+//	@summary        Record interaction events
+//	@description    Record a batch of up to 100 book views, searches and checkouts, which are stored to train recommendations. Events are attributed to the signed-in user, if any, and to their session. The batch is accepted or refused as a whole
+//	@tags           events
+//	@accept         json
+//	@produce        json
+//	@param          body    body    Form    true    "Event batch"
+//	@success        202 {object}    AcceptedDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@failure        503 {object}    err.Problem
+//	@router         /events [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+	for _, ev := range form.Events {
+		if ev != nil {
+			sanitizer.Struct(ev)
+		}
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	var userID *uuid.UUID
+	if id, ok := user.From(r.Context()); ok {
+		userID = &id
+	}
+
+	now := api.now().UTC()
+	events := make(Events, len(form.Events))
+	for i, ev := range form.Events {
+		event, err := toEvent(ev, userID, now)
+		if err != nil {
+			return e.RespInvalidEventID
+		}
+		events[i] = event
+	}
+
+	if err := api.recorder.Record(events); err != nil {
+		if errors.Is(err, ErrBufferFull) {
+			w.Header().Set("Retry-After", "5")
+			return e.RespEventBufferFull
+		}
+
+		return e.RespDBDataInsertFailure
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(&AcceptedDTO{Accepted: len(events)}); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// toEvent turns a validated form into an event received at now. Only the
+// field its type calls for is kept, and times ahead of now are taken as
+// now, so that a client clock running ahead does not reorder sessions.
+func toEvent(f *EventForm, userID *uuid.UUID, now time.Time) (*Event, error) {
+	ev := &Event{
+		ID:         uuid.New(),
+		Type:       f.Type,
+		UserID:     userID,
+		SessionID:  f.SessionID,
+		OccurredAt: now,
+		ReceivedAt: now,
+	}
+	if f.OccurredAt != nil && f.OccurredAt.Before(now) {
+		ev.OccurredAt = f.OccurredAt.UTC()
+	}
+
+	var err error
+	switch f.Type {
+	case TypeView:
+		ev.BookID, err = decode(f.BookID)
+	case TypeSearch:
+		ev.Query = f.Query
+	case TypeCheckout:
+		ev.OrderID, err = decode(f.OrderID)
+	}
+	return ev, err
+}
+
+func decode(s string) (*uuid.UUID, error) {
+	id, err := idcodec.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
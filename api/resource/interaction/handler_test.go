@@ -0,0 +1,81 @@
+package interaction_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/interaction"
+	"hello/config"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Create(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &interaction.Event{})
+
+	recorder := interaction.NewRecorder(db, &config.ConfInteraction{BatchSize: 2, BufferSize: 4})
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Post("/events", e.Handle(interaction.New(recorder, validatorUtil.New()).Create))
+
+	alice := uuid.New()
+	serve := func(body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		req.Header.Set(user.Header, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	stored := func() []*interaction.Event {
+		var events []*interaction.Event
+		testUtil.NoError(t, db.Order("occurred_at").Find(&events).Error)
+		return events
+	}
+
+	bookID := uuid.NewString()
+	batch := `{"events": [
+		{"type": "search", "session_id": "s1", "query": "dune", "occurred_at": "2024-03-01T08:00:00Z"},
+		{"type": "view", "session_id": "s1", "book_id": "` + bookID + `", "query": "ignored", "occurred_at": "2024-03-01T08:00:05Z"},
+		{"type": "checkout", "session_id": "s1", "order_id": "` + uuid.NewString() + `", "occurred_at": "2999-01-01T00:00:00Z"}
+	]}`
+
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(`{"events": []}`, "").Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(`{"events": [{"type": "view", "session_id": "s1"}]}`, "").Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(`{"events": [{"type": "like", "session_id": "s1", "book_id": "`+bookID+`"}]}`, "").Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(`{"events": [null]}`, "").Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(`{"events": [{"type": "view", "session_id": "s1", "book_id": "nope"}]}`, "").Code)
+
+	w := serve(batch, alice.String())
+	testUtil.Equal(t, http.StatusAccepted, w.Code)
+	testUtil.Equal(t, `{"accepted":3}`, strings.TrimSpace(w.Body.String()))
+
+	// Nothing is written before a flush, and a batch that does not fit the
+	// buffer is refused whole.
+	testUtil.Equal(t, 0, len(stored()))
+	testUtil.Equal(t, http.StatusServiceUnavailable, serve(batch, "").Code)
+
+	testUtil.NoError(t, recorder.Flush(context.Background()))
+	events := stored()
+	testUtil.Equal(t, 3, len(events))
+	testUtil.Equal(t, interaction.TypeSearch, events[0].Type)
+	testUtil.Equal(t, "dune", events[0].Query)
+	testUtil.Equal(t, alice, *events[0].UserID)
+	testUtil.Equal(t, bookID, events[1].BookID.String())
+	testUtil.Equal(t, "", events[1].Query)
+	testUtil.Equal(t, true, events[2].OccurredAt.Year() < 2999)
+
+	testUtil.Equal(t, http.StatusAccepted, serve(batch, "").Code)
+	testUtil.NoError(t, recorder.Flush(context.Background()))
+	testUtil.Equal(t, 6, len(stored()))
+}
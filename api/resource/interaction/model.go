@@ -0,0 +1,62 @@
+package interaction
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types.
+const (
+	TypeView     = "view"
+	TypeSearch   = "search"
+	TypeCheckout = "checkout"
+	// TypeExposure is recorded by the server when a variant of an A/B
+	// experiment is served; clients cannot send it.
+	TypeExposure = "exposure"
+)
+
+// MaxBatch is the number of events one request may carry.
+const MaxBatch = 100
+
+type AcceptedDTO struct {
+	Accepted int `json:"accepted"`
+}
+
+// Form is a batch of events, sent as clients flush their own buffers.
+type Form struct {
+	Events []*EventForm `json:"events" validate:"required,min=1,max=100,dive,required"`
+}
+
+// EventForm is one interaction: a view of a book, a search, or a checkout
+// of an order. SessionID groups the events of one visit, signed in or not.
+// OccurredAt defaults to the time the batch is received.
+type EventForm struct {
+	Type       string     `json:"type" validate:"required,oneof=view search checkout"`
+	SessionID  string     `json:"session_id" validate:"required,max=64" sanitize:"singleline"`
+	BookID     string     `json:"book_id" validate:"required_if=Type view,max=64"`
+	Query      string     `json:"query" validate:"required_if=Type search,max=255" sanitize:"singleline"`
+	OrderID    string     `json:"order_id" validate:"required_if=Type checkout,max=64"`
+	OccurredAt *time.Time `json:"occurred_at"`
+}
+
+// Event is an interaction as recorded. Events are only ever appended.
+type Event struct {
+	ID         uuid.UUID `gorm:"primarykey"`
+	Type       string
+	UserID     *uuid.UUID
+	SessionID  string
+	BookID     *uuid.UUID
+	Query      string
+	OrderID    *uuid.UUID
+	Experiment string
+	Variant    string
+	OccurredAt time.Time
+	ReceivedAt time.Time
+}
+
+func (Event) TableName() string {
+	return "interaction_events"
+}
+
+type Events []*Event
@@ -0,0 +1,115 @@
+package interaction
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/config"
+	"hello/experiment"
+)
+
+var ErrBufferFull = errors.New("interaction: buffer full")
+
+// Recorder buffers events in memory and periodically appends them to the
+// database, keeping the request path free of DB writes.
+type Recorder struct {
+	repository *Repository
+	batchSize  int
+	bufferSize int
+
+	mu      sync.Mutex
+	pending Events
+}
+
+func NewRecorder(db *gorm.DB, conf *config.ConfInteraction) *Recorder {
+	return &Recorder{
+		repository: NewRepository(db),
+		batchSize:  conf.BatchSize,
+		bufferSize: conf.BufferSize,
+	}
+}
+
+// Record buffers events, all or none. It returns ErrBufferFull when they do
+// not fit, which happens when flushes fall behind or keep failing.
+func (r *Recorder) Record(events Events) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending)+len(events) > r.bufferSize {
+		return ErrBufferFull
+	}
+	r.pending = append(r.pending, events...)
+	return nil
+}
+
+// LogExposure records that an experiment variant was served, grouping the
+// exposures of anonymous clients by their experiment key. Exposures that do
+// not fit the buffer are dropped rather than failing the request.
+func (r *Recorder) LogExposure(_ context.Context, ex experiment.Exposure) {
+	err := r.Record(Events{{
+		ID:         uuid.New(),
+		Type:       TypeExposure,
+		UserID:     ex.UserID,
+		SessionID:  ex.Key,
+		Experiment: ex.Experiment,
+		Variant:    ex.Variant,
+		OccurredAt: ex.At,
+		ReceivedAt: ex.At,
+	}})
+	if err != nil {
+		log.Printf("interaction exposure: %s", err)
+	}
+}
+
+// Flush appends the buffered events. On failure they are put back, ahead of
+// those recorded since, so the next flush retries them; what no longer
+// fits in the buffer is dropped.
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := r.repository.WithContext(ctx).Append(pending, r.batchSize); err != nil {
+		r.mu.Lock()
+		r.pending = append(pending, r.pending...)
+		if dropped := len(r.pending) - r.bufferSize; dropped > 0 {
+			r.pending = r.pending[:r.bufferSize]
+			log.Printf("interaction flush: dropped %d events", dropped)
+		}
+		r.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Run flushes every interval until ctx is done, then flushes once more.
+func (r *Recorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := r.Flush(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("interaction flush: %s", err)
+			}
+			return
+		case <-ticker.C:
+			if err := r.Flush(ctx); err != nil {
+				log.Printf("interaction flush: %s", err)
+			}
+		}
+	}
+}
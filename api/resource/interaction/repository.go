@@ -0,0 +1,29 @@
+package interaction
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// Append inserts events, batchSize rows per statement.
+func (r *Repository) Append(events Events, batchSize int) error {
+	return r.db.CreateInBatches(events, batchSize).Error
+}
@@ -0,0 +1,71 @@
+package journal
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+
+	e "hello/api/resource/common/err"
+	"hello/replay"
+	validatorUtil "hello/util/validator"
+)
+
+// API switches the request journal on and off at runtime, so production
+// traffic is only journaled while a replay test is being prepared.
+type API struct {
+	journal   *replay.Journal
+	validator *validator.Validate
+}
+
+func New(j *replay.Journal, v *validator.Validate) *API {
+	return &API{
+		journal:   j,
+		validator: v,
+	}
+}
+
+// Read godoc
+//
+//	@summary        Request journal status
+//	@description    Whether sampled requests are being journaled for replay testing
+//	@tags           admin
+//	@produce        json
+//	@success        200 {object}    DTO
+//	@router         /admin/journal [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	if err := json.NewEncoder(w).Encode(&DTO{Enabled: api.journal.Enabled()}); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Update godoc
+//
+//	@summary        Switch request journal
+//	@description    Start or stop journaling sampled requests for replay testing
+//	@tags           admin
+//	@accept         json
+//	@produce        json
+//	@param          body    body    Form    true    "Journal state"
+//	@success        200 {object}    DTO
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/journal [put]
+func (api *API) Update(w http.ResponseWriter, r *http.Request) error {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	api.journal.SetEnabled(*form.Enabled)
+
+	if err := json.NewEncoder(w).Encode(&DTO{Enabled: api.journal.Enabled()}); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package journal
+
+type DTO struct {
+	Enabled bool `json:"enabled"`
+}
+
+type Form struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
@@ -0,0 +1,197 @@
+package legalhold
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/audit"
+	"hello/idcodec"
+	"hello/util/sanitizer"
+	validatorUtil "hello/util/validator"
+)
+
+// Audited actions. Placing and releasing a hold are recorded before the
+// response; attempts to change or delete a held record are noted by the
+// handlers refusing them.
+const (
+	ActionPlaced   = "legal_hold.placed"
+	ActionReleased = "legal_hold.released"
+	ActionBlocked  = "legal_hold.blocked"
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	now        func() time.Time
+}
+
+func New(db *gorm.DB, v *validator.Validate) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		now:        time.Now,
+	}
+}
+
+// List godoc
+//
+//	@summary        List legal holds
+//	@description    List the records under legal hold, most recently held first
+//	@tags           admin
+//	@produce        json
+//	@param          kind    query   string  false   "Only holds on this kind of record"  Enums(book, user)
+//	@success        200 {array}     DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/legal-holds [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	kind := r.URL.Query().Get("kind")
+	if _, ok := tables[kind]; kind != "" && !ok {
+		return e.RespInvalidHoldKind
+	}
+
+	holds, err := api.repository.WithContext(r.Context()).List(kind)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(holds.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Place godoc
+//
+//	@summary        Place legal hold
+//	@description    Put a book or user under legal hold. Until the hold is released the record cannot be changed, deleted or purged, and attempts to are audited
+//	@tags           admin
+//	@accept         json
+//	@param          kind    path    string  true    "Kind of record"  Enums(book, user)
+//	@param          id      path    string  true    "Record ID"
+//	@param          body    body    Form    true    "Legal hold form"
+//	@success        201
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/legal-holds/{kind}/{id} [post]
+func (api *API) Place(w http.ResponseWriter, r *http.Request) error {
+	kind, id, err := target(r)
+	if err != nil {
+		return err
+	}
+
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	sanitizer.Struct(form)
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	repository := api.repository.WithContext(r.Context())
+	exists, err := repository.Exists(kind, id)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if !exists {
+		return e.RespNotFound
+	}
+
+	hold := &Hold{Kind: kind, TargetID: id, Reason: form.Reason, PlacedAt: api.now().UTC()}
+	if actor, ok := user.Service(r.Context()); ok {
+		hold.PlacedBy = actor.String()
+	} else if actor, ok := user.APIKey(r.Context()); ok {
+		hold.PlacedBy = actor.String()
+	} else if actor, ok := user.From(r.Context()); ok {
+		hold.PlacedBy = actor.String()
+	}
+
+	held, err := repository.Held(kind, id)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if len(held) > 0 {
+		return e.RespAlreadyOnHold
+	}
+	if err := repository.Place(hold); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return e.RespAlreadyOnHold
+		}
+
+		return e.RespDBDataInsertFailure
+	}
+
+	if err := audit.Record(r, ActionPlaced, id.String(), audit.Details{"kind": kind, "reason": form.Reason}); err != nil {
+		return e.RespAuditFailure
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// Release godoc
+//
+//	@summary        Release legal hold
+//	@description    Release the legal hold on a book or user, so that it can be changed and deleted again
+//	@tags           admin
+//	@param          kind    path    string  true    "Kind of record"  Enums(book, user)
+//	@param          id      path    string  true    "Record ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/legal-holds/{kind}/{id} [delete]
+func (api *API) Release(w http.ResponseWriter, r *http.Request) error {
+	kind, id, err := target(r)
+	if err != nil {
+		return err
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).Release(kind, id)
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	if err := audit.Record(r, ActionReleased, id.String(), audit.Details{"kind": kind}); err != nil {
+		return e.RespAuditFailure
+	}
+	return nil
+}
+
+// target parses the kind and ID of the held record from the URL. Book IDs
+// are public IDs; user IDs are UUIDs.
+func target(r *http.Request) (string, uuid.UUID, error) {
+	kind := chi.URLParam(r, "kind")
+	if _, ok := tables[kind]; !ok {
+		return "", uuid.Nil, e.RespInvalidHoldKind
+	}
+
+	decode := uuid.Parse
+	if kind == KindBook {
+		decode = idcodec.Decode
+	}
+	id, err := decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return "", uuid.Nil, e.RespInvalidURLParamID
+	}
+	return kind, id, nil
+}
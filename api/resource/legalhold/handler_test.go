@@ -0,0 +1,73 @@
+package legalhold_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/resource/auth"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/legalhold"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_LegalHolds(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &legalhold.Hold{}, &book.Book{}, &auth.User{})
+
+	dune := &book.Book{ID: uuid.New(), Title: "Dune"}
+	testUtil.NoError(t, db.Create(dune).Error)
+	alice := &auth.User{ID: uuid.New(), Email: "alice@example.com"}
+	testUtil.NoError(t, db.Create(alice).Error)
+
+	api := legalhold.New(db, validatorUtil.New())
+	r := chi.NewRouter()
+	r.Get("/admin/legal-holds", e.Handle(api.List))
+	r.Post("/admin/legal-holds/{kind}/{id}", e.Handle(api.Place))
+	r.Delete("/admin/legal-holds/{kind}/{id}", e.Handle(api.Release))
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	list := func(query string) []*legalhold.DTO {
+		var dtos []*legalhold.DTO
+		testUtil.NoError(t, json.Unmarshal(serve(http.MethodGet, "/admin/legal-holds"+query, "").Body.Bytes(), &dtos))
+		return dtos
+	}
+
+	reason := `{"reason": "litigation"}`
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/admin/legal-holds/order/"+dune.ID.String(), reason).Code)
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/admin/legal-holds/book/nope", reason).Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, "/admin/legal-holds/book/"+dune.ID.String(), `{"reason": " "}`).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/admin/legal-holds/book/"+uuid.NewString(), reason).Code)
+
+	testUtil.Equal(t, http.StatusCreated, serve(http.MethodPost, "/admin/legal-holds/book/"+dune.ID.String(), reason).Code)
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodPost, "/admin/legal-holds/book/"+dune.ID.String(), reason).Code)
+	testUtil.Equal(t, http.StatusCreated, serve(http.MethodPost, "/admin/legal-holds/user/"+alice.ID.String(), reason).Code)
+
+	testUtil.Equal(t, 2, len(list("")))
+	users := list("?kind=user")
+	testUtil.Equal(t, 1, len(users))
+	testUtil.Equal(t, alice.ID.String(), users[0].TargetID)
+	testUtil.Equal(t, "litigation", users[0].Reason)
+	testUtil.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/admin/legal-holds?kind=order", "").Code)
+
+	held, err := legalhold.NewRepository(db).Held(legalhold.KindBook, dune.ID, uuid.New())
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(held))
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, "/admin/legal-holds/book/"+dune.ID.String(), "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/legal-holds/book/"+dune.ID.String(), "").Code)
+	testUtil.Equal(t, 1, len(list("")))
+}
@@ -0,0 +1,69 @@
+package legalhold
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of records that can be held.
+const (
+	KindBook = "book"
+	KindUser = "user"
+)
+
+// tables maps kinds to the table of their records, to check that a held
+// record exists.
+var tables = map[string]string{
+	KindBook: "books",
+	KindUser: "users",
+}
+
+type DTO struct {
+	Kind     string    `json:"kind"`
+	TargetID string    `json:"target_id"`
+	Reason   string    `json:"reason"`
+	PlacedBy string    `json:"placed_by,omitempty"`
+	PlacedAt time.Time `json:"placed_at"`
+}
+
+type Form struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// Hold keeps a record from being changed, deleted or purged until it is
+// released.
+type Hold struct {
+	Kind     string    `gorm:"primarykey"`
+	TargetID uuid.UUID `gorm:"primarykey"`
+	Reason   string
+	// PlacedBy is the ID of the user or service account that placed the
+	// hold.
+	PlacedBy string
+	PlacedAt time.Time
+}
+
+type Holds []*Hold
+
+func (Hold) TableName() string {
+	return "legal_holds"
+}
+
+func (h *Hold) ToDto() *DTO {
+	return &DTO{
+		Kind:     h.Kind,
+		TargetID: h.TargetID.String(),
+		Reason:   h.Reason,
+		PlacedBy: h.PlacedBy,
+		PlacedAt: h.PlacedAt,
+	}
+}
+
+func (hs Holds) ToDto() []*DTO {
+	dtos := make([]*DTO, len(hs))
+	for i, v := range hs {
+		dtos[i] = v.ToDto()
+	}
+
+	return dtos
+}
@@ -0,0 +1,67 @@
+package legalhold
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// List returns the holds, of kind unless it is empty, most recent first.
+func (r *Repository) List(kind string) (Holds, error) {
+	holds := make([]*Hold, 0)
+	q := r.db.Order("placed_at DESC")
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	if err := q.Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+// Exists reports whether the record of kind and id exists, soft-deleted or
+// not.
+func (r *Repository) Exists(kind string, id uuid.UUID) (bool, error) {
+	var n int64
+	if err := r.db.Table(tables[kind]).Where("id = ?", id).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *Repository) Place(h *Hold) error {
+	return r.db.Create(h).Error
+}
+
+func (r *Repository) Release(kind string, id uuid.UUID) (int64, error) {
+	result := r.db.Where("kind = ? AND target_id = ?", kind, id).Delete(&Hold{})
+	return result.RowsAffected, result.Error
+}
+
+// Held returns those of ids whose record of kind is under hold.
+func (r *Repository) Held(kind string, ids ...uuid.UUID) ([]uuid.UUID, error) {
+	held := make([]uuid.UUID, 0)
+	if len(ids) == 0 {
+		return held, nil
+	}
+	err := r.db.Model(&Hold{}).Where("kind = ? AND target_id IN ?", kind, ids).Pluck("target_id", &held).Error
+	return held, err
+}
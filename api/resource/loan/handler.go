@@ -0,0 +1,216 @@
+package loan
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/config"
+	"hello/idcodec"
+	validatorUtil "hello/util/validator"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	conf       *config.ConfLoan
+	now        func() time.Time
+}
+
+func New(db *gorm.DB, v *validator.Validate, c *config.ConfLoan) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		conf:       c,
+		now:        time.Now,
+	}
+}
+
+// Checkout godoc
+//
+//	@summary        Check out book
+//	@description    Borrow a book for the given number of days, the configured loan period by default. A book is lent to one borrower at a time, and a returned book held for a reader is lent to that reader only
+//	@tags           loans
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          body    body    Form    false   "Loan form"
+//	@success        201 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/checkout [post]
+func (api *API) Checkout(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+	bookID, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	// The form is optional.
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil && !errors.Is(err, io.EOF) {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	days := form.Days
+	if days == 0 {
+		days = api.conf.Days
+	}
+
+	now := api.now()
+	l, err := api.repository.WithContext(r.Context()).Checkout(&Loan{
+		ID:           uuid.New(),
+		BookID:       bookID,
+		UserID:       userID,
+		CheckedOutAt: now,
+		DueAt:        now.AddDate(0, 0, days),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrOnLoan):
+			return e.RespBookOnLoan
+		case errors.Is(err, ErrHeld):
+			return e.RespBookHeld
+		default:
+			return e.RespDBDataInsertFailure
+		}
+	}
+
+	w.Header().Set("Location", "/v1/loans/"+idcodec.Encode(l.ID))
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(l.ToDto(now)); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Return godoc
+//
+//	@summary        Return book
+//	@description    Return a book the signed-in user borrowed, making it available again, or holding it for the first reader in line
+//	@tags           loans
+//	@produce        json
+//	@param          id      path    string  true    "Loan ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /loans/{id}/return [post]
+func (api *API) Return(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	now := api.now()
+	l, err := api.repository.WithContext(r.Context()).Return(id, userID, now, api.conf.HoldClaimPeriod)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return e.RespNotFound
+		case errors.Is(err, ErrReturned):
+			return e.RespLoanReturned
+		default:
+			return e.RespDBDataUpdateFailure
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(l.ToDto(now)); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Overdue godoc
+//
+//	@summary        List overdue loans
+//	@description    List the loans past their due date and not returned, the longest overdue first
+//	@tags           loans
+//	@produce        json
+//	@param          limit   query   int     false   "Maximum number of loans (default 50, at most 500)"
+//	@param          offset  query   int     false   "Number of loans to skip"
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /loans/overdue [get]
+func (api *API) Overdue(w http.ResponseWriter, r *http.Request) error {
+	limit, offset := defaultListLimit, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxListLimit)
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	now := api.now()
+	loans, err := api.repository.WithContext(r.Context()).Overdue(now, limit, offset)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(loans.ToDto(now)); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// book returns the id of the book in the URL, answering 404 when there is
+// no such book.
+func (api *API) book(r *http.Request) (uuid.UUID, error) {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, e.RespInvalidURLParamID
+	}
+
+	exists, err := api.repository.WithContext(r.Context()).BookExists(id)
+	if err != nil {
+		return uuid.Nil, e.RespDBDataAccessFailure
+	}
+	if !exists {
+		return uuid.Nil, e.RespNotFound
+	}
+	return id, nil
+}
+
+// caller returns the signed-in user, answering 401 without one. Books are
+// lent to users, so service accounts borrow none.
+func caller(r *http.Request) (uuid.UUID, error) {
+	id, ok := user.From(r.Context())
+	if !ok {
+		return uuid.Nil, e.RespAuthenticationRequired
+	}
+	return id, nil
+}
@@ -0,0 +1,107 @@
+package loan_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/loan"
+	"hello/config"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Loans(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &loan.Loan{}, &loan.Hold{})
+	testUtil.NoError(t, db.Exec("CREATE UNIQUE INDEX loans_book_id_active_idx ON loans (book_id) WHERE returned_at IS NULL").Error)
+
+	dune, emma := &book.Book{ID: uuid.New(), Title: "Dune"}, &book.Book{ID: uuid.New(), Title: "Emma"}
+	testUtil.NoError(t, db.Create([]*book.Book{dune, emma}).Error)
+
+	api := loan.New(db, validatorUtil.New(), &config.ConfLoan{Days: 14})
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Post("/books/{id}/checkout", e.Handle(api.Checkout))
+	r.Post("/loans/{id}/return", e.Handle(api.Return))
+	r.Get("/loans/overdue", e.Handle(api.Overdue))
+
+	alice, bob := uuid.NewString(), uuid.NewString()
+	checkout := "/books/" + dune.ID.String() + "/checkout"
+	serve := func(method, target, body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if userID != "" {
+			req.Header.Set(user.Header, userID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	status := func(b *book.Book) *loan.StatusDTO {
+		values, err := loan.Resolver(loan.NewRepository(db), func(b *book.Book) uuid.UUID { return b.ID })(context.Background(), []*book.Book{b})
+		testUtil.NoError(t, err)
+		return values[0].(*loan.StatusDTO)
+	}
+
+	testUtil.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, checkout, "", "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/books/"+uuid.NewString()+"/checkout", "", alice).Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, checkout, `{"days": 91}`, alice).Code)
+	testUtil.Equal(t, loan.StatusAvailable, status(dune).Status)
+
+	// Without a form the book is lent for the default period.
+	w := serve(http.MethodPost, checkout, "", alice)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	var lent loan.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &lent))
+	testUtil.Equal(t, alice, lent.Borrower)
+	testUtil.Equal(t, 14*24*time.Hour, lent.DueAt.Sub(lent.CheckedOutAt))
+	testUtil.Equal(t, false, lent.Overdue)
+	testUtil.Equal(t, loan.StatusOnLoan, status(dune).Status)
+	testUtil.Equal(t, true, status(dune).DueAt.Equal(lent.DueAt))
+
+	// A book is lent to one borrower at a time.
+	w = serve(http.MethodPost, checkout, `{"days": 7}`, bob)
+	testUtil.Equal(t, http.StatusConflict, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "book_on_loan"))
+
+	// The index holds when the check is raced.
+	_, err := loan.NewRepository(db).Checkout(&loan.Loan{ID: uuid.New(), BookID: dune.ID, UserID: uuid.New()})
+	testUtil.Equal(t, loan.ErrOnLoan, err)
+	err = db.Create(&loan.Loan{ID: uuid.New(), BookID: dune.ID, UserID: uuid.New()}).Error
+	testUtil.Equal(t, gorm.ErrDuplicatedKey, err)
+
+	returned := "/loans/" + lent.ID + "/return"
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, returned, "", bob).Code)
+	w = serve(http.MethodPost, returned, "", alice)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"returned_at"`))
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodPost, returned, "", alice).Code)
+	testUtil.Equal(t, loan.StatusAvailable, status(dune).Status)
+	testUtil.Equal(t, http.StatusCreated, serve(http.MethodPost, checkout, `{"days": 7}`, bob).Code)
+
+	// Loans past due and not returned are listed, the longest overdue first.
+	now := time.Now()
+	late := &loan.Loan{ID: uuid.New(), BookID: emma.ID, UserID: uuid.MustParse(alice), CheckedOutAt: now.AddDate(0, 0, -20), DueAt: now.AddDate(0, 0, -6)}
+	testUtil.NoError(t, db.Create(late).Error)
+
+	w = serve(http.MethodGet, "/loans/overdue", "", bob)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var overdue []*loan.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &overdue))
+	testUtil.Equal(t, 1, len(overdue))
+	testUtil.Equal(t, emma.ID.String(), overdue[0].BookID)
+	testUtil.Equal(t, true, overdue[0].Overdue)
+}
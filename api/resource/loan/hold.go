@@ -0,0 +1,162 @@
+package loan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/config"
+	"hello/idcodec"
+)
+
+// PlaceHold godoc
+//
+//	@summary        Place hold
+//	@description    Get in line for a book on loan. Once it is returned, the book is held for the first reader in line, who has the configured claim period to check it out before the next reader's turn
+//	@tags           loans
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@success        201 {object}    HoldDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/holds [post]
+func (api *API) PlaceHold(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+	bookID, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	now := api.now()
+	h, err := api.repository.WithContext(r.Context()).PlaceHold(&Hold{
+		ID:        uuid.New(),
+		BookID:    bookID,
+		UserID:    userID,
+		Status:    HoldWaiting,
+		PlacedAt:  now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrAvailable):
+			return e.RespBookAvailable
+		case errors.Is(err, ErrBorrower):
+			return e.RespBorrowedByYou
+		case errors.Is(err, ErrHoldExists):
+			return e.RespDuplicateHold
+		default:
+			return e.RespDBDataInsertFailure
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(h.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// CancelHold godoc
+//
+//	@summary        Cancel hold
+//	@description    Leave the line for a book. Cancelling a ready hold holds the book for the next reader in line
+//	@tags           loans
+//	@param          id      path    string  true    "Hold ID"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /holds/{id} [delete]
+func (api *API) CancelHold(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	if err := api.repository.WithContext(r.Context()).CancelHold(id, userID, api.now(), api.conf.HoldClaimPeriod); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataRemoveFailure
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// ListHolds godoc
+//
+//	@summary        List holds
+//	@description    List the signed-in user's holds with their place in line, the oldest first
+//	@tags           loans
+//	@produce        json
+//	@success        200 {array}     HoldDTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me/holds [get]
+func (api *API) ListHolds(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	holds, err := api.repository.WithContext(r.Context()).Holds(userID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(holds.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// JobExpireHolds is the job type of Expirer.Expire, run every
+// LOAN_HOLD_SWEEP_INTERVAL.
+const JobExpireHolds = "loan.expire_holds"
+
+// Expirer expires the holds of returned books not claimed in time.
+type Expirer struct {
+	repository *Repository
+	claim      time.Duration
+	now        func() time.Time
+}
+
+func NewExpirer(db *gorm.DB, c *config.ConfLoan) *Expirer {
+	return &Expirer{
+		repository: NewRepository(db),
+		claim:      c.HoldClaimPeriod,
+		now:        time.Now,
+	}
+}
+
+// Expire removes the holds not claimed in time, holding their books for
+// the next readers in line.
+func (x *Expirer) Expire(ctx context.Context) error {
+	n, err := x.repository.WithContext(ctx).ExpireHolds(x.now(), x.claim)
+	if n > 0 {
+		log.Printf("Expired %d unclaimed holds", n)
+	}
+	return err
+}
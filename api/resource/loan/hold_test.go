@@ -0,0 +1,132 @@
+package loan_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/loan"
+	"hello/config"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Holds(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &loan.Loan{}, &loan.Hold{})
+	testUtil.NoError(t, db.Exec("CREATE UNIQUE INDEX holds_book_id_user_id_idx ON holds (book_id, user_id)").Error)
+	testUtil.NoError(t, db.Exec("CREATE UNIQUE INDEX holds_book_id_ready_idx ON holds (book_id) WHERE status = 'ready'").Error)
+
+	dune, emma := &book.Book{ID: uuid.New(), Title: "Dune"}, &book.Book{ID: uuid.New(), Title: "Emma"}
+	testUtil.NoError(t, db.Create([]*book.Book{dune, emma}).Error)
+
+	claim := 72 * time.Hour
+	api := loan.New(db, validatorUtil.New(), &config.ConfLoan{Days: 14, HoldClaimPeriod: claim})
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Post("/books/{id}/checkout", e.Handle(api.Checkout))
+	r.Post("/loans/{id}/return", e.Handle(api.Return))
+	r.Post("/books/{id}/holds", e.Handle(api.PlaceHold))
+	r.Delete("/holds/{id}", e.Handle(api.CancelHold))
+	r.Get("/me/holds", e.Handle(api.ListHolds))
+
+	alice, bob, carol, dave := uuid.NewString(), uuid.NewString(), uuid.NewString(), uuid.NewString()
+	serve := func(method, target, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set(user.Header, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	hold := func(userID string) (*httptest.ResponseRecorder, *loan.HoldDTO) {
+		w := serve(http.MethodPost, "/books/"+dune.ID.String()+"/holds", userID)
+		h := &loan.HoldDTO{}
+		if w.Code == http.StatusCreated {
+			testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), h))
+		}
+		return w, h
+	}
+	holds := func(userID string) []*loan.HoldDTO {
+		w := serve(http.MethodGet, "/me/holds", userID)
+		testUtil.Equal(t, http.StatusOK, w.Code)
+		var dtos []*loan.HoldDTO
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dtos))
+		return dtos
+	}
+	status := func() string {
+		values, err := loan.Resolver(loan.NewRepository(db), func(b *book.Book) uuid.UUID { return b.ID })(context.Background(), []*book.Book{dune})
+		testUtil.NoError(t, err)
+		return values[0].(*loan.StatusDTO).Status
+	}
+
+	// Available books are checked out rather than held.
+	w := serve(http.MethodPost, "/books/"+emma.ID.String()+"/holds", bob)
+	testUtil.Equal(t, http.StatusConflict, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "book_available"))
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/books/"+uuid.NewString()+"/holds", bob).Code)
+
+	w = serve(http.MethodPost, "/books/"+dune.ID.String()+"/checkout", alice)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	var lent loan.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &lent))
+
+	w, _ = hold(alice)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "borrowed_by_you"))
+
+	// Readers get in line in order.
+	w, bobs := hold(bob)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	testUtil.Equal(t, loan.HoldWaiting, bobs.Status)
+	testUtil.Equal(t, 1, bobs.Position)
+	_, carols := hold(carol)
+	testUtil.Equal(t, 2, carols.Position)
+	w, _ = hold(bob)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "duplicate_hold"))
+
+	// A returned book is held for the first in line.
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPost, "/loans/"+lent.ID+"/return", alice).Code)
+	testUtil.Equal(t, loan.StatusOnHold, status())
+	mine := holds(bob)
+	testUtil.Equal(t, 1, len(mine))
+	testUtil.Equal(t, loan.HoldReady, mine[0].Status)
+	testUtil.Equal(t, claim, mine[0].ExpiresAt.Sub(*mine[0].ReadyAt))
+	testUtil.Equal(t, 1, holds(carol)[0].Position)
+	w = serve(http.MethodPost, "/books/"+dune.ID.String()+"/checkout", carol)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "book_held"))
+
+	// Unclaimed, it is held for the next in line.
+	repository := loan.NewRepository(db)
+	n, err := repository.ExpireHolds(time.Now(), claim)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 0, n)
+	n, err = repository.ExpireHolds(time.Now().Add(claim+time.Minute), claim)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, n)
+	testUtil.Equal(t, 0, len(holds(bob)))
+	testUtil.Equal(t, loan.HoldReady, holds(carol)[0].Status)
+
+	// A held book can still be joined in line; checking it out claims the
+	// hold.
+	w, daves := hold(dave)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	testUtil.Equal(t, 1, daves.Position)
+	testUtil.Equal(t, http.StatusCreated, serve(http.MethodPost, "/books/"+dune.ID.String()+"/checkout", carol).Code)
+	testUtil.Equal(t, 0, len(holds(carol)))
+	testUtil.Equal(t, loan.StatusOnLoan, status())
+
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/holds/"+daves.ID, carol).Code)
+	testUtil.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/holds/"+daves.ID, dave).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/holds/"+daves.ID, dave).Code)
+}
@@ -0,0 +1,140 @@
+package loan
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/idcodec"
+)
+
+// Loan statuses of a book, see StatusDTO.
+const (
+	StatusAvailable = "available"
+	StatusOnLoan    = "on_loan"
+	// StatusOnHold is that of a returned book held for the first reader in
+	// line until they claim it or their hold expires.
+	StatusOnHold = "on_hold"
+)
+
+// Hold statuses. A hold waits in line while its book is on loan, and is
+// ready for its reader to check the book out once it is returned.
+const (
+	HoldWaiting = "waiting"
+	HoldReady   = "ready"
+)
+
+type DTO struct {
+	ID           string     `json:"id"`
+	BookID       string     `json:"book_id"`
+	Borrower     string     `json:"borrower"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	DueAt        time.Time  `json:"due_at"`
+	ReturnedAt   *time.Time `json:"returned_at,omitempty"`
+	Overdue      bool       `json:"overdue"`
+}
+
+// StatusDTO tells whether a book can be checked out, and when a book on
+// loan is due back. It is the computed field "loan" of book DTOs.
+type StatusDTO struct {
+	Status string     `json:"status"`
+	DueAt  *time.Time `json:"due_at,omitempty"`
+}
+
+// HoldDTO is a hold of the signed-in user. Position is its place in line,
+// 1 for the next reader; it is left out of ready holds, which expire
+// unless the book is checked out by ExpiresAt.
+type HoldDTO struct {
+	ID        string     `json:"id"`
+	BookID    string     `json:"book_id"`
+	Status    string     `json:"status"`
+	Position  int        `json:"position,omitempty"`
+	PlacedAt  time.Time  `json:"placed_at"`
+	ReadyAt   *time.Time `json:"ready_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Form sets the length of a loan in days, the configured default when
+// left out.
+type Form struct {
+	Days int `json:"days" validate:"omitempty,min=1,max=90"`
+}
+
+// Loan is a book checked out by a user until it is returned. A book has at
+// most one loan not yet returned.
+type Loan struct {
+	ID           uuid.UUID `gorm:"primarykey"`
+	BookID       uuid.UUID
+	UserID       uuid.UUID
+	CheckedOutAt time.Time
+	DueAt        time.Time
+	ReturnedAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type Loans []*Loan
+
+// Hold is a reader's place in line for a book on loan. Holds are served
+// in the order they were placed, and removed once their reader checks the
+// book out, cancels them or lets them expire.
+type Hold struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	BookID    uuid.UUID
+	UserID    uuid.UUID
+	Status    string
+	PlacedAt  time.Time
+	ReadyAt   *time.Time
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Position is set by Repository.Holds for waiting holds.
+	Position int `gorm:"-"`
+}
+
+type Holds []*Hold
+
+// Overdue reports whether l is past due and not returned at now.
+func (l *Loan) Overdue(now time.Time) bool {
+	return l.ReturnedAt == nil && now.After(l.DueAt)
+}
+
+func (l *Loan) ToDto(now time.Time) *DTO {
+	return &DTO{
+		ID:           idcodec.Encode(l.ID),
+		BookID:       idcodec.Encode(l.BookID),
+		Borrower:     l.UserID.String(),
+		CheckedOutAt: l.CheckedOutAt,
+		DueAt:        l.DueAt,
+		ReturnedAt:   l.ReturnedAt,
+		Overdue:      l.Overdue(now),
+	}
+}
+
+func (ls Loans) ToDto(now time.Time) []*DTO {
+	dtos := make([]*DTO, len(ls))
+	for i, v := range ls {
+		dtos[i] = v.ToDto(now)
+	}
+	return dtos
+}
+
+func (h *Hold) ToDto() *HoldDTO {
+	return &HoldDTO{
+		ID:        idcodec.Encode(h.ID),
+		BookID:    idcodec.Encode(h.BookID),
+		Status:    h.Status,
+		Position:  h.Position,
+		PlacedAt:  h.PlacedAt,
+		ReadyAt:   h.ReadyAt,
+		ExpiresAt: h.ExpiresAt,
+	}
+}
+
+func (hs Holds) ToDto() []*HoldDTO {
+	dtos := make([]*HoldDTO, len(hs))
+	for i, v := range hs {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
@@ -0,0 +1,305 @@
+package loan
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrOnLoan is returned when checking out a book already on loan.
+	ErrOnLoan = errors.New("loan: book already on loan")
+	// ErrReturned is returned when returning a loan already returned.
+	ErrReturned = errors.New("loan: already returned")
+	// ErrHeld is returned when checking out a book held for another reader.
+	ErrHeld = errors.New("loan: book held for another reader")
+	// ErrAvailable is returned when placing a hold on a book that can be
+	// checked out.
+	ErrAvailable = errors.New("loan: book available")
+	// ErrBorrower is returned when placing a hold on a book the reader has
+	// on loan.
+	ErrBorrower = errors.New("loan: book on loan to the reader")
+	// ErrHoldExists is returned when placing a hold on a book the reader
+	// holds already.
+	ErrHoldExists = errors.New("loan: hold already placed")
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// BookExists reports whether a book exists and is not deleted.
+func (r *Repository) BookExists(id uuid.UUID) (bool, error) {
+	var n int64
+	if err := r.db.Table("books").Where("id = ? AND deleted_at IS NULL", id).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Checkout creates a loan unless its book is on loan already, returning
+// ErrOnLoan then, or held for another reader, returning ErrHeld. The check
+// answers most attempts; the unique index on the active loan of a book
+// answers those racing it. The borrower's hold on the book, if any, is
+// removed.
+func (r *Repository) Checkout(l *Loan) (*Loan, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&Loan{}).Where("book_id = ? AND returned_at IS NULL", l.BookID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrOnLoan
+		}
+
+		ready := &Hold{}
+		res := tx.Where("book_id = ? AND status = ?", l.BookID, HoldReady).Limit(1).Find(ready)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 && ready.UserID != l.UserID {
+			return ErrHeld
+		}
+
+		if err := tx.Create(l).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.Is(err, gorm.ErrDuplicatedKey) || errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrOnLoan
+			}
+			return err
+		}
+		return tx.Where("book_id = ? AND user_id = ?", l.BookID, l.UserID).Delete(&Hold{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Return marks a user's loan returned at at, and holds the book for the
+// first reader in line, if any, for claim. It returns
+// gorm.ErrRecordNotFound when the user has no such loan, and ErrReturned
+// when it was returned already.
+func (r *Repository) Return(id, userID uuid.UUID, at time.Time, claim time.Duration) (*Loan, error) {
+	l := &Loan{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(l).Error; err != nil {
+			return err
+		}
+		if l.ReturnedAt != nil {
+			return ErrReturned
+		}
+
+		result := tx.Model(&Loan{}).Where("id = ? AND returned_at IS NULL", id).
+			Updates(map[string]any{"returned_at": at, "updated_at": at})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrReturned
+		}
+		l.ReturnedAt, l.UpdatedAt = &at, at
+		return promote(tx, l.BookID, at, claim)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Overdue returns the loans past due at now and not returned, the longest
+// overdue first.
+func (r *Repository) Overdue(now time.Time, limit, offset int) (Loans, error) {
+	loans := make([]*Loan, 0)
+	if err := r.db.Where("returned_at IS NULL AND due_at < ?", now).
+		Order("due_at, id").Limit(limit).Offset(offset).Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+// Active returns the loans not returned of the given books, by book.
+func (r *Repository) Active(bookIDs []uuid.UUID) (map[uuid.UUID]*Loan, error) {
+	var loans []*Loan
+	if err := r.db.Where("book_id IN ? AND returned_at IS NULL", bookIDs).Find(&loans).Error; err != nil {
+		return nil, err
+	}
+
+	active := make(map[uuid.UUID]*Loan, len(loans))
+	for _, l := range loans {
+		active[l.BookID] = l
+	}
+	return active, nil
+}
+
+// PlaceHold puts a reader in line for a book on loan, or held for another
+// reader. It returns ErrAvailable when the book can be checked out
+// instead, ErrBorrower when the reader has it on loan, and ErrHoldExists
+// when they hold it already.
+func (r *Repository) PlaceHold(h *Hold) (*Hold, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		active := &Loan{}
+		res := tx.Where("book_id = ? AND returned_at IS NULL", h.BookID).Limit(1).Find(active)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 && active.UserID == h.UserID {
+			return ErrBorrower
+		}
+		if res.RowsAffected == 0 {
+			var ready int64
+			if err := tx.Model(&Hold{}).Where("book_id = ? AND status = ?", h.BookID, HoldReady).Count(&ready).Error; err != nil {
+				return err
+			}
+			if ready == 0 {
+				return ErrAvailable
+			}
+		}
+
+		if err := tx.Create(h).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.Is(err, gorm.ErrDuplicatedKey) || errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrHoldExists
+			}
+			return err
+		}
+		return position(tx, h)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// CancelHold removes a user's hold, holding the book for the next reader
+// in line for claim when it was ready. It returns gorm.ErrRecordNotFound
+// when the user has no such hold.
+func (r *Repository) CancelHold(id, userID uuid.UUID, at time.Time, claim time.Duration) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		h := &Hold{}
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(h).Error; err != nil {
+			return err
+		}
+		return release(tx, h, at, claim)
+	})
+}
+
+// Holds returns the holds of a user, the oldest first.
+func (r *Repository) Holds(userID uuid.UUID) (Holds, error) {
+	holds := make([]*Hold, 0)
+	if err := r.db.Where("user_id = ?", userID).Order("placed_at, id").Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range holds {
+		if err := position(r.db, h); err != nil {
+			return nil, err
+		}
+	}
+	return holds, nil
+}
+
+// Ready returns the ready holds of the given books, by book.
+func (r *Repository) Ready(bookIDs []uuid.UUID) (map[uuid.UUID]*Hold, error) {
+	var holds []*Hold
+	if err := r.db.Where("book_id IN ? AND status = ?", bookIDs, HoldReady).Find(&holds).Error; err != nil {
+		return nil, err
+	}
+
+	ready := make(map[uuid.UUID]*Hold, len(holds))
+	for _, h := range holds {
+		ready[h.BookID] = h
+	}
+	return ready, nil
+}
+
+// ExpireHolds removes the ready holds not claimed by now, holding each of
+// their books for the next reader in line for claim, and returns how many
+// it removed.
+func (r *Repository) ExpireHolds(now time.Time, claim time.Duration) (int, error) {
+	var expired []*Hold
+	if err := r.db.Where("status = ? AND expires_at < ?", HoldReady, now).Order("expires_at").Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, h := range expired {
+		if err := r.db.Transaction(func(tx *gorm.DB) error {
+			return release(tx, h, now, claim)
+		}); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Claimed or cancelled since it was read.
+				continue
+			}
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// release removes a hold, and holds its book for the next reader in line
+// when it was ready. It returns gorm.ErrRecordNotFound when the hold was
+// removed already.
+func release(tx *gorm.DB, h *Hold, at time.Time, claim time.Duration) error {
+	res := tx.Where("id = ? AND status = ?", h.ID, h.Status).Delete(&Hold{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if h.Status != HoldReady {
+		return nil
+	}
+	return promote(tx, h.BookID, at, claim)
+}
+
+// promote readies the first waiting hold of a book, if any, for its reader
+// to claim within claim of at.
+func promote(tx *gorm.DB, bookID uuid.UUID, at time.Time, claim time.Duration) error {
+	next := &Hold{}
+	res := tx.Where("book_id = ? AND status = ?", bookID, HoldWaiting).Order("placed_at, id").Limit(1).Find(next)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+
+	return tx.Model(&Hold{}).Where("id = ?", next.ID).Updates(map[string]any{
+		"status":     HoldReady,
+		"ready_at":   at,
+		"expires_at": at.Add(claim),
+		"updated_at": at,
+	}).Error
+}
+
+// position sets the place in line of a waiting hold.
+func position(db *gorm.DB, h *Hold) error {
+	if h.Status != HoldWaiting {
+		return nil
+	}
+
+	var ahead int64
+	if err := db.Model(&Hold{}).
+		Where("book_id = ? AND status = ? AND (placed_at < ? OR placed_at = ? AND id < ?)", h.BookID, HoldWaiting, h.PlacedAt, h.PlacedAt, h.ID).
+		Count(&ahead).Error; err != nil {
+		return err
+	}
+	h.Position = int(ahead) + 1
+	return nil
+}
@@ -0,0 +1,41 @@
+package loan
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"hello/util/computed"
+)
+
+// Resolver returns a computed field holding the loan status of each item's
+// book.
+func Resolver[T any](r *Repository, bookID func(T) uuid.UUID) computed.Resolver[T] {
+	return func(ctx context.Context, items []T) ([]any, error) {
+		ids := make([]uuid.UUID, len(items))
+		for i, item := range items {
+			ids[i] = bookID(item)
+		}
+
+		active, err := r.WithContext(ctx).Active(ids)
+		if err != nil {
+			return nil, err
+		}
+		ready, err := r.WithContext(ctx).Ready(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]any, len(items))
+		for i, id := range ids {
+			status := &StatusDTO{Status: StatusAvailable}
+			if l, ok := active[id]; ok {
+				status = &StatusDTO{Status: StatusOnLoan, DueAt: &l.DueAt}
+			} else if _, ok := ready[id]; ok {
+				status = &StatusDTO{Status: StatusOnHold}
+			}
+			values[i] = status
+		}
+		return values, nil
+	}
+}
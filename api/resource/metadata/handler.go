@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	e "hello/api/resource/common/err"
+	meta "hello/metadata"
+)
+
+type API struct {
+	proxy *meta.Proxy
+}
+
+func New(proxy *meta.Proxy) *API {
+	return &API{
+		proxy: proxy,
+	}
+}
+
+// Lookup godoc
+//
+//	@summary        Look up book metadata
+//	@description    Book metadata by ISBN from the external provider, cached and rate limited
+//	@tags           books
+//	@produce        json
+//	@param          isbn    path        string  true    "ISBN-10 or ISBN-13"
+//	@success        200 {object}    meta.Record
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        502 {object}    err.Problem
+//	@failure        503 {object}    err.Problem
+//	@router         /metadata/isbn/{isbn} [get]
+func (api *API) Lookup(w http.ResponseWriter, r *http.Request) error {
+	rec, err := api.proxy.Lookup(r.Context(), chi.URLParam(r, "isbn"))
+	switch {
+	case errors.Is(err, meta.ErrInvalidISBN):
+		return e.RespInvalidISBN
+	case errors.Is(err, meta.ErrNotFound):
+		return e.RespNotFound
+	case errors.Is(err, meta.ErrRateLimited):
+		retryAfter := int(math.Ceil(api.proxy.RetryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, retryAfter)))
+		return e.RespMetadataRateLimited
+	case err != nil:
+		return e.RespMetadataFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(rec); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package order
+
+import (
+	"context"
+
+	"hello/util/computed"
+)
+
+// Computed holds the derived fields added to order DTOs. Other packages may
+// register fields while the router is being wired.
+var Computed = computed.NewRegistry[*Order]()
+
+// toDtos converts orders to DTOs with their computed fields resolved in one
+// batch.
+func toDtos(ctx context.Context, os Orders) ([]*DTO, error) {
+	fields, err := Computed.Resolve(ctx, os)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := os.ToDto()
+	for i, f := range fields {
+		dtos[i].Computed = f
+	}
+	return dtos, nil
+}
@@ -0,0 +1,429 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/config"
+	"hello/idcodec"
+	validatorUtil "hello/util/validator"
+)
+
+type API struct {
+	repository     *Repository
+	validator      *validator.Validate
+	reservationTTL time.Duration
+	now            func() time.Time
+}
+
+func New(db *gorm.DB, v *validator.Validate, c *config.ConfStore) *API {
+	return &API{
+		repository:     NewRepository(db),
+		validator:      v,
+		reservationTTL: c.ReservationTTL,
+		now:            time.Now,
+	}
+}
+
+// Cart godoc
+//
+//	@summary        Read cart
+//	@description    Read the signed-in user's cart with the books' current prices
+//	@tags           orders
+//	@produce        json
+//	@success        200 {object}    CartDTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /cart [get]
+func (api *API) Cart(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	lines, err := api.repository.WithContext(r.Context()).Cart(userID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(lines.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// PutCartItem godoc
+//
+//	@summary        Put cart item
+//	@description    Add a book to the cart or change its quantity
+//	@tags           orders
+//	@accept         json
+//	@param          id      path    string          true    "Book ID"
+//	@param          body    body    CartItemForm    true    "Cart item form"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /cart/items/{id} [put]
+func (api *API) PutCartItem(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	form := &CartItemForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	exists, err := api.repository.WithContext(r.Context()).BookExists(bookID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if !exists {
+		return e.RespNotFound
+	}
+
+	now := api.now()
+	item := &CartItem{UserID: userID, BookID: bookID, Quantity: form.Quantity, CreatedAt: now, UpdatedAt: now}
+	if err := api.repository.WithContext(r.Context()).PutCartItem(item); err != nil {
+		return e.RespDBDataUpdateFailure
+	}
+	return nil
+}
+
+// DeleteCartItem godoc
+//
+//	@summary        Delete cart item
+//	@description    Remove a book from the cart
+//	@tags           orders
+//	@param          id  path    string  true    "Book ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /cart/items/{id} [delete]
+func (api *API) DeleteCartItem(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).DeleteCartItem(userID, bookID)
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+	return nil
+}
+
+// Create godoc
+//
+//	@summary        Place order
+//	@description    Order the contents of the cart, reserving the copies and emptying the cart; unpaid orders are cancelled once their reservation expires
+//	@tags           orders
+//	@produce        json
+//	@success        201 {object}    DTO
+//	@failure        401 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /orders [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	o, err := api.repository.WithContext(r.Context()).Checkout(userID, api.now(), api.reservationTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrEmptyCart):
+			return e.RespEmptyCart
+		case errors.Is(err, ErrUnpriced):
+			return e.RespUnpricedBook
+		case errors.Is(err, ErrMixedCurrencies):
+			return e.RespMixedCurrencies
+		case errors.Is(err, ErrOutOfStock):
+			return e.RespOutOfStock
+		default:
+			return e.RespDBDataInsertFailure
+		}
+	}
+
+	dtos, err := toDtos(r.Context(), Orders{o})
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	w.Header().Set("Location", "/v1/orders/"+idcodec.Encode(o.ID))
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(dtos[0]); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// List godoc
+//
+//	@summary        List orders
+//	@description    List the signed-in user's orders, newest first
+//	@tags           orders
+//	@produce        json
+//	@success        200 {array}     DTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /orders [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	orders, err := api.repository.WithContext(r.Context()).List(userID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	dtos, err := toDtos(r.Context(), orders)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Read godoc
+//
+//	@summary        Read order
+//	@description    Read one of the signed-in user's orders
+//	@tags           orders
+//	@produce        json
+//	@param          id  path    string  true    "Order ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /orders/{id} [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	o, err := api.own(r)
+	if err != nil {
+		return err
+	}
+
+	dtos, err := toDtos(r.Context(), Orders{o})
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(dtos[0]); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Transition godoc
+//
+//	@summary        Transition order
+//	@description    Fire an event on one of the signed-in user's orders; customers may only cancel pending orders
+//	@tags           orders
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string          true    "Order ID"
+//	@param          body    body    TransitionForm  true    "Transition form"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /orders/{id}/transitions [post]
+func (api *API) Transition(w http.ResponseWriter, r *http.Request) error {
+	o, err := api.own(r)
+	if err != nil {
+		return err
+	}
+	return api.transition(w, r, o, false)
+}
+
+// AdminTransition godoc
+//
+//	@summary        Transition any order
+//	@description    Fire an event on an order as staff, e.g. to mark it paid or shipped
+//	@tags           orders
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string          true    "Order ID"
+//	@param          body    body    TransitionForm  true    "Transition form"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/orders/{id}/transitions [post]
+func (api *API) AdminTransition(w http.ResponseWriter, r *http.Request) error {
+	o, err := api.order(r)
+	if err != nil {
+		return err
+	}
+	return api.transition(w, r, o, true)
+}
+
+func (api *API) transition(w http.ResponseWriter, r *http.Request, o *Order, staff bool) error {
+	form := &TransitionForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	t, err := Next(o.Status, form.Event, staff)
+	if err != nil {
+		return e.RespInvalidTransition
+	}
+
+	if err := api.repository.WithContext(r.Context()).Transition(o, t, api.now()); err != nil {
+		if errors.Is(err, ErrStatusChanged) {
+			return e.RespOrderStatusChanged
+		}
+
+		return e.RespDBDataUpdateFailure
+	}
+
+	dtos, err := toDtos(r.Context(), Orders{o})
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(dtos[0]); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// PutStock godoc
+//
+//	@summary        Set stock
+//	@description    Set the number of copies of a book available to order
+//	@tags           orders
+//	@accept         json
+//	@param          id      path    string      true    "Book ID"
+//	@param          body    body    StockForm   true    "Stock form"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/stock/{id} [put]
+func (api *API) PutStock(w http.ResponseWriter, r *http.Request) error {
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	form := &StockForm{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	exists, err := api.repository.WithContext(r.Context()).BookExists(bookID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if !exists {
+		return e.RespNotFound
+	}
+
+	if err := api.repository.WithContext(r.Context()).SetStock(bookID, *form.Available); err != nil {
+		return e.RespDBDataUpdateFailure
+	}
+	return nil
+}
+
+// own reads the order in the URL, answering 404 unless it belongs to the
+// caller.
+func (api *API) own(r *http.Request) (*Order, error) {
+	userID, err := caller(r)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := api.order(r)
+	if err != nil {
+		return nil, err
+	}
+	if o.UserID != userID {
+		return nil, e.RespNotFound
+	}
+	return o, nil
+}
+
+func (api *API) order(r *http.Request) (*Order, error) {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, e.RespInvalidURLParamID
+	}
+
+	o, err := api.repository.WithContext(r.Context()).Read(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.RespNotFound
+		}
+
+		return nil, e.RespDBDataAccessFailure
+	}
+	return o, nil
+}
+
+// caller returns the signed-in user, answering 401 without one. Carts and
+// orders belong to users, so service accounts have none.
+func caller(r *http.Request) (uuid.UUID, error) {
+	id, ok := user.From(r.Context())
+	if !ok {
+		return uuid.Nil, e.RespAuthenticationRequired
+	}
+	return id, nil
+}
+
+func (api *API) validate(r *http.Request, form any) error {
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+	return nil
+}
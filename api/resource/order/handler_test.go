@@ -0,0 +1,242 @@
+package order_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/order"
+	"hello/config"
+	mockDB "hello/mock/db"
+	"hello/money"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func newRouter(t *testing.T) (http.Handler, *gorm.DB) {
+	t.Helper()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &order.Stock{}, &order.CartItem{}, &order.Order{}, &order.Item{}, &order.Copy{})
+
+	api := order.New(db, validatorUtil.New(), &config.ConfStore{ReservationTTL: 30 * time.Minute})
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Get("/cart", e.Handle(api.Cart))
+	r.Put("/cart/items/{id}", e.Handle(api.PutCartItem))
+	r.Delete("/cart/items/{id}", e.Handle(api.DeleteCartItem))
+	r.Get("/orders", e.Handle(api.List))
+	r.Post("/orders", e.Handle(api.Create))
+	r.Get("/orders/{id}", e.Handle(api.Read))
+	r.Post("/orders/{id}/transitions", e.Handle(api.Transition))
+	r.Post("/admin/orders/{id}/transitions", e.Handle(api.AdminTransition))
+	r.Put("/admin/stock/{id}", e.Handle(api.PutStock))
+	return r, db
+}
+
+func TestAPI_Checkout(t *testing.T) {
+	t.Parallel()
+
+	r, db := newRouter(t)
+	dune := &book.Book{ID: uuid.New(), Title: "Dune", Price: money.Money{Amount: 1250, Currency: "EUR"}}
+	emma := &book.Book{ID: uuid.New(), Title: "Emma", Price: money.Money{Amount: 800, Currency: "EUR"}}
+	free := &book.Book{ID: uuid.New(), Title: "Free"}
+	testUtil.NoError(t, db.Create([]*book.Book{dune, emma, free}).Error)
+
+	alice, bob := uuid.NewString(), uuid.NewString()
+	serve := func(method, target, body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(user.Header, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	stock := func(id uuid.UUID) int {
+		s := &order.Stock{}
+		testUtil.NoError(t, db.Where("book_id = ?", id).First(s).Error)
+		return s.Available
+	}
+
+	testUtil.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/cart", "", "").Code)
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodPost, "/orders", "", alice).Code)
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/admin/stock/"+dune.ID.String(), `{"available": 3}`, "").Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/admin/stock/"+emma.ID.String(), `{"available": 1}`, "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPut, "/admin/stock/"+uuid.NewString(), `{"available": 1}`, "").Code)
+
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPut, "/cart/items/"+dune.ID.String(), `{"quantity": 0}`, alice).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPut, "/cart/items/"+uuid.NewString(), `{"quantity": 1}`, alice).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/cart/items/"+dune.ID.String(), `{"quantity": 2}`, alice).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/cart/items/"+free.ID.String(), `{"quantity": 1}`, alice).Code)
+
+	w := serve(http.MethodGet, "/cart", "", alice)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, false, strings.Contains(w.Body.String(), `"total"`))
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodPost, "/orders", "", alice).Code)
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodDelete, "/cart/items/"+free.ID.String(), "", alice).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/cart/items/"+free.ID.String(), "", alice).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/cart/items/"+emma.ID.String(), `{"quantity": 2}`, alice).Code)
+
+	var cart order.CartDTO
+	w = serve(http.MethodGet, "/cart", "", alice)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+	testUtil.Equal(t, 2, len(cart.Items))
+	testUtil.Equal(t, money.Money{Amount: 4100, Currency: "EUR"}, *cart.Total)
+
+	// Only one copy of Emma is left, so nothing is reserved.
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodPost, "/orders", "", alice).Code)
+	testUtil.Equal(t, 3, stock(dune.ID))
+
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/cart/items/"+emma.ID.String(), `{"quantity": 1}`, alice).Code)
+	w = serve(http.MethodPost, "/orders", "", alice)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	var placed order.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &placed))
+	testUtil.Equal(t, order.StatusPending, placed.Status)
+	testUtil.Equal(t, money.Money{Amount: 3300, Currency: "EUR"}, placed.Total)
+	testUtil.Equal(t, "/v1/orders/"+placed.ID, w.Header().Get("Location"))
+	testUtil.Equal(t, 1, stock(dune.ID))
+	testUtil.Equal(t, 0, stock(emma.ID))
+
+	w = serve(http.MethodGet, "/cart", "", alice)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+	testUtil.Equal(t, 0, len(cart.Items))
+
+	var history []*order.DTO
+	w = serve(http.MethodGet, "/orders", "", alice)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	testUtil.Equal(t, 1, len(history))
+	testUtil.Equal(t, 2, len(history[0].Items))
+	testUtil.Equal(t, "Dune", history[0].Items[0].Title)
+
+	w = serve(http.MethodGet, "/orders", "", bob)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	testUtil.Equal(t, 0, len(history))
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/orders/"+placed.ID, "", bob).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodGet, "/orders/"+placed.ID, "", alice).Code)
+}
+
+func TestAPI_Transitions(t *testing.T) {
+	t.Parallel()
+
+	r, db := newRouter(t)
+	b := &book.Book{ID: uuid.New(), Title: "Dune", Price: money.Money{Amount: 1250, Currency: "EUR"}}
+	testUtil.NoError(t, db.Create(b).Error)
+	testUtil.NoError(t, db.Create(&order.Stock{BookID: b.ID, Available: 5}).Error)
+
+	alice := uuid.NewString()
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(user.Header, alice)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	place := func() string {
+		testUtil.Equal(t, http.StatusOK, serve(http.MethodPut, "/cart/items/"+b.ID.String(), `{"quantity": 2}`).Code)
+		w := serve(http.MethodPost, "/orders", "")
+		testUtil.Equal(t, http.StatusCreated, w.Code)
+		var dto order.DTO
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+		return dto.ID
+	}
+	fire := func(prefix, id, event string) int {
+		return serve(http.MethodPost, prefix+"/orders/"+id+"/transitions", `{"event": "`+event+`"}`).Code
+	}
+	stock := func() int {
+		s := &order.Stock{}
+		testUtil.NoError(t, db.Where("book_id = ?", b.ID).First(s).Error)
+		return s.Available
+	}
+
+	shipped := place()
+	testUtil.Equal(t, 3, stock())
+	testUtil.Equal(t, http.StatusConflict, fire("", shipped, order.EventPay))
+	testUtil.Equal(t, http.StatusUnprocessableEntity, fire("/admin", shipped, "refund"))
+	testUtil.Equal(t, http.StatusConflict, fire("/admin", shipped, order.EventShip))
+	testUtil.Equal(t, http.StatusOK, fire("/admin", shipped, order.EventPay))
+	testUtil.Equal(t, http.StatusConflict, fire("", shipped, order.EventCancel))
+	testUtil.Equal(t, http.StatusOK, fire("/admin", shipped, order.EventShip))
+	testUtil.Equal(t, http.StatusConflict, fire("/admin", shipped, order.EventCancel))
+	testUtil.Equal(t, 3, stock())
+
+	var copies int64
+	testUtil.NoError(t, db.Model(&order.Copy{}).Count(&copies).Error)
+	testUtil.Equal(t, int64(0), copies)
+	testUtil.Equal(t, http.StatusOK, fire("/admin", shipped, order.EventDeliver))
+	testUtil.NoError(t, db.Model(&order.Copy{}).Where("book_id = ?", b.ID).Count(&copies).Error)
+	testUtil.Equal(t, int64(2), copies)
+
+	cancelled := place()
+	testUtil.Equal(t, 1, stock())
+	testUtil.Equal(t, http.StatusOK, fire("", cancelled, order.EventCancel))
+	testUtil.Equal(t, 3, stock())
+	testUtil.Equal(t, http.StatusConflict, fire("", cancelled, order.EventCancel))
+	testUtil.Equal(t, 3, stock())
+}
+
+func TestRepository_ReleaseExpired(t *testing.T) {
+	t.Parallel()
+
+	_, db := newRouter(t)
+	b := &book.Book{ID: uuid.New(), Title: "Dune", Price: money.Money{Amount: 1250, Currency: "EUR"}}
+	unstocked := &book.Book{ID: uuid.New(), Title: "Emma"}
+	testUtil.NoError(t, db.Create([]*book.Book{b, unstocked}).Error)
+	testUtil.NoError(t, db.Create(&order.Stock{BookID: b.ID, Available: 5}).Error)
+
+	repo := order.NewRepository(db)
+	now := time.Now()
+	place := func(userID uuid.UUID, ttl time.Duration) *order.Order {
+		testUtil.NoError(t, repo.PutCartItem(&order.CartItem{UserID: userID, BookID: b.ID, Quantity: 1}))
+		o, err := repo.Checkout(userID, now, ttl)
+		testUtil.NoError(t, err)
+		return o
+	}
+	status := func(o *order.Order) string {
+		read, err := repo.Read(o.ID)
+		testUtil.NoError(t, err)
+		return read.Status
+	}
+	availability := func() *order.AvailabilityDTO {
+		values, err := order.AvailabilityResolver(repo, func(b *book.Book) uuid.UUID { return b.ID })(context.Background(), []*book.Book{b, unstocked})
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, nil, values[1])
+		return values[0].(*order.AvailabilityDTO)
+	}
+
+	short := place(uuid.New(), time.Minute)
+	long := place(uuid.New(), time.Hour)
+	held := place(uuid.New(), 0)
+	paid := place(uuid.New(), time.Minute)
+	pay, err := order.Next(paid.Status, order.EventPay, true)
+	testUtil.NoError(t, err)
+	testUtil.NoError(t, repo.Transition(paid, pay, now))
+	testUtil.Equal(t, (*time.Time)(nil), paid.ReservedUntil)
+	testUtil.Equal(t, order.AvailabilityDTO{Available: 1, Reserved: 3}, *availability())
+
+	released, err := repo.ReleaseExpired(now.Add(2*time.Minute), 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, released)
+	testUtil.Equal(t, order.StatusCancelled, status(short))
+	testUtil.Equal(t, order.StatusPending, status(long))
+	testUtil.Equal(t, order.StatusPaid, status(paid))
+	testUtil.Equal(t, order.AvailabilityDTO{Available: 2, Reserved: 2}, *availability())
+
+	released, err = repo.ReleaseExpired(now.Add(48*time.Hour), 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, released)
+	testUtil.Equal(t, order.StatusCancelled, status(long))
+	testUtil.Equal(t, order.StatusPending, status(held))
+	testUtil.Equal(t, order.AvailabilityDTO{Available: 3, Reserved: 1}, *availability())
+}
@@ -0,0 +1,197 @@
+package order
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/idcodec"
+	"hello/money"
+)
+
+type CartItemDTO struct {
+	BookID    string       `json:"book_id"`
+	Title     string       `json:"title"`
+	Quantity  int          `json:"quantity"`
+	UnitPrice *money.Money `json:"unit_price,omitempty"`
+}
+
+// CartDTO is a user's cart. Total is left out while the cart holds unpriced
+// books or books priced in different currencies, which cannot be ordered.
+type CartDTO struct {
+	Items []*CartItemDTO `json:"items"`
+	Total *money.Money   `json:"total,omitempty"`
+}
+
+type ItemDTO struct {
+	BookID    string      `json:"book_id"`
+	Title     string      `json:"title"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unit_price"`
+}
+
+type DTO struct {
+	ID     string      `json:"id"`
+	Status string      `json:"status"`
+	Total  money.Money `json:"total"`
+	Items  []*ItemDTO  `json:"items"`
+	// ReservedUntil is when a pending order is cancelled unless paid.
+	ReservedUntil *time.Time `json:"reserved_until,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Computed map[string]any `json:"computed,omitempty"`
+}
+
+type CartItemForm struct {
+	Quantity int `json:"quantity" validate:"required,min=1,max=99"`
+}
+
+type TransitionForm struct {
+	Event string `json:"event" validate:"required,oneof=pay ship deliver cancel"`
+}
+
+// AvailabilityDTO is the stock of a book: the copies that can be ordered and
+// those held by pending orders.
+type AvailabilityDTO struct {
+	Available int `json:"available"`
+	Reserved  int `json:"reserved"`
+}
+
+// StockForm sets the number of copies of a book available to order.
+type StockForm struct {
+	Available *int `json:"available" validate:"required,min=0"`
+}
+
+// Stock is the number of copies of a book that are not reserved by an order.
+type Stock struct {
+	BookID    uuid.UUID `gorm:"primarykey"`
+	Available int
+	UpdatedAt time.Time
+}
+
+func (Stock) TableName() string {
+	return "stock_levels"
+}
+
+type CartItem struct {
+	UserID    uuid.UUID `gorm:"primarykey"`
+	BookID    uuid.UUID `gorm:"primarykey"`
+	Quantity  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Line is a cart item together with the book's current title and price.
+type Line struct {
+	BookID   uuid.UUID
+	Quantity int
+	Title    string
+	Price    money.Money `gorm:"embedded;embeddedPrefix:price_"`
+}
+
+type Lines []*Line
+
+// Order is placed from a cart. Its items keep the title and price the books
+// had at the time, and their copies stay reserved until it is cancelled.
+// A pending order with ReservedUntil set is cancelled once that passes.
+type Order struct {
+	ID            uuid.UUID `gorm:"primarykey"`
+	UserID        uuid.UUID
+	Status        string
+	Total         money.Money `gorm:"embedded;embeddedPrefix:total_"`
+	Items         []*Item     `gorm:"foreignKey:OrderID"`
+	ReservedUntil *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type Orders []*Order
+
+// Copy is a copy of a book owned by a user. Copies are handed out when an
+// order is delivered and may later change hands.
+type Copy struct {
+	ID         uuid.UUID `gorm:"primarykey"`
+	BookID     uuid.UUID
+	OwnerID    uuid.UUID
+	OrderID    *uuid.UUID
+	AcquiredAt time.Time
+}
+
+type Item struct {
+	OrderID   uuid.UUID `gorm:"primarykey"`
+	BookID    uuid.UUID `gorm:"primarykey"`
+	Title     string
+	Quantity  int
+	UnitPrice money.Money `gorm:"embedded;embeddedPrefix:unit_"`
+}
+
+func (Item) TableName() string {
+	return "order_items"
+}
+
+func (ls Lines) ToDto() *CartDTO {
+	dto := &CartDTO{Items: make([]*CartItemDTO, len(ls))}
+	for i, l := range ls {
+		dto.Items[i] = &CartItemDTO{
+			BookID:   idcodec.Encode(l.BookID),
+			Title:    l.Title,
+			Quantity: l.Quantity,
+		}
+		if !l.Price.IsZero() {
+			dto.Items[i].UnitPrice = &l.Price
+		}
+	}
+	if total, err := ls.Total(); err == nil {
+		dto.Total = &total
+	}
+	return dto
+}
+
+// Total sums the lines. All of them must be priced in the same currency.
+func (ls Lines) Total() (money.Money, error) {
+	if len(ls) == 0 {
+		return money.Money{}, ErrEmptyCart
+	}
+
+	total := money.Money{Currency: ls[0].Price.Currency}
+	for _, l := range ls {
+		switch {
+		case l.Price.IsZero():
+			return money.Money{}, ErrUnpriced
+		case l.Price.Currency != total.Currency:
+			return money.Money{}, ErrMixedCurrencies
+		}
+		total.Amount += l.Price.Amount * int64(l.Quantity)
+	}
+	return total, nil
+}
+
+func (o *Order) ToDto() *DTO {
+	dto := &DTO{
+		ID:            idcodec.Encode(o.ID),
+		Status:        o.Status,
+		Total:         o.Total,
+		Items:         make([]*ItemDTO, len(o.Items)),
+		ReservedUntil: o.ReservedUntil,
+		CreatedAt:     o.CreatedAt,
+		UpdatedAt:     o.UpdatedAt,
+	}
+	for i, it := range o.Items {
+		dto.Items[i] = &ItemDTO{
+			BookID:    idcodec.Encode(it.BookID),
+			Title:     it.Title,
+			Quantity:  it.Quantity,
+			UnitPrice: it.UnitPrice,
+		}
+	}
+	return dto
+}
+
+func (os Orders) ToDto() []*DTO {
+	dtos := make([]*DTO, len(os))
+	for i, v := range os {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
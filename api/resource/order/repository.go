@@ -0,0 +1,265 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEmptyCart       = errors.New("order: cart is empty")
+	ErrUnpriced        = errors.New("order: book has no price")
+	ErrMixedCurrencies = errors.New("order: books priced in different currencies")
+	ErrOutOfStock      = errors.New("order: not enough copies in stock")
+	ErrStatusChanged   = errors.New("order: status changed concurrently")
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// SetStock sets the number of available copies of a book.
+func (r *Repository) SetStock(bookID uuid.UUID, available int) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "book_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"available", "updated_at"}),
+	}).Create(&Stock{BookID: bookID, Available: available}).Error
+}
+
+// BookExists reports whether a book that is not deleted has id.
+func (r *Repository) BookExists(id uuid.UUID) (bool, error) {
+	var n int64
+	err := r.db.Table("books").Where("id = ? AND deleted_at IS NULL", id).Count(&n).Error
+	return n > 0, err
+}
+
+// Cart returns the lines of a user's cart, oldest first. Items of deleted
+// books are left out.
+func (r *Repository) Cart(userID uuid.UUID) (Lines, error) {
+	return cart(r.db, userID)
+}
+
+func cart(db *gorm.DB, userID uuid.UUID) (Lines, error) {
+	lines := make([]*Line, 0)
+	err := db.Table("cart_items").
+		Select("cart_items.book_id, cart_items.quantity, books.title, books.price_amount, books.price_currency").
+		Joins("JOIN books ON books.id = cart_items.book_id AND books.deleted_at IS NULL").
+		Where("cart_items.user_id = ?", userID).
+		Order("cart_items.created_at, cart_items.book_id").
+		Scan(&lines).Error
+	if err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// PutCartItem adds a book to a user's cart or changes its quantity.
+func (r *Repository) PutCartItem(item *CartItem) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+	}).Create(item).Error
+}
+
+func (r *Repository) DeleteCartItem(userID, bookID uuid.UUID) (int64, error) {
+	result := r.db.Where("user_id = ? AND book_id = ?", userID, bookID).Delete(&CartItem{})
+	return result.RowsAffected, result.Error
+}
+
+// Checkout places an order for the contents of a user's cart, reserving the
+// copies it needs for ttl, or until cancelled when ttl is zero, and empties
+// the cart. Nothing changes unless every book is priced in one currency and
+// has enough copies in stock.
+func (r *Repository) Checkout(userID uuid.UUID, now time.Time, ttl time.Duration) (*Order, error) {
+	o := &Order{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if ttl > 0 {
+		until := now.Add(ttl)
+		o.ReservedUntil = &until
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		lines, err := cart(tx, userID)
+		if err != nil {
+			return err
+		}
+		if o.Total, err = lines.Total(); err != nil {
+			return err
+		}
+
+		for _, l := range lines {
+			result := tx.Model(&Stock{}).
+				Where("book_id = ? AND available >= ?", l.BookID, l.Quantity).
+				Updates(map[string]any{"available": gorm.Expr("available - ?", l.Quantity), "updated_at": now})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrOutOfStock
+			}
+
+			o.Items = append(o.Items, &Item{
+				OrderID:   o.ID,
+				BookID:    l.BookID,
+				Title:     l.Title,
+				Quantity:  l.Quantity,
+				UnitPrice: l.Price,
+			})
+		}
+
+		if err := tx.Create(o).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&CartItem{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// List returns a user's orders, newest first.
+func (r *Repository) List(userID uuid.UUID) (Orders, error) {
+	orders := make([]*Order, 0)
+	err := r.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("title")
+	}).Where("user_id = ?", userID).Order("created_at DESC").Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *Repository) Read(id uuid.UUID) (*Order, error) {
+	o := &Order{}
+	err := r.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("title")
+	}).Where("id = ?", id).First(o).Error
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Transition moves o along t, returning its copies to stock when t
+// releases them and handing them to the customer when t delivers them. Leaving pending ends the reservation's expiry, as the
+// copies are then either sold or released. It returns ErrStatusChanged when
+// o's status changed since it was read.
+func (r *Repository) Transition(o *Order, t Transition, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Order{}).
+			Where("id = ? AND status = ?", o.ID, o.Status).
+			Updates(map[string]any{"status": t.To, "reserved_until": nil, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+
+		if t.Release {
+			for _, it := range o.Items {
+				if err := tx.Model(&Stock{}).Where("book_id = ?", it.BookID).
+					Updates(map[string]any{"available": gorm.Expr("available + ?", it.Quantity), "updated_at": now}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if t.Deliver {
+			var copies []*Copy
+			for _, it := range o.Items {
+				for range it.Quantity {
+					copies = append(copies, &Copy{ID: uuid.New(), BookID: it.BookID, OwnerID: o.UserID, OrderID: &o.ID, AcquiredAt: now})
+				}
+			}
+			if len(copies) > 0 {
+				if err := tx.Create(copies).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		o.Status, o.ReservedUntil, o.UpdatedAt = t.To, nil, now
+		return nil
+	})
+}
+
+// ReleaseExpired cancels up to limit pending orders whose reservation
+// expired by now, returning their copies to stock, and reports how many it
+// cancelled. Orders paid in the meantime are left alone.
+func (r *Repository) ReleaseExpired(now time.Time, limit int) (int, error) {
+	expired := make([]*Order, 0)
+	err := r.db.Preload("Items").
+		Where("status = ? AND reserved_until <= ?", StatusPending, now).
+		Order("reserved_until").Limit(limit).Find(&expired).Error
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, o := range expired {
+		t, err := Next(o.Status, EventCancel, true)
+		if err != nil {
+			return released, err
+		}
+		if err := r.Transition(o, t, now); err != nil {
+			if errors.Is(err, ErrStatusChanged) {
+				continue
+			}
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+type availability struct {
+	BookID    uuid.UUID
+	Available int
+	Reserved  int
+}
+
+// Availability returns the stock of the books that have a stock level,
+// counting the copies held by pending orders as reserved.
+func (r *Repository) Availability(bookIDs []uuid.UUID) (map[uuid.UUID]*AvailabilityDTO, error) {
+	rows := make([]*availability, 0)
+	err := r.db.Table("stock_levels").
+		Select("stock_levels.book_id, stock_levels.available, COALESCE(SUM(order_items.quantity), 0) AS reserved").
+		Joins("LEFT JOIN order_items ON order_items.book_id = stock_levels.book_id AND "+
+			"order_items.order_id IN (SELECT id FROM orders WHERE status = ?)", StatusPending).
+		Where("stock_levels.book_id IN ?", bookIDs).
+		Group("stock_levels.book_id, stock_levels.available").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[uuid.UUID]*AvailabilityDTO, len(rows))
+	for _, row := range rows {
+		m[row.BookID] = &AvailabilityDTO{Available: row.Available, Reserved: row.Reserved}
+	}
+	return m, nil
+}
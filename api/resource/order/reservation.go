@@ -0,0 +1,62 @@
+package order
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/util/computed"
+)
+
+const expiredReservationBatchSize = 100
+
+// ExpireReservations cancels pending orders whose reservation expired every
+// interval until ctx is done, returning their copies to stock.
+func (api *API) ExpireReservations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := api.repository.WithContext(ctx).ReleaseExpired(api.now(), expiredReservationBatchSize)
+				if err != nil {
+					log.Printf("reservation expiry: %s", err)
+				}
+				if err != nil || n < expiredReservationBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// AvailabilityResolver returns a computed field holding the stock of each
+// item's book, or nothing for books without a stock level.
+func AvailabilityResolver[T any](r *Repository, bookID func(T) uuid.UUID) computed.Resolver[T] {
+	return func(ctx context.Context, items []T) ([]any, error) {
+		ids := make([]uuid.UUID, len(items))
+		for i, item := range items {
+			ids[i] = bookID(item)
+		}
+
+		stock, err := r.WithContext(ctx).Availability(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]any, len(items))
+		for i, id := range ids {
+			// A nil *AvailabilityDTO would still be a non-nil any.
+			if a, ok := stock[id]; ok {
+				values[i] = a
+			}
+		}
+		return values, nil
+	}
+}
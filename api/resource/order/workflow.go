@@ -0,0 +1,74 @@
+package order
+
+import (
+	"errors"
+	"slices"
+)
+
+// Order statuses. A new order is pending with its copies reserved.
+const (
+	StatusPending   = "pending"
+	StatusPaid      = "paid"
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+	StatusCancelled = "cancelled"
+)
+
+// Events move an order from one status to the next.
+const (
+	EventPay     = "pay"
+	EventShip    = "ship"
+	EventDeliver = "deliver"
+	EventCancel  = "cancel"
+)
+
+var (
+	ErrUnknownEvent      = errors.New("order: unknown event")
+	ErrInvalidTransition = errors.New("order: event not allowed in this status")
+)
+
+// Transition is the effect of an event.
+type Transition struct {
+	// From lists the statuses the event applies to.
+	From []string
+	To   string
+	// Customer lists the statuses in which the customer may fire the event
+	// on their own order; staff may fire it in any of From.
+	Customer []string
+	// Release returns the order's reserved copies to stock.
+	Release bool
+	// Deliver hands the order's copies to its customer.
+	Deliver bool
+}
+
+// workflow is the order lifecycle: pending -> paid -> shipped -> delivered,
+// with cancellation possible until the order ships.
+var workflow = map[string]Transition{
+	EventPay:     {From: []string{StatusPending}, To: StatusPaid},
+	EventShip:    {From: []string{StatusPaid}, To: StatusShipped},
+	EventDeliver: {From: []string{StatusShipped}, To: StatusDelivered, Deliver: true},
+	EventCancel: {
+		From:     []string{StatusPending, StatusPaid},
+		To:       StatusCancelled,
+		Customer: []string{StatusPending},
+		Release:  true,
+	},
+}
+
+// Next returns the transition event causes from status, as fired by staff
+// or by the order's customer.
+func Next(status, event string, staff bool) (Transition, error) {
+	t, ok := workflow[event]
+	if !ok {
+		return Transition{}, ErrUnknownEvent
+	}
+
+	allowed := t.From
+	if !staff {
+		allowed = t.Customer
+	}
+	if !slices.Contains(allowed, status) {
+		return Transition{}, ErrInvalidTransition
+	}
+	return t, nil
+}
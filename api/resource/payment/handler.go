@@ -0,0 +1,143 @@
+package payment
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/api/resource/order"
+	"hello/config"
+	"hello/idcodec"
+	"hello/money"
+)
+
+// SandboxSecret signs webhooks in sandbox mode when no secret is configured.
+const SandboxSecret = "whsec_sandbox"
+
+// maxPayload bounds the size of a webhook body.
+const maxPayload = 1 << 20
+
+// orderEvents maps the provider events that move orders to order events.
+var orderEvents = map[string]string{
+	TypePaymentSucceeded: order.EventPay,
+	TypeChargeRefunded:   order.EventCancel,
+}
+
+type EventDTO struct {
+	ID      string `json:"id"`
+	Outcome string `json:"outcome"`
+}
+
+type API struct {
+	repository *Repository
+	secret     string
+	tolerance  time.Duration
+	sandbox    bool
+	now        func() time.Time
+}
+
+func New(db *gorm.DB, c *config.ConfPayment) *API {
+	secret := c.WebhookSecret
+	if secret == "" && c.Sandbox {
+		secret = SandboxSecret
+	}
+	return &API{
+		repository: NewRepository(db),
+		secret:     secret,
+		tolerance:  c.Tolerance,
+		sandbox:    c.Sandbox,
+		now:        time.Now,
+	}
+}
+
+// Webhook godoc
+//
+//	@summary        Receive payment event
+//	@description    Receive a signed payment provider event. Successful payments mark orders paid and refunds cancel them; redelivered events are no-ops
+//	@tags           payments
+//	@accept         json
+//	@produce        json
+//	@param          Stripe-Signature    header  string  true    "Signature"
+//	@success        200 {object}    EventDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /payments/webhook [post]
+func (api *API) Webhook(w http.ResponseWriter, r *http.Request) error {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayload))
+	if err != nil {
+		return e.RespInvalidPaymentEvent
+	}
+
+	if err := Verify(api.secret, payload, r.Header.Get(SignatureHeader), api.tolerance, api.now()); err != nil {
+		return e.RespInvalidWebhookSignature
+	}
+
+	p := &Payload{}
+	if err := json.Unmarshal(payload, p); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+	if p.ID == "" || p.Type == "" {
+		return e.RespInvalidPaymentEvent
+	}
+	// Sandbox mode takes test events only, and live mode live ones only.
+	if p.Livemode == api.sandbox {
+		return e.RespPaymentModeMismatch
+	}
+
+	ev := &Event{
+		ID:         p.ID,
+		Type:       p.Type,
+		Livemode:   p.Livemode,
+		Payload:    string(payload),
+		ReceivedAt: api.now(),
+	}
+	if id, err := idcodec.Decode(p.Data.Object.Metadata["order_id"]); err == nil {
+		ev.OrderID = &id
+	}
+	amount := money.Money{Amount: p.Data.Object.Amount, Currency: strings.ToUpper(p.Data.Object.Currency)}
+
+	dto := &EventDTO{ID: ev.ID}
+	if err := api.repository.WithContext(r.Context()).Receive(ev, orderEvents[p.Type], amount, ev.ReceivedAt); err != nil {
+		if !errors.Is(err, ErrDuplicate) {
+			return e.RespDBDataInsertFailure
+		}
+		dto.Outcome = OutcomeDuplicate
+	} else {
+		dto.Outcome = ev.Outcome
+	}
+
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// SandboxEvent godoc
+//
+//	@summary        Simulate payment event
+//	@description    Sign an event with the webhook secret and deliver it to the webhook; only served in sandbox mode
+//	@tags           payments
+//	@accept         json
+//	@produce        json
+//	@param          body    body    Payload true    "Event"
+//	@success        200 {object}    EventDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /payments/sandbox/events [post]
+func (api *API) SandboxEvent(w http.ResponseWriter, r *http.Request) error {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayload))
+	if err != nil {
+		return e.RespInvalidPaymentEvent
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+	r.Header.Set(SignatureHeader, Sign(api.secret, payload, api.now()))
+	return api.Webhook(w, r)
+}
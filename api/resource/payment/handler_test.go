@@ -0,0 +1,153 @@
+package payment_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/order"
+	"hello/api/resource/payment"
+	"hello/config"
+	mockDB "hello/mock/db"
+	"hello/money"
+	testUtil "hello/util/test"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	payload := []byte(`{"id": "evt_1"}`)
+	valid := payment.Sign("secret", payload, now)
+
+	tests := []struct {
+		name   string
+		header string
+		err    error
+	}{
+		{"valid", valid, nil},
+		{"rolled secret", valid + ",v1=" + strings.Repeat("0", 64), nil},
+		{"other secret", payment.Sign("other", payload, now), payment.ErrInvalidSignature},
+		{"no signature", fmt.Sprintf("t=%d", now.Unix()), payment.ErrInvalidSignature},
+		{"no timestamp", strings.SplitN(valid, ",", 2)[1], payment.ErrInvalidSignature},
+		{"empty", "", payment.ErrInvalidSignature},
+		{"stale", payment.Sign("secret", payload, now.Add(-10*time.Minute)), payment.ErrStaleSignature},
+		{"future", payment.Sign("secret", payload, now.Add(10*time.Minute)), payment.ErrStaleSignature},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testUtil.Equal(t, tc.err, payment.Verify("secret", payload, tc.header, 5*time.Minute, now))
+		})
+	}
+
+	testUtil.Equal(t, payment.ErrInvalidSignature, payment.Verify("secret", []byte(`{"id": "evt_2"}`), valid, 5*time.Minute, now))
+}
+
+func TestAPI_Webhook(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &order.Stock{}, &order.CartItem{}, &order.Order{}, &order.Item{}, &payment.Event{})
+
+	b := &book.Book{ID: uuid.New(), Title: "Dune", Price: money.Money{Amount: 1250, Currency: "EUR"}}
+	testUtil.NoError(t, db.Create(b).Error)
+	testUtil.NoError(t, db.Create(&order.Stock{BookID: b.ID, Available: 5}).Error)
+	userID := uuid.New()
+	testUtil.NoError(t, db.Create(&order.CartItem{UserID: userID, BookID: b.ID, Quantity: 2}).Error)
+	o, err := order.NewRepository(db).Checkout(userID, time.Now(), 0)
+	testUtil.NoError(t, err)
+
+	api := payment.New(db, &config.ConfPayment{WebhookSecret: "whsec_test", Tolerance: 5 * time.Minute, Sandbox: true})
+	event := func(id, typ string, livemode bool, amount int64, orderID string) string {
+		return fmt.Sprintf(`{"id": %q, "type": %q, "livemode": %t, "data": {"object": {"amount": %d, "currency": "eur", "metadata": {"order_id": %q}}}}`,
+			id, typ, livemode, amount, orderID)
+	}
+	deliver := func(body, signature string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/payments/webhook", strings.NewReader(body))
+		req.Header.Set(payment.SignatureHeader, signature)
+		w := httptest.NewRecorder()
+		e.Handle(api.Webhook)(w, req)
+
+		var dto payment.EventDTO
+		json.Unmarshal(w.Body.Bytes(), &dto)
+		return w.Code, dto.Outcome
+	}
+	signed := func(body string) string {
+		return payment.Sign("whsec_test", []byte(body), time.Now())
+	}
+	status := func() string {
+		read, err := order.NewRepository(db).Read(o.ID)
+		testUtil.NoError(t, err)
+		return read.Status
+	}
+
+	paid := event("evt_paid", payment.TypePaymentSucceeded, false, 2500, o.ID.String())
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		code      int
+		outcome   string
+		status    string
+	}{
+		{"unsigned", paid, "", http.StatusBadRequest, "", order.StatusPending},
+		{"wrong secret", paid, payment.Sign("whsec_other", []byte(paid), time.Now()), http.StatusBadRequest, "", order.StatusPending},
+		{"live event in sandbox", event("evt_live", payment.TypePaymentSucceeded, true, 2500, o.ID.String()), "", http.StatusBadRequest, "", order.StatusPending},
+		{"wrong amount", event("evt_short", payment.TypePaymentSucceeded, false, 100, o.ID.String()), "", http.StatusOK, payment.OutcomeAmountMismatch, order.StatusPending},
+		{"unknown order", event("evt_lost", payment.TypePaymentSucceeded, false, 2500, uuid.NewString()), "", http.StatusOK, payment.OutcomeUnknownOrder, order.StatusPending},
+		{"other type", event("evt_other", "customer.created", false, 0, ""), "", http.StatusOK, payment.OutcomeIgnored, order.StatusPending},
+		{"paid", paid, "", http.StatusOK, payment.OutcomeApplied, order.StatusPaid},
+		{"redelivered", paid, "", http.StatusOK, payment.OutcomeDuplicate, order.StatusPaid},
+		{"paid twice", event("evt_paid_again", payment.TypePaymentSucceeded, false, 2500, o.ID.String()), "", http.StatusOK, payment.OutcomeIgnored, order.StatusPaid},
+		{"refunded", event("evt_refund", payment.TypeChargeRefunded, false, 2500, o.ID.String()), "", http.StatusOK, payment.OutcomeApplied, order.StatusCancelled},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			signature := tc.signature
+			if signature == "" && tc.name != "unsigned" {
+				signature = signed(tc.body)
+			}
+			code, outcome := deliver(tc.body, signature)
+			testUtil.Equal(t, tc.code, code)
+			testUtil.Equal(t, tc.outcome, outcome)
+			testUtil.Equal(t, tc.status, status())
+		})
+	}
+
+	var count int64
+	testUtil.NoError(t, db.Model(&payment.Event{}).Count(&count).Error)
+	testUtil.Equal(t, int64(6), count)
+
+	s := &order.Stock{}
+	testUtil.NoError(t, db.Where("book_id = ?", b.ID).First(s).Error)
+	testUtil.Equal(t, 5, s.Available)
+}
+
+func TestAPI_SandboxEvent(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &order.Order{}, &order.Item{}, &payment.Event{})
+
+	api := payment.New(db, &config.ConfPayment{Tolerance: 5 * time.Minute, Sandbox: true})
+	simulate := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e.Handle(api.SandboxEvent)(w, httptest.NewRequest(http.MethodPost, "/payments/sandbox/events", strings.NewReader(body)))
+		return w
+	}
+
+	w := simulate(`{"id": "evt_sim", "type": "customer.created", "livemode": false}`)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), payment.OutcomeIgnored))
+
+	// Events the webhook refuses are answered with its problem.
+	w = simulate(`{"id": "evt_live", "type": "customer.created", "livemode": true}`)
+	testUtil.Equal(t, http.StatusBadRequest, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "payment_mode_mismatch"))
+}
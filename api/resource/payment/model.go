@@ -0,0 +1,54 @@
+package payment
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types the webhook acts on; others are recorded and ignored.
+const (
+	TypePaymentSucceeded = "payment_intent.succeeded"
+	TypeChargeRefunded   = "charge.refunded"
+)
+
+// Outcomes of a received event.
+const (
+	OutcomeApplied        = "applied"
+	OutcomeIgnored        = "ignored"
+	OutcomeUnknownOrder   = "unknown_order"
+	OutcomeAmountMismatch = "amount_mismatch"
+	// OutcomeDuplicate answers redelivered events; it is never stored.
+	OutcomeDuplicate = "duplicate"
+)
+
+// Payload is the part of a provider event the webhook reads. The object's
+// metadata carries the public ID of the order it pays for.
+type Payload struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Livemode bool   `json:"livemode"`
+	Data     struct {
+		Object struct {
+			Amount   int64             `json:"amount"`
+			Currency string            `json:"currency"`
+			Metadata map[string]string `json:"metadata"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+// Event is a received provider event. Its ID is the provider's, which makes
+// redelivered events no-ops.
+type Event struct {
+	ID         string `gorm:"primarykey"`
+	Type       string
+	OrderID    *uuid.UUID
+	Livemode   bool
+	Outcome    string
+	Payload    string `gorm:"type:jsonb"`
+	ReceivedAt time.Time
+}
+
+func (Event) TableName() string {
+	return "payment_events"
+}
@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hello/api/resource/order"
+	"hello/money"
+)
+
+var ErrDuplicate = errors.New("payment: event already received")
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// Receive records ev and, when orderEvent is set, fires it on ev's order in
+// the same transaction, so that an event is applied exactly once however
+// often it is delivered. A payment must match the order total. It sets
+// ev.Outcome, and returns ErrDuplicate for events received before.
+func (r *Repository) Receive(ev *Event, orderEvent string, amount money.Money, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&Event{}).Where("id = ?", ev.ID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrDuplicate
+		}
+
+		outcome, err := apply(tx, ev, orderEvent, amount, now)
+		if err != nil {
+			return err
+		}
+		ev.Outcome = outcome
+		return tx.Create(ev).Error
+	})
+}
+
+func apply(tx *gorm.DB, ev *Event, orderEvent string, amount money.Money, now time.Time) (string, error) {
+	if orderEvent == "" {
+		return OutcomeIgnored, nil
+	}
+	if ev.OrderID == nil {
+		return OutcomeUnknownOrder, nil
+	}
+
+	orders := order.NewRepository(tx)
+	o, err := orders.Read(*ev.OrderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ev.OrderID = nil
+			return OutcomeUnknownOrder, nil
+		}
+		return "", err
+	}
+	if orderEvent == order.EventPay && amount != o.Total {
+		return OutcomeAmountMismatch, nil
+	}
+
+	// An order that already moved on, e.g. cancelled before the payment
+	// arrived, is left alone.
+	t, err := order.Next(o.Status, orderEvent, true)
+	if err != nil {
+		return OutcomeIgnored, nil
+	}
+	if err := orders.Transition(o, t, now); err != nil {
+		return "", err
+	}
+	return OutcomeApplied, nil
+}
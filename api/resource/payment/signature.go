@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the webhook signature in the provider's format:
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<payload>">". A header may hold
+// several v1 signatures while the secret is being rolled.
+const SignatureHeader = "Stripe-Signature"
+
+var (
+	ErrInvalidSignature = errors.New("payment: invalid signature")
+	ErrStaleSignature   = errors.New("payment: signature timestamp outside tolerance")
+)
+
+// Sign returns the signature header for payload sent at t.
+func Sign(secret string, payload []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac(secret, ts, payload)))
+}
+
+// Verify checks that header signs payload with secret, at a time no further
+// than tolerance from now.
+func Verify(secret string, payload []byte, header string, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+
+	want := mac(secret, ts, payload)
+	valid := false
+	for _, sig := range sigs {
+		valid = valid || hmac.Equal(sig, want)
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrStaleSignature
+	}
+	return nil
+}
+
+func mac(secret, ts string, payload []byte) []byte {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(ts))
+	m.Write([]byte("."))
+	m.Write(payload)
+	return m.Sum(nil)
+}
@@ -0,0 +1,267 @@
+package progress
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+	"hello/util/sanitizer"
+	validatorUtil "hello/util/validator"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+	defaultStatsDays    = 30
+	maxStatsDays        = 366
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	now        func() time.Time
+}
+
+func New(db *gorm.DB, v *validator.Validate) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		now:        time.Now,
+	}
+}
+
+// Put godoc
+//
+//	@summary        Report reading progress
+//	@description    Report the signed-in user's position in a book from a device, by page, percent or both. The position recorded last becomes the current one on every device; positions recorded earlier are only kept in the history. Returns the current position
+//	@tags           progress
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          body    body    Form    true    "Progress form"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me/books/{id}/progress [put]
+func (api *API) Put(w http.ResponseWriter, r *http.Request) error {
+	userID, bookID, err := params(r)
+	if err != nil {
+		return err
+	}
+
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+	sanitizer.Struct(form)
+	if err := api.validate(r, form); err != nil {
+		return err
+	}
+
+	repository := api.repository.WithContext(r.Context())
+	exists, err := repository.BookExists(bookID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+	if !exists {
+		return e.RespNotFound
+	}
+
+	// A device clock running ahead must not pin its position as the latest
+	// one, so positions are never recorded later than they are received.
+	now := api.now().UTC()
+	recordedAt := now
+	if form.RecordedAt != nil && form.RecordedAt.Before(now) {
+		recordedAt = form.RecordedAt.UTC()
+	}
+
+	p, err := repository.Record(&Entry{
+		UserID:     userID,
+		BookID:     bookID,
+		Page:       form.Page,
+		Percent:    form.Percent,
+		Device:     form.Device,
+		RecordedAt: recordedAt.Truncate(time.Microsecond),
+	})
+	if err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(p.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Read godoc
+//
+//	@summary        Read reading progress
+//	@description    Read the signed-in user's current position in a book
+//	@tags           progress
+//	@produce        json
+//	@param          id  path    string  true    "Book ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /me/books/{id}/progress [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	userID, bookID, err := params(r)
+	if err != nil {
+		return err
+	}
+
+	p, err := api.repository.WithContext(r.Context()).Read(userID, bookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(p.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// History godoc
+//
+//	@summary        List reading progress history
+//	@description    List the positions the signed-in user reported in a book from any device, latest first
+//	@tags           progress
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          limit   query   int     false   "Maximum number of positions (default 50, at most 500)"
+//	@success        200 {array}     DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me/books/{id}/progress/history [get]
+func (api *API) History(w http.ResponseWriter, r *http.Request) error {
+	userID, bookID, err := params(r)
+	if err != nil {
+		return err
+	}
+
+	limit := defaultHistoryLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxHistoryLimit)
+	}
+
+	entries, err := api.repository.WithContext(r.Context()).History(userID, bookID, limit)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(entries.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Stats godoc
+//
+//	@summary        Reading stats
+//	@description    Totals over the signed-in user's reading: books started and finished, pages reached, progress updates and the devices they came from
+//	@tags           progress
+//	@produce        json
+//	@success        200 {object}    StatsDTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me/reading/stats [get]
+func (api *API) Stats(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	stats, err := api.repository.WithContext(r.Context()).Stats(userID)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(stats.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Daily godoc
+//
+//	@summary        Daily reading stats
+//	@description    The signed-in user's reading on each UTC day with any: books read, pages advanced past the furthest reached before, and progress updates
+//	@tags           progress
+//	@produce        json
+//	@param          days    query   int     false   "Look-back window in days, including today (default 30, at most 366)"
+//	@success        200 {array}     DayDTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /me/reading/stats/daily [get]
+func (api *API) Daily(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	days := defaultStatsDays
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 {
+		days = min(d, maxStatsDays)
+	}
+	since := api.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+
+	ds, err := api.repository.WithContext(r.Context()).Days(userID, since)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(ds.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// params returns the signed-in user and the book in the URL.
+func params(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	userID, err := caller(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, e.RespInvalidURLParamID
+	}
+	return userID, bookID, nil
+}
+
+// caller returns the signed-in user, answering 401 without one.
+func caller(r *http.Request) (uuid.UUID, error) {
+	id, ok := user.From(r.Context())
+	if !ok {
+		return uuid.Nil, e.RespAuthenticationRequired
+	}
+	return id, nil
+}
+
+func (api *API) validate(r *http.Request, form any) error {
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package progress_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/progress"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Progress(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &progress.Progress{}, &progress.Entry{})
+
+	dune := &book.Book{ID: uuid.New(), Title: "Dune"}
+	emma := &book.Book{ID: uuid.New(), Title: "Emma"}
+	testUtil.NoError(t, db.Create([]*book.Book{dune, emma}).Error)
+
+	api := progress.New(db, validatorUtil.New())
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Put("/me/books/{id}/progress", e.Handle(api.Put))
+	r.Get("/me/books/{id}/progress", e.Handle(api.Read))
+	r.Get("/me/books/{id}/progress/history", e.Handle(api.History))
+	r.Get("/me/reading/stats", e.Handle(api.Stats))
+	r.Get("/me/reading/stats/daily", e.Handle(api.Daily))
+
+	alice := uuid.NewString()
+	serve := func(method, target, body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(user.Header, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	put := func(id uuid.UUID, body string) (int, *progress.DTO) {
+		w := serve(http.MethodPut, "/me/books/"+id.String()+"/progress", body, alice)
+		dto := &progress.DTO{}
+		json.Unmarshal(w.Body.Bytes(), dto)
+		return w.Code, dto
+	}
+
+	testUtil.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/me/reading/stats", "", "").Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/me/books/"+dune.ID.String()+"/progress", "", alice).Code)
+
+	code, _ := put(dune.ID, `{"device": "phone"}`)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, code)
+	code, _ = put(uuid.New(), `{"page": 1, "device": "phone"}`)
+	testUtil.Equal(t, http.StatusNotFound, code)
+
+	code, p := put(dune.ID, `{"page": 40, "device": "phone", "recorded_at": "2024-03-01T08:00:00Z"}`)
+	testUtil.Equal(t, http.StatusOK, code)
+	testUtil.Equal(t, 40, *p.Page)
+
+	// The e-reader syncs late with a position from before the phone's; the
+	// phone's stays current.
+	code, p = put(dune.ID, `{"page": 25, "percent": 10, "device": "e-reader", "recorded_at": "2024-03-01T07:00:00Z"}`)
+	testUtil.Equal(t, http.StatusOK, code)
+	testUtil.Equal(t, "phone", p.Device)
+	testUtil.Equal(t, 40, *p.Page)
+
+	code, p = put(dune.ID, `{"page": 90, "percent": 100, "device": "e-reader", "recorded_at": "2024-03-02T21:00:00Z"}`)
+	testUtil.Equal(t, http.StatusOK, code)
+	testUtil.Equal(t, "e-reader", p.Device)
+	testUtil.Equal(t, 100.0, *p.Percent)
+
+	// Retrying a report records it once.
+	code, _ = put(dune.ID, `{"page": 90, "percent": 100, "device": "e-reader", "recorded_at": "2024-03-02T21:00:00Z"}`)
+	testUtil.Equal(t, http.StatusOK, code)
+
+	// A position dated in the future is recorded as received.
+	code, p = put(emma.ID, `{"percent": 5, "device": "phone", "recorded_at": "2999-01-01T00:00:00Z"}`)
+	testUtil.Equal(t, http.StatusOK, code)
+	testUtil.Equal(t, true, p.RecordedAt.Year() < 2999)
+
+	var history []*progress.DTO
+	w := serve(http.MethodGet, "/me/books/"+dune.ID.String()+"/progress/history", "", alice)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	testUtil.Equal(t, 3, len(history))
+	testUtil.Equal(t, 90, *history[0].Page)
+	testUtil.Equal(t, 25, *history[2].Page)
+
+	var stats progress.StatsDTO
+	w = serve(http.MethodGet, "/me/reading/stats", "", alice)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	testUtil.Equal(t, int64(2), stats.BooksStarted)
+	testUtil.Equal(t, int64(1), stats.BooksFinished)
+	testUtil.Equal(t, int64(90), stats.PagesRead)
+	testUtil.Equal(t, int64(4), stats.Updates)
+	testUtil.Equal(t, int64(2), stats.Devices)
+
+	var days []*progress.DayDTO
+	w = serve(http.MethodGet, "/me/reading/stats/daily?days=366000", "", alice)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &days))
+	testUtil.Equal(t, 1, len(days))
+	testUtil.Equal(t, 1, days[0].Updates)
+}
+
+func TestRepository_Days(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &progress.Progress{}, &progress.Entry{})
+
+	userID, bookID := uuid.New(), uuid.New()
+	page := func(n int) *int { return &n }
+	at := func(s string) progress.Entry {
+		recordedAt, err := time.Parse(time.RFC3339, s)
+		testUtil.NoError(t, err)
+		return progress.Entry{UserID: userID, BookID: bookID, Device: "phone", RecordedAt: recordedAt}
+	}
+
+	repository := progress.NewRepository(db)
+	for _, v := range []struct {
+		at   string
+		page int
+	}{
+		{"2024-02-28T20:00:00Z", 30},
+		{"2024-03-01T08:00:00Z", 50},
+		{"2024-03-01T09:00:00Z", 45},
+		{"2024-03-03T22:00:00Z", 70},
+	} {
+		e := at(v.at)
+		e.Page = page(v.page)
+		_, err := repository.Record(&e)
+		testUtil.NoError(t, err)
+	}
+
+	since, _ := time.Parse(time.DateOnly, "2024-03-01")
+	days, err := repository.Days(userID, since)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 2, len(days))
+	testUtil.Equal(t, "2024-03-01", days[0].Date.Format(time.DateOnly))
+	testUtil.Equal(t, 20, days[0].Pages)
+	testUtil.Equal(t, 2, days[0].Updates)
+	testUtil.Equal(t, 20, days[1].Pages)
+}
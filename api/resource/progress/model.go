@@ -0,0 +1,143 @@
+package progress
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/idcodec"
+)
+
+type DTO struct {
+	BookID     string    `json:"book_id"`
+	Page       *int      `json:"page,omitempty"`
+	Percent    *float64  `json:"percent,omitempty"`
+	Device     string    `json:"device"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+type StatsDTO struct {
+	BooksStarted  int64      `json:"books_started"`
+	BooksFinished int64      `json:"books_finished"`
+	PagesRead     int64      `json:"pages_read"`
+	Updates       int64      `json:"updates"`
+	Devices       int64      `json:"devices"`
+	LastReadAt    *time.Time `json:"last_read_at,omitempty"`
+}
+
+type DayDTO struct {
+	Date    string `json:"date"`
+	Books   int    `json:"books"`
+	Pages   int    `json:"pages"`
+	Updates int    `json:"updates"`
+}
+
+// Form is a reading position on one device. RecordedAt is when the device
+// was at the position, so that updates synced late from an offline device
+// do not overwrite newer ones; it defaults to the time of the request.
+type Form struct {
+	Page       *int       `json:"page" validate:"required_without=Percent,omitempty,min=0"`
+	Percent    *float64   `json:"percent" validate:"required_without=Page,omitempty,min=0,max=100"`
+	Device     string     `json:"device" validate:"required,max=64" sanitize:"singleline"`
+	RecordedAt *time.Time `json:"recorded_at"`
+}
+
+// Progress is a user's latest reading position in a book, across devices.
+type Progress struct {
+	UserID     uuid.UUID `gorm:"primarykey"`
+	BookID     uuid.UUID `gorm:"primarykey"`
+	Page       *int
+	Percent    *float64
+	Device     string
+	RecordedAt time.Time
+	UpdatedAt  time.Time
+}
+
+func (Progress) TableName() string {
+	return "reading_progress"
+}
+
+// Entry is one reported position, kept as reading history. A device is at
+// one position at a time, so the same report sent twice is one entry.
+type Entry struct {
+	UserID     uuid.UUID `gorm:"primarykey"`
+	BookID     uuid.UUID `gorm:"primarykey"`
+	Device     string    `gorm:"primarykey"`
+	RecordedAt time.Time `gorm:"primarykey"`
+	Page       *int
+	Percent    *float64
+	CreatedAt  time.Time
+}
+
+func (Entry) TableName() string {
+	return "reading_progress_history"
+}
+
+type Entries []*Entry
+
+// Stats are totals over all of a user's books.
+type Stats struct {
+	BooksStarted  int64
+	BooksFinished int64
+	PagesRead     int64
+	Updates       int64
+	Devices       int64
+	LastReadAt    *time.Time
+}
+
+// Day is the reading done on one UTC day.
+type Day struct {
+	Date    time.Time
+	Books   int
+	Pages   int
+	Updates int
+}
+
+type Days []*Day
+
+func (p *Progress) ToDto() *DTO {
+	return &DTO{
+		BookID:     idcodec.Encode(p.BookID),
+		Page:       p.Page,
+		Percent:    p.Percent,
+		Device:     p.Device,
+		RecordedAt: p.RecordedAt,
+	}
+}
+
+func (e *Entry) ToDto() *DTO {
+	return &DTO{
+		BookID:     idcodec.Encode(e.BookID),
+		Page:       e.Page,
+		Percent:    e.Percent,
+		Device:     e.Device,
+		RecordedAt: e.RecordedAt,
+	}
+}
+
+func (es Entries) ToDto() []*DTO {
+	dtos := make([]*DTO, len(es))
+	for i, v := range es {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
+
+func (s *Stats) ToDto() *StatsDTO {
+	return &StatsDTO{
+		BooksStarted:  s.BooksStarted,
+		BooksFinished: s.BooksFinished,
+		PagesRead:     s.PagesRead,
+		Updates:       s.Updates,
+		Devices:       s.Devices,
+		LastReadAt:    s.LastReadAt,
+	}
+}
+
+func (ds Days) ToDto() []*DayDTO {
+	dtos := make([]*DayDTO, len(ds))
+	for i, v := range ds {
+		dtos[i] = &DayDTO{Date: v.Date.Format(time.DateOnly), Books: v.Books, Pages: v.Pages, Updates: v.Updates}
+	}
+	return dtos
+}
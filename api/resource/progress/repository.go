@@ -0,0 +1,194 @@
+package progress
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// BookExists reports whether a book that is not deleted has id.
+func (r *Repository) BookExists(id uuid.UUID) (bool, error) {
+	var n int64
+	err := r.db.Table("books").Where("id = ? AND deleted_at IS NULL", id).Count(&n).Error
+	return n > 0, err
+}
+
+// Record adds e to the reading history and makes it the current position
+// unless a later one is already recorded, then returns the current position.
+// The position recorded last wins, with ties going to the greater device
+// name, so devices syncing in any order agree on the result. Reporting the
+// same position twice records it once.
+func (r *Repository) Record(e *Entry) (*Progress, error) {
+	p := &Progress{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}, {Name: "device"}, {Name: "recorded_at"}},
+			DoNothing: true,
+		}).Create(e).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"page", "percent", "device", "recorded_at", "updated_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{gorm.Expr(
+				"excluded.recorded_at > reading_progress.recorded_at OR " +
+					"(excluded.recorded_at = reading_progress.recorded_at AND excluded.device > reading_progress.device)",
+			)}},
+		}).Create(&Progress{
+			UserID:     e.UserID,
+			BookID:     e.BookID,
+			Page:       e.Page,
+			Percent:    e.Percent,
+			Device:     e.Device,
+			RecordedAt: e.RecordedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ? AND book_id = ?", e.UserID, e.BookID).First(p).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *Repository) Read(userID, bookID uuid.UUID) (*Progress, error) {
+	p := &Progress{}
+	if err := r.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(p).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// History returns the positions a user reported in a book, latest first.
+func (r *Repository) History(userID, bookID uuid.UUID, limit int) (Entries, error) {
+	entries := make([]*Entry, 0)
+	err := r.db.Where("user_id = ? AND book_id = ?", userID, bookID).
+		Order("recorded_at DESC, device DESC").
+		Limit(limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Stats totals a user's reading. Pages read are the pages reached in each
+// book, and a book is finished once its current position is at 100%.
+func (r *Repository) Stats(userID uuid.UUID) (*Stats, error) {
+	s := &Stats{}
+	err := r.db.Model(&Progress{}).
+		Select("COUNT(*) AS books_started, "+
+			"COALESCE(SUM(CASE WHEN percent >= 100 THEN 1 ELSE 0 END), 0) AS books_finished, "+
+			"COALESCE(SUM(page), 0) AS pages_read").
+		Where("user_id = ?", userID).
+		Scan(s).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var history struct {
+		Updates int64
+		Devices int64
+	}
+	err = r.db.Model(&Entry{}).
+		Select("COUNT(*) AS updates, COUNT(DISTINCT device) AS devices").
+		Where("user_id = ?", userID).
+		Scan(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	s.Updates, s.Devices = history.Updates, history.Devices
+
+	var last []*Entry
+	if err := r.db.Where("user_id = ?", userID).Order("recorded_at DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	if len(last) > 0 {
+		s.LastReadAt = &last[0].RecordedAt
+	}
+	return s, nil
+}
+
+// Days returns the reading a user did on each UTC day since since, oldest
+// first, leaving out days without any. Pages count only when a book's page
+// moves past the furthest reached before, so rereading and devices
+// reporting out of order do not count pages twice.
+func (r *Repository) Days(userID uuid.UUID, since time.Time) (Days, error) {
+	var reached []struct {
+		BookID uuid.UUID
+		Page   int
+	}
+	err := r.db.Model(&Entry{}).
+		Select("book_id, MAX(page) AS page").
+		Where("user_id = ? AND recorded_at < ? AND page IS NOT NULL", userID, since).
+		Group("book_id").
+		Scan(&reached).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0)
+	err = r.db.Where("user_id = ? AND recorded_at >= ?", userID, since).
+		Order("recorded_at, device").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	furthest := make(map[uuid.UUID]int, len(reached))
+	for _, v := range reached {
+		furthest[v.BookID] = v.Page
+	}
+	return days(furthest, entries), nil
+}
+
+// days groups entries, in the order they were recorded, by UTC day.
+// furthest holds the page reached in each book before the first entry.
+func days(furthest map[uuid.UUID]int, entries Entries) Days {
+	ds := make(Days, 0)
+	var books map[uuid.UUID]bool
+	for _, e := range entries {
+		date := e.RecordedAt.UTC().Truncate(24 * time.Hour)
+		if len(ds) == 0 || !ds[len(ds)-1].Date.Equal(date) {
+			ds = append(ds, &Day{Date: date})
+			books = make(map[uuid.UUID]bool)
+		}
+		d := ds[len(ds)-1]
+
+		d.Updates++
+		if !books[e.BookID] {
+			books[e.BookID] = true
+			d.Books++
+		}
+		if e.Page != nil && *e.Page > furthest[e.BookID] {
+			d.Pages += *e.Page - furthest[e.BookID]
+			furthest[e.BookID] = *e.Page
+		}
+	}
+	return ds
+}
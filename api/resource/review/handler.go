@@ -0,0 +1,221 @@
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+	"hello/moderation"
+	"hello/util/sanitizer"
+	validatorUtil "hello/util/validator"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	moderator  *moderation.Moderator
+	now        func() time.Time
+}
+
+func New(db *gorm.DB, v *validator.Validate, moderator *moderation.Moderator) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		moderator:  moderator,
+		now:        time.Now,
+	}
+}
+
+// List godoc
+//
+//	@summary        List reviews
+//	@description    List the reviews of a book, newest first. The book's average_rating and review_count total them
+//	@tags           reviews
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          limit   query   int     false   "Maximum number of reviews (default 50, at most 500)"
+//	@param          offset  query   int     false   "Number of reviews to skip"
+//	@success        200 {array}     DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/reviews [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	bookID, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	limit, offset := defaultListLimit, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxListLimit)
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	reviews, err := api.repository.WithContext(r.Context()).List(bookID, limit, offset)
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(reviews.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Create godoc
+//
+//	@summary        Create review
+//	@description    Rate a book from 1 to 5 stars, optionally with a written review. Users review a book once; to change a review, delete it and write another. Text matching the deny-list or scored as spam is rejected, or stored flagged for moderation
+//	@tags           reviews
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string  true    "Book ID"
+//	@param          body    body    Form    true    "Review form"
+//	@success        201 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/reviews [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+	bookID, err := api.book(r)
+	if err != nil {
+		return err
+	}
+
+	form, err := api.form(r)
+	if err != nil {
+		return err
+	}
+	flagged, err := api.moderator.Moderate(r.Context(), moderation.NewSubmission(r, userID.String(), form.Title+"\n"+form.Body))
+	if err != nil {
+		if errors.Is(err, moderation.ErrRejected) {
+			return e.RespContentRejected
+		}
+
+		return err
+	}
+
+	now := api.now()
+	rv := form.ToModel()
+	rv.ID = uuid.New()
+	rv.BookID = bookID
+	rv.UserID = userID
+	rv.Flagged = flagged
+	rv.CreatedAt, rv.UpdatedAt = now, now
+
+	rv, err = api.repository.WithContext(r.Context()).Create(rv)
+	if err != nil {
+		if errors.Is(err, ErrDuplicate) {
+			return e.RespDuplicateReview
+		}
+
+		return e.RespDBDataInsertFailure
+	}
+
+	w.Header().Set("Location", "/v1/books/"+idcodec.Encode(bookID)+"/reviews")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(rv.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Delete godoc
+//
+//	@summary        Delete review
+//	@description    Delete the signed-in user's review of a book
+//	@tags           reviews
+//	@param          id      path    string  true    "Book ID"
+//	@success        204
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /books/{id}/reviews [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+	bookID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).Delete(bookID, userID)
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// book returns the id of the book in the URL, answering 404 when there is
+// no such book.
+func (api *API) book(r *http.Request) (uuid.UUID, error) {
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, e.RespInvalidURLParamID
+	}
+
+	exists, err := api.repository.WithContext(r.Context()).BookExists(id)
+	if err != nil {
+		return uuid.Nil, e.RespDBDataAccessFailure
+	}
+	if !exists {
+		return uuid.Nil, e.RespNotFound
+	}
+	return id, nil
+}
+
+// caller returns the signed-in user, answering 401 without one. Reviews
+// belong to users, so service accounts write none.
+func caller(r *http.Request) (uuid.UUID, error) {
+	id, ok := user.From(r.Context())
+	if !ok {
+		return uuid.Nil, e.RespAuthenticationRequired
+	}
+	return id, nil
+}
+
+func (api *API) form(r *http.Request) (*Form, error) {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return nil, e.RespJSONDecodeFailure
+	}
+	sanitizer.Struct(form)
+
+	if err := api.validator.Struct(form); err != nil {
+		return nil, e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+	return form, nil
+}
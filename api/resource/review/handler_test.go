@@ -0,0 +1,121 @@
+package review_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/review"
+	mockDB "hello/mock/db"
+	"hello/moderation"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Reviews(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &review.Review{})
+	testUtil.NoError(t, db.Exec("CREATE UNIQUE INDEX reviews_book_id_user_id_key ON reviews (book_id, user_id)").Error)
+
+	dune := &book.Book{ID: uuid.New(), Title: "Dune"}
+	testUtil.NoError(t, db.Create(dune).Error)
+
+	moderator := moderation.NewModerator(
+		moderation.NewFilter(moderation.ActionReject, "darn"),
+		moderation.NewAssessor(0.8, moderation.ScorerFunc(moderation.HeuristicScore)),
+	)
+	api := review.New(db, validatorUtil.New(), moderator)
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Get("/books/{id}/reviews", e.Handle(api.List))
+	r.Post("/books/{id}/reviews", e.Handle(api.Create))
+	r.Delete("/books/{id}/reviews", e.Handle(api.Delete))
+
+	alice, bob := uuid.NewString(), uuid.NewString()
+	base := "/books/" + dune.ID.String() + "/reviews"
+	serve := func(method, target, body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if userID != "" {
+			req.Header.Set(user.Header, userID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	totals := func() (int, int64) {
+		b := &book.Book{}
+		testUtil.NoError(t, db.First(b, "id = ?", dune.ID).Error)
+		return b.ReviewCount, b.RatingSum
+	}
+
+	testUtil.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, base, `{"rating": 5}`, "").Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, base, `{"rating": 6}`, alice).Code)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, base, `{"title": "No stars"}`, alice).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/books/"+uuid.NewString()+"/reviews", `{"rating": 5}`, alice).Code)
+
+	w := serve(http.MethodPost, base, `{"rating": 5, "title": "Spice", "body": "A classic."}`, alice)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	var created review.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	testUtil.Equal(t, 5, created.Rating)
+	testUtil.Equal(t, alice, created.Reviewer)
+
+	// One review per user and book.
+	w = serve(http.MethodPost, base, `{"rating": 1}`, alice)
+	testUtil.Equal(t, http.StatusConflict, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "duplicate_review"))
+
+	testUtil.Equal(t, http.StatusCreated, serve(http.MethodPost, base, `{"rating": 2}`, bob).Code)
+	count, sum := totals()
+	testUtil.Equal(t, 2, count)
+	testUtil.Equal(t, int64(7), sum)
+
+	dto := dune.ToDto()
+	dune.ReviewCount, dune.RatingSum = count, sum
+	testUtil.Equal(t, (*float64)(nil), dto.AverageRating)
+	dto = dune.ToDto()
+	testUtil.Equal(t, 3.5, *dto.AverageRating)
+	testUtil.Equal(t, 2, dto.ReviewCount)
+
+	w = serve(http.MethodGet, base+"?limit=1", "", "")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var listed []*review.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	testUtil.Equal(t, 1, len(listed))
+	w = serve(http.MethodGet, base, "", "")
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	testUtil.Equal(t, 2, len(listed))
+
+	// Deleting takes the review out of the totals, once.
+	testUtil.Equal(t, http.StatusNoContent, serve(http.MethodDelete, base, "", bob).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodDelete, base, "", bob).Code)
+	count, sum = totals()
+	testUtil.Equal(t, 1, count)
+	testUtil.Equal(t, int64(5), sum)
+
+	// Having deleted their review, a user may write another.
+	testUtil.Equal(t, http.StatusCreated, serve(http.MethodPost, base, `{"rating": 4}`, bob).Code)
+	count, sum = totals()
+	testUtil.Equal(t, 2, count)
+	testUtil.Equal(t, int64(9), sum)
+
+	// Reviews are moderated: deny-listed terms are rejected and spam is
+	// flagged.
+	carol := uuid.NewString()
+	w = serve(http.MethodPost, base, `{"rating": 1, "body": "Darn boring"}`, carol)
+	testUtil.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), "content_rejected"))
+	w = serve(http.MethodPost, base, `{"rating": 1, "body": "CHEAP COPIES AT https://a.example https://b.example https://c.example !!!!!!!!!!"}`, carol)
+	testUtil.Equal(t, http.StatusCreated, w.Code)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	testUtil.Equal(t, true, created.Flagged)
+}
@@ -0,0 +1,76 @@
+package review
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/idcodec"
+)
+
+type DTO struct {
+	ID        string    `json:"id"`
+	BookID    string    `json:"book_id"`
+	Reviewer  string    `json:"reviewer"`
+	Rating    int       `json:"rating"`
+	Title     string    `json:"title,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	Flagged   bool      `json:"flagged,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Form is a star rating from 1 to 5, optionally with a written review.
+type Form struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Title  string `json:"title" validate:"max=255" sanitize:"singleline"`
+	Body   string `json:"body" validate:"max=10000"`
+}
+
+// Review is a user's rating of a book. Each user reviews a book at most
+// once; the book keeps the totals of its reviews, see Repository.Create.
+// Flagged reviews matched the content filter or were scored as spam or
+// abuse, and await moderation.
+type Review struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	BookID    uuid.UUID
+	UserID    uuid.UUID
+	Rating    int
+	Title     string
+	Body      string
+	Flagged   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Reviews []*Review
+
+func (rv *Review) ToDto() *DTO {
+	return &DTO{
+		ID:        idcodec.Encode(rv.ID),
+		BookID:    idcodec.Encode(rv.BookID),
+		Reviewer:  rv.UserID.String(),
+		Rating:    rv.Rating,
+		Title:     rv.Title,
+		Body:      rv.Body,
+		Flagged:   rv.Flagged,
+		CreatedAt: rv.CreatedAt,
+		UpdatedAt: rv.UpdatedAt,
+	}
+}
+
+func (rs Reviews) ToDto() []*DTO {
+	dtos := make([]*DTO, len(rs))
+	for i, v := range rs {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
+
+func (f *Form) ToModel() *Review {
+	return &Review{
+		Rating: f.Rating,
+		Title:  f.Title,
+		Body:   f.Body,
+	}
+}
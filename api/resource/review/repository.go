@@ -0,0 +1,98 @@
+package review
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrDuplicate is returned when creating a review of a book the user
+// already reviewed.
+var ErrDuplicate = errors.New("review: book already reviewed")
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// BookExists reports whether a book exists and is not deleted.
+func (r *Repository) BookExists(id uuid.UUID) (bool, error) {
+	var n int64
+	if err := r.db.Table("books").Where("id = ? AND deleted_at IS NULL", id).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// List returns the reviews of a book, newest first.
+func (r *Repository) List(bookID uuid.UUID, limit, offset int) (Reviews, error) {
+	reviews := make([]*Review, 0)
+	if err := r.db.Where("book_id = ?", bookID).Order("created_at DESC, id").Limit(limit).Offset(offset).Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// Create adds a review and counts it in the totals of its book, in one
+// transaction so that they always agree.
+func (r *Repository) Create(rv *Review) (*Review, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rv).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.Is(err, gorm.ErrDuplicatedKey) || errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrDuplicate
+			}
+			return err
+		}
+		return count(tx, rv.BookID, 1, rv.Rating)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Delete removes a user's review of a book and takes it out of the totals
+// of the book. It reports no rows when the user has not reviewed the book.
+func (r *Repository) Delete(bookID, userID uuid.UUID) (int64, error) {
+	var rows int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		rv := &Review{}
+		result := tx.Where("book_id = ? AND user_id = ?", bookID, userID).Limit(1).Find(rv)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		result = tx.Where("id = ?", rv.ID).Delete(&Review{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		rows = result.RowsAffected
+		return count(tx, bookID, -1, -rv.Rating)
+	})
+	return rows, err
+}
+
+// count adds to the review totals of a book, leaving updated_at alone since
+// the book's own fields are unchanged.
+func count(tx *gorm.DB, bookID uuid.UUID, reviews, rating int) error {
+	return tx.Table("books").Where("id = ?", bookID).UpdateColumns(map[string]any{
+		"review_count": gorm.Expr("review_count + ?", reviews),
+		"rating_sum":   gorm.Expr("rating_sum + ?", rating),
+	}).Error
+}
@@ -0,0 +1,103 @@
+package schemas
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	e "hello/api/resource/common/err"
+	"hello/event/schema"
+)
+
+// ContentType is that of schema documents.
+const ContentType = "application/schema+json"
+
+// DTO lists a version of the schema of an event.
+type DTO struct {
+	Event   string `json:"event"`
+	Version int    `json:"version"`
+	URL     string `json:"url"`
+}
+
+type API struct {
+	registry *schema.Registry
+}
+
+func New(registry *schema.Registry) *API {
+	return &API{
+		registry: registry,
+	}
+}
+
+// List godoc
+//
+//	@summary        List event schemas
+//	@description    List the versions of the JSON Schemas of the payloads of published domain events. Events carry the version of their schema
+//	@tags           schemas
+//	@produce        json
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /schemas [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	all := api.registry.List()
+	dtos := make([]*DTO, len(all))
+	for i, s := range all {
+		dtos[i] = &DTO{Event: s.Event, Version: s.Version, URL: "/v1/schemas/" + s.Event + "/" + strconv.Itoa(s.Version)}
+	}
+
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Latest godoc
+//
+//	@summary        Read event schema
+//	@description    Read the latest JSON Schema of the payload of an event
+//	@tags           schemas
+//	@produce        json
+//	@param          event   path    string  true    "Event name"
+//	@success        200
+//	@failure        404 {object}    err.Problem
+//	@router         /schemas/{event} [get]
+func (api *API) Latest(w http.ResponseWriter, r *http.Request) error {
+	s, ok := api.registry.Latest(chi.URLParam(r, "event"))
+	if !ok {
+		return e.RespNotFound
+	}
+	write(w, s)
+	return nil
+}
+
+// Read godoc
+//
+//	@summary        Read event schema version
+//	@description    Read a version of the JSON Schema of the payload of an event
+//	@tags           schemas
+//	@produce        json
+//	@param          event   path    string  true    "Event name"
+//	@param          version path    int     true    "Schema version"
+//	@success        200
+//	@failure        404 {object}    err.Problem
+//	@router         /schemas/{event}/{version} [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		return e.RespNotFound
+	}
+
+	s, ok := api.registry.Get(chi.URLParam(r, "event"), version)
+	if !ok {
+		return e.RespNotFound
+	}
+	write(w, s)
+	return nil
+}
+
+func write(w http.ResponseWriter, s *schema.Schema) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Write(s.Document)
+}
@@ -0,0 +1,52 @@
+package schemas_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	e "hello/api/resource/common/err"
+	"hello/api/resource/schemas"
+	"hello/event/schema"
+	testUtil "hello/util/test"
+)
+
+func TestAPI(t *testing.T) {
+	t.Parallel()
+
+	registry, err := schema.Load()
+	testUtil.NoError(t, err)
+	api := schemas.New(registry)
+	r := chi.NewRouter()
+	r.Get("/schemas", e.Handle(api.List))
+	r.Get("/schemas/{event}", e.Handle(api.Latest))
+	r.Get("/schemas/{event}/{version}", e.Handle(api.Read))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get("/schemas")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var dtos []*schemas.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dtos))
+	testUtil.Equal(t, "book.created", dtos[0].Event)
+	testUtil.Equal(t, "/v1/schemas/book.created/1", dtos[0].URL)
+
+	for _, target := range []string{"/schemas/book.created", "/schemas/book.created/1"} {
+		w = get(target)
+		testUtil.Equal(t, http.StatusOK, w.Code)
+		testUtil.Equal(t, schemas.ContentType, w.Header().Get("Content-Type"))
+		testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"$id": "book.created.v1"`))
+	}
+
+	testUtil.Equal(t, http.StatusNotFound, get("/schemas/book.archived").Code)
+	testUtil.Equal(t, http.StatusNotFound, get("/schemas/book.created/2").Code)
+	testUtil.Equal(t, http.StatusNotFound, get("/schemas/book.created/v1").Code)
+}
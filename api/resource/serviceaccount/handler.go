@@ -0,0 +1,330 @@
+package serviceaccount
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+	"hello/api/middleware/warning"
+	e "hello/api/resource/common/err"
+	"hello/audit"
+	"hello/config"
+	validatorUtil "hello/util/validator"
+)
+
+// Audited actions.
+const (
+	ActionCreated = "service_account.created"
+	ActionDeleted = "service_account.deleted"
+	ActionRotated = "service_account.rotated"
+)
+
+// credentialPrefix marks bearer tokens that are service account
+// credentials, which read "sa.<account id>.<secret>".
+const credentialPrefix = "sa."
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	conf       *config.ConfServiceAccount
+}
+
+func New(db *gorm.DB, v *validator.Validate, c *config.ConfServiceAccount) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		conf:       c,
+	}
+}
+
+// List godoc
+//
+//	@summary        List service accounts
+//	@description    List the tenant's service accounts and their secrets, without the secrets themselves
+//	@tags           admin
+//	@produce        json
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/service-accounts [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	accounts, err := api.repository.WithContext(r.Context()).List(tenant.From(r.Context()))
+	if err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := json.NewEncoder(w).Encode(accounts.ToDto()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Create godoc
+//
+//	@summary        Create service account
+//	@description    Create a service account with the given scopes. The response carries its credential, which is not shown again.
+//	@tags           admin
+//	@accept         json
+//	@produce        json
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          body        body    Form    true    "Service account form"
+//	@success        201 {object}    CredentialDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/service-accounts [post]
+func (api *API) Create(w http.ResponseWriter, r *http.Request) error {
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	now := time.Now()
+	a := form.ToModel()
+	a.ID = uuid.New()
+	a.TenantID = tenant.From(r.Context())
+	a.CreatedAt = now
+	a.UpdatedAt = now
+
+	secret, s, err := api.newSecret(a.ID, now)
+	if err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	if err := api.repository.WithContext(r.Context()).Create(a, s); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return e.RespDuplicateServiceAccount
+		}
+
+		return e.RespDBDataInsertFailure
+	}
+	a.Secrets = []*Secret{s}
+
+	if err := audit.Record(r, ActionCreated, a.ID.String(), audit.Details{"name": a.Name, "scopes": a.Scopes}); err != nil {
+		return e.RespAuditFailure
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return api.writeCredential(w, a, secret, s)
+}
+
+// Delete godoc
+//
+//	@summary        Delete service account
+//	@description    Delete a service account, invalidating its credentials
+//	@tags           admin
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          id          path    string  true    "Service account ID"
+//	@success        200
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/service-accounts/{id} [delete]
+func (api *API) Delete(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	rows, err := api.repository.WithContext(r.Context()).Delete(tenant.From(r.Context()), id)
+	if err != nil {
+		return e.RespDBDataRemoveFailure
+	}
+	if rows == 0 {
+		return e.RespNotFound
+	}
+
+	if err := audit.Record(r, ActionDeleted, id.String(), nil); err != nil {
+		return e.RespAuditFailure
+	}
+	return nil
+}
+
+// Rotate godoc
+//
+//	@summary        Rotate service account secret
+//	@description    Issue a new credential. The previous one stays valid for the rotation grace period.
+//	@tags           admin
+//	@produce        json
+//	@param          X-Tenant-ID header  string  false   "Tenant"
+//	@param          id          path    string  true    "Service account ID"
+//	@success        201 {object}    CredentialDTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/service-accounts/{id}/rotate [post]
+func (api *API) Rotate(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	return api.rotate(w, r, id)
+}
+
+// RotateSelf godoc
+//
+//	@summary        Rotate own secret
+//	@description    Issue a new credential for the calling service account, so clients can rotate on a schedule. The previous one stays valid for the rotation grace period.
+//	@tags           service-accounts
+//	@produce        json
+//	@param          Authorization   header  string  true    "Bearer credential"
+//	@success        201 {object}    CredentialDTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /service-accounts/self/rotate [post]
+func (api *API) RotateSelf(w http.ResponseWriter, r *http.Request) error {
+	id, ok := user.Service(r.Context())
+	if !ok {
+		return e.RespAuthenticationRequired
+	}
+
+	return api.rotate(w, r, id)
+}
+
+func (api *API) rotate(w http.ResponseWriter, r *http.Request, id uuid.UUID) error {
+	a, err := api.repository.WithContext(r.Context()).Read(tenant.From(r.Context()), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.RespNotFound
+		}
+
+		return e.RespDBDataAccessFailure
+	}
+
+	now := time.Now()
+	secret, s, err := api.newSecret(a.ID, now)
+	if err != nil {
+		return e.RespDBDataInsertFailure
+	}
+
+	if err := api.repository.WithContext(r.Context()).Rotate(s, now, now.Add(api.conf.RotationGrace)); err != nil {
+		return e.RespDBDataUpdateFailure
+	}
+
+	if a, err = api.repository.WithContext(r.Context()).Read(a.TenantID, a.ID); err != nil {
+		return e.RespDBDataAccessFailure
+	}
+
+	if err := audit.Record(r, ActionRotated, a.ID.String(), audit.Details{"secret_id": s.ID.String()}); err != nil {
+		return e.RespAuditFailure
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return api.writeCredential(w, a, secret, s)
+}
+
+// Middleware authenticates requests bearing a service account credential,
+// acting in the account's tenant with its scopes in place of those of the
+// headers. Other requests pass untouched. A credential within the rotation
+// grace period of its expiry draws a warning.
+func (api *API) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(token, credentialPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, secret, ok := parseCredential(token)
+		if !ok {
+			e.Unauthorized(w, e.RespInvalidCredential)
+			return
+		}
+
+		now := time.Now()
+		a, s, err := api.repository.WithContext(r.Context()).Authenticate(id, hashSecret(secret), now)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				e.Unauthorized(w, e.RespInvalidCredential)
+				return
+			}
+
+			e.ServerError(w, e.RespDBDataAccessFailure)
+			return
+		}
+
+		if s.ExpiresAt != nil && s.ExpiresAt.Before(now.Add(api.conf.RotationGrace)) {
+			warning.Add(r, warning.CodeCredentialExpiring,
+				fmt.Sprintf("credential expires at %s; rotate it", s.ExpiresAt.UTC().Format(time.RFC3339)))
+		}
+
+		ctx := tenant.With(r.Context(), a.TenantID)
+		ctx = scope.With(ctx, a.ScopeList())
+		ctx = user.WithService(ctx, a.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (api *API) newSecret(accountID uuid.UUID, now time.Time) (string, *Secret, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	s := &Secret{
+		ID:               uuid.New(),
+		ServiceAccountID: accountID,
+		SecretHash:       hashSecret(secret),
+		CreatedAt:        now,
+	}
+	if api.conf.SecretMaxAge > 0 {
+		expiresAt := now.Add(api.conf.SecretMaxAge)
+		s.ExpiresAt = &expiresAt
+	}
+	return secret, s, nil
+}
+
+func (api *API) writeCredential(w http.ResponseWriter, a *Account, secret string, s *Secret) error {
+	dto := &CredentialDTO{
+		Account:    a.ToDto(),
+		Credential: credentialPrefix + a.ID.String() + "." + secret,
+		ExpiresAt:  s.ExpiresAt,
+	}
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+func parseCredential(token string) (uuid.UUID, string, bool) {
+	rest := strings.TrimPrefix(token, credentialPrefix)
+	idPart, secret, ok := strings.Cut(rest, ".")
+	if !ok || secret == "" {
+		return uuid.Nil, "", false
+	}
+
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+	return id, secret, true
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+package serviceaccount_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/serviceaccount"
+	"hello/config"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestAPI_Rotate(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &serviceaccount.Account{}, &serviceaccount.Secret{})
+
+	api := serviceaccount.New(db, validatorUtil.New(), &config.ConfServiceAccount{RotationGrace: time.Hour})
+
+	r := chi.NewRouter()
+	r.Use(tenant.Middleware, scope.Middleware, api.Middleware)
+	r.Post("/admin/service-accounts", e.Handle(api.Create))
+	r.Post("/admin/service-accounts/{id}/rotate", e.Handle(api.Rotate))
+	r.Post("/service-accounts/self/rotate", e.Handle(api.RotateSelf))
+	r.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tenant.From(r.Context()) + " " + strings.Join(scope.From(r.Context()), ",")))
+	})
+
+	serve := func(method, target, credential, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(tenant.Header, "acme")
+		if credential != "" {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	credential := func(w *httptest.ResponseRecorder) *serviceaccount.CredentialDTO {
+		testUtil.Equal(t, http.StatusCreated, w.Code)
+
+		var dto serviceaccount.CredentialDTO
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dto))
+		return &dto
+	}
+
+	first := credential(serve(http.MethodPost, "/admin/service-accounts", "", `{"name": "ci", "scopes": ["books:write"]}`))
+
+	w := serve(http.MethodGet, "/whoami", first.Credential, "")
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "acme books:write", w.Body.String())
+
+	second := credential(serve(http.MethodPost, "/admin/service-accounts/"+first.Account.ID+"/rotate", "", ""))
+	testUtil.Equal(t, 2, len(second.Account.Secrets))
+
+	// Both secrets are valid during the grace period.
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodGet, "/whoami", first.Credential, "").Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodGet, "/whoami", second.Credential, "").Code)
+
+	third := credential(serve(http.MethodPost, "/service-accounts/self/rotate", second.Credential, ""))
+
+	tests := []struct {
+		name       string
+		credential string
+		status     int
+	}{
+		{"oldest", first.Credential, http.StatusUnauthorized},
+		{"previous", second.Credential, http.StatusOK},
+		{"current", third.Credential, http.StatusOK},
+		{"malformed", "sa.nope", http.StatusUnauthorized},
+		{"other bearer token", "opaque", http.StatusOK},
+	}
+	for _, tc := range tests {
+		w := serve(http.MethodGet, "/whoami", tc.credential, "")
+		testUtil.Equal(t, tc.status, w.Code)
+	}
+
+	testUtil.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/service-accounts/self/rotate", "", "").Code)
+}
@@ -0,0 +1,112 @@
+package serviceaccount
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DTO struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Scopes    []string     `json:"scopes"`
+	Secrets   []*SecretDTO `json:"secrets"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// SecretDTO describes a secret without revealing it.
+type SecretDTO struct {
+	ID         string     `json:"id"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CredentialDTO carries a new credential. It is only ever shown once.
+type CredentialDTO struct {
+	Account    *DTO       `json:"account"`
+	Credential string     `json:"credential"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+type Form struct {
+	Name   string   `json:"name" validate:"required,max=255"`
+	Scopes []string `json:"scopes" validate:"omitempty,dive,required,max=64,excludesall=0x2C0x20"`
+}
+
+// Account is a non-human identity, such as a CI system, acting within a
+// tenant with fixed scopes.
+type Account struct {
+	ID       uuid.UUID `gorm:"primarykey"`
+	TenantID string
+	Name     string
+	// Scopes is comma-separated, like the X-Scopes header.
+	Scopes    string
+	Secrets   []*Secret `gorm:"foreignKey:ServiceAccountID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Account) TableName() string {
+	return "service_accounts"
+}
+
+// Secret is one of an account's credentials. Only its SHA-256 is stored.
+// Secrets without an expiry are current; a rotation gives the previous ones
+// an expiry so that clients can switch over.
+type Secret struct {
+	ID               uuid.UUID `gorm:"primarykey"`
+	ServiceAccountID uuid.UUID
+	SecretHash       string
+	ExpiresAt        *time.Time
+	LastUsedAt       *time.Time
+	CreatedAt        time.Time
+}
+
+func (Secret) TableName() string {
+	return "service_account_secrets"
+}
+
+type Accounts []*Account
+
+func (a *Account) ScopeList() []string {
+	if a.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(a.Scopes, ",")
+}
+
+func (a *Account) ToDto() *DTO {
+	dto := &DTO{
+		ID:        a.ID.String(),
+		Name:      a.Name,
+		Scopes:    a.ScopeList(),
+		Secrets:   make([]*SecretDTO, len(a.Secrets)),
+		CreatedAt: a.CreatedAt,
+	}
+	for i, s := range a.Secrets {
+		dto.Secrets[i] = &SecretDTO{
+			ID:         s.ID.String(),
+			ExpiresAt:  s.ExpiresAt,
+			LastUsedAt: s.LastUsedAt,
+			CreatedAt:  s.CreatedAt,
+		}
+	}
+	return dto
+}
+
+func (as Accounts) ToDto() []*DTO {
+	dtos := make([]*DTO, len(as))
+	for i, a := range as {
+		dtos[i] = a.ToDto()
+	}
+	return dtos
+}
+
+func (f *Form) ToModel() *Account {
+	return &Account{
+		Name:   f.Name,
+		Scopes: strings.Join(f.Scopes, ","),
+	}
+}
@@ -0,0 +1,119 @@
+package serviceaccount
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often a secret's last use is written.
+const lastUsedResolution = time.Minute
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+func (r *Repository) List(tenantID string) (Accounts, error) {
+	accounts := make([]*Account, 0)
+	if err := r.db.Preload("Secrets", orderSecrets).Where("tenant_id = ?", tenantID).Order("name").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (r *Repository) Read(tenantID string, id uuid.UUID) (*Account, error) {
+	a := &Account{}
+	if err := r.db.Preload("Secrets", orderSecrets).Where("tenant_id = ? AND id = ?", tenantID, id).First(a).Error; err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Create stores a with its first secret.
+func (r *Repository) Create(a *Account, s *Secret) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Secrets").Create(a).Error; err != nil {
+			return err
+		}
+		return tx.Create(s).Error
+	})
+}
+
+func (r *Repository) Delete(tenantID string, id uuid.UUID) (int64, error) {
+	result := r.db.Where("tenant_id = ? AND id = ?", tenantID, id).Delete(&Account{})
+	return result.RowsAffected, result.Error
+}
+
+// Rotate adds s to its account as the current secret. The previous current
+// secret stays valid until graceUntil, and any older ones expire now, so
+// that at most two secrets are ever valid.
+func (r *Repository) Rotate(s *Secret, now, graceUntil time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		current := &Secret{}
+		err := tx.Where("service_account_id = ? AND (expires_at IS NULL OR expires_at > ?)", s.ServiceAccountID, now).
+			Order("created_at DESC").First(current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil {
+			if err := tx.Model(&Secret{}).
+				Where("service_account_id = ? AND id <> ? AND (expires_at IS NULL OR expires_at > ?)", s.ServiceAccountID, current.ID, now).
+				Update("expires_at", now).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&Secret{}).
+				Where("id = ? AND (expires_at IS NULL OR expires_at > ?)", current.ID, graceUntil).
+				Update("expires_at", graceUntil).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&Account{}).Where("id = ?", s.ServiceAccountID).Update("updated_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(s).Error
+	})
+}
+
+// Authenticate returns the account and secret of an unexpired secret, or
+// gorm.ErrRecordNotFound, and records the secret's use.
+func (r *Repository) Authenticate(id uuid.UUID, secretHash string, now time.Time) (*Account, *Secret, error) {
+	s := &Secret{}
+	if err := r.db.Where("service_account_id = ? AND secret_hash = ? AND (expires_at IS NULL OR expires_at > ?)", id, secretHash, now).
+		First(s).Error; err != nil {
+		return nil, nil, err
+	}
+
+	a := &Account{}
+	if err := r.db.Where("id = ?", id).First(a).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if s.LastUsedAt == nil || s.LastUsedAt.Before(now.Add(-lastUsedResolution)) {
+		if err := r.db.Model(s).Update("last_used_at", now).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return a, s, nil
+}
+
+func orderSecrets(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at DESC")
+}
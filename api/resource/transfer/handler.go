@@ -0,0 +1,326 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/api/middleware/user"
+	e "hello/api/resource/common/err"
+	"hello/idcodec"
+	"hello/mail"
+	"hello/util/sanitizer"
+)
+
+// mailTimeout bounds sending a notification within a request.
+const mailTimeout = 10 * time.Second
+
+type API struct {
+	repository *Repository
+	validator  *validator.Validate
+	mailer     mail.Sender
+	now        func() time.Time
+}
+
+// Problems maps the errors of transfers to responses, for err.Middleware.
+var Problems = e.Mappers(
+	e.Is(ErrCopyNotFound, e.RespNotFound),
+	e.Is(ErrSelfTransfer, e.RespSelfTransfer),
+	e.Is(ErrPending, e.RespTransferPending),
+	e.Is(ErrStatusChanged, e.RespTransferClosed),
+	e.Is(ErrOwnerChanged, e.RespCopyChangedHands),
+	e.Is(ErrUnknownUser, e.RespUnknownRecipient),
+)
+
+func New(db *gorm.DB, v *validator.Validate, mailer mail.Sender) *API {
+	return &API{
+		repository: NewRepository(db),
+		validator:  v,
+		mailer:     mailer,
+		now:        time.Now,
+	}
+}
+
+// Copies godoc
+//
+//	@summary        List copies
+//	@description    List the copies of books the signed-in user owns, most recently acquired first
+//	@tags           transfers
+//	@produce        json
+//	@success        200 {array}     CopyDTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /copies [get]
+func (api *API) Copies(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	copies, err := api.repository.WithContext(r.Context()).Copies(userID)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(copies.ToDto())
+}
+
+// Offer godoc
+//
+//	@summary        Offer copy
+//	@description    Offer a copy the signed-in user owns to another registered user, who becomes its owner on accepting. Both are notified
+//	@tags           transfers
+//	@accept         json
+//	@produce        json
+//	@param          id      path    string  true    "Copy ID"
+//	@param          body    body    Form    true    "Transfer form"
+//	@success        201 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /copies/{id}/transfers [post]
+func (api *API) Offer(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	copyID, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return e.RespInvalidURLParamID
+	}
+
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+	sanitizer.Struct(form)
+	if err := api.validator.Struct(form); err != nil {
+		return err
+	}
+
+	repository := api.repository.WithContext(r.Context())
+	to := uuid.MustParse(form.To)
+	if _, err := repository.Email(to); err != nil {
+		return err
+	}
+
+	t := &Transfer{
+		ID:         uuid.New(),
+		CopyID:     copyID,
+		FromUserID: userID,
+		ToUserID:   to,
+		Status:     StatusPending,
+		Note:       form.Note,
+		CreatedAt:  api.now(),
+	}
+	if err := repository.Offer(t); err != nil {
+		return err
+	}
+
+	api.notify(r.Context(), t, "A book is offered to you",
+		"A copy of a book has been offered to you. Accept or decline transfer %s in your account.\n")
+
+	w.Header().Set("Location", "/v1/transfers/"+idcodec.Encode(t.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t.ToDto())
+	return nil
+}
+
+// List godoc
+//
+//	@summary        List transfers
+//	@description    List the transfers the signed-in user sent or received, newest first
+//	@tags           transfers
+//	@produce        json
+//	@success        200 {array}     DTO
+//	@failure        401 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /transfers [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	userID, err := caller(r)
+	if err != nil {
+		return err
+	}
+
+	transfers, err := api.repository.WithContext(r.Context()).List(userID)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(transfers.ToDto())
+}
+
+// Read godoc
+//
+//	@summary        Read transfer
+//	@description    Read a transfer the signed-in user is a party to, with its audit trail
+//	@tags           transfers
+//	@produce        json
+//	@param          id  path    string  true    "Transfer ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /transfers/{id} [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	userID, t, err := api.transfer(r)
+	if err != nil {
+		return err
+	}
+	if userID != t.FromUserID && userID != t.ToUserID {
+		return e.RespNotFound
+	}
+
+	events, err := api.repository.WithContext(r.Context()).Events(t.ID)
+	if err != nil {
+		return err
+	}
+
+	dto := t.ToDto()
+	dto.Events = events.ToDto()
+	return json.NewEncoder(w).Encode(dto)
+}
+
+// Accept godoc
+//
+//	@summary        Accept transfer
+//	@description    Accept a transfer offered to the signed-in user, who becomes the copy's owner. Both parties are notified
+//	@tags           transfers
+//	@produce        json
+//	@param          id  path    string  true    "Transfer ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /transfers/{id}/accept [post]
+func (api *API) Accept(w http.ResponseWriter, r *http.Request) error {
+	return api.respond(w, r, StatusAccepted, false)
+}
+
+// Decline godoc
+//
+//	@summary        Decline transfer
+//	@description    Decline a transfer offered to the signed-in user. Both parties are notified
+//	@tags           transfers
+//	@produce        json
+//	@param          id  path    string  true    "Transfer ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /transfers/{id}/decline [post]
+func (api *API) Decline(w http.ResponseWriter, r *http.Request) error {
+	return api.respond(w, r, StatusDeclined, false)
+}
+
+// Cancel godoc
+//
+//	@summary        Cancel transfer
+//	@description    Withdraw a transfer the signed-in user offered. Both parties are notified
+//	@tags           transfers
+//	@produce        json
+//	@param          id  path    string  true    "Transfer ID"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        401 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        409 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /transfers/{id}/cancel [post]
+func (api *API) Cancel(w http.ResponseWriter, r *http.Request) error {
+	return api.respond(w, r, StatusCancelled, true)
+}
+
+// respond closes the transfer in the URL with status. The sender may only
+// cancel it, and the recipient only accept or decline it.
+func (api *API) respond(w http.ResponseWriter, r *http.Request, status string, bySender bool) error {
+	userID, t, err := api.transfer(r)
+	if err != nil {
+		return err
+	}
+	party := t.ToUserID
+	if bySender {
+		party = t.FromUserID
+	}
+	if userID != party {
+		return e.RespNotFound
+	}
+
+	if err := api.repository.WithContext(r.Context()).Respond(t, status, userID, api.now()); err != nil {
+		return err
+	}
+
+	api.notify(r.Context(), t, "A book transfer was "+status, "Transfer %s was "+status+".\n")
+
+	return json.NewEncoder(w).Encode(t.ToDto())
+}
+
+// transfer reads the transfer in the URL for the signed-in user.
+func (api *API) transfer(r *http.Request) (uuid.UUID, *Transfer, error) {
+	userID, err := caller(r)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	id, err := idcodec.Decode(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, nil, e.RespInvalidURLParamID
+	}
+
+	t, err := api.repository.WithContext(r.Context()).Read(id)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	return userID, t, nil
+}
+
+// notify mails both parties of a transfer, skipping those without a
+// registered address. The change is committed by then, so failures are only
+// logged.
+func (api *API) notify(ctx context.Context, t *Transfer, subject, format string) {
+	body := fmt.Sprintf(format, idcodec.Encode(t.ID))
+	for _, id := range []uuid.UUID{t.FromUserID, t.ToUserID} {
+		to, err := api.repository.WithContext(ctx).Email(id)
+		if err != nil {
+			if !errors.Is(err, ErrUnknownUser) {
+				log.Printf("transfer %s notification: %s", t.ID, err)
+			}
+			continue
+		}
+
+		mctx, cancel := context.WithTimeout(ctx, mailTimeout)
+		err = api.mailer.Send(mctx, &mail.Message{To: to, Subject: subject, Body: body})
+		cancel()
+		if err != nil {
+			log.Printf("transfer %s notification to %s: %s", t.ID, to, err)
+		}
+	}
+}
+
+// caller returns the signed-in user, or RespAuthenticationRequired without
+// one. Copies belong to users, so service accounts have none.
+func caller(r *http.Request) (uuid.UUID, error) {
+	id, ok := user.From(r.Context())
+	if !ok {
+		return uuid.Nil, e.RespAuthenticationRequired
+	}
+	return id, nil
+}
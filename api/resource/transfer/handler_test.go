@@ -0,0 +1,137 @@
+package transfer_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/api/resource/auth"
+	"hello/api/resource/book"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/order"
+	"hello/api/resource/transfer"
+	"hello/mail"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+type outbox struct {
+	mu sync.Mutex
+	to []string
+}
+
+func (o *outbox) Send(_ context.Context, m *mail.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.to = append(o.to, m.To)
+	return nil
+}
+
+func (o *outbox) drain() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	to := o.to
+	o.to = nil
+	return to
+}
+
+func TestAPI_Transfers(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &book.Book{}, &auth.User{}, &order.Copy{}, &transfer.Transfer{}, &transfer.Event{})
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	testUtil.NoError(t, db.Create([]*auth.User{{ID: alice, Email: "alice@example.com"}, {ID: bob, Email: "bob@example.com"}}).Error)
+	b := &book.Book{ID: uuid.New(), Title: "Dune"}
+	testUtil.NoError(t, db.Create(b).Error)
+	c := &order.Copy{ID: uuid.New(), BookID: b.ID, OwnerID: alice, AcquiredAt: time.Now()}
+	testUtil.NoError(t, db.Create(c).Error)
+
+	out := &outbox{}
+	api := transfer.New(db, validatorUtil.New(), out)
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	r.Use(e.Middleware(validatorUtil.Mapper, transfer.Problems))
+	r.Get("/copies", e.Handle(api.Copies))
+	r.Post("/copies/{id}/transfers", e.Handle(api.Offer))
+	r.Get("/transfers", e.Handle(api.List))
+	r.Get("/transfers/{id}", e.Handle(api.Read))
+	r.Post("/transfers/{id}/accept", e.Handle(api.Accept))
+	r.Post("/transfers/{id}/decline", e.Handle(api.Decline))
+	r.Post("/transfers/{id}/cancel", e.Handle(api.Cancel))
+
+	serve := func(method, target, body string, as uuid.UUID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(user.Header, as.String())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	offer := func(to uuid.UUID, as uuid.UUID) (int, string) {
+		w := serve(http.MethodPost, "/copies/"+c.ID.String()+"/transfers", `{"to": "`+to.String()+`", "note": "Enjoy"}`, as)
+		var dto transfer.DTO
+		json.Unmarshal(w.Body.Bytes(), &dto)
+		return w.Code, dto.ID
+	}
+	owned := func(as uuid.UUID) int {
+		var copies []*transfer.CopyDTO
+		testUtil.NoError(t, json.Unmarshal(serve(http.MethodGet, "/copies", "", as).Body.Bytes(), &copies))
+		return len(copies)
+	}
+
+	testUtil.Equal(t, 1, owned(alice))
+
+	code, _ := offer(carol, alice)
+	testUtil.Equal(t, http.StatusBadRequest, code)
+	code, _ = offer(alice, alice)
+	testUtil.Equal(t, http.StatusBadRequest, code)
+	code, _ = offer(alice, bob)
+	testUtil.Equal(t, http.StatusNotFound, code)
+
+	code, declined := offer(bob, alice)
+	testUtil.Equal(t, http.StatusCreated, code)
+	testUtil.Equal(t, "alice@example.com bob@example.com", strings.Join(out.drain(), " "))
+	code, _ = offer(bob, alice)
+	testUtil.Equal(t, http.StatusConflict, code)
+
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/transfers/"+declined+"/accept", "", alice).Code)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/transfers/"+declined+"/cancel", "", bob).Code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPost, "/transfers/"+declined+"/decline", "", bob).Code)
+	testUtil.Equal(t, http.StatusConflict, serve(http.MethodPost, "/transfers/"+declined+"/accept", "", bob).Code)
+	testUtil.Equal(t, "alice@example.com bob@example.com", strings.Join(out.drain(), " "))
+
+	code, accepted := offer(bob, alice)
+	testUtil.Equal(t, http.StatusCreated, code)
+	testUtil.Equal(t, http.StatusOK, serve(http.MethodPost, "/transfers/"+accepted+"/accept", "", bob).Code)
+	testUtil.Equal(t, 0, owned(alice))
+	testUtil.Equal(t, 1, owned(bob))
+
+	var read transfer.DTO
+	w := serve(http.MethodGet, "/transfers/"+accepted, "", alice)
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &read))
+	testUtil.Equal(t, transfer.StatusAccepted, read.Status)
+	testUtil.Equal(t, 2, len(read.Events))
+	testUtil.Equal(t, transfer.ActionOffered, read.Events[0].Action)
+	testUtil.Equal(t, transfer.ActionAccepted, read.Events[1].Action)
+	testUtil.Equal(t, bob.String(), read.Events[1].Actor)
+	testUtil.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/transfers/"+accepted, "", carol).Code)
+
+	var list []*transfer.DTO
+	testUtil.NoError(t, json.Unmarshal(serve(http.MethodGet, "/transfers", "", bob).Body.Bytes(), &list))
+	testUtil.Equal(t, 2, len(list))
+
+	// Alice gave the copy away, so she can no longer offer it.
+	code, _ = offer(bob, alice)
+	testUtil.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,144 @@
+package transfer
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/idcodec"
+)
+
+// Transfer statuses. A transfer is pending until the recipient accepts or
+// declines it or the sender cancels it.
+const (
+	StatusPending   = "pending"
+	StatusAccepted  = "accepted"
+	StatusDeclined  = "declined"
+	StatusCancelled = "cancelled"
+)
+
+// Audit actions, one per change of a transfer.
+const (
+	ActionOffered   = "offered"
+	ActionAccepted  = "accepted"
+	ActionDeclined  = "declined"
+	ActionCancelled = "cancelled"
+)
+
+type CopyDTO struct {
+	ID         string    `json:"id"`
+	BookID     string    `json:"book_id"`
+	Title      string    `json:"title"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+type DTO struct {
+	ID          string     `json:"id"`
+	CopyID      string     `json:"copy_id"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	Status      string     `json:"status"`
+	Note        string     `json:"note,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+
+	// Events is the audit trail, included when reading one transfer.
+	Events []*EventDTO `json:"events,omitempty"`
+}
+
+type EventDTO struct {
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	At     time.Time `json:"at"`
+}
+
+type Form struct {
+	To   string `json:"to" validate:"required,uuid"`
+	Note string `json:"note" validate:"max=500" sanitize:"singleline"`
+}
+
+// OwnedCopy is a copy of a book together with the book's title.
+type OwnedCopy struct {
+	ID         uuid.UUID
+	BookID     uuid.UUID
+	Title      string
+	AcquiredAt time.Time
+}
+
+type OwnedCopies []*OwnedCopy
+
+// Transfer is an offer to hand a copy to another user, who becomes its
+// owner on accepting.
+type Transfer struct {
+	ID          uuid.UUID `gorm:"primarykey"`
+	CopyID      uuid.UUID
+	FromUserID  uuid.UUID
+	ToUserID    uuid.UUID
+	Status      string
+	Note        string
+	CreatedAt   time.Time
+	RespondedAt *time.Time
+}
+
+type Transfers []*Transfer
+
+// Event is the audit record of one change of a transfer.
+type Event struct {
+	ID         uuid.UUID `gorm:"primarykey"`
+	TransferID uuid.UUID
+	ActorID    uuid.UUID
+	Action     string
+	At         time.Time
+}
+
+func (Event) TableName() string {
+	return "transfer_events"
+}
+
+type Events []*Event
+
+func (c *OwnedCopy) ToDto() *CopyDTO {
+	return &CopyDTO{
+		ID:         idcodec.Encode(c.ID),
+		BookID:     idcodec.Encode(c.BookID),
+		Title:      c.Title,
+		AcquiredAt: c.AcquiredAt,
+	}
+}
+
+func (cs OwnedCopies) ToDto() []*CopyDTO {
+	dtos := make([]*CopyDTO, len(cs))
+	for i, v := range cs {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
+
+func (t *Transfer) ToDto() *DTO {
+	return &DTO{
+		ID:          idcodec.Encode(t.ID),
+		CopyID:      idcodec.Encode(t.CopyID),
+		From:        t.FromUserID.String(),
+		To:          t.ToUserID.String(),
+		Status:      t.Status,
+		Note:        t.Note,
+		CreatedAt:   t.CreatedAt,
+		RespondedAt: t.RespondedAt,
+	}
+}
+
+func (ts Transfers) ToDto() []*DTO {
+	dtos := make([]*DTO, len(ts))
+	for i, v := range ts {
+		dtos[i] = v.ToDto()
+	}
+	return dtos
+}
+
+func (es Events) ToDto() []*EventDTO {
+	dtos := make([]*EventDTO, len(es))
+	for i, v := range es {
+		dtos[i] = &EventDTO{Actor: v.ActorID.String(), Action: v.Action, At: v.At}
+	}
+	return dtos
+}
@@ -0,0 +1,172 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"hello/api/resource/order"
+)
+
+var (
+	ErrPending       = errors.New("transfer: copy already has a pending transfer")
+	ErrStatusChanged = errors.New("transfer: no longer pending")
+	ErrOwnerChanged  = errors.New("transfer: copy changed hands")
+	ErrUnknownUser   = errors.New("transfer: unknown user")
+	ErrSelfTransfer  = errors.New("transfer: sender and recipient are the same")
+	ErrCopyNotFound  = errors.New("transfer: copy not found")
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// WithContext returns a repository whose queries run in ctx.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
+// Copies returns the copies a user owns, most recently acquired first.
+func (r *Repository) Copies(ownerID uuid.UUID) (OwnedCopies, error) {
+	copies := make([]*OwnedCopy, 0)
+	err := r.db.Table("copies").
+		Select("copies.id, copies.book_id, books.title, copies.acquired_at").
+		Joins("JOIN books ON books.id = copies.book_id").
+		Where("copies.owner_id = ?", ownerID).
+		Order("copies.acquired_at DESC, copies.id").
+		Scan(&copies).Error
+	if err != nil {
+		return nil, err
+	}
+	return copies, nil
+}
+
+// Email returns the address of a registered user.
+func (r *Repository) Email(userID uuid.UUID) (string, error) {
+	var emails []string
+	if err := r.db.Table("users").Where("id = ?", userID).Pluck("email", &emails).Error; err != nil {
+		return "", err
+	}
+	if len(emails) == 0 {
+		return "", ErrUnknownUser
+	}
+	return emails[0], nil
+}
+
+// Offer records a pending transfer of a copy its sender owns. A copy has at
+// most one pending transfer at a time.
+func (r *Repository) Offer(t *Transfer) error {
+	if t.FromUserID == t.ToUserID {
+		return ErrSelfTransfer
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&order.Copy{}).Where("id = ? AND owner_id = ?", t.CopyID, t.FromUserID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrCopyNotFound
+		}
+
+		if err := tx.Model(&Transfer{}).Where("copy_id = ? AND status = ?", t.CopyID, StatusPending).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrPending
+		}
+
+		// A concurrent offer of the copy trips the unique index on pending
+		// transfers.
+		if err := tx.Create(t).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrPending
+			}
+			return err
+		}
+		return tx.Create(&Event{ID: uuid.New(), TransferID: t.ID, ActorID: t.FromUserID, Action: ActionOffered, At: t.CreatedAt}).Error
+	})
+}
+
+// List returns the transfers a user sent or received, newest first.
+func (r *Repository) List(userID uuid.UUID) (Transfers, error) {
+	transfers := make([]*Transfer, 0)
+	err := r.db.Where("from_user_id = ? OR to_user_id = ?", userID, userID).
+		Order("created_at DESC").Find(&transfers).Error
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (r *Repository) Read(id uuid.UUID) (*Transfer, error) {
+	t := &Transfer{}
+	if err := r.db.Where("id = ?", id).First(t).Error; err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Events returns the audit trail of a transfer, oldest first.
+func (r *Repository) Events(transferID uuid.UUID) (Events, error) {
+	events := make([]*Event, 0)
+	if err := r.db.Where("transfer_id = ?", transferID).Order("at, id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Respond closes a pending transfer with status, as done by actor. Accepting
+// hands the copy to the recipient, provided the sender still owns it. It
+// returns ErrStatusChanged when the transfer was closed since it was read.
+func (r *Repository) Respond(t *Transfer, status string, actor uuid.UUID, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Transfer{}).
+			Where("id = ? AND status = ?", t.ID, StatusPending).
+			Updates(map[string]any{"status": status, "responded_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+
+		if status == StatusAccepted {
+			result := tx.Model(&order.Copy{}).
+				Where("id = ? AND owner_id = ?", t.CopyID, t.FromUserID).
+				Updates(map[string]any{"owner_id": t.ToUserID, "acquired_at": now})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrOwnerChanged
+			}
+		}
+
+		if err := tx.Create(&Event{ID: uuid.New(), TransferID: t.ID, ActorID: actor, Action: actions[status], At: now}).Error; err != nil {
+			return err
+		}
+
+		t.Status, t.RespondedAt = status, &now
+		return nil
+	})
+}
+
+var actions = map[string]string{
+	StatusAccepted:  ActionAccepted,
+	StatusDeclined:  ActionDeclined,
+	StatusCancelled: ActionCancelled,
+}
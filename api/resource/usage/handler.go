@@ -0,0 +1,37 @@
+package usage
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "hello/api/resource/common/err"
+	"hello/telemetry"
+)
+
+// API shows the telemetry report being collected, so operators can see
+// exactly what is sent.
+type API struct {
+	reporter *telemetry.Reporter
+}
+
+func New(reporter *telemetry.Reporter) *API {
+	return &API{
+		reporter: reporter,
+	}
+}
+
+// Read godoc
+//
+//	@summary        Preview telemetry
+//	@description    The anonymous usage report collected so far in the current period
+//	@tags           admin
+//	@produce        json
+//	@success        200 {object}    telemetry.Report
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/telemetry [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	if err := json.NewEncoder(w).Encode(api.reporter.Report()); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "hello/api/resource/common/err"
+	"hello/buildinfo"
+)
+
+type DTO struct {
+	buildinfo.Info
+	// Latest is the latest release in the configured feed, when checked.
+	Latest          string `json:"latest,omitempty"`
+	UpdateAvailable bool   `json:"update_available,omitempty"`
+}
+
+type API struct {
+	releases *buildinfo.ReleaseChecker
+}
+
+// New returns the version API. releases is nil when no release feed is
+// configured.
+func New(releases *buildinfo.ReleaseChecker) *API {
+	return &API{
+		releases: releases,
+	}
+}
+
+// Read godoc
+//
+//	@summary        Read version
+//	@description    Version, commit and build date of the running build
+//	@tags           health
+//	@produce        json
+//	@success        200 {object}    DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /../version [get]
+func (api *API) Read(w http.ResponseWriter, r *http.Request) error {
+	dto := &DTO{Info: buildinfo.Get()}
+	if api.releases != nil {
+		dto.Latest = api.releases.Latest()
+		dto.UpdateAvailable = dto.Latest != "" && buildinfo.Outdated(dto.Version, dto.Latest)
+	}
+
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package workers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	e "hello/api/resource/common/err"
+	validatorUtil "hello/util/validator"
+	"hello/worker"
+)
+
+var RespInvalidSize = e.New(http.StatusBadRequest, "invalid_pool_size", "invalid pool size")
+
+// API shows the background worker pools of the instance and resizes them
+// at runtime, so that a backlog can be worked off without a restart. Sizes
+// set here last until the instance restarts.
+type API struct {
+	pools     *worker.Registry
+	validator *validator.Validate
+}
+
+func New(pools *worker.Registry, v *validator.Validate) *API {
+	return &API{
+		pools:     pools,
+		validator: v,
+	}
+}
+
+// List godoc
+//
+//	@summary        List worker pools
+//	@description    Workers, busy workers, queue depth and average wait and processing times of each background worker pool of the instance, and for the job runner the jobs of each type enqueued, succeeded, failed, retried and abandoned
+//	@tags           admin
+//	@produce        json
+//	@success        200 {array}     DTO
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/workers [get]
+func (api *API) List(w http.ResponseWriter, r *http.Request) error {
+	names := api.pools.Names()
+	dtos := make([]*DTO, 0, len(names))
+	for _, name := range names {
+		if p, ok := api.pools.Get(name); ok {
+			dtos = append(dtos, toDto(name, p))
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
+
+// Resize godoc
+//
+//	@summary        Resize worker pool
+//	@description    Set the number of workers of a pool until the instance restarts. Work running on workers beyond the new size completes first
+//	@tags           admin
+//	@accept         json
+//	@produce        json
+//	@param          name    path    string  true    "Pool name"
+//	@param          body    body    Form    true    "Pool size"
+//	@success        200 {object}    DTO
+//	@failure        400 {object}    err.Problem
+//	@failure        404 {object}    err.Problem
+//	@failure        422 {object}    err.Problem
+//	@failure        500 {object}    err.Problem
+//	@router         /admin/workers/{name} [put]
+func (api *API) Resize(w http.ResponseWriter, r *http.Request) error {
+	name := chi.URLParam(r, "name")
+	p, ok := api.pools.Get(name)
+	if !ok {
+		return e.RespNotFound
+	}
+
+	form := &Form{}
+	if err := json.NewDecoder(r.Body).Decode(form); err != nil {
+		return e.RespJSONDecodeFailure
+	}
+
+	if err := api.validator.Struct(form); err != nil {
+		return e.RespValidationFailed.WithErrors(validatorUtil.ToFieldErrors(err, r))
+	}
+
+	before := p.Stats().Workers
+	if err := p.Resize(form.Workers); err != nil {
+		return RespInvalidSize
+	}
+	log.Printf("Worker pool %s resized from %d to %d", name, before, form.Workers)
+
+	if err := json.NewEncoder(w).Encode(toDto(name, p)); err != nil {
+		return e.RespJSONEncodeFailure
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package workers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	e "hello/api/resource/common/err"
+	"hello/api/resource/workers"
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+	"hello/worker"
+)
+
+type pool struct {
+	size int
+}
+
+func (p *pool) Stats() worker.Stats {
+	return worker.Stats{Workers: p.size, Queued: 7, Capacity: 64}
+}
+
+func (p *pool) Resize(n int) error {
+	if err := worker.Validate(n); err != nil {
+		return err
+	}
+	p.size = n
+	return nil
+}
+
+func TestAPI(t *testing.T) {
+	t.Parallel()
+
+	pools := worker.NewRegistry()
+	scans := &pool{size: 1}
+	pools.Register("virus_scan", scans)
+	pools.Register("heavy_queue", &pool{size: 4})
+	jobs := worker.NewRunner(worker.NewMemoryBackend(8), worker.Retry{Attempts: 1})
+	jobs.Handle("loan.expire_holds", func(context.Context, *worker.Job) error { return nil }, nil)
+	testUtil.NoError(t, jobs.Enqueue(context.Background(), "loan.expire_holds", nil))
+	pools.Register("jobs", jobs)
+
+	api := workers.New(pools, validatorUtil.New())
+	r := chi.NewRouter()
+	r.Get("/admin/workers", e.Handle(api.List))
+	r.Put("/admin/workers/{name}", e.Handle(api.Resize))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/workers", nil))
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	var dtos []*workers.DTO
+	testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &dtos))
+	testUtil.Equal(t, 3, len(dtos))
+	testUtil.Equal(t, "heavy_queue", dtos[0].Name)
+	testUtil.Equal(t, 4, dtos[0].Workers)
+	testUtil.Equal(t, 0, len(dtos[0].Jobs))
+	testUtil.Equal(t, "jobs", dtos[1].Name)
+	testUtil.Equal(t, 1, dtos[1].Queued)
+	testUtil.Equal(t, worker.JobStats{Type: "loan.expire_holds", Pending: 1, Enqueued: 1}, dtos[1].Jobs[0])
+	testUtil.Equal(t, true, strings.Contains(w.Body.String(), `"queued":7`))
+
+	tests := []struct {
+		name   string
+		pool   string
+		body   string
+		status int
+	}{
+		{"unknown pool", "webhooks", `{"workers": 2}`, http.StatusNotFound},
+		{"too small", "virus_scan", `{"workers": 0}`, http.StatusUnprocessableEntity},
+		{"too large", "virus_scan", `{"workers": 65}`, http.StatusUnprocessableEntity},
+		{"valid", "virus_scan", `{"workers": 3}`, http.StatusOK},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/workers/"+tc.pool, strings.NewReader(tc.body)))
+		testUtil.Equal(t, tc.status, w.Code)
+	}
+	testUtil.Equal(t, 3, scans.size)
+}
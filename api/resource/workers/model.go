@@ -0,0 +1,29 @@
+package workers
+
+import "hello/worker"
+
+// DTO describes a pool. Jobs counts the jobs of each type of a job
+// runner's pool.
+type DTO struct {
+	Name string `json:"name"`
+	worker.Stats
+	Jobs []worker.JobStats `json:"jobs,omitempty"`
+}
+
+// Form sets the size of a pool, up to worker.MaxWorkers.
+type Form struct {
+	Workers int `json:"workers" validate:"required,min=1,max=64"`
+}
+
+// jobPool is a pool running jobs of several types.
+type jobPool interface {
+	JobStats() []worker.JobStats
+}
+
+func toDto(name string, p worker.Pool) *DTO {
+	dto := &DTO{Name: name, Stats: p.Stats()}
+	if jp, ok := p.(jobPool); ok {
+		dto.Jobs = jp.JobStats()
+	}
+	return dto
+}
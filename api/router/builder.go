@@ -0,0 +1,168 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"hello/api/middleware/access"
+	"hello/api/middleware/cache"
+	"hello/api/middleware/compress"
+	"hello/api/middleware/deprecated"
+	"hello/api/middleware/dryrun"
+	"hello/api/middleware/ratelimit"
+	"hello/api/middleware/scope"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route declares an endpoint together with the middleware it needs, so that
+// access rules live in one table instead of being wired by hand.
+type Route struct {
+	Method  string
+	Pattern string
+	Handler http.HandlerFunc
+
+	// Scopes restricts the route to callers holding at least one of them.
+	Scopes []string
+	// Role is the lowest role in the tenant a caller needs, when the builder
+	// enforces roles.
+	Role string
+	// RateLimit names a rate limit class applied on top of the global quota.
+	// Routes naming none fall into the read or write class by method, when
+	// the builder has it.
+	RateLimit string
+	// Cache is the Cache-Control policy of successful responses, unless the
+	// builder's Cache has a policy for the route.
+	Cache string
+	// Signed routes require a signed URL when the builder enforces them.
+	Signed bool
+	// Deprecation marks the route deprecated.
+	Deprecation *deprecated.Deprecation
+	// Public routes are served without the Verified check, e.g. registration.
+	Public bool
+	// DryRun write routes handle dry runs themselves; on other write routes
+	// the builder rejects them.
+	DryRun bool
+	// Idempotent routes replay their response to requests retried with the
+	// same Idempotency-Key, when the builder supports keys.
+	Idempotent bool
+	// Compress routes serve downloads compressed when asked with
+	// ?compress=gzip or ?compress=zstd.
+	Compress bool
+	// Conditional GET routes answer 304 to clients holding the current
+	// response, see cache.Conditional.
+	Conditional bool
+	// Heavy routes, such as exports, take turns in the builder's Queue so
+	// that they cannot hold every database connection.
+	Heavy bool
+}
+
+// Builder assembles chi middleware chains from route declarations.
+type Builder struct {
+	// RateLimits holds the limiters of the classes routes may name. Class
+	// quotas are counted per identity, see ratelimit.IdentityKey.
+	RateLimits ratelimit.Classes
+	WarnRatio  float64
+	// Signed guards Signed routes; nil leaves them open.
+	Signed func(http.Handler) http.Handler
+	// Deprecations records the use of deprecated routes.
+	Deprecations deprecated.Recorder
+	// Verified guards write requests to routes that are not Public; nil
+	// leaves them open.
+	Verified func(http.Handler) http.Handler
+	// Roles guards routes declaring a Role; nil leaves them open.
+	Roles func(role string) func(http.Handler) http.Handler
+	// Idempotency serves Idempotent routes; nil ignores Idempotency-Key.
+	Idempotency func(http.Handler) http.Handler
+	// Cache applies the configured cache policies of routes; nil leaves
+	// routes with their declared Cache.
+	Cache *cache.Cache
+	// Queue serves Heavy routes; nil serves them at once.
+	Queue func(http.Handler) http.Handler
+	// Access applies the configured network and country rules of routes,
+	// ahead of everything else; nil serves routes to everyone.
+	Access *access.Access
+}
+
+// Mount registers routes on r. It fails on a route naming an unknown rate
+// limit class rather than serving it without its quota.
+func (b *Builder) Mount(r chi.Router, routes []Route) error {
+	for _, rt := range routes {
+		chain, err := b.chain(rt)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", rt.Method, rt.Pattern, err)
+		}
+		r.With(chain...).Method(rt.Method, rt.Pattern, rt.Handler)
+	}
+	return nil
+}
+
+func (b *Builder) chain(rt Route) ([]func(http.Handler) http.Handler, error) {
+	var chain []func(http.Handler) http.Handler
+
+	if restrict := b.Access.For(rt.Method, rt.Pattern); restrict != nil {
+		chain = append(chain, restrict)
+	}
+	if isWrite(rt.Method) {
+		chain = append(chain, dryrun.Guard(rt.DryRun))
+	}
+	if rt.Deprecation != nil {
+		chain = append(chain, deprecated.Route(b.Deprecations, *rt.Deprecation))
+	}
+	if b.Verified != nil && !rt.Public && isWrite(rt.Method) {
+		chain = append(chain, b.Verified)
+	}
+	if rt.Role != "" && b.Roles != nil {
+		chain = append(chain, b.Roles(rt.Role))
+	}
+	if len(rt.Scopes) > 0 {
+		chain = append(chain, scope.Require(rt.Scopes...))
+	}
+	if rt.RateLimit != "" {
+		limiter, ok := b.RateLimits[rt.RateLimit]
+		if !ok {
+			return nil, fmt.Errorf("unknown rate limit class %q", rt.RateLimit)
+		}
+		chain = append(chain, ratelimit.New(limiter, ratelimit.IdentityKey, b.WarnRatio))
+	} else if limiter, ok := b.RateLimits[defaultClass(rt.Method)]; ok {
+		chain = append(chain, ratelimit.New(limiter, ratelimit.IdentityKey, b.WarnRatio))
+	}
+	if rt.Signed && b.Signed != nil {
+		chain = append(chain, b.Signed)
+	}
+	if rt.Idempotent && b.Idempotency != nil {
+		chain = append(chain, b.Idempotency)
+	}
+	if rt.Cache != "" {
+		chain = append(chain, cache.Control(rt.Cache))
+	}
+	p := b.Cache.For(rt.Method, rt.Pattern)
+	if rt.Method == http.MethodGet && (rt.Conditional || p != nil && p.Conditional) {
+		chain = append(chain, cache.Conditional)
+	}
+	if p != nil {
+		chain = append(chain, b.Cache.Middleware(p))
+	}
+	if rt.Heavy && b.Queue != nil {
+		chain = append(chain, b.Queue)
+	}
+	if rt.Compress {
+		chain = append(chain, compress.Download)
+	}
+	return chain, nil
+}
+
+func defaultClass(method string) string {
+	if isWrite(method) {
+		return ratelimit.ClassWrite
+	}
+	return ratelimit.ClassRead
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,208 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"hello/api/middleware/ratelimit"
+	"hello/api/middleware/scope"
+	"hello/api/middleware/user"
+	"hello/api/router"
+	testUtil "hello/util/test"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestBuilderMount(t *testing.T) {
+	t.Parallel()
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	signed := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("signature") == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	b := &router.Builder{
+		RateLimits: ratelimit.Classes{"upload": ratelimit.NewFixedWindow(1, time.Minute)},
+		WarnRatio:  0.8,
+		Signed:     signed,
+	}
+	r := chi.NewRouter()
+	r.Use(scope.Middleware)
+	err := b.Mount(r, []router.Route{
+		{Method: http.MethodGet, Pattern: "/open", Handler: ok, Cache: "private, max-age=60"},
+		{Method: http.MethodGet, Pattern: "/admin", Handler: ok, Scopes: []string{"admin"}},
+		{Method: http.MethodPost, Pattern: "/upload", Handler: ok, RateLimit: "upload"},
+		{Method: http.MethodGet, Pattern: "/download", Handler: ok, Signed: true},
+		{Method: http.MethodPost, Pattern: "/preview", Handler: ok, DryRun: true},
+	})
+	testUtil.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		scopes string
+		status int
+	}{
+		{"open", http.MethodGet, "/open", "", http.StatusOK},
+		{"missing scope", http.MethodGet, "/admin", "books:read", http.StatusForbidden},
+		{"with scope", http.MethodGet, "/admin", "books:read, admin", http.StatusOK},
+		{"within class quota", http.MethodPost, "/upload", "", http.StatusOK},
+		{"beyond class quota", http.MethodPost, "/upload", "", http.StatusTooManyRequests},
+		{"unsigned", http.MethodGet, "/download", "", http.StatusForbidden},
+		{"signed", http.MethodGet, "/download?signature=x", "", http.StatusOK},
+		{"dry run unsupported", http.MethodPost, "/upload?dry_run=true", "", http.StatusBadRequest},
+		{"dry run", http.MethodPost, "/preview?dry_run=true", "", http.StatusOK},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		req.Header.Set(scope.Header, tc.scopes)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		testUtil.Equal(t, tc.status, w.Code)
+
+		if tc.target == "/open" {
+			testUtil.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestBuilderMountUnknownClass(t *testing.T) {
+	t.Parallel()
+
+	b := &router.Builder{}
+	err := b.Mount(chi.NewRouter(), []router.Route{
+		{Method: http.MethodGet, Pattern: "/", Handler: func(w http.ResponseWriter, r *http.Request) {}, RateLimit: "nope"},
+	})
+	testUtil.Equal(t, true, err != nil)
+}
+
+func TestBuilderMountClasses(t *testing.T) {
+	t.Parallel()
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	b := &router.Builder{
+		RateLimits: ratelimit.Classes{
+			ratelimit.ClassRead:  ratelimit.NewFixedWindow(2, time.Minute),
+			ratelimit.ClassWrite: ratelimit.NewFixedWindow(1, time.Minute),
+			"export":             ratelimit.NewFixedWindow(1, time.Minute),
+		},
+		WarnRatio: 0.8,
+	}
+	r := chi.NewRouter()
+	r.Use(user.Middleware)
+	err := b.Mount(r, []router.Route{
+		{Method: http.MethodGet, Pattern: "/books", Handler: ok},
+		{Method: http.MethodPost, Pattern: "/books", Handler: ok},
+		{Method: http.MethodGet, Pattern: "/books/export", Handler: ok, RateLimit: "export"},
+	})
+	testUtil.NoError(t, err)
+
+	alice, bob := "5f3c6a43-3d4a-4a6f-9a43-8f6d8a6f0a01", "5f3c6a43-3d4a-4a6f-9a43-8f6d8a6f0a02"
+	do := func(method, target, userID string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set(user.Header, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// An exhausted export quota leaves reads and writes alone, and the
+	// quotas of other users.
+	testUtil.Equal(t, http.StatusOK, do(http.MethodGet, "/books/export", alice))
+	testUtil.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, "/books/export", alice))
+	testUtil.Equal(t, http.StatusOK, do(http.MethodGet, "/books/export", bob))
+	testUtil.Equal(t, http.StatusOK, do(http.MethodGet, "/books", alice))
+	testUtil.Equal(t, http.StatusOK, do(http.MethodPost, "/books", alice))
+
+	// Reads and writes fall into their class by method.
+	testUtil.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, "/books", alice))
+	testUtil.Equal(t, http.StatusOK, do(http.MethodGet, "/books", alice))
+	testUtil.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, "/books", alice))
+}
+
+func TestBuilderMountVerified(t *testing.T) {
+	t.Parallel()
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	b := &router.Builder{
+		Verified: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			})
+		},
+	}
+	r := chi.NewRouter()
+	err := b.Mount(r, []router.Route{
+		{Method: http.MethodGet, Pattern: "/books", Handler: ok},
+		{Method: http.MethodPost, Pattern: "/books", Handler: ok},
+		{Method: http.MethodPost, Pattern: "/auth/register", Handler: ok, Public: true},
+	})
+	testUtil.NoError(t, err)
+
+	tests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodGet, "/books", http.StatusOK},
+		{http.MethodPost, "/books", http.StatusForbidden},
+		{http.MethodPost, "/auth/register", http.StatusOK},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+		testUtil.Equal(t, tc.status, w.Code)
+	}
+}
+
+func TestBuilderMountRoles(t *testing.T) {
+	t.Parallel()
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	b := &router.Builder{
+		Roles: func(role string) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("X-Role") != role {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			}
+		},
+	}
+	r := chi.NewRouter()
+	err := b.Mount(r, []router.Route{
+		{Method: http.MethodGet, Pattern: "/open", Handler: ok},
+		{Method: http.MethodPost, Pattern: "/books", Handler: ok, Role: "editor"},
+	})
+	testUtil.NoError(t, err)
+
+	tests := []struct {
+		method string
+		target string
+		role   string
+		status int
+	}{
+		{http.MethodGet, "/open", "", http.StatusOK},
+		{http.MethodPost, "/books", "viewer", http.StatusForbidden},
+		{http.MethodPost, "/books", "editor", http.StatusOK},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		req.Header.Set("X-Role", tc.role)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		testUtil.Equal(t, tc.status, w.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"hello/api/middleware/idempotency"
+	"hello/api/resource/annotation"
+	"hello/api/resource/apikey"
+	"hello/api/resource/attachment"
+	"hello/api/resource/auth"
+	"hello/api/resource/blob"
+	"hello/api/resource/book"
+	"hello/api/resource/catalog"
+	"hello/api/resource/customfield"
+	"hello/api/resource/deadletter"
+	"hello/api/resource/denylist"
+	"hello/api/resource/deprecation"
+	"hello/api/resource/experiment"
+	"hello/api/resource/genre"
+	"hello/api/resource/interaction"
+	"hello/api/resource/legalhold"
+	"hello/api/resource/loan"
+	"hello/api/resource/order"
+	"hello/api/resource/payment"
+	"hello/api/resource/progress"
+	"hello/api/resource/review"
+	"hello/api/resource/serviceaccount"
+	"hello/api/resource/transfer"
+	"hello/audit"
+	"hello/inbox"
+	"hello/session"
+)
+
+// Models returns the persisted models of the resources the router serves.
+// Their tables are created by the migrations; the list is used to check the
+// live schema against them.
+func Models() []any {
+	return []any{
+		&book.Book{},
+		&book.Redirect{},
+		&book.Revision{},
+		&customfield.Definition{},
+		&genre.Genre{},
+		&attachment.Attachment{},
+		&attachment.Upload{},
+		&blob.Blob{},
+		&catalog.Entry{},
+		&denylist.Term{},
+		&deprecation.Usage{},
+		&auth.User{},
+		&auth.VerificationToken{},
+		&auth.ResetToken{},
+		&auth.Invitation{},
+		&auth.Role{},
+		&auth.Membership{},
+		&order.Stock{},
+		&order.CartItem{},
+		&order.Order{},
+		&order.Item{},
+		&order.Copy{},
+		&transfer.Transfer{},
+		&transfer.Event{},
+		&payment.Event{},
+		&progress.Progress{},
+		&progress.Entry{},
+		&annotation.Annotation{},
+		&review.Review{},
+		&loan.Loan{},
+		&loan.Hold{},
+		&deadletter.Letter{},
+		&interaction.Event{},
+		&experiment.Experiment{},
+		&legalhold.Hold{},
+		&serviceaccount.Account{},
+		&serviceaccount.Secret{},
+		&apikey.Key{},
+		&session.Record{},
+		&audit.Entry{},
+		&idempotency.Record{},
+		&inbox.Message{},
+	}
+}
@@ -0,0 +1,112 @@
+package router_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"hello/api/resource/book"
+	"hello/api/router"
+	"hello/config"
+	"hello/event"
+	"hello/lifecycle"
+	mockDB "hello/mock/db"
+	"hello/pact"
+	validatorUtil "hello/util/validator"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TestPactProvider verifies the API against its consumers' pacts. The pacts
+// are read from PACT_BROKER_URL when set, for PACT_PROVIDER (books-api by
+// default), and from PACT_DIR or testdata/pacts otherwise. The API runs on
+// an in-memory SQLite database, so only interactions that do not depend on
+// PostgreSQL specific queries can be verified.
+func TestPactProvider(t *testing.T) {
+	pacts := loadPacts(t)
+
+	t.Setenv("SERVER_PORT", "8080")
+	t.Setenv("SERVER_TIMEOUT_READ", "3s")
+	t.Setenv("SERVER_TIMEOUT_WRITE", "5s")
+	t.Setenv("SERVER_TIMEOUT_IDLE", "5s")
+	t.Setenv("SERVER_DEBUG", "false")
+	t.Setenv("DB_HOST", "memory")
+	t.Setenv("DB_PORT", "5432")
+	t.Setenv("DB_USER", "pact")
+	t.Setenv("DB_PASS", "pact")
+	t.Setenv("DB_NAME", "pact")
+	t.Setenv("DB_DEBUG", "false")
+	t.Setenv("RATE_LIMIT_REQUESTS", "0")
+	t.Setenv("STORAGE_FS_PATH", t.TempDir())
+	c := config.New()
+
+	db := mockDB.NewSQLite(t, router.Models()...)
+
+	lc := lifecycle.New(time.Second)
+	t.Cleanup(func() { lc.Shutdown(context.Background()) })
+	verifier := &pact.Verifier{
+		Handler: router.New(c, db, validatorUtil.New(), event.NewBus(), nil, lc),
+		Setup: func() error {
+			return db.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&book.Book{}).Error
+		},
+		States: map[string]pact.StateFunc{
+			"no books exist": func(map[string]any) error { return nil },
+			"a book exists": func(params map[string]any) error {
+				id := uuid.New()
+				if s, ok := params["id"].(string); ok {
+					var err error
+					if id, err = uuid.Parse(s); err != nil {
+						return err
+					}
+				}
+				_, err := book.NewRepository(db).Create(&book.Book{
+					ID:            id,
+					Title:         "Dune",
+					Author:        "Frank Herbert",
+					PublishedDate: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC),
+				})
+				return err
+			},
+		},
+	}
+
+	for _, p := range pacts {
+		t.Run(p.Consumer.Name, func(t *testing.T) {
+			if err := verifier.Verify(p); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func loadPacts(t *testing.T) []*pact.Pact {
+	if url := os.Getenv("PACT_BROKER_URL"); url != "" {
+		provider := os.Getenv("PACT_PROVIDER")
+		if provider == "" {
+			provider = "books-api"
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		broker := &pact.Broker{URL: url, Token: os.Getenv("PACT_BROKER_TOKEN")}
+		pacts, err := broker.Latest(ctx, provider)
+		if err != nil {
+			t.Fatal(fmt.Errorf("fetch pacts: %w", err))
+		}
+		return pacts
+	}
+
+	dir := os.Getenv("PACT_DIR")
+	if dir == "" {
+		dir = "testdata/pacts"
+	}
+	pacts, err := pact.LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pacts
+}
@@ -1,26 +1,738 @@
 package router
 
 import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"hello/api/middleware/access"
+	"hello/api/middleware/apiversion"
+	"hello/api/middleware/cache"
+	"hello/api/middleware/coalesce"
+	"hello/api/middleware/idempotency"
+	"hello/api/middleware/logger"
+	"hello/api/middleware/queue"
+	"hello/api/middleware/ratelimit"
+	"hello/api/middleware/region"
+	"hello/api/middleware/scope"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+	"hello/api/middleware/warning"
+	"hello/api/resource/annotation"
+	"hello/api/resource/apikey"
+	"hello/api/resource/attachment"
+	"hello/api/resource/auth"
+	"hello/api/resource/blob"
 	"hello/api/resource/book"
+	"hello/api/resource/cachestats"
+	"hello/api/resource/catalog"
+	e "hello/api/resource/common/err"
+	"hello/api/resource/cover"
+	"hello/api/resource/customfield"
+	"hello/api/resource/deadletter"
+	"hello/api/resource/denylist"
+	"hello/api/resource/deprecation"
+	"hello/api/resource/experiment"
+	"hello/api/resource/genre"
 	"hello/api/resource/health"
+	"hello/api/resource/interaction"
+	"hello/api/resource/journal"
+	"hello/api/resource/legalhold"
+	"hello/api/resource/loan"
+	"hello/api/resource/metadata"
+	"hello/api/resource/order"
+	"hello/api/resource/payment"
+	"hello/api/resource/progress"
+	"hello/api/resource/review"
+	"hello/api/resource/schemas"
+	"hello/api/resource/serviceaccount"
+	"hello/api/resource/transfer"
+	"hello/api/resource/usage"
+	"hello/api/resource/version"
+	"hello/api/resource/workers"
+	"hello/audit"
+	"hello/backup"
+	"hello/buildinfo"
+	datacache "hello/cache"
+	"hello/config"
+	"hello/dbtimeout"
+	"hello/event"
+	"hello/event/schema"
+	exp "hello/experiment"
+	"hello/fieldpolicy"
+	"hello/geoip"
+	"hello/idcodec"
+	"hello/lifecycle"
+	"hello/mail"
+	meta "hello/metadata"
+	"hello/moderation"
+	"hello/money"
+	"hello/openapi"
+	"hello/pricing"
+	"hello/replay"
+	"hello/scan"
+	"hello/search"
+	"hello/session"
+	"hello/signedurl"
+	"hello/storage"
+	"hello/telemetry"
+	"hello/tracing"
+	redisUtil "hello/util/redis"
+	validatorUtil "hello/util/validator"
+	"hello/worker"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
 )
 
-func New(db *gorm.DB, v *validator.Validate) *chi.Mux {
+// New builds the API. Background workers stop with lc, flushing what they
+// buffer.
+func New(c *config.Conf, db *gorm.DB, v *validator.Validate, bus event.Bus, idx search.Index, lc *lifecycle.Lifecycle) *chi.Mux {
 	r := chi.NewRouter()
+	if c.Tracing.Enabled {
+		r.Use(tracing.Middleware(otel.GetTracerProvider()))
+	}
+	r.Use(logger.New(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: c.Log.SlogLevel()}))))
+	r.Use(region.Headers(&c.Region))
+
+	// Public DTOs and URLs show IDs through the configured codec.
+	codec, err := idcodec.New(c.PublicID.Codec, c.PublicID.Key)
+	if err != nil {
+		log.Fatalf("Invalid ID codec: %s", err)
+	}
+	idcodec.Set(codec)
+
+	var reporter *telemetry.Reporter
+	if c.Telemetry.Enabled && !c.Telemetry.DoNotTrack && c.Telemetry.Endpoint != "" {
+		reporter = telemetry.New(c.Telemetry.Endpoint, c.Telemetry.Timeout, db.Dialector.Name(), Features(c, idx))
+		lc.Go("telemetry", func(ctx context.Context) { reporter.Run(ctx, c.Telemetry.Interval) })
+		r.Use(reporter.Middleware)
+		log.Printf("Anonymous usage telemetry is sent to %s; set TELEMETRY_ENABLED=false to opt out", c.Telemetry.Endpoint)
+	}
 
+	// Book and order DTOs show their prices as displayed in the caller's
+	// region.
+	if c.Pricing.RulesPath != "" {
+		rules, err := pricing.Load(c.Pricing.RulesPath)
+		if err != nil {
+			log.Fatalf("Failed to load pricing rules: %s", err)
+		}
+		prices := pricing.New(rules, c.Pricing.DefaultRegion)
+		r.Use(prices.Middleware)
+		book.Computed.Register("display_price", pricing.Resolver(prices, func(b *book.Book) money.Money { return b.Price }))
+		order.Computed.Register("display_total", pricing.Resolver(prices, func(o *order.Order) money.Money { return o.Total }))
+	}
+
+	healthAPI := health.New(db, &c.Region)
 	r.Get("/livez", health.Read)
+	r.Get("/readyz", healthAPI.Ready)
+
+	var releases *buildinfo.ReleaseChecker
+	if c.Release.FeedURL != "" {
+		releases = buildinfo.NewReleaseChecker(c.Release.FeedURL, c.Release.Timeout)
+		lc.Go("release_check", func(ctx context.Context) { releases.Run(ctx, c.Release.CheckInterval) })
+	}
+	r.Get("/version", e.Handle(version.New(releases).Read))
+
+	// Deliveries subscribers fail are kept at /admin/dead-letters, to be
+	// retried once the subscriber is fixed.
+	letters := deadletter.NewBus(bus, db)
+	letters.Payload(&book.Book{}, book.EventCreated, book.EventUpdated, book.EventRestored)
+	letters.Payload(&genre.DeletedDTO{}, genre.EventDeleted)
+	bus = letters
+
+	// Events are published with the version of the schema of their
+	// payload, checked against it. The schemas are served at /schemas.
+	eventSchemas, err := schema.Load()
+	if err != nil {
+		log.Fatalf("Failed to load event schemas: %s", err)
+	}
+	bus = schema.NewBus(bus, eventSchemas, c.EventSchema.Enforce)
+
+	catalog.Subscribe(bus, catalog.NewRepository(db))
+	book.SubscribeHistory(bus, book.NewRepository(db))
+
+	if idx != nil {
+		book.SubscribeSearch(bus, idx)
+	}
+
+	action := moderation.Action(c.Moderation.Action)
+	if !action.Valid() {
+		log.Fatalf("Invalid moderation action %q", action)
+	}
+	contentFilter := moderation.NewFilter(action, c.Moderation.DenyList...)
+	denylist.Load(db, contentFilter)
+	moderator := moderation.NewModerator(contentFilter, moderation.NewAssessorFromConfig(&c.Abuse))
+
+	policy, err := fieldpolicy.Load(c.FieldPolicy.Path)
+	if err != nil {
+		log.Fatalf("Failed to load field policy: %s", err)
+	}
+
+	spec, err := openapi.Load(c.OpenAPI.SpecPath)
+	if err != nil {
+		log.Fatalf("Failed to load OpenAPI spec: %s", err)
+	}
+
+	requestJournal, err := replay.Open(&c.Journal)
+	if err != nil {
+		log.Fatalf("Failed to open request journal: %s", err)
+	}
+
+	store, err := storage.New(&c.Storage)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %s", err)
+	}
+
+	// Deprecated routes are declared with a Deprecation, and deprecated
+	// parameters wrapped with deprecated.Param, using this tracker so their
+	// remaining use is reported.
+	deprecations := deprecation.NewTracker(db)
+	lc.Go("deprecation_usage", func(ctx context.Context) { deprecations.Run(ctx, c.Deprecation.FlushInterval) })
+
+	var rateLimitStore *redis.Client
+	if c.RateLimit.Store == "redis" {
+		rateLimitStore = redisUtil.New(c.RateLimit.RedisAddr, c.RateLimit.RedisPassword)
+		lc.Register("ratelimit_redis", 0, func(context.Context) error { return rateLimitStore.Close() })
+	}
+	newLimiter, err := ratelimit.NewFactory(c.RateLimit.Algorithm, rateLimitStore)
+	if err != nil {
+		log.Fatalf("Invalid rate limiter: %s", err)
+	}
+	rateLimits, err := ratelimit.ParseClasses(c.RateLimit.Classes, newLimiter)
+	if err != nil {
+		log.Fatalf("Invalid rate limit classes: %s", err)
+	}
+
+	blobs := blob.NewStore(db, store, cover.ThumbnailNames()...)
+	lc.Go("blob_collector", func(ctx context.Context) { blobs.RunCollector(ctx, c.Storage.BlobGCInterval) })
+
+	if c.Backup.Backend != "" {
+		secondary, err := storage.New(c.Backup.Storage())
+		if err != nil {
+			log.Fatalf("Failed to open backup storage: %s", err)
+		}
+		lc.Go("backup", func(ctx context.Context) { backup.New(db, store, secondary).Run(ctx, c.Backup.Interval) })
+	}
+
+	// Background worker pools are listed and resized at /admin/workers.
+	pools := worker.NewRegistry()
+
+	// Background jobs run on a pool of their own, with their counts by
+	// type listed along with it.
+	jobs := worker.NewRunner(worker.NewMemoryBackend(c.Jobs.QueueSize), worker.Retry{
+		Attempts:   c.Jobs.MaxAttempts,
+		Backoff:    c.Jobs.RetryBackoff,
+		MaxBackoff: c.Jobs.RetryMaxBackoff,
+	})
+	if err := jobs.Resize(c.Jobs.Workers); err != nil {
+		log.Fatalf("Invalid JOB_WORKERS %d: %s", c.Jobs.Workers, err)
+	}
+	// Jobs given up on are kept as dead letters, to be enqueued again.
+	jobs.OnAbandon(letters.KeepJob)
+	pools.Register("jobs", jobs)
+
+	scanWorker := attachment.NewScanWorker(db, store, blobs, scan.New(&c.Scan))
+	if err := scanWorker.Resize(c.Scan.Workers); err != nil {
+		log.Fatalf("Invalid SCAN_WORKERS %d: %s", c.Scan.Workers, err)
+	}
+	pools.Register("virus_scan", scanWorker)
+	lc.Go("virus_scan", func(ctx context.Context) { scanWorker.Run(ctx, c.Scan.SweepInterval) })
+
+	suggester := book.NewSuggester(db)
+	lc.Go("suggester", func(ctx context.Context) { suggester.Run(ctx, c.Suggest.RefreshInterval) })
+
+	var imageChecker *book.ImageChecker
+	if c.Book.CheckImageURL {
+		imageChecker = book.NewImageChecker(c.Book.ImageURLTimeout)
+	}
+
+	// Downloads may be restricted to short-lived signed URLs, issued by the
+	// .../url endpoints.
+	signer := signedurl.NewFromConfig(&c.SignedURL)
+
+	bookAPI := book.New(db, v, bus, book.NewCollator(c.Locale.Collations), imageChecker, policy)
+	// Book reads and lists may be cached, in memory or in a Redis server
+	// shared by instances.
+	var readCache *datacache.Counted
+	if c.Cache.BookTTL > 0 || c.Cache.BookListTTL > 0 {
+		var store datacache.Cache = datacache.NewMemory()
+		if c.Cache.Store == "redis" {
+			client := redisUtil.New(c.Cache.RedisAddr, c.Cache.RedisPassword)
+			lc.Register("cache_redis", 0, func(context.Context) error { return client.Close() })
+			store = datacache.NewRedis(client, "cache:")
+		}
+		readCache = datacache.Count(store)
+		bookAPI.UseCache(book.NewReadCache(readCache, bus, c.Cache.BookTTL, c.Cache.BookListTTL))
+	}
+	attachmentAPI := attachment.New(db, store, blobs, scanWorker, signer, &c.Attachment)
+	coverAPI := cover.New(db, store, blobs, signer, &c.Cover)
+	book.Computed.Register("cover", cover.Resolver(signer, store))
+	uploadAPI := attachment.NewUploadAPI(db, store, blobs, scanWorker, &c.Attachment)
+	lc.Go("upload_expiry", func(ctx context.Context) { uploadAPI.ExpireUploads(ctx, c.Attachment.UploadSweep) })
+	catalogAPI := catalog.New(db)
+	customFieldAPI := customfield.New(db, v)
+	genreAPI := genre.New(db, v, bus)
+	denyListAPI := denylist.New(db, v, contentFilter)
+	deprecationAPI := deprecation.New(db)
+	// Browser clients may sign in with cookie sessions instead of being
+	// identified by the gateway.
+	var sessions *session.Manager
+	if c.Session.Enabled {
+		sessionStore, err := session.NewStore(&c.Session, db)
+		if err != nil {
+			log.Fatalf("Failed to open session store: %s", err)
+		}
+		if closer, ok := sessionStore.(io.Closer); ok {
+			lc.Register("session_redis", 0, func(context.Context) error { return closer.Close() })
+		}
+		sessions = session.NewManagerFromConfig(sessionStore, &c.Session)
+		lc.Go("session_sweep", func(ctx context.Context) { sessions.Run(ctx, c.Session.SweepInterval) })
+		auth.SubscribeSessions(bus, sessions)
+	}
+
+	// Security-relevant actions are audited when sinks are configured.
+	auditLog, err := audit.NewFromConfig(&c.Audit, db)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %s", err)
+	}
+	if auditLog != nil {
+		lc.Go("audit", func(ctx context.Context) { auditLog.Run(ctx, c.Audit.FlushInterval) })
+	}
+
+	mailer := mail.New(&c.Mail)
+	authAPI := auth.New(db, v, mailer, bus, sessions, &c.Auth)
+	serviceAccountAPI := serviceaccount.New(db, v, &c.ServiceAccount)
+	apiKeyAPI := apikey.New(db, v)
+	progressAPI := progress.New(db, v)
+	annotationAPI := annotation.New(db, v, moderator)
+	reviewAPI := review.New(db, v, moderator)
+	loanAPI := loan.New(db, v, &c.Loan)
+	holdExpirer := loan.NewExpirer(db, &c.Loan)
+	jobs.Handle(loan.JobExpireHolds, func(ctx context.Context, _ *worker.Job) error { return holdExpirer.Expire(ctx) }, nil)
+	jobs.Every(loan.JobExpireHolds, c.Loan.HoldSweepInterval)
+	lc.Go("jobs", jobs.Run)
+	book.Computed.Register("loan", loan.Resolver(loan.NewRepository(db), func(b *book.Book) uuid.UUID { return b.ID }))
+	workersAPI := workers.New(pools, v)
+	deadLetterAPI := deadletter.New(letters, jobs, v)
+	schemasAPI := schemas.New(eventSchemas)
+	legalHoldAPI := legalhold.New(db, v)
+
+	admin := []string{"admin"}
+	// Roles apply when RBAC is enabled: viewers read books, editors write
+	// them and admins delete them.
+	viewer, editor := auth.RoleViewer, auth.RoleEditor
+	routes := []Route{
+		{Method: http.MethodGet, Pattern: "/books/suggest", Handler: e.Handle(suggester.Suggest), Role: viewer, Cache: "private, max-age=60"},
+
+		{Method: http.MethodGet, Pattern: "/books", Handler: e.Handle(bookAPI.List), Role: viewer},
+		{Method: http.MethodGet, Pattern: "/books/facets", Handler: e.Handle(bookAPI.Facets), Role: viewer, Cache: "private, max-age=60"},
+		{Method: http.MethodGet, Pattern: "/books/deleted", Handler: e.Handle(bookAPI.ListDeleted), Role: auth.RoleAdmin, Cache: "no-store"},
+		{Method: http.MethodPost, Pattern: "/books", Handler: e.Handle(bookAPI.Create), Role: editor, DryRun: true, Idempotent: true},
+		{Method: http.MethodPost, Pattern: "/books/bulk", Handler: e.Handle(bookAPI.BulkCreate), Role: editor, DryRun: true, Idempotent: true},
+		{Method: http.MethodGet, Pattern: "/books/export", Handler: e.Handle(bookAPI.Export), Role: viewer, RateLimit: "export", Cache: "no-store", Compress: true, Heavy: true},
+		{Method: http.MethodPost, Pattern: "/books/import", Handler: e.Handle(bookAPI.Import), Role: editor, RateLimit: "upload", Heavy: true},
+		{Method: http.MethodPost, Pattern: "/books/validate", Handler: e.Handle(bookAPI.BulkValidate), Role: editor},
+		{Method: http.MethodDelete, Pattern: "/books/bulk", Handler: e.Handle(bookAPI.BulkDelete), Role: auth.RoleAdmin, DryRun: true},
+		{Method: http.MethodPatch, Pattern: "/books/bulk", Handler: e.Handle(bookAPI.BulkPatch), Role: auth.RoleAdmin, DryRun: true},
+		{Method: http.MethodGet, Pattern: "/books/{id}", Handler: e.Handle(bookAPI.Read), Role: viewer},
+		{Method: http.MethodPut, Pattern: "/books/{id}", Handler: e.Handle(bookAPI.Update), Role: editor, DryRun: true},
+		{Method: http.MethodPatch, Pattern: "/books/{id}", Handler: e.Handle(bookAPI.Patch), Role: editor, DryRun: true},
+		{Method: http.MethodDelete, Pattern: "/books/{id}", Handler: e.Handle(bookAPI.Delete), Role: auth.RoleAdmin, DryRun: true},
+		{Method: http.MethodPost, Pattern: "/books/{id}/genres", Handler: e.Handle(bookAPI.AddGenres), Role: editor},
+		{Method: http.MethodDelete, Pattern: "/books/{id}/genres/{slug}", Handler: e.Handle(bookAPI.RemoveGenre), Role: editor},
+		{Method: http.MethodPost, Pattern: "/books/{id}/restore", Handler: e.Handle(bookAPI.Restore), Role: auth.RoleAdmin},
+		{Method: http.MethodDelete, Pattern: "/books/{id}/purge", Handler: e.Handle(bookAPI.Purge), Role: auth.RoleAdmin},
+
+		{Method: http.MethodGet, Pattern: "/books/{id}/attachments", Handler: e.Handle(attachmentAPI.List), Role: viewer},
+		{Method: http.MethodPost, Pattern: "/books/{id}/attachments", Handler: e.Handle(attachmentAPI.Create), Role: editor, RateLimit: "upload"},
+		{Method: http.MethodGet, Pattern: "/books/{id}/attachments/{attachmentID}", Handler: e.Handle(attachmentAPI.Read), Role: viewer, Signed: true, Cache: "private, no-cache"},
+		{Method: http.MethodGet, Pattern: "/books/{id}/attachments/{attachmentID}/url", Handler: e.Handle(attachmentAPI.URL), Role: viewer, Cache: "no-store"},
+		{Method: http.MethodDelete, Pattern: "/books/{id}/attachments/{attachmentID}", Handler: e.Handle(attachmentAPI.Delete), Role: auth.RoleAdmin},
+
+		{Method: http.MethodGet, Pattern: "/books/{id}/cover", Handler: e.Handle(coverAPI.Read), Role: viewer, Signed: true, Cache: "private, max-age=300"},
+		{Method: http.MethodGet, Pattern: "/books/{id}/cover/url", Handler: e.Handle(coverAPI.URL), Role: viewer, Cache: "no-store"},
+		{Method: http.MethodGet, Pattern: "/books/{id}/cover/thumbnails/{size}", Handler: e.Handle(coverAPI.ReadThumbnail), Role: viewer, Signed: true, Cache: "private, max-age=300"},
+		{Method: http.MethodPost, Pattern: "/books/{id}/cover", Handler: e.Handle(coverAPI.Upload), Role: editor, RateLimit: "upload"},
+		{Method: http.MethodPut, Pattern: "/books/{id}/cover", Handler: e.Handle(coverAPI.Update), Role: editor, RateLimit: "upload"},
+		{Method: http.MethodDelete, Pattern: "/books/{id}/cover", Handler: e.Handle(coverAPI.Delete), Role: auth.RoleAdmin},
+
+		{Method: http.MethodGet, Pattern: "/books/{id}/reviews", Handler: e.Handle(reviewAPI.List), Role: viewer, Cache: "no-store"},
+		{Method: http.MethodPost, Pattern: "/books/{id}/reviews", Handler: e.Handle(reviewAPI.Create), Role: viewer},
+		{Method: http.MethodDelete, Pattern: "/books/{id}/reviews", Handler: e.Handle(reviewAPI.Delete), Role: viewer},
+
+		{Method: http.MethodPost, Pattern: "/books/{id}/checkout", Handler: e.Handle(loanAPI.Checkout), Role: viewer},
+		{Method: http.MethodPost, Pattern: "/loans/{id}/return", Handler: e.Handle(loanAPI.Return), Role: viewer},
+		{Method: http.MethodGet, Pattern: "/loans/overdue", Handler: e.Handle(loanAPI.Overdue), Role: editor, Cache: "no-store"},
+		{Method: http.MethodPost, Pattern: "/books/{id}/holds", Handler: e.Handle(loanAPI.PlaceHold), Role: viewer},
+		{Method: http.MethodDelete, Pattern: "/holds/{id}", Handler: e.Handle(loanAPI.CancelHold), Role: viewer},
+		{Method: http.MethodGet, Pattern: "/me/holds", Handler: e.Handle(loanAPI.ListHolds), Role: viewer, Cache: "no-store"},
+
+		{Method: http.MethodGet, Pattern: "/books/{id}/annotations", Handler: e.Handle(annotationAPI.List), Role: viewer, Cache: "no-store"},
+		{Method: http.MethodPost, Pattern: "/books/{id}/annotations", Handler: e.Handle(annotationAPI.Create), Role: viewer},
+		{Method: http.MethodGet, Pattern: "/books/{id}/annotations/export", Handler: e.Handle(annotationAPI.Export), Role: viewer, RateLimit: "export", Cache: "no-store", Compress: true, Heavy: true},
+		{Method: http.MethodGet, Pattern: "/books/{id}/annotations/{annotationID}", Handler: e.Handle(annotationAPI.Read), Role: viewer, Cache: "no-store"},
+		{Method: http.MethodPut, Pattern: "/books/{id}/annotations/{annotationID}", Handler: e.Handle(annotationAPI.Update), Role: viewer},
+		{Method: http.MethodDelete, Pattern: "/books/{id}/annotations/{annotationID}", Handler: e.Handle(annotationAPI.Delete), Role: viewer},
+
+		{Method: http.MethodOptions, Pattern: "/books/{id}/attachments/uploads", Handler: e.Handle(uploadAPI.Options)},
+		{Method: http.MethodPost, Pattern: "/books/{id}/attachments/uploads", Handler: e.Handle(uploadAPI.Create), Role: editor, RateLimit: "upload"},
+		{Method: http.MethodHead, Pattern: "/books/{id}/attachments/uploads/{uploadID}", Handler: e.Handle(uploadAPI.Head), Role: editor},
+		{Method: http.MethodPatch, Pattern: "/books/{id}/attachments/uploads/{uploadID}", Handler: e.Handle(uploadAPI.Patch), Role: editor, RateLimit: "upload"},
+		{Method: http.MethodDelete, Pattern: "/books/{id}/attachments/uploads/{uploadID}", Handler: e.Handle(uploadAPI.Delete), Role: editor},
+
+		{Method: http.MethodGet, Pattern: "/me/books/{id}/progress", Handler: e.Handle(progressAPI.Read), Cache: "no-store"},
+		{Method: http.MethodPut, Pattern: "/me/books/{id}/progress", Handler: e.Handle(progressAPI.Put)},
+		{Method: http.MethodGet, Pattern: "/me/books/{id}/progress/history", Handler: e.Handle(progressAPI.History), Cache: "no-store"},
+		{Method: http.MethodGet, Pattern: "/me/reading/stats", Handler: e.Handle(progressAPI.Stats), Cache: "no-store"},
+		{Method: http.MethodGet, Pattern: "/me/reading/stats/daily", Handler: e.Handle(progressAPI.Daily), Cache: "no-store"},
+
+		{Method: http.MethodGet, Pattern: "/catalog/books", Handler: e.Handle(catalogAPI.List), Cache: "private, max-age=60", Conditional: true},
+		{Method: http.MethodGet, Pattern: "/catalog/books/{id}", Handler: e.Handle(catalogAPI.Read), Cache: "private, max-age=60", Conditional: true},
+
+		{Method: http.MethodGet, Pattern: "/custom-fields", Handler: e.Handle(customFieldAPI.List)},
+		{Method: http.MethodGet, Pattern: "/genres", Handler: e.Handle(genreAPI.List), Role: viewer, Cache: "public, max-age=300", Conditional: true},
+		{Method: http.MethodPost, Pattern: "/genres", Handler: e.Handle(genreAPI.Create), Role: editor},
+		{Method: http.MethodDelete, Pattern: "/genres/{slug}", Handler: e.Handle(genreAPI.Delete), Role: auth.RoleAdmin},
+		{Method: http.MethodPost, Pattern: "/custom-fields", Handler: e.Handle(customFieldAPI.Create), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodDelete, Pattern: "/custom-fields/{name}", Handler: e.Handle(customFieldAPI.Delete), Scopes: admin, RateLimit: "admin"},
+
+		{Method: http.MethodGet, Pattern: "/schemas", Handler: e.Handle(schemasAPI.List), Public: true, Cache: "public, max-age=300"},
+		{Method: http.MethodGet, Pattern: "/schemas/{event}", Handler: e.Handle(schemasAPI.Latest), Public: true, Cache: "public, max-age=300"},
+		{Method: http.MethodGet, Pattern: "/schemas/{event}/{version}", Handler: e.Handle(schemasAPI.Read), Public: true, Cache: "public, max-age=300"},
+
+		{Method: http.MethodGet, Pattern: "/admin/moderation/deny-list", Handler: e.Handle(denyListAPI.List), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodPost, Pattern: "/admin/moderation/deny-list", Handler: e.Handle(denyListAPI.Create), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodDelete, Pattern: "/admin/moderation/deny-list/{term}", Handler: e.Handle(denyListAPI.Delete), Scopes: admin, RateLimit: "admin"},
+
+		{Method: http.MethodGet, Pattern: "/admin/deprecations", Handler: e.Handle(deprecationAPI.Report), Scopes: admin, RateLimit: "admin"},
+
+		{Method: http.MethodPost, Pattern: "/auth/register", Handler: e.Handle(authAPI.Register), Public: true, RateLimit: "auth"},
+		{Method: http.MethodGet, Pattern: "/auth/verify", Handler: e.Handle(authAPI.Verify), Public: true, RateLimit: "auth", Cache: "no-store"},
+		{Method: http.MethodPost, Pattern: "/auth/verify/resend", Handler: e.Handle(authAPI.Resend), Public: true, RateLimit: "auth"},
+		{Method: http.MethodPost, Pattern: "/auth/forgot", Handler: e.Handle(authAPI.Forgot), Public: true, RateLimit: "auth"},
+		{Method: http.MethodPost, Pattern: "/auth/reset", Handler: e.Handle(authAPI.Reset), Public: true, RateLimit: "auth"},
+		{Method: http.MethodPost, Pattern: "/auth/accept-invite", Handler: e.Handle(authAPI.AcceptInvite), Public: true, RateLimit: "auth"},
+
+		{Method: http.MethodGet, Pattern: "/me", Handler: e.Handle(authAPI.ReadProfile), Cache: "no-store"},
+		{Method: http.MethodPatch, Pattern: "/me", Handler: e.Handle(authAPI.UpdateProfile), RateLimit: "auth"},
+		{Method: http.MethodDelete, Pattern: "/me", Handler: e.Handle(authAPI.DeleteProfile), RateLimit: "auth"},
+		{Method: http.MethodPut, Pattern: "/me/password", Handler: e.Handle(authAPI.ChangePassword), RateLimit: "auth"},
+
+		{Method: http.MethodGet, Pattern: "/admin/invitations", Handler: e.Handle(authAPI.ListInvitations), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodPost, Pattern: "/admin/invitations", Handler: e.Handle(authAPI.Invite), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodDelete, Pattern: "/admin/invitations/{id}", Handler: e.Handle(authAPI.RevokeInvitation), Scopes: admin, RateLimit: "admin"},
+
+		{Method: http.MethodGet, Pattern: "/admin/books/duplicates", Handler: e.Handle(bookAPI.Duplicates), Scopes: admin, RateLimit: "admin", Cache: "no-store"},
+		{Method: http.MethodPost, Pattern: "/admin/books/{id}/merge-into/{target}", Handler: e.Handle(bookAPI.MergeInto), Scopes: admin, RateLimit: "admin"},
+
+		{Method: http.MethodGet, Pattern: "/admin/legal-holds", Handler: e.Handle(legalHoldAPI.List), Scopes: admin, RateLimit: "admin", Cache: "no-store"},
+		{Method: http.MethodPost, Pattern: "/admin/legal-holds/{kind}/{id}", Handler: e.Handle(legalHoldAPI.Place), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodDelete, Pattern: "/admin/legal-holds/{kind}/{id}", Handler: e.Handle(legalHoldAPI.Release), Scopes: admin, RateLimit: "admin"},
+
+		{Method: http.MethodGet, Pattern: "/admin/service-accounts", Handler: e.Handle(serviceAccountAPI.List), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodPost, Pattern: "/admin/service-accounts", Handler: e.Handle(serviceAccountAPI.Create), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodDelete, Pattern: "/admin/service-accounts/{id}", Handler: e.Handle(serviceAccountAPI.Delete), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodPost, Pattern: "/admin/service-accounts/{id}/rotate", Handler: e.Handle(serviceAccountAPI.Rotate), Scopes: admin, RateLimit: "admin", Cache: "no-store"},
+		{Method: http.MethodPost, Pattern: "/service-accounts/self/rotate", Handler: e.Handle(serviceAccountAPI.RotateSelf), Cache: "no-store"},
+
+		{Method: http.MethodGet, Pattern: "/admin/api-keys", Handler: e.Handle(apiKeyAPI.List), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodPost, Pattern: "/admin/api-keys", Handler: e.Handle(apiKeyAPI.Create), Scopes: admin, RateLimit: "admin", Cache: "no-store"},
+		{Method: http.MethodDelete, Pattern: "/admin/api-keys/{id}", Handler: e.Handle(apiKeyAPI.Revoke), Scopes: admin, RateLimit: "admin"},
+
+		{Method: http.MethodGet, Pattern: "/admin/workers", Handler: e.Handle(workersAPI.List), Scopes: admin, RateLimit: "admin", Cache: "no-store"},
+		{Method: http.MethodPut, Pattern: "/admin/workers/{name}", Handler: e.Handle(workersAPI.Resize), Scopes: admin, RateLimit: "admin"},
+
+		{Method: http.MethodGet, Pattern: "/admin/dead-letters", Handler: e.Handle(deadLetterAPI.List), Scopes: admin, RateLimit: "admin", Cache: "no-store"},
+		{Method: http.MethodGet, Pattern: "/admin/dead-letters/{id}", Handler: e.Handle(deadLetterAPI.Read), Scopes: admin, RateLimit: "admin", Cache: "no-store"},
+		{Method: http.MethodPut, Pattern: "/admin/dead-letters/{id}", Handler: e.Handle(deadLetterAPI.Update), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodPost, Pattern: "/admin/dead-letters/{id}/retry", Handler: e.Handle(deadLetterAPI.Retry), Scopes: admin, RateLimit: "admin"},
+		{Method: http.MethodDelete, Pattern: "/admin/dead-letters/{id}", Handler: e.Handle(deadLetterAPI.Discard), Scopes: admin, RateLimit: "admin"},
+	}
+
+	if readCache != nil {
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/admin/cache", Handler: e.Handle(cachestats.New(readCache).Read), Scopes: admin, RateLimit: "admin", Cache: "no-store"},
+		)
+	}
+
+	if reporter != nil {
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/admin/telemetry", Handler: e.Handle(usage.New(reporter).Read), Scopes: admin, RateLimit: "admin"},
+		)
+	}
+
+	if sessions != nil {
+		routes = append(routes,
+			Route{Method: http.MethodPost, Pattern: "/auth/login", Handler: e.Handle(authAPI.Login), Public: true, RateLimit: "login", Cache: "no-store"},
+			Route{Method: http.MethodPost, Pattern: "/auth/logout", Handler: e.Handle(authAPI.Logout), Public: true, RateLimit: "auth"},
+		)
+	}
+
+	if idx != nil {
+		searchAPI := book.NewSearchAPI(db, idx, policy)
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/books/search", Handler: e.Handle(searchAPI.Search), Role: viewer, Heavy: true},
+			Route{Method: http.MethodPost, Pattern: "/admin/search/rebuild", Handler: e.Handle(searchAPI.Rebuild), Scopes: admin, RateLimit: "admin"},
+		)
+	}
+
+	if c.Metadata.ProviderURL != "" {
+		provider := meta.NewOpenLibrary(c.Metadata.ProviderURL, c.Metadata.Timeout)
+		metadataAPI := metadata.New(meta.NewProxy(provider, &c.Metadata))
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/metadata/isbn/{isbn}", Handler: e.Handle(metadataAPI.Lookup), Cache: "private, max-age=3600"},
+		)
+	}
+
+	if c.Store.Enabled {
+		orderAPI := order.New(db, v, &c.Store)
+		lc.Go("reservation_expiry", func(ctx context.Context) { orderAPI.ExpireReservations(ctx, c.Store.ReservationSweep) })
+		book.Computed.Register("availability", order.AvailabilityResolver(order.NewRepository(db), func(b *book.Book) uuid.UUID { return b.ID }))
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/cart", Handler: e.Handle(orderAPI.Cart), Cache: "no-store"},
+			Route{Method: http.MethodPut, Pattern: "/cart/items/{id}", Handler: e.Handle(orderAPI.PutCartItem)},
+			Route{Method: http.MethodDelete, Pattern: "/cart/items/{id}", Handler: e.Handle(orderAPI.DeleteCartItem)},
+			Route{Method: http.MethodGet, Pattern: "/orders", Handler: e.Handle(orderAPI.List)},
+			Route{Method: http.MethodPost, Pattern: "/orders", Handler: e.Handle(orderAPI.Create)},
+			Route{Method: http.MethodGet, Pattern: "/orders/{id}", Handler: e.Handle(orderAPI.Read)},
+			Route{Method: http.MethodPost, Pattern: "/orders/{id}/transitions", Handler: e.Handle(orderAPI.Transition)},
+			Route{Method: http.MethodPost, Pattern: "/admin/orders/{id}/transitions", Handler: e.Handle(orderAPI.AdminTransition), Scopes: admin, RateLimit: "admin"},
+			Route{Method: http.MethodPut, Pattern: "/admin/stock/{id}", Handler: e.Handle(orderAPI.PutStock), Scopes: admin, RateLimit: "admin"},
+		)
+
+		transferAPI := transfer.New(db, v, mailer)
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/copies", Handler: e.Handle(transferAPI.Copies), Cache: "no-store"},
+			Route{Method: http.MethodPost, Pattern: "/copies/{id}/transfers", Handler: e.Handle(transferAPI.Offer)},
+			Route{Method: http.MethodGet, Pattern: "/transfers", Handler: e.Handle(transferAPI.List), Cache: "no-store"},
+			Route{Method: http.MethodGet, Pattern: "/transfers/{id}", Handler: e.Handle(transferAPI.Read), Cache: "no-store"},
+			Route{Method: http.MethodPost, Pattern: "/transfers/{id}/accept", Handler: e.Handle(transferAPI.Accept)},
+			Route{Method: http.MethodPost, Pattern: "/transfers/{id}/decline", Handler: e.Handle(transferAPI.Decline)},
+			Route{Method: http.MethodPost, Pattern: "/transfers/{id}/cancel", Handler: e.Handle(transferAPI.Cancel)},
+		)
+
+		if c.Payment.WebhookSecret != "" || c.Payment.Sandbox {
+			paymentAPI := payment.New(db, &c.Payment)
+			routes = append(routes,
+				Route{Method: http.MethodPost, Pattern: "/payments/webhook", Handler: e.Handle(paymentAPI.Webhook), Public: true},
+			)
+			if c.Payment.Sandbox {
+				routes = append(routes,
+					Route{Method: http.MethodPost, Pattern: "/payments/sandbox/events", Handler: e.Handle(paymentAPI.SandboxEvent), Public: true},
+				)
+			}
+		}
+	}
+
+	var recorder *interaction.Recorder
+	if c.Interaction.Enabled {
+		recorder = interaction.NewRecorder(db, &c.Interaction)
+		lc.Go("interactions", func(ctx context.Context) { recorder.Run(ctx, c.Interaction.FlushInterval) })
+		routes = append(routes,
+			Route{Method: http.MethodPost, Pattern: "/events", Handler: e.Handle(interaction.New(recorder, v).Create)},
+		)
+	}
+
+	// Handlers look up the caller's variant with exp.VariantOf; exposures
+	// are logged with the interaction events.
+	var experiments *exp.Service
+	if c.Experiment.Enabled {
+		static, err := exp.Load(c.Experiment.Path)
+		if err != nil {
+			log.Fatalf("Failed to load experiments: %s", err)
+		}
+		var exposures exp.Logger
+		if recorder != nil {
+			exposures = recorder
+		}
+		experiments = exp.New(static, exposures)
+
+		load := experiment.Loader(db)
+		if stored, err := load(context.Background()); err != nil {
+			log.Printf("experiment load: %s", err)
+		} else {
+			experiments.Set(stored)
+		}
+		lc.Go("experiments", func(ctx context.Context) { experiments.Run(ctx, c.Experiment.RefreshInterval, load) })
+
+		experimentAPI := experiment.New(db, v, experiments)
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/experiments", Handler: e.Handle(experimentAPI.Assignments), Cache: "no-store"},
+			Route{Method: http.MethodGet, Pattern: "/admin/experiments", Handler: e.Handle(experimentAPI.List), Scopes: admin, RateLimit: "admin"},
+			Route{Method: http.MethodPut, Pattern: "/admin/experiments/{key}", Handler: e.Handle(experimentAPI.Put), Scopes: admin, RateLimit: "admin"},
+			Route{Method: http.MethodDelete, Pattern: "/admin/experiments/{key}", Handler: e.Handle(experimentAPI.Delete), Scopes: admin, RateLimit: "admin"},
+		)
+	}
+
+	if requestJournal != nil {
+		journalAPI := journal.New(requestJournal, v)
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/admin/journal", Handler: e.Handle(journalAPI.Read), Scopes: admin, RateLimit: "admin"},
+			Route{Method: http.MethodPut, Pattern: "/admin/journal", Handler: e.Handle(journalAPI.Update), Scopes: admin, RateLimit: "admin"},
+		)
+	}
+
+	// Old links to books merged into others lead on to the merged-into book.
+	for i, rt := range routes {
+		if strings.HasPrefix(rt.Pattern, "/books/{id}") {
+			routes[i].Handler = bookAPI.FollowMerges(rt.Handler)
+		}
+	}
+
+	// Retried creates carrying the same Idempotency-Key get the first
+	// response again instead of creating twice.
+	idempotencyStore := idempotency.New(db, c.Idempotency.TTL)
+	lc.Go("idempotency_sweep", func(ctx context.Context) { idempotencyStore.Run(ctx, c.Idempotency.SweepInterval) })
+
+	// Cache policies of routes may be tuned in a file instead of the code.
+	cachePolicies, err := cache.Load(c.Cache.PolicyPath)
+	if err != nil {
+		log.Fatalf("Failed to load cache policies: %s", err)
+	}
+	cachePolicies.BypassScopes = c.Cache.BypassScopes
+
+	builder := &Builder{
+		RateLimits:   rateLimits,
+		WarnRatio:    c.RateLimit.WarnRatio,
+		Deprecations: deprecations,
+		Idempotency:  idempotencyStore.Middleware,
+		Cache:        cache.New(cachePolicies, bus),
+	}
+	// Exports, imports and searches beyond Queue.Workers at once wait their
+	// turn, per identity, and are answered 202 with an operation to poll.
+	if c.Queue.Workers > 0 {
+		heavy := queue.New(c.Queue.Workers, c.Queue.Depth, c.Queue.ResultTTL)
+		pools.Register("heavy_queue", heavy)
+		builder.Queue = heavy.Middleware
+		routes = append(routes,
+			Route{Method: http.MethodGet, Pattern: "/operations/{id}", Handler: heavy.Read, Cache: "no-store"},
+			Route{Method: http.MethodGet, Pattern: "/operations/{id}/result", Handler: heavy.Result, Cache: "no-store"},
+		)
+	}
+	// Deployments with regulatory restrictions may keep routes from some
+	// networks and countries.
+	if c.Access.RulesPath != "" {
+		builder.Access = newAccess(&c.Access)
+	}
+	if c.SignedURL.Required {
+		builder.Signed = signer.Middleware
+	}
+	if c.Auth.RequireVerifiedEmail {
+		builder.Verified = authAPI.RequireVerified
+	}
+	if c.Auth.RBACEnabled {
+		builder.Roles = authAPI.RequireRole
+	}
 
 	r.Route("/v1", func(r chi.Router) {
-		bookAPI := book.New(db, v)
-		r.Get("/books", bookAPI.List)
-		r.Post("/books", bookAPI.Create)
-		r.Get("/books/{id}", bookAPI.Read)
-		r.Put("/books/{id}", bookAPI.Update)
-		r.Delete("/books/{id}", bookAPI.Delete)
+		r.Use(apiversion.Middleware)
+		r.Use(e.Middleware(validatorUtil.Mapper, genre.Problems, transfer.Problems))
+		r.Use(dbtimeout.Middleware)
+		r.Use(warning.Middleware)
+		r.Use(tenant.Middleware)
+		r.Use(scope.Middleware)
+		r.Use(user.Middleware)
+		if sessions != nil {
+			r.Use(sessions.Middleware)
+		}
+		r.Use(serviceAccountAPI.Middleware)
+		r.Use(apiKeyAPI.Middleware)
+		// The global quota is counted per authenticated API key, so it
+		// follows the middleware checking them.
+		if c.RateLimit.Requests > 0 {
+			limiter := newLimiter("global", c.RateLimit.Requests, c.RateLimit.Window)
+			r.Use(ratelimit.New(limiter, ratelimit.ClientKey, c.RateLimit.WarnRatio))
+		}
+		r.Use(coalesce.New())
+		if auditLog != nil {
+			r.Use(auditLog.Middleware)
+		}
+		if experiments != nil {
+			r.Use(experiments.Middleware)
+		}
+		if requestJournal != nil {
+			r.Use(requestJournal.Middleware)
+		}
+		if spec != nil {
+			r.Use(spec.Middleware(c.OpenAPI.Enforce))
+		}
+
+		if err := builder.Mount(r, routes); err != nil {
+			log.Fatalf("Failed to mount routes: %s", err)
+		}
 	})
 	return r
 }
+
+func newAccess(c *config.ConfAccess) *access.Access {
+	rules, err := access.Load(c.RulesPath)
+	if err != nil {
+		log.Fatalf("Failed to load access rules: %s", err)
+	}
+	trusted, err := access.ParseCIDRs(c.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid trusted proxies: %s", err)
+	}
+
+	var locator access.Locator
+	if c.GeoIPPath != "" {
+		db, err := geoip.Open(c.GeoIPPath)
+		if err != nil {
+			log.Fatalf("Failed to open GeoIP database: %s", err)
+		}
+		locator = db
+	} else if rules.UsesCountries() {
+		log.Fatalf("Access rules name countries but ACCESS_GEOIP_PATH is not set")
+	}
+	return access.New(rules, locator, trusted)
+}
+
+// Features names the optional subsystems c enables, for telemetry and the
+// startup banner.
+func Features(c *config.Conf, idx search.Index) []string {
+	var fs []string
+	add := func(enabled bool, name string) {
+		if enabled {
+			fs = append(fs, name)
+		}
+	}
+
+	add(idx != nil, "search")
+	add(c.Session.Enabled, "sessions:"+c.Session.Store)
+	add(c.Auth.RequireVerifiedEmail, "verified_email")
+	add(c.Auth.RBACEnabled, "rbac")
+	add(c.SignedURL.Required, "signed_urls")
+	add(c.OpenAPI.SpecPath != "", "openapi")
+	add(c.Journal.Path != "", "journal")
+	add(c.FieldPolicy.Path != "", "field_policy")
+	add(c.Cache.PolicyPath != "", "cache_policy")
+	add(c.Cache.BookTTL > 0 || c.Cache.BookListTTL > 0, "book_cache:"+c.Cache.Store)
+	add(c.Scan.ClamAVAddr != "", "virus_scan")
+	add(c.Book.CheckImageURL, "image_url_check")
+	add(c.Mail.SMTPAddr != "", "smtp")
+	add(c.Release.FeedURL != "", "release_check")
+	add(c.Metadata.ProviderURL != "", "metadata")
+	add(c.Store.Enabled, "store")
+	add(c.Store.Enabled && c.Payment.Sandbox, "payment_sandbox")
+	add(c.PublicID.Codec != idcodec.NameUUID, "id_codec:"+c.PublicID.Codec)
+	add(c.RateLimit.Requests > 0, "rate_limit")
+	add(c.Storage.Backend != "", "storage:"+c.Storage.Backend)
+	add(c.Backup.Backend != "", "backup:"+c.Backup.Backend)
+	add(c.Tracing.Enabled, "tracing")
+	add(c.Pricing.RulesPath != "", "pricing")
+	add(c.Interaction.Enabled, "interactions")
+	add(c.Experiment.Enabled, "experiments")
+	add(c.Queue.Workers > 0, "heavy_queue")
+	add(c.Access.RulesPath != "", "access_rules")
+	add(len(c.Audit.Sinks) > 0, "audit:"+strings.Join(c.Audit.Sinks, "+"))
+	return fs
+}
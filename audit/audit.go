@@ -0,0 +1,235 @@
+// Package audit records security-relevant actions, such as issuing
+// credentials or resetting passwords, to one or more sinks at once. Actions
+// whose trail must not be lost are recorded with Record, which returns only
+// once every sink has taken the entry, so that handlers can hold back their
+// response until then. Others are noted with Note and written in the
+// background.
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/logger"
+	"hello/api/middleware/tenant"
+	"hello/api/middleware/user"
+)
+
+// Actor types.
+const (
+	ActorUser           = "user"
+	ActorServiceAccount = "service_account"
+	ActorAPIKey         = "api_key"
+	ActorAnonymous      = "anonymous"
+)
+
+// Details holds action-specific values, stored as a JSON object.
+type Details map[string]any
+
+func (d Details) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(d)
+	return string(b), err
+}
+
+func (d *Details) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	default:
+		return errors.New("audit: unsupported Details source")
+	}
+}
+
+// Entry is one audited action: who did what to which target, from where.
+type Entry struct {
+	ID         uuid.UUID `gorm:"primarykey" json:"id"`
+	Action     string    `json:"action"`
+	TenantID   string    `json:"tenant_id"`
+	ActorType  string    `json:"actor_type"`
+	ActorID    string    `json:"actor_id,omitempty"`
+	Target     string    `json:"target,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Details    Details   `gorm:"type:jsonb" json:"details,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (Entry) TableName() string {
+	return "audit_log"
+}
+
+// Sink stores entries. Write returns once they are durably stored, or
+// with an error.
+type Sink interface {
+	Write(ctx context.Context, entries []*Entry) error
+}
+
+// Log writes entries to all of its sinks.
+type Log struct {
+	sinks      []Sink
+	bufferSize int
+	now        func() time.Time
+
+	mu sync.Mutex
+	// pending holds the noted entries not yet written, per sink, so that a
+	// failing sink is retried without writing twice to the others.
+	pending [][]*Entry
+}
+
+func New(bufferSize int, sinks ...Sink) *Log {
+	return &Log{
+		sinks:      sinks,
+		bufferSize: bufferSize,
+		now:        time.Now,
+		pending:    make([][]*Entry, len(sinks)),
+	}
+}
+
+// Write writes entries to every sink, returning once all have taken them.
+// A failing sink does not keep the others from being written.
+func (l *Log) Write(ctx context.Context, entries ...*Entry) error {
+	var errs []error
+	for _, s := range l.sinks {
+		if err := s.Write(ctx, entries); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Enqueue buffers entries for the next flush. Once bufferSize entries wait
+// for a sink, further ones are dropped for it.
+func (l *Log) Enqueue(entries ...*Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range l.pending {
+		room := l.bufferSize - len(l.pending[i])
+		if room < len(entries) {
+			log.Printf("audit: buffer full, dropped %d entries", len(entries)-max(room, 0))
+		}
+		l.pending[i] = append(l.pending[i], entries[:min(max(room, 0), len(entries))]...)
+	}
+}
+
+// Flush writes the buffered entries. The entries of a sink that fails are
+// kept, ahead of those buffered since, for the next flush.
+func (l *Log) Flush(ctx context.Context) error {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = make([][]*Entry, len(l.sinks))
+	l.mu.Unlock()
+
+	var errs []error
+	for i, s := range l.sinks {
+		if len(pending[i]) == 0 {
+			continue
+		}
+		if err := s.Write(ctx, pending[i]); err != nil {
+			errs = append(errs, err)
+			l.mu.Lock()
+			l.pending[i] = append(pending[i], l.pending[i]...)
+			l.mu.Unlock()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run flushes every interval until ctx is done, then flushes once more.
+func (l *Log) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := l.Flush(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("audit flush: %s", err)
+			}
+			return
+		case <-ticker.C:
+			if err := l.Flush(ctx); err != nil {
+				log.Printf("audit flush: %s", err)
+			}
+		}
+	}
+}
+
+type ctxKey struct{}
+
+// Middleware makes the log available to Record and Note.
+func (l *Log) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, l)))
+	})
+}
+
+// Record writes an entry for action on target, made by the caller of r, to
+// every sink before returning. Handlers of security-critical actions call
+// it after the change and answer with an error when it fails, so that no
+// such action succeeds without a trail. It is a no-op when the request did
+// not pass through the middleware.
+func Record(r *http.Request, action, target string, details Details) error {
+	l, ok := r.Context().Value(ctxKey{}).(*Log)
+	if !ok {
+		return nil
+	}
+	if err := l.Write(r.Context(), l.entry(r, action, target, details)); err != nil {
+		log.Printf("audit %s %s: %s", action, target, err)
+		return err
+	}
+	return nil
+}
+
+// Note is Record for actions whose entry may be written after the response.
+func Note(r *http.Request, action, target string, details Details) {
+	l, ok := r.Context().Value(ctxKey{}).(*Log)
+	if !ok {
+		return
+	}
+	l.Enqueue(l.entry(r, action, target, details))
+}
+
+func (l *Log) entry(r *http.Request, action, target string, details Details) *Entry {
+	ctx := r.Context()
+	e := &Entry{
+		ID:         uuid.New(),
+		Action:     action,
+		TenantID:   tenant.From(ctx),
+		ActorType:  ActorAnonymous,
+		Target:     target,
+		IP:         r.RemoteAddr,
+		RequestID:  logger.RequestID(ctx),
+		Details:    details,
+		OccurredAt: l.now().UTC(),
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		e.IP = host
+	}
+
+	if id, ok := user.Service(ctx); ok {
+		e.ActorType, e.ActorID = ActorServiceAccount, id.String()
+	} else if id, ok := user.APIKey(ctx); ok {
+		e.ActorType, e.ActorID = ActorAPIKey, id.String()
+	} else if id, ok := user.From(ctx); ok {
+		e.ActorType, e.ActorID = ActorUser, id.String()
+	}
+	return e
+}
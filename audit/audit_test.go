@@ -0,0 +1,169 @@
+package audit_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hello/audit"
+	testUtil "hello/util/test"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	fail    bool
+	entries []*audit.Entry
+}
+
+func (s *memorySink) Write(_ context.Context, entries []*audit.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("sink down")
+	}
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *memorySink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	ok, down := &memorySink{}, &memorySink{fail: true}
+	l := audit.New(10, ok, down)
+
+	var recordErr error
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordErr = audit.Record(r, "user.password_reset", "42", audit.Details{"via": "token"})
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "192.0.2.1:4321"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The failing sink fails the record, but does not keep the other from
+	// taking it.
+	testUtil.Equal(t, true, recordErr != nil)
+	testUtil.Equal(t, 1, ok.len())
+	testUtil.Equal(t, "user.password_reset", ok.entries[0].Action)
+	testUtil.Equal(t, "42", ok.entries[0].Target)
+	testUtil.Equal(t, audit.ActorAnonymous, ok.entries[0].ActorType)
+	testUtil.Equal(t, "192.0.2.1", ok.entries[0].IP)
+
+	// Without the middleware there is nothing to record to.
+	testUtil.NoError(t, audit.Record(req, "user.password_reset", "42", nil))
+}
+
+func TestNoteFlush(t *testing.T) {
+	t.Parallel()
+
+	ok, down := &memorySink{}, &memorySink{fail: true}
+	l := audit.New(2, ok, down)
+
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audit.Note(r, "user.login", "", nil)
+	}))
+	for range 3 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	}
+	testUtil.Equal(t, 0, ok.len())
+
+	// The buffer holds two entries per sink; the third is dropped.
+	testUtil.Equal(t, true, l.Flush(context.Background()) != nil)
+	testUtil.Equal(t, 2, ok.len())
+
+	// Only the failing sink's entries are kept and written once it is back.
+	down.mu.Lock()
+	down.fail = false
+	down.mu.Unlock()
+	testUtil.NoError(t, l.Flush(context.Background()))
+	testUtil.Equal(t, 2, ok.len())
+	testUtil.Equal(t, 2, down.len())
+}
+
+func TestFileSink(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	s, err := audit.OpenFileSink(path)
+	testUtil.NoError(t, err)
+	defer s.Close()
+
+	testUtil.NoError(t, s.Write(context.Background(), []*audit.Entry{{Action: "a"}, {Action: "b"}}))
+	testUtil.NoError(t, s.Write(context.Background(), []*audit.Entry{{Action: "c"}}))
+
+	b, err := os.ReadFile(path)
+	testUtil.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	testUtil.Equal(t, 3, len(lines))
+
+	var e audit.Entry
+	testUtil.NoError(t, json.Unmarshal([]byte(lines[2]), &e))
+	testUtil.Equal(t, "c", e.Action)
+}
+
+func TestSyslogSink(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	testUtil.NoError(t, err)
+	defer ln.Close()
+
+	msgs := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		rd := bufio.NewReader(conn)
+		for range 2 {
+			size, err := rd.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(rd, msg); err != nil {
+				return
+			}
+			msgs <- string(msg)
+		}
+	}()
+
+	s := audit.NewSyslogSink("tcp", ln.Addr().String(), time.Second)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	testUtil.NoError(t, s.Write(context.Background(), []*audit.Entry{
+		{Action: "service_account.created", ActorType: audit.ActorUser, OccurredAt: at},
+		{Action: "service_account.deleted", ActorType: audit.ActorUser, OccurredAt: at},
+	}))
+
+	for _, action := range []string{"service_account.created", "service_account.deleted"} {
+		msg := <-msgs
+		testUtil.Equal(t, true, strings.HasPrefix(msg, "<85>1 2024-05-01T12:00:00Z "))
+
+		fields := strings.SplitN(msg, " ", 8)
+		testUtil.Equal(t, action, fields[5])
+
+		var e audit.Entry
+		testUtil.NoError(t, json.Unmarshal([]byte(fields[7]), &e))
+		testUtil.Equal(t, action, e.Action)
+	}
+}
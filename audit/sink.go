@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hello/config"
+)
+
+// Sink names.
+const (
+	SinkDB     = "db"
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+)
+
+// NewFromConfig returns the log writing to the configured sinks, or nil
+// when none is configured.
+func NewFromConfig(c *config.ConfAudit, db *gorm.DB) (*Log, error) {
+	var sinks []Sink
+	for _, name := range c.Sinks {
+		switch name {
+		case SinkDB:
+			sinks = append(sinks, NewDBSink(db))
+		case SinkFile:
+			s, err := OpenFileSink(c.FilePath)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, s)
+		case SinkSyslog:
+			sinks = append(sinks, NewSyslogSink(c.SyslogNetwork, c.SyslogAddr, c.SyslogTimeout))
+		default:
+			return nil, fmt.Errorf("audit: unknown sink %q", name)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return New(c.BufferSize, sinks...), nil
+}
+
+// DBSink stores entries in the audit_log table.
+type DBSink struct {
+	db *gorm.DB
+}
+
+func NewDBSink(db *gorm.DB) *DBSink {
+	return &DBSink{db: db}
+}
+
+func (s *DBSink) Write(ctx context.Context, entries []*Entry) error {
+	return s.db.WithContext(ctx).CreateInBatches(entries, 100).Error
+}
+
+// FileSink appends entries to a file as JSON lines, syncing after each
+// write. The file is only ever appended to, so it can be shipped by a log
+// collector or made append-only by the file system.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, entries []*Entry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// SyslogSink sends entries to a syslog server or SIEM as RFC 5424
+// messages with the entry as JSON, one message per entry. Over TCP messages
+// are framed by octet counting (RFC 6587). The connection is opened on
+// first use and again after a failure.
+type SyslogSink struct {
+	network string
+	addr    string
+	timeout time.Duration
+	host    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// facility authpriv (10), severity notice (5)
+const syslogPriority = 10*8 + 5
+
+func NewSyslogSink(network, addr string, timeout time.Duration) *SyslogSink {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "-"
+	}
+	return &SyslogSink{
+		network: network,
+		addr:    addr,
+		timeout: timeout,
+		host:    host,
+	}
+}
+
+func (s *SyslogSink) Write(ctx context.Context, entries []*Entry) error {
+	// Over UDP each message is a datagram of its own; over TCP they are
+	// framed and sent at once.
+	var packets [][]byte
+	var stream bytes.Buffer
+	for _, e := range entries {
+		msg, err := s.format(e)
+		if err != nil {
+			return err
+		}
+		if s.network == "udp" {
+			packets = append(packets, msg)
+			continue
+		}
+		stream.WriteString(strconv.Itoa(len(msg)))
+		stream.WriteByte(' ')
+		stream.Write(msg)
+	}
+	if stream.Len() > 0 {
+		packets = append(packets, stream.Bytes())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		d := net.Dialer{Timeout: s.timeout}
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	for _, p := range packets {
+		if _, err := s.conn.Write(p); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink) format(e *Entry) ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("<%d>1 %s %s hello %d %s - ",
+		syslogPriority, e.OccurredAt.Format(time.RFC3339Nano), s.host, os.Getpid(), msgID(e.Action))
+	return append([]byte(header), body...), nil
+}
+
+// msgID fits an action into the MSGID field: printable ASCII, at most 32
+// characters.
+func msgID(action string) string {
+	b := []byte(action)
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 32 {
+		b = b[:32]
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
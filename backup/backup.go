@@ -0,0 +1,232 @@
+// Package backup copies uploaded content, covers and attachments, to a
+// second store and back. Content is stored once under its hash and never
+// changes under its key, so a sync only copies the blobs missing from the
+// manifest the backup keeps of what it holds.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hello/api/resource/blob"
+	"hello/storage"
+)
+
+// ManifestKey is where the manifest lives in the backup store.
+const ManifestKey = "manifest.json"
+
+const pageSize = 100
+
+var ErrNoManifest = errors.New("backup: no manifest in the backup store")
+
+// Manifest lists the blobs a backup holds by hash. Blobs are kept after
+// they are removed from the primary store, so that a restore can bring back
+// content deleted by mistake as long as its records are restored too.
+type Manifest struct {
+	Blobs     map[string]*Item `json:"blobs"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+type Item struct {
+	Size       int64     `json:"size"`
+	BackedUpAt time.Time `json:"backed_up_at"`
+}
+
+// Result counts the blobs a sync or restore copied, found in place, and
+// failed to copy. Failed blobs are logged and tried again on the next run.
+type Result struct {
+	Copied  int
+	Skipped int
+	Failed  int
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d copied, %d skipped, %d failed", r.Copied, r.Skipped, r.Failed)
+}
+
+// Backup copies the referenced blobs of the primary store to the
+// secondary one.
+type Backup struct {
+	blobs     *blob.Repository
+	primary   storage.Store
+	secondary storage.Store
+	now       func() time.Time
+}
+
+// New returns a backup of primary to secondary. The database is only used
+// by Sync, to list the referenced blobs.
+func New(db *gorm.DB, primary, secondary storage.Store) *Backup {
+	return &Backup{
+		blobs:     blob.NewRepository(db),
+		primary:   primary,
+		secondary: secondary,
+		now:       time.Now,
+	}
+}
+
+// Sync copies the referenced blobs the backup does not hold yet. The
+// manifest is saved after every page, so an interrupted sync resumes where
+// it stopped.
+func (b *Backup) Sync(ctx context.Context) (Result, error) {
+	var res Result
+	m, err := b.manifest(ctx)
+	if errors.Is(err, ErrNoManifest) {
+		m = &Manifest{Blobs: map[string]*Item{}}
+	} else if err != nil {
+		return res, err
+	}
+
+	after := ""
+	for {
+		page, err := b.blobs.Referenced(after, pageSize)
+		if err != nil {
+			return res, err
+		}
+
+		copied := res.Copied
+		for _, bl := range page {
+			if item, ok := m.Blobs[bl.Hash]; ok && item.Size == bl.Size {
+				res.Skipped++
+				continue
+			}
+			if err := copyBlob(ctx, b.primary, b.secondary, bl.Hash); err != nil {
+				log.Printf("backup %s: %s", bl.Hash, err)
+				res.Failed++
+				continue
+			}
+			m.Blobs[bl.Hash] = &Item{Size: bl.Size, BackedUpAt: b.now().UTC()}
+			res.Copied++
+		}
+		if res.Copied > copied {
+			if err := b.save(ctx, m); err != nil {
+				return res, err
+			}
+		}
+
+		if len(page) < pageSize {
+			return res, nil
+		}
+		after = page[len(page)-1].Hash
+	}
+}
+
+// Restore copies the blobs of the manifest that are missing from the
+// primary store back into it. It restores content only: the records
+// referencing it come from the database's own backups.
+func (b *Backup) Restore(ctx context.Context) (Result, error) {
+	var res Result
+	m, err := b.manifest(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	hashes := make([]string, 0, len(m.Blobs))
+	for hash := range m.Blobs {
+		hashes = append(hashes, hash)
+	}
+	slices.Sort(hashes)
+
+	for _, hash := range hashes {
+		existing, err := b.primary.Open(ctx, blob.Key(hash))
+		if err == nil {
+			existing.Close()
+			res.Skipped++
+			continue
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			err = copyBlob(ctx, b.secondary, b.primary, hash)
+		}
+		if err != nil {
+			log.Printf("restore %s: %s", hash, err)
+			res.Failed++
+			continue
+		}
+		res.Copied++
+	}
+	return res, nil
+}
+
+// Run syncs every interval until ctx is done.
+func (b *Backup) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := b.Sync(ctx)
+			if err != nil {
+				log.Printf("backup sync: %s", err)
+			}
+			if res.Copied > 0 || res.Failed > 0 {
+				log.Printf("backup sync: %s", res)
+			}
+		}
+	}
+}
+
+func (b *Backup) manifest(ctx context.Context) (*Manifest, error) {
+	f, err := b.secondary.Open(ctx, ManifestKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNoManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &Manifest{}
+	if err := json.NewDecoder(f).Decode(m); err != nil {
+		return nil, fmt.Errorf("backup: read manifest: %w", err)
+	}
+	if m.Blobs == nil {
+		m.Blobs = map[string]*Item{}
+	}
+	return m, nil
+}
+
+func (b *Backup) save(ctx context.Context, m *Manifest) error {
+	m.UpdatedAt = b.now().UTC()
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = b.secondary.Put(ctx, ManifestKey, bytes.NewReader(data))
+	return err
+}
+
+// copyBlob copies the blob of hash from one store to the other, checking
+// its content against the hash on the way. Content that does not match is
+// removed from the destination again rather than spread.
+func copyBlob(ctx context.Context, from, to storage.Store, hash string) error {
+	content, err := from.Open(ctx, blob.Key(hash))
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	h := sha256.New()
+	if _, err := to.Put(ctx, blob.Key(hash), io.TeeReader(content, h)); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != hash {
+		if err := to.Delete(ctx, blob.Key(hash)); err != nil {
+			log.Printf("backup delete %s: %s", hash, err)
+		}
+		return fmt.Errorf("backup: content does not match its hash, got %s", sum)
+	}
+	return nil
+}
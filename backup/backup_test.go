@@ -0,0 +1,72 @@
+package backup_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"hello/api/resource/blob"
+	"hello/backup"
+	mockDB "hello/mock/db"
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+func TestBackup(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &blob.Blob{})
+
+	primary, err := storage.NewFS(t.TempDir())
+	testUtil.NoError(t, err)
+	secondary, err := storage.NewFS(t.TempDir())
+	testUtil.NoError(t, err)
+
+	ctx := context.Background()
+	blobs := blob.NewStore(db, primary)
+	put := func(content string) *blob.Blob {
+		b, err := blobs.Put(ctx, strings.NewReader(content))
+		testUtil.NoError(t, err)
+		return b
+	}
+	cover, manual := put("cover"), put("manual")
+
+	bk := backup.New(db, primary, secondary)
+	_, err = bk.Restore(ctx)
+	testUtil.Equal(t, backup.ErrNoManifest, err)
+
+	res, err := bk.Sync(ctx)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, backup.Result{Copied: 2}, res)
+
+	// Only new content is copied on the next run.
+	put("errata")
+	res, err = bk.Sync(ctx)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, backup.Result{Copied: 1, Skipped: 2}, res)
+
+	// Content that does not match its hash is not backed up.
+	corrupt := put("index")
+	_, err = primary.Put(ctx, blob.Key(corrupt.Hash), strings.NewReader("tampered"))
+	testUtil.NoError(t, err)
+	res, err = bk.Sync(ctx)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, backup.Result{Skipped: 3, Failed: 1}, res)
+	_, err = secondary.Open(ctx, blob.Key(corrupt.Hash))
+	testUtil.Equal(t, storage.ErrNotFound, err)
+
+	// Losing content in the primary store is undone by a restore.
+	testUtil.NoError(t, primary.Delete(ctx, blob.Key(cover.Hash)))
+	testUtil.NoError(t, primary.Delete(ctx, blob.Key(manual.Hash)))
+	res, err = bk.Restore(ctx)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, backup.Result{Copied: 2, Skipped: 1}, res)
+
+	f, err := primary.Open(ctx, blob.Key(cover.Hash))
+	testUtil.NoError(t, err)
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "cover", string(content))
+}
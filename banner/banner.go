@@ -0,0 +1,237 @@
+// Package banner logs what a starting instance runs with: its effective
+// configuration, with secrets redacted, the subsystems it enables, the
+// migration its database is at and the addresses it listens on. The summary
+// is kept on local disk so that the next boot can log what changed.
+package banner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// secretNames are the parts of a setting's name that mark it as secret.
+var secretNames = []string{"PASS", "SECRET", "KEY", "TOKEN"}
+
+// Summary describes one boot.
+type Summary struct {
+	Version    string            `json:"version"`
+	Commit     string            `json:"commit"`
+	Config     map[string]string `json:"config"`
+	Subsystems []string          `json:"subsystems"`
+	Migration  int64             `json:"migration"`
+	Listen     []string          `json:"listen"`
+	// Secrets holds a fingerprint of each secret setting, so that a changed
+	// secret shows in the diff without its value being kept. It is saved
+	// with the summary but never logged.
+	Secrets map[string]string `json:"secrets,omitempty"`
+}
+
+// Flatten records the settings of c, a configuration struct, by their
+// environment variable names. Secret settings are redacted in Config and
+// fingerprinted in Secrets, and passwords are removed from URLs.
+func (s *Summary) Flatten(c any) {
+	s.Config = make(map[string]string)
+	s.Secrets = make(map[string]string)
+	flatten(reflect.Indirect(reflect.ValueOf(c)), s)
+}
+
+func flatten(v reflect.Value, s *Summary) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("env")
+		if tag == "" {
+			if value.Kind() == reflect.Struct {
+				flatten(value, s)
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		setting := format(value)
+		if secret(name) {
+			if setting != "" {
+				s.Secrets[name] = fingerprint(setting)
+				setting = redacted
+			}
+		} else {
+			setting = stripPassword(setting)
+		}
+		s.Config[name] = setting
+	}
+}
+
+// format writes a value the way it would be set in the environment, with
+// list items separated by semicolons.
+func format(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ";")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func secret(name string) bool {
+	for _, part := range secretNames {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func fingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// stripPassword redacts the password of a URL with credentials, leaving
+// other values alone.
+func stripPassword(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "redacted")
+	}
+	return u.String()
+}
+
+// Log writes the summary as a single JSON line.
+func (s *Summary) Log() {
+	shown := *s
+	shown.Secrets = nil
+	b, err := json.Marshal(&shown)
+	if err != nil {
+		log.Printf("Boot summary: %s", err)
+		return
+	}
+	log.Printf("Boot summary: %s", b)
+}
+
+// Diff lists what changed since prev, one line per change, sorted.
+func (s *Summary) Diff(prev *Summary) []string {
+	var changes []string
+	add := func(format string, args ...any) {
+		changes = append(changes, fmt.Sprintf(format, args...))
+	}
+
+	if prev.Version != s.Version || prev.Commit != s.Commit {
+		add("version: %s (%s) -> %s (%s)", prev.Version, prev.Commit, s.Version, s.Commit)
+	}
+	if prev.Migration != s.Migration {
+		add("migration: %d -> %d", prev.Migration, s.Migration)
+	}
+	if !slices.Equal(prev.Listen, s.Listen) {
+		add("listen: %s -> %s", strings.Join(prev.Listen, ","), strings.Join(s.Listen, ","))
+	}
+	for _, name := range s.Subsystems {
+		if !slices.Contains(prev.Subsystems, name) {
+			add("subsystem enabled: %s", name)
+		}
+	}
+	for _, name := range prev.Subsystems {
+		if !slices.Contains(s.Subsystems, name) {
+			add("subsystem disabled: %s", name)
+		}
+	}
+
+	var settings []string
+	for name, value := range s.Config {
+		old, ok := prev.Config[name]
+		switch {
+		case !ok:
+			settings = append(settings, fmt.Sprintf("%s: added %q", name, value))
+		case s.Secrets[name] != prev.Secrets[name]:
+			settings = append(settings, fmt.Sprintf("%s: changed", name))
+		case old != value:
+			settings = append(settings, fmt.Sprintf("%s: %q -> %q", name, old, value))
+		}
+	}
+	for name := range prev.Config {
+		if _, ok := s.Config[name]; !ok {
+			settings = append(settings, fmt.Sprintf("%s: removed", name))
+		}
+	}
+	sort.Strings(settings)
+	return append(changes, settings...)
+}
+
+// Load reads the summary saved at path. A missing file yields nil.
+func Load(path string) (*Summary, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s Summary
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("banner: %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Save writes the summary to path, readable by its owner only.
+func (s *Summary) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// Report logs the summary and, when statePath is set, what changed since the
+// boot saved there, then saves this boot in its place.
+func (s *Summary) Report(statePath string) {
+	s.Log()
+	if statePath == "" {
+		return
+	}
+
+	prev, err := Load(statePath)
+	switch {
+	case err != nil:
+		log.Printf("Boot diff unavailable: %s", err)
+	case prev == nil:
+		log.Printf("Boot diff: no previous boot recorded in %s", statePath)
+	default:
+		changes := s.Diff(prev)
+		if len(changes) == 0 {
+			log.Printf("Boot diff: unchanged since the previous boot")
+		}
+		for _, c := range changes {
+			log.Printf("Boot diff: %s", c)
+		}
+	}
+
+	if err := s.Save(statePath); err != nil {
+		log.Printf("Failed to save boot state: %s", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Package buildinfo describes the running build. Version, Commit and Date
+// are set at build time:
+//
+//	go build -ldflags "-X hello/buildinfo.Version=v1.2.0 -X hello/buildinfo.Commit=$(git rev-parse HEAD) -X hello/buildinfo.Date=$(date -u +%FT%TZ)"
+//
+// Without them the commit and date fall back to the VCS stamp Go embeds
+// when building from a checkout.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build's info.
+func Get() Info {
+	once.Do(func() {
+		info = Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.Date == "":
+				info.Date = s.Value
+			}
+		}
+	})
+	return info
+}
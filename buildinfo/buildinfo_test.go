@@ -0,0 +1,49 @@
+package buildinfo_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"hello/buildinfo"
+	testUtil "hello/util/test"
+)
+
+func TestOutdated(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		running string
+		latest  string
+		want    bool
+	}{
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.10.0", true},
+		{"1.2.3", "v1.2.4", true},
+		{"v2.0.0", "v1.9.9", false},
+		{"v1.2.0-rc.1", "v1.2.0", true},
+		{"v1.2.0", "v1.2.1-rc.1", true},
+		{"v1.2.0+build.5", "v1.2.0", false},
+		{"dev", "v1.0.0", false},
+		{"v1.0.0", "latest", false},
+	}
+	for _, tc := range tests {
+		testUtil.Equal(t, tc.want, buildinfo.Outdated(tc.running, tc.latest))
+	}
+}
+
+func TestReleaseChecker_Check(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v9.9.9", "name": "Release 9.9.9"}`))
+	}))
+	defer srv.Close()
+
+	rc := buildinfo.NewReleaseChecker(srv.URL, time.Second)
+	testUtil.Equal(t, "", rc.Latest())
+	testUtil.NoError(t, rc.Check(context.Background()))
+	testUtil.Equal(t, "v9.9.9", rc.Latest())
+}
@@ -0,0 +1,151 @@
+package buildinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReleaseChecker polls a release feed and logs when the running version is
+// outdated. The feed answers with the latest release as JSON, either in the
+// GitHub releases API form, {"tag_name": "v1.2.0"}, or as
+// {"version": "v1.2.0"}.
+type ReleaseChecker struct {
+	url    string
+	client *http.Client
+
+	mu     sync.RWMutex
+	latest string
+}
+
+func NewReleaseChecker(url string, timeout time.Duration) *ReleaseChecker {
+	return &ReleaseChecker{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Latest returns the latest release seen in the feed, or "" before the
+// first successful check.
+func (rc *ReleaseChecker) Latest() string {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.latest
+}
+
+// Run checks the feed every interval, and once at start, until ctx is done.
+func (rc *ReleaseChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := rc.Check(ctx); err != nil {
+			log.Printf("release check: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check fetches the latest release and logs when it is newer than the
+// running version.
+func (rc *ReleaseChecker) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rc.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := rc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("release feed: %s", res.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&release); err != nil {
+		return fmt.Errorf("release feed: %w", err)
+	}
+
+	latest := release.TagName
+	if latest == "" {
+		latest = release.Version
+	}
+	if latest == "" {
+		return fmt.Errorf("release feed: no version")
+	}
+
+	rc.mu.Lock()
+	rc.latest = latest
+	rc.mu.Unlock()
+
+	if Outdated(Get().Version, latest) {
+		log.Printf("running version %s is outdated; the latest release is %s", Get().Version, latest)
+	}
+	return nil
+}
+
+// Outdated reports whether the semantic version running is older than
+// latest. Versions that do not parse, such as "dev", are never outdated.
+// Pre-releases precede their release and are compared as plain strings.
+func Outdated(running, latest string) bool {
+	r, ok := parseVersion(running)
+	if !ok {
+		return false
+	}
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+
+	for i := range 3 {
+		if r.parts[i] != l.parts[i] {
+			return r.parts[i] < l.parts[i]
+		}
+	}
+	// A pre-release precedes its release.
+	return r.pre != "" && (l.pre == "" || r.pre < l.pre)
+}
+
+type semver struct {
+	parts [3]int
+	pre   string
+}
+
+func parseVersion(v string) (semver, bool) {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "+")
+	v, pre, _ := strings.Cut(v, "-")
+
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return semver{}, false
+	}
+
+	s := semver{pre: pre}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		s.parts[i] = n
+	}
+	return s, true
+}
@@ -0,0 +1,179 @@
+// Package cache keeps values by key for a while, in memory or in Redis,
+// for read-through caches of data that is expensive to load. Values are
+// bytes, encoded by the caller; a cache shared by instances must not hold
+// anything they encode differently.
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned by Get for keys the cache does not hold.
+var ErrMiss = errors.New("cache: miss")
+
+// Cache keeps values by key.
+type Cache interface {
+	// Get returns the value of key, or ErrMiss.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set keeps value under key for ttl. A zero ttl keeps it until it is
+	// deleted or evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete drops keys. Keys the cache does not hold are ignored.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// MaxEntries bounds the values a Memory cache keeps. When full, expired
+// values are dropped, and new values are not kept until some expire.
+const MaxEntries = 10000
+
+// Memory keeps values in the process, so each instance has its own.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]*entry)}
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[key]
+	if e == nil || e.expired(time.Now()) {
+		return nil, ErrMiss
+	}
+	return e.value, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	e := &entry{value: value}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[key]; !ok && len(m.entries) >= MaxEntries {
+		for k, old := range m.entries {
+			if old.expired(now) {
+				delete(m.entries, k)
+			}
+		}
+		if len(m.entries) >= MaxEntries {
+			return nil
+		}
+	}
+	m.entries[key] = e
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range keys {
+		delete(m.entries, k)
+	}
+	return nil
+}
+
+// Redis keeps values in a Redis server shared by instances, under a prefix
+// telling apart the caches sharing it.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+func (rc *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := rc.client.Get(ctx, rc.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (rc *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl > 0 {
+		ttl = max(time.Millisecond, ttl)
+	}
+	return rc.client.Set(ctx, rc.prefix+key, value, ttl).Err()
+}
+
+func (rc *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, k := range keys {
+		prefixed[i] = rc.prefix + k
+	}
+	return rc.client.Del(ctx, prefixed...).Err()
+}
+
+// Stats counts the lookups of a cache: those answered from it, those it
+// did not hold and those that failed.
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+// Counted is a cache counting its lookups.
+type Counted struct {
+	Cache
+
+	hits, misses, errors atomic.Uint64
+}
+
+func Count(c Cache) *Counted {
+	return &Counted{Cache: c}
+}
+
+func (c *Counted) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.Cache.Get(ctx, key)
+	switch {
+	case err == nil:
+		c.hits.Add(1)
+	case errors.Is(err, ErrMiss):
+		c.misses.Add(1)
+	default:
+		c.errors.Add(1)
+	}
+	return value, err
+}
+
+// Unwrap returns the cache c counts, for lookups that are not to be
+// counted.
+func (c *Counted) Unwrap() Cache {
+	return c.Cache
+}
+
+func (c *Counted) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Errors: c.errors.Load()}
+}
@@ -0,0 +1,88 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"hello/cache"
+	"hello/util/redis"
+	"hello/util/redis/redistest"
+	testUtil "hello/util/test"
+)
+
+func testCache(t *testing.T, c cache.Cache) {
+	t.Helper()
+	ctx := context.Background()
+
+	_, err := c.Get(ctx, "a")
+	testUtil.Equal(t, true, errors.Is(err, cache.ErrMiss))
+
+	testUtil.NoError(t, c.Set(ctx, "a", []byte("1"), 0))
+	testUtil.NoError(t, c.Set(ctx, "b", []byte("2"), 20*time.Millisecond))
+	v, err := c.Get(ctx, "a")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "1", string(v))
+	v, err = c.Get(ctx, "b")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "2", string(v))
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = c.Get(ctx, "b")
+	testUtil.Equal(t, true, errors.Is(err, cache.ErrMiss))
+
+	testUtil.NoError(t, c.Delete(ctx, "a", "missing"))
+	_, err = c.Get(ctx, "a")
+	testUtil.Equal(t, true, errors.Is(err, cache.ErrMiss))
+	testUtil.NoError(t, c.Delete(ctx))
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	testCache(t, cache.NewMemory())
+}
+
+func TestMemory_Full(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	m := cache.NewMemory()
+	for i := range cache.MaxEntries {
+		testUtil.NoError(t, m.Set(ctx, strconv.Itoa(i), []byte("v"), 0))
+	}
+	testUtil.NoError(t, m.Set(ctx, "new", []byte("v"), 0))
+	_, err := m.Get(ctx, "new")
+	testUtil.Equal(t, true, errors.Is(err, cache.ErrMiss))
+
+	// Values already kept are still replaced.
+	testUtil.NoError(t, m.Set(ctx, "0", []byte("w"), 0))
+	v, err := m.Get(ctx, "0")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "w", string(v))
+}
+
+func TestRedis(t *testing.T) {
+	t.Parallel()
+
+	testCache(t, cache.NewRedis(redis.New(redistest.Start(t).Addr, ""), "test:"))
+}
+
+func TestCounted(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	c := cache.Count(cache.NewMemory())
+	testUtil.NoError(t, c.Set(ctx, "a", []byte("1"), 0))
+	c.Get(ctx, "a")
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+	testUtil.Equal(t, cache.Stats{Hits: 2, Misses: 1}, c.Stats())
+
+	down := cache.Count(cache.NewRedis(redis.New("127.0.0.1:1", ""), ""))
+	_, err := down.Get(ctx, "a")
+	testUtil.Equal(t, true, err != nil)
+	testUtil.Equal(t, cache.Stats{Errors: 1}, down.Stats())
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"hello/anonymize"
+	"hello/config"
+)
+
+var (
+	flags = flag.NewFlagSet("anonymize", flag.ExitOnError)
+	key   = flags.String("key", os.Getenv("ANONYMIZE_KEY"), "key the fakes are derived with (default: $ANONYMIZE_KEY)")
+)
+
+func main() {
+	flags.Usage = usage
+	flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *key == "" {
+		log.Fatal("-key or ANONYMIZE_KEY is not set")
+	}
+
+	c := config.NewDB()
+	db, err := gorm.Open(postgres.Open(c.ConnString()), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Error)})
+	if err != nil {
+		log.Fatalf("DB connection start failure: %s", err)
+	}
+
+	// The database is named on the command line, so that a copy is not
+	// mistaken for the database the connection settings point at.
+	var name string
+	if err := db.Raw("SELECT current_database()").Scan(&name).Error; err != nil {
+		log.Fatalf("Failed to read the database name: %s", err)
+	}
+	if name != args[0] {
+		log.Fatalf("Connected to database %q, not %q", name, args[0])
+	}
+
+	res, err := anonymize.Run(context.Background(), db, anonymize.NewFaker(*key))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("anonymize %s: %s\n", name, res)
+}
+
+func usage() {
+	fmt.Println(usagePrefix)
+	flags.PrintDefaults()
+}
+
+var usagePrefix = `Usage: anonymize [OPTIONS] DATABASE
+Rewrites the personal data in DATABASE, a copy of production, with fakes
+derived from the real values: user and invitation emails, display names,
+transfer notes, annotations, reviews, search queries and the IPs and emails
+in the audit log. Password hashes, sessions, tokens, API key and service
+account secrets, stored idempotent responses, payment payloads and dead
+letters are cleared. DATABASE must be the one the DB_* settings connect to.
+`
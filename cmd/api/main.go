@@ -1,13 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"hello/api/router"
+	"hello/banner"
+	"hello/buildinfo"
 	"hello/config"
+	"hello/dbtimeout"
+	"hello/drift"
+	"hello/event"
+	"hello/lifecycle"
+	"hello/migrations"
+	"hello/search"
+	"hello/tracing"
 
 	validatorUil "hello/util/validator"
 
@@ -16,8 +30,6 @@ import (
 	gormlogger "gorm.io/gorm/logger"
 )
 
-const fmtDBString = "host=%s user=%s password=%s dbname=%s port=%d sslmode=disable"
-
 //  @title          MYAPP API
 //  @version        1.0
 //  @description    This is a sample RESTful API with a CRUD
@@ -33,6 +45,9 @@ const fmtDBString = "host=%s user=%s password=%s dbname=%s port=%d sslmode=disab
 func main() {
 	c := config.New()
 	v := validatorUil.New()
+	// Subsystems register their teardown as they start, and stop in the
+	// reverse order once the server is told to.
+	lc := lifecycle.New(c.Server.TimeoutHook)
 
 	var logLevel gormlogger.LogLevel
 	if c.DB.Debug {
@@ -41,14 +56,54 @@ func main() {
 		logLevel = gormlogger.Error
 	}
 
-	dbString := fmt.Sprintf(fmtDBString, c.DB.Host, c.DB.Username, c.DB.Password, c.DB.DBName, c.DB.Port)
-	db, err := gorm.Open(postgres.Open(dbString), &gorm.Config{Logger: gormlogger.Default.LogMode(logLevel)})
+	db, err := gorm.Open(postgres.Open(c.DB.ConnString()), &gorm.Config{Logger: gormlogger.Default.LogMode(logLevel)})
 	if err != nil {
 		log.Fatal("DB connection start failure")
 		return
 	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("DB connection pool failure: %s", err)
+		return
+	}
+	sqlDB.SetMaxOpenConns(c.DB.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(c.DB.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(c.DB.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(c.DB.ConnMaxIdleTime)
+	lc.Register("db", 0, func(context.Context) error { return sqlDB.Close() })
+	if err := dbtimeout.Register(db, c.DB.StatementTimeout); err != nil {
+		log.Fatalf("DB statement timeout failure: %s", err)
+		return
+	}
+
+	if c.Tracing.Enabled {
+		tp, err := tracing.Setup(context.Background(), &c.Tracing, buildinfo.Get().Version)
+		if err != nil {
+			log.Fatalf("Tracing start failure: %s", err)
+			return
+		}
+		lc.Register("tracing", 0, tp.Shutdown)
+
+		if err := tracing.Instrument(db, tp); err != nil {
+			log.Fatalf("DB tracing start failure: %s", err)
+			return
+		}
+	}
+
+	if c.Schema.Check {
+		checkSchema(db, &c.Schema)
+	}
+
+	idx, err := search.New(&c.Search, db)
+	if err != nil {
+		log.Fatalf("Search index start failure: %s", err)
+		return
+	}
+	if idx != nil {
+		lc.Register("search", 0, func(context.Context) error { return idx.Close() })
+	}
 
-	r := router.New(db, v)
+	r := router.New(c, db, v, event.NewBus(), idx, lc)
 	s := &http.Server{
 		Addr:         fmt.Sprintf(":%d", c.Server.Port),
 		Handler:      r,
@@ -57,9 +112,71 @@ func main() {
 		IdleTimeout:  c.Server.TimeoutIdle,
 	}
 
-	log.Println("Starting server " + s.Addr)
-	if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatal("Server startup failed")
+	info := buildinfo.Get()
+	boot := &banner.Summary{
+		Version:    info.Version,
+		Commit:     info.Commit,
+		Subsystems: router.Features(c, idx),
+		Listen:     []string{s.Addr},
+	}
+	boot.Flatten(c)
+	if boot.Migration, err = drift.AppliedVersion(db); err != nil {
+		log.Printf("Migration state unknown: %s", err)
+	}
+	boot.Report(c.Boot.StatePath)
+
+	// Registered last, the server stops first: it stops accepting
+	// connections and waits for in-flight requests before anything they
+	// use is torn down.
+	lc.Register("http", c.Server.TimeoutShutdown, s.Shutdown)
+
+	log.Printf("Starting server %s, version %s (commit %s)", s.Addr, info.Version, info.Commit)
+	serve(s, c.Server.TimeoutShutdown)
+
+	if err := lc.Shutdown(context.Background()); err != nil {
+		log.Printf("Server shutdown: %s", err)
+	}
+	log.Print("Server stopped")
+}
+
+// serve runs s until SIGINT or SIGTERM. A second signal while shutting down
+// exits at once.
+func serve(s *http.Server, timeout time.Duration) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		log.Fatalf("Server startup failed: %s", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", timeout)
+}
+
+// checkSchema logs where the database differs from the migrations and
+// models, and exits when the check is strict.
+func checkSchema(db *gorm.DB, c *config.ConfSchema) {
+	report, err := drift.Check(db, migrations.Open(c.MigrationsDir), router.Models()...)
+	if err != nil {
+		log.Printf("Schema check failed: %s", err)
+		if c.Strict {
+			os.Exit(1)
+		}
+		return
+	}
+
+	for _, p := range report.Problems {
+		log.Printf("Schema drift: %s", p)
+	}
+	if !report.OK() && c.Strict {
+		log.Fatal("Refusing to start with a drifted schema; run migrations or unset SCHEMA_CHECK_STRICT")
 	}
 }
 
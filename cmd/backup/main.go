@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"hello/backup"
+	"hello/config"
+	"hello/storage"
+)
+
+var flags = flag.NewFlagSet("backup", flag.ExitOnError)
+
+func main() {
+	flags.Usage = usage
+	flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) != 1 || (args[0] != "sync" && args[0] != "restore") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c := config.New()
+	if c.Backup.Backend == "" {
+		log.Fatal("BACKUP_BACKEND is not set")
+	}
+
+	primary, err := storage.New(&c.Storage)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %s", err)
+	}
+	secondary, err := storage.New(c.Backup.Storage())
+	if err != nil {
+		log.Fatalf("Failed to open backup storage: %s", err)
+	}
+
+	// Only a sync reads the blobs from the database, so content can be
+	// restored before the database is back.
+	var db *gorm.DB
+	if args[0] == "sync" {
+		db, err = gorm.Open(postgres.Open(c.DB.ConnString()), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Error)})
+		if err != nil {
+			log.Fatalf("DB connection start failure: %s", err)
+		}
+	}
+	b := backup.New(db, primary, secondary)
+
+	var res backup.Result
+	switch args[0] {
+	case "sync":
+		res, err = b.Sync(context.Background())
+	case "restore":
+		res, err = b.Restore(context.Background())
+	}
+	if err != nil {
+		log.Fatalf("%s: %s", args[0], err)
+	}
+
+	fmt.Printf("%s: %s\n", args[0], res)
+	if res.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Println(usagePrefix)
+	flags.PrintDefaults()
+}
+
+var usagePrefix = `Usage: backup COMMAND
+Copies uploaded covers and attachments between the storage backend and the
+backup store configured by BACKUP_BACKEND and BACKUP_FS_PATH.
+Commands:
+    sync       copy the content the backup does not hold yet, as the API
+               does every BACKUP_INTERVAL
+    restore    copy the content missing from the storage backend back from
+               the backup
+`
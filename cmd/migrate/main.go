@@ -7,19 +7,17 @@ import (
 	"os"
 
 	"hello/config"
+	"hello/migrations"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 )
 
-const (
-	dialect     = "pgx"
-	fmtDBString = "host=%s user=%s password=%s dbname=%s port=%d sslmode=disable"
-)
+const dialect = "pgx"
 
 var (
 	flags = flag.NewFlagSet("migrate", flag.ExitOnError)
-	dir   = flags.String("dir", "migrations", "directory with migration files")
+	dir   = flags.String("dir", "", "directory with migration files (default: the migrations built into the binary)")
 )
 
 func main() {
@@ -34,10 +32,21 @@ func main() {
 
 	command := args[0]
 
-	c := config.NewDB()
-	dbString := fmt.Sprintf(fmtDBString, c.Host, c.Username, c.Password, c.DBName, c.Port)
+	// create and fix write migration files, so they always work on a
+	// directory; the other commands read the embedded files unless told
+	// otherwise.
+	switch {
+	case command == "create" || command == "fix":
+		if *dir == "" {
+			*dir = "migrations"
+		}
+	case *dir == "":
+		goose.SetBaseFS(migrations.FS)
+		*dir = "."
+	}
 
-	db, err := goose.OpenDBWithDriver(dialect, dbString)
+	c := config.NewDB()
+	db, err := goose.OpenDBWithDriver(dialect, c.ConnString())
 	if err != nil {
 		log.Fatalf(err.Error())
 	}
@@ -63,6 +72,8 @@ var (
 	usagePrefix = `Usage: migrate COMMAND
 Examples:
     migrate status
+    migrate up
+    migrate -dir ./migrations down
 `
 
 	usageCommands = `
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"hello/openapi"
+)
+
+var (
+	flags    = flag.NewFlagSet("mock", flag.ExitOnError)
+	spec     = flags.String("spec", "docs/swagger.json", "Swagger document generated by swag")
+	fixtures = flags.String("fixtures", "", "JSON file of responses overriding the generated examples")
+	addr     = flags.String("addr", ":8080", "address to listen on")
+)
+
+func main() {
+	flags.Usage = usage
+	flags.Parse(os.Args[1:])
+
+	s, err := openapi.Load(*spec)
+	if err != nil {
+		log.Fatalf("Failed to load spec: %s", err)
+	}
+	if s == nil {
+		log.Fatal("No spec given")
+	}
+
+	f, err := openapi.LoadFixtures(*fixtures)
+	if err != nil {
+		log.Fatalf("Failed to load fixtures: %s", err)
+	}
+
+	log.Println("Starting mock server " + *addr)
+	if err := http.ListenAndServe(*addr, s.Mock(f)); err != nil {
+		log.Fatal("Server startup failed")
+	}
+}
+
+func usage() {
+	fmt.Println(usagePrefix)
+	flags.PrintDefaults()
+	fmt.Println(usageFixtures)
+}
+
+var (
+	usagePrefix = `Usage: mock [FLAGS]
+Serves example responses for every operation in the API spec.
+Examples:
+    mock -spec docs/swagger.json -fixtures fixtures.json
+`
+
+	usageFixtures = `
+Fixtures map "METHOD /spec/path" to a canned response:
+    {"GET /books/{id}": {"status": 200, "body": {"id": "1", "title": "Dune"}}}`
+)
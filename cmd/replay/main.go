@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"hello/replay"
+)
+
+var (
+	flags   = flag.NewFlagSet("replay", flag.ExitOnError)
+	target  = flags.String("target", "", "base URL of the deployment to replay against, e.g. http://staging:8080")
+	methods = flags.String("methods", "GET,HEAD", "comma-separated methods to replay; add writes only against disposable data")
+	timeout = flags.Duration("timeout", 10*time.Second, "timeout per request")
+)
+
+func main() {
+	flags.Usage = usage
+	flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) != 1 || *target == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := replay.Read(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rp := &replay.Replayer{
+		Target:  *target,
+		Client:  &http.Client{Timeout: *timeout},
+		Methods: strings.Split(strings.ToUpper(*methods), ","),
+	}
+	diffs, replayed := rp.Replay(context.Background(), entries)
+
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	fmt.Printf("%d of %d journaled requests replayed, %d differ\n", replayed, len(entries), len(diffs))
+
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Println(usagePrefix)
+	flags.PrintDefaults()
+}
+
+var usagePrefix = `Usage: replay -target URL JOURNAL
+Replays a request journal against another deployment and reports requests
+whose response status or shape changed.
+Examples:
+    replay -target http://staging:8080 data/journal.jsonl
+`
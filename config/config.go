@@ -1,32 +1,452 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"log/slog"
 	"time"
 
 	"github.com/joeshaw/envdecode"
 )
 
 type Conf struct {
-	Server ConfServer
-	DB     ConfDB
+	Server  ConfServer
+	DB      ConfDB
+	Log     ConfLog
+	Schema  ConfSchema
+	Region  ConfRegion
+	Search  ConfSearch
+	Suggest ConfSuggest
+	Locale  ConfLocale
+
+	Moderation ConfModeration
+	Abuse      ConfAbuse
+	RateLimit  ConfRateLimit
+	Book       ConfBook
+	Loan       ConfLoan
+
+	Deprecation ConfDeprecation
+	FieldPolicy ConfFieldPolicy
+	Cache       ConfCache
+	OpenAPI     ConfOpenAPI
+	EventSchema ConfEventSchema
+	Journal     ConfJournal
+	Release     ConfRelease
+
+	Storage    ConfStorage
+	Backup     ConfBackup
+	Attachment ConfAttachment
+	Scan       ConfScan
+	Cover      ConfCover
+	SignedURL  ConfSignedURL
+
+	Auth           ConfAuth
+	Mail           ConfMail
+	ServiceAccount ConfServiceAccount
+	Session        ConfSession
+	Telemetry      ConfTelemetry
+	Boot           ConfBoot
+	Metadata       ConfMetadata
+	PublicID       ConfPublicID
+	Store          ConfStore
+	Payment        ConfPayment
+	Tracing        ConfTracing
+	Pricing        ConfPricing
+	Interaction    ConfInteraction
+	Experiment     ConfExperiment
+	Audit          ConfAudit
+	Idempotency    ConfIdempotency
+	Queue          ConfQueue
+	Jobs           ConfJobs
+	Access         ConfAccess
 }
 
+// ConfServer configures the HTTP server. On SIGINT or SIGTERM it stops
+// accepting connections and waits up to TimeoutShutdown for in-flight
+// requests to finish. Background workers and connections then stop in
+// turn, each given up to TimeoutHook.
 type ConfServer struct {
-	Port         int           `env:SERVER_PORT, required`
-	TimeoutRead  time.Duration `env:SERVER_TIMEOUT_READ,required`
-	TimeoutWrite time.Duration `env:SERVER_TIMEOUT_WRITE, required`
-	TimeoutIdle  time.Duration `env:SERVER_TIMEOUT_IDLE, required`
-	Debug        bool          `env:SERVER_DEBUG,required`
+	Port            int           `env:"SERVER_PORT,required"`
+	TimeoutRead     time.Duration `env:"SERVER_TIMEOUT_READ,required"`
+	TimeoutWrite    time.Duration `env:"SERVER_TIMEOUT_WRITE,required"`
+	TimeoutIdle     time.Duration `env:"SERVER_TIMEOUT_IDLE,required"`
+	TimeoutShutdown time.Duration `env:"SERVER_TIMEOUT_SHUTDOWN,default=30s"`
+	TimeoutHook     time.Duration `env:"SERVER_TIMEOUT_HOOK,default=10s"`
+	Debug           bool          `env:"SERVER_DEBUG,required"`
 }
 
+// ConfDB configures the database connection, either as a whole DSN or from
+// its parts, and the size of the connection pool. A zero MaxOpenConns
+// leaves the number of connections unbounded, and a zero StatementTimeout
+// the time a statement may take.
 type ConfDB struct {
-	Host     string `env:"DB_HOST,required"`
-	Port     int    `env:"DB_PORT,required"`
-	Username string `env:"DB_USER,required"`
-	Password string `env:"DB_PASS,required"`
-	DBName   string `env:"DB_NAME,required"`
-	Debug    bool   `env:"DB_DEBUG,required"`
+	DSN      string `env:"DB_DSN"`
+	Host     string `env:"DB_HOST,default=localhost"`
+	Port     int    `env:"DB_PORT,default=5432"`
+	Username string `env:"DB_USER"`
+	Password string `env:"DB_PASS"`
+	DBName   string `env:"DB_NAME"`
+	SSLMode  string `env:"DB_SSLMODE,default=disable"`
+	Debug    bool   `env:"DB_DEBUG,default=false"`
+
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS,default=25"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS,default=25"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME,default=30m"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME,default=5m"`
+
+	StatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT,default=5s"`
+}
+
+// ConnString returns DSN when set, and a connection string built from the
+// parts otherwise.
+func (c *ConfDB) ConnString() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		c.Host, c.Username, c.Password, c.DBName, c.Port, c.SSLMode)
+}
+
+// ConfLog sets the lowest level of the structured request log: debug, info,
+// warn or error.
+type ConfLog struct {
+	Level string `env:"LOG_LEVEL,default=info"`
+}
+
+// SlogLevel returns Level as a slog level. Level is validated on load.
+func (c *ConfLog) SlogLevel() slog.Level {
+	var l slog.Level
+	l.UnmarshalText([]byte(c.Level))
+	return l
+}
+
+// ConfRegion identifies the region this instance serves in an active-passive
+// deployment. A passive region reports not-ready once its replica falls more
+// than MaxReplicationLag behind the primary.
+type ConfRegion struct {
+	Name              string        `env:"REGION_NAME,default=local"`
+	Role              string        `env:"REGION_ROLE,default=active"`
+	MaxReplicationLag time.Duration `env:"REGION_MAX_REPLICATION_LAG,default=30s"`
+}
+
+type ConfSearch struct {
+	Backend   string `env:"SEARCH_BACKEND,default=none"`
+	BlevePath string `env:"SEARCH_BLEVE_PATH,default=data/search.bleve"`
+}
+
+type ConfSuggest struct {
+	RefreshInterval time.Duration `env:"SUGGEST_REFRESH_INTERVAL,default=1m"`
+}
+
+// ConfLocale lists the locales whose ICU collations are available in the
+// database, separated by semicolons.
+type ConfLocale struct {
+	Collations []string `env:"LOCALE_COLLATIONS,default=en;de;fr;es;sv;ja"`
+}
+
+// ConfModeration configures the content filter applied to user-generated
+// content. DenyList seeds the filter with semicolon separated terms; more can
+// be added at runtime through the admin API.
+type ConfModeration struct {
+	Action   string   `env:"MODERATION_ACTION,default=flag"`
+	DenyList []string `env:"MODERATION_DENY_LIST"`
+}
+
+// ConfAbuse configures spam and abuse scoring of submissions. Submissions
+// scoring at or above Threshold (0-1) are flagged for moderation.
+type ConfAbuse struct {
+	Threshold     float64       `env:"ABUSE_THRESHOLD,default=0.8"`
+	RateLimit     int           `env:"ABUSE_RATE_LIMIT,default=5"`
+	RateWindow    time.Duration `env:"ABUSE_RATE_WINDOW,default=10m"`
+	ScorerURL     string        `env:"ABUSE_SCORER_URL"`
+	ScorerTimeout time.Duration `env:"ABUSE_SCORER_TIMEOUT,default=2s"`
+}
+
+// ConfRateLimit sets the per-client request quota. Clients are warned once
+// they have used WarnRatio of it. A zero Requests disables rate limiting.
+// Classes define additional quotas, as name=requests/window, that routes opt
+// into by name.
+//
+// Algorithm is fixed_window or token_bucket. Token buckets refill steadily
+// rather than all at once, and with Store set to redis are shared by all
+// instances.
+type ConfRateLimit struct {
+	Requests  int           `env:"RATE_LIMIT_REQUESTS,default=600"`
+	Window    time.Duration `env:"RATE_LIMIT_WINDOW,default=1m"`
+	WarnRatio float64       `env:"RATE_LIMIT_WARN_RATIO,default=0.8"`
+	Classes   []string      `env:"RATE_LIMIT_CLASSES,default=upload=30/1m;admin=60/1m;login=10/1m;read=300/1m;write=60/1m;export=5/1m;auth=20/1m"`
+
+	Algorithm     string `env:"RATE_LIMIT_ALGORITHM,default=fixed_window"`
+	Store         string `env:"RATE_LIMIT_STORE,default=memory"`
+	RedisAddr     string `env:"RATE_LIMIT_REDIS_ADDR,default=localhost:6379"`
+	RedisPassword string `env:"RATE_LIMIT_REDIS_PASSWORD"`
+}
+
+// ConfBook tunes the book resource. With CheckImageURL set, writes probe the
+// image URL and warn, without failing, when it cannot be fetched.
+type ConfBook struct {
+	CheckImageURL   bool          `env:"BOOK_CHECK_IMAGE_URL,default=false"`
+	ImageURLTimeout time.Duration `env:"BOOK_IMAGE_URL_TIMEOUT,default=2s"`
+}
+
+// ConfLoan sets how many days a book is lent for when the borrower does
+// not ask for a period. A returned book is held for the first reader in
+// line for HoldClaimPeriod; holds not claimed by then are expired every
+// HoldSweepInterval, and the book held for the next reader.
+type ConfLoan struct {
+	Days              int           `env:"LOAN_DAYS,default=14"`
+	HoldClaimPeriod   time.Duration `env:"LOAN_HOLD_CLAIM_PERIOD,default=72h"`
+	HoldSweepInterval time.Duration `env:"LOAN_HOLD_SWEEP_INTERVAL,default=10m"`
+}
+
+type ConfDeprecation struct {
+	FlushInterval time.Duration `env:"DEPRECATION_FLUSH_INTERVAL,default=1m"`
+}
+
+// ConfFieldPolicy points at the JSON file restricting response fields by
+// scope. With no path every field is visible.
+type ConfFieldPolicy struct {
+	Path string `env:"FIELD_POLICY_PATH"`
+}
+
+// ConfCache points at the JSON file mapping routes and resources to cache
+// policies: the Cache-Control header, a TTL for keeping responses in
+// memory, the request headers they vary by and the events invalidating
+// them. With no path routes keep the policies declared in code. Callers
+// holding one of BypassScopes may skip the server cache of responses.
+//
+// Apart from responses, book reads and lists are cached for BookTTL and
+// BookListTTL in Store, memory or redis; zero TTLs leave them uncached.
+type ConfCache struct {
+	PolicyPath   string   `env:"CACHE_POLICY_PATH"`
+	BypassScopes []string `env:"CACHE_BYPASS_SCOPES,default=admin"`
+
+	Store         string        `env:"CACHE_STORE,default=memory"`
+	RedisAddr     string        `env:"CACHE_REDIS_ADDR,default=localhost:6379"`
+	RedisPassword string        `env:"CACHE_REDIS_PASSWORD"`
+	BookTTL       time.Duration `env:"CACHE_BOOK_TTL,default=0"`
+	BookListTTL   time.Duration `env:"CACHE_BOOK_LIST_TTL,default=0"`
+}
+
+// ConfOpenAPI enables request validation against the Swagger document
+// generated by swag, e.g. docs/swagger.json. With no path requests are not
+// validated. Unless Enforce is set violations are only logged.
+type ConfOpenAPI struct {
+	SpecPath string `env:"OPENAPI_SPEC_PATH"`
+	Enforce  bool   `env:"OPENAPI_ENFORCE,default=true"`
+}
+
+// ConfEventSchema controls the check of published event payloads against
+// their schemas. Unless Enforce is set violations are only logged; with it
+// events violating their schema are not published, so that their
+// subscribers miss them.
+type ConfEventSchema struct {
+	Enforce bool `env:"EVENT_SCHEMA_ENFORCE,default=false"`
+}
+
+// ConfJournal configures the request journal used for replay testing. With
+// a Path it can be switched on and off at runtime through the admin API, and
+// journals SampleRate of requests while on. Request fields and query
+// parameters whose names contain one of RedactFields are redacted, and
+// bodies over MaxBody bytes are left out.
+type ConfJournal struct {
+	Path         string   `env:"JOURNAL_PATH"`
+	Enabled      bool     `env:"JOURNAL_ENABLED,default=false"`
+	SampleRate   float64  `env:"JOURNAL_SAMPLE_RATE,default=0.1"`
+	MaxBody      int64    `env:"JOURNAL_MAX_BODY,default=65536"`
+	RedactFields []string `env:"JOURNAL_REDACT_FIELDS,default=password;token;secret;signature;key;email;phone"`
+}
+
+// ConfRelease points at a feed announcing the latest release, checked every
+// CheckInterval to log when the running version is outdated. Without a
+// FeedURL no check is made.
+type ConfRelease struct {
+	FeedURL       string        `env:"RELEASE_FEED_URL"`
+	CheckInterval time.Duration `env:"RELEASE_CHECK_INTERVAL,default=24h"`
+	Timeout       time.Duration `env:"RELEASE_CHECK_TIMEOUT,default=10s"`
+}
+
+// ConfStorage selects where uploaded files are kept: fs, below FSPath, or
+// s3, in an S3 bucket or one of a compatible store at S3Endpoint. Identical
+// content is stored once; blobs nothing references any more are removed
+// every BlobGCInterval.
+type ConfStorage struct {
+	Backend        string        `env:"STORAGE_BACKEND,default=fs"`
+	FSPath         string        `env:"STORAGE_FS_PATH,default=data/storage"`
+	BlobGCInterval time.Duration `env:"STORAGE_BLOB_GC_INTERVAL,default=1h"`
+
+	S3Endpoint        string `env:"STORAGE_S3_ENDPOINT"`
+	S3Region          string `env:"STORAGE_S3_REGION,default=us-east-1"`
+	S3Bucket          string `env:"STORAGE_S3_BUCKET"`
+	S3AccessKeyID     string `env:"STORAGE_S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `env:"STORAGE_S3_SECRET_ACCESS_KEY"`
+	S3PathStyle       bool   `env:"STORAGE_S3_PATH_STYLE,default=false"`
+}
+
+// ConfBackup copies uploaded content to a second store every Interval, so
+// that covers and attachments survive the loss of the primary one. Only
+// content the backup does not hold yet is copied. Without a Backend nothing
+// is backed up.
+type ConfBackup struct {
+	Backend  string        `env:"BACKUP_BACKEND"`
+	FSPath   string        `env:"BACKUP_FS_PATH,default=data/backup"`
+	Interval time.Duration `env:"BACKUP_INTERVAL,default=1h"`
+}
+
+// Storage returns the configuration of the backup store.
+func (c *ConfBackup) Storage() *ConfStorage {
+	return &ConfStorage{Backend: c.Backend, FSPath: c.FSPath}
+}
+
+// ConfAttachment limits book attachment uploads. AllowedTypes lists the
+// accepted media types, separated by semicolons; the type is detected from
+// the content, not taken from the client. Resumable uploads may be larger
+// than single-request ones and are dropped if unfinished after UploadExpiry.
+type ConfAttachment struct {
+	MaxSize          int64         `env:"ATTACHMENT_MAX_SIZE,default=20971520"`
+	ResumableMaxSize int64         `env:"ATTACHMENT_RESUMABLE_MAX_SIZE,default=1073741824"`
+	UploadExpiry     time.Duration `env:"ATTACHMENT_UPLOAD_EXPIRY,default=24h"`
+	UploadSweep      time.Duration `env:"ATTACHMENT_UPLOAD_SWEEP_INTERVAL,default=10m"`
+	AllowedTypes     []string      `env:"ATTACHMENT_ALLOWED_TYPES,default=application/pdf;application/epub+zip;image/jpeg;image/png;text/plain"`
+}
+
+// ConfScan configures virus scanning of uploads. Without a ClamAV address
+// uploads are marked clean without scanning. Uploads whose scan failed are
+// retried every SweepInterval. Workers scan uploads at once; the admin API
+// can resize them at runtime.
+type ConfScan struct {
+	ClamAVAddr    string        `env:"SCAN_CLAMAV_ADDR"`
+	Timeout       time.Duration `env:"SCAN_TIMEOUT,default=30s"`
+	SweepInterval time.Duration `env:"SCAN_SWEEP_INTERVAL,default=1m"`
+	Workers       int           `env:"SCAN_WORKERS,default=1"`
+}
+
+// ConfCover limits uploaded book covers by byte size and pixel dimensions.
+type ConfCover struct {
+	MaxSize   int64 `env:"COVER_MAX_SIZE,default=10485760"`
+	MaxWidth  int   `env:"COVER_MAX_WIDTH,default=4000"`
+	MaxHeight int   `env:"COVER_MAX_HEIGHT,default=4000"`
+}
+
+// ConfSignedURL configures signed download URLs for covers and attachments.
+// With Required set, downloads are only served to requests signed with Key.
+// Without a Key a random one is generated at startup, so URLs do not survive
+// restarts and are not shared between instances.
+type ConfSignedURL struct {
+	Required bool          `env:"SIGNED_URL_REQUIRED,default=false"`
+	Key      string        `env:"SIGNED_URL_KEY"`
+	TTL      time.Duration `env:"SIGNED_URL_TTL,default=5m"`
+}
+
+// ConfSchema controls the startup check of the database schema against the
+// migrations and the models. Drift is logged, and with Strict set the
+// service refuses to start. The migrations embedded in the binary are used
+// unless MigrationsDir names a directory to read them from.
+type ConfSchema struct {
+	Check         bool   `env:"SCHEMA_CHECK,default=true"`
+	Strict        bool   `env:"SCHEMA_CHECK_STRICT,default=false"`
+	MigrationsDir string `env:"SCHEMA_MIGRATIONS_DIR"`
+}
+
+// ConfAuth configures user registration. Verification links are VerifyURL
+// with the token appended as a query parameter, and expire after
+// VerifyTokenTTL. Password reset links are built the same way from ResetURL,
+// a page of the client that posts the token to /auth/reset, and expire after
+// ResetTokenTTL. Invitation links are built from InviteURL, a page of the
+// client that posts the token to /auth/accept-invite, and expire after
+// InviteTTL. Verification and reset mails are each limited to
+// ResendLimit per address per ResendWindow. With RequireVerifiedEmail set,
+// write requests are only accepted from users who verified their email.
+type ConfAuth struct {
+	RequireVerifiedEmail bool          `env:"AUTH_REQUIRE_VERIFIED_EMAIL,default=false"`
+	VerifyURL            string        `env:"AUTH_VERIFY_URL,default=http://localhost:8080/v1/auth/verify"`
+	VerifyTokenTTL       time.Duration `env:"AUTH_VERIFY_TOKEN_TTL,default=24h"`
+	ResendLimit          int           `env:"AUTH_VERIFY_RESEND_LIMIT,default=3"`
+	ResendWindow         time.Duration `env:"AUTH_VERIFY_RESEND_WINDOW,default=1h"`
+	ResetURL             string        `env:"AUTH_RESET_URL,default=http://localhost:3000/reset-password"`
+	ResetTokenTTL        time.Duration `env:"AUTH_RESET_TOKEN_TTL,default=1h"`
+	InviteURL            string        `env:"AUTH_INVITE_URL,default=http://localhost:3000/accept-invite"`
+	InviteTTL            time.Duration `env:"AUTH_INVITE_TTL,default=168h"`
+	// RBACEnabled enforces the roles routes declare.
+	RBACEnabled bool `env:"AUTH_RBAC_ENABLED,default=false"`
+}
+
+// ConfMail configures outgoing email. Without an SMTP address messages are
+// only logged.
+type ConfMail struct {
+	SMTPAddr string `env:"MAIL_SMTP_ADDR"`
+	Username string `env:"MAIL_SMTP_USERNAME"`
+	Password string `env:"MAIL_SMTP_PASSWORD"`
+	From     string `env:"MAIL_FROM,default=no-reply@localhost"`
+}
+
+// ConfServiceAccount configures service account credentials. After a
+// rotation the previous secret stays valid for RotationGrace. With
+// SecretMaxAge set, secrets expire that long after they were issued, so
+// clients have to rotate them; zero keeps them valid until rotated.
+type ConfServiceAccount struct {
+	RotationGrace time.Duration `env:"SERVICE_ACCOUNT_ROTATION_GRACE,default=24h"`
+	SecretMaxAge  time.Duration `env:"SERVICE_ACCOUNT_SECRET_MAX_AGE,default=0s"`
+}
+
+// ConfSession configures cookie sessions for browser clients, an
+// alternative to the gateway identifying users. With Enabled set, users
+// sign in at /auth/login and get a cookie signed with Key; without a Key a
+// random one is generated at startup. Sessions expire after TTL without use
+// and after MaxLifetime regardless. Store is memory, redis or postgres.
+type ConfSession struct {
+	Enabled        bool          `env:"SESSION_ENABLED,default=false"`
+	Store          string        `env:"SESSION_STORE,default=memory"`
+	RedisAddr      string        `env:"SESSION_REDIS_ADDR,default=localhost:6379"`
+	RedisPassword  string        `env:"SESSION_REDIS_PASSWORD"`
+	Key            string        `env:"SESSION_KEY"`
+	TTL            time.Duration `env:"SESSION_TTL,default=24h"`
+	MaxLifetime    time.Duration `env:"SESSION_MAX_LIFETIME,default=720h"`
+	SweepInterval  time.Duration `env:"SESSION_SWEEP_INTERVAL,default=10m"`
+	CookieName     string        `env:"SESSION_COOKIE_NAME,default=session"`
+	CookieDomain   string        `env:"SESSION_COOKIE_DOMAIN"`
+	CookieSecure   bool          `env:"SESSION_COOKIE_SECURE,default=true"`
+	CookieSameSite string        `env:"SESSION_COOKIE_SAMESITE,default=lax"`
+}
+
+// ConfTelemetry configures anonymous usage reports. Reports are sent to
+// Endpoint every Interval while Enabled; without an Endpoint nothing is
+// sent. DO_NOT_TRACK=1 opts out as well.
+type ConfTelemetry struct {
+	Enabled    bool          `env:"TELEMETRY_ENABLED,default=true"`
+	DoNotTrack bool          `env:"DO_NOT_TRACK,default=false"`
+	Endpoint   string        `env:"TELEMETRY_ENDPOINT"`
+	Interval   time.Duration `env:"TELEMETRY_INTERVAL,default=24h"`
+	Timeout    time.Duration `env:"TELEMETRY_TIMEOUT,default=10s"`
+}
+
+// ConfBoot configures the startup banner. The effective configuration of each
+// boot is kept in StatePath so the next one can log what changed; an empty
+// path disables the comparison.
+type ConfBoot struct {
+	StatePath string `env:"BOOT_STATE_PATH,default=.boot.json"`
+}
+
+// ConfMetadata configures lookups at the external book metadata provider,
+// an Open Library compatible API; without a ProviderURL they are disabled.
+// Answers are cached for CacheTTL, "not found" for NotFoundTTL, and at most
+// QuotaRequests calls are made to the provider per QuotaWindow.
+type ConfMetadata struct {
+	ProviderURL   string        `env:"METADATA_PROVIDER_URL,default=https://openlibrary.org"`
+	Timeout       time.Duration `env:"METADATA_TIMEOUT,default=5s"`
+	CacheTTL      time.Duration `env:"METADATA_CACHE_TTL,default=24h"`
+	NotFoundTTL   time.Duration `env:"METADATA_NOT_FOUND_TTL,default=1h"`
+	CacheSize     int           `env:"METADATA_CACHE_SIZE,default=10000"`
+	QuotaRequests int           `env:"METADATA_QUOTA_REQUESTS,default=60"`
+	QuotaWindow   time.Duration `env:"METADATA_QUOTA_WINDOW,default=1m"`
+}
+
+// ConfPublicID chooses how IDs appear in public DTOs and URLs: "uuid" as is,
+// "short" as 22 base62 characters, or "opaque", encrypted under Key first.
+// Changing the codec or key changes every public ID.
+type ConfPublicID struct {
+	Codec string `env:"ID_CODEC,default=uuid"`
+	Key   string `env:"ID_CODEC_KEY"`
 }
 
 func New() *Conf {
@@ -34,6 +454,9 @@ func New() *Conf {
 	if err := envdecode.StrictDecode(&c); err != nil {
 		log.Fatalf("Failed to decode: %s", err)
 	}
+	if err := c.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%s", err)
+	}
 	return &c
 }
 
@@ -42,5 +465,128 @@ func NewDB() *ConfDB {
 	if err := envdecode.StrictDecode(&c); err != nil {
 		log.Fatalf("Failed to decode: %s", err)
 	}
+	if err := c.validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%s", err)
+	}
 	return &c
 }
+
+// ConfStore enables bookstore mode: carts, and orders that reserve copies
+// from stock. Pending orders hold their copies for ReservationTTL, after
+// which a sweep every ReservationSweep cancels them; a zero TTL holds them
+// until the order is cancelled.
+type ConfStore struct {
+	Enabled          bool          `env:"STORE_ENABLED,default=false"`
+	ReservationTTL   time.Duration `env:"STORE_RESERVATION_TTL,default=30m"`
+	ReservationSweep time.Duration `env:"STORE_RESERVATION_SWEEP_INTERVAL,default=1m"`
+}
+
+// ConfPayment configures the payment provider webhook, served in bookstore
+// mode when a secret is set. Sandbox mode takes test events only, falls back
+// to a well-known secret and serves an endpoint that signs and delivers
+// simulated events.
+type ConfPayment struct {
+	WebhookSecret string        `env:"PAYMENT_WEBHOOK_SECRET"`
+	Tolerance     time.Duration `env:"PAYMENT_WEBHOOK_TOLERANCE,default=5m"`
+	Sandbox       bool          `env:"PAYMENT_SANDBOX,default=false"`
+}
+
+// ConfTracing configures OpenTelemetry tracing. With Enabled set, spans of
+// requests and their database calls are exported over OTLP/HTTP to Endpoint,
+// a host:port, under ServiceName. SampleRate is the fraction of new traces
+// kept; requests that arrive with a sampled trace context are always kept.
+type ConfTracing struct {
+	Enabled     bool    `env:"TRACING_ENABLED,default=false"`
+	Endpoint    string  `env:"TRACING_ENDPOINT,default=localhost:4318"`
+	Insecure    bool    `env:"TRACING_INSECURE,default=true"`
+	SampleRate  float64 `env:"TRACING_SAMPLE_RATE,default=1"`
+	ServiceName string  `env:"TRACING_SERVICE_NAME,default=hello"`
+}
+
+// ConfPricing points at the JSON rules table giving each region its tax rate
+// and whether displayed prices include it. Regions without a rule display
+// prices as DefaultRegion does. Without a table no display prices are added.
+type ConfPricing struct {
+	RulesPath     string `env:"PRICING_RULES_PATH"`
+	DefaultRegion string `env:"PRICING_DEFAULT_REGION,default=US"`
+}
+
+// ConfInteraction enables collecting interaction events, such as book views,
+// searches and checkouts, for training recommendations. Accepted events are
+// buffered and appended to the database every FlushInterval, BatchSize rows
+// per statement. Once BufferSize events wait, further ones are refused
+// until a flush makes room.
+type ConfInteraction struct {
+	Enabled       bool          `env:"INTERACTION_ENABLED,default=false"`
+	FlushInterval time.Duration `env:"INTERACTION_FLUSH_INTERVAL,default=5s"`
+	BatchSize     int           `env:"INTERACTION_BATCH_SIZE,default=500"`
+	BufferSize    int           `env:"INTERACTION_BUFFER_SIZE,default=10000"`
+}
+
+// ConfExperiment enables A/B experiments, defined in the JSON file at Path
+// and at runtime through the admin API. Stored definitions are reloaded
+// every RefreshInterval, so that all instances assign units alike.
+// Exposures are logged as interaction events when those are collected.
+type ConfExperiment struct {
+	Enabled         bool          `env:"EXPERIMENT_ENABLED,default=false"`
+	Path            string        `env:"EXPERIMENT_PATH"`
+	RefreshInterval time.Duration `env:"EXPERIMENT_REFRESH_INTERVAL,default=1m"`
+}
+
+// ConfAudit selects the sinks of the audit log, semicolon separated: db for
+// the audit_log table, file for JSON lines appended to FilePath, and syslog
+// for RFC 5424 messages to a syslog server or SIEM at SyslogAddr. Every
+// entry goes to each sink. Entries of actions that may be written after
+// the response are flushed every FlushInterval, with up to BufferSize
+// waiting per sink. Without sinks nothing is audited.
+type ConfAudit struct {
+	Sinks         []string      `env:"AUDIT_SINKS"`
+	FilePath      string        `env:"AUDIT_FILE_PATH,default=audit.jsonl"`
+	SyslogNetwork string        `env:"AUDIT_SYSLOG_NETWORK,default=tcp"`
+	SyslogAddr    string        `env:"AUDIT_SYSLOG_ADDR"`
+	SyslogTimeout time.Duration `env:"AUDIT_SYSLOG_TIMEOUT,default=2s"`
+	FlushInterval time.Duration `env:"AUDIT_FLUSH_INTERVAL,default=5s"`
+	BufferSize    int           `env:"AUDIT_BUFFER_SIZE,default=10000"`
+}
+
+// ConfIdempotency configures Idempotency-Key handling on the routes that
+// support it. Stored responses are replayed for TTL after the first request
+// and removed by a sweep every SweepInterval.
+type ConfIdempotency struct {
+	TTL           time.Duration `env:"IDEMPOTENCY_TTL,default=24h"`
+	SweepInterval time.Duration `env:"IDEMPOTENCY_SWEEP_INTERVAL,default=10m"`
+}
+
+// ConfQueue configures the queue of heavy requests: exports, imports and
+// searches. Up to Workers of them are served at once and Depth more wait,
+// their responses kept for ResultTTL once served. Zero Workers serves them
+// all at once.
+type ConfQueue struct {
+	Workers   int           `env:"QUEUE_WORKERS,default=4"`
+	Depth     int           `env:"QUEUE_DEPTH,default=100"`
+	ResultTTL time.Duration `env:"QUEUE_RESULT_TTL,default=15m"`
+}
+
+// ConfJobs configures the runner of background jobs. Workers run queued
+// jobs, up to QueueSize of which wait. A failed job runs up to MaxAttempts
+// times in all, waiting RetryBackoff after its first failure and twice as
+// long after each next one, up to RetryMaxBackoff.
+type ConfJobs struct {
+	Workers         int           `env:"JOB_WORKERS,default=2"`
+	QueueSize       int           `env:"JOB_QUEUE_SIZE,default=1000"`
+	MaxAttempts     int           `env:"JOB_MAX_ATTEMPTS,default=5"`
+	RetryBackoff    time.Duration `env:"JOB_RETRY_BACKOFF,default=1s"`
+	RetryMaxBackoff time.Duration `env:"JOB_RETRY_MAX_BACKOFF,default=5m"`
+}
+
+// ConfAccess points at the JSON file restricting routes and resources to
+// allowed or away from denied networks and countries. Countries are looked
+// up in the MaxMind DB at GeoIPPath, e.g. GeoLite2-Country.mmdb, which
+// rules naming countries need. Behind a load balancer its addresses go in
+// TrustedProxies, so that clients are told by X-Forwarded-For. With no path
+// every route is served to everyone.
+type ConfAccess struct {
+	RulesPath      string   `env:"ACCESS_RULES_PATH"`
+	GeoIPPath      string   `env:"ACCESS_GEOIP_PATH"`
+	TrustedProxies []string `env:"ACCESS_TRUSTED_PROXIES"`
+}
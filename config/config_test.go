@@ -0,0 +1,89 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	"hello/config"
+	testUtil "hello/util/test"
+)
+
+func load(t *testing.T) *config.Conf {
+	t.Setenv("SERVER_PORT", "8080")
+	t.Setenv("SERVER_TIMEOUT_READ", "3s")
+	t.Setenv("SERVER_TIMEOUT_WRITE", "5s")
+	t.Setenv("SERVER_TIMEOUT_IDLE", "5s")
+	t.Setenv("SERVER_DEBUG", "false")
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_NAME", "app")
+	t.Setenv("STORAGE_FS_PATH", t.TempDir())
+	return config.New()
+}
+
+func TestConf_Validate(t *testing.T) {
+	tests := map[string]struct {
+		change func(c *config.Conf)
+		errs   []string
+	}{
+		"defaults": {
+			change: func(c *config.Conf) {},
+		},
+		"dsn without parts": {
+			change: func(c *config.Conf) {
+				c.DB.DSN = "postgres://app@db/app"
+				c.DB.Host, c.DB.Username, c.DB.DBName, c.DB.Port = "", "", "", 0
+			},
+		},
+		"no database": {
+			change: func(c *config.Conf) { c.DB.Host = "" },
+			errs:   []string{"DB_DSN, or DB_HOST"},
+		},
+		"several": {
+			change: func(c *config.Conf) {
+				c.Server.Port = 70000
+				c.Log.Level = "verbose"
+				c.DB.MaxIdleConns = 50
+				c.Tracing.SampleRate = 1.5
+			},
+			errs: []string{"SERVER_PORT", "LOG_LEVEL", "DB_MAX_IDLE_CONNS", "TRACING_SAMPLE_RATE"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := load(t)
+			tc.change(c)
+
+			err := c.Validate()
+			if len(tc.errs) == 0 {
+				testUtil.NoError(t, err)
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			for _, want := range tc.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfDB_ConnString(t *testing.T) {
+	c := load(t)
+	testUtil.Equal(t, "host=localhost user=app password= dbname=app port=5432 sslmode=disable", c.DB.ConnString())
+
+	c.DB.DSN = "postgres://app@db/app?sslmode=require"
+	testUtil.Equal(t, c.DB.DSN, c.DB.ConnString())
+}
+
+func TestConfLog_SlogLevel(t *testing.T) {
+	c := load(t)
+	testUtil.Equal(t, "INFO", c.Log.SlogLevel().String())
+
+	c.Log.Level = "WARN"
+	testUtil.Equal(t, "WARN", c.Log.SlogLevel().String())
+}
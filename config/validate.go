@@ -0,0 +1,119 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	auditSinks = []string{"db", "file", "syslog"}
+)
+
+// Validate reports every setting that is out of range, naming the
+// environment variable of each. Settings whose values are defined by the
+// packages using them, such as storage backends, are checked there.
+func (c *Conf) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	ratio := func(v float64, name string) {
+		check(v >= 0 && v <= 1, "%s must be between 0 and 1, got %v", name, v)
+	}
+	positive := func(d time.Duration, name string) {
+		check(d > 0, "%s must be positive, got %s", name, d)
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 1<<16, "SERVER_PORT must be a TCP port, got %d", c.Server.Port)
+	check(c.Server.TimeoutShutdown >= 0, "SERVER_TIMEOUT_SHUTDOWN must not be negative")
+	if err := c.DB.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	check(slices.Contains(logLevels, strings.ToLower(c.Log.Level)),
+		"LOG_LEVEL must be one of %s, got %q", strings.Join(logLevels, ", "), c.Log.Level)
+	check(c.Region.Role == "active" || c.Region.Role == "passive", "REGION_ROLE must be active or passive, got %q", c.Region.Role)
+
+	ratio(c.Abuse.Threshold, "ABUSE_THRESHOLD")
+	ratio(c.RateLimit.WarnRatio, "RATE_LIMIT_WARN_RATIO")
+	ratio(c.Journal.SampleRate, "JOURNAL_SAMPLE_RATE")
+	ratio(c.Tracing.SampleRate, "TRACING_SAMPLE_RATE")
+	check(c.RateLimit.Requests >= 0, "RATE_LIMIT_REQUESTS must not be negative")
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "redis", "RATE_LIMIT_STORE must be memory or redis, got %q", c.RateLimit.Store)
+
+	// These drive tickers, which cannot run at a zero interval.
+	positive(c.Suggest.RefreshInterval, "SUGGEST_REFRESH_INTERVAL")
+	positive(c.Deprecation.FlushInterval, "DEPRECATION_FLUSH_INTERVAL")
+	positive(c.Storage.BlobGCInterval, "STORAGE_BLOB_GC_INTERVAL")
+	positive(c.Attachment.UploadSweep, "ATTACHMENT_UPLOAD_SWEEP_INTERVAL")
+	positive(c.Scan.SweepInterval, "SCAN_SWEEP_INTERVAL")
+	positive(c.Idempotency.SweepInterval, "IDEMPOTENCY_SWEEP_INTERVAL")
+	positive(c.Idempotency.TTL, "IDEMPOTENCY_TTL")
+	if c.Session.Enabled {
+		positive(c.Session.SweepInterval, "SESSION_SWEEP_INTERVAL")
+	}
+	if c.Telemetry.Enabled && c.Telemetry.Endpoint != "" {
+		positive(c.Telemetry.Interval, "TELEMETRY_INTERVAL")
+	}
+	if c.Release.FeedURL != "" {
+		positive(c.Release.CheckInterval, "RELEASE_CHECK_INTERVAL")
+	}
+	if c.Interaction.Enabled {
+		positive(c.Interaction.FlushInterval, "INTERACTION_FLUSH_INTERVAL")
+		check(c.Interaction.BatchSize > 0, "INTERACTION_BATCH_SIZE must be positive, got %d", c.Interaction.BatchSize)
+		check(c.Interaction.BufferSize > 0, "INTERACTION_BUFFER_SIZE must be positive, got %d", c.Interaction.BufferSize)
+	}
+	if c.Experiment.Enabled {
+		positive(c.Experiment.RefreshInterval, "EXPERIMENT_REFRESH_INTERVAL")
+	}
+	if c.Backup.Backend != "" {
+		positive(c.Backup.Interval, "BACKUP_INTERVAL")
+		check(c.Backup.Backend != c.Storage.Backend || c.Backup.FSPath != c.Storage.FSPath,
+			"BACKUP_FS_PATH must not be STORAGE_FS_PATH, got %q", c.Backup.FSPath)
+	}
+	if len(c.Audit.Sinks) > 0 {
+		positive(c.Audit.FlushInterval, "AUDIT_FLUSH_INTERVAL")
+		check(c.Audit.BufferSize > 0, "AUDIT_BUFFER_SIZE must be positive, got %d", c.Audit.BufferSize)
+		for _, sink := range c.Audit.Sinks {
+			check(slices.Contains(auditSinks, sink), "AUDIT_SINKS must list %s, got %q", strings.Join(auditSinks, ", "), sink)
+		}
+		if slices.Contains(c.Audit.Sinks, "file") {
+			check(c.Audit.FilePath != "", "AUDIT_FILE_PATH must be set for the file sink")
+		}
+		if slices.Contains(c.Audit.Sinks, "syslog") {
+			check(c.Audit.SyslogAddr != "", "AUDIT_SYSLOG_ADDR must be set for the syslog sink")
+			check(c.Audit.SyslogNetwork == "tcp" || c.Audit.SyslogNetwork == "udp", "AUDIT_SYSLOG_NETWORK must be tcp or udp, got %q", c.Audit.SyslogNetwork)
+			positive(c.Audit.SyslogTimeout, "AUDIT_SYSLOG_TIMEOUT")
+		}
+	}
+	if c.Store.Enabled {
+		positive(c.Store.ReservationSweep, "STORE_RESERVATION_SWEEP_INTERVAL")
+		check(c.Store.ReservationTTL >= 0, "STORE_RESERVATION_TTL must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *ConfDB) validate() error {
+	var errs []error
+	if c.DSN == "" {
+		if c.Host == "" || c.Username == "" || c.DBName == "" {
+			errs = append(errs, errors.New("DB_DSN, or DB_HOST, DB_USER and DB_NAME, must be set"))
+		}
+		if c.Port <= 0 || c.Port >= 1<<16 {
+			errs = append(errs, fmt.Errorf("DB_PORT must be a TCP port, got %d", c.Port))
+		}
+	}
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative"))
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.MaxIdleConns, c.MaxOpenConns))
+	}
+	return errors.Join(errs...)
+}
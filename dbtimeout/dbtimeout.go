@@ -0,0 +1,147 @@
+// Package dbtimeout bounds the time database statements may take, and
+// answers requests whose statements were cut short with the status saying
+// why: 499 when the client went away, 503 when a statement ran out of time.
+//
+// Statements only stop with their request when run with its context,
+// through gorm's WithContext. Handlers pass the request context to their
+// repository's WithContext for this, which also traces the statements as
+// part of the request.
+package dbtimeout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+)
+
+const (
+	parentKey = "dbtimeout:parent"
+	cancelKey = "dbtimeout:cancel"
+)
+
+// Register bounds every statement run through db by d, within the context
+// it runs in. A zero d leaves statements unbounded. Row and Rows queries
+// are not bounded, as their results are read after gorm returns them.
+func Register(db *gorm.DB, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	before := func(tx *gorm.DB) {
+		ctx, cancel := context.WithTimeout(tx.Statement.Context, d)
+		tx.InstanceSet(parentKey, tx.Statement.Context)
+		tx.InstanceSet(cancelKey, cancel)
+		tx.Statement.Context = ctx
+	}
+	after := func(tx *gorm.DB) {
+		if errors.Is(tx.Error, context.DeadlineExceeded) {
+			timedOut(tx.Statement.Context)
+		}
+		if cancel, ok := tx.InstanceGet(cancelKey); ok {
+			cancel.(context.CancelFunc)()
+		}
+		// A statement can be reused for a later query, which must not run
+		// in the context just cancelled.
+		if parent, ok := tx.InstanceGet(parentKey); ok {
+			tx.Statement.Context = parent.(context.Context)
+		}
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("*").Register("dbtimeout:before", before),
+		cb.Create().After("*").Register("dbtimeout:after", after),
+		cb.Query().Before("*").Register("dbtimeout:before", before),
+		cb.Query().After("*").Register("dbtimeout:after", after),
+		cb.Update().Before("*").Register("dbtimeout:before", before),
+		cb.Update().After("*").Register("dbtimeout:after", after),
+		cb.Delete().Before("*").Register("dbtimeout:before", before),
+		cb.Delete().After("*").Register("dbtimeout:after", after),
+		cb.Raw().Before("*").Register("dbtimeout:before", before),
+		cb.Raw().After("*").Register("dbtimeout:after", after),
+	)
+}
+
+type ctxKey struct{}
+
+// state records that a statement of the request timed out. Statements
+// time out in a context of their own, which the request does not see.
+type state struct {
+	timedOut atomic.Bool
+}
+
+func timedOut(ctx context.Context) {
+	if s, ok := ctx.Value(ctxKey{}).(*state); ok {
+		s.timedOut.Store(true)
+	}
+}
+
+// Middleware answers the server errors of requests cancelled by their
+// client with e.RespClientClosed, and those of requests with a statement
+// that timed out with e.RespTimeout, in place of the error the handler
+// wrote.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := &state{}
+		r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, s))
+		next.ServeHTTP(&writer{ResponseWriter: w, ctx: r.Context(), state: s}, r)
+	})
+}
+
+type writer struct {
+	http.ResponseWriter
+	ctx   context.Context
+	state *state
+
+	wroteHeader bool
+	replaced    bool
+}
+
+func (w *writer) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if status >= http.StatusInternalServerError {
+		var p *e.Problem
+		switch {
+		case errors.Is(w.ctx.Err(), context.Canceled):
+			p = e.RespClientClosed
+		case w.state.timedOut.Load(), errors.Is(w.ctx.Err(), context.DeadlineExceeded):
+			p = e.RespTimeout
+		}
+		if p != nil {
+			w.replaced = true
+			e.Write(w.ResponseWriter, p)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
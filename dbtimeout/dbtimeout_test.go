@@ -0,0 +1,81 @@
+package dbtimeout_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	e "hello/api/resource/common/err"
+	"hello/dbtimeout"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+)
+
+type row struct {
+	ID int
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &row{})
+	testUtil.NoError(t, db.Create(&row{ID: 1}).Error)
+	testUtil.NoError(t, dbtimeout.Register(db, 20*time.Millisecond))
+
+	// A statement stuck in the database is simulated by waiting for its
+	// context to be done.
+	type stuckKey struct{}
+	testUtil.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:stuck", func(tx *gorm.DB) {
+		if tx.Statement.Context.Value(stuckKey{}) != nil {
+			<-tx.Statement.Context.Done()
+			tx.AddError(tx.Statement.Context.Err())
+		}
+	}))
+
+	serve := func(ctx context.Context) *httptest.ResponseRecorder {
+		h := dbtimeout.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var rows []row
+			if err := db.WithContext(r.Context()).Find(&rows).Error; err != nil {
+				e.ServerError(w, e.RespDBDataAccessFailure)
+				return
+			}
+			json.NewEncoder(w).Encode(rows)
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rows", nil).WithContext(ctx))
+		return w
+	}
+	code := func(w *httptest.ResponseRecorder) string {
+		var p e.Problem
+		testUtil.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		return p.Code
+	}
+
+	w := serve(context.Background())
+	testUtil.Equal(t, http.StatusOK, w.Code)
+	testUtil.Equal(t, "[{\"ID\":1}]\n", w.Body.String())
+
+	w = serve(context.WithValue(context.Background(), stuckKey{}, true))
+	testUtil.Equal(t, http.StatusServiceUnavailable, w.Code)
+	testUtil.Equal(t, "timeout", code(w))
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), stuckKey{}, true))
+	time.AfterFunc(time.Millisecond, cancel)
+	w = serve(ctx)
+	testUtil.Equal(t, e.StatusClientClosedRequest, w.Code)
+	testUtil.Equal(t, "client_closed_request", code(w))
+
+	// A statement reused for a second query does not run in the context
+	// of the first, cancelled once it returned.
+	q := db.WithContext(context.Background()).Model(&row{}).Where("id > ?", 0)
+	var n int64
+	testUtil.NoError(t, q.Count(&n).Error)
+	var rows []row
+	testUtil.NoError(t, q.Find(&rows).Error)
+	testUtil.Equal(t, 1, len(rows))
+}
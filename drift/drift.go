@@ -0,0 +1,212 @@
+// Package drift compares the live database schema with what the code
+// expects, so that a missed migration is reported at startup rather than by
+// a failing query later on.
+package drift
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_.+\.(sql|go)$`)
+	createIndex   = regexp.MustCompile(`(?i)CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(\w+)\s+ON\s+(?:ONLY\s+)?(\w+)`)
+	dropIndex     = regexp.MustCompile(`(?i)DROP\s+INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?(\w+)`)
+)
+
+// Report lists the differences found between the database and the
+// migrations and models.
+type Report struct {
+	// AppliedVersion is the latest migration applied to the database, and
+	// ExpectedVersion the latest one in the migrations directory.
+	AppliedVersion  int64
+	ExpectedVersion int64
+	Problems        []string
+}
+
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) add(format string, args ...any) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// Check compares db with the goose migrations in fsys and with the tables
+// and columns of models. Indexes are expected as created, and not later
+// dropped, by the migrations' Up sections.
+func Check(db *gorm.DB, fsys fs.FS, models ...any) (*Report, error) {
+	r := &Report{}
+
+	expected, indexes, err := readMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	r.ExpectedVersion = expected
+
+	applied, err := AppliedVersion(db)
+	if err != nil {
+		r.add("migration state: %s", err)
+	}
+	r.AppliedVersion = applied
+	if applied < expected {
+		r.add("database is at migration %d, expected %d", applied, expected)
+	} else if applied > expected {
+		r.add("database is at migration %d, ahead of the expected %d", applied, expected)
+	}
+
+	m := db.Migrator()
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("drift: parse model %T: %w", model, err)
+		}
+
+		table := stmt.Schema.Table
+		if !m.HasTable(table) {
+			r.add("table %s is missing", table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !m.HasColumn(model, field.DBName) {
+				r.add("column %s.%s is missing", table, field.DBName)
+			}
+		}
+	}
+
+	for _, idx := range indexes {
+		if m.HasTable(idx.table) && !m.HasIndex(idx.table, idx.name) {
+			r.add("index %s on %s is missing", idx.name, idx.table)
+		}
+	}
+	return r, nil
+}
+
+// AppliedVersion finds the current version the way goose does: the latest
+// version whose most recent record is an up migration.
+func AppliedVersion(db *gorm.DB) (int64, error) {
+	if !db.Migrator().HasTable("goose_db_version") {
+		return 0, errors.New("no migrations applied")
+	}
+
+	rows, err := db.Raw("SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC").Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	seen := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		var applied bool
+		if err := rows.Scan(&version, &applied); err != nil {
+			return 0, err
+		}
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+		if applied {
+			return version, nil
+		}
+	}
+	return 0, rows.Err()
+}
+
+type index struct {
+	name  string
+	table string
+}
+
+// readMigrations returns the latest migration version in fsys and the
+// indexes its SQL migrations leave in place.
+func readMigrations(fsys fs.FS) (int64, []index, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return 0, nil, fmt.Errorf("drift: %w", err)
+	}
+
+	type migration struct {
+		version int64
+		path    string
+	}
+	var migrations []migration
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return 0, nil, fmt.Errorf("drift: %s: %w", e.Name(), err)
+		}
+		migrations = append(migrations, migration{version, e.Name()})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	var latest int64
+	indexes := make(map[string]index)
+	for _, mig := range migrations {
+		latest = mig.version
+		if path.Ext(mig.path) != ".sql" {
+			continue
+		}
+
+		up, err := upSection(fsys, mig.path)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, m := range createIndex.FindAllStringSubmatch(up, -1) {
+			indexes[m[1]] = index{name: m[1], table: m[2]}
+		}
+		for _, m := range dropIndex.FindAllStringSubmatch(up, -1) {
+			delete(indexes, m[1])
+		}
+	}
+
+	list := make([]index, 0, len(indexes))
+	for _, idx := range indexes {
+		list = append(list, idx)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return latest, list, nil
+}
+
+// upSection returns the statements between "-- +goose Up" and
+// "-- +goose Down".
+func upSection(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	up := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "-- +goose Up"):
+			up = true
+			continue
+		case strings.HasPrefix(line, "-- +goose Down"):
+			up = false
+			continue
+		}
+		if up {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), sc.Err()
+}
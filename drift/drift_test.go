@@ -0,0 +1,59 @@
+package drift_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hello/drift"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+)
+
+type shelf struct {
+	ID    int
+	Name  string
+	Floor int
+}
+
+type bin struct {
+	ID int
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	migrations := map[string]string{
+		"00001_create_shelves.sql": "-- +goose Up\nCREATE TABLE shelves (id int, name text);\nCREATE INDEX IF NOT EXISTS shelves_name_idx ON shelves (name);\n" +
+			"CREATE INDEX shelves_old_idx ON shelves (id);\n-- +goose Down\nCREATE INDEX shelves_down_idx ON shelves (id);\n",
+		"00002_add_shelves_floor.sql": "-- +goose Up\nALTER TABLE shelves ADD COLUMN floor int;\nCREATE INDEX shelves_floor_idx ON shelves (floor);\nDROP INDEX shelves_old_idx;\n",
+		"README.md":                   "not a migration",
+	}
+	for name, content := range migrations {
+		testUtil.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	db := mockDB.NewSQLite(t)
+
+	for _, stmt := range []string{
+		"CREATE TABLE goose_db_version (id integer primary key, version_id bigint, is_applied boolean)",
+		"INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, true), (1, true), (2, true), (2, false)",
+		"CREATE TABLE shelves (id int, name text)",
+		"CREATE INDEX shelves_name_idx ON shelves (name)",
+	} {
+		testUtil.NoError(t, db.Exec(stmt).Error)
+	}
+
+	report, err := drift.Check(db, os.DirFS(dir), &shelf{}, &bin{})
+	testUtil.NoError(t, err)
+
+	testUtil.Equal(t, int64(1), report.AppliedVersion)
+	testUtil.Equal(t, int64(2), report.ExpectedVersion)
+	testUtil.Equal(t, false, report.OK())
+	testUtil.Equal(t, 4, len(report.Problems))
+	testUtil.Equal(t, "database is at migration 1, expected 2", report.Problems[0])
+	testUtil.Equal(t, "column shelves.floor is missing", report.Problems[1])
+	testUtil.Equal(t, "table bins is missing", report.Problems[2])
+	testUtil.Equal(t, "index shelves_floor_idx on shelves is missing", report.Problems[3])
+}
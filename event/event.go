@@ -0,0 +1,70 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Event is a domain event raised after a write has been committed. Version
+// is that of the schema of its payload, set on publish when schemas are
+// checked.
+type Event struct {
+	Name        string
+	Version     int
+	AggregateID string
+	OccurredAt  time.Time
+	Payload     any
+}
+
+type Handler func(ctx context.Context, e Event) error
+
+type Bus interface {
+	Publish(ctx context.Context, e Event) error
+	Subscribe(name string, h Handler)
+}
+
+// MemoryBus dispatches events synchronously to in-process subscribers, so read
+// models are up to date by the time the publishing request returns.
+type MemoryBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBus() *MemoryBus {
+	return &MemoryBus{
+		handlers: make(map[string][]Handler),
+	}
+}
+
+func New(name, aggregateID string, payload any) Event {
+	return Event{
+		Name:        name,
+		AggregateID: aggregateID,
+		OccurredAt:  time.Now(),
+		Payload:     payload,
+	}
+}
+
+func (b *MemoryBus) Subscribe(name string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[name] = append(b.handlers[name], h)
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	handlers := b.handlers[e.Name]
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,178 @@
+// Package schema holds the JSON Schemas of the payloads of published
+// domain events, one document per event and version, and checks payloads
+// against them on publish. The documents are served to consumers at
+// /schemas.
+//
+// A payload changing incompatibly gets a new version, as a new document
+// next to the old, e.g. book.created.v2.json; events are published with
+// the latest version of their schema.
+package schema
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"hello/event"
+	"hello/openapi"
+)
+
+//go:embed events/*.json
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^([a-z_.]+)\.v([1-9][0-9]*)\.json$`)
+
+var (
+	ErrUnknownEvent   = errors.New("schema: no schema for event")
+	ErrInvalidPayload = errors.New("schema: invalid event payload")
+)
+
+// Schema is a version of the schema of an event's payload.
+type Schema struct {
+	Event    string
+	Version  int
+	Document json.RawMessage
+
+	schema *openapi.Schema
+}
+
+// Validate checks the JSON form of payload, returning one message per
+// violation.
+func (s *Schema) Validate(payload any) ([]string, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return (&openapi.Spec{}).ValidateAt(s.schema, v, "payload"), nil
+}
+
+// Registry holds the schemas by event, oldest version first.
+type Registry struct {
+	schemas map[string][]*Schema
+}
+
+// Load reads the schemas embedded in the binary.
+func Load() (*Registry, error) {
+	entries, err := fs.ReadDir(files, "events")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{schemas: make(map[string][]*Schema)}
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("schema: %s: name does not match event.vN.json", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[2])
+
+		b, err := fs.ReadFile(files, "events/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		s := &Schema{Event: m[1], Version: version, Document: b, schema: &openapi.Schema{}}
+		if err := json.Unmarshal(b, s.schema); err != nil {
+			return nil, fmt.Errorf("schema: %s: %w", entry.Name(), err)
+		}
+		r.schemas[s.Event] = append(r.schemas[s.Event], s)
+	}
+
+	for _, versions := range r.schemas {
+		sort.Slice(versions, func(i, k int) bool { return versions[i].Version < versions[k].Version })
+	}
+	return r, nil
+}
+
+// List returns every schema, by event and version.
+func (r *Registry) List() []*Schema {
+	var all []*Schema
+	for _, versions := range r.schemas {
+		all = append(all, versions...)
+	}
+	sort.Slice(all, func(i, k int) bool {
+		if all[i].Event != all[k].Event {
+			return all[i].Event < all[k].Event
+		}
+		return all[i].Version < all[k].Version
+	})
+	return all
+}
+
+// Latest returns the latest version of the schema of event.
+func (r *Registry) Latest(event string) (*Schema, bool) {
+	versions := r.schemas[event]
+	if len(versions) == 0 {
+		return nil, false
+	}
+	return versions[len(versions)-1], true
+}
+
+// Get returns a version of the schema of event.
+func (r *Registry) Get(event string, version int) (*Schema, bool) {
+	for _, s := range r.schemas[event] {
+		if s.Version == version {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Bus is an event.Bus publishing events with the latest version of their
+// schema, after checking their payloads against it. Unless enforce is set
+// events without a schema or with an invalid payload are published anyway
+// and the violation logged, which is meant for catching drift in staging;
+// otherwise they are not published and Publish returns ErrUnknownEvent or
+// ErrInvalidPayload.
+type Bus struct {
+	next     event.Bus
+	registry *Registry
+	enforce  bool
+}
+
+func NewBus(next event.Bus, registry *Registry, enforce bool) *Bus {
+	return &Bus{next: next, registry: registry, enforce: enforce}
+}
+
+func (b *Bus) Subscribe(name string, h event.Handler) {
+	b.next.Subscribe(name, h)
+}
+
+func (b *Bus) Publish(ctx context.Context, e event.Event) error {
+	if err := b.check(&e); err != nil {
+		if b.enforce {
+			return err
+		}
+		log.Printf("event schema: %s", err)
+	}
+	return b.next.Publish(ctx, e)
+}
+
+// check sets the schema version of e and checks its payload.
+func (b *Bus) check(e *event.Event) error {
+	s, ok := b.registry.Latest(e.Name)
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownEvent, e.Name)
+	}
+	e.Version = s.Version
+
+	errs, err := s.Validate(e.Payload)
+	if err != nil {
+		return fmt.Errorf("%w: %s v%d: %s", ErrInvalidPayload, e.Name, s.Version, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %s v%d: %s", ErrInvalidPayload, e.Name, s.Version, strings.Join(errs, "; "))
+	}
+	return nil
+}
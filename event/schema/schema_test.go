@@ -0,0 +1,95 @@
+package schema_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"hello/api/resource/auth"
+	"hello/api/resource/book"
+	"hello/api/resource/genre"
+	"hello/event"
+	"hello/event/schema"
+	testUtil "hello/util/test"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	r, err := schema.Load()
+	testUtil.NoError(t, err)
+
+	u := &auth.UserDTO{ID: uuid.NewString(), Email: "reader@example.com"}
+	tests := []struct {
+		event   string
+		payload any
+	}{
+		{book.EventCreated, &book.Book{ID: uuid.New(), Title: "Dune", Author: "Frank Herbert"}},
+		// Partial writes carry only the fields they change.
+		{book.EventUpdated, &book.Book{ID: uuid.New(), Title: "Dune"}},
+		{book.EventRestored, &book.Book{ID: uuid.New()}},
+		{book.EventDeleted, nil},
+		{genre.EventDeleted, &genre.DeletedDTO{Slug: "classics", BookIDs: []uuid.UUID{uuid.New()}}},
+		{auth.EventPasswordReset, u},
+		{auth.EventPasswordChanged, u},
+		{auth.EventUserDeleted, u},
+	}
+	for _, tc := range tests {
+		s, ok := r.Latest(tc.event)
+		testUtil.Equal(t, true, ok)
+		testUtil.Equal(t, 1, s.Version)
+		errs, err := s.Validate(tc.payload)
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, 0, len(errs))
+	}
+	testUtil.Equal(t, len(tests), len(r.List()))
+
+	s, _ := r.Latest(auth.EventUserDeleted)
+	errs, err := s.Validate(map[string]any{"id": 7, "email": "reader@example.com"})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "payload.email_verified is a required field", errs[0])
+	testUtil.Equal(t, "payload.id must be of type string", errs[1])
+
+	s, _ = r.Latest(book.EventDeleted)
+	errs, _ = s.Validate(&book.Book{})
+	testUtil.Equal(t, 1, len(errs))
+
+	_, ok := r.Get(book.EventCreated, 2)
+	testUtil.Equal(t, false, ok)
+}
+
+func TestBus(t *testing.T) {
+	t.Parallel()
+
+	r, err := schema.Load()
+	testUtil.NoError(t, err)
+
+	for _, enforce := range []bool{false, true} {
+		var got []event.Event
+		bus := schema.NewBus(event.NewBus(), r, enforce)
+		for _, name := range []string{book.EventCreated, "book.archived"} {
+			bus.Subscribe(name, func(_ context.Context, e event.Event) error {
+				got = append(got, e)
+				return nil
+			})
+		}
+
+		ctx := context.Background()
+		testUtil.NoError(t, bus.Publish(ctx, event.New(book.EventCreated, "b1", &book.Book{ID: uuid.New()})))
+		testUtil.Equal(t, 1, got[0].Version)
+
+		err := bus.Publish(ctx, event.New(book.EventCreated, "b2", "Dune"))
+		testUtil.Equal(t, enforce, errors.Is(err, schema.ErrInvalidPayload))
+		err = bus.Publish(ctx, event.New("book.archived", "b3", nil))
+		testUtil.Equal(t, enforce, errors.Is(err, schema.ErrUnknownEvent))
+
+		// Violations are only logged unless enforced.
+		want := 3
+		if enforce {
+			want = 1
+		}
+		testUtil.Equal(t, want, len(got))
+	}
+}
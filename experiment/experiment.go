@@ -0,0 +1,152 @@
+// Package experiment runs A/B tests. An experiment splits its units, users
+// or anonymous clients, between weighted variants. Assignment hashes the
+// experiment and the unit, so a unit sees the same variant on every request
+// and every instance without any stored state, for as long as the variants
+// and their weights stay the same.
+package experiment
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+var (
+	ErrInvalidKey      = errors.New("experiment: key must be a lowercase identifier")
+	ErrNoVariants      = errors.New("experiment: no variants")
+	ErrInvalidVariant  = errors.New("experiment: variant name must be a lowercase identifier")
+	ErrDuplicate       = errors.New("experiment: duplicate variant")
+	ErrInvalidWeight   = errors.New("experiment: variant weight must be positive")
+	ErrUnsupportedScan = errors.New("experiment: unsupported Variants source")
+)
+
+// Keys and variant names are written into the X-Experiments header
+// unquoted, so they are restricted to identifiers like custom field names.
+var nameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// Variant is one arm of an experiment. Units are split between variants in
+// proportion to their weights.
+//
+//	{"name": "green", "weight": 1}
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// Variants are stored as a JSON array.
+type Variants []Variant
+
+func (vs Variants) Value() (driver.Value, error) {
+	if vs == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(vs)
+	return string(b), err
+}
+
+func (vs *Variants) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*vs = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, vs)
+	case string:
+		return json.Unmarshal([]byte(v), vs)
+	default:
+		return ErrUnsupportedScan
+	}
+}
+
+// Experiment is a named split of units between variants.
+type Experiment struct {
+	Key      string   `json:"-"`
+	Variants Variants `json:"variants"`
+}
+
+// Validate reports the first problem with e.
+func (e *Experiment) Validate() error {
+	if !nameRegexp.MatchString(e.Key) {
+		return ErrInvalidKey
+	}
+	if len(e.Variants) == 0 {
+		return ErrNoVariants
+	}
+
+	seen := make(map[string]bool, len(e.Variants))
+	for _, v := range e.Variants {
+		if !nameRegexp.MatchString(v.Name) {
+			return ErrInvalidVariant
+		}
+		if seen[v.Name] {
+			return ErrDuplicate
+		}
+		seen[v.Name] = true
+		if v.Weight <= 0 {
+			return ErrInvalidWeight
+		}
+	}
+	return nil
+}
+
+// Assign returns the variant of unit. Units are spread uniformly by a hash
+// of the experiment key and the unit, so that the same unit falls into
+// unrelated variants in different experiments.
+func (e *Experiment) Assign(unit string) string {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(e.Key + "\x00" + unit))
+	point := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	for _, v := range e.Variants {
+		if point < v.Weight {
+			return v.Name
+		}
+		point -= v.Weight
+	}
+	return ""
+}
+
+// Experiments maps experiment keys to their definition.
+//
+//	{"checkout_button": {"variants": [{"name": "control", "weight": 1}, {"name": "green", "weight": 1}]}}
+type Experiments map[string]*Experiment
+
+// Load reads an experiments file. An empty path yields no experiments.
+func Load(path string) (Experiments, error) {
+	if path == "" {
+		return Experiments{}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var es Experiments
+	if err := json.Unmarshal(b, &es); err != nil {
+		return nil, fmt.Errorf("experiment: parse %s: %w", path, err)
+	}
+
+	for key, e := range es {
+		if e == nil {
+			e = &Experiment{}
+			es[key] = e
+		}
+		e.Key = key
+		if err := e.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, key)
+		}
+	}
+	return es, nil
+}
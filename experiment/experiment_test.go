@@ -0,0 +1,128 @@
+package experiment_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/experiment"
+	testUtil "hello/util/test"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		testUtil.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	es, err := experiment.Load(write("ok.json", `{"checkout_button": {"variants": [{"name": "control", "weight": 1}, {"name": "green", "weight": 3}]}}`))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "checkout_button", es["checkout_button"].Key)
+	testUtil.Equal(t, 2, len(es["checkout_button"].Variants))
+	testUtil.Equal(t, experiment.Variant{Name: "green", Weight: 3}, es["checkout_button"].Variants[1])
+
+	for _, content := range []string{
+		`{"Bad Key": {"variants": [{"name": "a", "weight": 1}]}}`,
+		`{"x": {"variants": []}}`,
+		`{"x": null}`,
+		`{"x": {"variants": [{"name": "a", "weight": 1}, {"name": "a", "weight": 1}]}}`,
+		`{"x": {"variants": [{"name": "a", "weight": 0}]}}`,
+		`{"x": {"variants": [{"name": "a=b", "weight": 1}]}}`,
+	} {
+		_, err := experiment.Load(write("bad.json", content))
+		testUtil.Equal(t, true, err != nil)
+	}
+
+	es, err = experiment.Load("")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 0, len(es))
+}
+
+func TestExperiment_Assign(t *testing.T) {
+	t.Parallel()
+
+	e := &experiment.Experiment{Key: "shelf", Variants: experiment.Variants{{Name: "a", Weight: 1}, {Name: "b", Weight: 3}}}
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		unit := "unit-" + strconv.Itoa(i)
+		v := e.Assign(unit)
+		testUtil.Equal(t, v, e.Assign(unit))
+		counts[v]++
+	}
+	testUtil.Equal(t, 2, len(counts))
+	testUtil.Equal(t, true, counts["a"] > 800 && counts["a"] < 1200)
+
+	// The same units are split independently by another experiment.
+	other := &experiment.Experiment{Key: "banner", Variants: e.Variants}
+	same := 0
+	for i := 0; i < 1000; i++ {
+		unit := "unit-" + strconv.Itoa(i)
+		if e.Assign(unit) == other.Assign(unit) {
+			same++
+		}
+	}
+	testUtil.Equal(t, true, same < 800)
+}
+
+type exposures []experiment.Exposure
+
+func (es *exposures) LogExposure(_ context.Context, ex experiment.Exposure) {
+	*es = append(*es, ex)
+}
+
+func TestService_Middleware(t *testing.T) {
+	t.Parallel()
+
+	logged := &exposures{}
+	s := experiment.New(experiment.Experiments{
+		"shelf":  {Key: "shelf", Variants: experiment.Variants{{Name: "grid", Weight: 1}}},
+		"banner": {Key: "banner", Variants: experiment.Variants{{Name: "off", Weight: 1}}},
+	}, logged)
+	s.Set(experiment.Experiments{
+		"banner": {Key: "banner", Variants: experiment.Variants{{Name: "on", Weight: 1}}},
+	})
+	testUtil.Equal(t, true, s.Stored("banner"))
+	testUtil.Equal(t, false, s.Stored("shelf"))
+
+	h := user.Middleware(s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(experiment.VariantOf(r.Context(), "banner") + experiment.VariantOf(r.Context(), "banner") + experiment.VariantOf(r.Context(), "missing")))
+	})))
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("", "")
+	testUtil.Equal(t, "", w.Body.String())
+	testUtil.Equal(t, "", w.Header().Get(experiment.HeaderAssignments))
+	testUtil.Equal(t, 0, len(*logged))
+
+	w = serve(experiment.HeaderKey, "device-1")
+	testUtil.Equal(t, "onon", w.Body.String())
+	testUtil.Equal(t, "banner=on", w.Header().Get(experiment.HeaderAssignments))
+	testUtil.Equal(t, 1, len(*logged))
+	testUtil.Equal(t, "device-1", (*logged)[0].Key)
+	testUtil.Equal(t, "on", (*logged)[0].Variant)
+
+	id := uuid.New()
+	serve(user.Header, id.String())
+	testUtil.Equal(t, 2, len(*logged))
+	testUtil.Equal(t, id, *(*logged)[1].UserID)
+	testUtil.Equal(t, "", (*logged)[1].Key)
+}
@@ -0,0 +1,260 @@
+package experiment
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+)
+
+const (
+	// HeaderKey identifies anonymous clients, which should send a random
+	// key that stays the same across their visits. Signed-in users are
+	// assigned by their ID instead, so they see the same variants on every
+	// device.
+	HeaderKey = "X-Experiment-Key"
+	// HeaderAssignments lists the variants a response was served with, as
+	// experiment=variant pairs separated by commas.
+	HeaderAssignments = "X-Experiments"
+
+	maxKeyLen = 64
+)
+
+// Exposure records that a unit was served a variant.
+type Exposure struct {
+	Experiment string
+	Variant    string
+	UserID     *uuid.UUID
+	// Key is the anonymous client's key; it is empty for signed-in users.
+	Key string
+	At  time.Time
+}
+
+// Logger takes exposures to the analytics pipeline. It is called on the
+// request path, so it should only buffer.
+type Logger interface {
+	LogExposure(ctx context.Context, ex Exposure)
+}
+
+// Service holds the running experiments: those of the experiments file,
+// which are fixed, and those stored in the database, which are reloaded
+// and take precedence.
+type Service struct {
+	static Experiments
+	logger Logger
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	stored Experiments
+	all    Experiments
+}
+
+// New returns a service running the static experiments. Exposures go to
+// logger unless it is nil.
+func New(static Experiments, logger Logger) *Service {
+	s := &Service{
+		static: static,
+		logger: logger,
+		now:    time.Now,
+	}
+	s.Set(nil)
+	return s
+}
+
+// Set replaces the stored experiments.
+func (s *Service) Set(stored Experiments) {
+	all := make(Experiments, len(s.static)+len(stored))
+	for key, e := range s.static {
+		all[key] = e
+	}
+	for key, e := range stored {
+		all[key] = e
+	}
+
+	s.mu.Lock()
+	s.stored, s.all = stored, all
+	s.mu.Unlock()
+}
+
+// Get returns the running experiment key.
+func (s *Service) Get(key string) (*Experiment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.all[key]
+	return e, ok
+}
+
+// List returns the running experiments by key.
+func (s *Service) List() []*Experiment {
+	s.mu.RLock()
+	es := make([]*Experiment, 0, len(s.all))
+	for _, e := range s.all {
+		es = append(es, e)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(es, func(a, b *Experiment) int { return strings.Compare(a.Key, b.Key) })
+	return es
+}
+
+// Stored reports whether the running experiment key is a stored one rather
+// than that of the experiments file.
+func (s *Service) Stored(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.stored[key]
+	return ok
+}
+
+// Run reloads the stored experiments every interval until ctx is done. A
+// failed load keeps the experiments running as they are.
+func (s *Service) Run(ctx context.Context, interval time.Duration, load func(context.Context) (Experiments, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stored, err := load(ctx)
+			if err != nil {
+				log.Printf("experiment reload: %s", err)
+				continue
+			}
+			s.Set(stored)
+		}
+	}
+}
+
+// Assignment is the variant of one experiment served to a request.
+type Assignment struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+}
+
+type ctxKey struct{}
+
+type assigner struct {
+	s      *Service
+	userID *uuid.UUID
+	key    string
+
+	mu       sync.Mutex
+	assigned []Assignment
+}
+
+// Middleware identifies the unit of the request, the signed-in user or else
+// the X-Experiment-Key, for handlers to look up variants with VariantOf. The
+// variants looked up are listed in the X-Experiments header of the response.
+// It must run after the middleware identifying users.
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := &assigner{s: s}
+		if id, ok := user.From(r.Context()); ok {
+			a.userID = &id
+		} else if key := r.Header.Get(HeaderKey); len(key) <= maxKeyLen {
+			a.key = key
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, a))
+		next.ServeHTTP(&writer{ResponseWriter: w, a: a}, r)
+	})
+}
+
+// VariantOf returns the variant of experiment key served to the request, or ""
+// when the experiment is not running or the request has no unit to assign.
+// The first lookup in a request logs an exposure, so handlers should look up
+// a variant only where they act on it.
+func VariantOf(ctx context.Context, key string) string {
+	a, ok := ctx.Value(ctxKey{}).(*assigner)
+	if !ok || (a.userID == nil && a.key == "") {
+		return ""
+	}
+	e, ok := a.s.Get(key)
+	if !ok {
+		return ""
+	}
+	return a.assign(ctx, e)
+}
+
+// All returns the variants of every running experiment served to the
+// request, logging an exposure for each. It is meant for clients that
+// render experiments themselves.
+func All(ctx context.Context) []Assignment {
+	a, ok := ctx.Value(ctxKey{}).(*assigner)
+	if !ok || (a.userID == nil && a.key == "") {
+		return []Assignment{}
+	}
+
+	for _, e := range a.s.List() {
+		a.assign(ctx, e)
+	}
+	return a.list()
+}
+
+func (a *assigner) assign(ctx context.Context, e *Experiment) string {
+	unit := a.key
+	if a.userID != nil {
+		unit = a.userID.String()
+	}
+	variant := e.Assign(unit)
+
+	a.mu.Lock()
+	seen := slices.ContainsFunc(a.assigned, func(as Assignment) bool { return as.Experiment == e.Key })
+	if !seen {
+		a.assigned = append(a.assigned, Assignment{Experiment: e.Key, Variant: variant})
+	}
+	a.mu.Unlock()
+
+	if !seen && a.s.logger != nil {
+		a.s.logger.LogExposure(ctx, Exposure{
+			Experiment: e.Key,
+			Variant:    variant,
+			UserID:     a.userID,
+			Key:        a.key,
+			At:         a.s.now().UTC(),
+		})
+	}
+	return variant
+}
+
+func (a *assigner) list() []Assignment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Assignment{}, a.assigned...)
+}
+
+type writer struct {
+	http.ResponseWriter
+	a           *assigner
+	wroteHeader bool
+}
+
+func (w *writer) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if assigned := w.a.list(); len(assigned) > 0 {
+			pairs := make([]string, len(assigned))
+			for i, as := range assigned {
+				pairs[i] = as.Experiment + "=" + as.Variant
+			}
+			w.Header().Set(HeaderAssignments, strings.Join(pairs, ", "))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
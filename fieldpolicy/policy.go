@@ -0,0 +1,95 @@
+package fieldpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Policy restricts response fields by scope. It maps a resource name to the
+// JSON paths of restricted fields ("notes", "computed.age_years") and the
+// scopes allowed to see each one. Fields without a rule are visible to
+// everyone, and a rule with no scopes hides the field from everyone.
+//
+//	{"book": {"custom_fields": ["admin", "staff"]}}
+type Policy map[string]map[string][]string
+
+// Load reads a policy file. An empty path yields an empty policy.
+func Load(path string) (Policy, error) {
+	if path == "" {
+		return Policy{}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Policy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("fieldpolicy: parse %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Mask returns v with the fields the scopes may not see removed. v is
+// re-encoded as generic JSON when the resource has rules, and returned as is
+// otherwise. Slices are masked element by element.
+func (p Policy) Mask(resource string, scopes []string, v any) (any, error) {
+	hidden := p.Hidden(resource, scopes)
+	if len(hidden) == 0 {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic any
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+
+	for _, path := range hidden {
+		remove(generic, strings.Split(path, "."))
+	}
+	return generic, nil
+}
+
+// Hidden returns the paths of the resource's fields the scopes may not see.
+func (p Policy) Hidden(resource string, scopes []string) []string {
+	var hidden []string
+	for path, allowed := range p[resource] {
+		if !intersects(allowed, scopes) {
+			hidden = append(hidden, path)
+		}
+	}
+	return hidden
+}
+
+func intersects(allowed, scopes []string) bool {
+	for _, s := range scopes {
+		if slices.Contains(allowed, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(v any, path []string) {
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			remove(item, path)
+		}
+	case map[string]any:
+		if len(path) == 1 {
+			delete(v, path[0])
+			return
+		}
+		remove(v[path[0]], path[1:])
+	}
+}
@@ -0,0 +1,64 @@
+package fieldpolicy_test
+
+import (
+	"testing"
+
+	"hello/fieldpolicy"
+	testUtil "hello/util/test"
+)
+
+type item struct {
+	Title    string         `json:"title"`
+	Notes    string         `json:"notes"`
+	Computed map[string]any `json:"computed"`
+}
+
+func TestPolicy_Mask(t *testing.T) {
+	t.Parallel()
+
+	p := fieldpolicy.Policy{
+		"book": {
+			"notes":             {"admin"},
+			"computed.internal": {},
+		},
+	}
+	items := []item{{Title: "T", Notes: "N", Computed: map[string]any{"internal": 1, "age": 2}}}
+
+	tests := []struct {
+		name      string
+		scopes    []string
+		wantNotes bool
+	}{
+		{"anonymous", nil, false},
+		{"reader", []string{"reader"}, false},
+		{"admin", []string{"reader", "admin"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			masked, err := p.Mask("book", tc.scopes, items)
+			testUtil.NoError(t, err)
+
+			got := masked.([]any)[0].(map[string]any)
+			_, hasNotes := got["notes"]
+			testUtil.Equal(t, tc.wantNotes, hasNotes)
+			testUtil.Equal(t, "T", got["title"].(string))
+
+			computed := got["computed"].(map[string]any)
+			_, hasInternal := computed["internal"]
+			testUtil.Equal(t, false, hasInternal)
+			testUtil.Equal(t, 2.0, computed["age"].(float64))
+		})
+	}
+}
+
+func TestPolicy_MaskUnrestricted(t *testing.T) {
+	t.Parallel()
+
+	p := fieldpolicy.Policy{}
+	v := &item{Title: "T"}
+
+	masked, err := p.Mask("book", nil, v)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, any(v), masked)
+}
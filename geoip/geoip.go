@@ -0,0 +1,300 @@
+// Package geoip looks up the country of IP addresses in a MaxMind DB file,
+// such as GeoLite2 Country or GeoIP2 Country. It reads the format described
+// at https://maxmind.github.io/MaxMind-DB/, as much of it as country
+// lookups need.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"net/netip"
+	"os"
+)
+
+var ErrInvalidDB = errors.New("geoip: invalid database")
+
+// metadataMarker precedes the metadata at the end of the file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Types of the data section.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds the nesting of decoded values, so that a corrupt file
+// cannot recurse forever through pointers.
+const maxDepth = 32
+
+// DB is a MaxMind DB loaded in memory. It is safe for concurrent use.
+type DB struct {
+	tree       []byte
+	data       []byte
+	nodes      uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node IPv4 lookups start at in an IPv6 tree, where
+	// IPv4 addresses sit under ::/96.
+	ipv4Start uint
+}
+
+// Open loads the database at path.
+func Open(path string) (*DB, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(b)
+}
+
+// New reads a database from its content.
+func New(b []byte) (*DB, error) {
+	i := bytes.LastIndex(b, metadataMarker)
+	if i < 0 {
+		return nil, ErrInvalidDB
+	}
+	v, _, err := decode(b[i+len(metadataMarker):], 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return nil, ErrInvalidDB
+	}
+	nodes, _ := meta["node_count"].(uint64)
+	recordSize, _ := meta["record_size"].(uint64)
+	ipVersion, _ := meta["ip_version"].(uint64)
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 || ipVersion != 4 && ipVersion != 6 {
+		return nil, ErrInvalidDB
+	}
+
+	// The tree is followed by 16 zero bytes, then the data section.
+	treeSize := nodes * recordSize * 2 / 8
+	if treeSize+16 > uint64(i) {
+		return nil, ErrInvalidDB
+	}
+	db := &DB{
+		tree:       b[:treeSize],
+		data:       b[treeSize+16 : i],
+		nodes:      uint(nodes),
+		recordSize: uint(recordSize),
+		ipVersion:  uint(ipVersion),
+	}
+	if db.ipVersion == 6 {
+		for j := 0; j < 96 && db.ipv4Start < db.nodes; j++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+	return db, nil
+}
+
+// Country returns the ISO 3166-1 code of the country ip is located in, or
+// else of the country it is registered in. It returns "" for addresses the
+// database does not know, such as private ones.
+func (db *DB) Country(ip netip.Addr) (string, error) {
+	v, err := db.lookup(ip)
+	if err != nil {
+		return "", err
+	}
+	record, _ := v.(map[string]any)
+	for _, name := range []string{"country", "registered_country"} {
+		country, _ := record[name].(map[string]any)
+		if code, _ := country["iso_code"].(string); code != "" {
+			return code, nil
+		}
+	}
+	return "", nil
+}
+
+// lookup returns the data record of the network holding ip, or nil.
+func (db *DB) lookup(ip netip.Addr) (any, error) {
+	var bits []byte
+	node := uint(0)
+	if ip = ip.Unmap(); ip.Is4() {
+		a := ip.As4()
+		bits = a[:]
+		node = db.ipv4Start
+	} else {
+		if db.ipVersion == 4 {
+			return nil, nil
+		}
+		a := ip.As16()
+		bits = a[:]
+	}
+
+	for i := 0; i < len(bits)*8 && node < db.nodes; i++ {
+		node = db.record(node, uint(bits[i/8]>>(7-i%8))&1)
+	}
+	switch {
+	case node == db.nodes:
+		return nil, nil
+	case node < db.nodes:
+		return nil, ErrInvalidDB
+	}
+
+	off := node - db.nodes - 16
+	if off >= uint(len(db.data)) {
+		return nil, ErrInvalidDB
+	}
+	v, _, err := decode(db.data, int(off), 0)
+	return v, err
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node.
+func (db *DB) record(node, bit uint) uint {
+	b := db.tree
+	switch db.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		// The middle byte holds the high nibbles of both records.
+		off := node * 7
+		if bit == 0 {
+			return uint(b[off+3]&0xF0)<<20 | uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+		}
+		return uint(b[off+3]&0x0F)<<24 | uint(b[off+4])<<16 | uint(b[off+5])<<8 | uint(b[off+6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(b[off:]))
+	}
+}
+
+// decode returns the value at off in b and the offset following it.
+// Pointers are offsets in b, which is the data or the metadata section.
+func decode(b []byte, off, depth int) (any, int, error) {
+	if depth > maxDepth || off < 0 || off >= len(b) {
+		return nil, 0, ErrInvalidDB
+	}
+	ctrl := b[off]
+	off++
+
+	typ := int(ctrl >> 5)
+	if typ == typePointer {
+		n := int(ctrl>>3&3) + 1
+		if off+n > len(b) {
+			return nil, 0, ErrInvalidDB
+		}
+		p, v := 0, int(ctrl&7)
+		switch n {
+		case 1:
+			p = v<<8 | int(b[off])
+		case 2:
+			p = v<<16 | int(b[off])<<8 | int(b[off+1]) + 2048
+		case 3:
+			p = v<<24 | int(b[off])<<16 | int(b[off+1])<<8 | int(b[off+2]) + 526336
+		case 4:
+			p = int(binary.BigEndian.Uint32(b[off:]))
+		}
+		value, _, err := decode(b, p, depth+1)
+		return value, off + n, err
+	}
+	if typ == typeExtended {
+		if off >= len(b) {
+			return nil, 0, ErrInvalidDB
+		}
+		typ = 7 + int(b[off])
+		off++
+	}
+
+	size := int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if off+n > len(b) {
+			return nil, 0, ErrInvalidDB
+		}
+		extra := 0
+		for _, c := range b[off : off+n] {
+			extra = extra<<8 | int(c)
+		}
+		size = [...]int{29, 285, 65821}[n-1] + extra
+		off += n
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, min(size, 64))
+		for i := 0; i < size; i++ {
+			k, next, err := decode(b, off, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, ErrInvalidDB
+			}
+			if m[key], off, err = decode(b, next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, off, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 64))
+		for i := 0; i < size; i++ {
+			v, next, err := decode(b, off, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			off = next
+		}
+		return a, off, nil
+	case typeBool:
+		return size != 0, off, nil
+	}
+
+	if off+size > len(b) {
+		return nil, 0, ErrInvalidDB
+	}
+	raw := b[off : off+size]
+	off += size
+	switch typ {
+	case typeString:
+		return string(raw), off, nil
+	case typeBytes:
+		return bytes.Clone(raw), off, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, ErrInvalidDB
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), off, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, ErrInvalidDB
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(raw)), off, nil
+	case typeUint16, typeUint32, typeUint64, typeInt32:
+		if size > 8 {
+			return nil, 0, ErrInvalidDB
+		}
+		var u uint64
+		for _, c := range raw {
+			u = u<<8 | uint64(c)
+		}
+		if typ == typeInt32 {
+			return int32(uint32(u)), off, nil
+		}
+		return u, off, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(raw), off, nil
+	default:
+		return nil, 0, ErrInvalidDB
+	}
+}
@@ -0,0 +1,167 @@
+package geoip_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"hello/geoip"
+	testUtil "hello/util/test"
+)
+
+type network struct {
+	prefix string
+	// field is "country" or "registered_country".
+	field   string
+	country string
+}
+
+// node is a node of the search tree being built; a child is either a node
+// or the index of a network, or empty.
+type node struct {
+	children [2]*node
+	leaves   [2]int
+}
+
+// build writes an IPv6 MaxMind DB of networks with records of recordSize
+// bits. The keys of data records after the first are pointers to those of
+// the first, as real databases have them.
+func build(t *testing.T, recordSize int, networks []network) []byte {
+	t.Helper()
+
+	root := &node{leaves: [2]int{-1, -1}}
+	for i, n := range networks {
+		p := netip.MustParsePrefix(n.prefix)
+		addr, bits := p.Addr().As16(), p.Bits()
+		if p.Addr().Is4() {
+			// IPv4 networks sit under ::/96.
+			a4 := p.Addr().As4()
+			addr = [16]byte{12: a4[0], 13: a4[1], 14: a4[2], 15: a4[3]}
+			bits += 96
+		}
+		cur := root
+		for j := 0; j < bits; j++ {
+			bit := int(addr[j/8]>>(7-j%8)) & 1
+			if j == bits-1 {
+				cur.leaves[bit] = i
+				break
+			}
+			if cur.children[bit] == nil {
+				cur.children[bit] = &node{leaves: [2]int{-1, -1}}
+			}
+			cur = cur.children[bit]
+		}
+	}
+
+	var nodes []*node
+	index := map[*node]int{}
+	for queue := []*node{root}; len(queue) > 0; queue = queue[1:] {
+		index[queue[0]] = len(nodes)
+		nodes = append(nodes, queue[0])
+		for _, c := range queue[0].children {
+			if c != nil {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	var data []byte
+	offsets := make([]int, len(networks))
+	keys := map[string]int{}
+	key := func(k string) {
+		if p, ok := keys[k]; ok {
+			data = append(data, 1<<5|byte(p>>8), byte(p))
+			return
+		}
+		keys[k] = len(data)
+		data = append(data, str(k)...)
+	}
+	for i, n := range networks {
+		offsets[i] = len(data)
+		data = append(data, 7<<5|1)
+		key(n.field)
+		data = append(data, 7<<5|1)
+		key("iso_code")
+		data = append(data, str(n.country)...)
+	}
+
+	record := func(n *node, bit int) uint32 {
+		switch {
+		case n.children[bit] != nil:
+			return uint32(index[n.children[bit]])
+		case n.leaves[bit] >= 0:
+			return uint32(len(nodes) + 16 + offsets[n.leaves[bit]])
+		default:
+			return uint32(len(nodes))
+		}
+	}
+	var tree []byte
+	for _, n := range nodes {
+		l, r := record(n, 0), record(n, 1)
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			tree = append(tree, byte(l>>16), byte(l>>8), byte(l), byte(l>>24<<4)|byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		case 32:
+			tree = append(tree, byte(l>>24), byte(l>>16), byte(l>>8), byte(l), byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		}
+	}
+
+	b := append(tree, make([]byte, 16)...)
+	b = append(b, data...)
+	b = append(b, "\xAB\xCD\xEFMaxMind.com"...)
+	b = append(b, 7<<5|3)
+	b = append(b, str("node_count")...)
+	b = append(b, 6<<5|4, byte(len(nodes)>>24), byte(len(nodes)>>16), byte(len(nodes)>>8), byte(len(nodes)))
+	b = append(b, str("record_size")...)
+	b = append(b, 5<<5|1, byte(recordSize))
+	b = append(b, str("ip_version")...)
+	b = append(b, 5<<5|1, 6)
+	return b
+}
+
+func str(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+func TestDB_Country(t *testing.T) {
+	t.Parallel()
+
+	networks := []network{
+		{"81.2.69.0/24", "country", "GB"},
+		{"2.125.160.0/19", "country", "DE"},
+		{"175.16.199.0/24", "registered_country", "CN"},
+		{"2001:db8::/32", "country", "FR"},
+	}
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"81.2.69.160", "GB"},
+		{"2.125.191.255", "DE"},
+		{"2.125.192.0", ""},
+		{"175.16.199.1", "CN"},
+		{"::ffff:81.2.69.1", "GB"},
+		{"2001:db8::1", "FR"},
+		{"2001:db9::1", ""},
+		{"10.0.0.1", ""},
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		db, err := geoip.New(build(t, recordSize, networks))
+		testUtil.NoError(t, err)
+
+		for _, tt := range tests {
+			got, err := db.Country(netip.MustParseAddr(tt.ip))
+			testUtil.NoError(t, err)
+			testUtil.Equal(t, tt.want, got)
+		}
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := geoip.New([]byte("not a database"))
+	testUtil.Equal(t, geoip.ErrInvalidDB, err)
+}
@@ -0,0 +1,163 @@
+// Package idcodec controls how IDs appear in public DTOs and URLs. The
+// default codec shows plain UUIDs; the short one encodes them in 22 base62
+// characters, and the opaque one encrypts them first with a secret key so
+// that the internal IDs are not exposed at all. Each codec only decodes IDs
+// in its own form.
+package idcodec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NameUUID   = "uuid"
+	NameShort  = "short"
+	NameOpaque = "opaque"
+)
+
+var ErrInvalidID = errors.New("idcodec: invalid id")
+
+// Codec converts between UUIDs and their public form.
+type Codec interface {
+	Encode(id uuid.UUID) string
+	Decode(s string) (uuid.UUID, error)
+}
+
+// New returns the codec of the given name. The opaque codec needs a key.
+func New(name, key string) (Codec, error) {
+	switch name {
+	case "", NameUUID:
+		return UUID{}, nil
+	case NameShort:
+		return Short{}, nil
+	case NameOpaque:
+		if key == "" {
+			return nil, errors.New("idcodec: the opaque codec needs a key")
+		}
+		return NewOpaque(key), nil
+	default:
+		return nil, fmt.Errorf("idcodec: unknown codec %q", name)
+	}
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(codec{UUID{}})
+}
+
+// codec wraps a Codec so that codecs of different types can share current.
+type codec struct {
+	Codec
+}
+
+// Set makes c the codec used by Encode and Decode. It is meant to be called
+// once at startup.
+func Set(c Codec) {
+	current.Store(codec{c})
+}
+
+// Encode returns the public form of id.
+func Encode(id uuid.UUID) string {
+	return current.Load().(codec).Encode(id)
+}
+
+// Decode returns the UUID of a public ID.
+func Decode(s string) (uuid.UUID, error) {
+	return current.Load().(codec).Decode(s)
+}
+
+// UUID shows IDs as canonical UUIDs.
+type UUID struct{}
+
+func (UUID) Encode(id uuid.UUID) string {
+	return id.String()
+}
+
+func (UUID) Decode(s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil || len(s) != 36 {
+		return uuid.Nil, ErrInvalidID
+	}
+	return id, nil
+}
+
+// Short shows IDs in base62, always 22 characters long.
+type Short struct{}
+
+func (Short) Encode(id uuid.UUID) string {
+	return encode62(id[:])
+}
+
+func (Short) Decode(s string) (uuid.UUID, error) {
+	var id uuid.UUID
+	if err := decode62(s, id[:]); err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+// Opaque encrypts IDs with AES under a key derived from a secret, then
+// shows them in base62. A UUID is exactly one AES block, so the encoding is
+// reversible and as long as the short one, and tokens cannot be linked to
+// the IDs they stand for without the secret.
+type Opaque struct {
+	block cipher.Block
+}
+
+func NewOpaque(secret string) *Opaque {
+	key := sha256.Sum256([]byte(secret))
+	block, _ := aes.NewCipher(key[:])
+	return &Opaque{block: block}
+}
+
+func (o *Opaque) Encode(id uuid.UUID) string {
+	var b [aes.BlockSize]byte
+	o.block.Encrypt(b[:], id[:])
+	return encode62(b[:])
+}
+
+func (o *Opaque) Decode(s string) (uuid.UUID, error) {
+	var b [aes.BlockSize]byte
+	if err := decode62(s, b[:]); err != nil {
+		return uuid.Nil, err
+	}
+
+	var id uuid.UUID
+	o.block.Decrypt(id[:], b[:])
+	return id, nil
+}
+
+const (
+	alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// width is the number of base62 digits of a 128-bit number.
+	width = 22
+)
+
+var max128 = new(big.Int).Lsh(big.NewInt(1), 128)
+
+func encode62(b []byte) string {
+	s := new(big.Int).SetBytes(b).Text(62)
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+func decode62(s string, dst []byte) error {
+	if len(s) != width || strings.Trim(s, alphabet) != "" {
+		return ErrInvalidID
+	}
+	n, ok := new(big.Int).SetString(s, 62)
+	if !ok || n.Cmp(max128) >= 0 {
+		return ErrInvalidID
+	}
+	n.FillBytes(dst)
+	return nil
+}
@@ -0,0 +1,85 @@
+package idcodec_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"hello/idcodec"
+	testUtil "hello/util/test"
+)
+
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	ids := []uuid.UUID{uuid.Nil, uuid.Max, uuid.New(), uuid.New()}
+
+	tests := []struct {
+		name   string
+		key    string
+		length int
+	}{
+		{idcodec.NameUUID, "", 36},
+		{idcodec.NameShort, "", 22},
+		{idcodec.NameOpaque, "secret", 22},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := idcodec.New(tc.name, tc.key)
+			testUtil.NoError(t, err)
+
+			for _, id := range ids {
+				s := c.Encode(id)
+				testUtil.Equal(t, len(s), tc.length)
+
+				got, err := c.Decode(s)
+				testUtil.NoError(t, err)
+				testUtil.Equal(t, got, id)
+			}
+
+			for _, bad := range []string{"", "books", "zzzzzzzzzzzzzzzzzzzzzz", "0000000000000000000000-", "00000000-0000-0000-0000-00000000000g"} {
+				_, err := c.Decode(bad)
+				testUtil.Equal(t, err, idcodec.ErrInvalidID)
+			}
+		})
+	}
+}
+
+func TestCodecs_OwnFormOnly(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	short, _ := idcodec.New(idcodec.NameShort, "")
+	plain, _ := idcodec.New(idcodec.NameUUID, "")
+
+	_, err := short.Decode(id.String())
+	testUtil.Equal(t, err, idcodec.ErrInvalidID)
+	_, err = plain.Decode(short.Encode(id))
+	testUtil.Equal(t, err, idcodec.ErrInvalidID)
+}
+
+func TestOpaque_Keyed(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	a := idcodec.NewOpaque("one")
+	b := idcodec.NewOpaque("two")
+	short := idcodec.Short{}
+
+	testUtil.Equal(t, a.Encode(id) != b.Encode(id), true)
+	testUtil.Equal(t, a.Encode(id) != short.Encode(id), true)
+
+	// Another key decodes to a different ID rather than the original one.
+	got, err := b.Decode(a.Encode(id))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, got != id, true)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := idcodec.New(idcodec.NameOpaque, "")
+	testUtil.Equal(t, err != nil, true)
+	_, err = idcodec.New("hashids", "")
+	testUtil.Equal(t, err != nil, true)
+}
@@ -0,0 +1,72 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const jpegQuality = 90
+
+var (
+	ErrNotImage = errors.New("imaging: not a supported image")
+	ErrTooLarge = errors.New("imaging: image dimensions too large")
+)
+
+// Limits bounds the pixel dimensions of accepted images.
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+}
+
+// Sanitize checks that data is a JPEG, PNG or GIF image within limits and
+// re-encodes it from its decoded pixels, which drops EXIF and any other
+// metadata or trailing payload. The dimensions are read from the header
+// before any pixels are decoded, so oversized images are rejected without
+// allocating them. JPEGs are rotated upright according to their EXIF
+// orientation and stay JPEG; other formats become PNG. It returns the new
+// image and its media type.
+func Sanitize(data []byte, limits Limits) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrNotImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, "", ErrNotImage
+	}
+	if cfg.Width > limits.MaxWidth || cfg.Height > limits.MaxHeight {
+		return nil, "", ErrTooLarge
+	}
+
+	var img image.Image
+	switch format {
+	case "jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, "", ErrNotImage
+	}
+	if err != nil {
+		return nil, "", ErrNotImage
+	}
+
+	var out bytes.Buffer
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+		if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, "", err
+		}
+		return out.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(&out, img); err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), "image/png", nil
+}
@@ -0,0 +1,132 @@
+package imaging_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"hello/imaging"
+	testUtil "hello/util/test"
+)
+
+var limits = imaging.Limits{MaxWidth: 100, MaxHeight: 100}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	var buf bytes.Buffer
+	testUtil.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// jpegWithOrientation encodes a w×h JPEG carrying an EXIF orientation tag.
+func jpegWithOrientation(t *testing.T, w, h int, orientation uint16) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	testUtil.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil))
+	data := buf.Bytes()
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(payload)+2))
+	app1 = append(app1, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestSanitize(t *testing.T) {
+	t.Parallel()
+
+	t.Run("strips metadata and applies orientation", func(t *testing.T) {
+		out, contentType, err := imaging.Sanitize(jpegWithOrientation(t, 4, 2, 6), limits)
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, "image/jpeg", contentType)
+		testUtil.Equal(t, false, bytes.Contains(out, []byte("Exif")))
+
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, 2, cfg.Width)
+		testUtil.Equal(t, 4, cfg.Height)
+	})
+
+	t.Run("drops trailing payload", func(t *testing.T) {
+		polyglot := append(encodePNG(t, 2, 2), []byte("<script>alert(1)</script>")...)
+
+		out, contentType, err := imaging.Sanitize(polyglot, limits)
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, "image/png", contentType)
+		testUtil.Equal(t, false, bytes.Contains(out, []byte("<script>")))
+	})
+
+	t.Run("rejects oversized dimensions", func(t *testing.T) {
+		_, _, err := imaging.Sanitize(encodePNG(t, 101, 1), limits)
+		testUtil.Equal(t, imaging.ErrTooLarge, err)
+	})
+
+	t.Run("rejects non-images", func(t *testing.T) {
+		_, _, err := imaging.Sanitize([]byte("%PDF-1.4"), limits)
+		testUtil.Equal(t, imaging.ErrNotImage, err)
+	})
+}
+
+func TestThumbnail(t *testing.T) {
+	t.Parallel()
+
+	// A 40×20 image, red on the left half and blue on the right.
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 20 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	testUtil.NoError(t, png.Encode(&buf, img))
+
+	thumb, contentType, err := imaging.Thumbnail(buf.Bytes(), 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "image/png", contentType)
+	out, err := png.Decode(bytes.NewReader(thumb))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, image.Rect(0, 0, 10, 5), out.Bounds())
+	testUtil.Equal(t, color.RGBAModel.Convert(color.RGBA{R: 255, A: 255}), color.RGBAModel.Convert(out.At(0, 0)))
+	testUtil.Equal(t, color.RGBAModel.Convert(color.RGBA{B: 255, A: 255}), color.RGBAModel.Convert(out.At(9, 4)))
+
+	// Images within the size are kept, and JPEGs stay JPEGs.
+	same, _, err := imaging.Thumbnail(buf.Bytes(), 40)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, true, bytes.Equal(buf.Bytes(), same))
+	tall := jpegWithOrientation(t, 30, 90, 1)
+	thumb, contentType, err = imaging.Thumbnail(tall, 30)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "image/jpeg", contentType)
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 10, cfg.Width)
+	testUtil.Equal(t, 30, cfg.Height)
+
+	_, _, err = imaging.Thumbnail([]byte("not an image"), 10)
+	testUtil.Equal(t, imaging.ErrNotImage, err)
+}
@@ -0,0 +1,69 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// Thumbnail scales a JPEG or PNG image, as returned by Sanitize, down to
+// fit within size×size pixels, keeping its aspect ratio and format. Each
+// pixel of the thumbnail averages the pixels it covers. Images already
+// within size are returned as they are.
+func Thumbnail(data []byte, size int) ([]byte, string, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, "", ErrNotImage
+	}
+	contentType := "image/" + format
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return data, contentType, nil
+	}
+	tw, th := size, max(1, h*size/w)
+	if h > w {
+		tw, th = max(1, w*size/h), size
+	}
+
+	// Converting first takes the fast paths of draw for the common source
+	// types, rather than a call to At for every pixel.
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := range sum {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := range sum {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&out, dst)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), contentType, nil
+}
@@ -0,0 +1,68 @@
+// Package inbox makes consumers of redelivered messages, such as those of
+// a broker delivering at least once, apply each message exactly once. A
+// message is marked processed in the transaction that applies it, so that
+// either both commit or neither does.
+package inbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrProcessed is returned for a message its consumer processed before.
+var ErrProcessed = errors.New("inbox: message already processed")
+
+// Message is a message a consumer processed. IDs are those of the
+// producer, unique per consumer.
+type Message struct {
+	Consumer    string `gorm:"primarykey"`
+	MessageID   string `gorm:"primarykey"`
+	ProcessedAt time.Time
+}
+
+func (Message) TableName() string {
+	return "processed_messages"
+}
+
+// Handler applies a message in tx.
+type Handler func(tx *gorm.DB) error
+
+type Inbox struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func New(db *gorm.DB) *Inbox {
+	return &Inbox{db: db, now: time.Now}
+}
+
+// Process runs h in a transaction marking the message id of consumer
+// processed, and returns ErrProcessed without running h when it was
+// already. The mark is written first: a concurrent delivery of the same
+// message waits on it, and gets ErrProcessed once the first commits. An
+// error from h rolls the mark back, so that the message is processed
+// again when redelivered.
+func (i *Inbox) Process(ctx context.Context, consumer, id string, h Handler) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		m := &Message{Consumer: consumer, MessageID: id, ProcessedAt: i.now()}
+		if err := tx.Create(m).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.Is(err, gorm.ErrDuplicatedKey) || errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrProcessed
+			}
+			return err
+		}
+		return h(tx)
+	})
+}
+
+// Prune removes the marks of messages processed before t, once they can
+// no longer be redelivered, and returns how many it removed.
+func (i *Inbox) Prune(ctx context.Context, t time.Time) (int64, error) {
+	res := i.db.WithContext(ctx).Where("processed_at < ?", t).Delete(&Message{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,63 @@
+package inbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hello/inbox"
+	mockDB "hello/mock/db"
+	testUtil "hello/util/test"
+)
+
+type row struct {
+	ID    uint
+	Value string
+}
+
+func TestInbox_Process(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t, &inbox.Message{}, &row{})
+
+	in := inbox.New(db)
+	ctx := context.Background()
+	insert := func(value string) inbox.Handler {
+		return func(tx *gorm.DB) error {
+			return tx.Create(&row{Value: value}).Error
+		}
+	}
+	count := func() int64 {
+		var n int64
+		testUtil.NoError(t, db.Model(&row{}).Count(&n).Error)
+		return n
+	}
+
+	testUtil.NoError(t, in.Process(ctx, "catalog_sync", "m1", insert("a")))
+	testUtil.Equal(t, true, errors.Is(in.Process(ctx, "catalog_sync", "m1", insert("a")), inbox.ErrProcessed))
+	testUtil.Equal(t, int64(1), count())
+
+	// IDs are unique per consumer.
+	testUtil.NoError(t, in.Process(ctx, "pricing_sync", "m1", insert("b")))
+	testUtil.Equal(t, int64(2), count())
+
+	// A failed message is rolled back, mark included, and processed again
+	// when redelivered.
+	failure := errors.New("boom")
+	err := in.Process(ctx, "catalog_sync", "m2", func(tx *gorm.DB) error {
+		testUtil.NoError(t, insert("c")(tx))
+		return failure
+	})
+	testUtil.Equal(t, failure, err)
+	testUtil.Equal(t, int64(2), count())
+	testUtil.NoError(t, in.Process(ctx, "catalog_sync", "m2", insert("c")))
+	testUtil.Equal(t, int64(3), count())
+
+	n, err := in.Prune(ctx, time.Now().Add(time.Minute))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, int64(3), n)
+	testUtil.NoError(t, in.Process(ctx, "catalog_sync", "m1", insert("a")))
+}
@@ -0,0 +1,131 @@
+// Package lifecycle tears the server down in order. Subsystems register a
+// hook as they start, and hooks run in the reverse order at shutdown, as
+// deferred calls would: the HTTP server, registered last, stops taking
+// requests first, and the database, registered first, closes last, once
+// every worker that writes to it has flushed.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Hook stops a subsystem. It should return when done, or when ctx is done.
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	name    string
+	timeout time.Duration
+	stop    Hook
+}
+
+// Lifecycle holds the shutdown hooks of the running subsystems.
+type Lifecycle struct {
+	timeout time.Duration
+
+	mu    sync.Mutex
+	hooks []hook
+	done  bool
+	once  sync.Once
+	err   error
+}
+
+// New returns a lifecycle giving hooks registered without a timeout up to
+// timeout each to return.
+func New(timeout time.Duration) *Lifecycle {
+	return &Lifecycle{timeout: timeout}
+}
+
+// Register adds a hook run at shutdown with up to timeout to return, or
+// the lifecycle's default with a zero timeout. Hooks registered after
+// Shutdown started are run at once.
+func (l *Lifecycle) Register(name string, timeout time.Duration, stop Hook) {
+	if timeout <= 0 {
+		timeout = l.timeout
+	}
+	h := hook{name: name, timeout: timeout, stop: stop}
+
+	l.mu.Lock()
+	if !l.done {
+		l.hooks = append(l.hooks, h)
+		l.mu.Unlock()
+		return
+	}
+	l.mu.Unlock()
+	run(context.Background(), h)
+}
+
+// Go runs a background loop such as a flusher or a sweeper, passing it a
+// context canceled at shutdown. Its hook waits for the loop to return, so
+// that a final flush on cancellation completes before the hooks registered
+// earlier, such as the database's, run.
+func (l *Lifecycle) Go(name string, loop func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		loop(ctx)
+	}()
+
+	l.Register(name, 0, func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	})
+}
+
+// Shutdown runs the hooks, the last registered first, each with its
+// timeout, and returns their errors joined. Later calls return the same
+// result without running them again.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	l.once.Do(func() {
+		l.mu.Lock()
+		l.done = true
+		hooks := l.hooks
+		l.hooks = nil
+		l.mu.Unlock()
+
+		var errs []error
+		for i := len(hooks) - 1; i >= 0; i-- {
+			if err := run(ctx, hooks[i]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		l.err = errors.Join(errs...)
+	})
+	return l.err
+}
+
+// run runs a hook, reporting how long it took and whether it failed or
+// timed out. A hook that does not return in time is left running.
+func run(ctx context.Context, h hook) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- h.stop(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		log.Printf("Shutdown %s failed after %s: %s", h.name, time.Since(start).Round(time.Millisecond), err)
+		return fmt.Errorf("%s: %w", h.name, err)
+	}
+	log.Printf("Shutdown %s done in %s", h.name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
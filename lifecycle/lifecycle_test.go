@@ -0,0 +1,57 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hello/lifecycle"
+	testUtil "hello/util/test"
+)
+
+func TestLifecycle(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	lc := lifecycle.New(time.Second)
+	lc.Register("db", 0, func(context.Context) error {
+		record("db")
+		return nil
+	})
+	lc.Go("flusher", func(ctx context.Context) {
+		<-ctx.Done()
+		// The final flush completes before the database closes.
+		time.Sleep(10 * time.Millisecond)
+		record("flusher")
+	})
+	lc.Register("stuck", 20*time.Millisecond, func(ctx context.Context) error {
+		select {}
+	})
+	lc.Register("broken", 0, func(context.Context) error {
+		record("broken")
+		return errors.New("boom")
+	})
+
+	err := lc.Shutdown(context.Background())
+	testUtil.Equal(t, true, errors.Is(err, context.DeadlineExceeded))
+	testUtil.Equal(t, "broken: boom\nstuck: context deadline exceeded", err.Error())
+	testUtil.Equal(t, "broken flusher db", strings.Join(order, " "))
+
+	// Shutdown runs hooks once; later hooks run at once.
+	testUtil.Equal(t, err, lc.Shutdown(context.Background()))
+	lc.Register("late", 0, func(context.Context) error {
+		record("late")
+		return nil
+	})
+	testUtil.Equal(t, "broken flusher db late", strings.Join(order, " "))
+}
@@ -0,0 +1,82 @@
+// Package mail sends transactional email such as verification links.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"hello/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// New returns an SMTP sender when an SMTP address is configured, and one
+// that only logs messages otherwise, for development.
+func New(c *config.ConfMail) Sender {
+	if c.SMTPAddr == "" {
+		return Log{}
+	}
+	return &SMTP{conf: c}
+}
+
+// Log writes messages to the log instead of sending them.
+type Log struct{}
+
+func (Log) Send(_ context.Context, m *Message) error {
+	log.Printf("mail to %s: %s\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// SMTP sends messages through an SMTP relay, authenticating with PLAIN auth
+// when a username is configured.
+type SMTP struct {
+	conf *config.ConfMail
+}
+
+func (s *SMTP) Send(ctx context.Context, m *Message) error {
+	var auth smtp.Auth
+	if s.conf.Username != "" {
+		host, _, _ := strings.Cut(s.conf.SMTPAddr, ":")
+		auth = smtp.PlainAuth("", s.conf.Username, s.conf.Password, host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.conf.SMTPAddr, auth, s.conf.From, []string{m.To}, s.format(m))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *SMTP) format(m *Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.conf.From)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,190 @@
+// Package metadata looks books up by ISBN at an external metadata provider.
+// Lookups go through a Proxy, which caches answers, coalesces concurrent
+// lookups of the same ISBN and keeps within the provider's quota, so that
+// enriching many books does not exhaust it.
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var (
+	ErrInvalidISBN = errors.New("metadata: invalid ISBN")
+	ErrNotFound    = errors.New("metadata: not found")
+	// ErrRateLimited is returned while the provider's quota is exhausted and
+	// no cached answer is available.
+	ErrRateLimited = errors.New("metadata: provider quota exhausted")
+)
+
+// QuotaError is returned by a provider that refused a lookup for exceeding
+// its quota. It matches ErrRateLimited.
+type QuotaError struct {
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("metadata: provider quota exhausted, retry after %s", e.RetryAfter)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Record is what a provider knows about a book.
+type Record struct {
+	ISBN          string   `json:"isbn"`
+	Title         string   `json:"title"`
+	Authors       []string `json:"authors"`
+	PublishedDate string   `json:"published_date"`
+	Publishers    []string `json:"publishers,omitempty"`
+	Pages         int      `json:"pages,omitempty"`
+	CoverURL      string   `json:"cover_url,omitempty"`
+}
+
+// Provider looks a book up by normalized ISBN.
+type Provider interface {
+	Lookup(ctx context.Context, isbn string) (*Record, error)
+}
+
+// NormalizeISBN strips hyphens and spaces from an ISBN-10 or ISBN-13 and
+// checks its length and check digit.
+func NormalizeISBN(isbn string) (string, error) {
+	isbn = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+
+	switch len(isbn) {
+	case 10:
+		sum := 0
+		for i, c := range isbn {
+			var d int
+			switch {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case c == 'X' && i == 9:
+				d = 10
+			default:
+				return "", ErrInvalidISBN
+			}
+			sum += d * (10 - i)
+		}
+		if sum%11 != 0 {
+			return "", ErrInvalidISBN
+		}
+	case 13:
+		sum := 0
+		for i, c := range isbn {
+			if c < '0' || c > '9' {
+				return "", ErrInvalidISBN
+			}
+			if i%2 == 0 {
+				sum += int(c - '0')
+			} else {
+				sum += 3 * int(c-'0')
+			}
+		}
+		if sum%10 != 0 {
+			return "", ErrInvalidISBN
+		}
+	default:
+		return "", ErrInvalidISBN
+	}
+	return isbn, nil
+}
+
+// OpenLibrary queries the Open Library books API, or one compatible with it
+// at another base URL.
+type OpenLibrary struct {
+	url    string
+	client *http.Client
+}
+
+func NewOpenLibrary(baseURL string, timeout time.Duration) *OpenLibrary {
+	return &OpenLibrary{
+		url:    strings.TrimSuffix(baseURL, "/"),
+		client: &http.Client{Timeout: timeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+func (ol *OpenLibrary) Lookup(ctx context.Context, isbn string) (*Record, error) {
+	key := "ISBN:" + isbn
+	q := url.Values{"bibkeys": {key}, "format": {"json"}, "jscmd": {"data"}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ol.url+"/api/books?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := ol.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return nil, &QuotaError{RetryAfter: retryAfter(res.Header.Get("Retry-After"))}
+	default:
+		return nil, fmt.Errorf("metadata: provider: %s", res.Status)
+	}
+
+	var books map[string]struct {
+		Title         string `json:"title"`
+		PublishDate   string `json:"publish_date"`
+		NumberOfPages int    `json:"number_of_pages"`
+		Authors       []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		Publishers []struct {
+			Name string `json:"name"`
+		} `json:"publishers"`
+		Cover struct {
+			Large string `json:"large"`
+		} `json:"cover"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&books); err != nil {
+		return nil, fmt.Errorf("metadata: provider: %w", err)
+	}
+
+	b, ok := books[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	rec := &Record{
+		ISBN:          isbn,
+		Title:         b.Title,
+		PublishedDate: b.PublishDate,
+		Pages:         b.NumberOfPages,
+		CoverURL:      b.Cover.Large,
+	}
+	for _, a := range b.Authors {
+		rec.Authors = append(rec.Authors, a.Name)
+	}
+	for _, p := range b.Publishers {
+		rec.Publishers = append(rec.Publishers, p.Name)
+	}
+	return rec, nil
+}
+
+// retryAfter reads a Retry-After header given in seconds or as a date,
+// defaulting to a minute.
+func retryAfter(v string) time.Duration {
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && time.Until(t) > 0 {
+		return time.Until(t)
+	}
+	return time.Minute
+}
@@ -0,0 +1,197 @@
+package metadata_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hello/config"
+	"hello/metadata"
+	testUtil "hello/util/test"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"978-0-441-17271-9", "9780441172719", nil},
+		{"0 441 17271 7", "0441172717", nil},
+		{"080442957x", "080442957X", nil},
+		{"9780441172710", "", metadata.ErrInvalidISBN},
+		{"0441172718", "", metadata.ErrInvalidISBN},
+		{"12345", "", metadata.ErrInvalidISBN},
+		{"X441172717", "", metadata.ErrInvalidISBN},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := metadata.NormalizeISBN(tc.in)
+			testUtil.Equal(t, errors.Is(err, tc.err) || err == tc.err, true)
+			testUtil.Equal(t, got, tc.want)
+		})
+	}
+}
+
+func TestOpenLibrary_Lookup(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("bibkeys") {
+		case "ISBN:9780441172719":
+			w.Write([]byte(`{"ISBN:9780441172719": {"title": "Dune", "publish_date": "1990", "number_of_pages": 535,
+				"authors": [{"name": "Frank Herbert"}], "publishers": [{"name": "Ace"}], "cover": {"large": "https://covers/l.jpg"}}}`))
+		case "ISBN:0441172717":
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	ol := metadata.NewOpenLibrary(srv.URL+"/", time.Second)
+
+	rec, err := ol.Lookup(context.Background(), "9780441172719")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, rec.Title, "Dune")
+	testUtil.Equal(t, rec.Authors[0], "Frank Herbert")
+	testUtil.Equal(t, rec.Publishers[0], "Ace")
+	testUtil.Equal(t, rec.Pages, 535)
+	testUtil.Equal(t, rec.CoverURL, "https://covers/l.jpg")
+
+	_, err = ol.Lookup(context.Background(), "9780307277671")
+	testUtil.Equal(t, err, metadata.ErrNotFound)
+
+	_, err = ol.Lookup(context.Background(), "0441172717")
+	var quotaErr *metadata.QuotaError
+	testUtil.Equal(t, errors.As(err, &quotaErr), true)
+	testUtil.Equal(t, quotaErr.RetryAfter, 30*time.Second)
+	testUtil.Equal(t, errors.Is(err, metadata.ErrRateLimited), true)
+}
+
+type fakeProvider struct {
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (p *fakeProvider) Lookup(_ context.Context, isbn string) (*metadata.Record, error) {
+	p.calls.Add(1)
+	if p.release != nil {
+		<-p.release
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if isbn == "9780307277671" {
+		return nil, metadata.ErrNotFound
+	}
+	return &metadata.Record{ISBN: isbn, Title: "Dune"}, nil
+}
+
+func newConf() *config.ConfMetadata {
+	return &config.ConfMetadata{
+		CacheTTL:      time.Hour,
+		NotFoundTTL:   time.Hour,
+		CacheSize:     10,
+		QuotaRequests: 100,
+		QuotaWindow:   time.Minute,
+	}
+}
+
+func TestProxy_Coalesces(t *testing.T) {
+	t.Parallel()
+
+	provider := &fakeProvider{release: make(chan struct{})}
+	proxy := metadata.NewProxy(provider, newConf())
+
+	const n = 5
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec, err := proxy.Lookup(context.Background(), "978-0-441-17271-9")
+			testUtil.NoError(t, err)
+			testUtil.Equal(t, rec.Title, "Dune")
+		}()
+	}
+
+	for provider.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(provider.release)
+	wg.Wait()
+
+	testUtil.Equal(t, provider.calls.Load() < n, true)
+
+	// Later lookups, in either form of the ISBN, are served from the cache.
+	calls := provider.calls.Load()
+	_, err := proxy.Lookup(context.Background(), "9780441172719")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, provider.calls.Load(), calls)
+}
+
+func TestProxy_CachesNotFound(t *testing.T) {
+	t.Parallel()
+
+	provider := &fakeProvider{}
+	proxy := metadata.NewProxy(provider, newConf())
+
+	for range 3 {
+		_, err := proxy.Lookup(context.Background(), "9780307277671")
+		testUtil.Equal(t, err, metadata.ErrNotFound)
+	}
+	testUtil.Equal(t, provider.calls.Load(), int32(1))
+}
+
+func TestProxy_Quota(t *testing.T) {
+	t.Parallel()
+
+	c := newConf()
+	c.QuotaRequests = 1
+	provider := &fakeProvider{}
+	proxy := metadata.NewProxy(provider, c)
+
+	_, err := proxy.Lookup(context.Background(), "9780441172719")
+	testUtil.NoError(t, err)
+
+	_, err = proxy.Lookup(context.Background(), "0441172717")
+	testUtil.Equal(t, err, metadata.ErrRateLimited)
+	testUtil.Equal(t, proxy.RetryAfter() > 0, true)
+	testUtil.Equal(t, provider.calls.Load(), int32(1))
+}
+
+func TestProxy_ServesStale(t *testing.T) {
+	t.Parallel()
+
+	c := newConf()
+	c.CacheTTL = time.Nanosecond
+	provider := &fakeProvider{}
+	proxy := metadata.NewProxy(provider, c)
+
+	_, err := proxy.Lookup(context.Background(), "9780441172719")
+	testUtil.NoError(t, err)
+
+	// The provider now refuses lookups; the expired answer is served instead,
+	// and no further calls are made until the quota resets.
+	provider.err = &metadata.QuotaError{RetryAfter: time.Minute}
+	for range 2 {
+		rec, err := proxy.Lookup(context.Background(), "9780441172719")
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, rec.Title, "Dune")
+	}
+	testUtil.Equal(t, provider.calls.Load(), int32(2))
+
+	_, err = proxy.Lookup(context.Background(), "0441172717")
+	testUtil.Equal(t, err, metadata.ErrRateLimited)
+}
@@ -0,0 +1,180 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"hello/config"
+)
+
+// Proxy sits in front of a Provider. Answers, including "not found", are
+// cached; concurrent lookups of an ISBN share one provider call; and calls
+// are limited to quota per window. While the quota is exhausted, or the
+// provider fails, expired answers are served rather than an error.
+type Proxy struct {
+	provider    Provider
+	ttl         time.Duration
+	notFoundTTL time.Duration
+	maxEntries  int
+	quota       int
+	window      time.Duration
+
+	group singleflight.Group
+
+	mu          sync.Mutex
+	entries     map[string]*entry
+	calls       int
+	windowEnd   time.Time
+	blockedTill time.Time
+}
+
+type entry struct {
+	record  *Record
+	expires time.Time
+}
+
+func NewProxy(provider Provider, c *config.ConfMetadata) *Proxy {
+	return &Proxy{
+		provider:    provider,
+		ttl:         c.CacheTTL,
+		notFoundTTL: c.NotFoundTTL,
+		maxEntries:  c.CacheSize,
+		quota:       c.QuotaRequests,
+		window:      c.QuotaWindow,
+		entries:     make(map[string]*entry),
+	}
+}
+
+// Lookup returns the record of isbn, from the cache when fresh.
+func (p *Proxy) Lookup(ctx context.Context, isbn string) (*Record, error) {
+	isbn, err := NormalizeISBN(isbn)
+	if err != nil {
+		return nil, err
+	}
+
+	if e, ok := p.cached(isbn); ok && time.Now().Before(e.expires) {
+		return e.found()
+	}
+
+	v, err, _ := p.group.Do(isbn, func() (any, error) {
+		// The first caller may go away; the shared lookup must still
+		// complete for everyone else waiting on it.
+		return p.fetch(context.WithoutCancel(ctx), isbn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Record), nil
+}
+
+// RetryAfter reports how long the provider's quota stays exhausted.
+func (p *Proxy) RetryAfter() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	switch {
+	case now.Before(p.blockedTill):
+		return p.blockedTill.Sub(now)
+	case p.calls >= p.quota && now.Before(p.windowEnd):
+		return p.windowEnd.Sub(now)
+	}
+	return 0
+}
+
+func (p *Proxy) fetch(ctx context.Context, isbn string) (*Record, error) {
+	stale, hasStale := p.cached(isbn)
+	fallback := func(err error) (*Record, error) {
+		if hasStale {
+			return stale.found()
+		}
+		return nil, err
+	}
+
+	if !p.allow() {
+		return fallback(ErrRateLimited)
+	}
+
+	rec, err := p.provider.Lookup(ctx, isbn)
+	var quotaErr *QuotaError
+	switch {
+	case errors.As(err, &quotaErr):
+		p.block(quotaErr.RetryAfter)
+		return fallback(ErrRateLimited)
+	case errors.Is(err, ErrNotFound):
+		p.store(isbn, nil, p.notFoundTTL)
+		return nil, ErrNotFound
+	case err != nil:
+		log.Printf("metadata lookup of %s: %s", isbn, err)
+		return fallback(err)
+	}
+
+	p.store(isbn, rec, p.ttl)
+	return rec, nil
+}
+
+func (e *entry) found() (*Record, error) {
+	if e.record == nil {
+		return nil, ErrNotFound
+	}
+	return e.record, nil
+}
+
+func (p *Proxy) cached(isbn string) (*entry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.entries[isbn]
+	return e, ok
+}
+
+// store caches an answer, evicting the entry closest to expiry when the
+// cache is full. A cache size of zero leaves the cache unbounded.
+func (p *Proxy) store(isbn string, rec *Record, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.entries[isbn]; !ok && p.maxEntries > 0 && len(p.entries) >= p.maxEntries {
+		var oldest string
+		for k, e := range p.entries {
+			if oldest == "" || e.expires.Before(p.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		delete(p.entries, oldest)
+	}
+	p.entries[isbn] = &entry{record: rec, expires: time.Now().Add(ttl)}
+}
+
+// allow counts a provider call against the quota of the current window.
+func (p *Proxy) allow() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(p.blockedTill) {
+		return false
+	}
+	if !now.Before(p.windowEnd) {
+		p.calls, p.windowEnd = 0, now.Add(p.window)
+	}
+	if p.calls >= p.quota {
+		return false
+	}
+	p.calls++
+	return true
+}
+
+// block stops provider calls for d after the provider refused one.
+func (p *Proxy) block(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.blockedTill = time.Now().Add(d)
+	log.Printf("metadata provider quota exhausted; pausing lookups for %s", d)
+}
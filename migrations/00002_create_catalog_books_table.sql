@@ -0,0 +1,24 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS catalog_books
+(
+    id             UUID PRIMARY KEY,
+    title          TEXT      NOT NULL,
+    author         TEXT      NOT NULL,
+    published_date DATE      NOT NULL,
+    image_url      TEXT      NULL,
+    description    TEXT      NULL,
+    updated_at     TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS catalog_books_title_idx ON catalog_books (title);
+
+INSERT INTO catalog_books (id, title, author, published_date, image_url, description, updated_at)
+SELECT id, title, author, published_date, image_url, description, updated_at
+FROM books
+WHERE deleted_at IS NULL
+ON CONFLICT (id) DO NOTHING;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS catalog_books;
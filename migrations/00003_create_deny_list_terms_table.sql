@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS deny_list_terms
+(
+    term       TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS deny_list_terms;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS deprecation_usages
+(
+    day          DATE      NOT NULL,
+    item         TEXT      NOT NULL,
+    client       TEXT      NOT NULL,
+    count        BIGINT    NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (day, item, client)
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS deprecation_usages;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS books_custom_fields_idx ON books USING GIN (custom_fields jsonb_path_ops);
+
+CREATE TABLE IF NOT EXISTS custom_field_definitions
+(
+    id         UUID         NOT NULL,
+    tenant_id  VARCHAR(64)  NOT NULL,
+    name       VARCHAR(64)  NOT NULL,
+    type       VARCHAR(16)  NOT NULL,
+    required   BOOLEAN      NOT NULL DEFAULT FALSE,
+    indexed    BOOLEAN      NOT NULL DEFAULT FALSE,
+    enum       JSONB,
+    min        DOUBLE PRECISION,
+    max        DOUBLE PRECISION,
+    created_at TIMESTAMP    NOT NULL,
+    updated_at TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (tenant_id, name)
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS custom_field_definitions;
+DROP INDEX IF EXISTS books_custom_fields_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS custom_fields;
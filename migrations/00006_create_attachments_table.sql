@@ -0,0 +1,18 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS attachments
+(
+    id           UUID         NOT NULL,
+    book_id      UUID         NOT NULL REFERENCES books (id),
+    filename     VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size         BIGINT       NOT NULL,
+    storage_key  TEXT         NOT NULL,
+    created_at   TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS attachments_book_id_idx ON attachments (book_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS attachments;
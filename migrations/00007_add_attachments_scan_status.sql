@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'pending';
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS scan_signature TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS attachments_pending_idx ON attachments (created_at) WHERE status = 'pending';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP INDEX IF EXISTS attachments_pending_idx;
+ALTER TABLE attachments DROP COLUMN IF EXISTS scan_signature;
+ALTER TABLE attachments DROP COLUMN IF EXISTS status;
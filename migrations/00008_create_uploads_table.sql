@@ -0,0 +1,20 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS uploads
+(
+    id           UUID         NOT NULL,
+    book_id      UUID         NOT NULL REFERENCES books (id),
+    filename     VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    size         BIGINT       NOT NULL,
+    received     BIGINT       NOT NULL DEFAULT 0,
+    chunks       INTEGER      NOT NULL DEFAULT 0,
+    expires_at   TIMESTAMP    NOT NULL,
+    created_at   TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS uploads_expires_at_idx ON uploads (expires_at);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS uploads;
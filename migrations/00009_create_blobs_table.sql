@@ -0,0 +1,19 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS blobs
+(
+    hash       CHAR(64)  NOT NULL,
+    size       BIGINT    NOT NULL,
+    ref_count  BIGINT    NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (hash)
+);
+CREATE INDEX IF NOT EXISTS blobs_unreferenced_idx ON blobs (hash) WHERE ref_count = 0;
+
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS blob_hash CHAR(64) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE attachments DROP COLUMN IF EXISTS blob_hash;
+DROP TABLE IF EXISTS blobs;
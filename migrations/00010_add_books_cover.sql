@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_hash CHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_type VARCHAR(32) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE books DROP COLUMN IF EXISTS cover_type;
+ALTER TABLE books DROP COLUMN IF EXISTS cover_hash;
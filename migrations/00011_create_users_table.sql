@@ -0,0 +1,28 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS users
+(
+    id                UUID         NOT NULL,
+    email             VARCHAR(254) NOT NULL,
+    password_hash     VARCHAR(255) NOT NULL,
+    email_verified_at TIMESTAMP,
+    created_at        TIMESTAMP    NOT NULL,
+    updated_at        TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens
+(
+    token_hash CHAR(64)  NOT NULL,
+    user_id    UUID      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (token_hash)
+);
+CREATE INDEX IF NOT EXISTS email_verification_tokens_user_id_idx ON email_verification_tokens (user_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS email_verification_tokens;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS invitations
+(
+    id          UUID         NOT NULL,
+    tenant_id   VARCHAR(63)  NOT NULL,
+    email       VARCHAR(254) NOT NULL,
+    role        VARCHAR(32)  NOT NULL,
+    token_hash  CHAR(64)     NOT NULL,
+    invited_by  UUID,
+    expires_at  TIMESTAMP    NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    created_at  TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (token_hash)
+);
+CREATE INDEX IF NOT EXISTS invitations_tenant_id_idx ON invitations (tenant_id);
+
+CREATE TABLE IF NOT EXISTS memberships
+(
+    user_id    UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    tenant_id  VARCHAR(63) NOT NULL,
+    role       VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP   NOT NULL,
+    PRIMARY KEY (user_id, tenant_id)
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS invitations;
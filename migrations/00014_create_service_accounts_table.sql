@@ -0,0 +1,30 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS service_accounts
+(
+    id         UUID          NOT NULL,
+    tenant_id  VARCHAR(63)   NOT NULL,
+    name       VARCHAR(255)  NOT NULL,
+    scopes     VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at TIMESTAMP     NOT NULL,
+    updated_at TIMESTAMP     NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (tenant_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS service_account_secrets
+(
+    id                 UUID      NOT NULL,
+    service_account_id UUID      NOT NULL REFERENCES service_accounts (id) ON DELETE CASCADE,
+    secret_hash        CHAR(64)  NOT NULL,
+    expires_at         TIMESTAMP,
+    last_used_at       TIMESTAMP,
+    created_at         TIMESTAMP NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS service_account_secrets_service_account_id_idx ON service_account_secrets (service_account_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS service_account_secrets;
+DROP TABLE IF EXISTS service_accounts;
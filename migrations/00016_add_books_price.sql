@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS price_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS price_currency CHAR(3) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS books_price_idx ON books (price_currency, price_amount);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP INDEX IF EXISTS books_price_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS price_currency;
+ALTER TABLE books DROP COLUMN IF EXISTS price_amount;
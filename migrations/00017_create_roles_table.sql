@@ -0,0 +1,27 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS roles
+(
+    name        VARCHAR(32)  NOT NULL,
+    rank        INTEGER      NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    PRIMARY KEY (name)
+);
+INSERT INTO roles (name, rank, description)
+VALUES ('viewer', 10, 'Reads books'),
+       ('editor', 20, 'Creates and updates books'),
+       ('admin', 30, 'Deletes books and manages the tenant')
+ON CONFLICT (name) DO NOTHING;
+
+UPDATE memberships SET role = 'editor' WHERE role = 'member';
+UPDATE invitations SET role = 'editor' WHERE role = 'member';
+ALTER TABLE memberships ADD CONSTRAINT memberships_role_fkey FOREIGN KEY (role) REFERENCES roles (name);
+ALTER TABLE invitations ADD CONSTRAINT invitations_role_fkey FOREIGN KEY (role) REFERENCES roles (name);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE invitations DROP CONSTRAINT IF EXISTS invitations_role_fkey;
+ALTER TABLE memberships DROP CONSTRAINT IF EXISTS memberships_role_fkey;
+UPDATE invitations SET role = 'member' WHERE role = 'editor';
+UPDATE memberships SET role = 'member' WHERE role = 'editor';
+DROP TABLE IF EXISTS roles;
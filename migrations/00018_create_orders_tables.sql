@@ -0,0 +1,51 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS stock_levels
+(
+    book_id    UUID      NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    available  INTEGER   NOT NULL CHECK (available >= 0),
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (book_id)
+);
+
+CREATE TABLE IF NOT EXISTS cart_items
+(
+    user_id    UUID      NOT NULL,
+    book_id    UUID      NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    quantity   INTEGER   NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, book_id)
+);
+
+CREATE TABLE IF NOT EXISTS orders
+(
+    id             UUID        NOT NULL,
+    user_id        UUID        NOT NULL,
+    status         VARCHAR(16) NOT NULL,
+    total_amount   BIGINT      NOT NULL,
+    total_currency CHAR(3)     NOT NULL,
+    created_at     TIMESTAMP   NOT NULL,
+    updated_at     TIMESTAMP   NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS orders_user_id_created_at_idx ON orders (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS order_items
+(
+    order_id      UUID         NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    book_id       UUID         NOT NULL,
+    title         VARCHAR(255) NOT NULL,
+    quantity      INTEGER      NOT NULL,
+    unit_amount   BIGINT       NOT NULL,
+    unit_currency CHAR(3)      NOT NULL,
+    PRIMARY KEY (order_id, book_id)
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS order_items;
+DROP INDEX IF EXISTS orders_user_id_created_at_idx;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS cart_items;
+DROP TABLE IF EXISTS stock_levels;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS payment_events
+(
+    id          VARCHAR(255) NOT NULL,
+    type        VARCHAR(255) NOT NULL,
+    order_id    UUID REFERENCES orders (id) ON DELETE SET NULL,
+    livemode    BOOLEAN      NOT NULL,
+    outcome     VARCHAR(32)  NOT NULL,
+    payload     JSONB        NOT NULL,
+    received_at TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS payment_events_order_id_idx ON payment_events (order_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP INDEX IF EXISTS payment_events_order_id_idx;
+DROP TABLE IF EXISTS payment_events;
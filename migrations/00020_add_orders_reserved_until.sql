@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS reserved_until TIMESTAMP;
+CREATE INDEX IF NOT EXISTS orders_reserved_until_idx ON orders (reserved_until) WHERE status = 'pending';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP INDEX IF EXISTS orders_reserved_until_idx;
+ALTER TABLE orders DROP COLUMN IF EXISTS reserved_until;
@@ -0,0 +1,45 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS copies
+(
+    id          UUID      NOT NULL,
+    book_id     UUID      NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    owner_id    UUID      NOT NULL,
+    order_id    UUID      REFERENCES orders (id) ON DELETE SET NULL,
+    acquired_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS copies_owner_id_idx ON copies (owner_id);
+
+CREATE TABLE IF NOT EXISTS transfers
+(
+    id           UUID        NOT NULL,
+    copy_id      UUID        NOT NULL REFERENCES copies (id) ON DELETE CASCADE,
+    from_user_id UUID        NOT NULL,
+    to_user_id   UUID        NOT NULL,
+    status       VARCHAR(16) NOT NULL,
+    note         TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMP   NOT NULL,
+    responded_at TIMESTAMP,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS transfers_copy_id_pending_idx ON transfers (copy_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS transfers_from_user_id_idx ON transfers (from_user_id);
+CREATE INDEX IF NOT EXISTS transfers_to_user_id_idx ON transfers (to_user_id);
+
+CREATE TABLE IF NOT EXISTS transfer_events
+(
+    id          UUID        NOT NULL,
+    transfer_id UUID        NOT NULL REFERENCES transfers (id) ON DELETE CASCADE,
+    actor_id    UUID        NOT NULL,
+    action      VARCHAR(16) NOT NULL,
+    at          TIMESTAMP   NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS transfer_events_transfer_id_idx ON transfer_events (transfer_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS transfer_events;
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS copies;
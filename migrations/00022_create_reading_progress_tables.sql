@@ -0,0 +1,31 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS reading_progress
+(
+    user_id     UUID         NOT NULL,
+    book_id     UUID         NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    page        INTEGER,
+    percent     NUMERIC(5, 2),
+    device      VARCHAR(64)  NOT NULL,
+    recorded_at TIMESTAMP    NOT NULL,
+    updated_at  TIMESTAMP    NOT NULL,
+    PRIMARY KEY (user_id, book_id)
+);
+
+CREATE TABLE IF NOT EXISTS reading_progress_history
+(
+    user_id     UUID         NOT NULL,
+    book_id     UUID         NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    device      VARCHAR(64)  NOT NULL,
+    recorded_at TIMESTAMP    NOT NULL,
+    page        INTEGER,
+    percent     NUMERIC(5, 2),
+    created_at  TIMESTAMP    NOT NULL,
+    PRIMARY KEY (user_id, book_id, device, recorded_at)
+);
+CREATE INDEX IF NOT EXISTS reading_progress_history_user_id_recorded_at_idx ON reading_progress_history (user_id, recorded_at);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS reading_progress_history;
+DROP TABLE IF EXISTS reading_progress;
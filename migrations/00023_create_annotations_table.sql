@@ -0,0 +1,22 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS annotations
+(
+    id         UUID         NOT NULL,
+    book_id    UUID         NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    user_id    UUID         NOT NULL,
+    position   VARCHAR(255) NOT NULL,
+    text       TEXT         NOT NULL DEFAULT '',
+    note       TEXT         NOT NULL DEFAULT '',
+    color      VARCHAR(16)  NOT NULL,
+    shared     BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP    NOT NULL,
+    updated_at TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS annotations_book_id_user_id_idx ON annotations (book_id, user_id);
+CREATE INDEX IF NOT EXISTS annotations_book_id_shared_idx ON annotations (book_id) WHERE shared;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS annotations;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS interaction_events
+(
+    id          UUID         NOT NULL,
+    type        VARCHAR(16)  NOT NULL,
+    user_id     UUID,
+    session_id  VARCHAR(64)  NOT NULL,
+    book_id     UUID,
+    query       VARCHAR(255) NOT NULL DEFAULT '',
+    order_id    UUID,
+    occurred_at TIMESTAMP    NOT NULL,
+    received_at TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS interaction_events_occurred_at_idx ON interaction_events (occurred_at);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS interaction_events;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS experiments
+(
+    key        VARCHAR(63) NOT NULL,
+    variants   JSONB       NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP   NOT NULL,
+    updated_at TIMESTAMP   NOT NULL,
+    PRIMARY KEY (key)
+);
+
+ALTER TABLE interaction_events ADD COLUMN IF NOT EXISTS experiment VARCHAR(63) NOT NULL DEFAULT '';
+ALTER TABLE interaction_events ADD COLUMN IF NOT EXISTS variant VARCHAR(63) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE interaction_events DROP COLUMN IF EXISTS variant;
+ALTER TABLE interaction_events DROP COLUMN IF EXISTS experiment;
+
+DROP TABLE IF EXISTS experiments;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id          UUID         NOT NULL,
+    action      VARCHAR(64)  NOT NULL,
+    tenant_id   VARCHAR(64)  NOT NULL DEFAULT '',
+    actor_type  VARCHAR(16)  NOT NULL,
+    actor_id    VARCHAR(64)  NOT NULL DEFAULT '',
+    target      VARCHAR(255) NOT NULL DEFAULT '',
+    ip          VARCHAR(64)  NOT NULL DEFAULT '',
+    request_id  VARCHAR(128) NOT NULL DEFAULT '',
+    details     JSONB        NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS audit_log_occurred_at_idx ON audit_log (occurred_at);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_type, actor_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS legal_holds
+(
+    kind      VARCHAR(16) NOT NULL,
+    target_id UUID        NOT NULL,
+    reason    TEXT        NOT NULL,
+    placed_by VARCHAR(64) NOT NULL DEFAULT '',
+    placed_at TIMESTAMP   NOT NULL,
+    PRIMARY KEY (kind, target_id)
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS legal_holds;
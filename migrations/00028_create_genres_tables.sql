@@ -0,0 +1,25 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS genres
+(
+    id         UUID         NOT NULL,
+    slug       VARCHAR(63)  NOT NULL,
+    name       VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP    NOT NULL,
+    updated_at TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (slug)
+);
+
+CREATE TABLE IF NOT EXISTS book_genres
+(
+    book_id  UUID NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    genre_id UUID NOT NULL REFERENCES genres (id) ON DELETE CASCADE,
+    PRIMARY KEY (book_id, genre_id)
+);
+CREATE INDEX IF NOT EXISTS book_genres_genre_id_idx ON book_genres (genre_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS book_genres;
+DROP TABLE IF EXISTS genres;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS book_redirects
+(
+    from_id   UUID      NOT NULL,
+    to_id     UUID      NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    merged_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (from_id)
+);
+CREATE INDEX IF NOT EXISTS book_redirects_to_id_idx ON book_redirects (to_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS book_redirects;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    caller       VARCHAR(255) NOT NULL,
+    key          VARCHAR(255) NOT NULL,
+    request_hash CHAR(64)     NOT NULL,
+    status       INTEGER      NOT NULL DEFAULT 0,
+    header       BYTEA,
+    body         BYTEA,
+    created_at   TIMESTAMP    NOT NULL,
+    expires_at   TIMESTAMP    NOT NULL,
+    PRIMARY KEY (caller, key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id           UUID          NOT NULL,
+    tenant_id    VARCHAR(63)   NOT NULL,
+    name         VARCHAR(255)  NOT NULL,
+    prefix       VARCHAR(16)   NOT NULL,
+    key_hash     CHAR(64)      NOT NULL,
+    scopes       VARCHAR(1024) NOT NULL DEFAULT '',
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45)   NOT NULL DEFAULT '',
+    expires_at   TIMESTAMP,
+    revoked_at   TIMESTAMP,
+    created_at   TIMESTAMP     NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (key_hash)
+);
+CREATE INDEX IF NOT EXISTS api_keys_tenant_id_idx ON api_keys (tenant_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS book_revisions
+(
+    id          BIGSERIAL NOT NULL,
+    book_id     UUID      NOT NULL,
+    snapshot    JSONB,
+    deleted     BOOLEAN   NOT NULL DEFAULT FALSE,
+    recorded_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS book_revisions_book_id_recorded_at_idx ON book_revisions (book_id, recorded_at);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS book_revisions;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS reviews
+(
+    id         UUID         NOT NULL,
+    book_id    UUID         NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    user_id    UUID         NOT NULL,
+    rating     SMALLINT     NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    title      VARCHAR(255) NOT NULL DEFAULT '',
+    body       TEXT         NOT NULL DEFAULT '',
+    created_at TIMESTAMP    NOT NULL,
+    updated_at TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (book_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS reviews_book_id_created_at_idx ON reviews (book_id, created_at DESC);
+
+-- The totals of a book's reviews, kept by the review API in the transaction
+-- writing the review, so that book reads need no join.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS review_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS rating_sum BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE books DROP COLUMN IF EXISTS rating_sum;
+ALTER TABLE books DROP COLUMN IF EXISTS review_count;
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS loans
+(
+    id             UUID      NOT NULL,
+    book_id        UUID      NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    user_id        UUID      NOT NULL,
+    checked_out_at TIMESTAMP NOT NULL,
+    due_at         TIMESTAMP NOT NULL,
+    returned_at    TIMESTAMP,
+    created_at     TIMESTAMP NOT NULL,
+    updated_at     TIMESTAMP NOT NULL,
+    PRIMARY KEY (id)
+);
+-- A book is lent to one borrower at a time. The loan API checks it before
+-- checking a book out; the index settles concurrent checkouts.
+CREATE UNIQUE INDEX IF NOT EXISTS loans_book_id_active_idx ON loans (book_id) WHERE returned_at IS NULL;
+CREATE INDEX IF NOT EXISTS loans_due_at_active_idx ON loans (due_at) WHERE returned_at IS NULL;
+CREATE INDEX IF NOT EXISTS loans_user_id_idx ON loans (user_id, checked_out_at DESC);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS loans;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS dead_letters
+(
+    id           UUID         NOT NULL,
+    event        VARCHAR(100) NOT NULL,
+    handler      VARCHAR(255) NOT NULL,
+    aggregate_id VARCHAR(255) NOT NULL,
+    occurred_at  TIMESTAMP    NOT NULL,
+    payload      JSONB        NOT NULL,
+    error        TEXT         NOT NULL,
+    attempts     INTEGER      NOT NULL,
+    created_at   TIMESTAMP    NOT NULL,
+    updated_at   TIMESTAMP    NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS dead_letters_created_at_idx ON dead_letters (created_at);
+CREATE INDEX IF NOT EXISTS dead_letters_event_idx ON dead_letters (event, handler);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS dead_letters;
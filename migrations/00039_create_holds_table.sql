@@ -0,0 +1,26 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE IF NOT EXISTS holds
+(
+    id         UUID        NOT NULL,
+    book_id    UUID        NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    user_id    UUID        NOT NULL,
+    status     VARCHAR(20) NOT NULL,
+    placed_at  TIMESTAMP   NOT NULL,
+    ready_at   TIMESTAMP,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP   NOT NULL,
+    updated_at TIMESTAMP   NOT NULL,
+    PRIMARY KEY (id)
+);
+-- A reader holds a book once, and a returned book is held for one reader
+-- at a time, the first in line.
+CREATE UNIQUE INDEX IF NOT EXISTS holds_book_id_user_id_idx ON holds (book_id, user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS holds_book_id_ready_idx ON holds (book_id) WHERE status = 'ready';
+CREATE INDEX IF NOT EXISTS holds_book_id_waiting_idx ON holds (book_id, placed_at) WHERE status = 'waiting';
+CREATE INDEX IF NOT EXISTS holds_expires_at_ready_idx ON holds (expires_at) WHERE status = 'ready';
+CREATE INDEX IF NOT EXISTS holds_user_id_idx ON holds (user_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS holds;
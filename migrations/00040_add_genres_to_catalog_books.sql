@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE catalog_books ADD COLUMN IF NOT EXISTS genres TEXT NOT NULL DEFAULT '';
+
+UPDATE catalog_books
+SET genres = tagged.slugs
+FROM (SELECT book_genres.book_id, string_agg(genres.slug, ',' ORDER BY genres.slug) AS slugs
+      FROM book_genres
+               JOIN genres ON genres.id = book_genres.genre_id
+      GROUP BY book_genres.book_id) AS tagged
+WHERE tagged.book_id = catalog_books.id;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE catalog_books DROP COLUMN IF EXISTS genres;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE dead_letters ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'event';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE dead_letters DROP COLUMN IF EXISTS kind;
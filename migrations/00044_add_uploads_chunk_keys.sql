@@ -0,0 +1,7 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS chunk_keys TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE uploads DROP COLUMN IF EXISTS chunk_keys;
@@ -0,0 +1,29 @@
+// Package migrations holds the versioned SQL migrations of the database
+// schema, in goose's format: each file is numbered, and has an Up section
+// applying a change and a Down section reverting it. The files are embedded
+// into the binaries, so a deployed binary always carries the schema it was
+// built for.
+//
+// cmd/migrate applies them with goose, which records the applied versions
+// in goose_db_version; deployed databases and the startup drift check rely
+// on that table.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Open returns the migrations in dir, or the embedded ones when dir is
+// empty. Reading them from disk is meant for developing new migrations
+// without rebuilding.
+func Open(dir string) fs.FS {
+	if dir == "" {
+		return FS
+	}
+	return os.DirFS(dir)
+}
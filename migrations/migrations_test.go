@@ -0,0 +1,44 @@
+package migrations_test
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+
+	"hello/migrations"
+)
+
+var migrationFile = regexp.MustCompile(`^(\d{5})_[a-z0-9_]+\.sql$`)
+
+func TestFS(t *testing.T) {
+	entries, err := fs.ReadDir(migrations.FS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			t.Errorf("%s: name does not match NNNNN_description.sql", e.Name())
+			continue
+		}
+		if want := fmt.Sprintf("%05d", i+1); m[1] != want {
+			t.Errorf("%s: version %s, want %s", e.Name(), m[1], want)
+		}
+
+		b, err := fs.ReadFile(migrations.FS, e.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		up := strings.Index(string(b), "-- +goose Up")
+		down := strings.Index(string(b), "-- +goose Down")
+		if up < 0 || down < up {
+			t.Errorf("%s: needs an Up section followed by a Down section", e.Name())
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var sqliteDBs atomic.Int64
+
+// NewSQLite opens an in-memory SQLite database of its own for t, creates
+// the tables of models in it, and closes it when t ends. Errors are
+// translated, so that unique violations are gorm.ErrDuplicatedKey as they
+// are on Postgres.
+func NewSQLite(t testing.TB, models ...any) *gorm.DB {
+	t.Helper()
+
+	// Connections share the database by name, so each one gets a new name.
+	dsn := fmt.Sprintf("file:sqlite%d?mode=memory&cache=shared", sqliteDBs.Add(1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:         gormlogger.Default.LogMode(gormlogger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %s", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open sqlite: %s", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate sqlite: %s", err)
+	}
+	return db
+}
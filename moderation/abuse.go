@@ -0,0 +1,268 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"hello/config"
+)
+
+// Submission is a piece of user-generated content about to be stored.
+type Submission struct {
+	AuthorID string
+	IP       string
+	Text     string
+	At       time.Time
+}
+
+// Scorer rates how likely a submission is spam or abuse, from 0 (clean) to 1.
+type Scorer interface {
+	Score(ctx context.Context, s Submission) (float64, error)
+}
+
+type ScorerFunc func(ctx context.Context, s Submission) (float64, error)
+
+func (f ScorerFunc) Score(ctx context.Context, s Submission) (float64, error) {
+	return f(ctx, s)
+}
+
+type Assessment struct {
+	Score   float64
+	Flagged bool
+}
+
+// Assessor combines scorers, taking the highest score, and flags submissions
+// at or above the threshold.
+type Assessor struct {
+	scorers   []Scorer
+	threshold float64
+}
+
+func NewAssessor(threshold float64, scorers ...Scorer) *Assessor {
+	return &Assessor{
+		scorers:   scorers,
+		threshold: threshold,
+	}
+}
+
+// NewAssessorFromConfig wires the built-in rate and heuristic scorers, and the
+// external scorer when a URL is configured.
+func NewAssessorFromConfig(c *config.ConfAbuse) *Assessor {
+	scorers := []Scorer{
+		NewRateScorer(c.RateLimit, c.RateWindow),
+		ScorerFunc(HeuristicScore),
+	}
+	if c.ScorerURL != "" {
+		scorers = append(scorers, NewHTTPScorer(c.ScorerURL, c.ScorerTimeout))
+	}
+
+	return NewAssessor(c.Threshold, scorers...)
+}
+
+// Assess never blocks a submission because a scorer failed; the error is
+// returned alongside the best assessment the other scorers could make.
+func (a *Assessor) Assess(ctx context.Context, s Submission) (Assessment, error) {
+	var (
+		result Assessment
+		errs   []error
+	)
+	for _, sc := range a.scorers {
+		score, err := sc.Score(ctx, s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Score = max(result.Score, score)
+	}
+
+	result.Flagged = result.Score >= a.threshold
+	if len(errs) > 0 {
+		return result, fmt.Errorf("abuse scoring: %v", errs)
+	}
+	return result, nil
+}
+
+// RateScorer scores by how many submissions the same author or IP made within
+// the window: reaching limit scores 1.
+type RateScorer struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	seen    map[string][]time.Time
+	sweepAt time.Time
+}
+
+func NewRateScorer(limit int, window time.Duration) *RateScorer {
+	return &RateScorer{
+		limit:  limit,
+		window: window,
+		seen:   make(map[string][]time.Time),
+	}
+}
+
+func (rs *RateScorer) Score(_ context.Context, s Submission) (float64, error) {
+	if rs.limit <= 0 {
+		return 0, nil
+	}
+
+	at := s.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.sweep(at)
+
+	var worst int
+	for _, key := range []string{"author:" + s.AuthorID, "ip:" + s.IP} {
+		if strings.HasSuffix(key, ":") {
+			continue
+		}
+
+		recent := append(rs.recent(key, at), at)
+		rs.seen[key] = recent
+
+		worst = max(worst, len(recent))
+	}
+
+	return min(1, float64(worst)/float64(rs.limit)), nil
+}
+
+// Len returns the number of authors and IPs with submissions in the window.
+func (rs *RateScorer) Len() int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return len(rs.seen)
+}
+
+// recent drops the submissions of key older than the window before at, and
+// key itself once none are left.
+func (rs *RateScorer) recent(key string, at time.Time) []time.Time {
+	recent := rs.seen[key][:0]
+	for _, t := range rs.seen[key] {
+		if at.Sub(t) < rs.window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		delete(rs.seen, key)
+		return nil
+	}
+	rs.seen[key] = recent
+	return recent
+}
+
+// sweep drops idle authors and IPs at most once per window so that those
+// not seen again do not accumulate.
+func (rs *RateScorer) sweep(at time.Time) {
+	if at.Before(rs.sweepAt) {
+		return
+	}
+	for key := range rs.seen {
+		rs.recent(key, at)
+	}
+	rs.sweepAt = at.Add(rs.window)
+}
+
+// HeuristicScore looks for common spam traits: many links, shouting, and long
+// runs of a repeated character.
+func HeuristicScore(_ context.Context, s Submission) (float64, error) {
+	text := s.Text
+	var score float64
+
+	if links := strings.Count(text, "http://") + strings.Count(text, "https://"); links >= 3 {
+		score += 0.5
+	} else if links > 0 {
+		score += 0.2
+	}
+
+	var letters, upper, run, longestRun int
+	var prev rune
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+		if r == prev {
+			run++
+		} else {
+			run = 1
+			prev = r
+		}
+		longestRun = max(longestRun, run)
+	}
+
+	if letters >= 20 && float64(upper)/float64(letters) > 0.7 {
+		score += 0.3
+	}
+	if longestRun >= 8 {
+		score += 0.3
+	}
+
+	return min(1, score), nil
+}
+
+// HTTPScorer delegates to an external service that accepts the submission as
+// JSON and answers {"score": 0.0-1.0}.
+type HTTPScorer struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPScorer(url string, timeout time.Duration) *HTTPScorer {
+	return &HTTPScorer{
+		url:    url,
+		client: &http.Client{Timeout: timeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+func (hs *HTTPScorer) Score(ctx context.Context, s Submission) (float64, error) {
+	body, err := json.Marshal(map[string]string{
+		"author_id": s.AuthorID,
+		"ip":        s.IP,
+		"text":      s.Text,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hs.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("abuse scorer: unexpected status %d", resp.StatusCode)
+	}
+
+	var out struct {
+		Score float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, err
+	}
+
+	return max(0, min(1, out.Score)), nil
+}
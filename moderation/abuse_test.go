@@ -0,0 +1,55 @@
+package moderation_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"hello/moderation"
+	testUtil "hello/util/test"
+)
+
+func TestAssessor_Assess(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a := moderation.NewAssessor(0.8,
+		moderation.NewRateScorer(3, time.Minute),
+		moderation.ScorerFunc(moderation.HeuristicScore),
+	)
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		res, err := a.Assess(ctx, moderation.Submission{AuthorID: "u1", Text: "Lovely read.", At: now})
+		testUtil.NoError(t, err)
+		testUtil.Equal(t, false, res.Flagged)
+	}
+
+	res, err := a.Assess(ctx, moderation.Submission{AuthorID: "u1", Text: "Lovely read.", At: now})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, true, res.Flagged)
+
+	spam := "BUY NOW AT https://a.example https://b.example https://c.example " + strings.Repeat("!", 10)
+	res, err = a.Assess(ctx, moderation.Submission{AuthorID: "u2", Text: spam, At: now})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, true, res.Flagged)
+}
+
+func TestRateScorer_Sweep(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	rs := moderation.NewRateScorer(3, time.Minute)
+
+	now := time.Now()
+	rs.Score(ctx, moderation.Submission{AuthorID: "u1", IP: "192.0.2.1", At: now})
+	rs.Score(ctx, moderation.Submission{AuthorID: "u2", IP: "192.0.2.2", At: now})
+	testUtil.Equal(t, 4, rs.Len())
+
+	// Authors and IPs idle for a window are forgotten.
+	score, err := rs.Score(ctx, moderation.Submission{AuthorID: "u1", At: now.Add(2 * time.Minute)})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1.0/3, score)
+	testUtil.Equal(t, 1, rs.Len())
+}
@@ -0,0 +1,124 @@
+package moderation
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Action is what happens to a submission that matches the deny-list.
+type Action string
+
+const (
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+func (a Action) Valid() bool {
+	return a == ActionReject || a == ActionFlag
+}
+
+// Verdict is the outcome of checking a piece of user-generated content. A
+// clean submission has no Matched terms and an empty Action.
+type Verdict struct {
+	Matched []string
+	Action  Action
+}
+
+func (v Verdict) Clean() bool {
+	return len(v.Matched) == 0
+}
+
+// Filter matches text against a deny-list of words and phrases. Matching is
+// case-insensitive and on whole words, so "ass" does not match "class". It is
+// safe for concurrent use and can be edited at runtime.
+type Filter struct {
+	action Action
+
+	mu    sync.RWMutex
+	terms map[string]struct{}
+}
+
+func NewFilter(action Action, terms ...string) *Filter {
+	f := &Filter{
+		action: action,
+		terms:  make(map[string]struct{}),
+	}
+	f.Add(terms...)
+	return f
+}
+
+func (f *Filter) Action() Action {
+	return f.action
+}
+
+// Add inserts terms and returns them in normalized form; blank terms are
+// dropped.
+func (f *Filter) Add(terms ...string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	added := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if t = Normalize(t); t != "" {
+			f.terms[t] = struct{}{}
+			added = append(added, t)
+		}
+	}
+	return added
+}
+
+func (f *Filter) Remove(term string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	term = Normalize(term)
+	if _, ok := f.terms[term]; !ok {
+		return false
+	}
+	delete(f.terms, term)
+	return true
+}
+
+func (f *Filter) Terms() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	terms := make([]string, 0, len(f.terms))
+	for t := range f.terms {
+		terms = append(terms, t)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+func (f *Filter) Check(text string) Verdict {
+	padded := " " + Normalize(text) + " "
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var v Verdict
+	for t := range f.terms {
+		if strings.Contains(padded, " "+t+" ") {
+			v.Matched = append(v.Matched, t)
+		}
+	}
+	if len(v.Matched) > 0 {
+		sort.Strings(v.Matched)
+		v.Action = f.action
+	}
+	return v
+}
+
+// Normalize lower-cases s and reduces it to single-space separated words of
+// letters and digits.
+func Normalize(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(norm.NFKC.String(s)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
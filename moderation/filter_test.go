@@ -0,0 +1,25 @@
+package moderation_test
+
+import (
+	"testing"
+
+	"hello/moderation"
+	testUtil "hello/util/test"
+)
+
+func TestFilter_Check(t *testing.T) {
+	t.Parallel()
+
+	f := moderation.NewFilter(moderation.ActionFlag, "darn", "Heck  Yes")
+
+	v := f.Check("Well, DARN it!")
+	testUtil.Equal(t, false, v.Clean())
+	testUtil.Equal(t, moderation.ActionFlag, v.Action)
+	testUtil.Equal(t, "darn", v.Matched[0])
+
+	testUtil.Equal(t, true, f.Check("darned good book").Clean())
+	testUtil.Equal(t, false, f.Check("heck, yes.").Clean())
+
+	testUtil.Equal(t, true, f.Remove("DARN"))
+	testUtil.Equal(t, true, f.Check("darn").Clean())
+}
@@ -0,0 +1,63 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+)
+
+// ErrRejected is returned by Moderate for submissions that must not be
+// stored.
+var ErrRejected = errors.New("moderation: submission rejected")
+
+// Moderator checks user-generated content before it is stored. Submissions
+// matching the deny-list are rejected or flagged for moderation, as the
+// filter's action says; the others are flagged when the assessor scores
+// them as spam or abuse.
+type Moderator struct {
+	filter   *Filter
+	assessor *Assessor
+}
+
+// NewModerator returns a moderator; assessor may be nil to only filter.
+func NewModerator(filter *Filter, assessor *Assessor) *Moderator {
+	return &Moderator{
+		filter:   filter,
+		assessor: assessor,
+	}
+}
+
+// Moderate returns ErrRejected for a submission that must not be stored,
+// and otherwise whether to store it flagged for moderation.
+func (m *Moderator) Moderate(ctx context.Context, s Submission) (bool, error) {
+	switch m.filter.Check(s.Text).Action {
+	case ActionReject:
+		return false, ErrRejected
+	case ActionFlag:
+		return true, nil
+	}
+	if m.assessor == nil {
+		return false, nil
+	}
+
+	a, err := m.assessor.Assess(ctx, s)
+	if err != nil {
+		log.Printf("moderation: %s", err)
+	}
+	return a.Flagged, nil
+}
+
+// NewSubmission describes text the author is submitting in r.
+func NewSubmission(r *http.Request, authorID, text string) Submission {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return Submission{
+		AuthorID: authorID,
+		IP:       ip,
+		Text:     text,
+	}
+}
@@ -0,0 +1,35 @@
+package moderation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"hello/moderation"
+	testUtil "hello/util/test"
+)
+
+func TestModerator_Moderate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	failing := moderation.ScorerFunc(func(context.Context, moderation.Submission) (float64, error) {
+		return 0, errors.New("scorer down")
+	})
+	m := moderation.NewModerator(
+		moderation.NewFilter(moderation.ActionReject, "darn"),
+		moderation.NewAssessor(0.8, failing, moderation.ScorerFunc(moderation.HeuristicScore)),
+	)
+
+	_, err := m.Moderate(ctx, moderation.Submission{AuthorID: "u1", Text: "Darn it"})
+	testUtil.Equal(t, moderation.ErrRejected, err)
+
+	// A failing scorer does not block a submission.
+	flagged, err := m.Moderate(ctx, moderation.Submission{AuthorID: "u1", Text: "Lovely read."})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, false, flagged)
+
+	flagged, err = m.Moderate(ctx, moderation.Submission{AuthorID: "u1", Text: "SEE https://a.example https://b.example https://c.example !!!!!!!!!!"})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, true, flagged)
+}
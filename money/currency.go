@@ -0,0 +1,34 @@
+package money
+
+import "strings"
+
+// currencies lists the active ISO 4217 codes by their number of minor
+// units. Funds and precious metals are left out.
+var currencies = func() map[string]int {
+	byExponent := map[int]string{
+		0: "BIF CLP DJF GNF ISK JPY KMF KRW PYG RWF UGX UYI VND VUV XAF XOF XPF",
+		2: "AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BMD BND BOB BOV BRL BSD BTN BWP BYN BZD " +
+			"CAD CDF CHE CHF CHW CNY COP COU CRC CUC CUP CVE CZK DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL " +
+			"GHS GIP GMD GTQ GYD HKD HNL HTG HUF IDR ILS INR IRR JMD KES KGS KHR KPW KYD KZT LAK LBP LKR LRD " +
+			"LSL MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO NOK NPR NZD PAB PEN " +
+			"PGK PHP PKR PLN QAR RON RSD RUB SAR SBD SCR SDG SEK SGD SHP SLE SLL SOS SRD SSP STN SVC SYP SZL " +
+			"THB TJS TMT TOP TRY TTD TWD TZS UAH USD USN UYU UZS VED VES WST XCD YER ZAR ZMW ZWL",
+		3: "BHD IQD JOD KWD LYD OMR TND",
+		4: "CLF UYW",
+	}
+
+	m := make(map[string]int)
+	for exp, codes := range byExponent {
+		for _, code := range strings.Fields(codes) {
+			m[code] = exp
+		}
+	}
+	return m
+}()
+
+// Exponent returns the number of minor units of currency, e.g. 2 for EUR
+// and 0 for JPY, and whether currency is a known code.
+func Exponent(currency string) (int, bool) {
+	exp, ok := currencies[currency]
+	return exp, ok
+}
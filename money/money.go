@@ -0,0 +1,128 @@
+// Package money holds amounts as integers of a currency's minor unit, so
+// that prices never pass through floating point. Amounts are written as
+// decimal strings with exactly the currency's number of decimals, e.g.
+// {"amount": "12.50", "currency": "EUR"} or {"amount": "1200", "currency": "JPY"}.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCurrency = errors.New("money: unknown currency")
+	ErrInvalidAmount   = errors.New("money: invalid amount")
+)
+
+// Money is an amount in the minor unit of Currency, an ISO 4217 code. The
+// zero value has no currency and means "no amount".
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// Parse reads a decimal amount in currency. The amount may have up to as
+// many decimals as the currency has minor units, e.g. "12", "12.5" and
+// "12.50" are all 1250 EUR cents.
+func Parse(amount, currency string) (Money, error) {
+	exp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, ErrInvalidCurrency
+	}
+
+	s, neg := strings.CutPrefix(amount, "-")
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || (hasFrac && frac == "") || len(frac) > exp || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, ErrInvalidAmount
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	digits := whole + frac
+	if neg {
+		digits = "-" + digits
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+	return Money{Amount: n, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsZero reports whether m holds no amount at all.
+func (m Money) IsZero() bool {
+	return m.Currency == ""
+}
+
+// Decimal formats the amount with the currency's number of decimals.
+func (m Money) Decimal() string {
+	exp, _ := Exponent(m.Currency)
+
+	sign := ""
+	amount := uint64(m.Amount)
+	if m.Amount < 0 {
+		// Negating math.MinInt64 overflows back to itself, which still
+		// converts to the right magnitude.
+		sign, amount = "-", uint64(-m.Amount)
+	}
+
+	s := strconv.FormatUint(amount, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// jsonMoney reads the amount from its text, whether it is given as a string
+// or as a JSON number, so that it is never parsed as a float.
+type jsonMoney struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*m = Money{}
+		return nil
+	}
+
+	var v jsonMoney
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	parsed, err := Parse(v.Amount.String(), v.Currency)
+	if err != nil {
+		return fmt.Errorf("%w: %s %s", err, v.Amount, v.Currency)
+	}
+	*m = parsed
+	return nil
+}
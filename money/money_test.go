@@ -0,0 +1,91 @@
+package money_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"hello/money"
+	testUtil "hello/util/test"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"12.50", "EUR", 1250, nil},
+		{"12.5", "EUR", 1250, nil},
+		{"12", "EUR", 1200, nil},
+		{"0.07", "USD", 7, nil},
+		{"-3.10", "GBP", -310, nil},
+		{"1200", "JPY", 1200, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"12.505", "EUR", 0, money.ErrInvalidAmount},
+		{"12.5", "JPY", 0, money.ErrInvalidAmount},
+		{"12.", "EUR", 0, money.ErrInvalidAmount},
+		{".5", "EUR", 0, money.ErrInvalidAmount},
+		{"1e3", "EUR", 0, money.ErrInvalidAmount},
+		{"+1", "EUR", 0, money.ErrInvalidAmount},
+		{"99999999999999999999", "EUR", 0, money.ErrInvalidAmount},
+		{"1", "eur", 0, money.ErrInvalidCurrency},
+		{"1", "XAU", 0, money.ErrInvalidCurrency},
+	}
+	for _, tc := range tests {
+		t.Run(tc.amount+" "+tc.currency, func(t *testing.T) {
+			m, err := money.Parse(tc.amount, tc.currency)
+			testUtil.Equal(t, err, tc.err)
+			testUtil.Equal(t, m.Amount, tc.want)
+		})
+	}
+}
+
+func TestMoney_Decimal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		m    money.Money
+		want string
+	}{
+		{money.Money{Amount: 1250, Currency: "EUR"}, "12.50"},
+		{money.Money{Amount: 7, Currency: "USD"}, "0.07"},
+		{money.Money{Amount: -310, Currency: "GBP"}, "-3.10"},
+		{money.Money{Amount: 1200, Currency: "JPY"}, "1200"},
+		{money.Money{Amount: 5, Currency: "KWD"}, "0.005"},
+		{money.Money{Amount: math.MinInt64, Currency: "EUR"}, "-92233720368547758.08"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			testUtil.Equal(t, tc.m.Decimal(), tc.want)
+
+			back, err := money.Parse(tc.want, tc.m.Currency)
+			testUtil.NoError(t, err)
+			testUtil.Equal(t, back, tc.m)
+		})
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(money.Money{Amount: 1999, Currency: "USD"})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, string(b), `{"amount":"19.99","currency":"USD"}`)
+
+	b, err = json.Marshal(money.Money{})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, string(b), `null`)
+
+	for _, in := range []string{`{"amount":"19.99","currency":"USD"}`, `{"amount":19.99,"currency":"USD"}`} {
+		var m money.Money
+		testUtil.NoError(t, json.Unmarshal([]byte(in), &m))
+		testUtil.Equal(t, m, money.Money{Amount: 1999, Currency: "USD"})
+	}
+
+	var m money.Money
+	testUtil.Equal(t, json.Unmarshal([]byte(`{"amount":"19.999","currency":"USD"}`), &m) != nil, true)
+}
@@ -0,0 +1,114 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	e "hello/api/resource/common/err"
+)
+
+// maxBodySize bounds the bodies buffered for validation. Larger bodies are
+// passed through unchecked and left to the handler's own limits.
+const maxBodySize = 1 << 20
+
+// Middleware validates requests against the spec. Parameter violations are
+// rejected with 400 and body schema violations with 422, both listing every
+// violation. With enforce unset violations are only logged, which is meant
+// for catching handler and spec drift in staging without failing requests.
+// Requests to operations missing from the spec are logged and passed
+// through.
+func (s *Spec) Middleware(enforce bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, pathParams, ok := s.Operation(r.Method, r.URL.Path)
+			if !ok {
+				log.Printf("openapi: %s %s is not in the spec", r.Method, r.URL.Path)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if errs := s.ValidateParams(op, r, pathParams); len(errs) > 0 {
+				if reject(w, r, enforce, e.RespInvalidParams, errs) {
+					return
+				}
+			}
+
+			if schema, required := op.BodySchema(); schema != nil && isJSON(r) {
+				body, complete, err := buffer(r)
+				if err != nil {
+					e.BadRequest(w, e.RespJSONDecodeFailure)
+					return
+				}
+
+				if complete {
+					if errs := s.validateBody(schema, required, body); len(errs) > 0 {
+						if reject(w, r, enforce, e.RespValidationFailed, errs) {
+							return
+						}
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (s *Spec) validateBody(schema *Schema, required bool, body []byte) []string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if required {
+			return []string{"body is required"}
+		}
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []string{"body is not valid JSON"}
+	}
+	return s.ValidateValue(schema, v)
+}
+
+// reject writes the violations and reports true when enforcing, and logs
+// them otherwise.
+func reject(w http.ResponseWriter, r *http.Request, enforce bool, p *e.Problem, errs []string) bool {
+	if !enforce {
+		log.Printf("openapi: %s %s violates the spec: %v", r.Method, r.URL.Path, errs)
+		return false
+	}
+
+	e.Write(w, p.WithErrors(e.Messages(errs)))
+	return true
+}
+
+// buffer reads up to maxBodySize of the body and puts it back for the
+// handler. complete is false when the body was larger.
+func buffer(r *http.Request) (body []byte, complete bool, err error) {
+	body, err = io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	complete = len(body) <= maxBodySize
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	return body, complete, nil
+}
+
+// isJSON reports whether r has a JSON body. The handlers decode bodies as
+// JSON whatever their content type, so an unset one counts too.
+func isJSON(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ct)
+	return err == nil && mediaType == "application/json"
+}
@@ -0,0 +1,159 @@
+package openapi
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxExampleDepth stops example generation on recursive definitions.
+const maxExampleDepth = 8
+
+// Fixture is a canned response served in place of the generated example.
+type Fixture struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// Fixtures maps an operation, as "METHOD /spec/path", to its fixture.
+//
+//	{"GET /books/{id}": {"status": 200, "body": {"id": "1", "title": "Dune"}}}
+type Fixtures map[string]Fixture
+
+// LoadFixtures reads a fixtures file. An empty path yields no fixtures.
+func LoadFixtures(path string) (Fixtures, error) {
+	if path == "" {
+		return Fixtures{}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f Fixtures
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("openapi: parse %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Mock serves every operation in the spec with its fixture when there is
+// one, and otherwise with an example built from its success response: the
+// response's own example, else one generated from its schema. Operations
+// missing from the spec answer 404.
+func (s *Spec) Mock(fixtures Fixtures) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, _, ok := s.Operation(r.Method, r.URL.Path)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if f, ok := fixtures[r.Method+" "+op.Pattern]; ok {
+			w.WriteHeader(cmp.Or(f.Status, http.StatusOK))
+			w.Write(f.Body)
+			return
+		}
+
+		status, resp := op.success()
+		if resp == nil {
+			w.WriteHeader(status)
+			return
+		}
+
+		example, ok := resp.Examples["application/json"]
+		if !ok {
+			if resp.Schema == nil {
+				w.WriteHeader(status)
+				return
+			}
+			example = s.Example(resp.Schema)
+		}
+
+		b, err := json.Marshal(example)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write(b)
+	})
+}
+
+// success returns the lowest 2xx response of op, or 200 with no response
+// when none is declared.
+func (op *Operation) success() (int, *Response) {
+	codes := make([]int, 0, len(op.Responses))
+	for code := range op.Responses {
+		if n, err := strconv.Atoi(code); err == nil && n >= 200 && n < 300 {
+			codes = append(codes, n)
+		}
+	}
+	if len(codes) == 0 {
+		return http.StatusOK, nil
+	}
+
+	sort.Ints(codes)
+	return codes[0], op.Responses[strconv.Itoa(codes[0])]
+}
+
+// Example builds a value matching schema, preferring the examples and enums
+// it declares.
+func (s *Spec) Example(schema *Schema) any {
+	return s.example(schema, 0)
+}
+
+func (s *Spec) example(schema *Schema, depth int) any {
+	schema = s.resolve(schema)
+	if schema == nil || depth > maxExampleDepth {
+		return nil
+	}
+	if schema.Example != nil {
+		return schema.Example
+	}
+	if len(schema.Enum) > 0 {
+		return schema.Enum[0]
+	}
+
+	switch schema.Type {
+	case "array":
+		return []any{s.example(schema.Items, depth+1)}
+	case "string":
+		return stringExample(schema.Format)
+	case "integer", "number":
+		if schema.Minimum != nil {
+			return *schema.Minimum
+		}
+		return 0
+	case "boolean":
+		return false
+	}
+
+	obj := make(map[string]any, len(schema.Properties))
+	for name, prop := range schema.Properties {
+		obj[name] = s.example(prop, depth+1)
+	}
+	return obj
+}
+
+func stringExample(format string) string {
+	switch strings.ToLower(format) {
+	case "date":
+		return "2006-01-02"
+	case "date-time":
+		return "2006-01-02T15:04:05Z"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "uri", "url":
+		return "https://example.com"
+	}
+	return "string"
+}
@@ -0,0 +1,100 @@
+package openapi_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hello/openapi"
+	testUtil "hello/util/test"
+)
+
+func TestSpec_Middleware(t *testing.T) {
+	t.Parallel()
+
+	spec, err := openapi.Load("testdata/swagger.json")
+	testUtil.NoError(t, err)
+
+	var gotBody string
+	h := spec.Middleware(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"valid query", http.MethodGet, "/v1/books?decade=1990", "", http.StatusOK},
+		{"invalid query type", http.MethodGet, "/v1/books?decade=nineties", "", http.StatusBadRequest},
+		{"literal preferred to param", http.MethodGet, "/v1/books/search", "", http.StatusBadRequest},
+		{"path param", http.MethodGet, "/v1/books/abc", "", http.StatusOK},
+		{"valid body", http.MethodPost, "/v1/books", `{"title": "Go", "author": "A", "custom_fields": {"x": 1}}`, http.StatusOK},
+		{"missing field", http.MethodPost, "/v1/books", `{"title": "Go"}`, http.StatusUnprocessableEntity},
+		{"wrong type", http.MethodPost, "/v1/books", `{"title": 1, "author": "A"}`, http.StatusUnprocessableEntity},
+		{"too long", http.MethodPost, "/v1/books", `{"title": "Long", "author": "A"}`, http.StatusUnprocessableEntity},
+		{"missing body", http.MethodPost, "/v1/books", "", http.StatusUnprocessableEntity},
+		{"not in spec", http.MethodDelete, "/v1/books", "", http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+			testUtil.Equal(t, tc.status, w.Code)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	body := `{"title": "Go", "author": "A"}`
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader(body)))
+	testUtil.Equal(t, body, gotBody)
+}
+
+func TestSpec_MiddlewareReport(t *testing.T) {
+	t.Parallel()
+
+	spec, err := openapi.Load("testdata/swagger.json")
+	testUtil.NoError(t, err)
+
+	h := spec.Middleware(false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/books?decade=nineties", nil))
+	testUtil.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSpec_Mock(t *testing.T) {
+	t.Parallel()
+
+	spec, err := openapi.Load("testdata/swagger.json")
+	testUtil.NoError(t, err)
+
+	h := spec.Mock(openapi.Fixtures{
+		"GET /books/{id}": {Status: http.StatusNotFound, Body: []byte(`{"error": "not found"}`)},
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{"generated example", http.MethodGet, "/v1/books", http.StatusOK, `[{"author":"string","title":"Dune"}]`},
+		{"no schema", http.MethodPost, "/v1/books", http.StatusCreated, ""},
+		{"fixture", http.MethodGet, "/v1/books/1", http.StatusNotFound, `{"error": "not found"}`},
+		{"not in spec", http.MethodDelete, "/v1/books", http.StatusNotFound, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+			testUtil.Equal(t, tc.status, w.Code)
+			testUtil.Equal(t, tc.body, w.Body.String())
+		})
+	}
+}
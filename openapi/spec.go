@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Spec is the subset of a Swagger 2.0 document, as generated by swag from
+// the handlers' godoc annotations, needed to validate requests.
+type Spec struct {
+	BasePath    string                          `json:"basePath"`
+	Paths       map[string]map[string]Operation `json:"paths"`
+	Definitions map[string]*Schema              `json:"definitions"`
+
+	routes []route
+}
+
+type Operation struct {
+	Parameters []Parameter          `json:"parameters"`
+	Responses  map[string]*Response `json:"responses"`
+
+	// Pattern is the spec path the operation is declared under.
+	Pattern string `json:"-"`
+}
+
+type Response struct {
+	Schema   *Schema        `json:"schema"`
+	Examples map[string]any `json:"examples"`
+}
+
+// Parameter describes one request parameter. Body parameters carry a
+// schema, the others a simple type.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Type     string  `json:"type"`
+	Enum     []any   `json:"enum"`
+	Schema   *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Required   []string           `json:"required"`
+	Properties map[string]*Schema `json:"properties"`
+	Items      *Schema            `json:"items"`
+	Enum       []any              `json:"enum"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+	Format     string             `json:"format"`
+	Example    any                `json:"example"`
+}
+
+type route struct {
+	segments []string
+	literals int
+	methods  map[string]Operation
+}
+
+// Load reads a spec file. An empty path yields nil, which disables
+// validation.
+func Load(path string) (*Spec, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Spec
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("openapi: parse %s: %w", path, err)
+	}
+
+	for path, methods := range s.Paths {
+		rt := route{segments: split(path), methods: make(map[string]Operation, len(methods))}
+		for _, seg := range rt.segments {
+			if !isParam(seg) {
+				rt.literals++
+			}
+		}
+		for method, op := range methods {
+			op.Pattern = path
+			rt.methods[strings.ToUpper(method)] = op
+		}
+		s.routes = append(s.routes, rt)
+	}
+	return &s, nil
+}
+
+// Operation finds the operation serving method and path, returning the path
+// parameter values it captures. Literal segments win over parameters, so
+// /books/search is preferred to /books/{id}.
+func (s *Spec) Operation(method, path string) (*Operation, map[string]string, bool) {
+	path, ok := strings.CutPrefix(path, strings.TrimSuffix(s.BasePath, "/"))
+	if !ok {
+		return nil, nil, false
+	}
+	segments := split(path)
+
+	var best *route
+	for i := range s.routes {
+		rt := &s.routes[i]
+		if _, ok := rt.methods[method]; !ok || !matches(rt.segments, segments) {
+			continue
+		}
+		if best == nil || rt.literals > best.literals {
+			best = rt
+		}
+	}
+	if best == nil {
+		return nil, nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range best.segments {
+		if isParam(seg) {
+			params[seg[1:len(seg)-1]] = segments[i]
+		}
+	}
+	op := best.methods[method]
+	return &op, params, true
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+	}
+	return schema
+}
+
+func split(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func isParam(seg string) bool {
+	return strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
+}
+
+func matches(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, seg := range pattern {
+		if !isParam(seg) && seg != segments[i] {
+			return false
+		}
+	}
+	return true
+}
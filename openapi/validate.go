@@ -0,0 +1,176 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ValidateParams checks the path, query and header parameters of r against
+// op, returning one message per violation.
+func (s *Spec) ValidateParams(op *Operation, r *http.Request, pathParams map[string]string) []string {
+	var errs []string
+	query := r.URL.Query()
+
+	for _, p := range op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value, present = pathParams[p.Name]
+		case "query":
+			present = query.Has(p.Name)
+			value = query.Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		default:
+			continue
+		}
+
+		if !present {
+			if p.Required {
+				errs = append(errs, fmt.Sprintf("%s parameter %s is required", p.In, p.Name))
+			}
+			continue
+		}
+
+		v, ok := parse(p.Type, value)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s parameter %s must be a valid %s", p.In, p.Name, p.Type))
+			continue
+		}
+		if len(p.Enum) > 0 && !inEnum(p.Enum, v) {
+			errs = append(errs, fmt.Sprintf("%s parameter %s must be one of %v", p.In, p.Name, p.Enum))
+		}
+	}
+	return errs
+}
+
+// BodySchema returns the schema of op's body parameter, if it has one.
+func (op *Operation) BodySchema() (*Schema, bool) {
+	for _, p := range op.Parameters {
+		if p.In == "body" && p.Schema != nil {
+			return p.Schema, p.Required
+		}
+	}
+	return nil, false
+}
+
+// ValidateValue checks a decoded JSON value against schema, returning one
+// message per violation. Fields are named by their JSON path.
+func (s *Spec) ValidateValue(schema *Schema, v any) []string {
+	return s.ValidateAt(schema, v, "body")
+}
+
+// ValidateAt is ValidateValue naming fields by their JSON path from root.
+func (s *Spec) ValidateAt(schema *Schema, v any, root string) []string {
+	var errs []string
+	s.validate(schema, v, root, &errs)
+	return errs
+}
+
+func (s *Spec) validate(schema *Schema, v any, path string, errs *[]string) {
+	schema = s.resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	if !hasType(schema.Type, v) {
+		*errs = append(*errs, fmt.Sprintf("%s must be of type %s", path, schema.Type))
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, v) {
+		*errs = append(*errs, fmt.Sprintf("%s must be one of %v", path, schema.Enum))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s.%s is a required field", path, name))
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := schema.Properties[name]; ok {
+				s.validate(prop, v[name], path+"."+name, errs)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if schema.MinLength != nil && n < *schema.MinLength {
+			*errs = append(*errs, fmt.Sprintf("%s must be a minimum of %d in length", path, *schema.MinLength))
+		}
+		if schema.MaxLength != nil && n > *schema.MaxLength {
+			*errs = append(*errs, fmt.Sprintf("%s must be a maximum of %d in length", path, *schema.MaxLength))
+		}
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			*errs = append(*errs, fmt.Sprintf("%s must be at least %v", path, *schema.Minimum))
+		}
+		if schema.Maximum != nil && v > *schema.Maximum {
+			*errs = append(*errs, fmt.Sprintf("%s must be at most %v", path, *schema.Maximum))
+		}
+	}
+}
+
+func hasType(typ string, v any) bool {
+	switch typ {
+	case "":
+		return true
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+// parse converts a parameter value to the JSON type it is declared as.
+func parse(typ, value string) (any, bool) {
+	switch typ {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		return float64(n), err == nil
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		return b, err == nil
+	}
+	return value, true
+}
+
+func inEnum(enum []any, v any) bool {
+	return slices.Contains(enum, v)
+}
@@ -0,0 +1,79 @@
+package pact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Broker fetches pacts from a Pact broker.
+type Broker struct {
+	URL string
+	// Token, when set, is sent as a bearer token.
+	Token  string
+	Client *http.Client
+}
+
+// Latest fetches the latest pact of every consumer of provider.
+func (b *Broker) Latest(ctx context.Context, provider string) ([]*Pact, error) {
+	index := strings.TrimSuffix(b.URL, "/") + "/pacts/provider/" + url.PathEscape(provider) + "/latest"
+
+	var links struct {
+		Links struct {
+			Pacts    []struct{ Href string } `json:"pb:pacts"`
+			OldPacts []struct{ Href string } `json:"pacts"`
+		} `json:"_links"`
+	}
+	if err := b.get(ctx, index, &links); err != nil {
+		return nil, err
+	}
+
+	hrefs := links.Links.Pacts
+	if len(hrefs) == 0 {
+		hrefs = links.Links.OldPacts
+	}
+
+	pacts := make([]*Pact, 0, len(hrefs))
+	for _, link := range hrefs {
+		var p Pact
+		if err := b.get(ctx, link.Href, &p); err != nil {
+			return nil, err
+		}
+		pacts = append(pacts, &p)
+	}
+	return pacts, nil
+}
+
+func (b *Broker) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/hal+json, application/json")
+	if b.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.Token)
+	}
+
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, res.Body)
+		return fmt.Errorf("pact: broker GET %s: %s", url, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("pact: broker GET %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package pact
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rule is a single matcher. Values without a rule must equal the expected
+// value, except that objects may carry keys the pact does not mention.
+type rule struct {
+	Match string `json:"match"`
+	Regex string `json:"regex"`
+	Value string `json:"value"`
+	Min   *int   `json:"min"`
+	Max   *int   `json:"max"`
+}
+
+// rules holds the body matchers by parsed JSON path, and the header
+// matchers by lower-cased name.
+type rules struct {
+	body    []pathRule
+	headers map[string]rule
+}
+
+type pathRule struct {
+	path []string
+	rule rule
+}
+
+// parseRules reads version 2 rules, keyed by "$.body..." and
+// "$.headers..." paths, and version 3 rules, grouped by category with a list
+// of matchers each. Of several version 3 matchers only the first is used.
+func parseRules(raw json.RawMessage) (*rules, error) {
+	rs := &rules{headers: make(map[string]rule)}
+	if !hasBody(raw) {
+		return rs, nil
+	}
+
+	var generic map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, fmt.Errorf("pact: matching rules: %w", err)
+	}
+
+	for key, value := range generic {
+		switch {
+		case key == "body" || key == "header":
+			var v3 map[string]struct {
+				Matchers []rule `json:"matchers"`
+			}
+			if err := json.Unmarshal(value, &v3); err != nil {
+				return nil, fmt.Errorf("pact: matching rules: %w", err)
+			}
+			for path, m := range v3 {
+				if len(m.Matchers) == 0 {
+					continue
+				}
+				if key == "header" {
+					rs.headers[strings.ToLower(path)] = m.Matchers[0]
+				} else {
+					rs.body = append(rs.body, pathRule{parsePath(path), m.Matchers[0]})
+				}
+			}
+		case strings.HasPrefix(key, "$.body"):
+			var r rule
+			if err := json.Unmarshal(value, &r); err != nil {
+				return nil, fmt.Errorf("pact: matching rules: %w", err)
+			}
+			rs.body = append(rs.body, pathRule{parsePath("$" + strings.TrimPrefix(key, "$.body")), r})
+		case strings.HasPrefix(key, "$.headers."):
+			var r rule
+			if err := json.Unmarshal(value, &r); err != nil {
+				return nil, fmt.Errorf("pact: matching rules: %w", err)
+			}
+			rs.headers[strings.ToLower(strings.TrimPrefix(key, "$.headers."))] = r
+		}
+	}
+	return rs, nil
+}
+
+// parsePath splits a JSON path such as $.items[*].id or $['a.b'] into its
+// keys and indices, with * for wildcards.
+func parsePath(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+
+	var segments []string
+	for len(path) > 0 {
+		switch {
+		case strings.HasPrefix(path, "['"):
+			end := strings.Index(path, "']")
+			if end < 0 {
+				return append(segments, path[2:])
+			}
+			segments = append(segments, path[2:end])
+			path = path[end+2:]
+		case path[0] == '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return append(segments, path[1:])
+			}
+			segments = append(segments, path[1:end])
+			path = path[end+1:]
+		case path[0] == '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			segments = append(segments, path[:end])
+			path = path[end:]
+		default:
+			return append(segments, path)
+		}
+	}
+	return segments
+}
+
+// find returns the most specific rule declared for path.
+func (rs *rules) find(path []string) (rule, bool) {
+	var best rule
+	bestScore := -1
+	for _, pr := range rs.body {
+		if len(pr.path) != len(path) {
+			continue
+		}
+
+		score := 0
+		for i, seg := range pr.path {
+			if seg == "*" {
+				continue
+			}
+			if seg != path[i] {
+				score = -1
+				break
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = pr.rule, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// compare matches actual against expected at path, appending a message per
+// mismatch. inherited is a type rule of an enclosing value, which applies to
+// everything below it that has no rule of its own.
+func (rs *rules) compare(path []string, expected, actual any, inherited *rule, errs *[]string) {
+	r, ok := rs.find(path)
+	if !ok && inherited != nil {
+		r, ok = *inherited, true
+	}
+
+	if ok {
+		switch r.Match {
+		case "type":
+			rs.compareType(path, r, expected, actual, errs)
+			return
+		case "regex":
+			s := fmt.Sprint(actual)
+			if matched, err := regexp.MatchString(r.Regex, s); err != nil || !matched {
+				fail(errs, path, "%q does not match %q", s, r.Regex)
+			}
+			return
+		case "include":
+			if s, ok := actual.(string); !ok || !strings.Contains(s, r.Value) {
+				fail(errs, path, "%v does not include %q", actual, r.Value)
+			}
+			return
+		case "integer":
+			if f, ok := actual.(float64); !ok || f != float64(int64(f)) {
+				fail(errs, path, "%v is not an integer", actual)
+			}
+			return
+		case "decimal", "number":
+			if _, ok := actual.(float64); !ok {
+				fail(errs, path, "%v is not a number", actual)
+			}
+			return
+		case "boolean":
+			if _, ok := actual.(bool); !ok {
+				fail(errs, path, "%v is not a boolean", actual)
+			}
+			return
+		case "null":
+			if actual != nil {
+				fail(errs, path, "%v is not null", actual)
+			}
+			return
+		}
+	}
+
+	switch exp := expected.(type) {
+	case map[string]any:
+		act, ok := actual.(map[string]any)
+		if !ok {
+			fail(errs, path, "expected an object, got %v", actual)
+			return
+		}
+		for key, value := range exp {
+			got, ok := act[key]
+			if !ok {
+				fail(errs, at(path, key), "missing")
+				continue
+			}
+			rs.compare(at(path, key), value, got, nil, errs)
+		}
+	case []any:
+		act, ok := actual.([]any)
+		if !ok || len(act) != len(exp) {
+			fail(errs, path, "expected %v, got %v", expected, actual)
+			return
+		}
+		for i := range exp {
+			rs.compare(at(path, strconv.Itoa(i)), exp[i], act[i], nil, errs)
+		}
+	default:
+		if !reflect.DeepEqual(expected, actual) {
+			fail(errs, path, "expected %v, got %v", expected, actual)
+		}
+	}
+}
+
+// compareType matches by kind, passing a type rule down to the children.
+// Arrays are matched element by element against the first expected element
+// and checked against the rule's min and max.
+func (rs *rules) compareType(path []string, r rule, expected, actual any, errs *[]string) {
+	inherited := &rule{Match: "type"}
+
+	switch exp := expected.(type) {
+	case map[string]any:
+		act, ok := actual.(map[string]any)
+		if !ok {
+			fail(errs, path, "expected an object, got %v", actual)
+			return
+		}
+		for key, value := range exp {
+			got, ok := act[key]
+			if !ok {
+				fail(errs, at(path, key), "missing")
+				continue
+			}
+			rs.compare(at(path, key), value, got, inherited, errs)
+		}
+	case []any:
+		act, ok := actual.([]any)
+		if !ok {
+			fail(errs, path, "expected an array, got %v", actual)
+			return
+		}
+		if r.Min != nil && len(act) < *r.Min {
+			fail(errs, path, "expected at least %d elements, got %d", *r.Min, len(act))
+		}
+		if r.Max != nil && len(act) > *r.Max {
+			fail(errs, path, "expected at most %d elements, got %d", *r.Max, len(act))
+		}
+		if len(exp) == 0 {
+			return
+		}
+		for i := range act {
+			rs.compare(at(path, strconv.Itoa(i)), exp[0], act[i], inherited, errs)
+		}
+	default:
+		if reflect.TypeOf(expected) != reflect.TypeOf(actual) {
+			fail(errs, path, "expected a value like %v, got %v", expected, actual)
+		}
+	}
+}
+
+// at returns the path of a child of path, without sharing path's backing
+// array with its siblings.
+func at(path []string, seg string) []string {
+	return append(path[:len(path):len(path)], seg)
+}
+
+func fail(errs *[]string, path []string, format string, args ...any) {
+	*errs = append(*errs, "$"+formatPath(path)+": "+fmt.Sprintf(format, args...))
+}
+
+func formatPath(path []string) string {
+	var b strings.Builder
+	for _, seg := range path {
+		if _, err := strconv.Atoi(seg); err == nil {
+			b.WriteString("[" + seg + "]")
+		} else {
+			b.WriteString("." + seg)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,124 @@
+// Package pact verifies this service against consumer-driven contracts in the
+// Pact format, version 2 or 3, as published by consumers to a Pact broker.
+package pact
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Pact is the contract between one consumer and this provider.
+type Pact struct {
+	Consumer     Participant    `json:"consumer"`
+	Provider     Participant    `json:"provider"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Participant struct {
+	Name string `json:"name"`
+}
+
+// Interaction is one request the consumer makes and the response it relies
+// on.
+type Interaction struct {
+	Description string `json:"description"`
+	// ProviderState is the version 2 form of ProviderStates.
+	ProviderState  string          `json:"providerState"`
+	ProviderStates []ProviderState `json:"providerStates"`
+	Request        Request         `json:"request"`
+	Response       Response        `json:"response"`
+}
+
+// ProviderState names data the provider must hold for an interaction.
+type ProviderState struct {
+	Name   string         `json:"name"`
+	Params map[string]any `json:"params"`
+}
+
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   json.RawMessage   `json:"query"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+type Response struct {
+	Status        int               `json:"status"`
+	Headers       map[string]string `json:"headers"`
+	Body          json.RawMessage   `json:"body"`
+	MatchingRules json.RawMessage   `json:"matchingRules"`
+}
+
+// Parse decodes a pact document.
+func Parse(b []byte) (*Pact, error) {
+	var p Pact
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("pact: parse: %w", err)
+	}
+	return &p, nil
+}
+
+// LoadDir reads every .json pact in dir.
+func LoadDir(dir string) ([]*Pact, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	pacts := make([]*Pact, 0, len(paths))
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		p, err := Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		pacts = append(pacts, p)
+	}
+	return pacts, nil
+}
+
+// States returns the interaction's provider states in either version's
+// form.
+func (i *Interaction) States() []ProviderState {
+	if len(i.ProviderStates) > 0 {
+		return i.ProviderStates
+	}
+	if i.ProviderState != "" {
+		return []ProviderState{{Name: i.ProviderState}}
+	}
+	return nil
+}
+
+// RawQuery encodes the request query, which version 2 pacts give as a string
+// and version 3 pacts as a map of value lists.
+func (r *Request) RawQuery() (string, error) {
+	if len(r.Query) == 0 || string(r.Query) == "null" {
+		return "", nil
+	}
+
+	var s string
+	if err := json.Unmarshal(r.Query, &s); err == nil {
+		return s, nil
+	}
+
+	var m map[string][]string
+	if err := json.Unmarshal(r.Query, &m); err != nil {
+		return "", fmt.Errorf("pact: query: %w", err)
+	}
+	return url.Values(m).Encode(), nil
+}
+
+// hasBody reports whether raw holds a body, as opposed to being absent.
+func hasBody(raw json.RawMessage) bool {
+	return len(raw) > 0 && strings.TrimSpace(string(raw)) != "null"
+}
@@ -0,0 +1,132 @@
+package pact_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hello/pact"
+	testUtil "hello/util/test"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	t.Parallel()
+
+	books := `[{"id": "1", "title": "Dune", "year": 1965, "tags": ["sf"]}, {"id": "2", "title": "Emma", "year": 1815, "tags": []}]`
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("author") == "herbert":
+			w.Write([]byte(books))
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		pact    string
+		wantErr string
+	}{
+		{
+			"v3 type and regex rules",
+			`{"interactions": [{"description": "list", "providerStates": [{"name": "books exist"}],
+				"request": {"method": "GET", "path": "/books", "query": {"author": ["herbert"]}},
+				"response": {"status": 200, "headers": {"Content-Type": "application/json"},
+					"body": [{"id": "x", "title": "T", "year": 2000, "tags": ["t"]}],
+					"matchingRules": {"body": {
+						"$": {"matchers": [{"match": "type", "min": 2}]},
+						"$[*].id": {"matchers": [{"match": "regex", "regex": "^[0-9]+$"}]}}}}}]}`,
+			"",
+		},
+		{
+			"v2 rules",
+			`{"interactions": [{"description": "list", "providerState": "books exist",
+				"request": {"method": "GET", "path": "/books", "query": "author=herbert"},
+				"response": {"status": 200, "body": [{"title": "Dune"}, {"title": "Emma"}],
+					"matchingRules": {"$.body[1].title": {"match": "regex", "regex": "^E"}}}}]}`,
+			"",
+		},
+		{
+			"exact mismatch",
+			`{"interactions": [{"description": "list", "providerState": "books exist",
+				"request": {"method": "GET", "path": "/books", "query": "author=herbert"},
+				"response": {"status": 200, "body": [{"title": "Dune"}, {"title": "Persuasion"}]}}]}`,
+			`$[1].title: expected Persuasion, got Emma`,
+		},
+		{
+			"type mismatch",
+			`{"interactions": [{"description": "list", "providerState": "books exist",
+				"request": {"method": "GET", "path": "/books", "query": "author=herbert"},
+				"response": {"status": 200, "body": [{"year": "1965"}],
+					"matchingRules": {"body": {"$": {"matchers": [{"match": "type"}]}}}}}]}`,
+			`$[0].year: expected a value like 1965, got 1965`,
+		},
+		{
+			"status mismatch",
+			`{"interactions": [{"description": "create", "request": {"method": "POST", "path": "/books", "body": {"title": "Dune"}},
+				"response": {"status": 200}}]}`,
+			"status: expected 200, got 201",
+		},
+		{
+			"unknown state",
+			`{"interactions": [{"description": "list", "providerState": "nothing", "request": {"method": "GET", "path": "/books"},
+				"response": {"status": 404}}]}`,
+			`unknown provider state "nothing"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := pact.Parse([]byte(tc.pact))
+			testUtil.NoError(t, err)
+
+			v := &pact.Verifier{
+				Handler: h,
+				States:  map[string]pact.StateFunc{"books exist": func(map[string]any) error { return nil }},
+			}
+			err = v.Verify(p)
+			if tc.wantErr == "" {
+				testUtil.NoError(t, err)
+				return
+			}
+			testUtil.Equal(t, true, err != nil && strings.Contains(err.Error(), tc.wantErr))
+		})
+	}
+}
+
+func TestBroker_Latest(t *testing.T) {
+	t.Parallel()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/pacts/provider/books-api/latest":
+			fmt.Fprintf(w, `{"_links": {"pb:pacts": [{"href": "%s/pacts/web"}]}}`, srv.URL)
+		case "/pacts/web":
+			w.Write([]byte(`{"consumer": {"name": "web"}, "interactions": [{"description": "list"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	pacts, err := (&pact.Broker{URL: srv.URL, Token: "secret"}).Latest(context.Background(), "books-api")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(pacts))
+	testUtil.Equal(t, "web", pacts[0].Consumer.Name)
+	testUtil.Equal(t, 1, len(pacts[0].Interactions))
+
+	_, err = (&pact.Broker{URL: srv.URL}).Latest(context.Background(), "books-api")
+	testUtil.Equal(t, true, err != nil)
+}
@@ -0,0 +1,153 @@
+package pact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+)
+
+// StateFunc puts the provider into a named state before an interaction.
+type StateFunc func(params map[string]any) error
+
+// Verifier replays the interactions of pacts against Handler and checks the
+// responses against what the consumers expect.
+type Verifier struct {
+	Handler http.Handler
+	// Setup, when set, runs before every interaction, e.g. to reset data.
+	Setup func() error
+	// States sets up the provider states interactions may name.
+	States map[string]StateFunc
+}
+
+// Verify checks every interaction of p, returning one error per failed
+// interaction.
+func (v *Verifier) Verify(p *Pact) error {
+	var errs []error
+	for _, i := range p.Interactions {
+		if err := v.verify(i); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %q: %w", p.Consumer.Name, i.Description, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (v *Verifier) verify(i *Interaction) error {
+	if v.Setup != nil {
+		if err := v.Setup(); err != nil {
+			return fmt.Errorf("setup: %w", err)
+		}
+	}
+	for _, state := range i.States() {
+		set, ok := v.States[state.Name]
+		if !ok {
+			return fmt.Errorf("unknown provider state %q", state.Name)
+		}
+		if err := set(state.Params); err != nil {
+			return fmt.Errorf("provider state %q: %w", state.Name, err)
+		}
+	}
+
+	req, err := i.Request.build()
+	if err != nil {
+		return err
+	}
+	w := httptest.NewRecorder()
+	v.Handler.ServeHTTP(w, req)
+
+	rs, err := parseRules(i.Response.MatchingRules)
+	if err != nil {
+		return err
+	}
+
+	var mismatches []string
+	if i.Response.Status != 0 && w.Code != i.Response.Status {
+		mismatches = append(mismatches, fmt.Sprintf("status: expected %d, got %d", i.Response.Status, w.Code))
+	}
+	for name, want := range i.Response.Headers {
+		if !headerMatches(rs, name, want, w.Header().Get(name)) {
+			mismatches = append(mismatches, fmt.Sprintf("header %s: expected %q, got %q", name, want, w.Header().Get(name)))
+		}
+	}
+	if hasBody(i.Response.Body) {
+		mismatches = append(mismatches, rs.compareBody(i.Response.Body, w.Body.Bytes())...)
+	}
+
+	if len(mismatches) > 0 {
+		return errors.New(strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+func (r *Request) build() (*http.Request, error) {
+	query, err := r.RawQuery()
+	if err != nil {
+		return nil, err
+	}
+	target := r.Path
+	if query != "" {
+		target += "?" + query
+	}
+
+	var body *bytes.Reader
+	if hasBody(r.Body) {
+		var s string
+		if err := json.Unmarshal(r.Body, &s); err == nil && !isJSON(r.Headers["Content-Type"]) {
+			body = bytes.NewReader([]byte(s))
+		} else {
+			body = bytes.NewReader(r.Body)
+		}
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(strings.ToUpper(r.Method), target, body)
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+// compareBody compares a JSON body, or a plain one given as a JSON string.
+func (rs *rules) compareBody(want json.RawMessage, got []byte) []string {
+	var expected, actual any
+	if err := json.Unmarshal(want, &expected); err != nil {
+		return []string{fmt.Sprintf("body: invalid expectation: %s", err)}
+	}
+	if err := json.Unmarshal(got, &actual); err != nil {
+		if s, ok := expected.(string); ok && s == string(got) {
+			return nil
+		}
+		return []string{fmt.Sprintf("body: expected %s, got %q", want, got)}
+	}
+
+	var errs []string
+	rs.compare(nil, expected, actual, nil, &errs)
+	return errs
+}
+
+// headerMatches applies the header's rule when there is one. Otherwise the
+// values must be equal, except that a content type without parameters
+// matches the same media type with parameters.
+func headerMatches(rs *rules, name, want, got string) bool {
+	if r, ok := rs.headers[strings.ToLower(name)]; ok && r.Match == "regex" {
+		matched, err := regexp.MatchString(r.Regex, got)
+		return err == nil && matched
+	}
+
+	if strings.EqualFold(name, "Content-Type") && !strings.Contains(want, ";") {
+		mediaType, _, err := mime.ParseMediaType(got)
+		return err == nil && strings.EqualFold(mediaType, want)
+	}
+	return strings.TrimSpace(want) == strings.TrimSpace(got)
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err != nil || strings.HasSuffix(mediaType, "json")
+}
@@ -0,0 +1,210 @@
+// Package pricing computes the prices shown to customers. Prices are stored
+// net of tax; a rules table gives each region its tax rate and whether
+// prices are displayed with the tax included, as is usual for VAT, or
+// excluded, as is usual for US sales tax. The region is taken from the
+// request, so the same book shows a different price to a customer in
+// Germany than to one in Switzerland.
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"hello/money"
+	"hello/util/computed"
+)
+
+var ErrInvalidRate = errors.New("pricing: invalid tax rate")
+
+// Rule is how prices are displayed in a region.
+//
+//	{"tax_rate": "19", "tax_included": true}
+type Rule struct {
+	// Rate is the tax rate in basis points, e.g. 1900 for 19%.
+	Rate     int64
+	Included bool
+}
+
+type ruleJSON struct {
+	TaxRate     string `json:"tax_rate"`
+	TaxIncluded bool   `json:"tax_included"`
+}
+
+func (r *Rule) UnmarshalJSON(b []byte) error {
+	var v ruleJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	rate, err := parseRate(v.TaxRate)
+	if err != nil {
+		return err
+	}
+	r.Rate, r.Included = rate, v.TaxIncluded
+	return nil
+}
+
+// parseRate reads a percentage with up to two decimals, e.g. "19" or "7.7",
+// as basis points.
+func parseRate(s string) (int64, error) {
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || (hasFrac && frac == "") || len(frac) > 2 || strings.Trim(whole+frac, "0123456789") != "" {
+		return 0, ErrInvalidRate
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	rate, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || rate > 100_00 {
+		return 0, ErrInvalidRate
+	}
+	return rate, nil
+}
+
+// formatRate writes basis points as a percentage without trailing zeros.
+func formatRate(rate int64) string {
+	s := fmt.Sprintf("%d.%02d", rate/100, rate%100)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// Rules maps ISO 3166 region codes to their rule.
+//
+//	{"DE": {"tax_rate": "19", "tax_included": true}, "US": {"tax_rate": "0"}}
+type Rules map[string]Rule
+
+// Load reads a rules file. An empty path yields no rules.
+func Load(path string) (Rules, error) {
+	if path == "" {
+		return Rules{}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs Rules
+	if err := json.Unmarshal(b, &rs); err != nil {
+		return nil, fmt.Errorf("pricing: parse %s: %w", path, err)
+	}
+
+	upper := make(Rules, len(rs))
+	for region, rule := range rs {
+		upper[strings.ToUpper(region)] = rule
+	}
+	return upper, nil
+}
+
+// Price is a price as displayed in a region.
+type Price struct {
+	Price       money.Money `json:"price"`
+	Region      string      `json:"region"`
+	TaxRate     string      `json:"tax_rate"`
+	TaxIncluded bool        `json:"tax_included"`
+}
+
+// Service displays prices by the rules. Requests whose region has no rule
+// get the rule of the fallback region.
+type Service struct {
+	rules    Rules
+	fallback string
+}
+
+func New(rules Rules, fallback string) *Service {
+	return &Service{
+		rules:    rules,
+		fallback: strings.ToUpper(fallback),
+	}
+}
+
+// Display returns net as displayed in region, or nil when net is no price
+// or neither region nor the fallback has a rule.
+func (s *Service) Display(region string, net money.Money) *Price {
+	if net.IsZero() {
+		return nil
+	}
+
+	rule, ok := s.rules[region]
+	if !ok {
+		region = s.fallback
+		if rule, ok = s.rules[region]; !ok {
+			return nil
+		}
+	}
+
+	p := &Price{Price: net, Region: region, TaxRate: formatRate(rule.Rate), TaxIncluded: rule.Included}
+	if rule.Included {
+		p.Price.Amount += tax(net.Amount, rule.Rate)
+	}
+	return p
+}
+
+// tax is rate of amount, rounded half away from zero to the minor unit.
+func tax(amount, rate int64) int64 {
+	t := amount * rate
+	if t < 0 {
+		return -((-t + 50_00) / 100_00)
+	}
+	return (t + 50_00) / 100_00
+}
+
+type ctxKey struct{}
+
+// Middleware records the region prices are displayed for: the ?region=
+// query parameter, or else the region of the preferred Accept-Language,
+// e.g. CH for de-CH. Prices name the region they were displayed for, so
+// clients can tell when an unknown region fell back.
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region := strings.ToUpper(r.URL.Query().Get("region"))
+		if region == "" {
+			region = acceptedRegion(r.Header.Get("Accept-Language"))
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, region)))
+	})
+}
+
+func acceptedRegion(header string) string {
+	if header == "" {
+		return ""
+	}
+	accepted, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(accepted) == 0 {
+		return ""
+	}
+
+	region, conf := accepted[0].Region()
+	if conf == language.No {
+		return ""
+	}
+	return region.String()
+}
+
+// Region returns the region recorded by the middleware, or "".
+func Region(ctx context.Context) string {
+	region, _ := ctx.Value(ctxKey{}).(string)
+	return region
+}
+
+// Resolver returns a computed field holding the display price of each item's
+// net price, in the request's region.
+func Resolver[T any](s *Service, net func(T) money.Money) computed.Resolver[T] {
+	return func(ctx context.Context, items []T) ([]any, error) {
+		region := Region(ctx)
+		values := make([]any, len(items))
+		for i, item := range items {
+			// A nil *Price would still be a non-nil any.
+			if p := s.Display(region, net(item)); p != nil {
+				values[i] = p
+			}
+		}
+		return values, nil
+	}
+}
@@ -0,0 +1,128 @@
+package pricing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hello/money"
+	"hello/pricing"
+	testUtil "hello/util/test"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		testUtil.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	rules, err := pricing.Load(write("ok.json", `{"de": {"tax_rate": "19", "tax_included": true}, "CH": {"tax_rate": "8.1", "tax_included": true}, "US": {"tax_rate": "0"}}`))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, pricing.Rule{Rate: 1900, Included: true}, rules["DE"])
+	testUtil.Equal(t, pricing.Rule{Rate: 810, Included: true}, rules["CH"])
+	testUtil.Equal(t, pricing.Rule{}, rules["US"])
+
+	for _, rate := range []string{"", "19.", "7.125", "-5", "101", "x"} {
+		_, err := pricing.Load(write("bad.json", `{"DE": {"tax_rate": "`+rate+`"}}`))
+		testUtil.Equal(t, true, err != nil)
+	}
+
+	rules, err = pricing.Load("")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 0, len(rules))
+}
+
+func TestService_Display(t *testing.T) {
+	t.Parallel()
+
+	s := pricing.New(pricing.Rules{
+		"DE": {Rate: 1900, Included: true},
+		"CH": {Rate: 810, Included: true},
+		"US": {Rate: 0, Included: false},
+	}, "us")
+
+	tests := []struct {
+		name   string
+		region string
+		net    money.Money
+		want   *pricing.Price
+	}{
+		{"vat included", "DE", money.Money{Amount: 1250, Currency: "EUR"},
+			&pricing.Price{Price: money.Money{Amount: 1488, Currency: "EUR"}, Region: "DE", TaxRate: "19", TaxIncluded: true}},
+		{"rounded", "CH", money.Money{Amount: 1234, Currency: "CHF"},
+			&pricing.Price{Price: money.Money{Amount: 1334, Currency: "CHF"}, Region: "CH", TaxRate: "8.1", TaxIncluded: true}},
+		{"zero decimals", "DE", money.Money{Amount: 1500, Currency: "JPY"},
+			&pricing.Price{Price: money.Money{Amount: 1785, Currency: "JPY"}, Region: "DE", TaxRate: "19", TaxIncluded: true}},
+		{"tax excluded", "US", money.Money{Amount: 1250, Currency: "USD"},
+			&pricing.Price{Price: money.Money{Amount: 1250, Currency: "USD"}, Region: "US", TaxRate: "0", TaxIncluded: false}},
+		{"unknown region falls back", "FR", money.Money{Amount: 1250, Currency: "EUR"},
+			&pricing.Price{Price: money.Money{Amount: 1250, Currency: "EUR"}, Region: "US", TaxRate: "0", TaxIncluded: false}},
+		{"no price", "DE", money.Money{}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := s.Display(tc.region, tc.net)
+			if tc.want == nil {
+				testUtil.Equal(t, (*pricing.Price)(nil), got)
+				return
+			}
+			testUtil.Equal(t, *tc.want, *got)
+		})
+	}
+
+	testUtil.Equal(t, (*pricing.Price)(nil), pricing.New(pricing.Rules{}, "US").Display("DE", money.Money{Amount: 1, Currency: "EUR"}))
+}
+
+func TestService_Middleware(t *testing.T) {
+	t.Parallel()
+
+	s := pricing.New(pricing.Rules{"DE": {Rate: 1900, Included: true}}, "DE")
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		region         string
+	}{
+		{"query", "?region=ch", "de-DE", "CH"},
+		{"accept-language", "", "de-AT, en;q=0.5", "AT"},
+		{"language only", "", "de", "DE"},
+		{"none", "", "", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var region string
+			h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				region = pricing.Region(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/books"+tc.query, nil)
+			if tc.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tc.acceptLanguage)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			testUtil.Equal(t, tc.region, region)
+		})
+	}
+
+	testUtil.Equal(t, "", pricing.Region(context.Background()))
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	s := pricing.New(pricing.Rules{"DE": {Rate: 700, Included: true}}, "DE")
+	resolve := pricing.Resolver(s, func(m money.Money) money.Money { return m })
+
+	values, err := resolve(context.Background(), []money.Money{{Amount: 1000, Currency: "EUR"}, {}})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 2, len(values))
+	testUtil.Equal(t, int64(1070), values[0].(*pricing.Price).Price.Amount)
+	testUtil.Equal(t, nil, values[1])
+}
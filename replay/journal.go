@@ -0,0 +1,252 @@
+// Package replay journals sanitized production requests and replays them
+// against another deployment, comparing response status and shape to catch
+// regressions before a release.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hello/config"
+)
+
+const redacted = "[redacted]"
+
+// journaledHeaders are the request headers kept in the journal. Everything
+// else, credentials in particular, is dropped.
+var journaledHeaders = []string{"Accept", "Accept-Language", "Content-Type", "If-None-Match", "X-Tenant-ID", "X-Scopes"}
+
+// Entry is one journaled request and the response it got.
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	// BodyOmitted is set when the request body was not JSON or too large to
+	// journal, in which case the request cannot be replayed faithfully.
+	BodyOmitted bool `json:"body_omitted,omitempty"`
+
+	Status int    `json:"status"`
+	Shape  string `json:"shape,omitempty"`
+}
+
+// Journal appends sampled requests to a file as JSON lines while enabled.
+type Journal struct {
+	enabled    atomic.Bool
+	sampleRate float64
+	maxBody    int64
+	redact     []string
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// Open opens the journal file for appending. An empty path yields nil,
+// meaning journaling is not available.
+func Open(c *config.ConfJournal) (*Journal, error) {
+	if c.Path == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(c.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	redact := make([]string, len(c.RedactFields))
+	for i, field := range c.RedactFields {
+		redact[i] = strings.ToLower(field)
+	}
+
+	j := &Journal{
+		sampleRate: c.SampleRate,
+		maxBody:    c.MaxBody,
+		redact:     redact,
+		f:          f,
+		enc:        json.NewEncoder(f),
+	}
+	j.enabled.Store(c.Enabled)
+	return j, nil
+}
+
+func (j *Journal) Enabled() bool {
+	return j.enabled.Load()
+}
+
+func (j *Journal) SetEnabled(enabled bool) {
+	j.enabled.Store(enabled)
+	log.Printf("request journal enabled: %t", enabled)
+}
+
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// Middleware journals a sample of requests while the journal is enabled.
+func (j *Journal) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !j.Enabled() || rand.Float64() >= j.sampleRate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entry := &Entry{
+			Time:    time.Now().UTC(),
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   j.sanitizeQuery(r.URL.Query()),
+			Headers: make(map[string]string),
+		}
+		for _, h := range journaledHeaders {
+			if v := r.Header.Get(h); v != "" {
+				entry.Headers[h] = v
+			}
+		}
+
+		if r.Body != nil && r.Body != http.NoBody {
+			body, err := io.ReadAll(io.LimitReader(r.Body, j.maxBody+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+			entry.Body, entry.BodyOmitted = j.sanitizeBody(r, body, err)
+		}
+
+		rec := &recorder{ResponseWriter: w, limit: j.maxBody}
+		next.ServeHTTP(rec, r)
+
+		entry.Status = rec.status()
+		entry.Shape = Shape(rec.body.Bytes())
+		if rec.truncated {
+			entry.Shape = shapeUnknown
+		}
+		j.write(entry)
+	})
+}
+
+func (j *Journal) write(e *Entry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.enc.Encode(e); err != nil {
+		log.Printf("request journal: %s", err)
+	}
+}
+
+func (j *Journal) sanitizeQuery(q url.Values) string {
+	for key := range q {
+		if j.sensitive(key) {
+			q[key] = []string{redacted}
+		}
+	}
+	return q.Encode()
+}
+
+func (j *Journal) sanitizeBody(r *http.Request, body []byte, readErr error) (json.RawMessage, bool) {
+	if len(body) == 0 && readErr == nil {
+		return nil, false
+	}
+	if readErr != nil || int64(len(body)) > j.maxBody {
+		return nil, true
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err != nil || mediaType != "application/json" {
+			return nil, true
+		}
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, true
+	}
+	b, err := json.Marshal(j.redactValue(v))
+	if err != nil {
+		return nil, true
+	}
+	return b, false
+}
+
+func (j *Journal) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if j.sensitive(key) {
+				v[key] = redacted
+			} else {
+				v[key] = j.redactValue(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = j.redactValue(value)
+		}
+	}
+	return v
+}
+
+// sensitive reports whether a field or parameter name contains one of the
+// redacted names.
+func (j *Journal) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range j.redact {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder passes the response through, keeping its status and up to limit
+// bytes of its body.
+type recorder struct {
+	http.ResponseWriter
+	limit     int64
+	code      int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.code == 0 {
+		rec.code = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	if !rec.truncated {
+		if int64(rec.body.Len()+len(b)) > rec.limit {
+			rec.truncated = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *recorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+	return rec.code
+}
@@ -0,0 +1,189 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// shapeUnknown is journaled for responses too large to keep.
+const shapeUnknown = "unknown"
+
+// Shape describes the structure of a JSON body without its values, e.g.
+// [{"id":string,"tags":[string]}], so responses can be compared across
+// deployments holding different data. Arrays take the shape of their first
+// element. Bodies that are not JSON have the shape "non-json", and empty
+// ones an empty shape.
+func Shape(body []byte) string {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return ""
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "non-json"
+	}
+
+	var b strings.Builder
+	shape(&b, v)
+	return b.String()
+}
+
+func shape(b *strings.Builder, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%q:", k)
+			shape(b, v[k])
+		}
+		b.WriteByte('}')
+	case []any:
+		b.WriteByte('[')
+		if len(v) > 0 {
+			shape(b, v[0])
+		}
+		b.WriteByte(']')
+	case string:
+		b.WriteString("string")
+	case float64:
+		b.WriteString("number")
+	case bool:
+		b.WriteString("boolean")
+	case nil:
+		b.WriteString("null")
+	}
+}
+
+// Read decodes a journal, one entry per line.
+func Read(r io.Reader) ([]*Entry, error) {
+	var entries []*Entry
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("replay: line %d: %w", line, err)
+		}
+		entries = append(entries, &e)
+	}
+	return entries, sc.Err()
+}
+
+// Diff is a journaled request whose replay answered differently.
+type Diff struct {
+	Entry  *Entry
+	Status int
+	Shape  string
+	Err    error
+}
+
+func (d *Diff) String() string {
+	target := d.Entry.Method + " " + d.Entry.Path
+	if d.Entry.Query != "" {
+		target += "?" + d.Entry.Query
+	}
+
+	if d.Err != nil {
+		return fmt.Sprintf("%s: %s", target, d.Err)
+	}
+
+	var changes []string
+	if d.Status != d.Entry.Status {
+		changes = append(changes, fmt.Sprintf("status %d -> %d", d.Entry.Status, d.Status))
+	}
+	if d.Shape != d.Entry.Shape {
+		changes = append(changes, fmt.Sprintf("shape %s -> %s", d.Entry.Shape, d.Shape))
+	}
+	return fmt.Sprintf("%s: %s", target, strings.Join(changes, ", "))
+}
+
+// Replayer sends journaled requests to Target, a base URL such as
+// http://staging:8080.
+type Replayer struct {
+	Target string
+	Client *http.Client
+	// Methods limits replay to these methods, so that writes are only
+	// replayed when asked for.
+	Methods []string
+}
+
+// Replay replays the entries in order, returning those that got a different
+// status or response shape. Entries whose body was not journaled or whose
+// method is not replayed are skipped.
+func (rp *Replayer) Replay(ctx context.Context, entries []*Entry) (diffs []*Diff, replayed int) {
+	for _, e := range entries {
+		if e.BodyOmitted || !slices.Contains(rp.Methods, e.Method) {
+			continue
+		}
+		replayed++
+
+		if d := rp.replay(ctx, e); d != nil {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs, replayed
+}
+
+func (rp *Replayer) replay(ctx context.Context, e *Entry) *Diff {
+	target := strings.TrimSuffix(rp.Target, "/") + e.Path
+	if e.Query != "" {
+		target += "?" + e.Query
+	}
+
+	var body io.Reader
+	if len(e.Body) > 0 {
+		body = strings.NewReader(string(e.Body))
+	}
+	req, err := http.NewRequestWithContext(ctx, e.Method, target, body)
+	if err != nil {
+		return &Diff{Entry: e, Err: err}
+	}
+	for name, value := range e.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := rp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return &Diff{Entry: e, Err: err}
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return &Diff{Entry: e, Err: err}
+	}
+
+	d := &Diff{Entry: e, Status: res.StatusCode, Shape: Shape(b)}
+	if e.Shape == shapeUnknown {
+		d.Shape = shapeUnknown
+	}
+	if d.Status == e.Status && d.Shape == e.Shape {
+		return nil
+	}
+	return d
+}
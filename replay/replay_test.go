@@ -0,0 +1,114 @@
+package replay_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hello/config"
+	"hello/replay"
+	testUtil "hello/util/test"
+)
+
+func TestShape(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{``, ``},
+		{`not json`, `non-json`},
+		{`{"b": 1, "a": ["x", "y"], "c": null}`, `{"a":[string],"b":number,"c":null}`},
+		{`[{"ok": true}, {"other": 1}]`, `[{"ok":boolean}]`},
+		{`[]`, `[]`},
+	}
+	for _, tc := range tests {
+		testUtil.Equal(t, tc.want, replay.Shape([]byte(tc.body)))
+	}
+}
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := replay.Open(&config.ConfJournal{
+		Path:         path,
+		SampleRate:   1,
+		MaxBody:      1024,
+		RedactFields: []string{"password", "signature"},
+	})
+	testUtil.NoError(t, err)
+	defer j.Close()
+
+	var gotBody string
+	h := j.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Write([]byte(`{"id": "1", "title": "Dune"}`))
+	}))
+
+	do := func() {
+		req := httptest.NewRequest(http.MethodPost, "/v1/books?signature=abc&page=2", strings.NewReader(`{"title": "Dune", "password": "hunter2"}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Tenant-ID", "acme")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	do()
+	j.SetEnabled(true)
+	do()
+	testUtil.Equal(t, `{"title": "Dune", "password": "hunter2"}`, gotBody)
+
+	f, err := os.Open(path)
+	testUtil.NoError(t, err)
+	defer f.Close()
+	entries, err := replay.Read(f)
+	testUtil.NoError(t, err)
+
+	testUtil.Equal(t, 1, len(entries))
+	e := entries[0]
+	testUtil.Equal(t, "/v1/books", e.Path)
+	testUtil.Equal(t, "page=2&signature=%5Bredacted%5D", e.Query)
+	testUtil.Equal(t, `{"password":"[redacted]","title":"Dune"}`, string(e.Body))
+	testUtil.Equal(t, "", e.Headers["Authorization"])
+	testUtil.Equal(t, "acme", e.Headers["X-Tenant-ID"])
+	testUtil.Equal(t, http.StatusOK, e.Status)
+	testUtil.Equal(t, `{"id":string,"title":string}`, e.Shape)
+}
+
+func TestReplayer_Replay(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/books":
+			w.Write([]byte(`[{"id": "2", "title": "Emma"}]`))
+		case "/v1/books/1":
+			w.Write([]byte(`{"id": 1}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	entries := []*replay.Entry{
+		{Method: http.MethodGet, Path: "/v1/books", Status: http.StatusOK, Shape: `[{"id":string,"title":string}]`},
+		{Method: http.MethodGet, Path: "/v1/books/1", Status: http.StatusOK, Shape: `{"id":string}`},
+		{Method: http.MethodGet, Path: "/v1/books/facets", Status: http.StatusOK, Shape: `{}`},
+		{Method: http.MethodPost, Path: "/v1/books", Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/v1/books", BodyOmitted: true},
+	}
+
+	rp := &replay.Replayer{Target: srv.URL, Methods: []string{http.MethodGet}}
+	diffs, replayed := rp.Replay(context.Background(), entries)
+	testUtil.Equal(t, 3, replayed)
+	testUtil.Equal(t, 2, len(diffs))
+	testUtil.Equal(t, `GET /v1/books/1: shape {"id":string} -> {"id":number}`, diffs[0].String())
+	testUtil.Equal(t, `GET /v1/books/facets: status 200 -> 500, shape {} -> `, diffs[1].String())
+}
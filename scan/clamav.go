@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const clamAVChunkSize = 32 * 1024
+
+// ClamAV scans content with a clamd daemon over TCP using the INSTREAM
+// command.
+type ClamAV struct {
+	addr    string
+	timeout time.Duration
+}
+
+func NewClamAV(addr string, timeout time.Duration) *ClamAV {
+	return &ClamAV{addr: addr, timeout: timeout}
+}
+
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return Result{}, fmt.Errorf("clamav: dial: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("clamav: write: %w", err)
+	}
+
+	buf := make([]byte, 4+clamAVChunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return Result{}, fmt.Errorf("clamav: write: %w", err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return Result{}, fmt.Errorf("clamav: write: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return Result{}, fmt.Errorf("clamav: read: %w", err)
+	}
+	return parseClamAVReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamAVReply reads replies of the form "stream: OK",
+// "stream: <signature> FOUND" and "<message> ERROR".
+func parseClamAVReply(reply string) (Result, error) {
+	switch {
+	case strings.HasSuffix(reply, " FOUND"):
+		sig := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return Result{Infected: true, Signature: sig}, nil
+	case strings.HasSuffix(reply, ": OK"):
+		return Result{}, nil
+	default:
+		return Result{}, fmt.Errorf("clamav: %s", reply)
+	}
+}
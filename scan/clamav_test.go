@@ -0,0 +1,85 @@
+package scan_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"hello/scan"
+	testUtil "hello/util/test"
+)
+
+// fakeClamd answers a single INSTREAM session, reporting content containing
+// "EICAR" as infected.
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	testUtil.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			r := bufio.NewReader(conn)
+			if cmd, _ := r.ReadString(0); cmd != "zINSTREAM\x00" {
+				conn.Close()
+				continue
+			}
+
+			var content bytes.Buffer
+			for {
+				var size uint32
+				if err := binary.Read(r, binary.BigEndian, &size); err != nil || size == 0 {
+					break
+				}
+				io.CopyN(&content, r, int64(size))
+			}
+
+			if bytes.Contains(content.Bytes(), []byte("EICAR")) {
+				conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestClamAV_Scan(t *testing.T) {
+	t.Parallel()
+
+	c := scan.NewClamAV(fakeClamd(t), time.Second)
+
+	tests := []struct {
+		name      string
+		content   string
+		infected  bool
+		signature string
+	}{
+		{"clean", "sample chapter", false, ""},
+		{"infected", "X5O!P%@AP EICAR test", true, "Eicar-Signature"},
+		{"large clean", strings.Repeat("a", 100*1024), false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := c.Scan(context.Background(), strings.NewReader(tt.content))
+			testUtil.NoError(t, err)
+			testUtil.Equal(t, tt.infected, res.Infected)
+			testUtil.Equal(t, tt.signature, res.Signature)
+		})
+	}
+}
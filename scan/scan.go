@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"context"
+	"io"
+
+	"hello/config"
+)
+
+// Result is the outcome of scanning a file.
+type Result struct {
+	Infected bool
+	// Signature names the detected threat when Infected is set.
+	Signature string
+}
+
+// Scanner checks uploaded content for malware.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// ScannerFunc adapts a function to the Scanner interface.
+type ScannerFunc func(ctx context.Context, r io.Reader) (Result, error)
+
+func (f ScannerFunc) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return f(ctx, r)
+}
+
+// Nop reports every file as clean. It is used when no scanner is configured.
+var Nop Scanner = ScannerFunc(func(context.Context, io.Reader) (Result, error) {
+	return Result{}, nil
+})
+
+// New returns the ClamAV scanner when an address is configured, and Nop
+// otherwise.
+func New(conf *config.ConfScan) Scanner {
+	if conf.ClamAVAddr == "" {
+		return Nop
+	}
+	return NewClamAV(conf.ClamAVAddr, conf.Timeout)
+}
@@ -0,0 +1,144 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Bleve is an embedded, on-disk index for deployments that do not run a
+// separate search cluster.
+type Bleve struct {
+	path string
+
+	mu    sync.RWMutex
+	index bleve.Index
+}
+
+func NewBleve(path string) (*Bleve, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, bleve.NewIndexMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bleve{path: path, index: index}, nil
+}
+
+func (b *Bleve) Index(_ context.Context, doc Document) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.index.Index(doc.ID, doc)
+}
+
+func (b *Bleve) Delete(_ context.Context, id string) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.index.Delete(id)
+}
+
+func (b *Bleve) Search(ctx context.Context, query string, limit int) ([]Hit, error) {
+	if query == "" {
+		return nil, ErrInvalidQuery
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewMatchQuery(query), limit, 0, false)
+	req.Highlight = bleve.NewHighlight()
+
+	b.mu.RLock()
+	res, err := b.index.SearchInContext(ctx, req)
+	b.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, len(res.Hits))
+	for i, h := range res.Hits {
+		hits[i] = Hit{ID: h.ID, Score: h.Score, Fragments: h.Fragments}
+	}
+
+	return hits, nil
+}
+
+// Rebuild re-indexes docs in a single batch into a new index next to the
+// current one, which is only replaced once the new one is complete. If the
+// rebuild fails the current index is kept as it was.
+func (b *Bleve) Rebuild(_ context.Context, docs []Document) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tmp := b.path + ".rebuild"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := build(tmp, docs); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	if err := b.index.Close(); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	// Whether or not the new index took the place of the old, the one at
+	// the path is opened again.
+	swapErr := replaceDir(b.path, tmp)
+	index, err := bleve.Open(b.path)
+	if err != nil {
+		return err
+	}
+	b.index = index
+	return swapErr
+}
+
+// build creates an index of docs at path.
+func build(path string, docs []Document) error {
+	index, err := bleve.New(path, bleve.NewIndexMapping())
+	if err != nil {
+		return err
+	}
+
+	batch := index.NewBatch()
+	for _, doc := range docs {
+		if err := batch.Index(doc.ID, doc); err != nil {
+			index.Close()
+			return err
+		}
+	}
+	if err := index.Batch(batch); err != nil {
+		index.Close()
+		return err
+	}
+	return index.Close()
+}
+
+// replaceDir moves the directory src to dst, replacing it. dst is moved
+// aside first and put back if src cannot take its place.
+func replaceDir(dst, src string) error {
+	old := dst + ".old"
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, old); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		os.Rename(old, dst)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+func (b *Bleve) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.index.Close()
+}
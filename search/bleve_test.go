@@ -0,0 +1,43 @@
+package search_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"hello/search"
+	testUtil "hello/util/test"
+)
+
+func TestBleve(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	idx, err := search.NewBleve(filepath.Join(t.TempDir(), "books.bleve"))
+	testUtil.NoError(t, err)
+	defer idx.Close()
+
+	testUtil.NoError(t, idx.Index(ctx, search.Document{ID: "1", Title: "The Hobbit", Author: "Tolkien"}))
+	testUtil.NoError(t, idx.Index(ctx, search.Document{ID: "2", Title: "Dune", Author: "Herbert"}))
+
+	hits, err := idx.Search(ctx, "hobbit", 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(hits))
+	testUtil.Equal(t, "1", hits[0].ID)
+
+	testUtil.NoError(t, idx.Rebuild(ctx, []search.Document{{ID: "2", Title: "Dune", Author: "Herbert"}}))
+
+	hits, err = idx.Search(ctx, "hobbit", 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 0, len(hits))
+
+	// A failed rebuild keeps the index as it was.
+	testUtil.Equal(t, true, idx.Rebuild(ctx, []search.Document{{Title: "The Hobbit"}}) != nil)
+	hits, err = idx.Search(ctx, "dune", 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(hits))
+	testUtil.NoError(t, idx.Index(ctx, search.Document{ID: "3", Title: "Emma", Author: "Austen"}))
+
+	_, err = idx.Search(ctx, "", 10)
+	testUtil.Equal(t, search.ErrInvalidQuery, err)
+}
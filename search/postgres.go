@@ -0,0 +1,186 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Highlight markers around matched terms in fragments.
+const (
+	markStart = "<mark>"
+	markStop  = "</mark>"
+)
+
+// Postgres searches the books table itself, through the search_vector
+// column the database keeps up to date on every write and its GIN index.
+// Writes therefore need no indexing, and Index, Delete and Rebuild do
+// nothing.
+//
+// On databases other than Postgres, such as SQLite in tests, it falls back
+// to matching every word of the query with LIKE, ranking books by the
+// fields the words are found in.
+type Postgres struct {
+	db *gorm.DB
+}
+
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Index(context.Context, Document) error { return nil }
+
+func (p *Postgres) Delete(context.Context, string) error { return nil }
+
+func (p *Postgres) Rebuild(context.Context, []Document) error { return nil }
+
+func (p *Postgres) Close() error { return nil }
+
+// match is a book found by a query, with its fields highlighted.
+type match struct {
+	ID          string
+	Score       float64
+	Title       string
+	Author      string
+	Description string
+}
+
+func (m *match) hit() Hit {
+	h := Hit{ID: m.ID, Score: m.Score, Fragments: map[string][]string{}}
+	for field, fragment := range map[string]string{"title": m.Title, "author": m.Author, "description": m.Description} {
+		if strings.Contains(fragment, markStart) {
+			h.Fragments[field] = []string{fragment}
+		}
+	}
+	return h
+}
+
+func (p *Postgres) Search(ctx context.Context, query string, limit int) ([]Hit, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrInvalidQuery
+	}
+
+	db := p.db.WithContext(ctx)
+	var matches []*match
+	var err error
+	if db.Dialector.Name() == "postgres" {
+		matches, err = searchPostgres(db, query, limit)
+	} else {
+		matches, err = searchLike(db, query, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, len(matches))
+	for i, m := range matches {
+		hits[i] = m.hit()
+	}
+	return hits, nil
+}
+
+// searchPostgres ranks by cover density, which favours books where the
+// words of the query appear close together, weighted by field: title over
+// author over description. Fragments are computed for the returned page
+// only, as ts_headline reads the whole text.
+func searchPostgres(db *gorm.DB, query string, limit int) ([]*match, error) {
+	var matches []*match
+	err := db.Raw(`
+SELECT id, score,
+       ts_headline('english', title, q, @options)                     AS title,
+       ts_headline('english', author, q, @options)                    AS author,
+       ts_headline('english', COALESCE(description, ''), q, @options) AS description
+FROM (SELECT id, title, author, description, q, ts_rank_cd(search_vector, q) AS score
+      FROM books, websearch_to_tsquery('english', @query) q
+      WHERE deleted_at IS NULL AND search_vector @@ q
+      ORDER BY score DESC, id
+      LIMIT @limit) ranked
+ORDER BY score DESC, id`, map[string]any{
+		"query":   query,
+		"limit":   limit,
+		"options": "StartSel=" + markStart + ", StopSel=" + markStop + ", MaxFragments=2",
+	}).Scan(&matches).Error
+	return matches, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeWeights rank matches of title, author and description in the LIKE
+// fallback as the weights of the search_vector column do in Postgres.
+var likeWeights = [3]float64{1, 0.4, 0.2}
+
+func searchLike(db *gorm.DB, query string, limit int) ([]*match, error) {
+	words := strings.Fields(strings.ToLower(query))
+
+	q := db.Table("books").
+		Select("id, title, author, COALESCE(description, '') AS description").
+		Where("deleted_at IS NULL")
+	for _, w := range words {
+		pattern := "%" + likeEscaper.Replace(w) + "%"
+		q = q.Where(`(LOWER(title) LIKE ? ESCAPE '\' OR LOWER(author) LIKE ? ESCAPE '\' OR LOWER(COALESCE(description, '')) LIKE ? ESCAPE '\')`,
+			pattern, pattern, pattern)
+	}
+	var matches []*match
+	if err := q.Scan(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	for _, m := range matches {
+		for i, text := range [3]*string{&m.Title, &m.Author, &m.Description} {
+			for _, w := range words {
+				if strings.Contains(strings.ToLower(*text), w) {
+					m.Score += likeWeights[i]
+				}
+			}
+			*text = highlight(*text, words)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches[:min(limit, len(matches))], nil
+}
+
+// highlight marks the case-insensitive occurrences of words in text.
+func highlight(text string, words []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Lowercasing changed byte offsets; leave the text unmarked rather
+		// than mark the wrong bytes.
+		return text
+	}
+
+	marked := make([]bool, len(text))
+	for _, w := range words {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], w)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(w); k++ {
+				marked[k] = true
+			}
+			i += j + len(w)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(markStart)
+		}
+		if !marked[i] && i > 0 && marked[i-1] {
+			b.WriteString(markStop)
+		}
+		b.WriteByte(text[i])
+	}
+	if len(text) > 0 && marked[len(text)-1] {
+		b.WriteString(markStop)
+	}
+	return b.String()
+}
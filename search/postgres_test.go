@@ -0,0 +1,54 @@
+package search_test
+
+import (
+	"context"
+	"testing"
+
+	mockDB "hello/mock/db"
+	"hello/search"
+	testUtil "hello/util/test"
+)
+
+func TestPostgres_LikeFallback(t *testing.T) {
+	t.Parallel()
+
+	db := mockDB.NewSQLite(t)
+	testUtil.NoError(t, db.Exec(`CREATE TABLE books (id TEXT PRIMARY KEY, title TEXT, author TEXT, description TEXT, deleted_at DATETIME)`).Error)
+	testUtil.NoError(t, db.Exec(`INSERT INTO books (id, title, author, description, deleted_at) VALUES
+		('1', 'Dune', 'Frank Herbert', 'A desert planet', NULL),
+		('2', 'Children of Dune', 'Frank Herbert', NULL, NULL),
+		('3', 'The Hobbit', 'J.R.R. Tolkien', 'There and back again, not to the dunes', NULL),
+		('4', 'Dune Messiah', 'Frank Herbert', NULL, CURRENT_TIMESTAMP),
+		('5', '100% Dune', 'Nobody', NULL, NULL)`).Error)
+
+	ctx := context.Background()
+	idx := search.NewPostgres(db)
+
+	// Every word must match; title matches rank above description ones.
+	hits, err := idx.Search(ctx, "DUNE herbert", 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 2, len(hits))
+	testUtil.Equal(t, "1", hits[0].ID)
+	testUtil.Equal(t, "<mark>Dune</mark>", hits[0].Fragments["title"][0])
+	testUtil.Equal(t, "Frank <mark>Herbert</mark>", hits[0].Fragments["author"][0])
+	testUtil.Equal(t, 0, len(hits[0].Fragments["description"]))
+
+	hits, err = idx.Search(ctx, "dune", 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 4, len(hits))
+	testUtil.Equal(t, "3", hits[3].ID)
+	testUtil.Equal(t, "There and back again, not to the <mark>dune</mark>s", hits[3].Fragments["description"][0])
+
+	hits, err = idx.Search(ctx, "dune", 1)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(hits))
+
+	// LIKE wildcards in the query are matched literally.
+	hits, err = idx.Search(ctx, "100%", 10)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, len(hits))
+	testUtil.Equal(t, "5", hits[0].ID)
+
+	_, err = idx.Search(ctx, "  ", 10)
+	testUtil.Equal(t, search.ErrInvalidQuery, err)
+}
@@ -0,0 +1,60 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"hello/config"
+)
+
+const (
+	BackendNone     = "none"
+	BackendBleve    = "bleve"
+	BackendPostgres = "postgres"
+)
+
+var ErrInvalidQuery = errors.New("search: invalid query")
+
+// Document is the searchable projection of a book.
+type Document struct {
+	ID          string
+	Title       string
+	Author      string
+	Description string
+}
+
+// Hit is a single ranked match. Fragments holds highlighted snippets keyed by
+// field name, when the backend supports highlighting.
+type Hit struct {
+	ID        string
+	Score     float64
+	Fragments map[string][]string
+}
+
+// Index is implemented by every search backend.
+type Index interface {
+	Index(ctx context.Context, doc Document) error
+	Delete(ctx context.Context, id string) error
+	Search(ctx context.Context, query string, limit int) ([]Hit, error)
+	// Rebuild replaces the whole index content with docs.
+	Rebuild(ctx context.Context, docs []Document) error
+	Close() error
+}
+
+// New opens the backend selected in c. It returns a nil Index when search is
+// disabled. The database is only used by the postgres backend.
+func New(c *config.ConfSearch, db *gorm.DB) (Index, error) {
+	switch c.Backend {
+	case BackendNone, "":
+		return nil, nil
+	case BackendBleve:
+		return NewBleve(c.BlevePath)
+	case BackendPostgres:
+		return NewPostgres(db), nil
+	default:
+		return nil, fmt.Errorf("search: unknown backend %q", c.Backend)
+	}
+}
@@ -0,0 +1,214 @@
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/config"
+)
+
+// Manager issues sessions in cookies signed with HMAC-SHA256 and resolves
+// them on later requests.
+type Manager struct {
+	store Store
+	key   []byte
+	conf  *config.ConfSession
+}
+
+func NewManager(store Store, key []byte, c *config.ConfSession) *Manager {
+	return &Manager{store: store, key: key, conf: c}
+}
+
+// NewManagerFromConfig builds a manager from conf, generating a random key
+// when none is configured.
+func NewManagerFromConfig(store Store, conf *config.ConfSession) *Manager {
+	key := []byte(conf.Key)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("session: generate key: %s", err)
+		}
+		log.Println("SESSION_KEY not set, sessions are only valid on this instance until restart")
+	}
+	return NewManager(store, key, conf)
+}
+
+// Start creates a session for userID and sets its cookie.
+func (m *Manager) Start(ctx context.Context, w http.ResponseWriter, userID uuid.UUID) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	s := &Session{UserID: userID, CreatedAt: now, ExpiresAt: m.expiry(now, now)}
+	if err := m.store.Save(ctx, hashToken(token), s); err != nil {
+		return err
+	}
+
+	m.setCookie(w, token+"."+m.sign(token), s.ExpiresAt)
+	return nil
+}
+
+// End deletes the request's session, if any, and clears its cookie.
+func (m *Manager) End(w http.ResponseWriter, r *http.Request) error {
+	defer m.clearCookie(w)
+
+	token, ok := m.token(r)
+	if !ok {
+		return nil
+	}
+	return m.store.Delete(r.Context(), hashToken(token))
+}
+
+// RevokeUser ends all sessions of a user.
+func (m *Manager) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	return m.store.DeleteUser(ctx, userID)
+}
+
+// Middleware signs in requests carrying a valid session cookie, unless the
+// gateway already identified the user. Sessions used past half their idle
+// timeout are extended. Invalid or expired cookies are cleared and the
+// request proceeds anonymously.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := user.From(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := r.Cookie(m.conf.CookieName); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := m.token(r)
+		if !ok {
+			m.clearCookie(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id := hashToken(token)
+		s, err := m.store.Get(r.Context(), id)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				log.Printf("session: %s", err)
+			}
+			m.clearCookie(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		if s.ExpiresAt.Sub(now) < m.conf.TTL/2 {
+			if expiresAt := m.expiry(s.CreatedAt, now); expiresAt.After(s.ExpiresAt) {
+				s.ExpiresAt = expiresAt
+				if err := m.store.Save(r.Context(), id, s); err != nil {
+					log.Printf("session: %s", err)
+				} else {
+					m.setCookie(w, token+"."+m.sign(token), s.ExpiresAt)
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(user.WithID(r.Context(), s.UserID)))
+	})
+}
+
+// Run removes expired sessions every interval until ctx is done.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.store.DeleteExpired(ctx, time.Now()); err != nil {
+				log.Printf("session sweep: %s", err)
+			}
+		}
+	}
+}
+
+// expiry is the idle timeout from now, capped at the maximum lifetime of a
+// session created at created.
+func (m *Manager) expiry(created, now time.Time) time.Time {
+	expiresAt := now.Add(m.conf.TTL)
+	if limit := created.Add(m.conf.MaxLifetime); expiresAt.After(limit) {
+		return limit
+	}
+	return expiresAt
+}
+
+// token returns the token of the request's cookie if its signature holds.
+func (m *Manager) token(r *http.Request) (string, bool) {
+	c, err := r.Cookie(m.conf.CookieName)
+	if err != nil {
+		return "", false
+	}
+
+	token, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(m.sign(token))) {
+		return "", false
+	}
+	return token, true
+}
+
+func (m *Manager) sign(token string) string {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, value string, expires time.Time) {
+	http.SetCookie(w, m.cookie(value, expires, int(time.Until(expires).Seconds())))
+}
+
+func (m *Manager) clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, m.cookie("", time.Unix(0, 0), -1))
+}
+
+func (m *Manager) cookie(value string, expires time.Time, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.conf.CookieName,
+		Value:    value,
+		Path:     "/",
+		Domain:   m.conf.CookieDomain,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		Secure:   m.conf.CookieSecure,
+		HttpOnly: true,
+		SameSite: sameSite(m.conf.CookieSameSite),
+	}
+}
+
+func sameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
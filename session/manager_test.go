@@ -0,0 +1,124 @@
+package session_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/api/middleware/user"
+	"hello/config"
+	"hello/session"
+	testUtil "hello/util/test"
+)
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	conf := &config.ConfSession{
+		TTL:          time.Hour,
+		MaxLifetime:  24 * time.Hour,
+		CookieName:   "session",
+		CookieSecure: true,
+	}
+	store := session.NewMemory()
+	m := session.NewManager(store, []byte("key"), conf)
+
+	userID := uuid.New()
+	w := httptest.NewRecorder()
+	testUtil.NoError(t, m.Start(context.Background(), w, userID))
+
+	cookies := w.Result().Cookies()
+	testUtil.Equal(t, 1, len(cookies))
+	cookie := cookies[0]
+	testUtil.Equal(t, true, cookie.HttpOnly)
+	testUtil.Equal(t, true, cookie.Secure)
+	testUtil.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := user.From(r.Context()); ok {
+			w.Write([]byte(id.String()))
+		}
+	}))
+	serve := func(value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if value != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: value})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		user  string
+	}{
+		{"valid", cookie.Value, userID.String()},
+		{"tampered", cookie.Value + "x", ""},
+		{"unsigned", "token", ""},
+		{"none", "", ""},
+	}
+	for _, tc := range tests {
+		w := serve(tc.value)
+		testUtil.Equal(t, tc.user, w.Body.String())
+	}
+
+	testUtil.NoError(t, m.RevokeUser(context.Background(), userID))
+	w = serve(cookie.Value)
+	testUtil.Equal(t, "", w.Body.String())
+	testUtil.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+}
+
+// recordingStore remembers the IDs sessions were saved under.
+type recordingStore struct {
+	*session.Memory
+	ids []string
+}
+
+func (s *recordingStore) Save(ctx context.Context, id string, sess *session.Session) error {
+	s.ids = append(s.ids, id)
+	return s.Memory.Save(ctx, id, sess)
+}
+
+func TestManagerSlidingExpiry(t *testing.T) {
+	t.Parallel()
+
+	conf := &config.ConfSession{TTL: time.Hour, MaxLifetime: 24 * time.Hour, CookieName: "session"}
+	store := &recordingStore{Memory: session.NewMemory()}
+	m := session.NewManager(store, []byte("key"), conf)
+
+	w := httptest.NewRecorder()
+	testUtil.NoError(t, m.Start(context.Background(), w, uuid.New()))
+	cookie := w.Result().Cookies()[0]
+
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+
+	// Fresh sessions are not extended.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	testUtil.Equal(t, 0, len(w.Result().Cookies()))
+
+	// Past half their idle timeout they are.
+	id := store.ids[0]
+	s, err := store.Get(context.Background(), id)
+	testUtil.NoError(t, err)
+	s.ExpiresAt = time.Now().Add(10 * time.Minute)
+	testUtil.NoError(t, store.Save(context.Background(), id, s))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	testUtil.Equal(t, 1, len(w.Result().Cookies()))
+	testUtil.Equal(t, true, w.Result().Cookies()[0].MaxAge > int((50*time.Minute).Seconds()))
+
+	// Never beyond the maximum lifetime.
+	s, err = store.Get(context.Background(), id)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, false, s.ExpiresAt.After(s.CreatedAt.Add(conf.MaxLifetime)))
+}
@@ -0,0 +1,71 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory keeps sessions in process. They are lost on restart and not
+// shared between instances, which suits development and single instances.
+type Memory struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func NewMemory() *Memory {
+	return &Memory{sessions: make(map[string]Session)}
+}
+
+func (m *Memory) Get(_ context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok || !time.Now().Before(s.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (m *Memory) Save(_ context.Context, id string, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[id] = *s
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *Memory) DeleteUser(_ context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteExpired(_ context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Record is a session row of the Postgres store.
+type Record struct {
+	ID        string `gorm:"primarykey"`
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (Record) TableName() string {
+	return "sessions"
+}
+
+// Postgres keeps sessions in the sessions table. Expired rows are removed
+// by DeleteExpired.
+type Postgres struct {
+	db *gorm.DB
+}
+
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Get(ctx context.Context, id string) (*Session, error) {
+	rec := &Record{}
+	err := p.db.WithContext(ctx).Where("id = ? AND expires_at > ?", id, time.Now()).First(rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Session{UserID: rec.UserID, CreatedAt: rec.CreatedAt, ExpiresAt: rec.ExpiresAt}, nil
+}
+
+func (p *Postgres) Save(ctx context.Context, id string, s *Session) error {
+	return p.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&Record{
+		ID:        id,
+		UserID:    s.UserID,
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+	}).Error
+}
+
+func (p *Postgres) Delete(ctx context.Context, id string) error {
+	return p.db.WithContext(ctx).Where("id = ?", id).Delete(&Record{}).Error
+}
+
+func (p *Postgres) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	return p.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&Record{}).Error
+}
+
+func (p *Postgres) DeleteExpired(ctx context.Context, now time.Time) error {
+	return p.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&Record{}).Error
+}
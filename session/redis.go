@@ -0,0 +1,95 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	redisUtil "hello/util/redis"
+)
+
+// Redis keeps sessions in Redis, which expires them on its own. Each
+// user's session IDs are indexed in a set so they can be revoked together;
+// the set is kept for maxLifetime after the last save, which outlasts every
+// session in it. Connections are pooled until the store is closed.
+type Redis struct {
+	client      *redis.Client
+	maxLifetime time.Duration
+}
+
+func NewRedis(addr, password string, maxLifetime time.Duration) *Redis {
+	return &Redis{client: redisUtil.New(addr, password), maxLifetime: maxLifetime}
+}
+
+func (r *Redis) Get(ctx context.Context, id string) (*Session, error) {
+	b, err := r.client.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *Redis) Save(ctx context.Context, id string, s *Session) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	ttl := max(time.Millisecond, time.Until(s.ExpiresAt))
+	userKey := userKey(s.UserID)
+	_, err = r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, sessionKey(id), b, ttl)
+		p.SAdd(ctx, userKey, id)
+		p.PExpire(ctx, userKey, r.maxLifetime)
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) Delete(ctx context.Context, id string) error {
+	return r.client.Del(ctx, sessionKey(id)).Err()
+}
+
+func (r *Redis) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	ids, err := r.client.SMembers(ctx, userKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{userKey(userID)}
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id))
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// Close closes the connections to Redis.
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// DeleteExpired does nothing, as Redis expires keys itself. Members of a
+// user's set may point at expired keys until the set expires too.
+func (r *Redis) DeleteExpired(context.Context, time.Time) error {
+	return nil
+}
+
+func sessionKey(id string) string {
+	return "session:" + id
+}
+
+func userKey(id uuid.UUID) string {
+	return "session:user:" + id.String()
+}
@@ -0,0 +1,41 @@
+package session_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"hello/session"
+	"hello/util/redis/redistest"
+	testUtil "hello/util/test"
+)
+
+func TestRedis(t *testing.T) {
+	t.Parallel()
+
+	addr := redistest.StartAuth(t, "secret").Addr
+	ctx := context.Background()
+	store := session.NewRedis(addr, "secret", 24*time.Hour)
+
+	userID := uuid.New()
+	s := &session.Session{UserID: userID, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	testUtil.NoError(t, store.Save(ctx, "a", s))
+	testUtil.NoError(t, store.Save(ctx, "b", s))
+
+	got, err := store.Get(ctx, "a")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, userID, got.UserID)
+
+	testUtil.NoError(t, store.Delete(ctx, "a"))
+	_, err = store.Get(ctx, "a")
+	testUtil.Equal(t, session.ErrNotFound, err)
+
+	testUtil.NoError(t, store.DeleteUser(ctx, userID))
+	_, err = store.Get(ctx, "b")
+	testUtil.Equal(t, session.ErrNotFound, err)
+
+	_, err = session.NewRedis(addr, "wrong", time.Hour).Get(ctx, "b")
+	testUtil.Equal(t, true, err != nil && err != session.ErrNotFound)
+}
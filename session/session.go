@@ -0,0 +1,62 @@
+// Package session keeps browser sessions in a pluggable store and carries
+// them in signed cookies.
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"hello/config"
+)
+
+const (
+	StoreMemory   = "memory"
+	StoreRedis    = "redis"
+	StorePostgres = "postgres"
+)
+
+var ErrNotFound = errors.New("session: not found")
+
+// Session is a signed-in user's session. ExpiresAt moves forward while the
+// session is used, but never beyond the configured maximum lifetime from
+// CreatedAt.
+type Session struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Store keeps sessions by ID. IDs are hashes of the cookie tokens, so a
+// leaked store does not yield usable cookies.
+type Store interface {
+	// Get returns the unexpired session id, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Session, error)
+	// Save stores s under id until s.ExpiresAt, replacing any existing one.
+	Save(ctx context.Context, id string, s *Session) error
+	// Delete removes session id. Deleting a missing session is not an error.
+	Delete(ctx context.Context, id string) error
+	// DeleteUser removes all sessions of a user.
+	DeleteUser(ctx context.Context, userID uuid.UUID) error
+	// DeleteExpired removes sessions that expired before now, for stores
+	// that do not expire them on their own.
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// NewStore returns the store selected by conf.
+func NewStore(conf *config.ConfSession, db *gorm.DB) (Store, error) {
+	switch conf.Store {
+	case StoreMemory:
+		return NewMemory(), nil
+	case StoreRedis:
+		return NewRedis(conf.RedisAddr, conf.RedisPassword, conf.MaxLifetime), nil
+	case StorePostgres:
+		return NewPostgres(db), nil
+	default:
+		return nil, fmt.Errorf("session: unknown store %q", conf.Store)
+	}
+}
@@ -0,0 +1,64 @@
+package signedurl_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"hello/signedurl"
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	s := signedurl.New([]byte("secret"), time.Minute)
+	signed, _ := s.Sign("/v1/books/1/cover")
+
+	tests := []struct {
+		name string
+		url  string
+		err  error
+	}{
+		{"valid", signed, nil},
+		{"unsigned", "/v1/books/1/cover", signedurl.ErrInvalidSignature},
+		{"other path", strings.Replace(signed, "/1/", "/2/", 1), signedurl.ErrInvalidSignature},
+		{"tampered expiry", strings.Replace(signed, "expires=", "expires=9", 1), signedurl.ErrInvalidSignature},
+		{"other key", mustSign(signedurl.New([]byte("other"), time.Minute), "/v1/books/1/cover"), signedurl.ErrInvalidSignature},
+		{"expired", mustSign(signedurl.New([]byte("secret"), -time.Minute), "/v1/books/1/cover"), signedurl.ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Verify(httptest.NewRequest(http.MethodGet, tt.url, nil))
+			testUtil.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestSigner_StableURL(t *testing.T) {
+	t.Parallel()
+
+	s := signedurl.New([]byte("secret"), time.Hour)
+	store, err := storage.NewFS(t.TempDir())
+	testUtil.NoError(t, err)
+
+	u1, expires, err := s.StableURL(context.Background(), store, "key", "/v1/books/1/cover")
+	testUtil.NoError(t, err)
+	u2, _, err := s.StableURL(context.Background(), store, "key", "/v1/books/1/cover")
+	testUtil.NoError(t, err)
+
+	// The hour would have to turn between the two calls for this to fail.
+	testUtil.Equal(t, u1, u2)
+	testUtil.Equal(t, true, !expires.Before(time.Now().Add(time.Hour-time.Second)))
+	testUtil.NoError(t, s.Verify(httptest.NewRequest(http.MethodGet, u1, nil)))
+}
+
+func mustSign(s *signedurl.Signer, path string) string {
+	u, _ := s.Sign(path)
+	return u
+}
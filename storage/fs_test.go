@@ -0,0 +1,40 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, err := storage.NewFS(t.TempDir())
+	testUtil.NoError(t, err)
+
+	n, err := s.Put(ctx, "books/1/a", strings.NewReader("hello"))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, int64(5), n)
+
+	rc, err := s.Open(ctx, "books/1/a")
+	testUtil.NoError(t, err)
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "hello", string(b))
+
+	testUtil.NoError(t, s.Delete(ctx, "books/1/a"))
+	testUtil.NoError(t, s.Delete(ctx, "books/1/a"))
+
+	_, err = s.Open(ctx, "books/1/a")
+	testUtil.Equal(t, true, errors.Is(err, storage.ErrNotFound))
+
+	_, err = s.Put(ctx, "../escape", strings.NewReader("x"))
+	testUtil.Equal(t, true, err != nil)
+}
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPresignExpiry is the longest validity S3 accepts for presigned URLs.
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// S3Config locates a bucket of S3 or of a compatible store such as MinIO.
+// Without an Endpoint the AWS endpoint of Region is used. PathStyle puts
+// the bucket in the path rather than the host name, as most compatible
+// stores need.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool
+}
+
+// S3 stores objects in an S3 bucket, signing requests with AWS Signature
+// Version 4. It hands out presigned URLs, so downloads go to the bucket
+// directly.
+type S3 struct {
+	conf   S3Config
+	base   *url.URL
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3(conf S3Config) (*S3, error) {
+	if conf.Bucket == "" || conf.Region == "" {
+		return nil, errors.New("storage: s3 needs a bucket and a region")
+	}
+	endpoint := conf.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + conf.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("storage: invalid s3 endpoint %q", endpoint)
+	}
+	return &S3{conf: conf, base: base, client: &http.Client{}, now: time.Now}, nil
+}
+
+// Put spools r to a temporary file, as S3 needs the length and hash of the
+// content before it is sent.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	tmp, err := os.CreateTemp("", "s3-put-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(tmp, io.TeeReader(r, h))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), io.NopCloser(tmp))
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = n
+	s.sign(req, hex.EncodeToString(h.Sum(nil)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, s3Error(resp)
+	}
+	return n, nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error(resp)
+	}
+}
+
+// SignedURL presigns a download of key. URLs are signed as of the start of
+// the current ttl window and valid for two windows, so that every call in
+// a window returns the same URL, valid for at least ttl. Responses
+// embedding it keep their ETag for that long.
+func (s *S3) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	ttl = min(max(ttl, time.Second), maxPresignExpiry/2)
+	at := s.now().UTC().Truncate(ttl)
+
+	u := s.objectURL(key)
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.conf.AccessKeyID+"/"+s.scope(at))
+	q.Set("X-Amz-Date", at.Format("20060102T150405Z"))
+	q.Set("X-Amz-Expires", strconv.Itoa(int((2 * ttl).Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = canonicalQuery(q)
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(at, canonical)
+	return u.String(), nil
+}
+
+func (s *S3) do(ctx context.Context, method, key string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, emptySHA256)
+	return s.client.Do(req)
+}
+
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.base
+	path := "/" + strings.TrimPrefix(key, "/")
+	if s.conf.PathStyle {
+		path = "/" + s.conf.Bucket + path
+	} else {
+		u.Host = s.conf.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(s.base.Path, "/") + path
+	u.RawPath = ""
+	return &u
+}
+
+// sign adds the Authorization header of a request whose payload hashes to
+// payloadHash, signing the host and the x-amz headers.
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	at := s.now().UTC()
+	req.Header.Set("X-Amz-Date", at.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signed = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + req.Header.Get("X-Amz-Date") + "\n",
+		signed,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.conf.AccessKeyID+"/"+s.scope(at)+
+		", SignedHeaders="+signed+", Signature="+s.signature(at, canonical))
+}
+
+func (s *S3) scope(at time.Time) string {
+	return at.Format("20060102") + "/" + s.conf.Region + "/s3/aws4_request"
+}
+
+func (s *S3) signature(at time.Time, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + at.Format("20060102T150405Z") + "\n" + s.scope(at) + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.conf.SecretAccessKey), at.Format("20060102"))
+	key = hmacSHA256(key, s.conf.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes q sorted by name, escaping everything but the
+// unreserved characters as Signature Version 4 requires.
+func canonicalQuery(q url.Values) string {
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, v := range q[name] {
+			parts = append(parts, uriEncode(name)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("storage: s3 %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
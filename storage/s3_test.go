@@ -0,0 +1,110 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hello/storage"
+	testUtil "hello/util/test"
+)
+
+func TestS3(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			b, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(b)
+			if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = b
+		case http.MethodGet:
+			b, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(b)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	s, err := storage.NewS3(storage.S3Config{Endpoint: srv.URL, Region: "eu-west-1", Bucket: "covers", AccessKeyID: "AKID", SecretAccessKey: "secret", PathStyle: true})
+	testUtil.NoError(t, err)
+
+	n, err := s.Put(ctx, "books/1/a", strings.NewReader("hello"))
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, int64(5), n)
+	mu.Lock()
+	testUtil.Equal(t, "hello", string(objects["/covers/books/1/a"]))
+	mu.Unlock()
+
+	rc, err := s.Open(ctx, "books/1/a")
+	testUtil.NoError(t, err)
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "hello", string(b))
+
+	testUtil.NoError(t, s.Delete(ctx, "books/1/a"))
+	testUtil.NoError(t, s.Delete(ctx, "books/1/a"))
+	_, err = s.Open(ctx, "books/1/a")
+	testUtil.Equal(t, true, errors.Is(err, storage.ErrNotFound))
+
+	denied, err := storage.NewS3(storage.S3Config{Endpoint: srv.URL, Region: "eu-west-1", Bucket: "covers", AccessKeyID: "other", PathStyle: true})
+	testUtil.NoError(t, err)
+	_, err = denied.Open(ctx, "books/1/a")
+	testUtil.Equal(t, true, err != nil && !errors.Is(err, storage.ErrNotFound))
+}
+
+func TestS3_SignedURL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, err := storage.NewS3(storage.S3Config{Region: "us-east-1", Bucket: "examplebucket", AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	testUtil.NoError(t, err)
+
+	first, err := s.SignedURL(ctx, "blobs/ab/abc", time.Hour)
+	testUtil.NoError(t, err)
+	u, err := url.Parse(first)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "examplebucket.s3.us-east-1.amazonaws.com", u.Host)
+	testUtil.Equal(t, "/blobs/ab/abc", u.Path)
+	testUtil.Equal(t, "7200", u.Query().Get("X-Amz-Expires"))
+	testUtil.Equal(t, "host", u.Query().Get("X-Amz-SignedHeaders"))
+	testUtil.Equal(t, 64, len(u.Query().Get("X-Amz-Signature")))
+
+	// URLs are the same within a window.
+	second, err := s.SignedURL(ctx, "blobs/ab/abc", time.Hour)
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, first, second)
+
+	_, err = storage.NewS3(storage.S3Config{Region: "us-east-1"})
+	testUtil.Equal(t, true, err != nil)
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"hello/config"
+)
+
+const (
+	BackendFS = "fs"
+	BackendS3 = "s3"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+// Store keeps opaque blobs under caller-chosen keys.
+type Store interface {
+	// Put writes r under key, replacing any existing object, and returns the
+	// number of bytes written.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open returns the object under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key. Deleting a missing key is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// URLSigner is implemented by stores that can hand out time-limited URLs
+// for downloading an object directly from the store.
+type URLSigner interface {
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// New returns the store selected by conf.
+func New(conf *config.ConfStorage) (Store, error) {
+	switch conf.Backend {
+	case BackendFS:
+		return NewFS(conf.FSPath)
+	case BackendS3:
+		return NewS3(S3Config{
+			Endpoint:        conf.S3Endpoint,
+			Region:          conf.S3Region,
+			Bucket:          conf.S3Bucket,
+			AccessKeyID:     conf.S3AccessKeyID,
+			SecretAccessKey: conf.S3SecretAccessKey,
+			PathStyle:       conf.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", conf.Backend)
+	}
+}
@@ -0,0 +1,167 @@
+// Package telemetry reports anonymous, aggregate usage of the service: which
+// endpoints are used and how often, the database dialect, the version and
+// the enabled features. No request content, identifiers or addresses are
+// collected. Reporting is on by default and is turned off with
+// TELEMETRY_ENABLED=false or DO_NOT_TRACK=1.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"hello/buildinfo"
+)
+
+// Report is what is sent for each period.
+type Report struct {
+	// Instance is random per process, so reports of one run can be told
+	// apart without identifying the deployment.
+	Instance  string         `json:"instance"`
+	Version   string         `json:"version"`
+	GoVersion string         `json:"go_version"`
+	OS        string         `json:"os"`
+	Arch      string         `json:"arch"`
+	Dialect   string         `json:"db_dialect"`
+	Features  []string       `json:"features"`
+	Endpoints map[string]int `json:"endpoints"`
+	From      time.Time      `json:"from"`
+	To        time.Time      `json:"to"`
+}
+
+// Reporter counts requests by route and posts a report to its endpoint
+// every interval.
+type Reporter struct {
+	endpoint string
+	client   *http.Client
+	instance string
+	dialect  string
+	features []string
+
+	mu     sync.Mutex
+	counts map[string]int
+	since  time.Time
+}
+
+func New(endpoint string, timeout time.Duration, dialect string, features []string) *Reporter {
+	features = slices.Clone(features)
+	slices.Sort(features)
+
+	return &Reporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: timeout},
+		instance: uuid.NewString(),
+		dialect:  dialect,
+		features: features,
+		counts:   make(map[string]int),
+		since:    time.Now().UTC(),
+	}
+}
+
+// Middleware counts requests by method and route pattern, e.g.
+// "GET /v1/books/{id}". Requests that matched no route are not counted.
+func (rp *Reporter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil {
+			return
+		}
+		pattern := rctx.RoutePattern()
+		if pattern == "" || pattern == "/*" {
+			return
+		}
+
+		rp.mu.Lock()
+		rp.counts[r.Method+" "+pattern]++
+		rp.mu.Unlock()
+	})
+}
+
+// Report returns the report of the current period without ending it.
+func (rp *Reporter) Report() *Report {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	info := buildinfo.Get()
+	endpoints := make(map[string]int, len(rp.counts))
+	for k, v := range rp.counts {
+		endpoints[k] = v
+	}
+	return &Report{
+		Instance:  rp.instance,
+		Version:   info.Version,
+		GoVersion: info.GoVersion,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Dialect:   rp.dialect,
+		Features:  rp.features,
+		Endpoints: endpoints,
+		From:      rp.since,
+		To:        time.Now().UTC(),
+	}
+}
+
+// Run sends a report every interval until ctx is done.
+func (rp *Reporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := rp.Send(ctx); err != nil {
+				log.Printf("telemetry: %s", err)
+			}
+		}
+	}
+}
+
+// Send posts the current report and starts a new period. Counts are kept
+// for the next report when sending fails.
+func (rp *Reporter) Send(ctx context.Context) error {
+	report := rp.Report()
+	b, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rp.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := rp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("endpoint answered %s", res.Status)
+	}
+
+	rp.mu.Lock()
+	for k, v := range report.Endpoints {
+		if rp.counts[k] -= v; rp.counts[k] <= 0 {
+			delete(rp.counts, k)
+		}
+	}
+	rp.since = report.To
+	rp.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,56 @@
+package telemetry_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"hello/telemetry"
+	testUtil "hello/util/test"
+)
+
+func TestReporter(t *testing.T) {
+	t.Parallel()
+
+	var received []*telemetry.Report
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := &telemetry.Report{}
+		testUtil.NoError(t, json.NewDecoder(r.Body).Decode(report))
+		received = append(received, report)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	rp := telemetry.New(srv.URL, time.Second, "postgres", []string{"search", "journal"})
+
+	r := chi.NewRouter()
+	r.Use(rp.Middleware)
+	r.Get("/v1/books/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	for _, target := range []string{"/v1/books/1", "/v1/books/2", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	report := rp.Report()
+	testUtil.Equal(t, 1, len(report.Endpoints))
+	testUtil.Equal(t, 2, report.Endpoints["GET /v1/books/{id}"])
+	testUtil.Equal(t, "journal,search", strings.Join(report.Features, ","))
+	testUtil.Equal(t, "postgres", report.Dialect)
+
+	// Failed sends keep the counts.
+	status = http.StatusInternalServerError
+	testUtil.Equal(t, true, rp.Send(context.Background()) != nil)
+	testUtil.Equal(t, 2, rp.Report().Endpoints["GET /v1/books/{id}"])
+
+	status = http.StatusAccepted
+	testUtil.NoError(t, rp.Send(context.Background()))
+	testUtil.Equal(t, 2, len(received))
+	testUtil.Equal(t, 2, received[1].Endpoints["GET /v1/books/{id}"])
+	testUtil.Equal(t, 0, len(rp.Report().Endpoints))
+}
@@ -0,0 +1,95 @@
+// Package tracing sets up OpenTelemetry tracing: spans for requests and the
+// database calls made while serving them, exported over OTLP/HTTP. Trace
+// context is taken from incoming requests and passed on to outgoing ones in
+// the W3C traceparent and baggage headers.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"hello/config"
+)
+
+// Setup installs a tracer provider exporting to c.Endpoint as the global one,
+// along with the trace context propagator. The returned provider must be
+// shut down on exit to flush the spans still buffered.
+func Setup(ctx context.Context, c *config.ConfTracing, version string) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+	if c.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(c.ServiceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRate))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp, nil
+}
+
+// Instrument makes db record a span for every query, a child of the span in
+// the query's context. Queries only join a request's trace when run with
+// the request context, through gorm's WithContext. Bound values are left
+// out of the recorded statements.
+func Instrument(db *gorm.DB, tp trace.TracerProvider) error {
+	return db.Use(otelgorm.NewPlugin(
+		otelgorm.WithTracerProvider(tp),
+		otelgorm.WithoutQueryVariables(),
+		otelgorm.WithoutMetrics(),
+	))
+}
+
+// Middleware returns middleware that serves every request in a span, which
+// continues the trace of an incoming trace context. Once the request is
+// routed the span is named after its method and route pattern rather than
+// its path, so that requests for different resources group together.
+func Middleware(tp trace.TracerProvider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			rctx := chi.RouteContext(r.Context())
+			if rctx == nil {
+				return
+			}
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes(semconv.HTTPRoute(pattern))
+			}
+		})
+		return otelhttp.NewHandler(named, "HTTP",
+			otelhttp.WithTracerProvider(tp),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method
+			}),
+		)
+	}
+}
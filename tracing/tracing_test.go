@@ -0,0 +1,59 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	mockDB "hello/mock/db"
+	"hello/tracing"
+	testUtil "hello/util/test"
+)
+
+type shelf struct {
+	ID   int
+	Name string
+}
+
+func TestMiddleware(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	db := mockDB.NewSQLite(t, &shelf{})
+	testUtil.NoError(t, tracing.Instrument(db, tp))
+
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware(tp))
+	r.Get("/shelves/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var s shelf
+		db.WithContext(r.Context()).Where("id = ?", chi.URLParam(r, "id")).Find(&s)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/shelves/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	testUtil.Equal(t, http.StatusNoContent, w.Code)
+
+	spans := recorder.Ended()
+	testUtil.Equal(t, 2, len(spans))
+	query, server := spans[0], spans[1]
+
+	testUtil.Equal(t, "GET /shelves/{id}", server.Name())
+	testUtil.Equal(t, trace.SpanKindServer, server.SpanKind())
+	testUtil.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	testUtil.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+
+	testUtil.Equal(t, server.SpanContext().TraceID(), query.SpanContext().TraceID())
+	testUtil.Equal(t, server.SpanContext().SpanID(), query.Parent().SpanID())
+}
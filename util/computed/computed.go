@@ -0,0 +1,81 @@
+package computed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Resolver computes one field for a batch of items and returns one value per
+// item, in order. Resolvers that need I/O should fetch for the whole batch at
+// once. A nil value leaves the field out for that item.
+type Resolver[T any] func(ctx context.Context, items []T) ([]any, error)
+
+// Field adapts a per-item function that needs no I/O into a Resolver.
+func Field[T any, V any](fn func(T) V) Resolver[T] {
+	return func(_ context.Context, items []T) ([]any, error) {
+		values := make([]any, len(items))
+		for i, item := range items {
+			values[i] = fn(item)
+		}
+		return values, nil
+	}
+}
+
+// Registry holds the computed fields of a resource, in registration order.
+type Registry[T any] struct {
+	mu        sync.RWMutex
+	names     []string
+	resolvers map[string]Resolver[T]
+}
+
+func NewRegistry[T any]() *Registry[T] {
+	return &Registry[T]{resolvers: make(map[string]Resolver[T])}
+}
+
+// Register adds a computed field. It panics if the name is already taken, as
+// that is a wiring mistake.
+func (r *Registry[T]) Register(name string, resolve Resolver[T]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.resolvers[name]; ok {
+		panic(fmt.Sprintf("computed: field %q registered twice", name))
+	}
+	r.names = append(r.names, name)
+	r.resolvers[name] = resolve
+}
+
+// Resolve runs every registered resolver once over items and returns the
+// computed fields of each item, in the same order as items.
+func (r *Registry[T]) Resolve(ctx context.Context, items []T) ([]map[string]any, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fields := make([]map[string]any, len(items))
+	if len(items) == 0 || len(r.names) == 0 {
+		return fields, nil
+	}
+
+	for _, name := range r.names {
+		values, err := r.resolvers[name](ctx, items)
+		if err != nil {
+			return nil, fmt.Errorf("computed: resolve %s: %w", name, err)
+		}
+		if len(values) != len(items) {
+			return nil, fmt.Errorf("computed: resolve %s: got %d values for %d items", name, len(values), len(items))
+		}
+
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			if fields[i] == nil {
+				fields[i] = make(map[string]any, len(r.names))
+			}
+			fields[i][name] = v
+		}
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,50 @@
+package computed_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"hello/util/computed"
+	testUtil "hello/util/test"
+)
+
+func TestRegistry_Resolve(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	r := computed.NewRegistry[int]()
+	r.Register("double", computed.Field(func(n int) int { return n * 2 }))
+	r.Register("even", func(_ context.Context, items []int) ([]any, error) {
+		calls++
+		values := make([]any, len(items))
+		for i, n := range items {
+			if n%2 == 0 {
+				values[i] = true
+			}
+		}
+		return values, nil
+	})
+
+	fields, err := r.Resolve(context.Background(), []int{1, 2, 3})
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 1, calls)
+	testUtil.Equal(t, 3, len(fields))
+	testUtil.Equal(t, 2, fields[0]["double"].(int))
+	testUtil.Equal(t, 1, len(fields[0]))
+	testUtil.Equal(t, true, fields[1]["even"].(bool))
+}
+
+func TestRegistry_ResolveError(t *testing.T) {
+	t.Parallel()
+
+	r := computed.NewRegistry[int]()
+	r.Register("broken", func(context.Context, []int) ([]any, error) {
+		return nil, errors.New("boom")
+	})
+
+	_, err := r.Resolve(context.Background(), []int{1})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,289 @@
+// Package jsonpatch applies JSON Patch (RFC 6902) documents to decoded JSON
+// values: maps of string to any, slices of any and scalars, as
+// encoding/json produces them.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Operation names.
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+var (
+	// ErrInvalid reports a malformed patch document.
+	ErrInvalid = errors.New("jsonpatch: invalid patch")
+	// ErrPath reports an operation on a location the document lacks.
+	ErrPath = errors.New("jsonpatch: path not found")
+	// ErrTestFailed reports a test operation whose value did not match.
+	ErrTestFailed = errors.New("jsonpatch: test failed")
+)
+
+// Operation is one step of a patch. Value is kept raw so that an explicit
+// null can be told from a missing value.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is a JSON Patch document.
+type Patch []*Operation
+
+// Validate checks that every operation is well-formed: a known op, valid
+// pointers, a from where needed and a value where needed.
+func (p Patch) Validate() error {
+	for i, op := range p {
+		if op == nil {
+			return fmt.Errorf("%w: operation %d is null", ErrInvalid, i)
+		}
+		if _, err := Parse(op.Path); err != nil {
+			return fmt.Errorf("%w: operation %d: %s", ErrInvalid, i, err)
+		}
+		switch op.Op {
+		case OpAdd, OpReplace, OpTest:
+			if op.Value == nil {
+				return fmt.Errorf("%w: operation %d: %s needs a value", ErrInvalid, i, op.Op)
+			}
+		case OpMove, OpCopy:
+			if _, err := Parse(op.From); err != nil || op.From == "" && op.Path == "" {
+				return fmt.Errorf("%w: operation %d: %s needs a valid from", ErrInvalid, i, op.Op)
+			}
+			if op.Op == OpMove && strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return fmt.Errorf("%w: operation %d: cannot move a value into itself", ErrInvalid, i)
+			}
+		case OpRemove:
+		default:
+			return fmt.Errorf("%w: operation %d: unknown op %q", ErrInvalid, i, op.Op)
+		}
+	}
+	return nil
+}
+
+// Parse splits a JSON Pointer (RFC 6901) into its unescaped reference
+// tokens. The empty pointer refers to the whole document.
+func Parse(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q does not start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// Apply applies p to doc and returns the result. Operations apply in order
+// and the patch fails as a whole: on error, doc may have been partly
+// changed, so callers apply patches to a copy they can drop.
+func (p Patch) Apply(doc any) (any, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	for i, op := range p {
+		path, _ := Parse(op.Path)
+		var err error
+		switch op.Op {
+		case OpAdd, OpReplace, OpTest:
+			var value any
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %s", ErrInvalid, i, err)
+			}
+			switch op.Op {
+			case OpAdd:
+				doc, err = add(doc, path, value)
+			case OpReplace:
+				if doc, _, err = remove(doc, path); err == nil {
+					doc, err = add(doc, path, value)
+				}
+			case OpTest:
+				var current any
+				if current, err = get(doc, path); err == nil && !reflect.DeepEqual(current, value) {
+					err = ErrTestFailed
+				}
+			}
+		case OpRemove:
+			doc, _, err = remove(doc, path)
+		case OpMove:
+			from, _ := Parse(op.From)
+			var value any
+			if doc, value, err = remove(doc, from); err == nil {
+				doc, err = add(doc, path, value)
+			}
+		case OpCopy:
+			from, _ := Parse(op.From)
+			var value any
+			if value, err = get(doc, from); err == nil {
+				doc, err = add(doc, path, clone(value))
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			child, ok := v[token]
+			if !ok {
+				return nil, ErrPath
+			}
+			doc = child
+		case []any:
+			i, err := index(token, len(v))
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, ErrPath
+		}
+	}
+	return doc, nil
+}
+
+// add sets the member or inserts the element at path, returning the
+// document, which is value itself when path is the root.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch v := parent.(type) {
+	case map[string]any:
+		v[token] = value
+		return doc, nil
+	case []any:
+		i := len(v)
+		if token != "-" {
+			if i, err = index(token, len(v)+1); err != nil {
+				return nil, err
+			}
+		}
+		v = append(v, nil)
+		copy(v[i+1:], v[i:])
+		v[i] = value
+		return set(doc, path[:len(path)-1], v)
+	default:
+		return nil, ErrPath
+	}
+}
+
+// remove deletes the member or element at path and returns the document
+// and the removed value.
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	token := path[len(path)-1]
+
+	switch v := parent.(type) {
+	case map[string]any:
+		value, ok := v[token]
+		if !ok {
+			return nil, nil, ErrPath
+		}
+		delete(v, token)
+		return doc, value, nil
+	case []any:
+		i, err := index(token, len(v))
+		if err != nil {
+			return nil, nil, err
+		}
+		value := v[i]
+		doc, err = set(doc, path[:len(path)-1], append(v[:i:i], v[i+1:]...))
+		return doc, value, err
+	default:
+		return nil, nil, ErrPath
+	}
+}
+
+// set replaces the value at path, which exists, with value. Slices change
+// length on insertion and removal, so their parents are updated with them.
+func set(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch v := parent.(type) {
+	case map[string]any:
+		v[token] = value
+	case []any:
+		i, err := index(token, len(v))
+		if err != nil {
+			return nil, err
+		}
+		v[i] = value
+	default:
+		return nil, ErrPath
+	}
+	return doc, nil
+}
+
+// index parses an array index below n. Leading zeros are not allowed.
+func index(token string, n int) (int, error) {
+	if token == "" || len(token) > 1 && token[0] == '0' {
+		return 0, ErrPath
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= n {
+		return 0, ErrPath
+	}
+	return i, nil
+}
+
+// clone deep-copies a decoded JSON value, so that a copied value does not
+// share maps or slices with its source.
+func clone(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = clone(e)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = clone(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,80 @@
+package jsonpatch_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"hello/util/jsonpatch"
+	testUtil "hello/util/test"
+)
+
+func TestPatch_Apply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		{"add member", `{"a": 1}`, `[{"op": "add", "path": "/b", "value": [1]}]`, `{"a": 1, "b": [1]}`, nil},
+		{"add replaces member", `{"a": 1}`, `[{"op": "add", "path": "/a", "value": null}]`, `{"a": null}`, nil},
+		{"insert element", `{"a": [1, 3]}`, `[{"op": "add", "path": "/a/1", "value": 2}]`, `{"a": [1, 2, 3]}`, nil},
+		{"append element", `{"a": [1]}`, `[{"op": "add", "path": "/a/-", "value": 2}]`, `{"a": [1, 2]}`, nil},
+		{"remove element", `{"a": [1, 2, 3]}`, `[{"op": "remove", "path": "/a/0"}]`, `{"a": [2, 3]}`, nil},
+		{"replace", `{"a": {"b": 1}}`, `[{"op": "replace", "path": "/a/b", "value": "x"}]`, `{"a": {"b": "x"}}`, nil},
+		{"replace root", `{"a": 1}`, `[{"op": "replace", "path": "", "value": [1]}]`, `[1]`, nil},
+		{"move", `{"a": 1}`, `[{"op": "move", "from": "/a", "path": "/b"}]`, `{"b": 1}`, nil},
+		{"copy is deep", `{"a": {"x": 1}}`, `[{"op": "copy", "from": "/a", "path": "/b"}, {"op": "add", "path": "/b/x", "value": 2}]`, `{"a": {"x": 1}, "b": {"x": 2}}`, nil},
+		{"escaped pointer", `{"a/b": 1, "m~n": 2}`, `[{"op": "remove", "path": "/a~1b"}, {"op": "remove", "path": "/m~0n"}]`, `{}`, nil},
+		{"test passes", `{"a": [1, {"b": "c"}]}`, `[{"op": "test", "path": "/a", "value": [1, {"b": "c"}]}]`, `{"a": [1, {"b": "c"}]}`, nil},
+		{"test fails", `{"a": 1}`, `[{"op": "test", "path": "/a", "value": 2}]`, ``, jsonpatch.ErrTestFailed},
+		{"remove missing", `{"a": 1}`, `[{"op": "remove", "path": "/b"}]`, ``, jsonpatch.ErrPath},
+		{"replace missing", `{}`, `[{"op": "replace", "path": "/a", "value": 1}]`, ``, jsonpatch.ErrPath},
+		{"add to missing parent", `{}`, `[{"op": "add", "path": "/a/b", "value": 1}]`, ``, jsonpatch.ErrPath},
+		{"index out of range", `[1]`, `[{"op": "add", "path": "/2", "value": 1}]`, ``, jsonpatch.ErrPath},
+		{"leading zero", `[1, 2]`, `[{"op": "remove", "path": "/01"}]`, ``, jsonpatch.ErrPath},
+		{"unknown op", `{}`, `[{"op": "merge", "path": "/a"}]`, ``, jsonpatch.ErrInvalid},
+		{"missing value", `{}`, `[{"op": "add", "path": "/a"}]`, ``, jsonpatch.ErrInvalid},
+		{"bad pointer", `{}`, `[{"op": "remove", "path": "a"}]`, ``, jsonpatch.ErrInvalid},
+		{"move into itself", `{"a": {}}`, `[{"op": "move", "from": "/a", "path": "/a/b"}]`, ``, jsonpatch.ErrInvalid},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc any
+			testUtil.NoError(t, json.Unmarshal([]byte(tc.doc), &doc))
+			var patch jsonpatch.Patch
+			testUtil.NoError(t, json.Unmarshal([]byte(tc.patch), &patch))
+
+			got, err := patch.Apply(doc)
+			if tc.err != nil {
+				testUtil.Equal(t, true, errors.Is(err, tc.err))
+				return
+			}
+			testUtil.NoError(t, err)
+
+			var want any
+			testUtil.NoError(t, json.Unmarshal([]byte(tc.want), &want))
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			testUtil.Equal(t, string(wantJSON), string(gotJSON))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tokens, err := jsonpatch.Parse("/a~1b/~0/")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 3, len(tokens))
+	testUtil.Equal(t, "a/b", tokens[0])
+	testUtil.Equal(t, "~", tokens[1])
+	testUtil.Equal(t, "", tokens[2])
+
+	tokens, err = jsonpatch.Parse("")
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, 0, len(tokens))
+}
@@ -0,0 +1,40 @@
+// Package redis opens clients of the Redis servers the service shares with
+// its other instances, configured alike wherever they are used.
+package redis
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Timeout bounds each call, connection included.
+const Timeout = 5 * time.Second
+
+// MaxIdle bounds the connections kept open between calls; more are opened
+// under load and closed once done. IdleTimeout is how long one is kept
+// unused, so that connections dropped by the server or a proxy in between
+// are not reused.
+const (
+	MaxIdle     = 16
+	IdleTimeout = time.Minute
+)
+
+// New returns a client of the server at addr, authenticating with password
+// unless it is empty. Its connections are pooled until it is closed. It
+// speaks RESP2 and does not name itself to the server, so that servers
+// before Redis 6 and stand-ins are understood.
+func New(addr, password string) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:                  addr,
+		Password:              password,
+		Protocol:              2,
+		DisableIndentity:      true,
+		DialTimeout:           Timeout,
+		ReadTimeout:           Timeout,
+		WriteTimeout:          Timeout,
+		ContextTimeoutEnabled: true,
+		MaxIdleConns:          MaxIdle,
+		ConnMaxIdleTime:       IdleTimeout,
+	})
+}
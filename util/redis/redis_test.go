@@ -0,0 +1,35 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	redisUtil "hello/util/redis"
+	"hello/util/redis/redistest"
+	testUtil "hello/util/test"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	srv := redistest.StartAuth(t, "secret")
+	c := redisUtil.New(srv.Addr, "secret")
+	ctx := context.Background()
+
+	testUtil.NoError(t, c.Set(ctx, "a", "1", 0).Err())
+	v, err := c.Get(ctx, "a").Result()
+	testUtil.NoError(t, err)
+	testUtil.Equal(t, "1", v)
+
+	// Error replies leave the connection usable.
+	testUtil.Equal(t, true, c.Do(ctx, "NOPE").Err() != nil)
+	testUtil.Equal(t, true, errors.Is(c.Get(ctx, "missing").Err(), redis.Nil))
+	testUtil.Equal(t, 1, srv.Conns())
+	testUtil.NoError(t, c.Close())
+
+	err = redisUtil.New(srv.Addr, "wrong").Get(ctx, "a").Err()
+	testUtil.Equal(t, true, err != nil && !errors.Is(err, redis.Nil))
+}
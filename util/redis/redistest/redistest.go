@@ -0,0 +1,223 @@
+// Package redistest runs a stand-in for Redis in tests. It serves, over
+// RESP2, the few commands the service sends, keeping its data in memory.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Handler answers a command, given with its arguments, with a raw RESP2
+// reply.
+type Handler func(cmd []string) string
+
+// Server serves AUTH, GET, SET with EX or PX, DEL, PEXPIRE, SADD and
+// SMEMBERS, and the commands given a Handler.
+type Server struct {
+	Addr string
+
+	password string
+	conns    atomic.Int64
+
+	mu       sync.Mutex
+	strings  map[string]string
+	sets     map[string]map[string]bool
+	expires  map[string]time.Time
+	handlers map[string]Handler
+}
+
+// Start serves on a local port until the test ends.
+func Start(t *testing.T) *Server {
+	return StartAuth(t, "")
+}
+
+// StartAuth serves like Start, answering AUTH with an error unless it is
+// given password.
+func StartAuth(t *testing.T, password string) *Server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("redistest: listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &Server{
+		Addr:     ln.Addr().String(),
+		password: password,
+		strings:  make(map[string]string),
+		sets:     make(map[string]map[string]bool),
+		expires:  make(map[string]time.Time),
+		handlers: make(map[string]Handler),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.conns.Add(1)
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// Handle serves the command name with h. Handlers run one at a time.
+func (s *Server) Handle(name string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[strings.ToUpper(name)] = h
+}
+
+// Conns returns the number of connections accepted.
+func (s *Server) Conns() int {
+	return int(s.conns.Load())
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	for {
+		cmd, err := readCommand(br)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, s.exec(cmd))
+	}
+}
+
+func (s *Server) exec(cmd []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToUpper(cmd[0])
+	if h, ok := s.handlers[name]; ok {
+		return h(cmd)
+	}
+
+	switch name {
+	case "AUTH":
+		if cmd[1] != s.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "SET":
+		s.strings[cmd[1]] = cmd[2]
+		delete(s.expires, cmd[1])
+		if len(cmd) == 5 {
+			switch strings.ToUpper(cmd[3]) {
+			case "PX":
+				s.expire(cmd[1], cmd[4])
+			case "EX":
+				n, _ := strconv.Atoi(cmd[4])
+				s.expire(cmd[1], strconv.Itoa(n*1000))
+			}
+		}
+		return "+OK\r\n"
+	case "GET":
+		s.evict(cmd[1])
+		v, ok := s.strings[cmd[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "DEL":
+		n := 0
+		for _, key := range cmd[1:] {
+			s.evict(key)
+			if _, ok := s.strings[key]; ok {
+				n++
+			} else if _, ok := s.sets[key]; ok {
+				n++
+			}
+			delete(s.strings, key)
+			delete(s.sets, key)
+			delete(s.expires, key)
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "PEXPIRE":
+		s.evict(cmd[1])
+		_, str := s.strings[cmd[1]]
+		_, set := s.sets[cmd[1]]
+		if !str && !set {
+			return ":0\r\n"
+		}
+		s.expire(cmd[1], cmd[2])
+		return ":1\r\n"
+	case "SADD":
+		s.evict(cmd[1])
+		if s.sets[cmd[1]] == nil {
+			s.sets[cmd[1]] = make(map[string]bool)
+		}
+		n := 0
+		for _, m := range cmd[2:] {
+			if !s.sets[cmd[1]][m] {
+				s.sets[cmd[1]][m] = true
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "SMEMBERS":
+		s.evict(cmd[1])
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%d\r\n", len(s.sets[cmd[1]]))
+		for m := range s.sets[cmd[1]] {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(m), m)
+		}
+		return b.String()
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func (s *Server) expire(key, ms string) {
+	n, _ := strconv.Atoi(ms)
+	s.expires[key] = time.Now().Add(time.Duration(n) * time.Millisecond)
+}
+
+// evict drops key once it has expired.
+func (s *Server) evict(key string) {
+	if at, ok := s.expires[key]; ok && !time.Now().Before(at) {
+		delete(s.strings, key)
+		delete(s.sets, key)
+		delete(s.expires, key)
+	}
+}
+
+func readCommand(br *bufio.Reader) ([]string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := make([]string, n)
+	for i := range cmd {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		cmd[i] = string(buf[:size])
+	}
+	return cmd, nil
+}
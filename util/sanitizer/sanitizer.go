@@ -0,0 +1,65 @@
+package sanitizer
+
+import (
+	"reflect"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	tagName = "sanitize"
+
+	// TagSingleLine additionally collapses every run of whitespace, including
+	// newlines, into a single space.
+	TagSingleLine = "singleline"
+	// TagSkip leaves the field untouched.
+	TagSkip = "-"
+)
+
+// Struct normalizes every exported string field of the struct v points to:
+// NFC normalization and trimming by default, plus whatever the field's
+// `sanitize` tag asks for. Call it before validation so that visually
+// identical input is stored identically.
+func Struct(v any) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return
+	}
+	sanitizeStruct(rv.Elem())
+}
+
+func sanitizeStruct(rv reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get(tagName)
+		if tag == TagSkip {
+			continue
+		}
+
+		fv := rv.Field(i)
+		switch {
+		case fv.Kind() == reflect.String:
+			fv.SetString(String(fv.String(), tag == TagSingleLine))
+		case fv.Kind() == reflect.Pointer && !fv.IsNil() && fv.Elem().Kind() == reflect.String:
+			fv.Elem().SetString(String(fv.Elem().String(), tag == TagSingleLine))
+		case fv.Kind() == reflect.Struct:
+			sanitizeStruct(fv)
+		}
+	}
+}
+
+// String NFC-normalizes and trims s, collapsing internal whitespace when
+// singleLine is set.
+func String(s string, singleLine bool) string {
+	s = norm.NFC.String(s)
+	if singleLine {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,29 @@
+package sanitizer_test
+
+import (
+	"testing"
+
+	"hello/util/sanitizer"
+	testUtil "hello/util/test"
+)
+
+func TestStruct(t *testing.T) {
+	t.Parallel()
+
+	form := &struct {
+		Title       string `sanitize:"singleline"`
+		Description string
+		Raw         string `sanitize:"-"`
+	}{
+		// "e\u0301" is the decomposed spelling of "é".
+		Title:       "  Cafe\u0301   au \t lait \n",
+		Description: " line one\n\nline two  ",
+		Raw:         "  keep  ",
+	}
+
+	sanitizer.Struct(form)
+
+	testUtil.Equal(t, "Café au lait", form.Title)
+	testUtil.Equal(t, "line one\n\nline two", form.Description)
+	testUtil.Equal(t, "  keep  ", form.Raw)
+}
@@ -0,0 +1,75 @@
+package trie
+
+import (
+	"sort"
+	"strings"
+)
+
+// Trie is a case-insensitive prefix tree. It is not safe for concurrent
+// writes; build it once and swap it in atomically.
+type Trie[V any] struct {
+	root *node[V]
+}
+
+type node[V any] struct {
+	keys     []rune
+	children map[rune]*node[V]
+	values   []V
+}
+
+func New[V any]() *Trie[V] {
+	return &Trie[V]{root: newNode[V]()}
+}
+
+func newNode[V any]() *node[V] {
+	return &node[V]{children: make(map[rune]*node[V])}
+}
+
+func (t *Trie[V]) Insert(key string, v V) {
+	n := t.root
+	for _, r := range strings.ToLower(key) {
+		child, ok := n.children[r]
+		if !ok {
+			child = newNode[V]()
+			n.children[r] = child
+			i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= r })
+			n.keys = append(n.keys, 0)
+			copy(n.keys[i+1:], n.keys[i:])
+			n.keys[i] = r
+		}
+		n = child
+	}
+	n.values = append(n.values, v)
+}
+
+// Find returns at most limit values whose key starts with prefix, shortest
+// and then lexicographically smallest keys first.
+func (t *Trie[V]) Find(prefix string, limit int) []V {
+	n := t.root
+	for _, r := range strings.ToLower(prefix) {
+		child, ok := n.children[r]
+		if !ok {
+			return nil
+		}
+		n = child
+	}
+
+	var out []V
+	queue := []*node[V]{n}
+	for len(queue) > 0 && len(out) < limit {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for _, v := range cur.values {
+			if len(out) == limit {
+				break
+			}
+			out = append(out, v)
+		}
+		for _, k := range cur.keys {
+			queue = append(queue, cur.children[k])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,27 @@
+package trie_test
+
+import (
+	"testing"
+
+	testUtil "hello/util/test"
+	"hello/util/trie"
+)
+
+func TestTrie_Find(t *testing.T) {
+	t.Parallel()
+
+	tr := trie.New[string]()
+	tr.Insert("Harry Potter", "hp")
+	tr.Insert("Hamlet", "hamlet")
+	tr.Insert("Hobbit", "hobbit")
+	tr.Insert("ha", "ha")
+
+	got := tr.Find("HA", 10)
+	testUtil.Equal(t, 3, len(got))
+	testUtil.Equal(t, "ha", got[0])
+	testUtil.Equal(t, "hamlet", got[1])
+	testUtil.Equal(t, "hp", got[2])
+
+	testUtil.Equal(t, 1, len(tr.Find("ha", 1)))
+	testUtil.Equal(t, 0, len(tr.Find("x", 10)))
+}
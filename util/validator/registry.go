@@ -0,0 +1,233 @@
+package validator
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+
+	"hello/metadata"
+	"hello/money"
+)
+
+// DefaultLanguage is the language of error messages when the request asks
+// for none that rules have messages in. Every rule has messages in it.
+const DefaultLanguage = "en"
+
+// Message describes a failed rule on a field, given the rule's param.
+type Message func(field, param string) string
+
+// Rule is a validation tag of the service's own, with its error messages
+// keyed by language, e.g. "en" or "de".
+type Rule struct {
+	Tag      string
+	Func     validator.Func
+	Messages map[string]Message
+}
+
+var (
+	rules     = make(map[string]*Rule)
+	ruleOrder []string
+)
+
+// Register adds a rule that New registers with every validator. It is meant
+// to be called at startup, from init functions, and panics on a tag
+// registered twice or lacking a message in DefaultLanguage.
+func Register(rule Rule) {
+	if _, ok := rules[rule.Tag]; ok {
+		panic(fmt.Sprintf("validator: rule %q registered twice", rule.Tag))
+	}
+	if rule.Messages[DefaultLanguage] == nil {
+		panic(fmt.Sprintf("validator: rule %q lacks a %s message", rule.Tag, DefaultLanguage))
+	}
+	rules[rule.Tag] = &rule
+	ruleOrder = append(ruleOrder, rule.Tag)
+}
+
+// Languages returns the languages error messages can be given in, the
+// default first.
+func Languages() []string {
+	langs := []string{DefaultLanguage}
+	for _, tag := range ruleOrder {
+		for lang := range rules[tag].Messages {
+			if !slices.Contains(langs, lang) {
+				langs = append(langs, lang)
+			}
+		}
+	}
+	slices.Sort(langs[1:])
+	return langs
+}
+
+// message returns the message of a failed rule in lang, falling back to
+// DefaultLanguage, or false when tag is not a registered rule.
+func message(tag, lang, field, param string) (string, bool) {
+	rule, ok := rules[tag]
+	if !ok {
+		return "", false
+	}
+	m := rule.Messages[lang]
+	if m == nil {
+		m = rule.Messages[DefaultLanguage]
+	}
+	return m(field, param), true
+}
+
+func messages(en, de, fr string) map[string]Message {
+	format := func(f string) Message {
+		return func(field, _ string) string { return fmt.Sprintf(f, field) }
+	}
+	return map[string]Message{"en": format(en), "de": format(de), "fr": format(fr)}
+}
+
+var (
+	alphaSpaceRegex = regexp.MustCompile("^[a-zA-Z ]+$")
+	identifierRegex = regexp.MustCompile("^[a-z][a-z0-9_]*$")
+)
+
+func init() {
+	Register(Rule{
+		Tag:  "alphaspace",
+		Func: func(fl validator.FieldLevel) bool { return alphaSpaceRegex.MatchString(fl.Field().String()) },
+		Messages: messages(
+			"%s can only contain alphabetic and space characters",
+			"%s darf nur Buchstaben und Leerzeichen enthalten",
+			"%s ne peut contenir que des lettres et des espaces",
+		),
+	})
+	Register(Rule{
+		Tag:  "identifier",
+		Func: func(fl validator.FieldLevel) bool { return identifierRegex.MatchString(fl.Field().String()) },
+		Messages: messages(
+			"%s must start with a lowercase letter and contain only lowercase letters, digits and underscores",
+			"%s muss mit einem Kleinbuchstaben beginnen und darf nur Kleinbuchstaben, Ziffern und Unterstriche enthalten",
+			"%s doit commencer par une lettre minuscule et ne contenir que des lettres minuscules, des chiffres et des tirets bas",
+		),
+	})
+	Register(Rule{
+		Tag:  "currency",
+		Func: isCurrency,
+		Messages: messages(
+			"%s must be an ISO 4217 currency code",
+			"%s muss ein Währungscode nach ISO 4217 sein",
+			"%s doit être un code de devise ISO 4217",
+		),
+	})
+	Register(Rule{
+		Tag:  "money",
+		Func: isMoney,
+		Messages: messages(
+			"%s must be a non-negative amount with no more decimals than the currency allows",
+			"%s muss ein nicht negativer Betrag mit höchstens so vielen Nachkommastellen sein, wie die Währung erlaubt",
+			"%s doit être un montant positif ou nul, sans plus de décimales que la devise n'en permet",
+		),
+	})
+	Register(Rule{
+		Tag:  "isbn",
+		Func: isISBN,
+		Messages: messages(
+			"%s must be a valid ISBN-10 or ISBN-13",
+			"%s muss eine gültige ISBN-10 oder ISBN-13 sein",
+			"%s doit être un ISBN-10 ou ISBN-13 valide",
+		),
+	})
+	Register(Rule{
+		Tag:  "language",
+		Func: isLanguage,
+		Messages: messages(
+			"%s must be a language code such as en or pt-BR",
+			"%s muss ein Sprachcode wie en oder pt-BR sein",
+			"%s doit être un code de langue tel que en ou pt-BR",
+		),
+	})
+	Register(Rule{
+		Tag:  "notfuture",
+		Func: isNotFuture,
+		Messages: messages(
+			"%s must not be in the future",
+			"%s darf nicht in der Zukunft liegen",
+			"%s ne doit pas être dans le futur",
+		),
+	})
+	Register(Rule{
+		Tag:  "urlscheme",
+		Func: hasURLScheme,
+		Messages: map[string]Message{
+			"en": func(field, param string) string {
+				return fmt.Sprintf("%s must be a URL starting with %s", field, schemes(param, " or "))
+			},
+			"de": func(field, param string) string {
+				return fmt.Sprintf("%s muss eine URL sein, die mit %s beginnt", field, schemes(param, " oder "))
+			},
+			"fr": func(field, param string) string {
+				return fmt.Sprintf("%s doit être une URL commençant par %s", field, schemes(param, " ou "))
+			},
+		},
+	})
+}
+
+func isCurrency(fl validator.FieldLevel) bool {
+	_, ok := money.Exponent(fl.Field().String())
+	return ok
+}
+
+// isMoney checks a non-negative decimal amount in the currency held by the
+// sibling field named by the tag's param. An unknown currency is left for
+// that field's own validation to report.
+func isMoney(fl validator.FieldLevel) bool {
+	currency := fl.Parent().FieldByName(fl.Param()).String()
+	if _, ok := money.Exponent(currency); !ok {
+		return true
+	}
+
+	m, err := money.Parse(fl.Field().String(), currency)
+	return err == nil && m.Amount >= 0
+}
+
+// isISBN checks an ISBN-10 or ISBN-13, hyphens and spaces allowed, by its
+// check digit. It replaces the validator's own isbn, which rejects hyphens.
+func isISBN(fl validator.FieldLevel) bool {
+	_, err := metadata.NormalizeISBN(fl.Field().String())
+	return err == nil
+}
+
+// isLanguage checks a BCP 47 language tag, e.g. "en" or "pt-BR".
+func isLanguage(fl validator.FieldLevel) bool {
+	_, err := language.Parse(fl.Field().String())
+	return err == nil
+}
+
+// isNotFuture checks that a time, or a date string in the layout given as
+// param (2006-01-02 by default), is not after now. Strings that do not parse
+// are left for datetime to report.
+func isNotFuture(fl validator.FieldLevel) bool {
+	if t, ok := fl.Field().Interface().(time.Time); ok {
+		return !t.After(time.Now())
+	}
+
+	layout := fl.Param()
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	t, err := time.Parse(layout, fl.Field().String())
+	return err != nil || !t.After(time.Now())
+}
+
+// hasURLScheme checks an absolute URL with one of the space-separated
+// schemes of the param, e.g. urlscheme=http https.
+func hasURLScheme(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return slices.Contains(strings.Fields(fl.Param()), strings.ToLower(u.Scheme))
+}
+
+func schemes(param, or string) string {
+	return strings.Join(strings.Fields(param), or)
+}
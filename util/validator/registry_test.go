@@ -0,0 +1,70 @@
+package validator_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	testUtil "hello/util/test"
+	validatorUtil "hello/util/validator"
+)
+
+func TestRules(t *testing.T) {
+	t.Parallel()
+
+	type form struct {
+		ISBN     string `json:"isbn" validate:"omitempty,isbn"`
+		Language string `json:"language" validate:"omitempty,language"`
+		Date     string `json:"date" validate:"omitempty,notfuture"`
+		URL      string `json:"url" validate:"omitempty,urlscheme=http https"`
+	}
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	tests := []struct {
+		name  string
+		form  form
+		valid bool
+	}{
+		{"ISBN-13 with hyphens", form{ISBN: "978-0-441-17271-9"}, true},
+		{"ISBN-10 with check X", form{ISBN: "0-8044-2957-X"}, true},
+		{"ISBN with wrong check digit", form{ISBN: "978-0-441-17271-8"}, false},
+		{"language with region", form{Language: "pt-BR"}, true},
+		{"invalid language", form{Language: "not a language"}, false},
+		{"past date", form{Date: "1965-08-01"}, true},
+		{"future date", form{Date: tomorrow}, false},
+		{"unparsable date left to datetime", form{Date: "1965"}, true},
+		{"https URL", form{URL: "https://example.com/dune.png"}, true},
+		{"javascript URL", form{URL: "javascript:alert(1)"}, false},
+		{"relative URL", form{URL: "/dune.png"}, false},
+	}
+	v := validatorUtil.New()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testUtil.Equal(t, tc.valid, v.Struct(tc.form) == nil)
+		})
+	}
+}
+
+func TestToLocalizedErrResponse(t *testing.T) {
+	t.Parallel()
+
+	type form struct {
+		Date string `json:"date" validate:"required,notfuture"`
+		URL  string `json:"url" validate:"urlscheme=http https"`
+	}
+	err := validatorUtil.New().Struct(form{Date: "2999-01-01", URL: "ftp://example.com"})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "de-CH, en;q=0.5")
+	resp := validatorUtil.ToLocalizedErrResponse(err, r)
+	testUtil.Equal(t, 2, len(resp.Errors))
+	testUtil.Equal(t, "date darf nicht in der Zukunft liegen", resp.Errors[0])
+	testUtil.Equal(t, "url muss eine URL sein, die mit http oder https beginnt", resp.Errors[1])
+
+	r.Header.Set("Accept-Language", "ja")
+	testUtil.Equal(t, "date must not be in the future", validatorUtil.ToLocalizedErrResponse(err, r).Errors[0])
+	testUtil.Equal(t, "url must be a URL starting with http or https", validatorUtil.ToErrResponse(err).Errors[1])
+
+	testUtil.Equal(t, "en,de,fr", strings.Join(validatorUtil.Languages(), ","))
+}
@@ -1,43 +1,128 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+
+	e "hello/api/resource/common/err"
 )
 
 type ErrResponse struct {
 	Errors []string `json:"errors"`
 }
 
+// ToErrResponse describes validation errors in the default language, or
+// returns nil for other errors.
 func ToErrResponse(err error) *ErrResponse {
+	return toErrResponse(err, DefaultLanguage)
+}
+
+// ToLocalizedErrResponse describes validation errors in the language of the
+// request's Accept-Language header, as far as registered rules have
+// messages in it; the validator's own rules are described in English.
+func ToLocalizedErrResponse(err error, r *http.Request) *ErrResponse {
+	return toErrResponse(err, Language(r))
+}
+
+// Language returns the language among Languages that best matches the
+// request's Accept-Language header, or DefaultLanguage.
+func Language(r *http.Request) string {
+	accepted, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil || len(accepted) == 0 {
+		return DefaultLanguage
+	}
+
+	langs := Languages()
+	tags := make([]language.Tag, len(langs))
+	for i, l := range langs {
+		tags[i] = language.Make(l)
+	}
+	_, i, conf := language.NewMatcher(tags).Match(accepted...)
+	if conf == language.No {
+		return DefaultLanguage
+	}
+	return langs[i]
+}
+
+// ToFieldErrors describes validation errors as ToLocalizedErrResponse does,
+// naming the field of each, or returns nil for other errors.
+func ToFieldErrors(err error, r *http.Request) []e.FieldError {
+	return toFieldErrors(err, Language(r))
+}
+
+// Mapper maps validation errors to a 422 problem listing them, for
+// err.Middleware.
+func Mapper(r *http.Request, err error) *e.Problem {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return nil
+	}
+	return e.RespValidationFailed.WithErrors(toFieldErrors(fieldErrors, Language(r)))
+}
+
+func toErrResponse(err error, lang string) *ErrResponse {
+	fieldErrors := toFieldErrors(err, lang)
+	if fieldErrors == nil {
+		return nil
+	}
+
+	resp := &ErrResponse{Errors: make([]string, len(fieldErrors))}
+	for i, fe := range fieldErrors {
+		resp.Errors[i] = fe.Message
+	}
+	return resp
+}
+
+func toFieldErrors(err error, lang string) []e.FieldError {
 	if fieldErrors, ok := err.(validator.ValidationErrors); ok {
-		resp := ErrResponse{
-			Errors: make([]string, len(fieldErrors)),
-		}
+		resp := make([]e.FieldError, len(fieldErrors))
 
 		for i, err := range fieldErrors {
+			resp[i].Field = err.Field()
+
+			if msg, ok := message(err.Tag(), lang, err.Field(), err.Param()); ok {
+				resp[i].Message = msg
+				continue
+			}
+
 			switch err.Tag() {
 			case "required":
-				resp.Errors[i] = fmt.Sprintf("%s is a required field", err.Field())
+				resp[i].Message = fmt.Sprintf("%s is a required field", err.Field())
+			case "required_without":
+				resp[i].Message = fmt.Sprintf("%s is required when %s is not given", err.Field(), strings.ToLower(err.Param()))
+			case "required_if":
+				if field, value, ok := strings.Cut(err.Param(), " "); ok {
+					resp[i].Message = fmt.Sprintf("%s is required when %s is %s", err.Field(), strings.ToLower(field), value)
+				} else {
+					resp[i].Message = fmt.Sprintf("%s is a required field", err.Field())
+				}
 			case "max":
-				resp.Errors[i] = fmt.Sprintf("%s must be a maximum of %s in length", err.Field(), err.Param())
+				resp[i].Message = fmt.Sprintf("%s must be a maximum of %s in length", err.Field(), err.Param())
+			case "min":
+				resp[i].Message = fmt.Sprintf("%s must be a minimum of %s in length", err.Field(), err.Param())
+			case "email":
+				resp[i].Message = fmt.Sprintf("%s must be a valid email address", err.Field())
 			case "url":
-				resp.Errors[i] = fmt.Sprintf("%s must be a valid URL", err.Field())
-			case "alphaspace":
-				resp.Errors[i] = fmt.Sprintf("%s can only contain alphabetic and space characters", err.Field())
+				resp[i].Message = fmt.Sprintf("%s must be a valid URL", err.Field())
+			case "oneof":
+				resp[i].Message = fmt.Sprintf("%s must be one of %s", err.Field(), err.Param())
 			case "datetime":
 				if err.Param() == "2006-01-02" {
-					resp.Errors[i] = fmt.Sprintf("%s must be a valid date", err.Field())
+					resp[i].Message = fmt.Sprintf("%s must be a valid date", err.Field())
 				} else {
-					resp.Errors[i] = fmt.Sprintf("%s must follow %s format", err.Field(), err.Param())
+					resp[i].Message = fmt.Sprintf("%s must follow %s format", err.Field(), err.Param())
 				}
 			default:
-				resp.Errors[i] = fmt.Sprintf("something wrong on %s; %s", err.Field(), err.Tag())
+				resp[i].Message = fmt.Sprintf("something wrong on %s; %s", err.Field(), err.Tag())
 			}
 		}
 
-		return &resp
+		return resp
 	}
 
 	return nil
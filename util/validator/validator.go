@@ -2,14 +2,13 @@ package validator
 
 import (
 	"reflect"
-	"regexp"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
 
-const alphaSpaceRegexString string = "^[a-zA-Z ]+$"
-
+// New returns a validator naming fields by their JSON names, with every
+// registered rule.
 func New() *validator.Validate {
 	validate := validator.New()
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
@@ -20,12 +19,11 @@ func New() *validator.Validate {
 		return name
 	})
 
-	validate.RegisterValidation("alphaspace", isAlphaSpace)
+	for _, tag := range ruleOrder {
+		if err := validate.RegisterValidation(tag, rules[tag].Func); err != nil {
+			panic(err)
+		}
+	}
 
 	return validate
 }
-
-func isAlphaSpace(fl validator.FieldLevel) bool {
-	reg := regexp.MustCompile(alphaSpaceRegexString)
-	return reg.MatchString(fl.Field().String())
-}
@@ -0,0 +1,424 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrQueueFull  = errors.New("worker: job queue full")
+	ErrUnknownJob = errors.New("worker: unknown job type")
+)
+
+// Job is a piece of background work. Its payload is JSON, so that a
+// backend can queue it out of process.
+type Job struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// Attempt counts the runs of the job, this one included.
+	Attempt    int       `json:"attempt"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// QueuedAt is when the job was last queued, at enqueue or for a retry.
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// Decode unmarshals the payload of j into v.
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler runs the jobs of a type. A job whose handler returns an error,
+// or panics, is retried.
+type Handler func(ctx context.Context, j *Job) error
+
+// Backend queues jobs for the workers of a runner. MemoryBackend queues
+// them in process, where they are lost at shutdown; a backend on a shared
+// store, e.g. Redis, would keep them across restarts and let instances
+// share them.
+type Backend interface {
+	// Push queues j, returning ErrQueueFull when the queue is full.
+	Push(ctx context.Context, j *Job) error
+	// Pop waits for a job until ctx is done.
+	Pop(ctx context.Context) (*Job, error)
+	Len() int
+	Cap() int
+}
+
+// MemoryBackend is a bounded in-process queue.
+type MemoryBackend struct {
+	jobs chan *Job
+}
+
+func NewMemoryBackend(size int) *MemoryBackend {
+	return &MemoryBackend{jobs: make(chan *Job, size)}
+}
+
+func (b *MemoryBackend) Push(_ context.Context, j *Job) error {
+	select {
+	case b.jobs <- j:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (b *MemoryBackend) Pop(ctx context.Context) (*Job, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case j := <-b.jobs:
+		return j, nil
+	}
+}
+
+func (b *MemoryBackend) Len() int {
+	return len(b.jobs)
+}
+
+func (b *MemoryBackend) Cap() int {
+	return cap(b.jobs)
+}
+
+// Retry sets how often a failed job runs again: up to Attempts runs in
+// all, Backoff after the first failure and twice as long after each next
+// one, up to MaxBackoff.
+type Retry struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// delay returns how long to wait before the run after attempt.
+func (r Retry) delay(attempt int) time.Duration {
+	d := r.Backoff
+	for i := 1; i < attempt && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.MaxBackoff)
+}
+
+// JobStats counts the jobs of a type since the instance started. Pending
+// jobs are queued, running or waiting for a retry. Failed counts failed
+// runs; Abandoned the jobs given up after their last run, or dropped at
+// shutdown while pending.
+type JobStats struct {
+	Type                 string  `json:"type"`
+	Pending              int64   `json:"pending"`
+	Enqueued             int64   `json:"enqueued"`
+	Succeeded            int64   `json:"succeeded"`
+	Failed               int64   `json:"failed"`
+	Retried              int64   `json:"retried"`
+	Abandoned            int64   `json:"abandoned"`
+	AvgProcessingSeconds float64 `json:"avg_processing_seconds"`
+}
+
+type jobType struct {
+	handle Handler
+	retry  Retry
+	every  time.Duration
+
+	pending, enqueued, succeeded, failed, retried, abandoned atomic.Int64
+	meter                                                    Meter
+}
+
+// Runner runs the background jobs of the instance on a pool of workers,
+// one unless resized. Handlers are registered by job type before Run.
+type Runner struct {
+	backend Backend
+	retry   Retry
+	types   map[string]*jobType
+
+	mu   sync.Mutex
+	size int
+	// ctx is that of Run while it runs. Workers pop with a context of
+	// their own, canceled when Resize stops them, and run jobs with ctx.
+	ctx   context.Context
+	stops []context.CancelFunc
+	wg    sync.WaitGroup
+	busy  atomic.Int64
+	meter Meter
+
+	onAbandon func(ctx context.Context, j *Job, cause error)
+}
+
+// NewRunner returns a runner taking jobs from backend and retrying them
+// as retry tells, unless their type has a retry of its own.
+func NewRunner(backend Backend, retry Retry) *Runner {
+	return &Runner{
+		backend: backend,
+		retry:   retry,
+		types:   make(map[string]*jobType),
+		size:    1,
+	}
+}
+
+// Handle registers the handler of a job type, with the retry of the
+// runner when retry is nil. It is not safe to call once Run started.
+func (r *Runner) Handle(typ string, h Handler, retry *Retry) {
+	t := &jobType{handle: h, retry: r.retry}
+	if retry != nil {
+		t.retry = *retry
+	}
+	r.types[typ] = t
+}
+
+// OnAbandon sets f to be called with each job the runner gives up on,
+// after its last run or while waiting for a retry at shutdown, and the
+// error of its last run, so that it can be kept and enqueued again later.
+// It is not safe to call once Run started.
+func (r *Runner) OnAbandon(f func(ctx context.Context, j *Job, cause error)) {
+	r.onAbandon = f
+}
+
+// Every enqueues a job of typ, with no payload, every interval while Run
+// runs, unless one is still pending. typ must have been registered.
+func (r *Runner) Every(typ string, interval time.Duration) {
+	r.types[typ].every = interval
+}
+
+// Enqueue queues a job of typ with payload marshalled to JSON. It returns
+// ErrUnknownJob for types without a handler and ErrQueueFull when the
+// queue is full.
+func (r *Runner) Enqueue(ctx context.Context, typ string, payload any) error {
+	t, ok := r.types[typ]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, typ)
+	}
+
+	var raw json.RawMessage
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		raw = b
+	}
+
+	now := time.Now()
+	j := &Job{ID: uuid.NewString(), Type: typ, Payload: raw, EnqueuedAt: now, QueuedAt: now}
+	if err := r.backend.Push(ctx, j); err != nil {
+		return err
+	}
+	t.enqueued.Add(1)
+	t.pending.Add(1)
+	return nil
+}
+
+// Run starts the workers and the schedules, and returns once ctx is done,
+// the jobs running have completed and those waiting for a retry have been
+// dropped. Jobs left queued stay in the backend.
+func (r *Runner) Run(ctx context.Context) {
+	r.mu.Lock()
+	r.ctx = ctx
+	for len(r.stops) < r.size {
+		r.start()
+	}
+	r.mu.Unlock()
+
+	for typ, t := range r.types {
+		if t.every > 0 {
+			r.wg.Add(1)
+			go r.schedule(ctx, typ, t)
+		}
+	}
+
+	<-ctx.Done()
+	r.wg.Wait()
+	r.mu.Lock()
+	r.ctx, r.stops = nil, nil
+	r.mu.Unlock()
+
+	if n := r.backend.Len(); n > 0 {
+		log.Printf("Jobs: %d queued jobs left at shutdown", n)
+	}
+}
+
+// start runs a worker until Run's context is done or Resize stops it. It
+// is called with r.mu held.
+func (r *Runner) start() {
+	ctx := r.ctx
+	popCtx, stop := context.WithCancel(ctx)
+	r.stops = append(r.stops, stop)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			j, err := r.backend.Pop(popCtx)
+			if err != nil {
+				if popCtx.Err() == nil {
+					log.Printf("job pop: %s", err)
+				}
+				return
+			}
+			r.process(ctx, j)
+		}
+	}()
+}
+
+// Resize sets the number of workers. Workers beyond the new size stop
+// once done with their job.
+func (r *Runner) Resize(n int) error {
+	if err := Validate(n); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.size = n
+	if r.ctx == nil {
+		return nil
+	}
+	for len(r.stops) < n {
+		r.start()
+	}
+	for len(r.stops) > n {
+		r.stops[len(r.stops)-1]()
+		r.stops = r.stops[:len(r.stops)-1]
+	}
+	return nil
+}
+
+// Stats tells how many jobs are running and queued, and how long they
+// waited and took.
+func (r *Runner) Stats() Stats {
+	r.mu.Lock()
+	size := r.size
+	r.mu.Unlock()
+
+	s := Stats{
+		Workers:  size,
+		Busy:     int(r.busy.Load()),
+		Queued:   r.backend.Len(),
+		Capacity: r.backend.Cap(),
+	}
+	r.meter.Fill(&s)
+	return s
+}
+
+// JobStats returns the counts of each job type, sorted by type.
+func (r *Runner) JobStats() []JobStats {
+	stats := make([]JobStats, 0, len(r.types))
+	for typ, t := range r.types {
+		s := JobStats{
+			Type:      typ,
+			Pending:   t.pending.Load(),
+			Enqueued:  t.enqueued.Load(),
+			Succeeded: t.succeeded.Load(),
+			Failed:    t.failed.Load(),
+			Retried:   t.retried.Load(),
+			Abandoned: t.abandoned.Load(),
+		}
+		var m Stats
+		t.meter.Fill(&m)
+		s.AvgProcessingSeconds = m.AvgProcessingSeconds
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, k int) bool { return stats[i].Type < stats[k].Type })
+	return stats
+}
+
+// process runs j, retrying it after a delay when it fails and has runs
+// left. A job running at shutdown completes, so it is not cancelled with
+// ctx; one waiting for a retry is dropped.
+func (r *Runner) process(ctx context.Context, j *Job) {
+	t, ok := r.types[j.Type]
+	if !ok {
+		log.Printf("job %s: %s", j.ID, fmt.Errorf("%w: %s", ErrUnknownJob, j.Type))
+		return
+	}
+
+	r.busy.Add(1)
+	defer r.busy.Add(-1)
+
+	j.Attempt++
+	start := time.Now()
+	err := run(context.WithoutCancel(ctx), t.handle, j)
+	took := time.Since(start)
+	r.meter.Observe(start.Sub(j.QueuedAt), took)
+	t.meter.Observe(start.Sub(j.QueuedAt), took)
+
+	if err == nil {
+		t.succeeded.Add(1)
+		t.pending.Add(-1)
+		return
+	}
+	t.failed.Add(1)
+
+	if j.Attempt >= t.retry.Attempts {
+		log.Printf("job %s %s abandoned after %d attempts: %s", j.Type, j.ID, j.Attempt, err)
+		r.abandon(ctx, t, j, err)
+		return
+	}
+
+	delay := t.retry.delay(j.Attempt)
+	log.Printf("job %s %s attempt %d failed, retrying in %s: %s", j.Type, j.ID, j.Attempt, delay, err)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		select {
+		case <-ctx.Done():
+			r.abandon(ctx, t, j, err)
+		case <-time.After(delay):
+			j.QueuedAt = time.Now()
+			if perr := r.backend.Push(ctx, j); perr != nil {
+				log.Printf("job %s %s retry: %s", j.Type, j.ID, perr)
+				r.abandon(ctx, t, j, err)
+				return
+			}
+			t.retried.Add(1)
+		}
+	}()
+}
+
+// abandon gives up on j, passing it to the OnAbandon function. That may
+// outlive ctx, as at shutdown, so it is not cancelled with it.
+func (r *Runner) abandon(ctx context.Context, t *jobType, j *Job, cause error) {
+	t.abandoned.Add(1)
+	t.pending.Add(-1)
+	if r.onAbandon != nil {
+		r.onAbandon(context.WithoutCancel(ctx), j, cause)
+	}
+}
+
+// schedule enqueues a job of typ every t.every until ctx is done.
+func (r *Runner) schedule(ctx context.Context, typ string, t *jobType) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(t.every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if t.pending.Load() > 0 {
+				continue
+			}
+			if err := r.Enqueue(ctx, typ, nil); err != nil {
+				log.Printf("job %s schedule: %s", typ, err)
+			}
+		}
+	}
+}
+
+// run calls h, turning a panic into an error so that the job is retried
+// and the worker lives on.
+func run(ctx context.Context, h Handler, j *Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, j)
+}
@@ -0,0 +1,121 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testUtil "hello/util/test"
+	"hello/worker"
+)
+
+// eventually waits up to a second for cond.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func stats(r *worker.Runner, typ string) worker.JobStats {
+	for _, s := range r.JobStats() {
+		if s.Type == typ {
+			return s
+		}
+	}
+	return worker.JobStats{}
+}
+
+func TestRunner(t *testing.T) {
+	t.Parallel()
+
+	retry := worker.Retry{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	r := worker.NewRunner(worker.NewMemoryBackend(16), retry)
+
+	var sent atomic.Value
+	r.Handle("mail", func(_ context.Context, j *worker.Job) error {
+		var to string
+		if err := j.Decode(&to); err != nil {
+			return err
+		}
+		sent.Store(to)
+		return nil
+	}, nil)
+
+	// Fails twice, then succeeds on its last attempt.
+	var flaky atomic.Int64
+	r.Handle("flaky", func(context.Context, *worker.Job) error {
+		if flaky.Add(1) < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}, nil)
+
+	r.Handle("broken", func(context.Context, *worker.Job) error {
+		panic("broken")
+	}, &worker.Retry{Attempts: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	var ticks atomic.Int64
+	r.Handle("sweep", func(context.Context, *worker.Job) error {
+		ticks.Add(1)
+		return nil
+	}, nil)
+	r.Every("sweep", 2*time.Millisecond)
+
+	var abandoned atomic.Value
+	r.OnAbandon(func(_ context.Context, j *worker.Job, cause error) {
+		abandoned.Store(j.Type + " " + cause.Error())
+	})
+
+	ctx := context.Background()
+	testUtil.Equal(t, true, errors.Is(r.Enqueue(ctx, "nope", nil), worker.ErrUnknownJob))
+
+	testUtil.NoError(t, r.Enqueue(ctx, "mail", "reader@example.com"))
+	testUtil.NoError(t, r.Enqueue(ctx, "flaky", nil))
+	testUtil.NoError(t, r.Enqueue(ctx, "broken", nil))
+	testUtil.Equal(t, 3, r.Stats().Queued)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(runCtx)
+	}()
+	testUtil.NoError(t, r.Resize(2))
+
+	eventually(t, func() bool {
+		return stats(r, "flaky").Pending == 0 && stats(r, "broken").Pending == 0 && ticks.Load() >= 2
+	})
+	cancel()
+	<-done
+
+	testUtil.Equal(t, "reader@example.com", sent.Load().(string))
+	testUtil.Equal(t, worker.JobStats{Type: "mail", Enqueued: 1, Succeeded: 1}, zeroAvg(stats(r, "mail")))
+	testUtil.Equal(t, worker.JobStats{Type: "flaky", Enqueued: 1, Succeeded: 1, Failed: 2, Retried: 2}, zeroAvg(stats(r, "flaky")))
+	testUtil.Equal(t, worker.JobStats{Type: "broken", Enqueued: 1, Failed: 2, Retried: 1, Abandoned: 1}, zeroAvg(stats(r, "broken")))
+	testUtil.Equal(t, "broken panic: broken", abandoned.Load().(string))
+	testUtil.Equal(t, 2, r.Stats().Workers)
+	testUtil.Equal(t, 0, r.Stats().Busy)
+}
+
+func TestRunner_QueueFull(t *testing.T) {
+	t.Parallel()
+
+	r := worker.NewRunner(worker.NewMemoryBackend(1), worker.Retry{Attempts: 1})
+	r.Handle("mail", func(context.Context, *worker.Job) error { return nil }, nil)
+
+	testUtil.NoError(t, r.Enqueue(context.Background(), "mail", nil))
+	testUtil.Equal(t, worker.ErrQueueFull, r.Enqueue(context.Background(), "mail", nil))
+	testUtil.Equal(t, int64(1), stats(r, "mail").Pending)
+}
+
+func zeroAvg(s worker.JobStats) worker.JobStats {
+	s.AvgProcessingSeconds = 0
+	return s
+}
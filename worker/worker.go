@@ -0,0 +1,109 @@
+// Package worker describes pools of background workers to operators: how
+// many run, how much work waits for them, and how long it waits and takes.
+// Pools can be resized while running, so that a backlog can be worked off
+// without a restart.
+package worker
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxWorkers bounds the size of a pool.
+const MaxWorkers = 64
+
+var ErrInvalidSize = errors.New("worker: invalid pool size")
+
+// Stats describes a pool. Queued counts the work waiting, up to Capacity;
+// the averages are over the work processed since the instance started.
+type Stats struct {
+	Workers  int `json:"workers"`
+	Busy     int `json:"busy"`
+	Queued   int `json:"queued"`
+	Capacity int `json:"capacity"`
+
+	Processed            int64   `json:"processed"`
+	AvgWaitSeconds       float64 `json:"avg_wait_seconds"`
+	AvgProcessingSeconds float64 `json:"avg_processing_seconds"`
+}
+
+// Pool is a resizable pool of workers.
+type Pool interface {
+	Stats() Stats
+	// Resize sets the number of workers. Work running on workers beyond
+	// the new size completes first.
+	Resize(n int) error
+}
+
+// Validate returns ErrInvalidSize unless n is between 1 and MaxWorkers.
+func Validate(n int) error {
+	if n < 1 || n > MaxWorkers {
+		return ErrInvalidSize
+	}
+	return nil
+}
+
+// Meter accumulates how long processed work waited and took. It is safe
+// for concurrent use.
+type Meter struct {
+	processed  atomic.Int64
+	wait       atomic.Int64
+	processing atomic.Int64
+}
+
+// Observe counts a piece of work that waited wait before taking took.
+func (m *Meter) Observe(wait, took time.Duration) {
+	m.processed.Add(1)
+	m.wait.Add(int64(wait))
+	m.processing.Add(int64(took))
+}
+
+// Fill sets the counts and averages of s.
+func (m *Meter) Fill(s *Stats) {
+	s.Processed = m.processed.Load()
+	if s.Processed == 0 {
+		return
+	}
+	s.AvgWaitSeconds = time.Duration(m.wait.Load() / s.Processed).Seconds()
+	s.AvgProcessingSeconds = time.Duration(m.processing.Load() / s.Processed).Seconds()
+}
+
+// Registry names the pools of the instance.
+type Registry struct {
+	mu    sync.RWMutex
+	pools map[string]Pool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{pools: make(map[string]Pool)}
+}
+
+// Register adds a pool under name, replacing any of the same name.
+func (r *Registry) Register(name string, p Pool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pools[name] = p
+}
+
+// Get returns the pool of name, if any.
+func (r *Registry) Get(name string) (Pool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.pools[name]
+	return p, ok
+}
+
+// Names returns the names of the pools, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.pools))
+	for name := range r.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
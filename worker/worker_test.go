@@ -0,0 +1,35 @@
+package worker_test
+
+import (
+	"testing"
+	"time"
+
+	testUtil "hello/util/test"
+	"hello/worker"
+)
+
+func TestMeter(t *testing.T) {
+	t.Parallel()
+
+	var m worker.Meter
+	s := worker.Stats{}
+	m.Fill(&s)
+	testUtil.Equal(t, int64(0), s.Processed)
+	testUtil.Equal(t, 0.0, s.AvgWaitSeconds)
+
+	m.Observe(time.Second, 2*time.Second)
+	m.Observe(0, 4*time.Second)
+	m.Fill(&s)
+	testUtil.Equal(t, int64(2), s.Processed)
+	testUtil.Equal(t, 0.5, s.AvgWaitSeconds)
+	testUtil.Equal(t, 3.0, s.AvgProcessingSeconds)
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	testUtil.Equal(t, worker.ErrInvalidSize, worker.Validate(0))
+	testUtil.NoError(t, worker.Validate(1))
+	testUtil.NoError(t, worker.Validate(worker.MaxWorkers))
+	testUtil.Equal(t, worker.ErrInvalidSize, worker.Validate(worker.MaxWorkers+1))
+}